@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+
+	"dns-bench/benchmark"
+)
+
+// anonymizer replaces domain names in exports with a keyed hash that keeps
+// only the top-level domain, e.g. "3f2a9c1b7d4e.com". The key is random per
+// run: a name maps to the same hash throughout one run, so per-domain
+// analysis still works, but a dictionary of popular names can't reverse it.
+// A nil anonymizer leaves everything as it is.
+type anonymizer struct {
+	key []byte
+}
+
+// newAnonymizer returns an anonymizer with a fresh random key.
+func newAnonymizer() (*anonymizer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &anonymizer{key: key}, nil
+}
+
+// domain returns the anonymized form of name.
+func (a *anonymizer) domain(name string) string {
+	if a == nil || name == "" {
+		return name
+	}
+	trimmed := strings.TrimSuffix(name, ".")
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(strings.ToLower(trimmed)))
+	out := hex.EncodeToString(mac.Sum(nil))[:12]
+	if i := strings.LastIndexByte(trimmed, '.'); i >= 0 {
+		out += strings.ToLower(trimmed[i:])
+	}
+	if trimmed != name {
+		out += "."
+	}
+	return out
+}
+
+// result anonymizes the names in res, including any the error text repeats.
+func (a *anonymizer) result(res benchmark.Result) benchmark.Result {
+	if a == nil {
+		return res
+	}
+	if res.Error != nil {
+		msg := res.Error.Error()
+		redacted := msg
+		// The query name usually contains the domain, so it goes first
+		for _, name := range []string{res.QueryName, res.Domain} {
+			if name != "" {
+				redacted = strings.ReplaceAll(redacted, strings.TrimSuffix(name, "."), strings.TrimSuffix(a.domain(name), "."))
+			}
+		}
+		if redacted != msg {
+			res.Error = errors.New(redacted)
+		}
+	}
+	res.Domain = a.domain(res.Domain)
+	res.QueryName = a.domain(res.QueryName)
+	return res
+}
+
+// results returns anonymized copies of results.
+func (a *anonymizer) results(results []benchmark.Result) []benchmark.Result {
+	if a == nil {
+		return results
+	}
+	out := make([]benchmark.Result, len(results))
+	for i, res := range results {
+		out[i] = a.result(res)
+	}
+	return out
+}
+
+// sink wraps an OnResult hook so it only sees anonymized results.
+func (a *anonymizer) sink(fn func(benchmark.Result)) func(benchmark.Result) {
+	if a == nil {
+		return fn
+	}
+	return func(res benchmark.Result) { fn(a.result(res)) }
+}
+
+// provenance returns a copy of p with the domain list anonymized.
+func (a *anonymizer) provenance(p *provenance) *provenance {
+	if a == nil {
+		return p
+	}
+	anon := *p
+	anon.Config.Domains = make([]string, len(p.Config.Domains))
+	for i, d := range p.Config.Domains {
+		anon.Config.Domains[i] = a.domain(d)
+	}
+	// Itemised domain warnings name the domain; the summary keeps the count
+	anon.Warnings = slices.DeleteFunc(slices.Clone(p.Warnings), func(w runWarning) bool {
+		return w.Detail && w.Source == "domains"
+	})
+	return &anon
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestAnonymizeDomain(t *testing.T) {
+	a, err := newAnonymizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := a.domain("www.Example.COM")
+	if !strings.HasSuffix(got, ".com") || strings.Contains(got, "example") || len(got) != len("000000000000.com") {
+		t.Errorf("domain = %q", got)
+	}
+	if a.domain("www.example.com") != got {
+		t.Error("the same name in another case anonymized differently")
+	}
+	if a.domain("example.com") == got {
+		t.Error("different names anonymized to the same hash")
+	}
+	if d := a.domain("kubernetes"); strings.Contains(d, ".") {
+		t.Errorf("single-label name kept a dot: %q", d)
+	}
+	if d := a.domain("example.org."); !strings.HasSuffix(d, ".org.") {
+		t.Errorf("trailing dot lost: %q", d)
+	}
+
+	other, err := newAnonymizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.domain("www.example.com") == got {
+		t.Error("two runs share a key")
+	}
+
+	var none *anonymizer
+	if none.domain("example.com") != "example.com" {
+		t.Error("nil anonymizer changed a name")
+	}
+}
+
+func TestAnonymizeResult(t *testing.T) {
+	a, err := newAnonymizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := benchmark.Result{
+		Server:    "1.1.1.1",
+		Domain:    "secret.example",
+		QueryName: "secret.example.corp.internal.",
+		Error:     errors.New("lookup secret.example.corp.internal on 1.1.1.1: no such host"),
+	}
+	got := a.result(res)
+	for _, s := range []string{got.Domain, got.QueryName, got.Error.Error()} {
+		if strings.Contains(s, "secret") {
+			t.Errorf("name leaked: %q", s)
+		}
+	}
+	if got.Server != res.Server || !strings.Contains(got.Error.Error(), "1.1.1.1: no such host") {
+		t.Errorf("unexpected changes: %+v", got)
+	}
+	if res.Domain != "secret.example" {
+		t.Error("original result modified")
+	}
+
+	prov := &provenance{Config: Config{Domains: []string{"secret.example"}}}
+	if d := a.provenance(prov).Config.Domains[0]; d != a.domain("secret.example") {
+		t.Errorf("provenance domain = %q", d)
+	}
+	if prov.Config.Domains[0] != "secret.example" {
+		t.Error("original provenance modified")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// answerStats counts records per response section for a server and how
+// often it answered NOERROR with an empty answer section. A resolver that
+// "succeeds" with nothing for names other servers resolve is broken in a
+// way latency and error rates don't show.
+type answerStats struct {
+	Count         int
+	Empty         int
+	EmptyPct      float64
+	AvgAnswers    float64
+	AvgAuthority  float64
+	AvgAdditional float64
+	// EmptyDomains lists the domains that came back empty at least once
+	EmptyDomains []string
+
+	answers, authority, additional int
+	empty                          map[string]bool
+}
+
+func (a *answerStats) add(res benchmark.Result) {
+	a.Count++
+	a.answers += res.Answers
+	a.authority += res.Authority
+	a.additional += res.Additional
+	if !res.EmptyAnswer {
+		return
+	}
+	a.Empty++
+	if a.empty == nil {
+		a.empty = make(map[string]bool)
+	}
+	a.empty[res.Domain] = true
+}
+
+func (a *answerStats) finish() {
+	if a.Count == 0 {
+		return
+	}
+	n := float64(a.Count)
+	a.EmptyPct = float64(a.Empty) / n * 100
+	a.AvgAnswers = float64(a.answers) / n
+	a.AvgAuthority = float64(a.authority) / n
+	a.AvgAdditional = float64(a.additional) / n
+	a.EmptyDomains = a.EmptyDomains[:0]
+	for d := range a.empty {
+		a.EmptyDomains = append(a.EmptyDomains, d)
+	}
+	sort.Strings(a.EmptyDomains)
+}
+
+// answeredElsewhere returns the domains in s.Answers.EmptyDomains that some
+// other server answered with at least one record.
+func answeredElsewhere(s *ServerStats, results []benchmark.Result) []string {
+	empty := make(map[string]bool, len(s.Answers.EmptyDomains))
+	for _, d := range s.Answers.EmptyDomains {
+		empty[d] = true
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, res := range results {
+		if res.Server == s.Server || res.Error != nil || res.Answers == 0 {
+			continue
+		}
+		if empty[res.Domain] && !seen[res.Domain] {
+			seen[res.Domain] = true
+			out = append(out, res.Domain)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// printAnswers shows the average section sizes and the empty NOERROR rate
+// for each server, and names the domains a server returned empty while
+// another answered them. It stays quiet when no server returned an empty
+// answer.
+func printAnswers(stats []*ServerStats, results []benchmark.Result) {
+	found := false
+	for _, s := range stats {
+		if s.Answers.Empty > 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+
+	fmt.Printf("\nResponse Sections\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tAVG ANSWER\tAVG AUTHORITY\tAVG ADDITIONAL\tEMPTY NOERROR"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		a := s.Answers
+		if _, err := fmt.Fprintf(w, "%s\t%.1f\t%.1f\t%.1f\t%d (%.1f%%)\n", s.Server, a.AvgAnswers, a.AvgAuthority, a.AvgAdditional, a.Empty, a.EmptyPct); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	for _, s := range stats {
+		if domains := answeredElsewhere(s, results); len(domains) > 0 {
+			fmt.Printf("⚠️  %s returned empty NOERROR answers for %d domain(s) other servers resolved: %s\n", s.Server, len(domains), strings.Join(domains, ", "))
+		}
+	}
+}
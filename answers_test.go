@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestAnswerStats(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "a.com", Duration: time.Millisecond, Answers: 2, Additional: 1},
+		{Server: "8.8.8.8", Domain: "b.com", Duration: time.Millisecond, Answers: 1, Authority: 2},
+		{Server: "10.0.0.1", Domain: "a.com", Duration: time.Millisecond, Answers: 2},
+		{Server: "10.0.0.1", Domain: "b.com", Duration: time.Millisecond, Authority: 1, EmptyAnswer: true},
+		{Server: "10.0.0.1", Domain: "b.com", Duration: time.Millisecond, Authority: 1, EmptyAnswer: true},
+		{Server: "10.0.0.1", Domain: "c.com", Duration: time.Millisecond, EmptyAnswer: true},
+	}
+
+	stats := calculateStats(results)
+	byServer := make(map[string]*ServerStats)
+	for _, s := range stats {
+		byServer[s.Server] = s
+	}
+
+	google := byServer["8.8.8.8"].Answers
+	if google.Empty != 0 || google.AvgAnswers != 1.5 || google.AvgAuthority != 1 || google.AvgAdditional != 0.5 {
+		t.Errorf("unexpected stats for 8.8.8.8: %+v", google)
+	}
+
+	local := byServer["10.0.0.1"]
+	if local.Answers.Empty != 3 || local.Answers.EmptyPct != 75 {
+		t.Errorf("expected 3 empty answers (75%%), got %d (%.1f%%)", local.Answers.Empty, local.Answers.EmptyPct)
+	}
+	if want := []string{"b.com", "c.com"}; !reflect.DeepEqual(local.Answers.EmptyDomains, want) {
+		t.Errorf("expected empty domains %v, got %v", want, local.Answers.EmptyDomains)
+	}
+	// Only b.com was resolved by another server; c.com may simply have no A record.
+	if got := answeredElsewhere(local, results); !reflect.DeepEqual(got, []string{"b.com"}) {
+		t.Errorf("expected b.com answered elsewhere, got %v", got)
+	}
+}
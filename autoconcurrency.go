@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+const (
+	// maxAutoConcurrency is the highest concurrency level -auto-concurrency tries.
+	maxAutoConcurrency = 256
+	// autoProbeDomains caps how many domains each probe cycles through.
+	autoProbeDomains = 50
+	// autoProbeQueries is the minimum number of queries measured per level;
+	// higher levels send 10 queries per worker so every worker stays busy.
+	autoProbeQueries = 100
+	// autoInflation is how far the median may rise above the single-worker
+	// baseline before a level counts as contended.
+	autoInflation = 0.25
+	// autoLossMargin is how many percentage points the loss may rise above
+	// the baseline before a level counts as contended.
+	autoLossMargin = 5.0
+)
+
+// concurrencyProbe is the outcome of measuring one concurrency level.
+type concurrencyProbe struct {
+	Level    int
+	Median   time.Duration
+	LossPct  float64
+	Inflated bool
+}
+
+// autoConcurrency sweeps concurrency levels in powers of two up to maxLevel
+// and returns the highest level before latency or loss starts inflating
+// relative to a single worker, along with the measurements. The sweep stops
+// at the first inflated level.
+func autoConcurrency(config benchmark.Config, maxLevel int) (int, []concurrencyProbe) {
+	probe := config
+	probe.Duration = 0
+	probe.Verbose = false
+	probe.LogSample = 0
+	probe.ShowProgress = false
+	probe.OnResult = nil
+	probe.Domains = config.Domains[:min(len(config.Domains), autoProbeDomains)]
+
+	// Warm resolver caches first so the baseline isn't inflated by misses
+	// that later levels would not see.
+	warm := probe
+	warm.Iterations = 1
+	warm.Concurrency = min(4, maxLevel)
+	benchmark.Run(warm)
+
+	var probes []concurrencyProbe
+	chosen := 1
+	for _, level := range scalingLevels(maxLevel) {
+		probe.Concurrency = level
+		probe.Iterations = probeIterations(len(probe.Servers), len(probe.Domains), max(autoProbeQueries, level*10))
+		median, loss := medianLatency(benchmark.Run(probe))
+
+		p := concurrencyProbe{Level: level, Median: median, LossPct: loss}
+		if len(probes) > 0 {
+			p.Inflated = contended(probes[0], p)
+		}
+		probes = append(probes, p)
+		if p.Inflated {
+			break
+		}
+		chosen = level
+	}
+	return chosen, probes
+}
+
+// contended reports whether p shows inflated latency or loss compared with
+// the single-worker baseline.
+func contended(baseline, p concurrencyProbe) bool {
+	if p.Median == 0 {
+		return true
+	}
+	limit := time.Duration(float64(baseline.Median) * (1 + autoInflation))
+	return p.Median > limit || p.LossPct > baseline.LossPct+autoLossMargin
+}
+
+// probeIterations returns how many iterations over servers x domains are
+// needed to send at least queries queries.
+func probeIterations(servers, domains, queries int) int {
+	perIteration := max(servers*domains, 1)
+	return (queries + perIteration - 1) / perIteration
+}
+
+// medianLatency returns the median duration of the successful results and
+// the percentage of failed ones.
+func medianLatency(results []benchmark.Result) (time.Duration, float64) {
+	var durations []time.Duration
+	for _, res := range results {
+		if res.Error == nil {
+			durations = append(durations, res.Duration)
+		}
+	}
+	if len(results) == 0 {
+		return 0, 0
+	}
+	loss := float64(len(results)-len(durations)) / float64(len(results)) * 100
+	if len(durations) == 0 {
+		return 0, loss
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2], loss
+}
+
+func printAutoConcurrency(chosen int, probes []concurrencyProbe) {
+	fmt.Printf("\nConcurrency Auto-Tuning (median latency vs. 1 worker)\n\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "CONCURRENCY\tMEDIAN\tLOSS %\t"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, p := range probes {
+		note := ""
+		switch {
+		case p.Level == chosen:
+			note = "<- selected"
+		case p.Inflated:
+			note = "contended"
+		}
+		if _, err := fmt.Fprintf(w, "%d\t%v\t%.2f%%\t%s\n", p.Level, p.Median, p.LossPct, note); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+	fmt.Printf("Using concurrency %d\n\n", chosen)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestContended(t *testing.T) {
+	baseline := concurrencyProbe{Level: 1, Median: 10 * time.Millisecond, LossPct: 1}
+
+	tests := []struct {
+		name  string
+		probe concurrencyProbe
+		want  bool
+	}{
+		{"within tolerance", concurrencyProbe{Median: 12 * time.Millisecond, LossPct: 1}, false},
+		{"latency inflated", concurrencyProbe{Median: 13 * time.Millisecond, LossPct: 1}, true},
+		{"loss inflated", concurrencyProbe{Median: 10 * time.Millisecond, LossPct: 7}, true},
+		{"no successes", concurrencyProbe{LossPct: 100}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contended(baseline, tt.probe); got != tt.want {
+				t.Errorf("contended() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeIterations(t *testing.T) {
+	if got := probeIterations(2, 50, 100); got != 1 {
+		t.Errorf("expected 1 iteration for 100 queries over 2x50, got %d", got)
+	}
+	if got := probeIterations(1, 3, 100); got != 34 {
+		t.Errorf("expected 34 iterations for 100 queries over 1x3, got %d", got)
+	}
+}
+
+func TestMedianLatency(t *testing.T) {
+	results := []benchmark.Result{
+		{Duration: 30 * time.Millisecond},
+		{Duration: 10 * time.Millisecond},
+		{Duration: 20 * time.Millisecond},
+		{Duration: time.Second, Error: errors.New("timeout")},
+	}
+	median, loss := medianLatency(results)
+	if median != 20*time.Millisecond {
+		t.Errorf("expected 20ms median, got %v", median)
+	}
+	if loss != 25 {
+		t.Errorf("expected 25%% loss, got %.1f", loss)
+	}
+}
+
+func TestAutoConcurrencyMockServer(t *testing.T) {
+	mock, err := benchmark.StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mock.Close()
+
+	chosen, probes := autoConcurrency(benchmark.Config{
+		Servers: []string{mock.Addr},
+		Domains: []string{"example.com", "example.org"},
+		Timeout: time.Second,
+	}, 4)
+	if chosen < 1 || chosen > 4 {
+		t.Errorf("expected a level between 1 and 4, got %d", chosen)
+	}
+	if len(probes) == 0 || probes[0].Level != 1 || probes[0].Median == 0 {
+		t.Errorf("expected a successful single-worker baseline, got %+v", probes)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// defaultBaselineMinRuns is how many runs a provider, protocol and region
+// needs before the baseline subcommand publishes a baseline for it: fewer
+// say little about what is normal, and could single out who contributed.
+const defaultBaselineMinRuns = 5
+
+// baselineSummary is one run's contribution to a crowd-sourced baseline:
+// for each public provider benchmarked, by protocol, the median and p90
+// latency and the loss. It holds nothing about the user, their network or
+// the domains queried beyond the region they give.
+type baselineSummary struct {
+	Region string `json:"region,omitempty"`
+	// Date is the day of the run, no finer
+	Date    string          `json:"date"`
+	Version string          `json:"version"`
+	Servers []baselineEntry `json:"servers"`
+}
+
+// baselineEntry is one provider and protocol in a run's summary.
+type baselineEntry struct {
+	Provider string  `json:"provider"`
+	Protocol string  `json:"protocol"`
+	Queries  int     `json:"queries"`
+	MedianMs float64 `json:"median_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	LossPct  float64 `json:"loss_pct"`
+}
+
+// baselineFile is what the baseline subcommand builds from summaries and
+// -baseline compares against.
+type baselineFile struct {
+	Generated time.Time  `json:"generated"`
+	Baselines []baseline `json:"baselines"`
+}
+
+// baseline is the spread of run medians for a provider and protocol in a
+// region; Region is empty for the baseline over every region.
+type baseline struct {
+	Provider string  `json:"provider"`
+	Protocol string  `json:"protocol"`
+	Region   string  `json:"region,omitempty"`
+	Runs     int     `json:"runs"`
+	P25Ms    float64 `json:"p25_ms"`
+	P50Ms    float64 `json:"p50_ms"`
+	P75Ms    float64 `json:"p75_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+}
+
+// summarizeBaseline builds the run's baseline summary from its results.
+// Only servers of a known public provider (see knownProviders) are
+// included, each provider's servers of a protocol pooled together.
+func summarizeBaseline(results []benchmark.Result, region string, now time.Time) baselineSummary {
+	type key struct{ provider, protocol string }
+	durations := make(map[key][]time.Duration)
+	totals := make(map[key]int)
+	var keys []key
+	for _, res := range results {
+		p := providerOf(res.Server)
+		if p == nil {
+			continue
+		}
+		k := key{p.Name, benchmark.ServerProtocol(res.Server)}
+		if totals[k] == 0 {
+			keys = append(keys, k)
+		}
+		totals[k]++
+		if res.Error == nil {
+			durations[k] = append(durations[k], res.Duration)
+		}
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.provider, b.provider), cmp.Compare(a.protocol, b.protocol))
+	})
+
+	summary := baselineSummary{Region: strings.ToUpper(region), Date: now.UTC().Format(time.DateOnly), Version: Version}
+	for _, k := range keys {
+		d := durations[k]
+		slices.Sort(d)
+		summary.Servers = append(summary.Servers, baselineEntry{
+			Provider: k.provider,
+			Protocol: k.protocol,
+			Queries:  totals[k],
+			MedianMs: millis(percentile(d, 50)),
+			P90Ms:    millis(percentile(d, 90)),
+			LossPct:  float64(totals[k]-len(d)) / float64(totals[k]) * 100,
+		})
+	}
+	return summary
+}
+
+// writeBaselineSummary writes summary to path as indented JSON.
+func writeBaselineSummary(summary baselineSummary, path string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// readBaselines reads a baselines file from a path or http(s) URL.
+func readBaselines(source string, warns *warningLog) (*baselineFile, error) {
+	path, err := localList(source, warns)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	// As do files published before them
+	for i := range file.Baselines {
+		file.Baselines[i].Protocol = benchmark.ProtocolLabel(file.Baselines[i].Protocol)
+	}
+	return &file, nil
+}
+
+// buildBaselines aggregates run summaries into baselines per provider,
+// protocol and region, and per provider and protocol over all regions,
+// leaving out those with fewer than minRuns runs.
+func buildBaselines(summaries []baselineSummary, minRuns int, now time.Time) baselineFile {
+	type key struct{ provider, protocol, region string }
+	medians := make(map[key][]float64)
+	for _, s := range summaries {
+		for _, e := range s.Servers {
+			if e.Queries == 0 || e.LossPct >= 100 {
+				continue
+			}
+			medians[key{e.Provider, e.Protocol, ""}] = append(medians[key{e.Provider, e.Protocol, ""}], e.MedianMs)
+			if s.Region != "" {
+				k := key{e.Provider, e.Protocol, strings.ToUpper(s.Region)}
+				medians[k] = append(medians[k], e.MedianMs)
+			}
+		}
+	}
+
+	file := baselineFile{Generated: now.UTC()}
+	for k, m := range medians {
+		if len(m) < minRuns {
+			continue
+		}
+		slices.Sort(m)
+		file.Baselines = append(file.Baselines, baseline{
+			Provider: k.provider,
+			Protocol: k.protocol,
+			Region:   k.region,
+			Runs:     len(m),
+			P25Ms:    percentile(m, 25),
+			P50Ms:    percentile(m, 50),
+			P75Ms:    percentile(m, 75),
+			P90Ms:    percentile(m, 90),
+		})
+	}
+	slices.SortFunc(file.Baselines, func(a, b baseline) int {
+		return cmp.Or(cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.Protocol, b.Protocol), cmp.Compare(a.Region, b.Region))
+	})
+	return file
+}
+
+// baselineCheck places one of the run's providers in its baseline.
+type baselineCheck struct {
+	Entry    baselineEntry
+	Baseline *baseline
+	Verdict  string
+}
+
+// checkBaselines finds the baseline for each provider and protocol in
+// summary, preferring summary's region over all regions, and says where
+// the run's median falls in it.
+func checkBaselines(summary baselineSummary, file *baselineFile) []baselineCheck {
+	find := func(provider, protocol, region string) *baseline {
+		for i, b := range file.Baselines {
+			if b.Provider == provider && b.Protocol == protocol && strings.EqualFold(b.Region, region) {
+				return &file.Baselines[i]
+			}
+		}
+		return nil
+	}
+
+	var checks []baselineCheck
+	for _, e := range summary.Servers {
+		b := find(e.Provider, e.Protocol, summary.Region)
+		if b == nil && summary.Region != "" {
+			b = find(e.Provider, e.Protocol, "")
+		}
+		c := baselineCheck{Entry: e, Baseline: b}
+		switch {
+		case b == nil:
+			c.Verdict = "no baseline"
+		case e.LossPct >= 100:
+			c.Verdict = "no answers"
+		case e.MedianMs > b.P90Ms:
+			c.Verdict = "⚠️  slower than 90% of runs: likely a local problem"
+		case e.MedianMs > b.P75Ms:
+			c.Verdict = "slower than most runs"
+		case e.MedianMs < b.P25Ms:
+			c.Verdict = "faster than most runs"
+		default:
+			c.Verdict = "typical"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// printBaselines compares the run's median latency per provider with the
+// crowd-sourced baselines.
+func printBaselines(checks []baselineCheck) {
+	if len(checks) == 0 {
+		return
+	}
+
+	fmt.Printf("\nCompared with Baselines (run medians of other users)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "PROVIDER\tPROTOCOL\tYOUR MEDIAN\tREGION\tRUNS\tTYPICAL (P25-P75)\tP90\t"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	ms := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Millisecond)).Round(100 * time.Microsecond)
+	}
+	for _, c := range checks {
+		region, runs, typical, p90 := "-", "-", "-", "-"
+		if b := c.Baseline; b != nil {
+			region = cmp.Or(b.Region, "all")
+			runs = fmt.Sprint(b.Runs)
+			typical = fmt.Sprintf("%v-%v", ms(b.P25Ms), ms(b.P75Ms))
+			p90 = ms(b.P90Ms).String()
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%s\t%s\t%s\n",
+			c.Entry.Provider, c.Entry.Protocol, ms(c.Entry.MedianMs), region, runs, typical, p90, c.Verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
+
+// runBaseline implements the baseline subcommand, which aggregates run
+// summaries written with -baseline-summary into a baselines file.
+func runBaseline(args []string) error {
+	fs := flag.NewFlagSet("baseline", flag.ExitOnError)
+	var output string
+	var minRuns int
+	fs.StringVar(&output, "o", "baselines.json", "Output baselines file")
+	fs.IntVar(&minRuns, "min-runs", defaultBaselineMinRuns, "Runs a provider, protocol and region needs to get a baseline")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s baseline [options] <summary.json>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one summary")
+	}
+
+	var summaries []baselineSummary
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var s baselineSummary
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		// Summaries from before the protocol labels name the scheme
+		for i := range s.Servers {
+			s.Servers[i].Protocol = benchmark.ProtocolLabel(s.Servers[i].Protocol)
+		}
+		summaries = append(summaries, s)
+	}
+
+	file := buildBaselines(summaries, minRuns, time.Now())
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("%d baselines from %d summaries written to %s\n", len(file.Baselines), len(summaries), output)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestSummarizeBaseline(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	results := []benchmark.Result{
+		{Server: "1.1.1.1", Domain: "secret.example", Duration: ms(10)},
+		{Server: "1.0.0.1", Domain: "secret.example", Duration: ms(30)},
+		{Server: "1.1.1.1", Domain: "secret.example", Duration: ms(20)},
+		{Server: "1.1.1.1", Domain: "secret.example", Error: errors.New("timeout")},
+		{Server: "https://dns.google/dns-query", Domain: "secret.example", Duration: ms(40)},
+		{Server: "192.168.1.1", Domain: "secret.example", Duration: ms(2)},
+	}
+	summary := summarizeBaseline(results, "gb", time.Date(2026, 5, 1, 13, 45, 0, 0, time.UTC))
+	if summary.Region != "GB" || summary.Date != "2026-05-01" {
+		t.Errorf("expected region GB on 2026-05-01, got %q %q", summary.Region, summary.Date)
+	}
+	if len(summary.Servers) != 2 {
+		t.Fatalf("expected Cloudflare udp and Google doh only, got %+v", summary.Servers)
+	}
+	cf := summary.Servers[0]
+	if cf.Provider != "Cloudflare" || cf.Protocol != "udp" || cf.Queries != 4 || cf.MedianMs != 20 || cf.LossPct != 25 {
+		t.Errorf("expected Cloudflare's servers pooled (4 queries, median 20ms, 25%% loss), got %+v", cf)
+	}
+	if g := summary.Servers[1]; g.Provider != "Google" || g.Protocol != "doh" {
+		t.Errorf("expected Google over doh, got %+v", g)
+	}
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeBaselineSummary(summary, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret.example") || strings.Contains(string(data), "192.168") {
+		t.Errorf("expected no domains or private servers in the summary, got %s", data)
+	}
+}
+
+func TestBuildAndCheckBaselines(t *testing.T) {
+	var summaries []baselineSummary
+	for i := 1; i <= 10; i++ {
+		region := "GB"
+		if i > 6 {
+			region = "AU"
+		}
+		summaries = append(summaries, baselineSummary{Region: region, Servers: []baselineEntry{
+			{Provider: "Quad9", Protocol: "udp", Queries: 100, MedianMs: float64(i * 10)},
+		}})
+	}
+	file := buildBaselines(summaries, 5, time.Now())
+	if len(file.Baselines) != 2 {
+		t.Fatalf("expected Quad9 baselines for all regions and GB (AU has too few runs), got %+v", file.Baselines)
+	}
+	all, gb := file.Baselines[0], file.Baselines[1]
+	if all.Region != "" || all.Runs != 10 || all.P50Ms != 50 || all.P90Ms != 90 {
+		t.Errorf("unexpected overall baseline %+v", all)
+	}
+	if gb.Region != "GB" || gb.Runs != 6 || gb.P25Ms != 20 || gb.P75Ms != 50 || gb.P90Ms != 60 {
+		t.Errorf("unexpected GB baseline %+v", gb)
+	}
+
+	for _, tc := range []struct {
+		region string
+		median float64
+		want   string
+	}{
+		{"GB", 45, "typical"},
+		{"gb", 55, "slower than most"},
+		{"GB", 70, "likely a local problem"},
+		{"GB", 10, "faster than most"},
+		{"AU", 45, "typical"}, // falls back to all regions
+	} {
+		summary := baselineSummary{Region: strings.ToUpper(tc.region), Servers: []baselineEntry{
+			{Provider: "Quad9", Protocol: "udp", Queries: 10, MedianMs: tc.median},
+		}}
+		checks := checkBaselines(summary, &file)
+		if len(checks) != 1 || !strings.Contains(checks[0].Verdict, tc.want) {
+			t.Errorf("%s %vms: expected %q, got %+v", tc.region, tc.median, tc.want, checks)
+		}
+	}
+	checks := checkBaselines(baselineSummary{Servers: []baselineEntry{{Provider: "Google", Protocol: "udp", Queries: 1}}}, &file)
+	if checks[0].Baseline != nil || checks[0].Verdict != "no baseline" {
+		t.Errorf("expected no baseline for Google, got %+v", checks[0])
+	}
+}
+
+func TestRunBaseline(t *testing.T) {
+	dir := t.TempDir()
+	var args []string
+	for i := range 5 {
+		path := filepath.Join(dir, fmt.Sprintf("run%d.json", i))
+		summary := baselineSummary{Region: "NZ", Servers: []baselineEntry{{Provider: "Cloudflare", Protocol: "tls", Queries: 50, MedianMs: 30}}}
+		if err := writeBaselineSummary(summary, path); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+	out := filepath.Join(dir, "baselines.json")
+	if err := runBaseline(append([]string{"-o", out}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	file, err := readBaselines(out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Summaries written before the protocol labels are relabelled
+	if len(file.Baselines) != 2 || file.Baselines[1].Region != "NZ" || file.Baselines[1].P50Ms != 30 || file.Baselines[1].Protocol != "dot" {
+		t.Errorf("expected overall and NZ baselines for Cloudflare over DoT, got %+v", file.Baselines)
+	}
+}
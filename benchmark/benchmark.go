@@ -1,19 +1,28 @@
 package benchmark
 
 import (
-	"bytes"
+	"cmp"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"golang.org/x/net/http2"
 )
 
@@ -23,99 +32,720 @@ type Result struct {
 	Domain   string
 	Duration time.Duration
 	Error    error
+	// KeepaliveAdvertised reports whether a DoT response carried the
+	// edns-tcp-keepalive option (RFC 7828); KeepaliveTimeout is its value.
+	KeepaliveAdvertised bool
+	KeepaliveTimeout    time.Duration
+	// Network tags the result with the network it was measured from
+	Network string
+	// CNAMEDepth is the number of CNAME hops in the answer before the final
+	// name; ChainUnfollowed is set when the chain ends without an address.
+	CNAMEDepth      int
+	ChainUnfollowed bool
+	// TTFB is the time until the first byte of a DoH response arrived; the
+	// rest of Duration was spent reading and unpacking the body.
+	TTFB time.Duration
+	// Connect is the part of Duration spent opening a new connection (TCP
+	// and TLS handshakes) for a DoT or https:// DoH query; zero when the
+	// query went over a connection that was already open.
+	Connect time.Duration
+	// Resumed is set when that new connection resumed an earlier TLS
+	// session (see Client.TLSResume) instead of a full handshake. h3://
+	// connections aren't traced, so for them it is only set with
+	// NoKeepalive, when every query opens one.
+	Resumed bool
+	// Transport is the transport that produced the answer in fallback mode
+	// ("udp", "tcp" or "tls") and Fallbacks the number of times the query
+	// had to move to the next one; Duration includes every attempt.
+	Transport string
+	Fallbacks int
+	// SVCB holds the parsed answer of HTTPS/SVCB queries; nil for other types.
+	SVCB *SVCBInfo
+	// With a search list, SearchQueries is the number of queries the lookup
+	// took, SearchOverhead the time spent on the ones that came back
+	// NXDOMAIN or empty before the last, and QueryName the last name tried.
+	// With CacheBust, QueryName is the random name actually queried.
+	SearchQueries  int
+	SearchOverhead time.Duration
+	QueryName      string
+	// HTTPVersion is the protocol a DoH response arrived over, e.g.
+	// "HTTP/2.0" or "HTTP/3.0" for h3:// servers.
+	HTTPVersion string
+	// Protocol is the transport the last query went over: "udp", "tcp",
+	// "dot", "doh" or "doh3" (see ProtocolLabel). In fallback mode it is
+	// Transport's label.
+	Protocol string
+	// Answers, Authority and Additional count the records in each section
+	// of the response (the EDNS OPT record excluded). EmptyAnswer is set for
+	// NOERROR responses with nothing in the answer section.
+	Answers     int
+	Authority   int
+	Additional  int
+	EmptyAnswer bool
+	// Rcode is the response code of the answer, e.g. "NOERROR" or
+	// "SERVFAIL"; empty when no response arrived.
+	Rcode string
+	// TTL is how long a stub may cache the response: the lowest TTL in the
+	// answer section or, for a negative answer, the SOA's negative caching
+	// TTL (RFC 2308). Zero when there was nothing to cache.
+	TTL time.Duration
+	// CachePass is CacheCold or CacheWarm for the two queries of a
+	// cold/warm pair (see Config.ColdWarm) and for the misses and hits of a
+	// Config.HitRatio mix, and empty otherwise.
+	CachePass string
+	// Truncated is set when the response had the TC bit, i.e. didn't fit
+	// the UDP payload size and a client would have to retry over TCP.
+	Truncated bool
+	// Retries is how many times Run repeated the lookup after a failed
+	// attempt (see Config.Retries). Duration covers every attempt.
+	Retries int
+	// QType is the type of the record queried, e.g. "A" or "MX".
+	QType string
+	// Checked is set when the question came with expected answers (see
+	// Question); Mismatch then says how the response differed, and is
+	// empty when it was as expected, and MismatchKind says which of the
+	// expectations it missed. Failed lookups are never checked.
+	Checked      bool
+	Mismatch     string
+	MismatchKind string
+	// Category classifies a failed lookup or an answer with an error code
+	// (see Classify); empty for NOERROR answers.
+	Category string
+	// RunID identifies the run a result came from in exports merged from
+	// several runs; it is empty for results of the current run.
+	RunID string
+	// Pair numbers the job of a paired run (see Config.Paired) the result
+	// came from, the same for every server; zero outside paired runs.
+	Pair int
+	// Time is the wall-clock time the lookup started (its first attempt,
+	// with retries) and Offset how long after the start of Run that was,
+	// so results line up with other monitoring data.
+	Time   time.Time
+	Offset time.Duration
 }
 
 // Client holds configuration for the DNS client
 type Client struct {
-	Timeout    time.Duration
-	httpClient *http.Client
+	Timeout time.Duration
+	// QType is the record type to query; zero means A.
+	QType uint16
+	// ConnsPerServer caps DoT and DoH at this many persistent connections
+	// per server when positive. Otherwise DoT queries still reuse open
+	// connections, one per concurrent query at most, and DoH queries share
+	// the server's one client.
+	ConnsPerServer int
+	// Fallback enables stub resolver emulation for plain DNS servers
+	// (FallbackTCP or FallbackDoT); empty means UDP only.
+	Fallback string
+	// SearchDomains and Ndots emulate a stub resolver's search list: relative
+	// names are expanded as resolv.conf(5) describes, and every query up to
+	// the first useful answer counts towards the lookup's latency.
+	SearchDomains []string
+	Ndots         int
+	// Proxy, if set, sends every query through a SOCKS5 proxy (see
+	// ParseProxy). Plain DNS then uses TCP, since SOCKS5 has no UDP here.
+	Proxy *url.URL
+	// Bootstrap, if set, is the plain DNS server (host[:port]) used to
+	// resolve the hostnames of DoT and DoH servers instead of the system
+	// resolver.
+	Bootstrap string
+	// Interface, if set, is the network interface every query is sent from
+	// (e.g. "wlan0"), regardless of which one carries the default route.
+	Interface string
+	// SourceIPs, if set, are the local addresses queries are sent from, at
+	// most one per address family (e.g. a VPN tunnel's address). With
+	// Interface, they replace the interface's own addresses.
+	SourceIPs []netip.Addr
+	// CacheBust prepends a random label to every queried name (e.g.
+	// "k3j2d9xa.example.com."), so each lookup misses the resolver's cache
+	// and measures recursive resolution instead of cache hits.
+	CacheBust bool
+	// UDPSize, DNSSEC and Padding add an EDNS0 OPT record to every query:
+	// the advertised UDP payload size (DefaultUDPSize when zero), the DO bit
+	// asking for DNSSEC records, and padding to a multiple of 128 bytes
+	// (RFC 8467).
+	UDPSize uint16
+	DNSSEC  bool
+	Padding bool
+	// ClientSubnet, if valid, is sent as an EDNS Client Subnet option (RFC
+	// 7871), so resolvers that pass it on get CDN answers for that network
+	// rather than for the resolver's own location. Queries carry no OPT
+	// record when none of these are set.
+	ClientSubnet netip.Prefix
+	// NoKeepalive gives every DoT and DoH query a connection of its own,
+	// closed after it, so each pays for a full TCP+TLS (or QUIC) handshake.
+	NoKeepalive bool
+	// TLSResume keeps the TLS sessions of each DoT and DoH server, so new
+	// connections resume them with an abbreviated handshake, as most stubs
+	// do, instead of a full one every time.
+	TLSResume bool
+
+	// dotConns sizes DoT pools when ConnsPerServer isn't set; zero means 1
+	dotConns int
+	poolsMu  sync.Mutex
+	// dohClients holds each DoH server's own client outside keepalive
+	// mode, so one server's connections never serve or delay another's
+	dohClients map[string]*http.Client
+	dotPools   map[string]*dotPool
+	dohPools   map[string]*dohPool
+	dohGET     map[string]bool
+	// sessions holds each server's TLS session cache with TLSResume
+	sessions map[string]*sessionCache
+
+	bootstrapMu    sync.Mutex
+	bootstrapCache map[string][]netip.Addr
+
+	ifaceOnce      sync.Once
+	iface          *ifaceBinding
+	quicTransports map[netip.Addr]*quic.Transport
 }
 
 // Measure performs a DNS query to a specific server and returns the result
 func (c *Client) Measure(serverAddr, domain string) Result {
-	m := new(dns.Msg)
-	m.SetQuestion(dns.Fqdn(domain), dns.TypeA)
+	return c.MeasureContext(context.Background(), serverAddr, domain)
+}
+
+// MeasureContext is Measure with a context that stops the lookup when it is
+// done: DoH and plain UDP queries are cancelled, and no further search list
+// candidates are tried. Other transports still give up after Timeout.
+func (c *Client) MeasureContext(ctx context.Context, serverAddr, domain string) Result {
+	res, _ := c.measure(ctx, serverAddr, domain, c.QType, c.CacheBust)
+	return res
+}
+
+// measure looks up domain with the given record type (zero means A),
+// under a random label with bust (see CacheBust), and returns the final
+// response along with the result, nil if none arrived.
+func (c *Client) measure(ctx context.Context, serverAddr, domain string, qtype uint16, bust bool) (Result, *dns.Msg) {
+	if qtype == 0 {
+		qtype = dns.TypeA
+	}
+
+	// Without a search list the domain is always queried as an absolute
+	// name; with one, a stub may try several names before one answers.
+	names := []string{dns.Fqdn(domain)}
+	if len(c.SearchDomains) > 0 {
+		names = searchCandidates(domain, c.SearchDomains, c.Ndots)
+	}
+	if bust {
+		for i, name := range names {
+			names[i] = cacheBustName(name)
+		}
+	}
 
 	start := time.Now()
+	var resp *dns.Msg
+	var info exchangeInfo
 	var err error
+	var overhead time.Duration
+	queries := 0
+
+	for i, name := range names {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		c.applyEDNS(m)
+
+		queryStart := time.Now()
+		resp, info, err = c.exchange(ctx, serverAddr, m)
+		queries++
+		if err != nil || i == len(names)-1 || !searchContinues(resp) {
+			break
+		}
+		overhead += time.Since(queryStart)
+	}
+
+	duration := time.Since(start)
+
+	res := Result{
+		Server:      serverAddr,
+		Domain:      domain,
+		Time:        start,
+		QType:       dns.TypeToString[qtype],
+		Duration:    duration,
+		Error:       err,
+		Transport:   info.transport,
+		Fallbacks:   info.fallbacks,
+		HTTPVersion: info.httpVersion,
+		Protocol:    info.protocol,
+	}
+	if len(c.SearchDomains) > 0 {
+		res.SearchQueries = queries
+		res.SearchOverhead = overhead
+		res.QueryName = names[queries-1]
+	} else if bust {
+		res.QueryName = names[0]
+	}
+	if !info.firstByte.IsZero() {
+		res.TTFB = info.firstByte.Sub(start)
+	}
+	res.Connect = info.connect
+	res.Resumed = info.resumed
+	if resp != nil {
+		res.KeepaliveTimeout, res.KeepaliveAdvertised = tcpKeepalive(resp)
+		res.CNAMEDepth, res.ChainUnfollowed = cnameChain(resp)
+		res.Answers, res.Authority, res.Additional = sectionCounts(resp)
+		res.EmptyAnswer = resp.Rcode == dns.RcodeSuccess && res.Answers == 0
+		res.Rcode = dns.RcodeToString[resp.Rcode]
+		res.TTL = cacheTTL(resp)
+		res.Truncated = resp.Truncated
+		if isSVCBType(qtype) {
+			res.SVCB = parseSVCB(resp)
+		}
+	}
+	res.Category = Classify(res)
+	return res, resp
+}
+
+// exchangeInfo describes how an exchange went beyond the answer itself.
+// firstByte and httpVersion are only set for DoH; transport and fallbacks
+// only in fallback mode; connect and resumed only for DoT and https:// DoH.
+type exchangeInfo struct {
+	protocol    string
+	firstByte   time.Time
+	httpVersion string
+	transport   string
+	fallbacks   int
+	connect     time.Duration
+	resumed     bool
+}
 
+// exchange sends m to serverAddr over the transport selected by its scheme.
+func (c *Client) exchange(ctx context.Context, serverAddr string, m *dns.Msg) (resp *dns.Msg, info exchangeInfo, err error) {
 	// Detect Protocol
 	switch {
-	case strings.HasPrefix(serverAddr, "https://"):
-		err = c.measureDoH(serverAddr, m)
+	case strings.HasPrefix(serverAddr, "https://"), strings.HasPrefix(serverAddr, "h3://"):
+		resp, info, err = c.measureDoH(ctx, serverAddr, m)
+		info.protocol = ServerProtocol(serverAddr)
 	case strings.HasPrefix(serverAddr, "tls://"):
-		// DoT (DNS over TLS)
-		host := strings.TrimPrefix(serverAddr, "tls://")
-		// Append default port 853 if not present
-		if !strings.Contains(host, ":") {
-			host += ":853"
-		}
-		client := new(dns.Client)
-		client.Net = "tcp-tls"
-		client.Timeout = c.Timeout
-		// InsecureSkipVerify is necessary for benchmarking DNS servers by IP address
-		// where the TLS certificate may not match the IP. This is acceptable for
-		// performance testing purposes.
-		//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
-		client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-
-		_, _, err = client.Exchange(m, host)
+		requestTCPKeepalive(m)
+		resp, info, err = c.measureDoT(serverAddr, m)
+		info.protocol = "dot"
+	case strings.HasPrefix(serverAddr, "tcp://"):
+		resp, err = c.measureTCP(withPort(strings.TrimPrefix(serverAddr, "tcp://"), "53"), m)
+		info.protocol = "tcp"
 	default:
 		// Standard UDP
-		host := serverAddr
-		if !strings.Contains(host, ":") {
-			host += ":53"
+		host := withPort(serverAddr, "53")
+		if c.Proxy != nil {
+			resp, err = c.measureTCP(host, m)
+			info.protocol = "tcp"
+			break
 		}
+		if c.Fallback != "" {
+			resp, info.transport, info.fallbacks, err = c.measureFallback(host, m)
+			info.protocol = ProtocolLabel(info.transport)
+			break
+		}
+		info.protocol = "udp"
 		client := new(dns.Client)
 		client.Timeout = c.Timeout
-		_, _, err = client.Exchange(m, host)
+		if err = c.bindDNS(client, host); err != nil {
+			break
+		}
+		resp, _, err = client.ExchangeContext(ctx, m, host)
 	}
+	return resp, info, err
+}
 
-	duration := time.Since(start)
+// cnameChain follows the CNAME records in resp's answer section from the
+// question name and returns the chain length, plus whether the chain stopped
+// at a CNAME with no record of the queried type for its final target (a
+// resolver that failed to chase the whole chain).
+func cnameChain(resp *dns.Msg) (depth int, unfollowed bool) {
+	if len(resp.Question) == 0 {
+		return 0, false
+	}
+	q := resp.Question[0]
+	name := q.Name
+	cnames := make(map[string]string)
+	for _, rr := range resp.Answer {
+		if c, ok := rr.(*dns.CNAME); ok {
+			cnames[strings.ToLower(c.Hdr.Name)] = c.Target
+		}
+	}
+	for depth < len(cnames) {
+		target, ok := cnames[strings.ToLower(name)]
+		if !ok {
+			break
+		}
+		name = target
+		depth++
+	}
+	if depth == 0 || resp.Rcode != dns.RcodeSuccess {
+		return depth, false
+	}
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == q.Qtype && strings.EqualFold(rr.Header().Name, name) {
+			return depth, false
+		}
+	}
+	return depth, true
+}
 
-	return Result{
-		Server:   serverAddr,
-		Domain:   domain,
-		Duration: duration,
-		Error:    err,
+// sectionCounts returns the number of records in the answer, authority and
+// additional sections of resp, not counting the OPT pseudo-record.
+func sectionCounts(resp *dns.Msg) (answer, authority, additional int) {
+	for _, rr := range resp.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			additional++
+		}
 	}
+	return len(resp.Answer), len(resp.Ns), additional
 }
 
-func (c *Client) measureDoH(url string, m *dns.Msg) error {
-	data, err := m.Pack()
+// cacheTTL returns how long resp may be cached (see Result.TTL).
+func cacheTTL(resp *dns.Msg) time.Duration {
+	var ttl uint32
+	found := false
+	for _, rr := range resp.Answer {
+		if !found || rr.Header().Ttl < ttl {
+			ttl, found = rr.Header().Ttl, true
+		}
+	}
+	if !found {
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl, found = min(soa.Hdr.Ttl, soa.Minttl), true
+			}
+		}
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// requestTCPKeepalive adds an empty edns-tcp-keepalive option to m, asking a
+// stream transport server to advertise its idle timeout (RFC 7828).
+func requestTCPKeepalive(m *dns.Msg) {
+	opt := m.IsEdns0()
+	if opt == nil {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		opt = m.IsEdns0()
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE})
+	if isPadded(m) {
+		padQuery(m)
+	}
+}
+
+// tcpKeepalive extracts the idle timeout a server advertised in resp.
+func tcpKeepalive(resp *dns.Msg) (time.Duration, bool) {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return 0, false
+	}
+	for _, o := range opt.Option {
+		if ka, ok := o.(*dns.EDNS0_TCP_KEEPALIVE); ok {
+			return time.Duration(ka.Timeout) * 100 * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// ServerProtocol returns the protocol a server address selects by its
+// scheme: "tcp", "dot", "doh" or "doh3", and "udp" for plain addresses.
+// Queries to plain servers may still move to TCP or TLS in fallback mode or
+// through a proxy; Result.Protocol records what was actually used.
+func ServerProtocol(serverAddr string) string {
+	scheme, _, found := strings.Cut(serverAddr, "://")
+	if !found {
+		return "udp"
+	}
+	return ProtocolLabel(scheme)
+}
+
+// protocolLabels name the encrypted transports by protocol rather than by
+// address scheme; DoH over HTTP/3 is doh3.
+var protocolLabels = map[string]string{"tls": "dot", "https": "doh", "h3": "doh3"}
+
+// ProtocolLabel returns the protocol label for a server address scheme or
+// fallback transport, e.g. "dot" for "tls". Labels and unknown names are
+// returned as they are, so older exports can be passed through it.
+func ProtocolLabel(name string) string {
+	if label, ok := protocolLabels[name]; ok {
+		return label
+	}
+	return name
+}
+
+// withPort returns host with port appended unless it already has one,
+// bracketing bare IPv6 addresses.
+func withPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// measureTCP queries host (with port) over plain TCP, through the proxy if
+// one is configured.
+func (c *Client) measureTCP(host string, m *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: "tcp", Timeout: c.Timeout}
+	if c.Proxy != nil {
+		return exchangeOnce(client, m, func() (*dns.Conn, error) { return c.dialProxyDNS(host, nil) })
+	}
+	if err := c.bindDNS(client, host); err != nil {
+		return nil, err
+	}
+	resp, _, err := client.Exchange(m, host)
+	return resp, err
+}
+
+// measureDoT sends m to a DoT server, over a pooled connection unless
+// NoKeepalive asks for a new one per query, and also returns how
+// long opening a new connection took (zero when one was reused) and whether
+// it resumed a TLS session.
+func (c *Client) measureDoT(serverAddr string, m *dns.Msg) (resp *dns.Msg, info exchangeInfo, err error) {
+	// DoT (DNS over TLS)
+	host := withPort(strings.TrimPrefix(serverAddr, "tls://"), "853")
+	client := new(dns.Client)
+	client.Net = "tcp-tls"
+	client.Timeout = c.Timeout
+	// InsecureSkipVerify is necessary for benchmarking DNS servers by IP address
+	// where the TLS certificate may not match the IP. This is acceptable for
+	// performance testing purposes.
+	//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
+	client.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	if c.TLSResume {
+		client.TLSConfig.ClientSessionCache = c.sessionCache(serverAddr)
+	}
+	// Send the hostname as SNI even when dialing a bootstrapped address
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		if _, err := netip.ParseAddr(name); err != nil {
+			client.TLSConfig.ServerName = name
+		}
+	}
+
+	if c.Proxy == nil && c.Bootstrap == "" {
+		if err := c.bindDNS(client, host); err != nil {
+			return nil, info, err
+		}
+	}
+	dial := func() (*dns.Conn, error) { return client.Dial(host) }
+	switch {
+	case c.Proxy != nil:
+		dial = func() (*dns.Conn, error) { return c.dialProxyDNS(host, client.TLSConfig) }
+	case c.Bootstrap != "":
+		dial = func() (*dns.Conn, error) { return c.dialBootstrapDNS(client, host) }
+	}
+	// Timing the dial separates the handshakes from the query itself.
+	// Proxied connections haven't shaken hands yet, so that happens here too.
+	timedDial := func() (*dns.Conn, error) {
+		start := time.Now()
+		conn, err := dial()
+		if err == nil {
+			if tlsConn, ok := conn.Conn.(*tls.Conn); ok {
+				ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+				err = tlsConn.HandshakeContext(ctx)
+				cancel()
+				info.resumed = tlsConn.ConnectionState().DidResume
+			}
+			if err != nil {
+				_ = conn.Close()
+				conn = nil
+			}
+		}
+		info.connect += time.Since(start)
+		return conn, err
+	}
+
+	if c.NoKeepalive {
+		resp, err = exchangeOnce(client, m, timedDial)
+		return resp, info, err
+	}
+
+	pool := c.dotPool(serverAddr)
+	conn, reused, err := pool.get(timedDial, c.Timeout)
+	if err != nil {
+		return nil, info, err
+	}
+	resp, _, err = client.ExchangeWithConn(m, conn)
+	if err != nil && reused {
+		// The server may have closed the idle connection; a real stub would
+		// redial once before reporting a failure.
+		pool.discard(conn)
+		if conn, _, err = pool.get(timedDial, c.Timeout); err != nil {
+			return nil, info, err
+		}
+		resp, _, err = client.ExchangeWithConn(m, conn)
+	}
 	if err != nil {
-		return err
+		pool.discard(conn)
+		return nil, info, err
 	}
+	pool.put(conn)
+	return resp, info, nil
+}
 
-	if c.httpClient == nil {
-		// Create a transport with TLS config
-		// InsecureSkipVerify is necessary for benchmarking DoH servers by IP address
-		// where the TLS certificate may not match the IP. This is acceptable for
-		// performance testing purposes.
-		//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
-		t := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// dotPool returns the connection pool for a DoT server, creating it on first use.
+func (c *Client) dotPool(serverAddr string) *dotPool {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	if c.dotPools == nil {
+		c.dotPools = make(map[string]*dotPool)
+	}
+	p, ok := c.dotPools[serverAddr]
+	if !ok {
+		size := c.ConnsPerServer
+		if size <= 0 {
+			size = max(c.dotConns, 1)
+		}
+		p = newDoTPool(size)
+		c.dotPools[serverAddr] = p
+	}
+	return p
+}
+
+// dohClient returns the HTTP client to use for the next query to a DoH
+// server (https:// or h3://): with NoKeepalive a new one, which the caller
+// closes, else one of the server's pool in keepalive mode or the server's
+// own client.
+func (c *Client) dohClient(serverAddr string) *http.Client {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	newClient := func() *http.Client {
+		var sessions tls.ClientSessionCache
+		if c.TLSResume {
+			sessions = c.sessionCacheLocked(serverAddr)
+		}
+		if strings.HasPrefix(serverAddr, "h3://") {
+			client := newHTTP3Client(c.Timeout)
+			client.Transport.(*http3.Transport).TLSClientConfig.ClientSessionCache = sessions
+			return c.useInterface(c.useBootstrap(client))
 		}
-		// Enable HTTP/2 support explicitly
-		_ = http2.ConfigureTransport(t) // Ignore error - fallback to HTTP/1.1 is acceptable
+		client := newHTTPClient(c.Timeout, c.Proxy)
+		t := client.Transport.(*http.Transport)
+		t.TLSClientConfig.ClientSessionCache = sessions
+		if c.NoKeepalive {
+			t.DisableKeepAlives = true
+		}
+		return c.useInterface(c.useBootstrap(client))
+	}
+	if c.NoKeepalive {
+		return newClient()
+	}
+	if c.ConnsPerServer <= 0 {
+		client, ok := c.dohClients[serverAddr]
+		if !ok {
+			if c.dohClients == nil {
+				c.dohClients = make(map[string]*http.Client)
+			}
+			client = newClient()
+			c.dohClients[serverAddr] = client
+		}
+		return client
+	}
+	if c.dohPools == nil {
+		c.dohPools = make(map[string]*dohPool)
+	}
+	p, ok := c.dohPools[serverAddr]
+	if !ok {
+		p = newDoHPool(c.ConnsPerServer, newClient)
+		c.dohPools[serverAddr] = p
+	}
+	return p.client()
+}
 
-		c.httpClient = &http.Client{
-			Timeout:   c.Timeout,
-			Transport: t,
+// prepareDoH creates the own client of every DoH server of config up front,
+// instead of on the first query to it.
+func (c *Client) prepareDoH(config Config) {
+	if c.NoKeepalive || c.ConnsPerServer > 0 {
+		return
+	}
+	for _, server := range config.Servers {
+		if via, ok := config.Via[server]; ok {
+			server = via
 		}
+		if strings.HasPrefix(server, "https://") || strings.HasPrefix(server, "h3://") {
+			c.dohClient(server)
+		}
+	}
+}
+
+// newHTTPClient builds an HTTP/2-capable client for DoH queries, connecting
+// through proxyURL when it is not nil.
+func newHTTPClient(timeout time.Duration, proxyURL *url.URL) *http.Client {
+	// Create a transport with TLS config
+	// InsecureSkipVerify is necessary for benchmarking DoH servers by IP address
+	// where the TLS certificate may not match the IP. This is acceptable for
+	// performance testing purposes.
+	//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
+	t := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	if proxyURL != nil {
+		t.Proxy = http.ProxyURL(proxyURL)
 	}
+	// Enable HTTP/2 support explicitly
+	_ = http2.ConfigureTransport(t) // Ignore error - fallback to HTTP/1.1 is acceptable
 
-	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewReader(data))
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: t,
+	}
+}
+
+// measureDoH sends m to a DoH server and also returns when the first byte of
+// the HTTP response arrived, so header latency can be told apart from a slow
+// body, and the HTTP version the response came over. h3:// servers are
+// queried at the same https:// URL over HTTP/3. Queries are POSTed unless
+// the server has answered a POST with 405 Method Not Allowed.
+func (c *Client) measureDoH(ctx context.Context, serverAddr string, m *dns.Msg) (*dns.Msg, exchangeInfo, error) {
+	var info exchangeInfo
+	url := serverAddr
+	if rest, ok := strings.CutPrefix(serverAddr, "h3://"); ok {
+		if c.Proxy != nil {
+			return nil, info, errH3Proxy
+		}
+		url = "https://" + rest
+	}
+	data, err := m.Pack()
 	if err != nil {
-		return err
+		return nil, info, err
 	}
-	req.Header.Set("Content-Type", "application/dns-message")
-	req.Header.Set("Accept", "application/dns-message")
 
-	resp, err := c.httpClient.Do(req)
+	var firstByte, getConn time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(conn httptrace.GotConnInfo) {
+			if !conn.Reused && !getConn.IsZero() {
+				info.connect += time.Since(getConn)
+			}
+			if tlsConn, ok := conn.Conn.(*tls.Conn); ok && !conn.Reused {
+				info.resumed = tlsConn.ConnectionState().DidResume
+			}
+		},
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	ctx = httptrace.WithClientTrace(ctx, trace)
+	client := c.dohClient(serverAddr)
+	if c.NoKeepalive {
+		// Runs after the body is closed, when the connection is idle
+		defer client.CloseIdleConnections()
+	}
+	get := c.dohUsesGET(serverAddr)
+	resp, err := sendDoH(ctx, client, url, data, get)
+	if err == nil && !get && resp.StatusCode == http.StatusMethodNotAllowed {
+		// Some DoH proxies only implement GET; switch the server over for
+		// good rather than paying for two requests every time
+		_ = resp.Body.Close()
+		c.setDoHGET(serverAddr)
+		resp, err = sendDoH(ctx, client, url, data, true)
+	}
 	if err != nil {
-		return err
+		return nil, info, err
+	}
+	info.httpVersion = resp.Proto
+	if c.NoKeepalive && resp.TLS != nil && strings.HasPrefix(serverAddr, "h3://") {
+		info.resumed = resp.TLS.DidResume
 	}
+	// HTTP/3 responses don't go through httptrace; the headers having
+	// arrived is the closest equivalent.
+	if firstByte.IsZero() {
+		firstByte = time.Now()
+	}
+	info.firstByte = firstByte
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to close response body: %v\n", err)
@@ -123,36 +753,155 @@ func (c *Client) measureDoH(url string, m *dns.Msg) error {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("DoH error: %s (failed to read body: %w)", resp.Status, err)
-		}
-		return fmt.Errorf("DoH error: %s: %s", resp.Status, string(body))
+		return nil, info, dohStatusError(url, resp)
 	}
 
-	// We don't strictly need to unpack the response for benchmarking latency,
-	// but it validates the server actually replied with DNS data.
+	// Unpacking validates the server actually replied with DNS data and lets
+	// Measure inspect the response.
 	respData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, info, err
 	}
 
 	respMsg := new(dns.Msg)
-	return respMsg.Unpack(respData)
+	if err := respMsg.Unpack(respData); err != nil {
+		return nil, info, notDNSMessage(url, resp, err)
+	}
+	return respMsg, info, nil
+}
+
+// sendDoH sends one DoH request for data to the server's endpoint.
+func sendDoH(ctx context.Context, client *http.Client, endpoint string, data []byte, get bool) (*http.Response, error) {
+	req, err := newDoHRequest(ctx, endpoint, data, get)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// ServerSpec holds the settings of one server that differ from the run's.
+type ServerSpec struct {
+	// Name labels the server in reports; Run doesn't use it
+	Name string
+	// Timeout, if set, replaces Config.Timeout for the server's queries
+	Timeout time.Duration
 }
 
 // Config holds the configuration for a benchmark run
 type Config struct {
-	Servers      []string
-	Domains      []string
+	Servers []string
+	Domains []string
+	// Questions, if set, replaces Domains with a batch of questions, each
+	// with its own type and optionally the answer it must get
+	Questions    []Question
 	Iterations   int
 	Concurrency  int
 	Timeout      time.Duration
+	QType        uint16 // Record type to query; zero means A
 	Duration     time.Duration
 	Verbose      bool
 	ShowProgress bool // Show progress updates
+	// ConnsPerServer caps persistent DoT/DoH connections per server (see Client.ConnsPerServer)
+	ConnsPerServer int
+	// Fallback enables stub resolver emulation (see Client.Fallback)
+	Fallback string
+	// SearchDomains and Ndots enable search list emulation (see Client.SearchDomains)
+	SearchDomains []string
+	Ndots         int
+	// Proxy sends every query through a SOCKS5 proxy (see Client.Proxy)
+	Proxy *url.URL
+	// Bootstrap resolves DoT and DoH server hostnames (see Client.Bootstrap);
+	// Run looks them all up before the first query.
+	Bootstrap string
+	// Interface and SourceIPs send every query from this network interface
+	// and these local addresses (see Client.Interface and Client.SourceIPs)
+	Interface string
+	SourceIPs []netip.Addr
+	// Via maps a server to the address actually queried for it, e.g. the
+	// NAT64-synthesized form of an IPv4 literal; results keep the server name
+	Via map[string]string
+	// Network is stamped on every Result (e.g. "wlan0 (HomeWiFi) via 192.168.1.1")
+	Network string
+	// Overhead is subtracted from every successful query's duration, e.g. the
+	// median reported by Calibrate, to leave only network and server time
+	Overhead time.Duration
+	// SlowThreshold is the latency above which Verbose logs a query as slow;
+	// zero means DefaultSlowThreshold
+	SlowThreshold time.Duration
+	// CacheBust queries random subdomains (see Client.CacheBust)
+	CacheBust bool
+	// ColdWarm measures every job twice in quick succession, tagging the
+	// results CacheCold and CacheWarm: the first query may have to be
+	// resolved, the second should come from the server's cache.
+	ColdWarm bool
+	// HitRatio, between 0 and 1, drives a mix of cache hits and misses, for
+	// load-testing a caching resolver: every question is asked once on
+	// every server before the run to warm its cache, then each job is a
+	// hit (the name itself, tagged CacheWarm) with this probability and
+	// otherwise a miss (under a random label, tagged CacheCold). Zero
+	// means no mix.
+	HitRatio float64
+	// Retries repeats a failed lookup up to this many times, as a stub
+	// resolver would; the result records how many retries it took
+	Retries int
+	// UDPSize, DNSSEC and Padding set EDNS0 options (see Client.UDPSize)
+	UDPSize uint16
+	DNSSEC  bool
+	Padding bool
+	// ClientSubnet is sent as an EDNS Client Subnet option (see
+	// Client.ClientSubnet)
+	ClientSubnet netip.Prefix
+	// QPS caps the queries Run sends per second across all servers, and
+	// ServerQPS the queries per second to individual servers; zero or
+	// missing means no limit. Queries are spread evenly, with no bursts.
+	QPS       float64
+	ServerQPS map[string]float64
+	// OnResult, if set, is called with each result as soon as it is collected.
+	// Calls happen on a single goroutine, in completion order.
+	OnResult func(Result)
+	// MaxResults, if positive, bounds the results Run returns to a uniform
+	// random sample of this many and its queues to Concurrency, so memory
+	// stays flat however long the run; OnResult still sees every result.
+	MaxResults int
+	// Weights, if set, makes a Duration run pick each question in
+	// proportion to the weight of its name, e.g. how often it was visited,
+	// instead of uniformly. Names without a weight count as 1.
+	Weights map[string]float64
+	// LogSample, between 0 and 1, logs that fraction of the answered
+	// lookups, picked at random, along with every failed one; it replaces
+	// Verbose's log of every slow lookup, so long runs log a bounded amount
+	LogSample float64
+	// Paired queries each domain on every server back to back, as one job,
+	// so the servers' results for it can be compared pair by pair (see
+	// Result.Pair); which server goes first rotates from job to job.
+	Paired bool
+	// Specs holds per-server settings, keyed by server address
+	Specs map[string]ServerSpec
+	// NoKeepalive opens a new connection for every DoT and DoH query (see
+	// Client.NoKeepalive)
+	NoKeepalive bool
+	// TLSResume resumes TLS sessions on new DoT and DoH connections (see
+	// Client.TLSResume)
+	TLSResume bool
 }
 
+// Values of Result.CachePass.
+const (
+	CacheCold = "cold"
+	CacheWarm = "warm"
+)
+
+// Values of Result.MismatchKind.
+const (
+	MismatchRcode      = "rcode"
+	MismatchMissing    = "missing"
+	MismatchUnexpected = "unexpected"
+)
+
+// DefaultSlowThreshold is the latency above which a query counts as slow
+// unless Config.SlowThreshold says otherwise.
+const DefaultSlowThreshold = 500 * time.Millisecond
+
 // ProgressUpdate represents benchmark progress
 type ProgressUpdate struct {
 	Completed int
@@ -164,6 +913,175 @@ type ProgressUpdate struct {
 type Job struct {
 	Server string
 	Domain string
+	// question, if set, is the batch question the job asks; Domain is then
+	// its name.
+	question *Question
+	// ctx is the context of the run the job belongs to; a job whose run is
+	// over by the time a worker picks it up is dropped. Nil for jobs run
+	// outside Run.
+	ctx context.Context
+	// pair numbers the jobs of a paired run, which leave Server empty and
+	// go to every server, starting with the pair-th (wrapping around).
+	pair int
+	// miss makes the job a cache miss of a HitRatio mix: its name is
+	// queried under a random label.
+	miss bool
+}
+
+// newRunClient creates the client Run measures with, with the hostnames of
+// encrypted servers already resolved when a bootstrap resolver is set.
+func newRunClient(config Config) *Client {
+	client := &Client{
+		Timeout:        config.Timeout,
+		QType:          config.QType,
+		ConnsPerServer: config.ConnsPerServer,
+		Fallback:       config.Fallback,
+		SearchDomains:  config.SearchDomains,
+		Ndots:          config.Ndots,
+		Proxy:          config.Proxy,
+		Bootstrap:      config.Bootstrap,
+		Interface:      config.Interface,
+		SourceIPs:      config.SourceIPs,
+		CacheBust:      config.CacheBust,
+		UDPSize:        config.UDPSize,
+		DNSSEC:         config.DNSSEC,
+		Padding:        config.Padding,
+		ClientSubnet:   config.ClientSubnet,
+		NoKeepalive:    config.NoKeepalive,
+		TLSResume:      config.TLSResume,
+		dotConns:       config.Concurrency,
+	}
+	if config.Bootstrap != "" && config.Proxy == nil {
+		client.prefetchBootstrap(config.Servers)
+	}
+	client.prepareDoH(config)
+	return client
+}
+
+// serverConfig returns config with the Spec of server applied.
+func (config Config) serverConfig(server string) Config {
+	if spec := config.Specs[server]; spec.Timeout > 0 {
+		config.Timeout = spec.Timeout
+	}
+	return config
+}
+
+// runJob measures one job the way Run does: through config.Via, stamped
+// with config.Network, retried up to config.Retries times, and less
+// config.Overhead. Each retry waits its turn on limits, which may be nil;
+// the caller waits for the first attempt.
+func (c *Client) runJob(config Config, job Job, limits *rateLimits) Result {
+	target := job.Server
+	if via, ok := config.Via[job.Server]; ok {
+		target = via
+	}
+	res := c.attempt(config, target, job)
+	first := res.Time
+	var failed time.Duration
+	for res.Error != nil && res.Retries < config.Retries {
+		failed += res.Duration
+		retries := res.Retries + 1
+		limits.wait(job.Server)
+		res = c.attempt(config, target, job)
+		res.Retries = retries
+	}
+	res.Time = first
+	res.Duration += failed
+	res.Server = job.Server
+	res.Network = config.Network
+	if res.Error == nil && config.Overhead > 0 {
+		res.Duration = max(res.Duration-config.Overhead, 0)
+	}
+	if config.Verbose || config.LogSample > 0 {
+		//nolint:gosec // G404: math/rand is sufficient for sampling log lines
+		logResult(os.Stdout, config, res, config.LogSample > 0 && rand.Float64() < config.LogSample)
+	}
+	return res
+}
+
+// logResult writes the log line for res, if it gets one. Failed lookups
+// are always logged. With LogSample, answered lookups are logged when
+// sampled, slow or not; otherwise Verbose logs the slow ones.
+func logResult(w io.Writer, config Config, res Result, sampled bool) {
+	slow := res.Duration > cmp.Or(config.SlowThreshold, DefaultSlowThreshold)
+	var err error
+	switch {
+	case res.Error != nil:
+		_, err = fmt.Fprintf(w, "[%s] Error resolving %s: %v\n", res.Server, res.Domain, res.Error)
+	case config.LogSample > 0 && !sampled:
+	case slow:
+		_, err = fmt.Fprintf(w, "[%s] Slow resolve %s: %v\n", res.Server, res.Domain, res.Duration)
+	case sampled:
+		_, err = fmt.Fprintf(w, "[%s] Resolved %s: %v %s\n", res.Server, res.Domain, res.Duration, res.Rcode)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write log: %v\n", err)
+	}
+}
+
+// attempt measures one try of a lookup with its own deadline, long enough
+// for every query it may take (see lookupBudget), and checks the answer
+// against the job's expectations.
+func (c *Client) attempt(config Config, target string, job Job) Result {
+	ctx := context.Background()
+	if budget := config.lookupBudget(); budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
+	}
+	bust := c.CacheBust || job.miss
+	q := job.question
+	if q == nil {
+		res, _ := c.measure(ctx, target, job.Domain, c.QType, bust)
+		return res
+	}
+	qtype := cmp.Or(q.Type, c.QType)
+	res, resp := c.measure(ctx, target, q.Name, qtype, bust)
+	if resp != nil && q.hasExpectations() {
+		res.Checked = true
+		res.MismatchKind, res.Mismatch = q.mismatch(resp)
+	}
+	return res
+}
+
+// lookupBudget is the longest one lookup may take: Timeout for every query
+// it can send, which search lists and transport fallback multiply.
+func (config Config) lookupBudget() time.Duration {
+	queries := max(len(config.SearchDomains)+1, 1)
+	switch config.Fallback {
+	case FallbackTCP:
+		queries *= 2
+	case FallbackDoT:
+		queries *= 3
+	}
+	return config.Timeout * time.Duration(queries)
+}
+
+// questionPicker returns a function that picks the index of a random
+// question, in proportion to weights when there are any.
+func questionPicker(questions []Question, weights map[string]float64) func(*rand.Rand) int {
+	uniform := func(rng *rand.Rand) int { return rng.Intn(len(questions)) }
+	if len(weights) == 0 {
+		return uniform
+	}
+	// cumulative[i] is the total weight of questions up to and including i
+	cumulative := make([]float64, len(questions))
+	var total float64
+	for i, q := range questions {
+		w, ok := weights[q.Name]
+		if !ok {
+			w = 1
+		}
+		total += max(w, 0)
+		cumulative[i] = total
+	}
+	if total <= 0 {
+		return uniform
+	}
+	return func(rng *rand.Rand) int {
+		r := rng.Float64() * total
+		return sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > r })
+	}
 }
 
 // Run executes the benchmark with the given configuration
@@ -171,22 +1089,90 @@ func Run(config Config) []Result {
 	// Use a reasonable buffer size for channels to prevent blocking,
 	// but don't try to buffer everything if running for a long duration.
 	bufferSize := config.Concurrency * 10
+	if config.MaxResults > 0 {
+		bufferSize = config.Concurrency
+	}
 	jobs := make(chan Job, bufferSize)
 	results := make(chan Result, bufferSize)
 
-	// Create client
-	client := Client{Timeout: config.Timeout}
+	client := newRunClient(config)
+	// A server with its own timeout gets its own client, as the timeout is
+	// built into a client's transports
+	type serverRunner struct {
+		client *Client
+		config Config
+	}
+	runners := make(map[string]serverRunner)
+	for server, spec := range config.Specs {
+		if spec.Timeout > 0 && slices.Contains(config.Servers, server) {
+			sc := config.serverConfig(server)
+			sc.Servers = []string{server}
+			runners[server] = serverRunner{newRunClient(sc), sc}
+		}
+	}
+	clientFor := func(server string) (*Client, Config) {
+		if r, ok := runners[server]; ok {
+			return r.client, r.config
+		}
+		return client, config
+	}
+	limits := newRateLimits(config)
+	questions := config.questions()
+	if config.HitRatio > 0 {
+		warmCaches(config, questions, clientFor, limits)
+	}
+	runStart := time.Now()
+
+	// The run's context ends with -duration; jobs still queued then are
+	// dropped instead of overrunning it
+	runCtx, cancel := context.WithCancel(context.Background())
+	if config.Duration > 0 {
+		runCtx, cancel = context.WithTimeout(context.Background(), config.Duration)
+	}
+	defer cancel()
 
 	// Calculate total jobs for progress tracking
 	var totalJobs int
 	if config.Duration == 0 {
-		totalJobs = len(config.Servers) * len(config.Domains) * config.Iterations
+		totalJobs = len(config.Servers) * len(questions) * config.Iterations
 	}
 
-	// Progress tracking
-	var completed int
-	var progressMu sync.Mutex
-	startTime := time.Now()
+	// Workers only bump a counter; a separate goroutine reports progress,
+	// so nothing on the query path waits for the terminal
+	var completed atomic.Int64
+	stopProgress := func() {}
+	if config.ShowProgress && totalJobs > 0 {
+		stopProgress = reportProgress(&completed, totalJobs)
+	}
+
+	measure := func(job Job) {
+		// Waiting once the job has left the queue means a limited
+		// server only ties up the workers holding its jobs
+		limits.wait(job.Server)
+		c, jobConfig := clientFor(job.Server)
+		if config.HitRatio > 0 {
+			//nolint:gosec // G404: math/rand is sufficient for picking hits and misses
+			job.miss = rand.Float64() >= config.HitRatio
+		}
+		res := c.runJob(jobConfig, job, limits)
+		res.Pair = job.pair
+		if config.HitRatio > 0 {
+			res.CachePass = CacheWarm
+			if job.miss {
+				res.CachePass = CacheCold
+			}
+		}
+		if config.ColdWarm {
+			res.CachePass = CacheCold
+			results <- res
+			limits.wait(job.Server)
+			res = c.runJob(jobConfig, job, limits)
+			res.Pair = job.pair
+			res.CachePass = CacheWarm
+		}
+		results <- res
+		completed.Add(1)
+	}
 
 	// Start workers
 	var wg sync.WaitGroup
@@ -195,26 +1181,16 @@ func Run(config Config) []Result {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				res := client.Measure(job.Server, job.Domain)
-				if config.Verbose {
-					if res.Error != nil {
-						fmt.Printf("[%s] Error resolving %s: %v\n", job.Server, job.Domain, res.Error)
-					} else if res.Duration > 500*time.Millisecond {
-						fmt.Printf("[%s] Slow resolve %s: %v\n", job.Server, job.Domain, res.Duration)
-					}
+				if job.ctx.Err() != nil {
+					continue
 				}
-				results <- res
-
-				// Update progress
-				if config.ShowProgress && totalJobs > 0 {
-					progressMu.Lock()
-					completed++
-					if completed%10 == 0 || completed == totalJobs {
-						elapsed := time.Since(startTime)
-						pct := float64(completed) / float64(totalJobs) * 100
-						fmt.Printf("\rProgress: %d/%d (%.1f%%) - Elapsed: %v", completed, totalJobs, pct, elapsed.Round(time.Second))
-					}
-					progressMu.Unlock()
+				if !config.Paired {
+					measure(job)
+					continue
+				}
+				for i := range config.Servers {
+					job.Server = config.Servers[(job.pair+i)%len(config.Servers)]
+					measure(job)
 				}
 			}
 		}()
@@ -222,64 +1198,115 @@ func Run(config Config) []Result {
 
 	// Enqueue jobs
 	go func() {
+		defer close(jobs)
 		if config.Duration > 0 {
-			// Use context for clean cancellation
-			ctx, cancel := context.WithTimeout(context.Background(), config.Duration)
-			defer cancel()
-
 			// Randomly select jobs to ensure fair coverage across all servers/domains
 			//nolint:gosec // G404: math/rand is sufficient for non-cryptographic benchmark randomization
 			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-			for {
+			pick := questionPicker(questions, config.Weights)
+			for pair := 1; ; pair++ {
+				q := &questions[pick(rng)]
+				job := Job{
+					Server:   config.Servers[rng.Intn(len(config.Servers))],
+					Domain:   q.Name,
+					question: q,
+					ctx:      runCtx,
+				}
+				if config.Paired {
+					job.Server, job.pair = "", pair
+				}
 				select {
-				case <-ctx.Done():
-					close(jobs)
+				case <-runCtx.Done():
 					return
-				default:
-					// Pick random server and domain
-					sIdx := rng.Intn(len(config.Servers))
-					dIdx := rng.Intn(len(config.Domains))
-
-					job := Job{
-						Server: config.Servers[sIdx],
-						Domain: config.Domains[dIdx],
-					}
-
-					select {
-					case <-ctx.Done():
-						close(jobs)
-						return
-					case jobs <- job:
-						// Job sent successfully
-					}
+				case jobs <- job:
 				}
 			}
-		} else {
+		}
+		if config.Paired {
+			pair := 1
 			for i := 0; i < config.Iterations; i++ {
-				for _, server := range config.Servers {
-					for _, domain := range config.Domains {
-						jobs <- Job{Server: server, Domain: domain}
-					}
+				for i := range questions {
+					jobs <- Job{Domain: questions[i].Name, question: &questions[i], ctx: runCtx, pair: pair}
+					pair++
+				}
+			}
+			return
+		}
+		for i := 0; i < config.Iterations; i++ {
+			for _, server := range config.Servers {
+				for i := range questions {
+					jobs <- Job{Server: server, Domain: questions[i].Name, question: &questions[i], ctx: runCtx}
 				}
 			}
-			close(jobs)
 		}
 	}()
 
 	// Wait for workers to finish in a separate goroutine to close results channel
 	go func() {
 		wg.Wait()
-		if config.ShowProgress && totalJobs > 0 {
-			fmt.Println() // New line after progress bar
-		}
+		stopProgress()
 		close(results)
 	}()
 
-	// Collect results
+	// Collect results, past MaxResults by reservoir sampling: the nth
+	// result replaces a random kept one with probability MaxResults/n
+	//nolint:gosec // G404: math/rand is sufficient for non-cryptographic sampling
+	sampler := rand.New(rand.NewSource(time.Now().UnixNano()))
 	allResults := make([]Result, 0, bufferSize)
+	collected := 0
 	for res := range results {
-		allResults = append(allResults, res)
+		res.Offset = res.Time.Sub(runStart)
+		if config.OnResult != nil {
+			config.OnResult(res)
+		}
+		collected++
+		if config.MaxResults <= 0 || len(allResults) < config.MaxResults {
+			allResults = append(allResults, res)
+		} else if i := sampler.Intn(collected); i < config.MaxResults {
+			allResults[i] = res
+		}
 	}
 
 	return allResults
 }
+
+// progressInterval is how often Run reports progress.
+const progressInterval = 250 * time.Millisecond
+
+// reportProgress prints how many of total jobs are done every
+// progressInterval until the returned function is called, which prints the
+// final count and ends the line.
+func reportProgress(completed *atomic.Int64, total int) (stop func()) {
+	start := time.Now()
+	show := func() {
+		n := completed.Load()
+		pct := float64(n) / float64(total) * 100
+		fmt.Printf("\rProgress: %d/%d (%.1f%%) - Elapsed: %v", n, total, pct, time.Since(start).Round(time.Second))
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		last := int64(-1)
+		for {
+			select {
+			case <-done:
+				show()
+				fmt.Println() // New line after progress bar
+				return
+			case <-ticker.C:
+				if n := completed.Load(); n != last {
+					last = n
+					show()
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
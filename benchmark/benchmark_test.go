@@ -1,8 +1,30 @@
 package benchmark
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func TestClientMeasureUDP(t *testing.T) {
@@ -199,8 +221,8 @@ func TestClientStructure(t *testing.T) {
 	if client.Timeout != 5*time.Second {
 		t.Errorf("Expected timeout 5s, got %v", client.Timeout)
 	}
-	if client.httpClient != nil {
-		t.Error("Expected httpClient to be nil initially")
+	if client.dohClients != nil {
+		t.Error("Expected no DoH clients initially")
 	}
 }
 
@@ -328,3 +350,866 @@ func TestRunMultipleServersAndDomains(t *testing.T) {
 		t.Errorf("Expected 12 total jobs (3*2*2), calculated %d", expectedJobs)
 	}
 }
+
+// countingListener counts accepted connections so tests can assert on reuse.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+// startDoTServer runs a local DNS-over-TLS server with a self-signed
+// certificate that answers every A query with 127.0.0.1 and advertises a 10s
+// edns-tcp-keepalive timeout to clients that ask for one.
+// selfSignedCert returns a certificate for 127.0.0.1 valid for an hour.
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func startDoTServer(t *testing.T) (string, *countingListener) {
+	t.Helper()
+	cert := selfSignedCert(t)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingListener{Listener: ln}
+
+	server := &dns.Server{
+		Listener: counting,
+		Net:      "tcp-tls",
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 127.0.0.1")
+			m.Answer = append(m.Answer, rr)
+			if _, ok := tcpKeepalive(r); ok {
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				opt := m.IsEdns0()
+				opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: 100})
+			}
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	return "tls://" + ln.Addr().String(), counting
+}
+
+func TestMeasureDoTFreshConnectionPerQuery(t *testing.T) {
+	server, ln := startDoTServer(t)
+	client := Client{Timeout: 2 * time.Second, NoKeepalive: true}
+
+	for i := 0; i < 3; i++ {
+		if res := client.Measure(server, "example.com"); res.Error != nil {
+			t.Fatalf("query %d failed: %v", i, res.Error)
+		}
+	}
+	if got := ln.accepted.Load(); got != 3 {
+		t.Errorf("expected 3 connections without keepalive, got %d", got)
+	}
+}
+
+func TestMeasureDoTReusesConnectionByDefault(t *testing.T) {
+	server, ln := startDoTServer(t)
+	client := Client{Timeout: 2 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		if res := client.Measure(server, "example.com"); res.Error != nil {
+			t.Fatalf("query %d failed: %v", i, res.Error)
+		}
+	}
+	if got := ln.accepted.Load(); got != 1 {
+		t.Errorf("expected 1 reused connection, got %d", got)
+	}
+}
+
+func TestMeasureDoTReusesPooledConnections(t *testing.T) {
+	server, ln := startDoTServer(t)
+	client := Client{Timeout: 2 * time.Second, ConnsPerServer: 2}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := client.Measure(server, "example.com"); res.Error != nil {
+				t.Errorf("query failed: %v", res.Error)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := ln.accepted.Load(); got < 1 || got > 2 {
+		t.Errorf("expected 1-2 pooled connections, got %d", got)
+	}
+}
+
+func TestMeasureConnectTime(t *testing.T) {
+	dot, _ := startDoTServer(t)
+	doh := startDoHServer(t, 0)
+	for _, tc := range []struct {
+		name   string
+		server string
+		client *Client
+		// reuse is whether the second query should find the connection open
+		reuse bool
+	}{
+		{"DoT", dot, &Client{Timeout: 2 * time.Second}, true},
+		{"DoT without keepalive", dot, &Client{Timeout: 2 * time.Second, NoKeepalive: true}, false},
+		{"DoT with -conns", dot, &Client{Timeout: 2 * time.Second, ConnsPerServer: 1}, true},
+		{"DoH", doh, &Client{Timeout: 2 * time.Second}, true},
+		{"DoH without keepalive", doh, &Client{Timeout: 2 * time.Second, NoKeepalive: true}, false},
+	} {
+		first := tc.client.Measure(tc.server, "example.com")
+		second := tc.client.Measure(tc.server, "example.com")
+		if first.Error != nil || second.Error != nil {
+			t.Fatalf("%s: queries failed: %v, %v", tc.name, first.Error, second.Error)
+		}
+		if first.Connect <= 0 || first.Connect > first.Duration {
+			t.Errorf("%s: expected the first query to include connection setup, got %v of %v", tc.name, first.Connect, first.Duration)
+		}
+		if reused := second.Connect == 0; reused != tc.reuse {
+			t.Errorf("%s: expected reuse %v for the second query, got connection setup %v", tc.name, tc.reuse, second.Connect)
+		}
+	}
+}
+
+func TestDoTPoolTimesOutWhenExhausted(t *testing.T) {
+	pool := newDoTPool(1)
+	client, server := net.Pipe()
+	defer server.Close()
+	dial := func() (*dns.Conn, error) { return &dns.Conn{Conn: client}, nil }
+
+	conn, reused, err := pool.get(dial, time.Second)
+	if err != nil || reused {
+		t.Fatalf("expected fresh connection, got reused=%v err=%v", reused, err)
+	}
+	if _, _, err := pool.get(dial, 10*time.Millisecond); err == nil {
+		t.Error("expected timeout while the only connection is checked out")
+	}
+
+	pool.put(conn)
+	if _, reused, err := pool.get(dial, time.Second); err != nil || !reused {
+		t.Errorf("expected to reuse returned connection, got reused=%v err=%v", reused, err)
+	}
+}
+
+func TestRunClientPreparesDoHClients(t *testing.T) {
+	servers := []string{"https://a.example/dns-query", "h3://b.example/dns-query", "8.8.8.8", "tls://c.example"}
+	client := newRunClient(Config{Servers: servers, Timeout: time.Second})
+	if len(client.dohClients) != 2 || client.dohClients[servers[0]] == client.dohClients[servers[1]] {
+		t.Fatalf("expected a client of its own for each DoH server, got %v", client.dohClients)
+	}
+	if client.dohClient(servers[0]) != client.dohClients[servers[0]] {
+		t.Error("expected queries to use the server's client")
+	}
+
+	cold := newRunClient(Config{Servers: servers, Timeout: time.Second, NoKeepalive: true})
+	if len(cold.dohClients) != 0 || cold.dohClient(servers[0]) == cold.dohClient(servers[0]) {
+		t.Error("expected a new client for every query without keepalive")
+	}
+}
+
+func TestDoHPoolRoundRobin(t *testing.T) {
+	pool := newDoHPool(3, func() *http.Client { return newHTTPClient(time.Second, nil) })
+	seen := make(map[*http.Client]bool)
+	for i := 0; i < 6; i++ {
+		seen[pool.client()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 distinct clients, got %d", len(seen))
+	}
+}
+
+func TestMeasureDoTReportsTCPKeepalive(t *testing.T) {
+	server, _ := startDoTServer(t)
+	client := Client{Timeout: 2 * time.Second}
+
+	res := client.Measure(server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if !res.KeepaliveAdvertised {
+		t.Fatal("expected server to advertise edns-tcp-keepalive")
+	}
+	if res.KeepaliveTimeout != 10*time.Second {
+		t.Errorf("expected 10s keepalive timeout, got %v", res.KeepaliveTimeout)
+	}
+}
+
+func TestTCPKeepaliveAbsent(t *testing.T) {
+	m := new(dns.Msg)
+	if _, ok := tcpKeepalive(m); ok {
+		t.Error("expected no keepalive on a message without OPT")
+	}
+	requestTCPKeepalive(m)
+	if timeout, ok := tcpKeepalive(m); !ok || timeout != 0 {
+		t.Errorf("expected empty keepalive option, got %v %v", timeout, ok)
+	}
+}
+
+func TestRunCallsOnResult(t *testing.T) {
+	server, _ := startDoTServer(t)
+
+	var streamed []Result
+	config := Config{
+		Servers:     []string{server},
+		Domains:     []string{"example.com", "example.org"},
+		Iterations:  2,
+		Concurrency: 2,
+		Timeout:     2 * time.Second,
+		OnResult:    func(res Result) { streamed = append(streamed, res) },
+	}
+
+	results := Run(config)
+	if len(streamed) != len(results) || len(results) != 4 {
+		t.Errorf("expected 4 streamed results matching %d collected, got %d", len(results), len(streamed))
+	}
+}
+
+func TestRunMaxResults(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	streamed := 0
+	results := Run(Config{
+		Servers:     []string{mock.Addr},
+		Domains:     []string{"example.com", "example.org", "example.net"},
+		Iterations:  20,
+		Concurrency: 4,
+		Timeout:     time.Second,
+		MaxResults:  10,
+		OnResult:    func(Result) { streamed++ },
+	})
+	if streamed != 60 {
+		t.Errorf("expected OnResult to see all 60 results, got %d", streamed)
+	}
+	if len(results) != 10 {
+		t.Errorf("expected a sample of 10 results, got %d", len(results))
+	}
+}
+
+func TestRunDurationWeights(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	results := Run(Config{
+		Servers:     []string{mock.Addr},
+		Domains:     []string{"example.com", "example.org", "example.net"},
+		Duration:    300 * time.Millisecond,
+		Concurrency: 4,
+		Timeout:     time.Second,
+		Weights:     map[string]float64{"example.com": 9, "example.net": 0},
+	})
+	counts := make(map[string]int)
+	for _, res := range results {
+		counts[res.Domain]++
+	}
+	if len(results) < 100 {
+		t.Fatalf("expected at least 100 results, got %d", len(results))
+	}
+	if counts["example.net"] != 0 {
+		t.Errorf("expected a zero weight never to be picked, got %d queries", counts["example.net"])
+	}
+	// example.org has no weight, so counts as 1 against example.com's 9
+	if share := float64(counts["example.com"]) / float64(len(results)); share < 0.8 || share > 0.97 {
+		t.Errorf("expected about 90%% of queries for example.com, got %.0f%% (%v)", share*100, counts)
+	}
+}
+
+func TestLogResult(t *testing.T) {
+	failed := Result{Server: "8.8.8.8", Domain: "example.com", Error: errors.New("timeout")}
+	slow := Result{Server: "8.8.8.8", Domain: "example.com", Duration: time.Second, Rcode: "NOERROR"}
+	fast := Result{Server: "8.8.8.8", Domain: "example.com", Duration: 5 * time.Millisecond, Rcode: "NOERROR"}
+	sample := Config{LogSample: 0.01}
+	for _, tc := range []struct {
+		name    string
+		config  Config
+		res     Result
+		sampled bool
+		want    string
+	}{
+		{"verbose error", Config{Verbose: true}, failed, false, "Error resolving example.com: timeout"},
+		{"verbose slow", Config{Verbose: true}, slow, false, "Slow resolve example.com: 1s"},
+		{"verbose fast", Config{Verbose: true}, fast, false, ""},
+		{"sampled error", sample, failed, false, "Error resolving"},
+		{"unsampled slow", sample, slow, false, ""},
+		{"sampled slow", sample, slow, true, "Slow resolve"},
+		{"sampled fast", sample, fast, true, "Resolved example.com: 5ms NOERROR"},
+	} {
+		var buf bytes.Buffer
+		logResult(&buf, tc.config, tc.res, tc.sampled)
+		if got := buf.String(); (tc.want == "") != (got == "") || !strings.Contains(got, tc.want) {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestRunQueriesViaAddress(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	results := Run(Config{
+		Servers:     []string{"192.0.2.53"},
+		Domains:     []string{"example.com"},
+		Iterations:  1,
+		Concurrency: 1,
+		Timeout:     time.Second,
+		Via:         map[string]string{"192.0.2.53": mock.Addr},
+	})
+	if len(results) != 1 || results[0].Error != nil || results[0].Server != "192.0.2.53" {
+		t.Errorf("expected a successful query reported under the configured server, got %+v", results)
+	}
+}
+
+func TestMeasureContextCancelled(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res := (&Client{Timeout: time.Second}).MeasureContext(ctx, mock.Addr, "example.com")
+	if !errors.Is(res.Error, context.Canceled) {
+		t.Errorf("expected the cancelled context's error, got %v", res.Error)
+	}
+}
+
+func TestLookupBudget(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		want   time.Duration
+	}{
+		{Config{Timeout: time.Second}, time.Second},
+		{Config{Timeout: time.Second, SearchDomains: []string{"a", "b"}}, 3 * time.Second},
+		{Config{Timeout: time.Second, Fallback: FallbackDoT}, 3 * time.Second},
+		{Config{}, 0},
+	} {
+		if got := tc.config.lookupBudget(); got != tc.want {
+			t.Errorf("lookupBudget(%+v) = %v, want %v", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	// Nothing listens on this port any more, so every attempt fails fast
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	results := Run(Config{
+		Servers:     []string{addr},
+		Domains:     []string{"example.com"},
+		Iterations:  1,
+		Concurrency: 1,
+		Timeout:     200 * time.Millisecond,
+		Retries:     2,
+	})
+	if len(results) != 1 || results[0].Error == nil || results[0].Retries != 2 {
+		t.Errorf("expected one failed lookup after 2 retries, got %+v", results)
+	}
+
+	// Retries wait on the rate limit like any other query: three attempts at
+	// 20 qps take at least 100ms
+	start := time.Now()
+	results = Run(Config{
+		Servers:     []string{addr},
+		Domains:     []string{"example.com"},
+		Iterations:  1,
+		Concurrency: 1,
+		Timeout:     200 * time.Millisecond,
+		Retries:     2,
+		ServerQPS:   map[string]float64{addr: 20},
+	})
+	if elapsed := time.Since(start); len(results) != 1 || results[0].Retries != 2 || elapsed < 100*time.Millisecond {
+		t.Errorf("expected 2 rate-limited retries to take at least 100ms, got %v for %+v", elapsed, results)
+	}
+
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+	results = Run(Config{Servers: []string{mock.Addr}, Domains: []string{"example.com"}, Iterations: 1, Concurrency: 1, Timeout: time.Second, Retries: 2})
+	if len(results) != 1 || results[0].Error != nil || results[0].Retries != 0 {
+		t.Errorf("expected a first-try success, got %+v", results)
+	}
+}
+
+func TestRunColdWarm(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	results := Run(Config{
+		Servers:     []string{mock.Addr},
+		Domains:     []string{"example.com", "example.org"},
+		Iterations:  1,
+		Concurrency: 1,
+		Timeout:     time.Second,
+		ColdWarm:    true,
+	})
+	if len(results) != 4 {
+		t.Fatalf("expected a cold and a warm query per domain, got %d results", len(results))
+	}
+	for i, res := range results {
+		want := CacheCold
+		if i%2 == 1 {
+			want = CacheWarm
+		}
+		if res.CachePass != want || res.Domain != results[i/2*2].Domain {
+			t.Errorf("result %d: expected %s query for %s, got %s for %s", i, want, results[i/2*2].Domain, res.CachePass, res.Domain)
+		}
+	}
+}
+
+func TestRunPaired(t *testing.T) {
+	var servers []string
+	for i := 0; i < 2; i++ {
+		mock, err := StartMockServer()
+		if err != nil {
+			t.Fatalf("failed to start mock server: %v", err)
+		}
+		defer func() { _ = mock.Close() }()
+		servers = append(servers, mock.Addr)
+	}
+
+	results := Run(Config{
+		Servers:     servers,
+		Domains:     []string{"example.com", "example.org", "example.net"},
+		Iterations:  2,
+		Concurrency: 2,
+		Timeout:     time.Second,
+		Paired:      true,
+	})
+	if len(results) != 12 {
+		t.Fatalf("expected every domain on both servers twice, got %d results", len(results))
+	}
+	pairs := make(map[int][]Result)
+	for _, res := range results {
+		pairs[res.Pair] = append(pairs[res.Pair], res)
+	}
+	if len(pairs) != 6 {
+		t.Fatalf("expected 6 pairs, got %d", len(pairs))
+	}
+	first := make(map[string]int)
+	for n, pair := range pairs {
+		if n == 0 || len(pair) != 2 || pair[0].Domain != pair[1].Domain || pair[0].Server == pair[1].Server {
+			t.Errorf("pair %d: expected one domain on both servers, got %+v", n, pair)
+			continue
+		}
+		first[pair[0].Server]++
+	}
+	if first[servers[0]] != 3 || first[servers[1]] != 3 {
+		t.Errorf("expected each server to go first in half the pairs, got %v", first)
+	}
+}
+
+func TestRunServerTimeout(t *testing.T) {
+	slow := func(w dns.ResponseWriter, r *dns.Msg) {
+		time.Sleep(200 * time.Millisecond)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	}
+	patient, impatient := startUDPServer(t, slow), startUDPServer(t, slow)
+
+	results := Run(Config{
+		Servers:     []string{patient, impatient},
+		Domains:     []string{"example.com"},
+		Iterations:  1,
+		Concurrency: 2,
+		Timeout:     50 * time.Millisecond,
+		Specs:       map[string]ServerSpec{patient: {Name: "patient", Timeout: time.Second}},
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, res := range results {
+		if failed := res.Error != nil; failed != (res.Server == impatient) {
+			t.Errorf("%s: expected only the server without its own timeout to time out, got %v", res.Server, res.Error)
+		}
+	}
+}
+
+func TestClientMeasureTCP(t *testing.T) {
+	// UDP answers from this server are truncated, so only a TCP query gets
+	// the A record back.
+	server := startTruncatingServer(t)
+	client := Client{Timeout: time.Second}
+
+	res := client.Measure("tcp://"+server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("tcp query failed: %v", res.Error)
+	}
+	if res.Protocol != "tcp" {
+		t.Errorf("expected protocol tcp, got %q", res.Protocol)
+	}
+	if res := client.Measure(server, "example.com"); res.Protocol != "udp" {
+		t.Errorf("expected protocol udp for a plain server, got %q", res.Protocol)
+	}
+}
+
+func TestServerProtocol(t *testing.T) {
+	for server, want := range map[string]string{
+		"8.8.8.8":                      "udp",
+		"tcp://8.8.8.8":                "tcp",
+		"tls://1.1.1.1":                "dot",
+		"https://dns.google/dns-query": "doh",
+		"h3://dns.google/dns-query":    "doh3",
+	} {
+		if got := ServerProtocol(server); got != want {
+			t.Errorf("%s: expected %s, got %s", server, want, got)
+		}
+	}
+	if got := ProtocolLabel("dot"); got != "dot" {
+		t.Errorf("expected a label to be kept, got %s", got)
+	}
+}
+
+func TestMeasureCountsSections(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	res := (&Client{Timeout: time.Second}).Measure(mock.Addr, "example.com")
+	if res.Error != nil || res.Answers != 1 || res.EmptyAnswer {
+		t.Errorf("expected one answer record, got %d (empty=%v, err=%v)", res.Answers, res.EmptyAnswer, res.Error)
+	}
+	if res.Rcode != "NOERROR" {
+		t.Errorf("expected rcode NOERROR, got %q", res.Rcode)
+	}
+	if res.TTL != time.Minute {
+		t.Errorf("expected the answer's 60s TTL, got %v", res.TTL)
+	}
+
+	// The mock answers anything but A with an empty NOERROR response.
+	res = (&Client{Timeout: time.Second, QType: dns.TypeAAAA}).Measure(mock.Addr, "example.com")
+	if res.Error != nil || res.Answers != 0 || !res.EmptyAnswer {
+		t.Errorf("expected an empty NOERROR answer, got %d answers (empty=%v, err=%v)", res.Answers, res.EmptyAnswer, res.Error)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.Answer = []dns.RR{
+		&dns.CNAME{Hdr: dns.RR_Header{Ttl: 3600}},
+		&dns.A{Hdr: dns.RR_Header{Ttl: 20}},
+	}
+	if ttl := cacheTTL(resp); ttl != 20*time.Second {
+		t.Errorf("expected the lowest answer TTL, got %v", ttl)
+	}
+
+	negative := new(dns.Msg)
+	negative.Rcode = dns.RcodeNameError
+	negative.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Ttl: 900}, Minttl: 300}}
+	if ttl := cacheTTL(negative); ttl != 300*time.Second {
+		t.Errorf("expected the SOA minimum as negative TTL, got %v", ttl)
+	}
+	if ttl := cacheTTL(new(dns.Msg)); ttl != 0 {
+		t.Errorf("expected no TTL for an empty response, got %v", ttl)
+	}
+}
+
+func TestMeasureCacheBust(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	client := &Client{Timeout: time.Second, CacheBust: true}
+	first := client.Measure(mock.Addr, "example.com")
+	second := client.Measure(mock.Addr, "example.com")
+	if first.Error != nil || second.Error != nil {
+		t.Fatalf("queries failed: %v, %v", first.Error, second.Error)
+	}
+	for _, res := range []Result{first, second} {
+		label, rest, _ := strings.Cut(res.QueryName, ".")
+		if len(label) != 8 || rest != "example.com." || res.Domain != "example.com" {
+			t.Errorf("expected a random label before example.com., got %q (domain %q)", res.QueryName, res.Domain)
+		}
+	}
+	if first.QueryName == second.QueryName {
+		t.Errorf("expected a new name for every query, got %q twice", first.QueryName)
+	}
+}
+
+func TestWithPort(t *testing.T) {
+	tests := []struct{ host, want string }{
+		{"1.1.1.1", "1.1.1.1:53"},
+		{"1.1.1.1:5353", "1.1.1.1:5353"},
+		{"dns.google", "dns.google:53"},
+		{"2606:4700:4700::1111", "[2606:4700:4700::1111]:53"},
+		{"[2606:4700:4700::1111]", "[2606:4700:4700::1111]:53"},
+		{"[2606:4700:4700::1111]:5353", "[2606:4700:4700::1111]:5353"},
+	}
+	for _, tt := range tests {
+		if got := withPort(tt.host, "53"); got != tt.want {
+			t.Errorf("withPort(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCNAMEChain(t *testing.T) {
+	msg := func(records ...string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.com.", dns.TypeA)
+		for _, r := range records {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name           string
+		resp           *dns.Msg
+		wantDepth      int
+		wantUnfollowed bool
+	}{
+		{"direct answer", msg("www.example.com. 60 IN A 192.0.2.1"), 0, false},
+		{"two hops", msg(
+			"www.example.com. 60 IN CNAME a.cdn.net.",
+			"a.cdn.net. 60 IN CNAME b.cdn.net.",
+			"b.cdn.net. 60 IN A 192.0.2.1",
+		), 2, false},
+		{"unfollowed", msg(
+			"www.example.com. 60 IN CNAME a.cdn.net.",
+			"a.cdn.net. 60 IN CNAME b.cdn.net.",
+		), 2, true},
+		{"empty", msg(), 0, false},
+	}
+	for _, tt := range tests {
+		depth, unfollowed := cnameChain(tt.resp)
+		if depth != tt.wantDepth || unfollowed != tt.wantUnfollowed {
+			t.Errorf("%s: got depth=%d unfollowed=%v, want %d %v", tt.name, depth, unfollowed, tt.wantDepth, tt.wantUnfollowed)
+		}
+	}
+}
+
+func TestCNAMEChainLoop(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("a.example.", dns.TypeA)
+	for _, r := range []string{"a.example. 60 IN CNAME b.example.", "b.example. 60 IN CNAME a.example."} {
+		rr, _ := dns.NewRR(r)
+		m.Answer = append(m.Answer, rr)
+	}
+	if depth, unfollowed := cnameChain(m); depth != 2 || !unfollowed {
+		t.Errorf("expected loop to stop after 2 hops as unfollowed, got %d %v", depth, unfollowed)
+	}
+}
+
+// startDoHServer runs a local DoH server that answers A queries with
+// 127.0.0.1. bodyDelay is slept between sending headers and the body.
+// dohHandler answers DoH POSTs with 127.0.0.1, flushing the headers and
+// then waiting bodyDelay before writing the body.
+func dohHandler(bodyDelay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := new(dns.Msg)
+		if err := req.Unpack(data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(req)
+		rr, _ := dns.NewRR(req.Question[0].Name + " 60 IN A 127.0.0.1")
+		m.Answer = append(m.Answer, rr)
+		out, _ := m.Pack()
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(bodyDelay)
+		_, _ = w.Write(out)
+	})
+}
+
+func startDoHServer(t *testing.T, bodyDelay time.Duration) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(dohHandler(bodyDelay))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL + "/dns-query"
+}
+
+func TestMeasureDoHRecordsTTFB(t *testing.T) {
+	server := startDoHServer(t, 50*time.Millisecond)
+	client := Client{Timeout: 2 * time.Second}
+
+	res := client.Measure(server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("DoH query failed: %v", res.Error)
+	}
+	if res.TTFB <= 0 || res.TTFB >= res.Duration {
+		t.Fatalf("expected 0 < TTFB < Duration, got TTFB=%v Duration=%v", res.TTFB, res.Duration)
+	}
+	if body := res.Duration - res.TTFB; body < 40*time.Millisecond {
+		t.Errorf("expected body read to include the 50ms delay, got %v", body)
+	}
+	if res.HTTPVersion != "HTTP/2.0" {
+		t.Errorf("expected HTTP/2.0, got %q", res.HTTPVersion)
+	}
+}
+
+// startDoH3Server serves DoH over HTTP/3 on loopback and returns its h3:// address.
+func startDoH3Server(t *testing.T) string {
+	t.Helper()
+	var lc net.ListenConfig
+	pc, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on udp: %v", err)
+	}
+	srv := &http3.Server{
+		Handler:   dohHandler(0),
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}),
+	}
+	go func() { _ = srv.Serve(pc) }()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		_ = pc.Close()
+	})
+	return "h3://" + pc.LocalAddr().String() + "/dns-query"
+}
+
+func TestMeasureDoHOverHTTP3(t *testing.T) {
+	server := startDoH3Server(t)
+	client := Client{Timeout: 2 * time.Second}
+
+	res := client.Measure(server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("DoH/3 query failed: %v", res.Error)
+	}
+	if res.HTTPVersion != "HTTP/3.0" || res.TTFB <= 0 {
+		t.Errorf("expected an HTTP/3.0 answer with TTFB, got %q (TTFB %v)", res.HTTPVersion, res.TTFB)
+	}
+
+	client.Proxy = &url.URL{Scheme: "socks5", Host: "127.0.0.1:1080"}
+	if res := client.Measure(server, "example.com"); !errors.Is(res.Error, errH3Proxy) {
+		t.Errorf("expected HTTP/3 through a proxy to be refused, got %v", res.Error)
+	}
+}
+
+// startUDPServer runs handler as a UDP DNS server on a random loopback port.
+func startUDPServer(t *testing.T, handler dns.HandlerFunc) string {
+	t.Helper()
+	var lc net.ListenConfig
+	pc, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on udp: %v", err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestMeasureDoHCustomEndpoint(t *testing.T) {
+	// A proxy on a non-standard port that only takes GET at /resolve?ct=
+	var gets atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/resolve" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !r.URL.Query().Has("ct") {
+			http.Error(w, "missing ct", http.StatusBadRequest)
+			return
+		}
+		gets.Add(1)
+		data, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		dohHandler(0).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	client := Client{Timeout: 2 * time.Second}
+
+	for range 2 {
+		if res := client.Measure(srv.URL+"/resolve?ct=", "example.com"); res.Error != nil {
+			t.Fatalf("expected the query to fall back to GET, got %v", res.Error)
+		}
+	}
+	if gets.Load() != 2 || !client.dohUsesGET(srv.URL+"/resolve?ct=") {
+		t.Errorf("expected both queries to arrive as GET, got %d", gets.Load())
+	}
+
+	res := client.Measure(srv.URL+"/dns-query", "example.com")
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "404 Not Found") || !strings.Contains(res.Error.Error(), "no DoH endpoint at this path") {
+		t.Errorf("expected an explicit error for a wrong path, got %v", res.Error)
+	}
+}
+
+func TestMeasureDoHNotDNSMessage(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, "<html>Welcome</html>")
+	}))
+	defer srv.Close()
+	client := Client{Timeout: 2 * time.Second}
+
+	res := client.Measure(srv.URL+"/", "example.com")
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "answered with text/html") {
+		t.Errorf("expected an error naming the content type, got %v", res.Error)
+	}
+}
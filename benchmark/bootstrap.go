@@ -0,0 +1,261 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// BootstrapResult describes how the hostname of a DoT or DoH server was resolved
+// before the server itself could be queried. That lookup is normally left
+// to the system resolver and hidden inside the first query's latency.
+type BootstrapResult struct {
+	Server string
+	Host   string
+	// Resolver is the bootstrap resolver that was asked, or empty for the
+	// system resolver.
+	Resolver string
+	Addrs    []netip.Addr
+	Duration time.Duration
+	Err      error
+}
+
+// BootstrapHost returns the hostname a DoT (tls://) or DoH (https:// or
+// h3://) server has to be resolved from, or "" when it is given as an IP
+// address or is not an encrypted server.
+func BootstrapHost(server string) string {
+	var host string
+	switch {
+	case strings.HasPrefix(server, "tls://"):
+		h, _, err := net.SplitHostPort(withPort(strings.TrimPrefix(server, "tls://"), "853"))
+		if err != nil {
+			return ""
+		}
+		host = h
+	case strings.HasPrefix(server, "https://"), strings.HasPrefix(server, "h3://"):
+		u, err := url.Parse(server)
+		if err != nil {
+			return ""
+		}
+		host = u.Hostname()
+	default:
+		return ""
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return ""
+	}
+	return host
+}
+
+// Bootstrap resolves the hostname of server the way a query to it would,
+// through resolver (a plain DNS server, port 53 by default) or the system
+// resolver when resolver is empty, and times the lookup.
+func Bootstrap(server, resolver string, timeout time.Duration) BootstrapResult {
+	res := BootstrapResult{Server: server, Host: BootstrapHost(server), Resolver: resolver}
+	if res.Host == "" {
+		res.Err = fmt.Errorf("%s has no hostname to bootstrap", server)
+		return res
+	}
+	start := time.Now()
+	res.Addrs, res.Err = lookupHost(res.Host, resolver, timeout)
+	res.Duration = time.Since(start)
+	return res
+}
+
+// lookupHost returns the IPv4 and IPv6 addresses of host, asking resolver
+// directly for A and AAAA records in parallel, or the system resolver when
+// resolver is empty. IPv4 addresses come first.
+func lookupHost(host, resolver string, timeout time.Duration) ([]netip.Addr, error) {
+	if resolver == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		for i := range addrs {
+			addrs[i] = addrs[i].Unmap()
+		}
+		return addrs, err
+	}
+
+	server := withPort(resolver, "53")
+	client := &dns.Client{Timeout: timeout}
+	var (
+		wg      sync.WaitGroup
+		answers [2][]netip.Addr
+		errs    [2]error
+	)
+	for i, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(host), qtype)
+			resp, _, err := client.Exchange(m, server)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if resp.Rcode != dns.RcodeSuccess {
+				errs[i] = fmt.Errorf("%s answered %s", resolver, dns.RcodeToString[resp.Rcode])
+				return
+			}
+			for _, rr := range resp.Answer {
+				var ip net.IP
+				switch rr := rr.(type) {
+				case *dns.A:
+					ip = rr.A
+				case *dns.AAAA:
+					ip = rr.AAAA
+				}
+				if addr, ok := netip.AddrFromSlice(ip); ok {
+					answers[i] = append(answers[i], addr.Unmap())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	addrs := append(answers[0], answers[1]...)
+	if len(addrs) == 0 {
+		if err := errors.Join(errs[:]...); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s has no A or AAAA records at %s", host, resolver)
+	}
+	return addrs, nil
+}
+
+// bootstrapAddrs resolves host through the client's bootstrap resolver,
+// remembering the answer so only the first connection pays for the lookup.
+func (c *Client) bootstrapAddrs(host string) ([]netip.Addr, error) {
+	c.bootstrapMu.Lock()
+	defer c.bootstrapMu.Unlock()
+	if addrs, ok := c.bootstrapCache[host]; ok {
+		return addrs, nil
+	}
+	addrs, err := lookupHost(host, c.Bootstrap, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("bootstrap lookup of %s via %s: %w", host, c.Bootstrap, err)
+	}
+	if c.bootstrapCache == nil {
+		c.bootstrapCache = make(map[string][]netip.Addr)
+	}
+	c.bootstrapCache[host] = addrs
+	return addrs, nil
+}
+
+// bootstrapTargets returns the ip:port addresses to try for addr, resolving
+// its host through the bootstrap resolver unless it is already an IP.
+func (c *Client) bootstrapTargets(addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{addr}, nil
+	}
+	addrs, err := c.bootstrapAddrs(host)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]string, len(addrs))
+	for i, a := range addrs {
+		targets[i] = net.JoinHostPort(a.String(), port)
+	}
+	return targets, nil
+}
+
+// prefetchBootstrap resolves the hostnames of all encrypted servers up
+// front, so the lookups don't count towards any query's latency. Failures
+// are left for the queries to report.
+func (c *Client) prefetchBootstrap(servers []string) {
+	for _, s := range servers {
+		if host := BootstrapHost(s); host != "" {
+			_, _ = c.bootstrapAddrs(host)
+		}
+	}
+}
+
+// dialBootstrapDNS dials a DoT server at the addresses the bootstrap
+// resolver returned for its host, trying each in turn.
+func (c *Client) dialBootstrapDNS(client *dns.Client, addr string) (*dns.Conn, error) {
+	targets, err := c.bootstrapTargets(addr)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, target := range targets {
+		if err := c.bindDNS(client, target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn, err := client.Dial(target)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialBootstrap is a DialContext for DoH transports that resolves hostnames
+// through the bootstrap resolver, trying each address in turn.
+func (c *Client) dialBootstrap(ctx context.Context, network, addr string) (net.Conn, error) {
+	targets, err := c.bootstrapTargets(addr)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, target := range targets {
+		conn, err := c.dialContext(ctx, network, target)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialBootstrapQUIC is the HTTP/3 counterpart of dialBootstrap. The TLS
+// server name is already set to the original host by the transport.
+func (c *Client) dialBootstrapQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	targets, err := c.bootstrapTargets(addr)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, target := range targets {
+		conn, err := quic.DialAddrEarly(ctx, target, tlsCfg, cfg)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// useBootstrap points a DoH client's transport at the bootstrap resolver.
+// Through a proxy, hostnames are left for the proxy to resolve.
+func (c *Client) useBootstrap(client *http.Client) *http.Client {
+	if c.Bootstrap == "" || c.Proxy != nil {
+		return client
+	}
+	switch t := client.Transport.(type) {
+	case *http.Transport:
+		t.DialContext = c.dialBootstrap
+	case *http3.Transport:
+		t.Dial = c.dialBootstrapQUIC
+	}
+	return client
+}
@@ -0,0 +1,87 @@
+package benchmark
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBootstrapHost(t *testing.T) {
+	tests := map[string]string{
+		"https://dns.google/dns-query":        "dns.google",
+		"h3://cloudflare-dns.com/dns-query":   "cloudflare-dns.com",
+		"https://1.1.1.1/dns-query":           "",
+		"https://[2606:4700::1111]/dns-query": "",
+		"tls://dns.google":                    "dns.google",
+		"tls://dns.google:853":                "dns.google",
+		"tls://1.1.1.1":                       "",
+		"8.8.8.8":                             "",
+	}
+	for server, want := range tests {
+		if got := BootstrapHost(server); got != want {
+			t.Errorf("BootstrapHost(%q) = %q, want %q", server, got, want)
+		}
+	}
+}
+
+func TestBootstrapThroughResolver(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	res := Bootstrap("https://doh.example.test/dns-query", mock.Addr, time.Second)
+	if res.Err != nil {
+		t.Fatalf("bootstrap failed: %v", res.Err)
+	}
+	if res.Host != "doh.example.test" || len(res.Addrs) != 1 || res.Addrs[0] != netip.MustParseAddr("127.0.0.1") || res.Duration <= 0 {
+		t.Errorf("unexpected bootstrap result: %+v", res)
+	}
+}
+
+func TestMeasureDoHWithBootstrap(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	// The mock resolves every name to 127.0.0.1, where the DoH servers
+	// listen; the system resolver knows nothing about doh.example.test.
+	for _, server := range []string{startDoHServer(t, 0), startDoH3Server(t)} {
+		named := strings.Replace(server, "127.0.0.1", "doh.example.test", 1)
+		client := Client{Timeout: 2 * time.Second, Bootstrap: mock.Addr}
+		if res := client.Measure(named, "example.com"); res.Error != nil {
+			t.Errorf("%s: query via bootstrap failed: %v", named, res.Error)
+		}
+	}
+}
+
+func TestRunDoTWithBootstrap(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	server, _ := startDoTServer(t)
+	named := strings.Replace(server, "127.0.0.1", "dot.example.test", 1)
+	for _, conns := range []int{0, 2} {
+		results := Run(Config{
+			Servers:        []string{named},
+			Domains:        []string{"example.com"},
+			Iterations:     2,
+			Concurrency:    1,
+			Timeout:        2 * time.Second,
+			ConnsPerServer: conns,
+			Bootstrap:      mock.Addr,
+		})
+		for _, res := range results {
+			if res.Error != nil {
+				t.Errorf("conns=%d: DoT query via bootstrap failed: %v", conns, res.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package benchmark
+
+import "math/rand"
+
+// cacheBustAlphabet is what random labels are made of: lowercase letters
+// and digits, so names stay valid hostnames.
+const cacheBustAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// cacheBustName prepends a random 8-character label to name, giving a name
+// no resolver has seen and so can't answer from its cache.
+func cacheBustName(name string) string {
+	label := make([]byte, 8)
+	for i := range label {
+		//nolint:gosec // G404: the label only has to be unpredictable to caches
+		label[i] = cacheBustAlphabet[rand.Intn(len(cacheBustAlphabet))]
+	}
+	return string(label) + "." + name
+}
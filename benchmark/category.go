@@ -0,0 +1,93 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/miekg/dns"
+)
+
+// Categories of a result that isn't a plain NOERROR answer. The first
+// four are lookups that failed without an answer; the rest are answers
+// with an error code. NXDOMAIN is a valid answer, but counted on its own
+// since a resolver that returns it for names others resolve is filtering.
+const (
+	// CategoryTimeout: no answer within the timeout.
+	CategoryTimeout = "timeout"
+	// CategoryConnRefused: the server's port was closed.
+	CategoryConnRefused = "conn-refused"
+	// CategoryTLS: the TLS handshake of a DoT, DoH or DoQ query failed,
+	// e.g. on an untrusted certificate.
+	CategoryTLS = "tls"
+	// CategoryNetwork: any other failure to get an answer (unreachable
+	// network, reset connection, DoH HTTP error, malformed response, ...).
+	CategoryNetwork = "network"
+	// CategoryServfail, CategoryRefused and CategoryNXDomain are answers
+	// with those response codes.
+	CategoryServfail = "servfail"
+	CategoryRefused  = "refused"
+	CategoryNXDomain = "nxdomain"
+	// CategoryRcode is an answer with any other error code (FORMERR,
+	// NOTIMP, ...).
+	CategoryRcode = "other-rcode"
+)
+
+// Categories lists every category, failures without an answer first.
+var Categories = []string{
+	CategoryTimeout, CategoryConnRefused, CategoryTLS, CategoryNetwork,
+	CategoryServfail, CategoryRefused, CategoryRcode, CategoryNXDomain,
+}
+
+// Classify returns the category of res, or "" for a NOERROR answer. Errors
+// read back from an export are plain strings, so their text is checked
+// when the error types say nothing.
+func Classify(res Result) string {
+	if err := res.Error; err != nil {
+		var netErr net.Error
+		var certErr *tls.CertificateVerificationError
+		var headerErr tls.RecordHeaderError
+		var alertErr tls.AlertError
+		var authorityErr x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		var invalidErr x509.CertificateInvalidError
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout(),
+			errors.Is(err, context.DeadlineExceeded),
+			errors.Is(err, os.ErrDeadlineExceeded):
+			return CategoryTimeout
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return CategoryConnRefused
+		case errors.As(err, &certErr), errors.As(err, &headerErr), errors.As(err, &alertErr),
+			errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+			return CategoryTLS
+		}
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+			return CategoryTimeout
+		case strings.Contains(msg, "connection refused"):
+			return CategoryConnRefused
+		case strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+			return CategoryTLS
+		}
+		return CategoryNetwork
+	}
+
+	switch res.Rcode {
+	case "", dns.RcodeToString[dns.RcodeSuccess]:
+		return ""
+	case dns.RcodeToString[dns.RcodeServerFailure]:
+		return CategoryServfail
+	case dns.RcodeToString[dns.RcodeRefused]:
+		return CategoryRefused
+	case dns.RcodeToString[dns.RcodeNameError]:
+		return CategoryNXDomain
+	}
+	return CategoryRcode
+}
@@ -0,0 +1,39 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		res  Result
+		want string
+	}{
+		{Result{Rcode: "NOERROR"}, ""},
+		{Result{}, ""},
+		{Result{Rcode: "NXDOMAIN"}, CategoryNXDomain},
+		{Result{Rcode: "SERVFAIL"}, CategoryServfail},
+		{Result{Rcode: "REFUSED"}, CategoryRefused},
+		{Result{Rcode: "NOTIMP"}, CategoryRcode},
+		{Result{Error: fmt.Errorf("read: %w", os.ErrDeadlineExceeded)}, CategoryTimeout},
+		{Result{Error: context.DeadlineExceeded}, CategoryTimeout},
+		{Result{Error: &net.OpError{Op: "read", Net: "udp", Err: syscall.ECONNREFUSED}}, CategoryConnRefused},
+		{Result{Error: fmt.Errorf("dial: %w", x509.UnknownAuthorityError{})}, CategoryTLS},
+		{Result{Error: errors.New("DoH error: 502 Bad Gateway: ")}, CategoryNetwork},
+		// Errors read back from exports only have their text
+		{Result{Error: errors.New("read udp 10.0.0.2:5353->8.8.8.8:53: i/o timeout")}, CategoryTimeout},
+		{Result{Error: errors.New("read udp 127.0.0.1:5353->127.0.0.1:53: recvfrom: connection refused")}, CategoryConnRefused},
+		{Result{Error: errors.New("tls: failed to verify certificate: x509: certificate signed by unknown authority")}, CategoryTLS},
+	} {
+		if got := Classify(tc.res); got != tc.want {
+			t.Errorf("Classify(%+v) = %q, want %q", tc.res, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package benchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dohContentType is the media type of DoH queries and answers (RFC 8484).
+const dohContentType = "application/dns-message"
+
+// dohUsesGET reports whether a DoH server turned out to accept only GET
+// queries (see measureDoH).
+func (c *Client) dohUsesGET(serverAddr string) bool {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	return c.dohGET[serverAddr]
+}
+
+// setDoHGET makes every further query to a DoH server a GET.
+func (c *Client) setDoHGET(serverAddr string) {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	if c.dohGET == nil {
+		c.dohGET = make(map[string]bool)
+	}
+	c.dohGET[serverAddr] = true
+}
+
+// newDoHRequest builds an RFC 8484 request for the wire-format query data:
+// a POST with the query as its body or, with get, a GET carrying it
+// base64url-encoded in the dns parameter. The endpoint's own port, path and
+// query parameters (e.g. an internal proxy's "/resolve?ct=") are kept as
+// they are.
+func newDoHRequest(ctx context.Context, endpoint string, data []byte, get bool) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if get {
+		sep := "?"
+		if strings.Contains(endpoint, "?") {
+			sep = "&"
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint+sep+"dns="+base64.RawURLEncoding.EncodeToString(data), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", dohContentType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dohContentType)
+	return req, nil
+}
+
+// dohStatusError describes a DoH response other than 200 OK, with a hint at
+// the likely cause for the statuses a misconfigured endpoint typically
+// returns and the first line of the body.
+func dohStatusError(endpoint string, resp *http.Response) error {
+	msg := fmt.Sprintf("DoH error: %s from %s", resp.Status, redactURL(endpoint))
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		msg += " (no DoH endpoint at this path)"
+	case http.StatusMethodNotAllowed:
+		msg += " (the endpoint accepts neither POST nor GET queries)"
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		msg += " (the endpoint rejected an " + dohContentType + " query)"
+	case http.StatusUnauthorized, http.StatusForbidden:
+		msg += " (the endpoint requires authorization)"
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return fmt.Errorf("%s (failed to read body: %w)", msg, err)
+	}
+	line, _, _ := bufio.NewReader(bytes.NewReader(body)).ReadLine()
+	if detail := strings.TrimSpace(string(line)); detail != "" {
+		msg += ": " + detail
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// notDNSMessage explains a 200 OK response that doesn't unpack as DNS,
+// which is usually a web page served at the wrong path.
+func notDNSMessage(endpoint string, resp *http.Response, err error) error {
+	ct := resp.Header.Get("Content-Type")
+	if ct == "" || strings.HasPrefix(ct, dohContentType) {
+		return err
+	}
+	return fmt.Errorf("DoH error: %s answered with %s instead of %s; check the path: %w", redactURL(endpoint), ct, dohContentType, err)
+}
+
+// redactURL hides the password of an endpoint with credentials in errors.
+func redactURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,50 @@
+package benchmark
+
+import (
+	"maps"
+	"slices"
+)
+
+// DuelPair is one domain queried against both servers of a duel, back to
+// back, so the two results saw the same network conditions.
+type DuelPair struct {
+	Domain string
+	A, B   Result
+}
+
+// RunDuel runs a matched workload against exactly two servers
+// (config.Servers[0] and [1]): a Paired Run, in which every job queries one
+// domain on both and which server goes first alternates between jobs so
+// neither benefits from the other warming a shared path. Iterations,
+// Duration and Concurrency work as in Run; OnResult is called for both
+// results of each pair. Pairs are returned in the order they were queued.
+func RunDuel(config Config) []DuelPair {
+	if len(config.Servers) != 2 {
+		return nil
+	}
+	config.Paired = true
+	config.Concurrency = max(config.Concurrency, 1)
+	a := config.Servers[0]
+
+	byPair := make(map[int]*DuelPair)
+	for _, res := range Run(config) {
+		p := byPair[res.Pair]
+		if p == nil {
+			p = &DuelPair{Domain: res.Domain}
+			byPair[res.Pair] = p
+		}
+		if res.Server == a {
+			p.A = res
+		} else {
+			p.B = res
+		}
+	}
+	out := make([]DuelPair, 0, len(byPair))
+	for _, n := range slices.Sorted(maps.Keys(byPair)) {
+		// A -duration run can end between the two queries of its last pairs
+		if p := byPair[n]; p.A.Server != "" && p.B.Server != "" {
+			out = append(out, *p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRunDuel(t *testing.T) {
+	answer := func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	}
+	a := startUDPServer(t, answer)
+	b := startUDPServer(t, answer)
+
+	var streamed int
+	pairs := RunDuel(Config{
+		Servers:     []string{a, b},
+		Domains:     []string{"example.com", "example.org"},
+		Iterations:  3,
+		Concurrency: 2,
+		Timeout:     time.Second,
+		OnResult:    func(Result) { streamed++ },
+	})
+	if len(pairs) != 6 {
+		t.Fatalf("expected 6 pairs, got %d", len(pairs))
+	}
+	for _, p := range pairs {
+		if p.A.Server != a || p.B.Server != b {
+			t.Errorf("pair servers swapped: %s vs %s", p.A.Server, p.B.Server)
+		}
+		if p.A.Domain != p.Domain || p.B.Domain != p.Domain {
+			t.Errorf("pair for %s has results for %s and %s", p.Domain, p.A.Domain, p.B.Domain)
+		}
+		if p.A.Error != nil || p.B.Error != nil {
+			t.Errorf("unexpected errors: %v, %v", p.A.Error, p.B.Error)
+		}
+	}
+	if streamed != 12 {
+		t.Errorf("expected OnResult for 12 results, got %d", streamed)
+	}
+
+	if RunDuel(Config{Servers: []string{a}, Domains: []string{"example.com"}, Iterations: 1}) != nil {
+		t.Error("expected no pairs without exactly two servers")
+	}
+}
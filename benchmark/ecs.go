@@ -0,0 +1,115 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ecsProbeName is answered by Google's authoritative servers with TXT
+// records naming the address the query came from and, when the resolver
+// sent one, the client subnet it passed on.
+const ecsProbeName = "o-o.myaddr.l.google.com."
+
+// ParseClientSubnet parses an EDNS Client Subnet prefix such as
+// "203.0.113.0/24". A bare address gets the prefix lengths RFC 7871
+// recommends for privacy, /24 for IPv4 and /56 for IPv6. Host bits are
+// cleared, as they must not be sent.
+func ParseClientSubnet(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid client subnet %q: %w", s, err)
+		}
+		bits := 24
+		if addr.Is6() {
+			bits = 56
+		}
+		return addr.Prefix(bits)
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid client subnet %q: %w", s, err)
+	}
+	return p.Masked(), nil
+}
+
+// clientSubnetOption builds the EDNS0 option carrying p.
+func clientSubnetOption(p netip.Prefix) *dns.EDNS0_SUBNET {
+	family := uint16(1)
+	if p.Addr().Is6() {
+		family = 2
+	}
+	return &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        family,
+		SourceNetmask: uint8(p.Bits()), //nolint:gosec // G115: prefix lengths are at most 128
+		Address:       net.IP(p.Addr().AsSlice()),
+	}
+}
+
+// ECSProbeResult is what a resolver revealed to an authoritative server
+// about one query.
+type ECSProbeResult struct {
+	// Egress is the address the resolver queried from
+	Egress string
+	// Subnet is the client subnet the resolver passed on, empty when it
+	// sent none
+	Subnet string
+	Err    error
+}
+
+// ProbeECS asks serverAddr for ecsProbeName, with the client's EDNS0
+// settings (including ClientSubnet, if set), and reports whether the
+// resolver forwarded a client subnet upstream.
+func (c *Client) ProbeECS(serverAddr string) ECSProbeResult {
+	m := newQuery(ecsProbeName, dns.TypeTXT)
+	c.applyEDNS(m)
+	resp, _, err := c.exchange(context.Background(), serverAddr, m)
+	if err != nil {
+		return ECSProbeResult{Err: err}
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return ECSProbeResult{Err: fmt.Errorf("%s", dns.RcodeToString[resp.Rcode])}
+	}
+	return readECSProbe(resp)
+}
+
+// readECSProbe picks the egress address and forwarded subnet out of the
+// TXT records in an answer for ecsProbeName.
+func readECSProbe(resp *dns.Msg) ECSProbeResult {
+	var res ECSProbeResult
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		text := strings.Join(txt.Txt, " ")
+		if subnet, ok := strings.CutPrefix(text, "edns0-client-subnet "); ok {
+			res.Subnet = strings.TrimSpace(subnet)
+		} else if _, err := netip.ParseAddr(text); err == nil {
+			res.Egress = text
+		}
+	}
+	if res.Egress == "" {
+		res.Err = fmt.Errorf("no address in the answer")
+	}
+	return res
+}
+
+// RunECSProbe probes every server in config in parallel and returns the
+// results by server.
+func RunECSProbe(config Config) map[string]ECSProbeResult {
+	client := &Client{
+		Timeout:      config.Timeout,
+		Fallback:     config.Fallback,
+		Proxy:        config.Proxy,
+		UDPSize:      config.UDPSize,
+		ClientSubnet: config.ClientSubnet,
+	}
+	return probeServers(config, client.ProbeECS)
+}
@@ -0,0 +1,59 @@
+package benchmark
+
+import (
+	"slices"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultUDPSize is the EDNS0 UDP payload size advertised when EDNS0 is
+// turned on without a size: the DNS Flag Day 2020 recommendation, which
+// avoids IP fragmentation on nearly every path.
+const DefaultUDPSize = 1232
+
+// queryPaddingBlock is the size padded queries are rounded up to, the block
+// length RFC 8467 recommends for queries.
+const queryPaddingBlock = 128
+
+// applyEDNS adds the client's EDNS0 settings to m, leaving it without an
+// OPT record when none are set. Padding goes last so it covers the rest.
+func (c *Client) applyEDNS(m *dns.Msg) {
+	if c.UDPSize == 0 && !c.DNSSEC && !c.Padding && !c.ClientSubnet.IsValid() {
+		return
+	}
+	size := c.UDPSize
+	if size == 0 {
+		size = DefaultUDPSize
+	}
+	m.SetEdns0(size, c.DNSSEC)
+	if c.ClientSubnet.IsValid() {
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, clientSubnetOption(c.ClientSubnet))
+	}
+	if c.Padding {
+		padQuery(m)
+	}
+}
+
+// padQuery adds an EDNS0 padding option (RFC 7830) that brings m's wire
+// size to a multiple of queryPaddingBlock, replacing any earlier padding.
+// m must already have an OPT record.
+func padQuery(m *dns.Msg) {
+	opt := m.IsEdns0()
+	opt.Option = slices.DeleteFunc(opt.Option, func(o dns.EDNS0) bool {
+		_, ok := o.(*dns.EDNS0_PADDING)
+		return ok
+	})
+	// The option's code and length take 4 bytes of their own
+	n := (queryPaddingBlock - (m.Len()+4)%queryPaddingBlock) % queryPaddingBlock
+	opt.Option = append(opt.Option, &dns.EDNS0_PADDING{Padding: make([]byte, n)})
+}
+
+// isPadded reports whether m carries an EDNS0 padding option.
+func isPadded(m *dns.Msg) bool {
+	opt := m.IsEdns0()
+	return opt != nil && slices.ContainsFunc(opt.Option, func(o dns.EDNS0) bool {
+		_, ok := o.(*dns.EDNS0_PADDING)
+		return ok
+	})
+}
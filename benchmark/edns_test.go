@@ -0,0 +1,103 @@
+package benchmark
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestApplyEDNS(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	(&Client{}).applyEDNS(m)
+	if m.IsEdns0() != nil {
+		t.Error("expected no OPT record without EDNS settings")
+	}
+
+	(&Client{DNSSEC: true}).applyEDNS(m)
+	opt := m.IsEdns0()
+	if opt == nil || !opt.Do() || opt.UDPSize() != DefaultUDPSize {
+		t.Fatalf("expected the DO bit with the default UDP size, got %v", opt)
+	}
+
+	m = new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	(&Client{UDPSize: 4096, Padding: true}).applyEDNS(m)
+	if m.IsEdns0().UDPSize() != 4096 {
+		t.Errorf("expected UDP size 4096, got %d", m.IsEdns0().UDPSize())
+	}
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed)%queryPaddingBlock != 0 {
+		t.Errorf("expected a padded query length, got %d bytes", len(packed))
+	}
+
+	// Asking for keepalive on DoT keeps the padding right
+	requestTCPKeepalive(m)
+	if packed, err = m.Pack(); err != nil || len(packed)%queryPaddingBlock != 0 {
+		t.Errorf("expected a padded query length after adding keepalive, got %d bytes (%v)", len(packed), err)
+	}
+}
+
+func TestParseClientSubnet(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"203.0.113.77/24", "203.0.113.0/24"},
+		{"203.0.113.77", "203.0.113.0/24"},
+		{"2001:db8:1:2::1", "2001:db8:1::/56"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+	} {
+		p, err := ParseClientSubnet(tc.in)
+		if err != nil || p.String() != tc.want {
+			t.Errorf("ParseClientSubnet(%q) = %v, %v, want %s", tc.in, p, err, tc.want)
+		}
+	}
+	if _, err := ParseClientSubnet("example.com/24"); err == nil {
+		t.Error("expected an error for a name instead of an address")
+	}
+}
+
+func TestApplyEDNSClientSubnet(t *testing.T) {
+	p, _ := ParseClientSubnet("198.51.100.0/24")
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	(&Client{ClientSubnet: p, Padding: true}).applyEDNS(m)
+	opts := m.IsEdns0().Option
+	if len(opts) != 2 {
+		t.Fatalf("expected subnet and padding options, got %v", opts)
+	}
+	ecs, ok := opts[0].(*dns.EDNS0_SUBNET)
+	if !ok || ecs.Family != 1 || ecs.SourceNetmask != 24 || ecs.Address.String() != "198.51.100.0" {
+		t.Errorf("expected 198.51.100.0/24, got %v", opts[0])
+	}
+	if _, err := m.Pack(); err != nil {
+		t.Errorf("expected the query to pack, got %v", err)
+	}
+}
+
+func TestReadECSProbe(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetQuestion(ecsProbeName, dns.TypeTXT)
+	for _, s := range []string{
+		`o-o.myaddr.l.google.com. 60 IN TXT "172.253.1.133"`,
+		`o-o.myaddr.l.google.com. 60 IN TXT "edns0-client-subnet 198.51.100.0/24"`,
+	} {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	res := readECSProbe(resp)
+	if res.Err != nil || res.Egress != "172.253.1.133" || res.Subnet != "198.51.100.0/24" {
+		t.Errorf("unexpected probe result %+v", res)
+	}
+
+	resp.Answer = resp.Answer[:1]
+	if res = readECSProbe(resp); res.Subnet != "" {
+		t.Errorf("expected no subnet, got %q", res.Subnet)
+	}
+}
@@ -0,0 +1,209 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eyeballsDelay is how long a Happy Eyeballs client gives IPv6 before it
+// also tries IPv4 (the RFC 8305 Connection Attempt Delay).
+const eyeballsDelay = 250 * time.Millisecond
+
+// FamilyConnect is a connection attempt to one address family of a server.
+type FamilyConnect struct {
+	Addr    netip.Addr
+	Connect time.Duration // TCP connect plus TLS handshake
+	Err     error
+}
+
+// EyeballsResult compares how a dual-stack encrypted server is reached over
+// each address family, with Happy Eyeballs (RFC 8305), and by a client that
+// tries IPv6 first and only falls back to IPv4 once that fails.
+type EyeballsResult struct {
+	Server string
+	Host   string
+	IPv6   FamilyConnect
+	IPv4   FamilyConnect
+	// Raced is the time to a connection with Happy Eyeballs and Winner the
+	// family that provided it ("IPv6" or "IPv4").
+	Raced  time.Duration
+	Winner string
+	// Sequential is the time the IPv6-first client took, ending on
+	// SequentialFamily.
+	Sequential       time.Duration
+	SequentialFamily string
+	// Err is set when neither family could be reached.
+	Err error
+}
+
+// eyeballsPort returns the TCP port an https:// or tls:// server listens on.
+func eyeballsPort(server string) string {
+	if rest, ok := strings.CutPrefix(server, "tls://"); ok {
+		if _, port, err := net.SplitHostPort(withPort(rest, "853")); err == nil {
+			return port
+		}
+		return "853"
+	}
+	if u, err := url.Parse(server); err == nil && u.Port() != "" {
+		return u.Port()
+	}
+	return "443"
+}
+
+// connectTLS opens a TCP connection to addr and completes a TLS handshake
+// with host as the server name, returning how long that took.
+func connectTLS(ctx context.Context, addr netip.Addr, port, host string) (time.Duration, error) {
+	start := time.Now()
+	var d net.Dialer
+	raw, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addr.String(), port))
+	if err != nil {
+		return 0, err
+	}
+	// Certificates aren't checked, for the same reason as in newHTTPClient;
+	// only the handshake time matters here.
+	//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
+	conn := tls.Client(raw, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	defer func() { _ = conn.Close() }() // Only the handshake is measured
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// connectFamily makes one timed connection attempt to addr.
+func connectFamily(addr netip.Addr, port, host string, timeout time.Duration) FamilyConnect {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	d, err := connectTLS(ctx, addr, port, host)
+	return FamilyConnect{Addr: addr, Connect: d, Err: err}
+}
+
+// raceFamilies connects the Happy Eyeballs way: IPv6 first, IPv4 as soon as
+// IPv6 fails or eyeballsDelay passes, and whichever connects first wins.
+func raceFamilies(v6, v4 netip.Addr, port, host string, timeout time.Duration) (time.Duration, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type attempt struct {
+		family string
+		err    error
+	}
+	done := make(chan attempt, 2)
+	start := time.Now()
+	try := func(family string, addr netip.Addr) {
+		_, err := connectTLS(ctx, addr, port, host)
+		done <- attempt{family, err}
+	}
+
+	go try("IPv6", v6)
+	timer := time.NewTimer(eyeballsDelay)
+	defer timer.Stop()
+	started, failed := 1, 0
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			if started == 1 {
+				started++
+				go try("IPv4", v4)
+			}
+		case a := <-done:
+			if a.err == nil {
+				return time.Since(start), a.family, nil
+			}
+			errs = append(errs, a.err)
+			failed++
+			if started == 1 {
+				started++
+				go try("IPv4", v4)
+			} else if failed == started {
+				return time.Since(start), "", errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// HappyEyeballs measures connection establishment to a dual-stack DoH or
+// DoT server. It reports false when the server's hostname doesn't have
+// both A and AAAA records, or the server is not reached over TCP.
+func (c *Client) HappyEyeballs(server string) (EyeballsResult, bool) {
+	res := EyeballsResult{Server: server, Host: BootstrapHost(server)}
+	if res.Host == "" || strings.HasPrefix(server, "h3://") {
+		return res, false
+	}
+	addrs, err := lookupHost(res.Host, c.Bootstrap, c.Timeout)
+	if err != nil {
+		return res, false
+	}
+	for _, a := range addrs {
+		if a.Is4() && !res.IPv4.Addr.IsValid() {
+			res.IPv4.Addr = a
+		}
+		if a.Is6() && !res.IPv6.Addr.IsValid() {
+			res.IPv6.Addr = a
+		}
+	}
+	if !res.IPv4.Addr.IsValid() || !res.IPv6.Addr.IsValid() {
+		return res, false
+	}
+
+	port := eyeballsPort(server)
+	res.IPv6 = connectFamily(res.IPv6.Addr, port, res.Host, c.Timeout)
+	res.IPv4 = connectFamily(res.IPv4.Addr, port, res.Host, c.Timeout)
+	res.Raced, res.Winner, res.Err = raceFamilies(res.IPv6.Addr, res.IPv4.Addr, port, res.Host, c.Timeout)
+
+	start := time.Now()
+	seq := connectFamily(res.IPv6.Addr, port, res.Host, c.Timeout)
+	res.SequentialFamily = "IPv6"
+	if seq.Err != nil {
+		seq = connectFamily(res.IPv4.Addr, port, res.Host, c.Timeout)
+		res.SequentialFamily = "IPv4"
+		if seq.Err != nil {
+			res.SequentialFamily = ""
+		}
+	}
+	res.Sequential = time.Since(start)
+	return res, true
+}
+
+// RunHappyEyeballs runs HappyEyeballs against every dual-stack DoH and DoT
+// server in config, in parallel. Hostnames are resolved through
+// config.Bootstrap when it is set. Nothing is measured through a proxy,
+// which picks the address family itself.
+func RunHappyEyeballs(config Config) []EyeballsResult {
+	if config.Proxy != nil {
+		return nil
+	}
+	client := &Client{Timeout: config.Timeout, Bootstrap: config.Bootstrap}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	found := make(map[string]EyeballsResult)
+	for _, server := range config.Servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res, ok := client.HappyEyeballs(server); ok {
+				mu.Lock()
+				found[server] = res
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var results []EyeballsResult
+	for _, server := range config.Servers {
+		if res, ok := found[server]; ok {
+			results = append(results, res)
+		}
+	}
+	return results
+}
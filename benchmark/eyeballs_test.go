@@ -0,0 +1,111 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startTLSListener accepts TLS connections on network/addr and completes
+// the handshake, returning the bound port.
+func startTLSListener(t *testing.T, network, addr string) (int, error) {
+	t.Helper()
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), network, addr)
+	if err != nil {
+		return 0, err
+	}
+	tlsLn := tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}})
+	go func() {
+		for {
+			conn, err := tlsLn.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}()
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// dualStackResolver answers A with 127.0.0.1 and AAAA with ::1 for every name.
+func dualStackResolver(t *testing.T) string {
+	return startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		hdr := dns.RR_Header{Name: r.Question[0].Name, Rrtype: r.Question[0].Qtype, Class: dns.ClassINET, Ttl: 60}
+		switch r.Question[0].Qtype {
+		case dns.TypeA:
+			m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: net.IPv4(127, 0, 0, 1)})
+		case dns.TypeAAAA:
+			m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.IPv6loopback})
+		}
+		_ = w.WriteMsg(m)
+	})
+}
+
+func TestHappyEyeballsPrefersWorkingIPv6(t *testing.T) {
+	port, err := startTLSListener(t, "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	if _, err := startTLSListener(t, "tcp6", "[::1]:"+strconv.Itoa(port)); err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+
+	client := &Client{Timeout: 2 * time.Second, Bootstrap: dualStackResolver(t)}
+	res, ok := client.HappyEyeballs("tls://dual.example.test:" + strconv.Itoa(port))
+	if !ok {
+		t.Fatal("expected a dual-stack server to be measured")
+	}
+	if res.IPv6.Err != nil || res.IPv4.Err != nil || res.Err != nil {
+		t.Fatalf("expected both families to connect, got %+v", res)
+	}
+	if res.Winner != "IPv6" || res.SequentialFamily != "IPv6" {
+		t.Errorf("expected IPv6 to win with a working IPv6 path, got %q / %q", res.Winner, res.SequentialFamily)
+	}
+}
+
+func TestHappyEyeballsFallsBackFromBrokenIPv6(t *testing.T) {
+	// Only IPv4 listens, so connecting to ::1 on this port is refused
+	port, err := startTLSListener(t, "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	client := &Client{Timeout: 2 * time.Second, Bootstrap: dualStackResolver(t)}
+	res, ok := client.HappyEyeballs("https://dual.example.test:" + strconv.Itoa(port) + "/dns-query")
+	if !ok {
+		t.Fatal("expected a dual-stack server to be measured")
+	}
+	if res.IPv6.Err == nil || res.IPv4.Err != nil {
+		t.Fatalf("expected only IPv4 to connect, got IPv6 err %v, IPv4 err %v", res.IPv6.Err, res.IPv4.Err)
+	}
+	if res.Winner != "IPv4" || res.SequentialFamily != "IPv4" {
+		t.Errorf("expected both strategies to end on IPv4, got %q / %q", res.Winner, res.SequentialFamily)
+	}
+}
+
+func TestHappyEyeballsSkipsSingleStack(t *testing.T) {
+	mock, err := StartMockServer() // Answers A only
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	client := &Client{Timeout: time.Second, Bootstrap: mock.Addr}
+	for _, server := range []string{"https://v4only.example.test/dns-query", "h3://dual.example.test/dns-query", "https://1.1.1.1/dns-query", "8.8.8.8"} {
+		if _, ok := client.HappyEyeballs(server); ok {
+			t.Errorf("expected %s to be skipped", server)
+		}
+	}
+}
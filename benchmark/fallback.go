@@ -0,0 +1,49 @@
+package benchmark
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Fallback modes for plain DNS servers, emulating how a real stub resolver
+// recovers when a UDP query does not produce a usable answer.
+const (
+	// FallbackTCP retries over TCP when the UDP answer is truncated or the
+	// query fails.
+	FallbackTCP = "tcp"
+	// FallbackDoT additionally escalates to DoT on port 853 of the same host
+	// when TCP fails as well.
+	FallbackDoT = "dot"
+)
+
+// measureFallback queries host over UDP and walks the fallback chain until a
+// transport produces a complete answer. It returns the transport that
+// answered (or was tried last) and how many fallbacks were needed.
+func (c *Client) measureFallback(host string, m *dns.Msg) (resp *dns.Msg, transport string, fallbacks int, err error) {
+	client := &dns.Client{Net: "udp", Timeout: c.Timeout}
+	if err = c.bindDNS(client, host); err != nil {
+		return nil, "udp", 0, err
+	}
+	resp, _, err = client.Exchange(m, host)
+	if err == nil && !resp.Truncated {
+		return resp, "udp", 0, nil
+	}
+
+	client.Net = "tcp"
+	if err = c.bindDNS(client, host); err != nil {
+		return nil, "tcp", 1, err
+	}
+	resp, _, err = client.Exchange(m, host)
+	if err == nil || c.Fallback != FallbackDoT {
+		return resp, "tcp", 1, err
+	}
+
+	hostname, _, splitErr := net.SplitHostPort(host)
+	if splitErr != nil {
+		return resp, "tcp", 1, err
+	}
+	requestTCPKeepalive(m)
+	resp, _, err = c.measureDoT("tls://"+net.JoinHostPort(hostname, "853"), m)
+	return resp, "tls", 2, err
+}
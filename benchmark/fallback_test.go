@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startTruncatingServer serves DNS on UDP and TCP at the same loopback port.
+// UDP answers are always truncated, so a stub has to retry over TCP.
+func startTruncatingServer(t *testing.T) string {
+	t.Helper()
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on tcp: %v", err)
+	}
+	pc, err := lc.ListenPacket(context.Background(), "udp", ln.Addr().String())
+	if err != nil {
+		_ = ln.Close()
+		t.Skipf("could not bind udp on the same port: %v", err)
+	}
+
+	udp := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		_ = w.WriteMsg(m)
+	})}
+	tcp := &dns.Server{Listener: ln, Handler: dns.HandlerFunc(mockAnswer)}
+	go func() { _ = udp.ActivateAndServe() }()
+	go func() { _ = tcp.ActivateAndServe() }()
+	t.Cleanup(func() {
+		_ = udp.Shutdown()
+		_ = tcp.Shutdown()
+	})
+	return ln.Addr().String()
+}
+
+func TestMeasureFallsBackToTCPOnTruncation(t *testing.T) {
+	server := startTruncatingServer(t)
+	client := Client{Timeout: time.Second, Fallback: FallbackTCP}
+
+	res := client.Measure(server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if res.Transport != "tcp" || res.Fallbacks != 1 {
+		t.Errorf("expected one fallback to tcp, got %q after %d", res.Transport, res.Fallbacks)
+	}
+}
+
+func TestMeasureWithoutFallbackStaysOnUDP(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mock.Close()
+
+	client := Client{Timeout: time.Second, Fallback: FallbackDoT}
+	res := client.Measure(mock.Addr, "example.com")
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if res.Transport != "udp" || res.Fallbacks != 0 {
+		t.Errorf("expected a direct udp answer, got %q after %d fallbacks", res.Transport, res.Fallbacks)
+	}
+}
+
+func TestMeasureFallbackReportsLastTransportOnFailure(t *testing.T) {
+	// Nothing listens on the mock's port once it is closed, so every
+	// transport fails and the chain runs to the end.
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	addr := mock.Addr
+	_ = mock.Close()
+
+	client := Client{Timeout: 200 * time.Millisecond, Fallback: FallbackTCP}
+	res := client.Measure(addr, "example.com")
+	if res.Error == nil {
+		t.Fatal("expected an error with no server listening")
+	}
+	if res.Transport != "tcp" || res.Fallbacks != 1 {
+		t.Errorf("expected the chain to end on tcp, got %q after %d", res.Transport, res.Fallbacks)
+	}
+}
@@ -0,0 +1,36 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+)
+
+// warmCaches asks every question once on every server before a HitRatio
+// run, so the run's hits find their names in the server's cache. It uses
+// the run's workers, clients and rate limits; the answers are discarded.
+func warmCaches(config Config, questions []Question, clientFor func(server string) (*Client, Config), limits *rateLimits) {
+	jobs := make(chan Job)
+	var wg sync.WaitGroup
+	for range max(config.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				limits.wait(job.Server)
+				c, jobConfig := clientFor(job.Server)
+				target := job.Server
+				if via, ok := jobConfig.Via[job.Server]; ok {
+					target = via
+				}
+				c.attempt(jobConfig, target, job)
+			}
+		}()
+	}
+	for _, server := range config.Servers {
+		for i := range questions {
+			jobs <- Job{Server: server, Domain: questions[i].Name, question: &questions[i], ctx: context.Background()}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+package benchmark
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRunHitRatio(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		names = append(names, r.Question[0].Name)
+		mu.Unlock()
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	})
+
+	results := Run(Config{
+		Servers:     []string{server},
+		Domains:     []string{"example.com", "example.org"},
+		Iterations:  50,
+		Concurrency: 4,
+		Timeout:     time.Second,
+		HitRatio:    0.5,
+	})
+	if len(results) != 100 {
+		t.Fatalf("expected 100 results without the warm-up queries, got %d", len(results))
+	}
+
+	// The warm-up asks both names before any of the run's queries
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) != 102 || names[0] == names[1] || names[0][0] != 'e' || names[1][0] != 'e' {
+		t.Errorf("expected both names to be warmed up first, got %d queries starting %v", len(names), names[:2])
+	}
+
+	var hits, misses int
+	for _, res := range results {
+		switch res.CachePass {
+		case CacheWarm:
+			hits++
+			if res.QueryName != "" {
+				t.Errorf("expected a hit to query the name itself, got %s", res.QueryName)
+			}
+		case CacheCold:
+			misses++
+			if res.QueryName == "" || dns.CountLabel(res.QueryName) != 3 {
+				t.Errorf("expected a miss to query a random label, got %q", res.QueryName)
+			}
+		default:
+			t.Errorf("expected every result to be a hit or a miss, got %q", res.CachePass)
+		}
+	}
+	// Half of 100 queries, with a lot of room for chance
+	if hits < 25 || misses < 25 {
+		t.Errorf("expected a mix of hits and misses, got %d and %d", hits, misses)
+	}
+}
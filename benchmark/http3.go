@@ -0,0 +1,28 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// errH3Proxy is returned for h3:// servers when a proxy is configured:
+// HTTP/3 runs over QUIC (UDP), which a SOCKS5 CONNECT tunnel can't carry.
+var errH3Proxy = errors.New("HTTP/3 can't be used through a SOCKS5 proxy")
+
+// newHTTP3Client builds a DoH client that speaks HTTP/3 over QUIC.
+func newHTTP3Client(timeout time.Duration) *http.Client {
+	// InsecureSkipVerify for the same reason as newHTTPClient: servers may be
+	// benchmarked by IP address.
+	//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
+	t := &http3.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: t,
+	}
+}
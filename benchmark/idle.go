@@ -0,0 +1,132 @@
+package benchmark
+
+import (
+	"context"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIdlePeriods are the idle periods RunIdleProbe waits by default: a
+// pause between page loads, a minute away and a laptop waking from sleep.
+var DefaultIdlePeriods = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// Values of IdleSample.Conn.
+const (
+	IdleConnReused = "reused"
+	IdleConnNew    = "new"
+)
+
+// IdleSample is the query sent after a connection to a server sat idle for
+// Idle, measured against a query sent just before on the same connection.
+type IdleSample struct {
+	Idle time.Duration
+	// Warm is the query on the freshly used connection and Duration the
+	// first one after the idle period; Penalty is the difference.
+	Warm     time.Duration
+	Duration time.Duration
+	Penalty  time.Duration
+	// Conn says whether the query after the idle period found the
+	// connection still open (IdleConnReused) or had to open a new one
+	// (IdleConnNew); empty for h3:// servers, whose connections aren't
+	// traced.
+	Conn string
+	Err  error
+}
+
+// IdleResult is the idle probe of one DoT or DoH server.
+type IdleResult struct {
+	Server  string
+	Samples []IdleSample
+}
+
+// probeIdle opens a connection to server with a query for domain, measures
+// a second query on it, leaves it idle and measures the first query after.
+// The client keeps the connection open for as long as the server and the
+// network let it, so any penalty is what closing it on their side costs.
+func probeIdle(config Config, server, domain string, idle time.Duration) IdleSample {
+	config.Servers = []string{server}
+	config.ConnsPerServer = 1
+	config.NoKeepalive = false
+	client := newRunClient(config)
+	sample := IdleSample{Idle: idle}
+	if res := client.Measure(server, domain); res.Error != nil {
+		sample.Err = res.Error
+		return sample
+	}
+	warm := client.Measure(server, domain)
+	if warm.Error != nil {
+		sample.Err = warm.Error
+		return sample
+	}
+	sample.Warm = warm.Duration
+
+	time.Sleep(idle)
+
+	ctx := context.Background()
+	var dials int64
+	switch {
+	case strings.HasPrefix(server, "tls://"):
+		dials = client.dotPool(server).dials.Load()
+	case strings.HasPrefix(server, "https://"):
+		sample.Conn = IdleConnNew
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					sample.Conn = IdleConnReused
+				}
+			},
+		})
+	}
+	res := client.MeasureContext(ctx, server, domain)
+	if strings.HasPrefix(server, "tls://") {
+		sample.Conn = IdleConnReused
+		if client.dotPool(server).dials.Load() > dials {
+			sample.Conn = IdleConnNew
+		}
+	}
+	sample.Duration, sample.Err = res.Duration, res.Error
+	if res.Error == nil {
+		sample.Penalty = res.Duration - sample.Warm
+	}
+	return sample
+}
+
+// RunIdleProbe measures the first-query-after-idle latency of every DoT and
+// DoH server in config, for each of the idle periods, querying the first of
+// config.Domains so answers come from the server's cache. Every server and
+// period gets its own connection and all of them wait at once, so the probe
+// takes about as long as the longest period.
+func RunIdleProbe(config Config, idles []time.Duration) []IdleResult {
+	if len(config.Domains) == 0 || len(idles) == 0 {
+		return nil
+	}
+	domain := config.Domains[0]
+
+	var servers []string
+	for _, s := range config.Servers {
+		if strings.HasPrefix(s, "tls://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "h3://") {
+			servers = append(servers, s)
+		}
+	}
+
+	results := make([]IdleResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		results[i] = IdleResult{Server: server, Samples: make([]IdleSample, len(idles))}
+		for j, idle := range idles {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				target := server
+				if via, ok := config.Via[server]; ok {
+					target = via
+				}
+				results[i].Samples[j] = probeIdle(config, target, domain, idle)
+			}()
+		}
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,71 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startIdleDoTServer runs a DoT server that closes connections once they
+// have been idle for idleTimeout.
+func startIdleDoTServer(t *testing.T, idleTimeout time.Duration) (string, *countingListener) {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingListener{Listener: ln}
+	server := &dns.Server{
+		Listener:    counting,
+		Net:         "tcp-tls",
+		IdleTimeout: func() time.Duration { return idleTimeout },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return "tls://" + ln.Addr().String(), counting
+}
+
+func TestRunIdleProbe(t *testing.T) {
+	dot, ln := startIdleDoTServer(t, 150*time.Millisecond)
+	doh := startDoHServer(t, 0)
+	config := Config{
+		Servers: []string{"127.0.0.1:1", dot, doh},
+		Domains: []string{"example.com"},
+		Timeout: 2 * time.Second,
+	}
+
+	results := RunIdleProbe(config, []time.Duration{10 * time.Millisecond, 400 * time.Millisecond})
+	if len(results) != 2 || results[0].Server != dot || results[1].Server != doh {
+		t.Fatalf("expected the DoT and DoH servers only, got %+v", results)
+	}
+	short, long := results[0].Samples[0], results[0].Samples[1]
+	if short.Err != nil || long.Err != nil {
+		t.Fatalf("DoT probe failed: %v, %v", short.Err, long.Err)
+	}
+	if short.Conn != IdleConnReused {
+		t.Errorf("expected the connection to survive 10ms idle, got %q", short.Conn)
+	}
+	if long.Conn != IdleConnNew {
+		t.Errorf("expected a new connection after the server's 150ms idle timeout, got %q", long.Conn)
+	}
+	if long.Penalty != long.Duration-long.Warm {
+		t.Errorf("expected the penalty to be the difference from the warm query, got %+v", long)
+	}
+	// One connection per period, plus the redial after the long one
+	if got := ln.accepted.Load(); got != 3 {
+		t.Errorf("expected 3 connections, got %d", got)
+	}
+
+	for _, s := range results[1].Samples {
+		if s.Err != nil || s.Conn != IdleConnReused {
+			t.Errorf("expected the DoH connection to be reused after %v, got %+v", s.Idle, s)
+		}
+	}
+}
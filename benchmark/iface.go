@@ -0,0 +1,263 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ifaceBinding is what binding sockets to Client.Interface or
+// Client.SourceIPs needs, looked up once per client. name describes the
+// binding in errors.
+type ifaceBinding struct {
+	name    string
+	iface   *net.Interface
+	v4, v6  netip.Addr
+	control func(network, address string, rc syscall.RawConn) error
+	err     error
+}
+
+// InterfaceAddrs returns the first global unicast IPv4 and IPv6 address of
+// the named interface; either is invalid when the interface has none.
+func InterfaceAddrs(name string) (v4, v6 netip.Addr, err error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return v4, v6, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return v4, v6, err
+	}
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil || !prefix.Addr().IsGlobalUnicast() {
+			continue
+		}
+		addr := prefix.Addr().Unmap()
+		if addr.Is4() && !v4.IsValid() {
+			v4 = addr
+		}
+		if addr.Is6() && !v6.IsValid() {
+			v6 = addr
+		}
+	}
+	if !v4.IsValid() && !v6.IsValid() {
+		return v4, v6, fmt.Errorf("interface %s has no usable address", name)
+	}
+	return v4, v6, nil
+}
+
+// bound reports whether the client sends from a chosen interface or
+// address rather than wherever the routing table says.
+func (c *Client) bound() bool {
+	return c.Interface != "" || len(c.SourceIPs) > 0
+}
+
+// binding returns the client's interface binding, looking it up on first
+// use. Source addresses replace the interface's own.
+func (c *Client) binding() *ifaceBinding {
+	c.ifaceOnce.Do(func() {
+		b := &ifaceBinding{name: "interface " + c.Interface}
+		if c.Interface != "" {
+			b.iface, b.err = net.InterfaceByName(c.Interface)
+			if b.err == nil && len(c.SourceIPs) == 0 {
+				b.v4, b.v6, b.err = InterfaceAddrs(c.Interface)
+			}
+			if b.err == nil {
+				b.control = bindToInterface(b.iface)
+			}
+		}
+		if len(c.SourceIPs) > 0 {
+			var addrs []string
+			for _, ip := range c.SourceIPs {
+				if ip = ip.Unmap(); ip.Is4() {
+					b.v4 = ip
+				} else {
+					b.v6 = ip
+				}
+				addrs = append(addrs, ip.String())
+			}
+			if c.Interface == "" {
+				b.name = "source address " + strings.Join(addrs, ", ")
+			}
+		}
+		c.iface = b
+	})
+	return c.iface
+}
+
+// localAddr picks the interface address of the family addr (host:port)
+// will be reached over. Hostnames are assumed to be reachable over IPv4
+// when the interface has an IPv4 address.
+func (b *ifaceBinding) localAddr(addr string) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	want6 := !b.v4.IsValid()
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		want6 = ip.Unmap().Is6()
+	}
+	if want6 {
+		if !b.v6.IsValid() {
+			return netip.Addr{}, fmt.Errorf("%s has no IPv6 address for %s", b.name, addr)
+		}
+		return b.v6, nil
+	}
+	if !b.v4.IsValid() {
+		return netip.Addr{}, fmt.Errorf("%s has no IPv4 address for %s", b.name, addr)
+	}
+	return b.v4, nil
+}
+
+// dialer returns a net.Dialer for network ("udp" or "tcp") that sends from
+// the client's interface or source address, or a plain one when neither is
+// set.
+func (c *Client) dialer(network, addr string) (*net.Dialer, error) {
+	d := &net.Dialer{Timeout: c.Timeout}
+	if !c.bound() {
+		return d, nil
+	}
+	b := c.binding()
+	if b.err != nil {
+		return nil, b.err
+	}
+	local, err := b.localAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(network, "udp") {
+		d.LocalAddr = net.UDPAddrFromAddrPort(netip.AddrPortFrom(local, 0))
+	} else {
+		d.LocalAddr = net.TCPAddrFromAddrPort(netip.AddrPortFrom(local, 0))
+	}
+	d.Control = b.control
+	return d, nil
+}
+
+// bindDNS points client's dialer at the client's interface for a query to
+// addr. It must be called again whenever client.Net changes.
+func (c *Client) bindDNS(client *dns.Client, addr string) error {
+	if !c.bound() {
+		return nil
+	}
+	network := "udp"
+	if client.Net != "" && client.Net != "udp" {
+		network = "tcp"
+	}
+	d, err := c.dialer(network, addr)
+	if err != nil {
+		return err
+	}
+	client.Dialer = d
+	return nil
+}
+
+// dialContext dials addr from the client's interface.
+func (c *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d, err := c.dialer(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// dialQUIC is the Dial of HTTP/3 transports bound to an interface: every
+// connection shares one UDP socket per address family on the interface.
+func (c *Client) dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	var targets []string
+	if c.Bootstrap != "" {
+		var err error
+		if targets, err = c.bootstrapTargets(addr); err != nil {
+			return nil, err
+		}
+	} else {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			targets = append(targets, net.JoinHostPort(a.Unmap().String(), port))
+		}
+	}
+
+	var errs []error
+	for _, target := range targets {
+		udpAddr, err := net.ResolveUDPAddr("udp", target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tr, err := c.quicTransport(target)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn, err := tr.DialEarly(ctx, udpAddr, tlsCfg, cfg)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// quicTransport returns the QUIC transport for the address family of
+// target, opening its socket on the client's interface on first use.
+func (c *Client) quicTransport(target string) (*quic.Transport, error) {
+	b := c.binding()
+	if b.err != nil {
+		return nil, b.err
+	}
+	local, err := b.localAddr(target)
+	if err != nil {
+		return nil, err
+	}
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	if tr, ok := c.quicTransports[local]; ok {
+		return tr, nil
+	}
+	lc := net.ListenConfig{Control: b.control}
+	pc, err := lc.ListenPacket(context.Background(), "udp", netip.AddrPortFrom(local, 0).String())
+	if err != nil {
+		return nil, err
+	}
+	tr := &quic.Transport{Conn: pc}
+	if c.quicTransports == nil {
+		c.quicTransports = make(map[netip.Addr]*quic.Transport)
+	}
+	c.quicTransports[local] = tr
+	return tr, nil
+}
+
+// useInterface makes a DoH client's transport send from the client's
+// interface or source address. Through a proxy, the proxy decides the route.
+func (c *Client) useInterface(client *http.Client) *http.Client {
+	if !c.bound() || c.Proxy != nil {
+		return client
+	}
+	switch t := client.Transport.(type) {
+	case *http.Transport:
+		if c.Bootstrap == "" {
+			t.DialContext = c.dialContext
+		}
+	case *http3.Transport:
+		t.Dial = c.dialQUIC
+	}
+	return client
+}
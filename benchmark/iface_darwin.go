@@ -0,0 +1,31 @@
+//go:build darwin
+
+package benchmark
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// bindToInterface scopes sockets to iface with IP_BOUND_IF (IPV6_BOUND_IF
+// for IPv6), so queries leave through it whatever the routing table says.
+func bindToInterface(iface *net.Interface) func(network, address string, rc syscall.RawConn) error {
+	return func(network, _ string, rc syscall.RawConn) error {
+		level, opt := syscall.IPPROTO_IP, syscall.IP_BOUND_IF
+		if strings.HasSuffix(network, "6") {
+			level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_BOUND_IF
+		}
+		var opErr error
+		if err := rc.Control(func(fd uintptr) {
+			opErr = syscall.SetsockoptInt(int(fd), level, opt, iface.Index)
+		}); err != nil {
+			return err
+		}
+		if opErr != nil {
+			return fmt.Errorf("binding to %s: %w", iface.Name, opErr)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package benchmark
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// bindToInterface binds sockets to iface with SO_BINDTODEVICE, so queries
+// leave through it whatever the routing table says. The kernel requires
+// CAP_NET_RAW for this.
+func bindToInterface(iface *net.Interface) func(network, address string, rc syscall.RawConn) error {
+	return func(_, _ string, rc syscall.RawConn) error {
+		var opErr error
+		if err := rc.Control(func(fd uintptr) {
+			opErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface.Name)
+		}); err != nil {
+			return err
+		}
+		if opErr != nil {
+			return fmt.Errorf("binding to %s (needs root or CAP_NET_RAW): %w", iface.Name, opErr)
+		}
+		return nil
+	}
+}
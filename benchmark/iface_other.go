@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package benchmark
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToInterface has no socket option to use on this platform; sockets are
+// only bound to the interface's address, which most hosts route out of that
+// interface.
+func bindToInterface(_ *net.Interface) func(network, address string, rc syscall.RawConn) error {
+	return nil
+}
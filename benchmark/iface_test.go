@@ -0,0 +1,86 @@
+package benchmark
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loopbackBinding makes c send from 127.0.0.1 as if that were an
+// interface's address, without the socket option that needs privileges.
+func loopbackBinding(c *Client) {
+	c.Interface = "test0"
+	c.ifaceOnce.Do(func() {
+		c.iface = &ifaceBinding{name: "interface test0", iface: &net.Interface{Name: "test0"}, v4: netip.MustParseAddr("127.0.0.1")}
+	})
+}
+
+func TestClientInterfaceBinding(t *testing.T) {
+	server := startTruncatingServer(t)
+	client := &Client{Timeout: time.Second, Fallback: FallbackTCP}
+	loopbackBinding(client)
+
+	for _, addr := range []string{server, "tcp://" + server} {
+		if res := client.Measure(addr, "example.com"); res.Error != nil {
+			t.Errorf("query to %s from the bound address failed: %v", addr, res.Error)
+		}
+	}
+
+	// The interface has no IPv6 address to reach an IPv6 server from
+	res := client.Measure("[::1]:53", "example.com")
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "no IPv6 address") {
+		t.Errorf("expected a missing IPv6 address error, got %v", res.Error)
+	}
+}
+
+func TestClientSourceIP(t *testing.T) {
+	server := startTruncatingServer(t)
+	client := &Client{Timeout: time.Second, Fallback: FallbackTCP, SourceIPs: []netip.Addr{netip.MustParseAddr("127.0.0.1")}}
+	for _, addr := range []string{server, "tcp://" + server} {
+		if res := client.Measure(addr, "example.com"); res.Error != nil {
+			t.Errorf("query to %s from the source address failed: %v", addr, res.Error)
+		}
+	}
+
+	// Only an IPv6 source address was given for an IPv4 server
+	client = &Client{Timeout: time.Second, SourceIPs: []netip.Addr{netip.MustParseAddr("::1")}}
+	res := client.Measure(server, "example.com")
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "source address ::1 has no IPv4 address") {
+		t.Errorf("expected a missing IPv4 address error, got %v", res.Error)
+	}
+}
+
+func TestClientUnknownInterface(t *testing.T) {
+	client := &Client{Timeout: time.Second, Interface: "nonexistent0"}
+	if res := client.Measure("127.0.0.1:53", "example.com"); res.Error == nil {
+		t.Error("expected an error for an unknown interface")
+	}
+	if _, _, err := InterfaceAddrs("nonexistent0"); err == nil {
+		t.Error("expected InterfaceAddrs to fail for an unknown interface")
+	}
+}
+
+func TestBindingLocalAddr(t *testing.T) {
+	b := &ifaceBinding{
+		name:  "interface wlan0",
+		iface: &net.Interface{Name: "wlan0"},
+		v4:    netip.MustParseAddr("192.0.2.10"),
+		v6:    netip.MustParseAddr("2001:db8::10"),
+	}
+	for addr, want := range map[string]string{
+		"8.8.8.8:53":              "192.0.2.10",
+		"[2001:4860::8888]:53":    "2001:db8::10",
+		"dns.google:853":          "192.0.2.10",
+		"[::ffff:1.1.1.1]:53":     "192.0.2.10",
+		"cloudflare-dns.com":      "192.0.2.10",
+		"[2606:4700::1111]:443":   "2001:db8::10",
+		"[2606:4700::1111%1]:443": "2001:db8::10",
+	} {
+		got, err := b.localAddr(addr)
+		if err != nil || got.String() != want {
+			t.Errorf("localAddr(%s) = %v, %v; want %s", addr, got, err, want)
+		}
+	}
+}
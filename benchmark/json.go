@@ -0,0 +1,186 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// resultJSON is the wire form of a Result used by the JSON exports.
+// Durations are milliseconds (TTLs whole seconds, as on the wire) and the
+// error is flattened to its message.
+type resultJSON struct {
+	Server             string    `json:"server"`
+	Domain             string    `json:"domain"`
+	DurationMs         float64   `json:"duration_ms"`
+	Error              string    `json:"error,omitempty"`
+	Network            string    `json:"network,omitempty"`
+	KeepaliveTimeoutMs *float64  `json:"keepalive_timeout_ms,omitempty"`
+	CNAMEDepth         int       `json:"cname_depth,omitempty"`
+	ChainUnfollowed    bool      `json:"chain_unfollowed,omitempty"`
+	TTFBMs             float64   `json:"ttfb_ms,omitempty"`
+	Transport          string    `json:"transport,omitempty"`
+	Fallbacks          int       `json:"fallbacks,omitempty"`
+	SVCB               *svcbJSON `json:"svcb,omitempty"`
+	SearchQueries      int       `json:"search_queries,omitempty"`
+	SearchOverheadMs   float64   `json:"search_overhead_ms,omitempty"`
+	QueryName          string    `json:"query_name,omitempty"`
+	HTTPVersion        string    `json:"http_version,omitempty"`
+	Protocol           string    `json:"protocol,omitempty"`
+	Answers            int       `json:"answers,omitempty"`
+	Authority          int       `json:"authority,omitempty"`
+	Additional         int       `json:"additional,omitempty"`
+	EmptyAnswer        bool      `json:"empty_answer,omitempty"`
+	Rcode              string    `json:"rcode,omitempty"`
+	TTLSeconds         int64     `json:"ttl_s,omitempty"`
+	CachePass          string    `json:"cache_pass,omitempty"`
+	Truncated          bool      `json:"truncated,omitempty"`
+	Retries            int       `json:"retries,omitempty"`
+	QType              string    `json:"qtype,omitempty"`
+	Checked            bool      `json:"checked,omitempty"`
+	Mismatch           string    `json:"mismatch,omitempty"`
+	MismatchKind       string    `json:"mismatch_kind,omitempty"`
+	Category           string    `json:"category,omitempty"`
+	RunID              string    `json:"run_id,omitempty"`
+	Time               string    `json:"time,omitempty"`
+	OffsetMs           float64   `json:"offset_ms,omitempty"`
+	Pair               int       `json:"pair,omitempty"`
+	ConnectMs          float64   `json:"connect_ms,omitempty"`
+	Resumed            bool      `json:"resumed,omitempty"`
+}
+
+// svcbJSON is the wire form of SVCBInfo.
+type svcbJSON struct {
+	Records   int      `json:"records"`
+	AliasMode bool     `json:"alias_mode,omitempty"`
+	ALPN      []string `json:"alpn,omitempty"`
+	ECH       bool     `json:"ech,omitempty"`
+	IPHints   bool     `json:"ip_hints,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Result) MarshalJSON() ([]byte, error) {
+	out := resultJSON{
+		Server:           r.Server,
+		Domain:           r.Domain,
+		DurationMs:       durationMs(r.Duration),
+		Network:          r.Network,
+		CNAMEDepth:       r.CNAMEDepth,
+		ChainUnfollowed:  r.ChainUnfollowed,
+		TTFBMs:           durationMs(r.TTFB),
+		Transport:        r.Transport,
+		Fallbacks:        r.Fallbacks,
+		SearchQueries:    r.SearchQueries,
+		SearchOverheadMs: durationMs(r.SearchOverhead),
+		QueryName:        r.QueryName,
+		HTTPVersion:      r.HTTPVersion,
+		Protocol:         r.Protocol,
+		Answers:          r.Answers,
+		Authority:        r.Authority,
+		Additional:       r.Additional,
+		EmptyAnswer:      r.EmptyAnswer,
+		Rcode:            r.Rcode,
+		TTLSeconds:       int64(r.TTL / time.Second),
+		CachePass:        r.CachePass,
+		Truncated:        r.Truncated,
+		Retries:          r.Retries,
+		QType:            r.QType,
+		Checked:          r.Checked,
+		Mismatch:         r.Mismatch,
+		MismatchKind:     r.MismatchKind,
+		Category:         r.Category,
+		RunID:            r.RunID,
+		OffsetMs:         durationMs(r.Offset),
+		Pair:             r.Pair,
+		ConnectMs:        durationMs(r.Connect),
+		Resumed:          r.Resumed,
+	}
+	if !r.Time.IsZero() {
+		out.Time = r.Time.Format(time.RFC3339Nano)
+	}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	if r.KeepaliveAdvertised {
+		ka := durationMs(r.KeepaliveTimeout)
+		out.KeepaliveTimeoutMs = &ka
+	}
+	if r.SVCB != nil {
+		out.SVCB = (*svcbJSON)(r.SVCB)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler so exported results can be read back.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var in resultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = Result{
+		Server:          in.Server,
+		Domain:          in.Domain,
+		Duration:        msDuration(in.DurationMs),
+		Network:         in.Network,
+		CNAMEDepth:      in.CNAMEDepth,
+		ChainUnfollowed: in.ChainUnfollowed,
+		TTFB:            msDuration(in.TTFBMs),
+		Transport:       in.Transport,
+		Fallbacks:       in.Fallbacks,
+		SearchQueries:   in.SearchQueries,
+		SearchOverhead:  msDuration(in.SearchOverheadMs),
+		QueryName:       in.QueryName,
+		HTTPVersion:     in.HTTPVersion,
+		Protocol:        in.Protocol,
+		Answers:         in.Answers,
+		Authority:       in.Authority,
+		Additional:      in.Additional,
+		EmptyAnswer:     in.EmptyAnswer,
+		Rcode:           in.Rcode,
+		TTL:             time.Duration(in.TTLSeconds) * time.Second,
+		CachePass:       in.CachePass,
+		Truncated:       in.Truncated,
+		Retries:         in.Retries,
+		QType:           in.QType,
+		Checked:         in.Checked,
+		Mismatch:        in.Mismatch,
+		MismatchKind:    in.MismatchKind,
+		Category:        in.Category,
+		RunID:           in.RunID,
+		Offset:          msDuration(in.OffsetMs),
+		Pair:            in.Pair,
+		Connect:         msDuration(in.ConnectMs),
+		Resumed:         in.Resumed,
+	}
+	if in.Time != "" {
+		t, err := time.Parse(time.RFC3339Nano, in.Time)
+		if err != nil {
+			return fmt.Errorf("invalid time %q: %w", in.Time, err)
+		}
+		r.Time = t
+	}
+	if in.Error != "" {
+		r.Error = errors.New(in.Error)
+	}
+	if r.Category == "" {
+		// Exports from before categories were recorded
+		r.Category = Classify(*r)
+	}
+	if in.KeepaliveTimeoutMs != nil {
+		r.KeepaliveAdvertised = true
+		r.KeepaliveTimeout = msDuration(*in.KeepaliveTimeoutMs)
+	}
+	if in.SVCB != nil {
+		r.SVCB = (*SVCBInfo)(in.SVCB)
+	}
+	return nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+func msDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultJSONRoundTrip(t *testing.T) {
+	in := Result{
+		Server:              "tls://1.1.1.1",
+		Domain:              "example.com",
+		Duration:            12500 * time.Microsecond,
+		Error:               errors.New("i/o timeout"),
+		Network:             "eth0",
+		KeepaliveAdvertised: true,
+		KeepaliveTimeout:    10 * time.Second,
+		Rcode:               "SERVFAIL",
+		TTL:                 300 * time.Second,
+		CachePass:           CacheWarm,
+		QType:               "MX",
+		Checked:             true,
+		Mismatch:            "NXDOMAIN instead of NOERROR",
+		Time:                time.Date(2026, 3, 1, 9, 30, 0, 125000, time.FixedZone("", -5*3600)),
+		Offset:              2500 * time.Microsecond,
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"duration_ms":12.5`) {
+		t.Errorf("expected duration in milliseconds, got %s", data)
+	}
+	if !strings.Contains(string(data), `"time":"2026-03-01T09:30:00.000125-05:00","offset_ms":2.5`) {
+		t.Errorf("expected an RFC3339 time with its zone and the run offset, got %s", data)
+	}
+
+	var out Result
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if out.Server != in.Server || out.Domain != in.Domain || out.Duration != in.Duration || out.Network != in.Network || out.Rcode != in.Rcode || out.TTL != in.TTL || out.CachePass != in.CachePass ||
+		out.QType != in.QType || out.Checked != in.Checked || out.Mismatch != in.Mismatch || !out.Time.Equal(in.Time) || out.Offset != in.Offset {
+		t.Errorf("round trip mismatch: %+v", out)
+	}
+	if out.Category != CategoryTimeout {
+		t.Errorf("expected the category of an older export to be derived from its error, got %q", out.Category)
+	}
+	if out.Error == nil || out.Error.Error() != "i/o timeout" {
+		t.Errorf("expected error to survive round trip, got %v", out.Error)
+	}
+	if !out.KeepaliveAdvertised || out.KeepaliveTimeout != 10*time.Second {
+		t.Errorf("expected keepalive to survive round trip, got %v %v", out.KeepaliveAdvertised, out.KeepaliveTimeout)
+	}
+}
+
+func TestResultJSONOmitsEmptyFields(t *testing.T) {
+	data, err := json.Marshal(Result{Server: "8.8.8.8", Domain: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"error", "network", "keepalive_timeout_ms", "rcode", "time", "offset_ms"} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("expected %q to be omitted, got %s", field, data)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// akamaiWhoamiName is answered by Akamai's authoritative servers with an A
+// record holding the address of the resolver that asked. Together with
+// ecsProbeName, whose TXT answer does the same for IPv6 resolvers too, it
+// is what DNS leak tests query.
+const akamaiWhoamiName = "whoami.akamai.net."
+
+// LeakProbeResult is where a server's recursive queries came from.
+type LeakProbeResult struct {
+	// Resolvers are the addresses the leak-test names' authoritative
+	// servers were queried from, i.e. the resolvers that did the recursion
+	Resolvers []netip.Addr
+	Err       error
+}
+
+// ProbeLeak asks serverAddr, over its own transport, for the leak-test
+// names and collects the resolver addresses in the answers. It only fails
+// when neither name told it anything.
+func (c *Client) ProbeLeak(serverAddr string) LeakProbeResult {
+	var res LeakProbeResult
+	var errs []error
+	add := func(addr netip.Addr) {
+		if addr = addr.Unmap(); !slices.Contains(res.Resolvers, addr) {
+			res.Resolvers = append(res.Resolvers, addr)
+		}
+	}
+
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{{akamaiWhoamiName, dns.TypeA}, {ecsProbeName, dns.TypeTXT}} {
+		m := newQuery(q.name, q.qtype)
+		c.applyEDNS(m)
+		resp, _, err := c.exchange(context.Background(), serverAddr, m)
+		if err == nil && resp.Rcode != dns.RcodeSuccess {
+			err = fmt.Errorf("%s", dns.RcodeToString[resp.Rcode])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", q.name, err))
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				if addr, ok := netip.AddrFromSlice(rr.A); ok {
+					add(addr)
+				}
+			case *dns.TXT:
+				// The client subnet record, if any, isn't an address
+				if addr, err := netip.ParseAddr(strings.Join(rr.Txt, " ")); err == nil {
+					add(addr)
+				}
+			}
+		}
+	}
+	if len(res.Resolvers) == 0 {
+		res.Err = errors.Join(errs...)
+		if res.Err == nil {
+			res.Err = fmt.Errorf("no resolver address in the answers")
+		}
+	}
+	return res
+}
+
+// RunLeakProbe probes every server in config in parallel and returns the
+// results by server.
+func RunLeakProbe(config Config) map[string]LeakProbeResult {
+	client := &Client{
+		Timeout:  config.Timeout,
+		Fallback: config.Fallback,
+		Proxy:    config.Proxy,
+		UDPSize:  config.UDPSize,
+	}
+	return probeServers(config, client.ProbeLeak)
+}
@@ -0,0 +1,51 @@
+package benchmark
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestProbeLeak(t *testing.T) {
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case akamaiWhoamiName:
+			rr, _ := dns.NewRR(akamaiWhoamiName + " 180 IN A 162.158.1.10")
+			m.Answer = append(m.Answer, rr)
+		case ecsProbeName:
+			for _, txt := range []string{`"2400:cb00:1::10"`, `"edns0-client-subnet 198.51.100.0/24"`, `"162.158.1.10"`} {
+				rr, _ := dns.NewRR(ecsProbeName + " 60 IN TXT " + txt)
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+	client := Client{Timeout: time.Second}
+
+	res := client.ProbeLeak(server)
+	if res.Err != nil {
+		t.Fatalf("leak probe failed: %v", res.Err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("162.158.1.10"), netip.MustParseAddr("2400:cb00:1::10")}
+	if !slices.Equal(res.Resolvers, want) {
+		t.Errorf("expected resolvers %v, got %v", want, res.Resolvers)
+	}
+}
+
+func TestProbeLeakFails(t *testing.T) {
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		_ = w.WriteMsg(m)
+	})
+	client := Client{Timeout: time.Second}
+
+	if res := client.ProbeLeak(server); res.Err == nil || len(res.Resolvers) != 0 {
+		t.Errorf("expected the probe to fail when both names are refused, got %+v", res)
+	}
+}
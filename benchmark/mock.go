@@ -0,0 +1,119 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// MockServer is a minimal in-process DNS server on the loopback interface.
+// It answers every query immediately, so measuring against it isolates the
+// overhead the benchmark itself adds (packing, syscalls, scheduling).
+type MockServer struct {
+	// Addr is the host:port the server listens on, usable as a benchmark server.
+	Addr   string
+	server *dns.Server
+}
+
+// StartMockServer starts a UDP mock server on 127.0.0.1 with a random port.
+func StartMockServer() (*MockServer, error) {
+	var lc net.ListenConfig
+	pc, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for mock server: %w", err)
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        pc,
+		Handler:           dns.HandlerFunc(mockAnswer),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ActivateAndServe() }()
+
+	select {
+	case <-started:
+	case err := <-errCh:
+		return nil, fmt.Errorf("mock server failed to start: %w", err)
+	}
+	return &MockServer{Addr: pc.LocalAddr().String(), server: server}, nil
+}
+
+// Close stops the mock server.
+func (s *MockServer) Close() error {
+	return s.server.Shutdown()
+}
+
+// mockAnswer replies to A queries with 127.0.0.1 and to everything else with
+// an empty NOERROR answer.
+func mockAnswer(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if len(r.Question) > 0 && r.Question[0].Qtype == dns.TypeA {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.IPv4(127, 0, 0, 1),
+		})
+	}
+	_ = w.WriteMsg(m) // Nothing useful to do if the client has gone away
+}
+
+// Calibration summarises the latency the tool itself adds to each query.
+type Calibration struct {
+	Samples int
+	Min     time.Duration
+	Median  time.Duration
+	P95     time.Duration
+}
+
+// Calibrate runs the configured workload against the mock server, with the
+// same concurrency as the real run, and reports the resulting latencies.
+// Network time on loopback is negligible, so what remains is overhead.
+func Calibrate(config Config, samples int) (Calibration, error) {
+	mock, err := StartMockServer()
+	if err != nil {
+		return Calibration{}, err
+	}
+	defer func() { _ = mock.Close() }()
+
+	domains := config.Domains
+	if len(domains) == 0 {
+		domains = []string{"example.com"}
+	}
+	iterations := (samples + len(domains) - 1) / len(domains)
+
+	cal := config
+	cal.Servers = []string{mock.Addr}
+	cal.Domains = domains
+	cal.Iterations = iterations
+	cal.Duration = 0
+	cal.Verbose = false
+	cal.LogSample = 0
+	cal.ShowProgress = false
+	cal.OnResult = nil
+	cal.Overhead = 0
+	cal.Proxy = nil // The mock server is on loopback
+
+	var durations []time.Duration
+	for _, res := range Run(cal) {
+		if res.Error == nil {
+			durations = append(durations, res.Duration)
+		}
+	}
+	if len(durations) == 0 {
+		return Calibration{}, fmt.Errorf("no successful calibration queries")
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return Calibration{
+		Samples: len(durations),
+		Min:     durations[0],
+		Median:  durations[len(durations)/2],
+		P95:     durations[(len(durations)*95)/100],
+	}, nil
+}
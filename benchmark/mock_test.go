@@ -0,0 +1,67 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMockServerAnswers(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mock.Close()
+
+	client := Client{Timeout: time.Second}
+	res := client.Measure(mock.Addr, "example.com")
+	if res.Error != nil {
+		t.Fatalf("query to mock server failed: %v", res.Error)
+	}
+	if res.Duration <= 0 {
+		t.Error("expected positive duration")
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	config := Config{
+		Domains:     []string{"example.com", "example.org"},
+		Concurrency: 4,
+		Timeout:     time.Second,
+	}
+
+	cal, err := Calibrate(config, 50)
+	if err != nil {
+		t.Fatalf("calibration failed: %v", err)
+	}
+	if cal.Samples < 50 {
+		t.Errorf("expected at least 50 samples, got %d", cal.Samples)
+	}
+	if cal.Min <= 0 || cal.Min > cal.Median || cal.Median > cal.P95 {
+		t.Errorf("expected 0 < min <= median <= p95, got %+v", cal)
+	}
+}
+
+func TestRunSubtractsOverhead(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer mock.Close()
+
+	results := Run(Config{
+		Servers:     []string{mock.Addr},
+		Domains:     []string{"example.com"},
+		Iterations:  5,
+		Concurrency: 1,
+		Timeout:     time.Second,
+		Overhead:    time.Hour, // Larger than any real query, so every result clamps to zero
+	})
+	for _, res := range results {
+		if res.Error != nil {
+			t.Fatalf("unexpected error: %v", res.Error)
+		}
+		if res.Duration != 0 {
+			t.Errorf("expected overhead to clamp duration to 0, got %v", res.Duration)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dotPool holds up to size persistent DoT connections to a single server.
+// Idle connections wait in conns; slots caps how many are open at once so a
+// busy worker pool queues for a connection instead of dialling more.
+type dotPool struct {
+	conns chan *dns.Conn
+	slots chan struct{}
+	// dials counts the connections opened, so a probe can tell whether a
+	// query reused one
+	dials atomic.Int64
+}
+
+func newDoTPool(size int) *dotPool {
+	return &dotPool{
+		conns: make(chan *dns.Conn, size),
+		slots: make(chan struct{}, size),
+	}
+}
+
+// get returns an idle connection, dials a new one if the pool has free slots,
+// or waits up to timeout for another worker to release one. reused reports
+// whether the connection had already carried a query.
+func (p *dotPool) get(dial func() (*dns.Conn, error), timeout time.Duration) (conn *dns.Conn, reused bool, err error) {
+	select {
+	case conn := <-p.conns:
+		return conn, true, nil
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case conn := <-p.conns:
+		return conn, true, nil
+	case p.slots <- struct{}{}:
+		conn, err := dial()
+		if err != nil {
+			<-p.slots
+			return nil, false, err
+		}
+		p.dials.Add(1)
+		return conn, false, nil
+	case <-timer.C:
+		return nil, false, fmt.Errorf("timed out waiting for a pooled connection")
+	}
+}
+
+// put returns a healthy connection to the pool for reuse.
+func (p *dotPool) put(conn *dns.Conn) {
+	p.conns <- conn
+}
+
+// discard closes a broken connection and frees its slot. The server has
+// often closed it already, so failing to close it cleanly is no news.
+func (p *dotPool) discard(conn *dns.Conn) {
+	_ = conn.Close()
+	<-p.slots
+}
+
+// dohPool spreads queries to a single DoH server across size independent
+// HTTP clients. Each client has its own transport, so each holds its own
+// connection even when HTTP/2 would otherwise multiplex everything onto one.
+type dohPool struct {
+	clients []*http.Client
+	next    atomic.Uint32
+}
+
+func newDoHPool(size int, newClient func() *http.Client) *dohPool {
+	p := &dohPool{clients: make([]*http.Client, size)}
+	for i := range p.clients {
+		p.clients[i] = newClient()
+	}
+	return p
+}
+
+// client returns the next HTTP client in round-robin order.
+func (p *dohPool) client() *http.Client {
+	n := p.next.Add(1) - 1
+	return p.clients[n%uint32(len(p.clients))]
+}
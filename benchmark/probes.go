@@ -0,0 +1,315 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Outcomes of a wire-format probe.
+const (
+	// ProbeOK means the server answered as the RFCs require.
+	ProbeOK = "ok"
+	// ProbeError means the server answered with an error code (FORMERR,
+	// SERVFAIL, NOTIMP, ...) or the exchange failed.
+	ProbeError = "error"
+	// ProbeDropped means no answer arrived within the timeout.
+	ProbeDropped = "dropped"
+	// ProbeMishandled means the server answered, but not correctly.
+	ProbeMishandled = "mishandled"
+)
+
+// paddingBlock is the query padding block size recommended by RFC 8467.
+const paddingBlock = 468
+
+// WireProbe is an unusual but legal query. query builds it for a name;
+// check judges the response and defaults to checkAnswer.
+type WireProbe struct {
+	Name        string
+	Description string
+	query       func(name string) *dns.Msg
+	check       func(q, resp *dns.Msg) (outcome, detail string)
+}
+
+// ProbeResult is the outcome of one probe against one server.
+type ProbeResult struct {
+	Probe    string
+	Outcome  string
+	Detail   string
+	Duration time.Duration
+}
+
+// WireProbes is the probe suite, in the order it runs. The plain query
+// comes first so a server that drops everything is easy to tell apart.
+var WireProbes = []WireProbe{
+	{
+		Name:        "plain",
+		Description: "ordinary A query, for reference",
+		query:       func(name string) *dns.Msg { return newQuery(name, dns.TypeA) },
+	},
+	{
+		Name:        "mixed-case",
+		Description: "randomised name case must be echoed exactly (DNS 0x20)",
+		query:       func(name string) *dns.Msg { return newQuery(mixCase(name), dns.TypeA) },
+		check:       checkCasePreserved,
+	},
+	{
+		Name:        "no-recursion",
+		Description: "RD bit clear; refusing is fine, failing is not",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.RecursionDesired = false
+			return m
+		},
+		check: func(q, resp *dns.Msg) (string, string) {
+			if resp.Rcode == dns.RcodeRefused {
+				return ProbeOK, "refuses non-recursive queries"
+			}
+			return checkAnswer(q, resp)
+		},
+	},
+	{
+		Name:        "checking-disabled",
+		Description: "CD and DO bits set",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.CheckingDisabled = true
+			m.SetEdns0(dns.DefaultMsgSize, true)
+			return m
+		},
+	},
+	{
+		Name:        "ad-flag",
+		Description: "AD bit set in the query (RFC 6840)",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.AuthenticatedData = true
+			return m
+		},
+	},
+	{
+		Name:        "z-flag",
+		Description: "reserved Z bit set, which servers must ignore",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.Zero = true
+			return m
+		},
+	},
+	{
+		Name:        "large-buffer",
+		Description: "EDNS with a 4096-byte UDP buffer and DO bit",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.SetEdns0(4096, true)
+			return m
+		},
+	},
+	{
+		Name:        "padding",
+		Description: "EDNS padding option up to a 468-byte query (RFC 8467)",
+		query:       paddedQuery,
+	},
+	{
+		Name:        "unknown-option",
+		Description: "256-byte EDNS option from the local range, which must be ignored",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			opt := m.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_LOCAL{Code: dns.EDNS0LOCALSTART, Data: make([]byte, 256)})
+			return m
+		},
+	},
+	{
+		Name:        "cookie",
+		Description: "EDNS client cookie, which must be echoed if cookies are supported",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			opt := m.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0123456789abcdef"})
+			return m
+		},
+		check: checkCookie,
+	},
+	{
+		Name:        "edns-version",
+		Description: "EDNS version 1, which must be answered with BADVERS",
+		query: func(name string) *dns.Msg {
+			m := newQuery(name, dns.TypeA)
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			m.IsEdns0().SetVersion(1)
+			return m
+		},
+		check: func(_, resp *dns.Msg) (string, string) {
+			if resp.Rcode == dns.RcodeBadVers {
+				return ProbeOK, ""
+			}
+			return ProbeMishandled, fmt.Sprintf("%s instead of BADVERS", dns.RcodeToString[resp.Rcode])
+		},
+	},
+	{
+		Name:        "unknown-type",
+		Description: "query for private-use TYPE65280",
+		query:       func(name string) *dns.Msg { return newQuery(name, 65280) },
+	},
+	{
+		Name:        "long-name",
+		Description: "name close to the 255-octet limit",
+		query:       func(name string) *dns.Msg { return newQuery(longName(name), dns.TypeA) },
+	},
+}
+
+func newQuery(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	return m
+}
+
+// mixCase alternates the case of every letter in name.
+func mixCase(name string) string {
+	b := []byte(strings.ToLower(name))
+	upper := true
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' {
+			if upper {
+				b[i] = c - 'a' + 'A'
+			}
+			upper = !upper
+		}
+	}
+	return string(b)
+}
+
+// longName prefixes name with 63-octet labels until one more would push it
+// past the 255-octet wire limit.
+func longName(name string) string {
+	name = dns.Fqdn(name)
+	label := strings.Repeat("a", 63) + "."
+	// Wire length is the presentation length plus the root label's length byte
+	for len(name)+len(label)+1 <= 255 {
+		name = label + name
+	}
+	return name
+}
+
+// paddedQuery builds a query padded to paddingBlock bytes.
+func paddedQuery(name string) *dns.Msg {
+	m := newQuery(name, dns.TypeA)
+	m.SetEdns0(dns.DefaultMsgSize, false)
+	opt := m.IsEdns0()
+	pad := &dns.EDNS0_PADDING{}
+	opt.Option = append(opt.Option, pad)
+	if packed, err := m.Pack(); err == nil && len(packed) < paddingBlock {
+		pad.Padding = make([]byte, paddingBlock-len(packed))
+	}
+	return m
+}
+
+// checkAnswer accepts NOERROR and NXDOMAIN answers to the question asked.
+func checkAnswer(q, resp *dns.Msg) (string, string) {
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return ProbeError, dns.RcodeToString[resp.Rcode]
+	}
+	if len(resp.Question) != 1 || !strings.EqualFold(resp.Question[0].Name, q.Question[0].Name) {
+		return ProbeMishandled, "answer is for a different question"
+	}
+	return ProbeOK, ""
+}
+
+func checkCasePreserved(q, resp *dns.Msg) (string, string) {
+	outcome, detail := checkAnswer(q, resp)
+	if outcome != ProbeOK {
+		return outcome, detail
+	}
+	if resp.Question[0].Name != q.Question[0].Name {
+		return ProbeMishandled, "question case not preserved"
+	}
+	return ProbeOK, ""
+}
+
+func checkCookie(q, resp *dns.Msg) (string, string) {
+	outcome, detail := checkAnswer(q, resp)
+	if outcome != ProbeOK || resp.IsEdns0() == nil {
+		return outcome, detail
+	}
+	sent := q.IsEdns0().Option[0].(*dns.EDNS0_COOKIE).Cookie
+	for _, o := range resp.IsEdns0().Option {
+		if c, ok := o.(*dns.EDNS0_COOKIE); ok {
+			if !strings.HasPrefix(c.Cookie, sent) {
+				return ProbeMishandled, "client cookie not echoed"
+			}
+			return ProbeOK, "cookies supported"
+		}
+	}
+	return ProbeOK, ""
+}
+
+// Probe runs the wire-format probe suite against serverAddr, querying name.
+func (c *Client) Probe(serverAddr, name string) []ProbeResult {
+	results := make([]ProbeResult, 0, len(WireProbes))
+	for _, p := range WireProbes {
+		q := p.query(name)
+		start := time.Now()
+		resp, _, err := c.exchange(context.Background(), serverAddr, q.Copy())
+		res := ProbeResult{Probe: p.Name, Duration: time.Since(start)}
+
+		var netErr net.Error
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout():
+			res.Outcome = ProbeDropped
+		case err != nil:
+			res.Outcome, res.Detail = ProbeError, err.Error()
+		default:
+			check := p.check
+			if check == nil {
+				check = checkAnswer
+			}
+			res.Outcome, res.Detail = check(q, resp)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// RunWireProbes probes every server in config in parallel, using the first
+// configured domain, and returns the results by server.
+func RunWireProbes(config Config) map[string][]ProbeResult {
+	name := "example.com"
+	if len(config.Domains) > 0 {
+		name = config.Domains[0]
+	}
+	client := &Client{Timeout: config.Timeout, Fallback: config.Fallback, Proxy: config.Proxy}
+	return probeServers(config, func(target string) []ProbeResult { return client.Probe(target, name) })
+}
+
+// probeServers runs probe against every server in config in parallel,
+// through config.Via, and returns the results by server.
+func probeServers[T any](config Config, probe func(target string) T) map[string]T {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	byServer := make(map[string]T, len(config.Servers))
+	for _, server := range config.Servers {
+		target := server
+		if via, ok := config.Via[server]; ok {
+			target = via
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := probe(target)
+			mu.Lock()
+			byServer[server] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return byServer
+}
@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestProbeCompliantServer(t *testing.T) {
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if opt := r.IsEdns0(); opt != nil && opt.Version() != 0 {
+			m.SetRcode(r, dns.RcodeBadVers)
+			m.SetEdns0(dns.DefaultMsgSize, false)
+		}
+		_ = w.WriteMsg(m)
+	})
+	client := Client{Timeout: time.Second}
+
+	for _, res := range client.Probe(server, "example.com") {
+		if res.Outcome != ProbeOK {
+			t.Errorf("%s: expected ok, got %s (%s)", res.Probe, res.Outcome, res.Detail)
+		}
+	}
+}
+
+func TestProbeFindsProblems(t *testing.T) {
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Zero {
+			return // Drop queries with the Z bit set
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Question[0].Name = strings.ToLower(m.Question[0].Name)
+		if r.Question[0].Qtype == 65280 {
+			m.Rcode = dns.RcodeNotImplemented
+		}
+		_ = w.WriteMsg(m)
+	})
+	client := Client{Timeout: 200 * time.Millisecond}
+
+	want := map[string]string{
+		"mixed-case":   ProbeMishandled,
+		"z-flag":       ProbeDropped,
+		"edns-version": ProbeMishandled,
+		"unknown-type": ProbeError,
+	}
+	for _, res := range client.Probe(server, "example.com") {
+		expected, ok := want[res.Probe]
+		if !ok {
+			expected = ProbeOK
+		}
+		if res.Outcome != expected {
+			t.Errorf("%s: expected %s, got %s (%s)", res.Probe, expected, res.Outcome, res.Detail)
+		}
+	}
+}
+
+func TestProbeQueries(t *testing.T) {
+	if got := mixCase("example.com."); got != "ExAmPlE.cOm." {
+		t.Errorf("unexpected mixed case name %q", got)
+	}
+	name := longName("example.com")
+	if _, ok := dns.IsDomainName(name); !ok || len(name)+1 > 255 || len(name)+1 < 255-64 {
+		t.Errorf("expected a valid name close to 255 octets, got %d octets", len(name)+1)
+	}
+	packed, err := paddedQuery("example.com").Pack()
+	if err != nil || len(packed) != paddingBlock {
+		t.Errorf("expected a %d-byte padded query, got %d (%v)", paddingBlock, len(packed), err)
+	}
+}
@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/proxy"
+)
+
+// ParseProxy validates a SOCKS5 proxy URL such as socks5h://127.0.0.1:9050.
+// Hostnames are always handed to the proxy to resolve, so socks5 and socks5h
+// behave the same.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use socks5:// or socks5h://)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return u, nil
+}
+
+// dialProxy opens a TCP connection to addr through c.Proxy.
+func (c *Client) dialProxy(addr string) (net.Conn, error) {
+	d, err := proxy.FromURL(c.Proxy, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return d.Dial("tcp", addr)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	return cd.DialContext(ctx, "tcp", addr)
+}
+
+// dialProxyDNS returns a DNS connection to addr through c.Proxy, wrapped in
+// TLS when tlsConfig is set. SOCKS5 only carries TCP, so plain DNS goes over
+// TCP instead of UDP.
+func (c *Client) dialProxyDNS(addr string, tlsConfig *tls.Config) (*dns.Conn, error) {
+	conn, err := c.dialProxy(addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	return &dns.Conn{Conn: conn}, nil
+}
+
+// exchangeOnce sends m over a fresh connection from dial and closes it,
+// for transports dns.Client can't dial itself (proxied or bootstrapped).
+func exchangeOnce(client *dns.Client, m *dns.Msg, dial func() (*dns.Conn, error)) (*dns.Msg, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }() // The answer has already been read
+	resp, _, err := client.ExchangeWithConn(m, conn)
+	return resp, err
+}
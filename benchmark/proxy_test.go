@@ -0,0 +1,128 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startSOCKSServer runs a minimal SOCKS5 proxy (no auth, CONNECT only) on
+// loopback and counts the connections it relays.
+func startSOCKSServer(t *testing.T) (*url.URL, *atomic.Int32) {
+	t.Helper()
+	var lc net.ListenConfig
+	ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on tcp: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	var relayed atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go socksRelay(conn, &relayed)
+		}
+	}()
+	return &url.URL{Scheme: "socks5", Host: ln.Addr().String()}, &relayed
+}
+
+// socksRelay performs the server side of a SOCKS5 CONNECT and then copies
+// data in both directions until either side closes.
+func socksRelay(conn net.Conn, relayed *atomic.Int32) {
+	defer func() { _ = conn.Close() }()
+	buf := make([]byte, 262)
+	// Greeting: version, method count, methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+	// Request: version, CONNECT, reserved, address type, address, port
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	var d net.Dialer
+	upstream, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	relayed.Add(1)
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, raw := range []string{"socks5://127.0.0.1:9050", "socks5h://localhost:9150"} {
+		if _, err := ParseProxy(raw); err != nil {
+			t.Errorf("expected %q to be accepted: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"http://127.0.0.1:8080", "socks5://", "127.0.0.1:9050"} {
+		if _, err := ParseProxy(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestMeasureThroughProxy(t *testing.T) {
+	proxyURL, relayed := startSOCKSServer(t)
+	dot, _ := startDoTServer(t)
+	client := Client{Timeout: 2 * time.Second, Proxy: proxyURL}
+
+	servers := []string{
+		startTruncatingServer(t), // Plain DNS goes over TCP, so never sees truncation
+		dot,
+		startDoHServer(t, 0),
+	}
+	for _, server := range servers {
+		if res := client.Measure(server, "example.com"); res.Error != nil {
+			t.Errorf("%s: query through proxy failed: %v", server, res.Error)
+		}
+	}
+	if got := relayed.Load(); got < int32(len(servers)) {
+		t.Errorf("expected every server to be reached through the proxy, got %d connections", got)
+	}
+}
@@ -0,0 +1,134 @@
+package benchmark
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Question is one query of a batch: a name, its type (zero means the
+// client's QType) and, optionally, what the answer must look like.
+type Question struct {
+	Name string
+	Type uint16
+	// ExpectRcode is the response code the answer must have, e.g.
+	// "NXDOMAIN"; empty means NOERROR when ExpectAnswers is set and
+	// anything otherwise.
+	ExpectRcode string
+	// ExpectAnswers are records (rdata in presentation format, e.g.
+	// "192.0.2.1") that must all be in the answer section.
+	ExpectAnswers []string
+	// ExpectWithin are the ranges every A and AAAA record in the answer
+	// must fall in. Addresses of a family without any range aren't
+	// checked, so IPv4 ranges alone say nothing about AAAA answers.
+	ExpectWithin []netip.Prefix
+}
+
+// hasExpectations reports whether answers to q can be right or wrong.
+func (q *Question) hasExpectations() bool {
+	return q.ExpectRcode != "" || len(q.ExpectAnswers) > 0 || len(q.ExpectWithin) > 0
+}
+
+// mismatch returns which of q's expectations resp falls short of, as a
+// Result.MismatchKind, and describes how, or returns "" for both when it
+// meets them.
+func (q *Question) mismatch(resp *dns.Msg) (kind, text string) {
+	rcode := dns.RcodeToString[resp.Rcode]
+	want := q.ExpectRcode
+	if want == "" && (len(q.ExpectAnswers) > 0 || len(q.ExpectWithin) > 0) {
+		want = "NOERROR"
+	}
+	if want != "" && !strings.EqualFold(rcode, want) {
+		return MismatchRcode, fmt.Sprintf("%s instead of %s", rcode, strings.ToUpper(want))
+	}
+
+	got := make(map[string]bool, len(resp.Answer))
+	for _, rr := range resp.Answer {
+		got[normalizeRdata(rdata(rr))] = true
+	}
+	var missing []string
+	for _, want := range q.ExpectAnswers {
+		if !got[normalizeRdata(want)] {
+			missing = append(missing, want)
+		}
+	}
+	if len(missing) > 0 {
+		return MismatchMissing, "missing " + strings.Join(missing, ", ")
+	}
+	if unexpected := q.outsideRanges(resp); len(unexpected) > 0 {
+		return MismatchUnexpected, "unexpected " + strings.Join(unexpected, ", ")
+	}
+	return "", ""
+}
+
+// outsideRanges returns the addresses in resp's answer that fall outside
+// q.ExpectWithin, skipping families q has no ranges for.
+func (q *Question) outsideRanges(resp *dns.Msg) []string {
+	if len(q.ExpectWithin) == 0 {
+		return nil
+	}
+	var outside []string
+	for _, rr := range resp.Answer {
+		var ip net.IP
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		default:
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(ip)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		checked, within := false, false
+		for _, p := range q.ExpectWithin {
+			if p.Addr().Is4() != addr.Is4() {
+				continue
+			}
+			checked = true
+			if p.Contains(addr) {
+				within = true
+				break
+			}
+		}
+		if checked && !within {
+			outside = append(outside, addr.String())
+		}
+	}
+	return outside
+}
+
+// rdata is the presentation form of rr without its owner, TTL, class and
+// type, e.g. "10 mx.example.com." for an MX record.
+func rdata(rr dns.RR) string {
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// normalizeRdata makes rdata comparable whatever the case and whether
+// names were written with their trailing dot.
+func normalizeRdata(s string) string {
+	fields := strings.Fields(strings.ToLower(s))
+	for i, f := range fields {
+		fields[i] = strings.TrimSuffix(f, ".")
+	}
+	return strings.Join(fields, " ")
+}
+
+// questions returns what Run asks every server: config.Questions, or one
+// question of the client's type per domain.
+func (config Config) questions() []Question {
+	if len(config.Questions) > 0 {
+		return config.Questions
+	}
+	questions := make([]Question, len(config.Domains))
+	for i, domain := range config.Domains {
+		questions[i] = Question{Name: domain}
+	}
+	return questions
+}
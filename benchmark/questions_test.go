@@ -0,0 +1,108 @@
+package benchmark
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestQuestionMismatch(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetQuestion("example.com.", dns.TypeMX)
+	rr, err := dns.NewRR("example.com. 300 IN MX 10 Mail.Example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Answer = []dns.RR{rr}
+
+	for _, tc := range []struct {
+		q    Question
+		want string
+	}{
+		{Question{ExpectAnswers: []string{"10 mail.example.com"}}, ""},
+		{Question{ExpectAnswers: []string{"10 mail.example.com.", "20 backup.example.com"}}, "missing 20 backup.example.com"},
+		{Question{ExpectRcode: "nxdomain"}, "NOERROR instead of NXDOMAIN"},
+		{Question{ExpectRcode: "NOERROR"}, ""},
+	} {
+		if _, got := tc.q.mismatch(resp); got != tc.want {
+			t.Errorf("mismatch(%+v) = %q, want %q", tc.q, got, tc.want)
+		}
+	}
+
+	resp.Rcode = dns.RcodeNameError
+	resp.Answer = nil
+	q := Question{ExpectAnswers: []string{"10 mail.example.com"}}
+	if kind, got := q.mismatch(resp); got != "NXDOMAIN instead of NOERROR" || kind != MismatchRcode {
+		t.Errorf("expected an rcode mismatch, got %q", got)
+	}
+}
+
+func TestRunQuestions(t *testing.T) {
+	mock, err := StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	results := Run(Config{
+		Servers: []string{mock.Addr},
+		Questions: []Question{
+			{Name: "example.com", ExpectAnswers: []string{"127.0.0.1"}},
+			{Name: "example.com", Type: dns.TypeAAAA, ExpectAnswers: []string{"::1"}},
+			{Name: "example.org", Type: dns.TypeTXT},
+		},
+		Iterations:  1,
+		Concurrency: 1,
+		Timeout:     time.Second,
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	byType := make(map[string]Result)
+	for _, res := range results {
+		byType[res.QType] = res
+	}
+	if a := byType["A"]; !a.Checked || a.Mismatch != "" {
+		t.Errorf("expected the A answer to match, got %+v", a)
+	}
+	if aaaa := byType["AAAA"]; !aaaa.Checked || aaaa.Mismatch != "missing ::1" {
+		t.Errorf("expected the AAAA answer to miss ::1, got %+v", aaaa)
+	}
+	if txt := byType["TXT"]; txt.Checked || txt.Domain != "example.org" {
+		t.Errorf("expected an unchecked TXT answer for example.org, got %+v", txt)
+	}
+}
+
+func TestQuestionExpectWithin(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetQuestion("example.com.", dns.TypeA)
+	for _, s := range []string{
+		"example.com. 300 IN CNAME edge.example.net.",
+		"edge.example.net. 60 IN A 192.0.2.10",
+		"edge.example.net. 60 IN A 203.0.113.66",
+		"edge.example.net. 60 IN AAAA 2001:db8::1",
+	} {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+
+	q := Question{ExpectWithin: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}}
+	if kind, got := q.mismatch(resp); got != "unexpected 203.0.113.66" || kind != MismatchUnexpected {
+		t.Errorf("expected the address outside the range to be flagged, got %q", got)
+	}
+	q.ExpectWithin = append(q.ExpectWithin, netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8:1::/48"))
+	if _, got := q.mismatch(resp); got != "unexpected 2001:db8::1" {
+		t.Errorf("expected the IPv6 address outside the range to be flagged, got %q", got)
+	}
+
+	resp.Rcode = dns.RcodeNameError
+	resp.Answer = nil
+	if kind, got := q.mismatch(resp); got != "NXDOMAIN instead of NOERROR" || kind != MismatchRcode {
+		t.Errorf("expected a name that should resolve to be flagged, got %q", got)
+	}
+}
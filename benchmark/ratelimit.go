@@ -0,0 +1,72 @@
+package benchmark
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket paces callers to a fixed rate. It holds a single token, so
+// queries are spread evenly instead of going out in bursts, which is what
+// providers' rate limiters count.
+type tokenBucket struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // When the next token is available
+}
+
+func newTokenBucket(qps float64) *tokenBucket {
+	return &tokenBucket{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+// wait blocks until a token is available and takes it.
+func (b *tokenBucket) wait() {
+	b.mu.Lock()
+	now := time.Now()
+	at := b.next
+	if at.Before(now) {
+		at = now
+	}
+	b.next = at.Add(b.interval)
+	b.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+// rateLimits holds the token buckets of a run: one for all queries and one
+// per server that has its own limit. A nil bucket doesn't limit.
+type rateLimits struct {
+	global    *tokenBucket
+	perServer map[string]*tokenBucket
+}
+
+// newRateLimits creates the buckets for config.QPS and config.ServerQPS,
+// or returns nil when neither is set.
+func newRateLimits(config Config) *rateLimits {
+	limits := &rateLimits{perServer: make(map[string]*tokenBucket)}
+	if config.QPS > 0 {
+		limits.global = newTokenBucket(config.QPS)
+	}
+	for server, qps := range config.ServerQPS {
+		if qps > 0 {
+			limits.perServer[server] = newTokenBucket(qps)
+		}
+	}
+	if limits.global == nil && len(limits.perServer) == 0 {
+		return nil
+	}
+	return limits
+}
+
+// wait blocks until a query to server is allowed by its own limit and the
+// global one.
+func (l *rateLimits) wait(server string) {
+	if l == nil {
+		return
+	}
+	if b := l.perServer[server]; b != nil {
+		b.wait()
+	}
+	if l.global != nil {
+		l.global.wait()
+	}
+}
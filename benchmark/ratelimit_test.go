@@ -0,0 +1,35 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitsPace(t *testing.T) {
+	limits := newRateLimits(Config{QPS: 200, ServerQPS: map[string]float64{"slow": 50}})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limits.wait("fast")
+	}
+	// The first token is free, the other four take 5ms each
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("5 queries at 200 qps took %v, expected at least 20ms", elapsed)
+	}
+
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		limits.wait("slow")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 queries at 50 qps took %v, expected at least 40ms", elapsed)
+	}
+}
+
+func TestRateLimitsUnset(t *testing.T) {
+	limits := newRateLimits(Config{ServerQPS: map[string]float64{"8.8.8.8": 0}})
+	if limits != nil {
+		t.Fatalf("expected no limits, got %+v", limits)
+	}
+	limits.wait("8.8.8.8") // A nil limiter never blocks
+}
@@ -0,0 +1,44 @@
+package benchmark
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// searchCandidates returns the names a stub resolver tries, in order, when
+// looking up name with the given search list (resolv.conf(5) semantics).
+// Names ending in a dot are absolute and never expanded. Names with at least
+// ndots dots are tried as-is before the search list, others after it.
+func searchCandidates(name string, search []string, ndots int) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	absoluteFirst := strings.Count(name, ".") >= ndots
+
+	out := make([]string, 0, len(search)+1)
+	if absoluteFirst {
+		out = append(out, dns.Fqdn(name))
+	}
+	for _, suffix := range search {
+		suffix = strings.Trim(suffix, ".")
+		if suffix == "" {
+			continue
+		}
+		out = append(out, dns.Fqdn(name+"."+suffix))
+	}
+	if !absoluteFirst {
+		out = append(out, dns.Fqdn(name))
+	}
+	return out
+}
+
+// searchContinues reports whether a stub would move on to the next search
+// candidate after resp: the name doesn't exist, or exists without records of
+// the queried type.
+func searchContinues(resp *dns.Msg) bool {
+	if resp.Rcode == dns.RcodeNameError {
+		return true
+	}
+	return resp.Rcode == dns.RcodeSuccess && len(resp.Answer) == 0
+}
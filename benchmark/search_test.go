@@ -0,0 +1,67 @@
+package benchmark
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSearchCandidates(t *testing.T) {
+	search := []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local."}
+	tests := []struct {
+		name  string
+		ndots int
+		want  []string
+	}{
+		{"web", 5, []string{"web.default.svc.cluster.local.", "web.svc.cluster.local.", "web.cluster.local.", "web."}},
+		{"example.com", 1, []string{"example.com.", "example.com.default.svc.cluster.local.", "example.com.svc.cluster.local.", "example.com.cluster.local."}},
+		{"example.com", 5, []string{"example.com.default.svc.cluster.local.", "example.com.svc.cluster.local.", "example.com.cluster.local.", "example.com."}},
+		{"example.com.", 5, []string{"example.com."}},
+	}
+	for _, tt := range tests {
+		got := searchCandidates(tt.name, search, tt.ndots)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchCandidates(%q, ndots=%d) = %v, want %v", tt.name, tt.ndots, got, tt.want)
+		}
+	}
+}
+
+func TestMeasureWalksSearchList(t *testing.T) {
+	// Only the fully qualified service name exists; everything else is NXDOMAIN.
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.Question[0].Name == "web.svc.cluster.local." {
+			rr, _ := dns.NewRR("web.svc.cluster.local. 30 IN A 10.0.0.1")
+			m.Answer = append(m.Answer, rr)
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	client := Client{
+		Timeout:       time.Second,
+		SearchDomains: []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"},
+		Ndots:         5,
+	}
+
+	res := client.Measure(server, "web")
+	if res.Error != nil {
+		t.Fatalf("lookup failed: %v", res.Error)
+	}
+	if res.SearchQueries != 2 || res.QueryName != "web.svc.cluster.local." {
+		t.Errorf("expected to resolve on the 2nd candidate, got %d queries ending at %q", res.SearchQueries, res.QueryName)
+	}
+	if res.SearchOverhead <= 0 || res.SearchOverhead >= res.Duration {
+		t.Errorf("expected 0 < overhead < duration, got %v of %v", res.SearchOverhead, res.Duration)
+	}
+
+	// An external name with fewer than ndots dots pays for every suffix first
+	res = client.Measure(server, "example.com")
+	if res.SearchQueries != 4 || res.QueryName != "example.com." {
+		t.Errorf("expected all 4 candidates to be tried, got %d ending at %q", res.SearchQueries, res.QueryName)
+	}
+}
@@ -0,0 +1,57 @@
+package benchmark
+
+import (
+	"github.com/miekg/dns"
+)
+
+// SVCBInfo summarises the HTTPS/SVCB records (RFC 9460) in an answer.
+type SVCBInfo struct {
+	Records   int      // HTTPS or SVCB records in the answer section
+	AliasMode bool     // At least one record has SvcPriority 0
+	ALPN      []string // Distinct ALPN protocol IDs, in order of appearance
+	ECH       bool     // An ECH config was published
+	IPHints   bool     // ipv4hint or ipv6hint was present
+}
+
+// isSVCBType reports whether qtype is answered with SVCB-format records.
+func isSVCBType(qtype uint16) bool {
+	return qtype == dns.TypeHTTPS || qtype == dns.TypeSVCB
+}
+
+// parseSVCB extracts the service binding parameters from resp's answer
+// section. CNAMEs leading to the records are skipped.
+func parseSVCB(resp *dns.Msg) *SVCBInfo {
+	info := &SVCBInfo{}
+	seen := map[string]bool{}
+	for _, rr := range resp.Answer {
+		var svcb *dns.SVCB
+		switch r := rr.(type) {
+		case *dns.HTTPS:
+			svcb = &r.SVCB
+		case *dns.SVCB:
+			svcb = r
+		default:
+			continue
+		}
+		info.Records++
+		if svcb.Priority == 0 {
+			info.AliasMode = true
+		}
+		for _, kv := range svcb.Value {
+			switch v := kv.(type) {
+			case *dns.SVCBAlpn:
+				for _, id := range v.Alpn {
+					if !seen[id] {
+						seen[id] = true
+						info.ALPN = append(info.ALPN, id)
+					}
+				}
+			case *dns.SVCBECHConfig:
+				info.ECH = true
+			case *dns.SVCBIPv4Hint, *dns.SVCBIPv6Hint:
+				info.IPHints = true
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,85 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("bad RR %q: %v", s, err)
+	}
+	return rr
+}
+
+func TestParseSVCB(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetQuestion("example.com.", dns.TypeHTTPS)
+	resp.Answer = []dns.RR{
+		mustRR(t, "example.com. 300 IN CNAME cdn.example.net."),
+		mustRR(t, "cdn.example.net. 300 IN HTTPS 1 . alpn=h3,h2 ipv4hint=192.0.2.1 ech=AEX+DQBB"),
+		mustRR(t, "cdn.example.net. 300 IN HTTPS 2 . alpn=h2,http/1.1"),
+	}
+
+	info := parseSVCB(resp)
+	if info.Records != 2 || info.AliasMode {
+		t.Errorf("expected 2 service-mode records, got %+v", info)
+	}
+	if len(info.ALPN) != 3 || info.ALPN[0] != "h3" || info.ALPN[2] != "http/1.1" {
+		t.Errorf("expected distinct ALPN ids h3, h2, http/1.1, got %v", info.ALPN)
+	}
+	if !info.ECH || !info.IPHints {
+		t.Errorf("expected ECH and IP hints, got %+v", info)
+	}
+}
+
+func TestParseSVCBAliasMode(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetQuestion("example.com.", dns.TypeHTTPS)
+	resp.Answer = []dns.RR{mustRR(t, "example.com. 300 IN HTTPS 0 svc.example.net.")}
+
+	if info := parseSVCB(resp); info.Records != 1 || !info.AliasMode {
+		t.Errorf("expected one alias-mode record, got %+v", info)
+	}
+}
+
+func TestMeasureHTTPSQuery(t *testing.T) {
+	gotType := make(chan uint16, 1)
+	server := startUDPServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		gotType <- r.Question[0].Qtype
+		m := new(dns.Msg)
+		m.SetReply(r)
+		rr, _ := dns.NewRR(r.Question[0].Name + " 300 IN HTTPS 1 . alpn=h2")
+		m.Answer = append(m.Answer, rr)
+		_ = w.WriteMsg(m)
+	})
+
+	client := Client{Timeout: time.Second, QType: dns.TypeHTTPS}
+	res := client.Measure(server, "example.com")
+	if res.Error != nil {
+		t.Fatalf("query failed: %v", res.Error)
+	}
+	if qtype := <-gotType; qtype != dns.TypeHTTPS {
+		t.Errorf("expected an HTTPS query, server saw type %d", qtype)
+	}
+	if res.SVCB == nil || res.SVCB.Records != 1 || len(res.SVCB.ALPN) != 1 {
+		t.Fatalf("expected a parsed HTTPS answer, got %+v", res.SVCB)
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Result
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.SVCB == nil || back.SVCB.ALPN[0] != "h2" {
+		t.Errorf("expected SVCB info to survive a JSON round trip, got %s", data)
+	}
+}
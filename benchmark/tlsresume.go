@@ -0,0 +1,485 @@
+package benchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// sessionCache is a server's TLS session cache with TLSResume. It also
+// notes the tickets the server issued and whether any allows 0-RTT, which
+// only QUIC connections record (see inspectTickets for TCP).
+type sessionCache struct {
+	tls.ClientSessionCache
+	tickets   atomic.Int64
+	earlyData atomic.Bool
+}
+
+// Put stores a session; a nil one removes the key's session.
+func (s *sessionCache) Put(key string, cs *tls.ClientSessionState) {
+	if cs != nil {
+		s.tickets.Add(1)
+		if _, state, err := cs.ResumptionState(); err == nil && state != nil && state.EarlyData {
+			s.earlyData.Store(true)
+		}
+	}
+	s.ClientSessionCache.Put(key, cs)
+}
+
+// sessionCache returns the TLS session cache of a server, creating it on
+// first use.
+func (c *Client) sessionCache(serverAddr string) *sessionCache {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	return c.sessionCacheLocked(serverAddr)
+}
+
+// sessionCacheLocked is sessionCache with poolsMu already held.
+func (c *Client) sessionCacheLocked(serverAddr string) *sessionCache {
+	s, ok := c.sessions[serverAddr]
+	if !ok {
+		if c.sessions == nil {
+			c.sessions = make(map[string]*sessionCache)
+		}
+		s = &sessionCache{ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+		c.sessions[serverAddr] = s
+	}
+	return s
+}
+
+// ticketWait is how long inspectTickets waits for session tickets after the
+// handshake; servers send them straight away.
+const ticketWait = time.Second
+
+// ticketInfo is what a server's TLS 1.3 session tickets allow, read from
+// the NewSessionTicket messages it sent after a handshake.
+type ticketInfo struct {
+	version uint16
+	tickets int
+	// lifetime is the longest ticket lifetime the server gave, and
+	// earlyData whether any ticket allows 0-RTT (a max_early_data_size)
+	lifetime  time.Duration
+	earlyData bool
+}
+
+// recordingConn keeps a copy of the first recordLimit bytes read from a
+// connection, so the TLS records can be looked into after the handshake.
+type recordingConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+// recordLimit bounds what a recordingConn keeps: enough for a handshake
+// with a long certificate chain and the tickets that follow.
+const recordLimit = 64 << 10
+
+func (r *recordingConn) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if room := recordLimit - r.buf.Len(); room > 0 {
+		r.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+// inspectTickets completes a TLS handshake over conn, offering alpn, and
+// reads the session tickets the server sends after it. Go's TLS client
+// doesn't expose a ticket's max_early_data_size over TCP, so the
+// application records are decrypted with the traffic secret from the key
+// log to read the NewSessionTicket messages themselves.
+func inspectTickets(ctx context.Context, conn net.Conn, serverName string, alpn []string) (ticketInfo, error) {
+	rec := &recordingConn{Conn: conn}
+	var keyLog bytes.Buffer
+	// Certificates aren't checked, for the same reason as in newHTTPClient.
+	// Without a session cache the client doesn't offer to resume, and
+	// servers then send no tickets.
+	//nolint:gosec // G402: InsecureSkipVerify is intentional for DNS benchmarking
+	tlsConn := tls.Client(rec, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		NextProtos:         alpn,
+		KeyLogWriter:       &keyLog,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	})
+	defer func() { _ = tlsConn.Close() }() // Only the tickets are needed
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return ticketInfo{}, err
+	}
+	state := tlsConn.ConnectionState()
+	info := ticketInfo{version: state.Version}
+	if state.Version != tls.VersionTLS13 {
+		return info, nil
+	}
+
+	// Reading processes the tickets; any application data (an HTTP/2
+	// SETTINGS frame) is of no interest
+	deadline := time.Now().Add(ticketWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = tlsConn.SetReadDeadline(deadline)
+	buf := make([]byte, 4096)
+	for {
+		if _, err := tlsConn.Read(buf); err != nil {
+			break
+		}
+	}
+
+	secret := keyLogSecret(keyLog.Bytes(), "SERVER_TRAFFIC_SECRET_0")
+	if secret == nil {
+		return info, errors.New("no traffic secret in the key log")
+	}
+	aead, iv, err := trafficAEAD(state.CipherSuite, secret)
+	if err != nil {
+		return info, err
+	}
+	for _, msg := range postHandshakeMessages(rec.buf.Bytes(), aead, iv) {
+		lifetime, earlyData, ok := parseNewSessionTicket(msg)
+		if !ok {
+			continue
+		}
+		info.tickets++
+		info.lifetime = max(info.lifetime, lifetime)
+		info.earlyData = info.earlyData || earlyData
+	}
+	return info, nil
+}
+
+// keyLogSecret returns the secret with label from an NSS key log.
+func keyLogSecret(keyLog []byte, label string) []byte {
+	sc := bufio.NewScanner(bytes.NewReader(keyLog))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 3 && fields[0] == label {
+			if secret, err := hex.DecodeString(fields[2]); err == nil {
+				return secret
+			}
+		}
+	}
+	return nil
+}
+
+// trafficAEAD derives the record protection of a TLS 1.3 traffic secret for
+// the cipher suite (RFC 8446, section 7.3).
+func trafficAEAD(suite uint16, secret []byte) (cipher.AEAD, []byte, error) {
+	var h func() hash.Hash
+	var keyLen int
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		h, keyLen = sha256.New, 16
+	case tls.TLS_AES_256_GCM_SHA384:
+		h, keyLen = sha512.New384, 32
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		h, keyLen = sha256.New, chacha20poly1305.KeySize
+	default:
+		return nil, nil, errors.New("unknown TLS 1.3 cipher suite " + tls.CipherSuiteName(suite))
+	}
+	key, err := expandLabel(h, secret, "key", keyLen)
+	if err != nil {
+		return nil, nil, err
+	}
+	iv, err := expandLabel(h, secret, "iv", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	if suite == tls.TLS_CHACHA20_POLY1305_SHA256 {
+		aead, err := chacha20poly1305.New(key)
+		return aead, iv, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, iv, err
+}
+
+// expandLabel is HKDF-Expand-Label with an empty context (RFC 8446,
+// section 7.1).
+func expandLabel(h func() hash.Hash, secret []byte, label string, length int) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint16(uint16(length)) //nolint:gosec // G115: a key or IV length
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes([]byte("tls13 " + label))
+	})
+	b.AddUint8LengthPrefixed(func(*cryptobyte.Builder) {})
+	info, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Expand(h, secret, string(info), length)
+}
+
+// postHandshakeMessages decrypts the application records in the TLS records
+// read from a server and returns the handshake messages among them. The
+// handshake's own encrypted records don't open with the application
+// traffic keys and are skipped; the first record that does is number zero.
+func postHandshakeMessages(records []byte, aead cipher.AEAD, iv []byte) [][]byte {
+	var handshake []byte
+	seq, started := uint64(0), false
+	s := cryptobyte.String(records)
+	for !s.Empty() {
+		var typ uint8
+		var version uint16
+		var body cryptobyte.String
+		header := s
+		if !s.ReadUint8(&typ) || !s.ReadUint16(&version) || !s.ReadUint16LengthPrefixed(&body) {
+			break
+		}
+		if typ != 23 { // application_data, which TLS 1.3 encrypts everything as
+			continue
+		}
+		nonce := slices.Clone(iv)
+		for i := range 8 {
+			nonce[len(nonce)-1-i] ^= byte(seq >> (8 * i))
+		}
+		plain, err := aead.Open(nil, nonce, body, header[:5])
+		if err != nil {
+			if started {
+				break
+			}
+			continue
+		}
+		started = true
+		seq++
+		// The inner content type follows the content, then zero padding
+		plain = bytes.TrimRight(plain, "\x00")
+		if len(plain) > 0 && plain[len(plain)-1] == 22 {
+			handshake = append(handshake, plain[:len(plain)-1]...)
+		}
+	}
+
+	var msgs [][]byte
+	hs := cryptobyte.String(handshake)
+	for !hs.Empty() {
+		var typ uint8
+		var msg cryptobyte.String
+		if !hs.ReadUint8(&typ) || !hs.ReadUint24LengthPrefixed(&msg) {
+			break
+		}
+		if typ == 4 { // new_session_ticket
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// parseNewSessionTicket reads the lifetime of a NewSessionTicket message
+// body and whether it allows early data (RFC 8446, section 4.6.1).
+func parseNewSessionTicket(msg []byte) (lifetime time.Duration, earlyData, ok bool) {
+	s := cryptobyte.String(msg)
+	var seconds, ageAdd uint32
+	var nonce, ticket, extensions cryptobyte.String
+	if !s.ReadUint32(&seconds) || !s.ReadUint32(&ageAdd) ||
+		!s.ReadUint8LengthPrefixed(&nonce) || !s.ReadUint16LengthPrefixed(&ticket) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return 0, false, false
+	}
+	for !extensions.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return 0, false, false
+		}
+		var maxEarlyData uint32
+		if typ == 42 && data.ReadUint32(&maxEarlyData) && maxEarlyData > 0 { // early_data
+			earlyData = true
+		}
+	}
+	return time.Duration(seconds) * time.Second, earlyData, true
+}
+
+// TLSProbeResult is the TLS session resumption probe of one DoT or DoH
+// server.
+type TLSProbeResult struct {
+	Server string
+	// Version is the TLS version negotiated, e.g. "TLS 1.3"
+	Version string
+	// Tickets is set when the server issued session tickets, and Lifetime
+	// is how long it said they last (TLS 1.3 over TCP only)
+	Tickets  bool
+	Lifetime time.Duration
+	// Resumes is set when a new connection with a ticket resumed the
+	// session instead of a full handshake
+	Resumes bool
+	// ZeroRTT is set when the server's tickets allow TLS 1.3 early data
+	// (0-RTT), with which a resumed connection can carry the first query
+	ZeroRTT bool
+	// FullConnect and ResumedConnect are the median connection setup times
+	// (TCP and TLS, or QUIC, handshakes) with a full and a resumed
+	// handshake, and Full and Resumed the median latency of the queries
+	// that opened them
+	FullConnect    time.Duration
+	ResumedConnect time.Duration
+	Full           time.Duration
+	Resumed        time.Duration
+	// Note says why something couldn't be told, e.g. the ticket inspection
+	// failing; Err is set when the server couldn't be queried at all.
+	Note string
+	Err  error
+}
+
+// tlsProbeRounds is the number of full and resumed queries TLSProbe takes
+// the median of.
+const tlsProbeRounds = 3
+
+// median returns the median of ds, zero when empty.
+func median(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	ds = slices.Clone(ds)
+	slices.Sort(ds)
+	return ds[len(ds)/2]
+}
+
+// TLSProbe checks whether a DoT or DoH server supports TLS session
+// resumption and 0-RTT, and measures queries over a new connection with a
+// full handshake against ones that resume the session. A first query warms
+// the server's cache so both see the same answer; then each round opens a
+// full-handshake connection with a client that has no session yet and a
+// resumed one with the ticket it received.
+func (c *Client) TLSProbe(config Config, server, domain string) TLSProbeResult {
+	res := TLSProbeResult{Server: server}
+	target := server
+	if via, ok := config.Via[server]; ok {
+		target = via
+	}
+	config.Servers = []string{target}
+	config.Via = nil
+	config.ConnsPerServer = 0
+	config.NoKeepalive = true
+	config.TLSResume = true
+
+	if warm := newRunClient(config).Measure(target, domain); warm.Error != nil {
+		res.Err = warm.Error
+		return res
+	}
+	var fullConnect, resumedConnect, full, resumed []time.Duration
+	var sessions *sessionCache
+	for range tlsProbeRounds {
+		client := newRunClient(config)
+		first := client.Measure(target, domain)
+		if first.Error != nil {
+			res.Err = first.Error
+			return res
+		}
+		second := client.Measure(target, domain)
+		if second.Error != nil {
+			res.Err = second.Error
+			return res
+		}
+		fullConnect = append(fullConnect, first.Connect)
+		full = append(full, first.Duration)
+		if second.Resumed {
+			res.Resumes = true
+			resumedConnect = append(resumedConnect, second.Connect)
+			resumed = append(resumed, second.Duration)
+		}
+		sessions = client.sessionCache(target)
+	}
+	res.Tickets = sessions.tickets.Load() > 0
+	res.Full, res.FullConnect = median(full), median(fullConnect)
+	res.Resumed, res.ResumedConnect = median(resumed), median(resumedConnect)
+
+	if strings.HasPrefix(target, "h3://") {
+		// QUIC is always TLS 1.3, and its sessions record whether the
+		// ticket allows 0-RTT
+		res.Version = tls.VersionName(tls.VersionTLS13)
+		res.ZeroRTT = sessions.earlyData.Load()
+		return res
+	}
+	info, err := c.inspectServerTickets(target)
+	if err != nil {
+		res.Note = "tickets not inspected: " + err.Error()
+		return res
+	}
+	res.Version = tls.VersionName(info.version)
+	res.Tickets = res.Tickets || info.tickets > 0
+	res.Lifetime = info.lifetime
+	res.ZeroRTT = info.earlyData
+	return res
+}
+
+// inspectServerTickets connects to a DoT or https:// DoH server the way
+// queries do (through the bootstrap resolver and from the interface, if
+// set) and inspects its session tickets.
+func (c *Client) inspectServerTickets(server string) (ticketInfo, error) {
+	var addr, host string
+	alpn := []string{"dot"}
+	if rest, ok := strings.CutPrefix(server, "tls://"); ok {
+		addr = withPort(rest, "853")
+		host, _, _ = net.SplitHostPort(addr)
+	} else {
+		u, err := url.Parse(server)
+		if err != nil {
+			return ticketInfo{}, err
+		}
+		host = u.Hostname()
+		addr = withPort(u.Host, "443")
+		alpn = []string{"h2", "http/1.1"}
+	}
+	serverName := host
+	if _, err := netip.ParseAddr(host); err == nil {
+		serverName = ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout+ticketWait)
+	defer cancel()
+	dial := c.dialContext
+	if c.Bootstrap != "" {
+		dial = c.dialBootstrap
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return ticketInfo{}, err
+	}
+	return inspectTickets(ctx, conn, serverName, alpn)
+}
+
+// RunTLSProbe runs TLSProbe against every DoT and DoH server in config, in
+// parallel, querying the first of config.Domains. Nothing is probed through
+// a proxy.
+func RunTLSProbe(config Config) []TLSProbeResult {
+	if config.Proxy != nil || len(config.Domains) == 0 {
+		return nil
+	}
+	client := &Client{Timeout: config.Timeout, Bootstrap: config.Bootstrap, Interface: config.Interface, SourceIPs: config.SourceIPs}
+
+	var servers []string
+	for _, s := range config.Servers {
+		if strings.HasPrefix(s, "tls://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "h3://") {
+			servers = append(servers, s)
+		}
+	}
+	results := make([]TLSProbeResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = client.TLSProbe(config, server, config.Domains[0])
+		}()
+	}
+	wg.Wait()
+	return results
+}
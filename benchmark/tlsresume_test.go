@@ -0,0 +1,153 @@
+package benchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+func TestMeasureTLSResume(t *testing.T) {
+	dot, _ := startDoTServer(t)
+	doh := startDoHServer(t, 0)
+	doh3 := startDoH3Server(t)
+	for _, tc := range []struct {
+		name   string
+		server string
+		resume bool
+	}{
+		{"DoT", dot, true},
+		{"DoT without resumption", dot, false},
+		{"DoH", doh, true},
+		{"DoH without resumption", doh, false},
+		{"DoH/3", doh3, true},
+	} {
+		client := &Client{Timeout: 2 * time.Second, NoKeepalive: true, TLSResume: tc.resume}
+		first := client.Measure(tc.server, "example.com")
+		second := client.Measure(tc.server, "example.com")
+		if first.Error != nil || second.Error != nil {
+			t.Fatalf("%s: queries failed: %v, %v", tc.name, first.Error, second.Error)
+		}
+		if first.Resumed {
+			t.Errorf("%s: expected a full handshake for the first connection", tc.name)
+		}
+		if second.Resumed != tc.resume {
+			t.Errorf("%s: expected resumed %v for the second connection, got %v", tc.name, tc.resume, second.Resumed)
+		}
+	}
+}
+
+func TestInspectTickets(t *testing.T) {
+	dot, _ := startDoTServer(t)
+	conn, err := net.Dial("tcp", strings.TrimPrefix(dot, "tls://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := inspectTickets(context.Background(), conn, "", []string{"dot"})
+	if err != nil {
+		t.Fatalf("inspection failed: %v", err)
+	}
+	// Go's TLS server issues one ticket for a week, without early data
+	if info.tickets != 1 || info.lifetime != 7*24*time.Hour || info.earlyData {
+		t.Errorf("expected one 7-day ticket without 0-RTT, got %+v", info)
+	}
+}
+
+func TestParseNewSessionTicket(t *testing.T) {
+	ticket := func(maxEarlyData uint32) []byte {
+		var b cryptobyte.Builder
+		b.AddUint32(86400)
+		b.AddUint32(12345)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte{0}) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte("opaque ticket")) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(42)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint32(maxEarlyData) })
+		})
+		return b.BytesOrPanic()
+	}
+	lifetime, earlyData, ok := parseNewSessionTicket(ticket(16384))
+	if !ok || lifetime != 24*time.Hour || !earlyData {
+		t.Errorf("expected a day-long ticket allowing early data, got %v %v %v", lifetime, earlyData, ok)
+	}
+	if _, earlyData, _ := parseNewSessionTicket(ticket(0)); earlyData {
+		t.Error("expected a max_early_data_size of 0 not to allow early data")
+	}
+	if _, _, ok := parseNewSessionTicket([]byte{0, 1}); ok {
+		t.Error("expected a truncated ticket to be rejected")
+	}
+}
+
+func TestPostHandshakeMessages(t *testing.T) {
+	secret := make([]byte, 32)
+	aead, iv, err := trafficAEAD(tls.TLS_AES_128_GCM_SHA256, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(seq uint64, plain []byte) []byte {
+		header := []byte{23, 3, 3, 0, 0}
+		binary.BigEndian.PutUint16(header[3:], uint16(len(plain)+aead.Overhead()))
+		nonce := slices.Clone(iv)
+		for i := range 8 {
+			nonce[len(nonce)-1-i] ^= byte(seq >> (8 * i))
+		}
+		return aead.Seal(header, nonce, plain, header)
+	}
+	var nst cryptobyte.Builder
+	nst.AddUint8(4)
+	nst.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint32(3600)
+		b.AddUint32(0)
+		b.AddUint8LengthPrefixed(func(*cryptobyte.Builder) {})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte("ticket")) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(42)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint32(16384) })
+		})
+	})
+
+	// A handshake record under other keys, then the ticket (inner type 22,
+	// padded) and application data
+	var records []byte
+	records = append(records, 23, 3, 3, 0, 20)
+	records = append(records, make([]byte, 20)...)
+	records = append(records, record(0, append(nst.BytesOrPanic(), 22, 0, 0))...)
+	records = append(records, record(1, []byte("answer\x17"))...)
+
+	msgs := postHandshakeMessages(records, aead, iv)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one NewSessionTicket, got %d", len(msgs))
+	}
+	if lifetime, earlyData, ok := parseNewSessionTicket(msgs[0]); !ok || lifetime != time.Hour || !earlyData {
+		t.Errorf("expected an hour-long ticket allowing early data, got %v %v %v", lifetime, earlyData, ok)
+	}
+}
+
+func TestRunTLSProbe(t *testing.T) {
+	dot, _ := startDoTServer(t)
+	doh3 := startDoH3Server(t)
+	config := Config{Servers: []string{"8.8.8.8", dot, doh3}, Domains: []string{"example.com"}, Timeout: 2 * time.Second}
+	results := RunTLSProbe(config)
+	if len(results) != 2 {
+		t.Fatalf("expected the DoT and DoH/3 servers to be probed, got %+v", results)
+	}
+	// quic-go's HTTP/3 server allows 0-RTT by default, Go's TLS server
+	// never does over TCP
+	for i, r := range results {
+		if zeroRTT := i == 1; r.Err != nil || r.Version != "TLS 1.3" || !r.Tickets || !r.Resumes || r.ZeroRTT != zeroRTT {
+			t.Errorf("%s: expected TLS 1.3 with resumption and 0-RTT %v, got %+v", r.Server, zeroRTT, r)
+		}
+		if r.Full <= 0 || r.Resumed <= 0 {
+			t.Errorf("%s: expected full and resumed query latencies, got %v and %v", r.Server, r.Full, r.Resumed)
+		}
+	}
+	if dot := results[0]; dot.Lifetime != 7*24*time.Hour || dot.FullConnect <= 0 || dot.ResumedConnect <= 0 {
+		t.Errorf("expected the DoT ticket lifetime and both setup times, got %+v", dot)
+	}
+}
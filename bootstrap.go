@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"github.com/miekg/dns"
+)
+
+// systemResolver describes the resolver the OS would use for bootstrap
+// lookups, naming the first nameserver in /etc/resolv.conf when there is one.
+func systemResolver() string {
+	if conf, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(conf.Servers) > 0 {
+		return "system (" + conf.Servers[0] + ")"
+	}
+	return "system"
+}
+
+// printBootstrap resolves the hostname of every DoT and DoH server given by name,
+// the way the benchmark will, and reports which resolver answered and how
+// long it took. Without an explicit bootstrap resolver that lookup depends
+// on the system resolver and lands in the first query to each server.
+func printBootstrap(servers []string, resolver string, timeout time.Duration) {
+	var results []benchmark.BootstrapResult
+	for _, s := range servers {
+		if benchmark.BootstrapHost(s) != "" {
+			results = append(results, benchmark.Bootstrap(s, resolver, timeout))
+		}
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	label := resolver
+	if label == "" {
+		label = systemResolver()
+	}
+	fmt.Printf("\nEncrypted Server Bootstrap (resolving server hostnames via %s)\n\n", label)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tHOST\tTIME\tADDRESSES"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range results {
+		addrs := make([]string, len(r.Addrs))
+		for i, a := range r.Addrs {
+			addrs[i] = a.String()
+		}
+		detail := strings.Join(addrs, ", ")
+		if r.Err != nil {
+			detail = "failed: " + r.Err.Error()
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", r.Server, r.Host, r.Duration, detail); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+	if resolver == "" {
+		fmt.Println("These lookups go through the system resolver during the run too, inside the first query to each server; use -bootstrap to pin them to a known resolver.")
+	} else {
+		fmt.Println("The benchmark resolves these hostnames through the bootstrap resolver once, before the first query, and never asks the system resolver.")
+	}
+	fmt.Println()
+}
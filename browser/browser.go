@@ -2,6 +2,7 @@ package browser
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -9,23 +10,93 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	// Import sqlite driver for database/sql (pure Go, no CGO required)
-	_ "modernc.org/sqlite"
+	"time"
 )
 
+// errSafariUnsupported is returned for Safari anywhere but macOS, where
+// there is no history to read.
+var errSafariUnsupported = errors.New("safari history is only available on macOS; on this OS, use chrome, brave, edge or firefox")
+
+// errNoSQLite is returned by builds without the SQLite driver.
+var errNoSQLite = errors.New("browser history import is not available in this build (built without SQLite support)")
+
 // browserConfig holds the resolved path and query for a browser
 type browserConfig struct {
 	historyPath string
 	query       string
 }
 
+// chromiumHistory returns the History database of the default profile in a
+// Chromium-based browser's user data directory. Opera keeps its profile at
+// the top of the directory rather than in Default, which is tried second.
+func chromiumHistory(dir string) string {
+	path := filepath.Join(dir, "Default", "History")
+	if _, err := os.Stat(path); err != nil {
+		if _, err := os.Stat(filepath.Join(dir, "History")); err == nil {
+			return filepath.Join(dir, "History")
+		}
+	}
+	return path
+}
+
 // GetDomains extracts unique domains from the specified browser's history
 func GetDomains(browserName string, limit int) ([]string, error) {
-	cfg, err := resolveBrowser(browserName)
+	domains, _, err := GetDomainVisits(browserName, limit)
+	return domains, err
+}
+
+// GetDomainVisits is like GetDomains but also returns how often each domain
+// was visited, summed over the history entries that were read.
+func GetDomainVisits(browserName string, limit int) ([]string, map[string]int, error) {
+	h, err := ReadHistory(browserName, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.Domains, h.Visits, nil
+}
+
+// Reasons history entries are left out, the keys of History.Excluded.
+const (
+	ExcludedNoHost      = "no hostname"
+	ExcludedLocalhost   = "localhost"
+	ExcludedIP          = "IP address"
+	ExcludedSingleLabel = "single-label name"
+	ExcludedOverLimit   = "over the domain limit"
+)
+
+// History is what was read from a browser's history: the unique hostnames
+// in the order the browser's query returned them, with visit counts, and
+// what became of the entries that didn't add a hostname.
+type History struct {
+	Domains []string
+	Visits  map[string]int
+	// Entries is the number of history entries (URLs) read; those that are
+	// neither a new domain nor excluded repeat a hostname already listed
+	Entries int
+	// Excluded counts the entries left out, by reason
+	Excluded map[string]int
+}
+
+// ReadHistory reads up to limit unique hostnames from the specified
+// browser's history: its default profile's or, for "name:profile", that of
+// the profile with this ID or name (see Profiles).
+func ReadHistory(browserName string, limit int) (*History, error) {
+	if !haveSQLite {
+		return nil, errNoSQLite
+	}
+
+	name, profile, _ := strings.Cut(browserName, ":")
+	cfg, err := resolveBrowser(name)
 	if err != nil {
 		return nil, err
 	}
+	if profile != "" {
+		p, err := findProfile(name, profile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.historyPath = p.historyPath
+	}
 
 	if cfg.historyPath == "" {
 		return nil, fmt.Errorf("could not locate history file for %s", browserName)
@@ -72,41 +143,46 @@ func GetDomains(browserName string, limit int) ([]string, error) {
 		}
 	}()
 
-	domainSet := make(map[string]struct{})
-	var domains []string
-
+	h := &History{Visits: make(map[string]int), Excluded: make(map[string]int)}
 	for rows.Next() {
 		var rawURL string
-		if err := rows.Scan(&rawURL); err != nil {
+		var count int
+		if err := rows.Scan(&rawURL, &count); err != nil {
 			continue
 		}
+		h.Entries++
 
 		u, err := url.Parse(rawURL)
 		if err != nil || u.Hostname() == "" {
+			h.Excluded[ExcludedNoHost]++
 			continue
 		}
 		host := u.Hostname()
 
 		if host == "localhost" || strings.Contains(host, "127.0.0.1") {
+			h.Excluded[ExcludedLocalhost]++
 			continue
 		}
 		if net.ParseIP(host) != nil {
+			h.Excluded[ExcludedIP]++
 			continue
 		}
 		if !strings.Contains(host, ".") {
+			h.Excluded[ExcludedSingleLabel]++
 			continue
 		}
 
-		if _, exists := domainSet[host]; !exists {
-			domainSet[host] = struct{}{}
-			domains = append(domains, host)
-			if len(domains) >= limit {
-				break
+		if _, exists := h.Visits[host]; !exists {
+			if len(h.Domains) >= limit {
+				h.Excluded[ExcludedOverLimit]++
+				continue
 			}
+			h.Domains = append(h.Domains, host)
 		}
+		h.Visits[host] += count
 	}
 
-	return domains, nil
+	return h, nil
 }
 
 func copyFile(src, dst string) error {
@@ -143,6 +219,127 @@ func copyFile(src, dst string) error {
 	return err
 }
 
+// findFirefoxPlaces returns the places.sqlite of the default Firefox profile
+// under root, the directory holding profiles.ini. Without a usable
+// profiles.ini it falls back to globbing root and root/Profiles (the macOS
+// and Windows layout) for the profile directories Firefox creates.
+func findFirefoxPlaces(root string) (string, error) {
+	if dir, err := defaultFirefoxProfile(filepath.Join(root, "profiles.ini")); err == nil {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		places := filepath.Join(dir, "places.sqlite")
+		if _, err := os.Stat(places); err == nil {
+			return places, nil
+		}
+	}
+	for _, dir := range []string{root, filepath.Join(root, "Profiles")} {
+		if places, err := findFirefoxProfile(dir); err == nil {
+			return places, nil
+		}
+	}
+	return "", fmt.Errorf("could not locate Firefox profile in %s", root)
+}
+
+// findFirefoxPlacesIn returns the places.sqlite of the default profile under
+// the first of roots that has one or, when several do (say a native Firefox
+// that was replaced by its snap), the most recently used of them.
+func findFirefoxPlacesIn(roots []string) (string, error) {
+	var newest string
+	var newestTime time.Time
+	for _, root := range roots {
+		places, err := findFirefoxPlaces(root)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(places)
+		if err != nil {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest, newestTime = places, info.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("could not locate Firefox profile in %s", strings.Join(roots, ", "))
+	}
+	return newest, nil
+}
+
+// defaultFirefoxProfile reads profiles.ini and returns the directory of the
+// default profile: the one the current install uses ([Install...] Default=),
+// else the profile marked Default=1, else the only one. Relative paths are
+// relative to the directory of profiles.ini.
+func defaultFirefoxProfile(iniPath string) (string, error) {
+	profiles, installDefault, err := readFirefoxProfiles(iniPath)
+	if err != nil {
+		return "", err
+	}
+	if installDefault != "" {
+		return installDefault, nil
+	}
+	for _, p := range profiles {
+		if p.isDefault && p.path != "" {
+			return p.path, nil
+		}
+	}
+	if len(profiles) == 1 && profiles[0].path != "" {
+		return profiles[0].path, nil
+	}
+	return "", fmt.Errorf("no default profile in %s", iniPath)
+}
+
+// firefoxProfile is a [Profile...] section of profiles.ini.
+type firefoxProfile struct {
+	name, path string
+	isDefault  bool
+}
+
+// readFirefoxProfiles parses profiles.ini into its profiles and the profile
+// directory the current install uses, if any. Paths in profiles.ini use
+// forward slashes; IsRelative=0 ones are absolute, which callers tell apart
+// with filepath.IsAbs.
+func readFirefoxProfiles(iniPath string) (profiles []*firefoxProfile, installDefault string, err error) {
+	data, err := os.ReadFile(iniPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var section string
+	var cur *firefoxProfile
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			cur = nil
+			if strings.HasPrefix(section, "Profile") {
+				cur = &firefoxProfile{}
+				profiles = append(profiles, cur)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(section, "Install") && key == "Default" && installDefault == "":
+			installDefault = filepath.FromSlash(value)
+		case cur != nil && key == "Name":
+			cur.name = value
+		case cur != nil && key == "Path":
+			cur.path = filepath.FromSlash(value)
+		case cur != nil && key == "Default":
+			cur.isDefault = value == "1"
+		}
+	}
+	return profiles, installDefault, nil
+}
+
 // findFirefoxProfile returns the path to the most likely Firefox places.sqlite
 // by globbing for *.default-release then *.default profiles.
 func findFirefoxProfile(profilesPath string) (string, error) {
@@ -1,6 +1,7 @@
 package browser
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -64,7 +65,7 @@ func TestResolveBrowserFirefox(t *testing.T) {
 // string and ends with "History", regardless of OS.
 func assertChromiumPath(t *testing.T, path, vendor string) {
 	t.Helper()
-	if !strings.Contains(path, vendor) {
+	if !strings.Contains(strings.ToLower(path), strings.ToLower(vendor)) {
 		t.Errorf("expected path to contain %q, got: %s", vendor, path)
 	}
 	if filepath.Base(path) != "History" {
@@ -72,6 +73,27 @@ func assertChromiumPath(t *testing.T, path, vendor string) {
 	}
 }
 
+// writeHistoryDB creates a history database at path with the given
+// statements.
+func writeHistoryDB(t *testing.T, path string, stmts ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // ── GetDomains integration tests ─────────────────────────────────────────────
 
 func TestGetDomainsUnsupportedBrowser(t *testing.T) {
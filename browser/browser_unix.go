@@ -6,9 +6,61 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
+// goos selects the browser data layout; a variable so tests can check the
+// other platform's paths.
+var goos = runtime.GOOS
+
+// browserDirs returns where each browser keeps its profile data on goos:
+// the Chromium-based browsers' user data directories and the directories
+// Firefox may keep profiles.ini in. Arc has no Linux version.
+func browserDirs(home string) (chromium map[string]string, firefox []string) {
+	if goos == "darwin" {
+		support := filepath.Join(home, "Library", "Application Support")
+		return map[string]string{
+			"chrome":   filepath.Join(support, "Google", "Chrome"),
+			"chromium": filepath.Join(support, "Chromium"),
+			"brave":    filepath.Join(support, "BraveSoftware", "Brave-Browser"),
+			"edge":     filepath.Join(support, "Microsoft Edge"),
+			"opera":    filepath.Join(support, "com.operasoftware.Opera"),
+			"vivaldi":  filepath.Join(support, "Vivaldi"),
+			"arc":      filepath.Join(support, "Arc", "User Data"),
+		}, []string{filepath.Join(support, "Firefox")}
+	}
+
+	// Linux and the BSDs follow the XDG base directory spec
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		config = filepath.Join(home, ".config")
+	}
+	return map[string]string{
+		"chrome":   filepath.Join(config, "google-chrome"),
+		"chromium": filepath.Join(config, "chromium"),
+		"brave":    filepath.Join(config, "BraveSoftware", "Brave-Browser"),
+		"edge":     filepath.Join(config, "microsoft-edge"),
+		"opera":    filepath.Join(config, "opera"),
+		"vivaldi":  filepath.Join(config, "vivaldi"),
+	}, []string{
+		filepath.Join(home, ".mozilla", "firefox"),
+		// Snap and Flatpak installs keep their own home
+		filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+		filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+	}
+}
+
+// userBrowserDirs is browserDirs for the current user.
+func userBrowserDirs() (chromium map[string]string, firefox []string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user home dir: %v", err)
+	}
+	chromium, firefox = browserDirs(home)
+	return chromium, firefox, nil
+}
+
 // resolveBrowser returns the history path and SQL query for the given browser
 // on macOS / Linux.
 func resolveBrowser(browserName string) (*browserConfig, error) {
@@ -17,43 +69,35 @@ func resolveBrowser(browserName string) (*browserConfig, error) {
 		return nil, fmt.Errorf("failed to get user home dir: %v", err)
 	}
 
-	const chromiumQuery = "SELECT url FROM urls ORDER BY last_visit_time DESC LIMIT ?"
-	const firefoxQuery = "SELECT url FROM moz_places ORDER BY last_visit_date DESC LIMIT ?"
-
-	switch strings.ToLower(browserName) {
-	case "chrome":
-		return &browserConfig{
-			historyPath: filepath.Join(home, "Library", "Application Support", "Google", "Chrome", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
+	const chromiumQuery = "SELECT url, visit_count FROM urls ORDER BY last_visit_time DESC LIMIT ?"
+	const firefoxQuery = "SELECT url, visit_count FROM moz_places ORDER BY last_visit_date DESC LIMIT ?"
 
-	case "brave":
-		return &browserConfig{
-			historyPath: filepath.Join(home, "Library", "Application Support", "BraveSoftware", "Brave-Browser", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
-
-	case "edge":
-		return &browserConfig{
-			historyPath: filepath.Join(home, "Library", "Application Support", "Microsoft Edge", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
+	chromium, firefox := browserDirs(home)
+	switch name := strings.ToLower(browserName); name {
+	case "chrome", "chromium", "brave", "edge", "opera", "vivaldi", "arc":
+		dir, ok := chromium[name]
+		if !ok {
+			return nil, fmt.Errorf("%s history is not available on %s", name, goos)
+		}
+		return &browserConfig{historyPath: chromiumHistory(dir), query: chromiumQuery}, nil
 
 	case "safari":
+		if goos != "darwin" {
+			return nil, errSafariUnsupported
+		}
 		return &browserConfig{
 			historyPath: filepath.Join(home, "Library", "Safari", "History.db"),
-			query:       "SELECT url FROM history_items ORDER BY visit_count DESC LIMIT ?",
+			query:       "SELECT url, visit_count FROM history_items ORDER BY visit_count DESC LIMIT ?",
 		}, nil
 
 	case "firefox":
-		profilesPath := filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")
-		path, err := findFirefoxProfile(profilesPath)
+		path, err := findFirefoxPlacesIn(firefox)
 		if err != nil {
 			return nil, err
 		}
 		return &browserConfig{historyPath: path, query: firefoxQuery}, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported browser: %s (options: chrome, brave, edge, safari, firefox)", browserName)
+		return nil, fmt.Errorf("unsupported browser: %s (options: chrome, chromium, brave, edge, opera, vivaldi, arc, safari, firefox)", browserName)
 	}
 }
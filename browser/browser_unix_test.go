@@ -0,0 +1,276 @@
+//go:build !windows
+
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetDomainVisits(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	chromium, _ := browserDirs(tmpDir)
+	writeHistoryDB(t, filepath.Join(chromium["chrome"], "Default", "History"),
+		"CREATE TABLE urls (url TEXT, visit_count INTEGER, last_visit_time INTEGER)",
+		"INSERT INTO urls VALUES ('https://example.com/a', 3, 4), ('https://example.com/b', 2, 3), ('https://example.org/', 1, 2), ('http://localhost/', 9, 1)",
+	)
+
+	domains, visits, err := GetDomainVisits("chrome", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(domains) != 2 || visits["example.com"] != 5 || visits["example.org"] != 1 {
+		t.Errorf("expected example.com (5) and example.org (1), got %v %v", domains, visits)
+	}
+
+	h, err := ReadHistory("chrome", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.Entries != 4 || h.Excluded[ExcludedLocalhost] != 1 || h.Excluded[ExcludedOverLimit] != 1 || len(h.Domains) != 1 {
+		t.Errorf("expected 4 entries with localhost and example.org excluded, got %+v", h)
+	}
+}
+
+func TestBrowserPathsByOS(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	for _, tc := range []struct {
+		goos, browser, want string
+	}{
+		{"linux", "chrome", filepath.Join(home, ".config", "google-chrome", "Default", "History")},
+		{"linux", "chromium", filepath.Join(home, ".config", "chromium", "Default", "History")},
+		{"linux", "brave", filepath.Join(home, ".config", "BraveSoftware", "Brave-Browser", "Default", "History")},
+		{"darwin", "chrome", filepath.Join(home, "Library", "Application Support", "Google", "Chrome", "Default", "History")},
+		{"darwin", "edge", filepath.Join(home, "Library", "Application Support", "Microsoft Edge", "Default", "History")},
+		{"linux", "vivaldi", filepath.Join(home, ".config", "vivaldi", "Default", "History")},
+		{"darwin", "vivaldi", filepath.Join(home, "Library", "Application Support", "Vivaldi", "Default", "History")},
+		{"darwin", "arc", filepath.Join(home, "Library", "Application Support", "Arc", "User Data", "Default", "History")},
+	} {
+		goos = tc.goos
+		cfg, err := resolveBrowser(tc.browser)
+		if err != nil {
+			t.Fatalf("%s on %s: %v", tc.browser, tc.goos, err)
+		}
+		if cfg.historyPath != tc.want {
+			t.Errorf("%s on %s: expected %s, got %s", tc.browser, tc.goos, tc.want, cfg.historyPath)
+		}
+	}
+
+	goos = "linux"
+	if _, err := resolveBrowser("arc"); err == nil || !strings.Contains(err.Error(), "not available on linux") {
+		t.Errorf("expected arc to be unavailable on Linux, got %v", err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	if cfg, err := resolveBrowser("chrome"); err != nil || !strings.HasPrefix(cfg.historyPath, filepath.Join(home, "xdg")) {
+		t.Errorf("expected XDG_CONFIG_HOME to be honoured, got %v %v", cfg, err)
+	}
+}
+
+func TestGetDomainVisitsOpera(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	goos = "linux"
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	// Opera keeps its history at the top of its directory, not in Default
+	writeHistoryDB(t, filepath.Join(home, ".config", "opera", "History"),
+		"CREATE TABLE urls (url TEXT, visit_count INTEGER, last_visit_time INTEGER)",
+		"INSERT INTO urls VALUES ('https://example.com/', 2, 1)",
+	)
+	domains, visits, err := GetDomainVisits("opera", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(domains) != 1 || visits["example.com"] != 2 {
+		t.Errorf("expected example.com (2), got %v %v", domains, visits)
+	}
+}
+
+func TestGetDomainVisitsFirefoxLinux(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	goos = "linux"
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	root := filepath.Join(home, ".mozilla", "firefox")
+	// An older default profile next to the one the install uses
+	writeHistoryDB(t, filepath.Join(root, "old.default", "places.sqlite"),
+		"CREATE TABLE moz_places (url TEXT, visit_count INTEGER, last_visit_date INTEGER)",
+		"INSERT INTO moz_places VALUES ('https://stale.example/', 1, 1)",
+	)
+	writeHistoryDB(t, filepath.Join(root, "abcd1234.default-release", "places.sqlite"),
+		"CREATE TABLE moz_places (url TEXT, visit_count INTEGER, last_visit_date INTEGER)",
+		"INSERT INTO moz_places VALUES ('https://example.net/x', 4, 2), ('https://example.net/y', 1, 1)",
+	)
+	ini := `[Install4F96D1932A9F858E]
+Default=abcd1234.default-release
+Locked=1
+
+[Profile1]
+Name=default
+IsRelative=1
+Path=old.default
+Default=1
+
+[Profile0]
+Name=default-release
+IsRelative=1
+Path=abcd1234.default-release
+
+[General]
+StartWithLastProfile=1
+Version=2
+`
+	if err := os.WriteFile(filepath.Join(root, "profiles.ini"), []byte(ini), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	domains, visits, err := GetDomainVisits("firefox", 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(domains) != 1 || visits["example.net"] != 5 {
+		t.Errorf("expected example.net (5) from the install's default profile, got %v %v", domains, visits)
+	}
+}
+
+func TestDefaultFirefoxProfile(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct{ ini, want string }{
+		"default flag":  {"[Profile0]\nPath=a.default\n\n[Profile1]\nPath=b.default\nDefault=1\n", "b.default"},
+		"only profile":  {"[Profile0]\nName=x\nPath=Profiles/x.default\n", filepath.Join("Profiles", "x.default")},
+		"absolute path": {"[Profile0]\nIsRelative=0\nPath=/data/ff\nDefault=1\n", filepath.FromSlash("/data/ff")},
+		"no default":    {"[Profile0]\nPath=a\n\n[Profile1]\nPath=b\n", ""},
+	} {
+		path := filepath.Join(dir, "profiles.ini")
+		if err := os.WriteFile(path, []byte(tc.ini), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := defaultFirefoxProfile(path)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", name, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: expected %s, got %s (%v)", name, tc.want, got, err)
+		}
+	}
+}
+
+func TestFirefoxSnapAndFlatpak(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	goos = "linux"
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	snap := filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox", "x.default-release", "places.sqlite")
+	writeHistoryDB(t, snap, "CREATE TABLE moz_places (url TEXT)")
+	cfg, err := resolveBrowser("firefox")
+	if err != nil || cfg.historyPath != snap {
+		t.Fatalf("expected the snap profile %s, got %v %v", snap, cfg, err)
+	}
+
+	// A native profile left behind from before the snap is older
+	native := filepath.Join(home, ".mozilla", "firefox", "y.default", "places.sqlite")
+	writeHistoryDB(t, native, "CREATE TABLE moz_places (url TEXT)")
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(native, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := resolveBrowser("firefox"); err != nil || cfg.historyPath != snap {
+		t.Errorf("expected the more recently used snap profile, got %v %v", cfg, err)
+	}
+
+	flatpak := filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox", "z.default-release", "places.sqlite")
+	writeHistoryDB(t, flatpak, "CREATE TABLE moz_places (url TEXT)")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(flatpak, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := resolveBrowser("firefox"); err != nil || cfg.historyPath != flatpak {
+		t.Errorf("expected the flatpak profile, got %v %v", cfg, err)
+	}
+}
+
+func TestSafariUnsupportedOffMacOS(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	goos = "linux"
+	_, err := GetDomains("safari", 10)
+	if err == nil || !strings.Contains(err.Error(), "only available on macOS") {
+		t.Errorf("expected a clear unsupported error, got %v", err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	defer func(orig string) { goos = orig }(goos)
+	goos = "linux"
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	chrome := filepath.Join(home, ".config", "google-chrome")
+	const schema = "CREATE TABLE urls (url TEXT, visit_count INTEGER, last_visit_time INTEGER)"
+	writeHistoryDB(t, filepath.Join(chrome, "Default", "History"), schema,
+		"INSERT INTO urls VALUES ('https://home.example/', 1, 1)")
+	writeHistoryDB(t, filepath.Join(chrome, "Profile 2", "History"), schema,
+		"INSERT INTO urls VALUES ('https://intranet.example/', 3, 1)")
+	writeHistoryDB(t, filepath.Join(chrome, "Guest Profile", "History"), schema)
+	state := `{"profile": {"info_cache": {"Default": {"name": "Personal"}, "Profile 2": {"name": "Work"}}}}`
+	if err := os.WriteFile(filepath.Join(chrome, "Local State"), []byte(state), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := Profiles("chrome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || !profiles[0].Default || profiles[0].String() != "Personal (Default)" || profiles[1].String() != "Work (Profile 2)" {
+		t.Errorf("expected Personal (Default) then Work (Profile 2), got %v", profiles)
+	}
+
+	for _, spec := range []string{"chrome:Work", "chrome:profile 2"} {
+		h, err := ReadHistory(spec, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if len(h.Domains) != 1 || h.Domains[0] != "intranet.example" {
+			t.Errorf("%s: expected the work profile's history, got %v", spec, h.Domains)
+		}
+	}
+	if _, err := ReadHistory("chrome:School", 10); err == nil || !strings.Contains(err.Error(), "Work (Profile 2)") {
+		t.Errorf("expected an error listing the profiles, got %v", err)
+	}
+
+	root := filepath.Join(home, ".mozilla", "firefox")
+	writeHistoryDB(t, filepath.Join(root, "a.default-release", "places.sqlite"), "CREATE TABLE moz_places (url TEXT)")
+	writeHistoryDB(t, filepath.Join(root, "b.work", "places.sqlite"), "CREATE TABLE moz_places (url TEXT)")
+	ini := "[Profile0]\nName=default-release\nPath=a.default-release\nDefault=1\n\n[Profile1]\nName=work\nPath=b.work\n"
+	if err := os.WriteFile(filepath.Join(root, "profiles.ini"), []byte(ini), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err = Profiles("firefox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0].ID != "a.default-release" || !profiles[0].Default || profiles[1].Name != "work" {
+		t.Errorf("expected the default-release profile then work, got %+v", profiles)
+	}
+
+	goos = "darwin"
+	if _, err := Profiles("safari"); err == nil || !strings.Contains(err.Error(), "no profiles") {
+		t.Errorf("expected safari to have no profiles, got %v", err)
+	}
+}
@@ -9,13 +9,9 @@ import (
 	"strings"
 )
 
-// resolveBrowser returns the history path and SQL query for the given browser
-// on Windows.
-//
-// Chrome, Brave, Edge, and Opera all use the Chromium engine and store history
-// at %LOCALAPPDATA%\<vendor>\<app>\User Data\Default\History.
-// Firefox uses %APPDATA%\Mozilla\Firefox\Profiles\<profile>\places.sqlite.
-func resolveBrowser(browserName string) (*browserConfig, error) {
+// userBrowserDirs returns the Chromium-based browsers' user data
+// directories and the directories Firefox may keep profiles.ini in.
+func userBrowserDirs() (chromium map[string]string, firefox []string, err error) {
 	localAppData := os.Getenv("LOCALAPPDATA")
 	appData := os.Getenv("APPDATA")
 
@@ -23,7 +19,7 @@ func resolveBrowser(browserName string) (*browserConfig, error) {
 		// Fall back to UserHomeDir-relative paths for non-standard setups
 		home, err := os.UserHomeDir()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get user home dir: %v", err)
+			return nil, nil, fmt.Errorf("failed to get user home dir: %v", err)
 		}
 		if localAppData == "" {
 			localAppData = filepath.Join(home, "AppData", "Local")
@@ -33,43 +29,59 @@ func resolveBrowser(browserName string) (*browserConfig, error) {
 		}
 	}
 
-	const chromiumQuery = "SELECT url FROM urls ORDER BY last_visit_time DESC LIMIT ?"
-	const firefoxQuery = "SELECT url FROM moz_places ORDER BY last_visit_date DESC LIMIT ?"
+	// Arc, like Store apps generally, lives in a package directory
+	arc := filepath.Join(localAppData, "Packages", "TheBrowserCompany.Arc_*", "LocalCache", "Local", "Arc", "User Data")
+	if packages, _ := filepath.Glob(arc); len(packages) > 0 {
+		arc = packages[0]
+	}
+	chromium = map[string]string{
+		"chrome":   filepath.Join(localAppData, "Google", "Chrome", "User Data"),
+		"chromium": filepath.Join(localAppData, "Chromium", "User Data"),
+		"brave":    filepath.Join(localAppData, "BraveSoftware", "Brave-Browser", "User Data"),
+		"edge":     filepath.Join(localAppData, "Microsoft", "Edge", "User Data"),
+		"vivaldi":  filepath.Join(localAppData, "Vivaldi", "User Data"),
+		"arc":      arc,
+		"opera":    filepath.Join(appData, "Opera Software", "Opera Stable"),
+	}
 
-	switch strings.ToLower(browserName) {
-	case "chrome":
-		return &browserConfig{
-			historyPath: filepath.Join(localAppData, "Google", "Chrome", "User Data", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
+	// So do Microsoft Store installs of Firefox
+	firefox = []string{filepath.Join(appData, "Mozilla", "Firefox")}
+	packages, _ := filepath.Glob(filepath.Join(localAppData, "Packages", "Mozilla.Firefox_*", "LocalCache", "Roaming", "Mozilla", "Firefox"))
+	return chromium, append(firefox, packages...), nil
+}
 
-	case "brave":
-		return &browserConfig{
-			historyPath: filepath.Join(localAppData, "BraveSoftware", "Brave-Browser", "User Data", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
+// resolveBrowser returns the history path and SQL query for the given browser
+// on Windows.
+//
+// Chrome, Chromium, Brave, Edge, Vivaldi and Arc all use the Chromium engine
+// and store history at %LOCALAPPDATA%\<vendor>\<app>\User Data\Default\History
+// (Arc inside its Store package); Opera under %APPDATA%\Opera Software.
+// Firefox uses the default profile in %APPDATA%\Mozilla\Firefox\profiles.ini,
+// usually %APPDATA%\Mozilla\Firefox\Profiles\<profile>\places.sqlite.
+func resolveBrowser(browserName string) (*browserConfig, error) {
+	chromium, firefox, err := userBrowserDirs()
+	if err != nil {
+		return nil, err
+	}
 
-	case "edge":
-		return &browserConfig{
-			historyPath: filepath.Join(localAppData, "Microsoft", "Edge", "User Data", "Default", "History"),
-			query:       chromiumQuery,
-		}, nil
+	const chromiumQuery = "SELECT url, visit_count FROM urls ORDER BY last_visit_time DESC LIMIT ?"
+	const firefoxQuery = "SELECT url, visit_count FROM moz_places ORDER BY last_visit_date DESC LIMIT ?"
 
-	case "opera":
-		return &browserConfig{
-			historyPath: filepath.Join(appData, "Opera Software", "Opera Stable", "History"),
-			query:       chromiumQuery,
-		}, nil
+	switch name := strings.ToLower(browserName); name {
+	case "chrome", "chromium", "brave", "edge", "vivaldi", "arc", "opera":
+		return &browserConfig{historyPath: chromiumHistory(chromium[name]), query: chromiumQuery}, nil
 
 	case "firefox":
-		profilesPath := filepath.Join(appData, "Mozilla", "Firefox", "Profiles")
-		path, err := findFirefoxProfile(profilesPath)
+		path, err := findFirefoxPlacesIn(firefox)
 		if err != nil {
 			return nil, err
 		}
 		return &browserConfig{historyPath: path, query: firefoxQuery}, nil
 
+	case "safari":
+		return nil, errSafariUnsupported
+
 	default:
-		return nil, fmt.Errorf("unsupported browser: %s (options: chrome, brave, edge, opera, firefox)", browserName)
+		return nil, fmt.Errorf("unsupported browser: %s (options: chrome, chromium, brave, edge, opera, vivaldi, arc, firefox)", browserName)
 	}
 }
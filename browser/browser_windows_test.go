@@ -0,0 +1,67 @@
+//go:build windows
+
+package browser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBrowserPathsWindows(t *testing.T) {
+	local, roaming := t.TempDir(), t.TempDir()
+	t.Setenv("LOCALAPPDATA", local)
+	t.Setenv("APPDATA", roaming)
+
+	for name, want := range map[string]string{
+		"chrome":   filepath.Join(local, "Google", "Chrome", "User Data", "Default", "History"),
+		"chromium": filepath.Join(local, "Chromium", "User Data", "Default", "History"),
+		"brave":    filepath.Join(local, "BraveSoftware", "Brave-Browser", "User Data", "Default", "History"),
+		"edge":     filepath.Join(local, "Microsoft", "Edge", "User Data", "Default", "History"),
+		"opera":    filepath.Join(roaming, "Opera Software", "Opera Stable", "Default", "History"),
+		"vivaldi":  filepath.Join(local, "Vivaldi", "User Data", "Default", "History"),
+	} {
+		cfg, err := resolveBrowser(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.historyPath != want {
+			t.Errorf("%s: expected %s, got %s", name, want, cfg.historyPath)
+		}
+	}
+}
+
+func TestArcWindowsStorePackage(t *testing.T) {
+	local := t.TempDir()
+	t.Setenv("LOCALAPPDATA", local)
+	t.Setenv("APPDATA", t.TempDir())
+
+	history := filepath.Join(local, "Packages", "TheBrowserCompany.Arc_ttt1ap7aakyb4", "LocalCache", "Local", "Arc", "User Data", "Default", "History")
+	writeHistoryDB(t, history, "CREATE TABLE urls (url TEXT)")
+	cfg, err := resolveBrowser("arc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.historyPath != history {
+		t.Errorf("expected %s, got %s", history, cfg.historyPath)
+	}
+}
+
+func TestFirefoxWindowsStorePackage(t *testing.T) {
+	local, roaming := t.TempDir(), t.TempDir()
+	t.Setenv("LOCALAPPDATA", local)
+	t.Setenv("APPDATA", roaming)
+
+	if _, err := resolveBrowser("firefox"); err == nil {
+		t.Fatal("expected an error with no Firefox profile")
+	}
+
+	places := filepath.Join(local, "Packages", "Mozilla.Firefox_n80bbvh6b1yt2", "LocalCache", "Roaming", "Mozilla", "Firefox", "Profiles", "abc.default-release", "places.sqlite")
+	writeHistoryDB(t, places, "CREATE TABLE moz_places (url TEXT)")
+	cfg, err := resolveBrowser("firefox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.historyPath != places {
+		t.Errorf("expected %s, got %s", places, cfg.historyPath)
+	}
+}
@@ -0,0 +1,154 @@
+package browser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile is one of a browser's profiles, as listed by Profiles.
+type Profile struct {
+	// ID is the profile's directory name (e.g. "Profile 2" or
+	// "abcd1234.default-release") and Name what the browser calls it (e.g.
+	// "Work"); either selects the profile in ReadHistory's name:profile
+	ID   string
+	Name string
+	// Default is set for the profile read when none is selected
+	Default bool
+
+	historyPath string
+}
+
+// String names the profile the way Profiles lists it: "Work (Profile 2)".
+func (p Profile) String() string {
+	if p.Name == p.ID {
+		return p.ID
+	}
+	return fmt.Sprintf("%s (%s)", p.Name, p.ID)
+}
+
+// Profiles lists the browser's profiles that have a history database, the
+// default one first.
+func Profiles(browserName string) ([]Profile, error) {
+	chromium, firefox, err := userBrowserDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	name := strings.ToLower(browserName)
+	if dir, ok := chromium[name]; ok {
+		profiles = chromiumProfiles(dir)
+	} else if name == "firefox" {
+		profiles = firefoxProfiles(firefox)
+	} else {
+		// resolveBrowser explains browsers that are unknown or unavailable
+		// here; Safari has a single history
+		if _, err := resolveBrowser(browserName); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s has no profiles to choose from", browserName)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no %s profiles with history found", browserName)
+	}
+	sort.SliceStable(profiles, func(i, j int) bool { return profiles[i].Default && !profiles[j].Default })
+	return profiles, nil
+}
+
+// findProfile returns the browser's profile whose ID or name is profile,
+// ignoring case.
+func findProfile(browserName, profile string) (*Profile, error) {
+	profiles, err := Profiles(browserName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		if strings.EqualFold(p.ID, profile) || strings.EqualFold(p.Name, profile) {
+			return &profiles[i], nil
+		}
+		names[i] = p.String()
+	}
+	return nil, fmt.Errorf("no %s profile %q (profiles: %s)", browserName, profile, strings.Join(names, ", "))
+}
+
+// chromiumProfiles lists the profiles in a Chromium user data directory,
+// named from its Local State file. Opera keeps its single profile at the
+// top of the directory, which then stands for the profile.
+func chromiumProfiles(dir string) []Profile {
+	var state struct {
+		Profile struct {
+			InfoCache map[string]struct {
+				Name string `json:"name"`
+			} `json:"info_cache"`
+		} `json:"profile"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Local State")); err == nil {
+		// Without it, profiles are only listed by directory
+		_ = json.Unmarshal(data, &state)
+	}
+
+	def := chromiumHistory(dir)
+	histories, _ := filepath.Glob(filepath.Join(dir, "*", "History"))
+	if _, err := os.Stat(filepath.Join(dir, "History")); err == nil {
+		histories = append(histories, filepath.Join(dir, "History"))
+	}
+	var profiles []Profile
+	for _, path := range histories {
+		id := filepath.Base(filepath.Dir(path))
+		if id == "System Profile" || id == "Guest Profile" {
+			continue
+		}
+		profiles = append(profiles, Profile{
+			ID:          id,
+			Name:        cmp.Or(state.Profile.InfoCache[id].Name, id),
+			Default:     path == def,
+			historyPath: path,
+		})
+	}
+	return profiles
+}
+
+// firefoxProfiles lists the Firefox profiles under roots, from each root's
+// profiles.ini or, without one, the profile directories found there.
+func firefoxProfiles(roots []string) []Profile {
+	def, _ := findFirefoxPlacesIn(roots)
+	var profiles []Profile
+	add := func(name, dir string) {
+		places := filepath.Join(dir, "places.sqlite")
+		if _, err := os.Stat(places); err != nil {
+			return
+		}
+		profiles = append(profiles, Profile{
+			ID:          filepath.Base(dir),
+			Name:        cmp.Or(name, filepath.Base(dir)),
+			Default:     places == def,
+			historyPath: places,
+		})
+	}
+	for _, root := range roots {
+		list, _, err := readFirefoxProfiles(filepath.Join(root, "profiles.ini"))
+		if err != nil {
+			for _, pattern := range []string{filepath.Join(root, "*", "places.sqlite"), filepath.Join(root, "Profiles", "*", "places.sqlite")} {
+				matches, _ := filepath.Glob(pattern)
+				for _, places := range matches {
+					add("", filepath.Dir(places))
+				}
+			}
+			continue
+		}
+		for _, p := range list {
+			dir := p.path
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			add(p.name, dir)
+		}
+	}
+	return profiles
+}
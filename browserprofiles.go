@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"dns-bench/browser"
+)
+
+// printBrowserProfiles lists a browser's profiles for -browser-profiles,
+// with what to pass to -browser-profile to read each.
+func printBrowserProfiles(name string, profiles []browser.Profile) {
+	fmt.Printf("\n%s profiles (select one with -browser-profile)\n\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "PROFILE\tNAME\t"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, p := range profiles {
+		note := ""
+		if p.Default {
+			note = "default"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", p.ID, p.Name, note); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// cacheStats splits a server's successful lookups into the first one for
+// each domain (likely a cache miss somewhere along the path) and repeats of
+// a domain already answered (likely served from the resolver's cache).
+// Results tagged by -cold-warm say which they are; otherwise they arrive in
+// completion order, which with several iterations puts each domain's first
+// query first.
+type cacheStats struct {
+	AvgCold time.Duration
+	AvgWarm time.Duration
+
+	seen                 map[string]bool
+	cold, warm           time.Duration
+	coldCount, warmCount int
+}
+
+func (c *cacheStats) add(res benchmark.Result) {
+	switch res.CachePass {
+	case benchmark.CacheCold:
+		c.cold += res.Duration
+		c.coldCount++
+		return
+	case benchmark.CacheWarm:
+		c.warm += res.Duration
+		c.warmCount++
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	// Cache-busting queries a new name every time, so nothing is a repeat
+	name := cmp.Or(res.QueryName, res.Domain)
+	if c.seen[name] {
+		c.warm += res.Duration
+		c.warmCount++
+		return
+	}
+	c.seen[name] = true
+	c.cold += res.Duration
+	c.coldCount++
+}
+
+func (c *cacheStats) finish() {
+	if c.coldCount > 0 {
+		c.AvgCold = c.cold / time.Duration(c.coldCount)
+	}
+	if c.warmCount > 0 {
+		c.AvgWarm = c.warm / time.Duration(c.warmCount)
+	}
+}
+
+// speedup is how many times faster warm lookups are than cold ones, or zero
+// without both.
+func (c *cacheStats) speedup() float64 {
+	if c.AvgCold == 0 || c.AvgWarm == 0 {
+		return 0
+	}
+	return float64(c.AvgCold) / float64(c.AvgWarm)
+}
+
+// printColdWarm shows each server's latency for the cold and warm query of
+// every -cold-warm pair, and what a cache hit saves.
+func printColdWarm(stats []*ServerStats) {
+	fmt.Printf("\nCold vs. Warm Cache (each domain queried twice in a row)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tCOLD AVG\tWARM AVG\tCACHE HIT SAVES\tSPEEDUP"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		saves, speedup := "-", "-"
+		if x := s.Cache.speedup(); x > 0 {
+			saves = (s.Cache.AvgCold - s.Cache.AvgWarm).String()
+			speedup = fmt.Sprintf("%.1fx", x)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Server, orDash(s.Cache.AvgCold), orDash(s.Cache.AvgWarm), saves, speedup); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestCacheStatsColdWarmPairs(t *testing.T) {
+	// Tagged results are split by their tag, not by whether the domain was
+	// seen before
+	results := []benchmark.Result{
+		{Server: "s", Domain: "a.example", Duration: 40 * time.Millisecond, CachePass: benchmark.CacheCold},
+		{Server: "s", Domain: "a.example", Duration: 2 * time.Millisecond, CachePass: benchmark.CacheWarm},
+		{Server: "s", Domain: "a.example", Duration: 20 * time.Millisecond, CachePass: benchmark.CacheCold},
+		{Server: "s", Domain: "a.example", Duration: 6 * time.Millisecond, CachePass: benchmark.CacheWarm},
+	}
+	s := calculateStats(results)[0]
+	if s.Cache.AvgCold != 30*time.Millisecond || s.Cache.AvgWarm != 4*time.Millisecond {
+		t.Errorf("expected 30ms cold and 4ms warm, got %v and %v", s.Cache.AvgCold, s.Cache.AvgWarm)
+	}
+	if x := s.Cache.speedup(); x != 7.5 {
+		t.Errorf("expected a 7.5x speedup, got %v", x)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dns-bench/validation"
+)
+
+// CatalogURL and CatalogKey are where -update-catalog fetches the provider
+// catalog from and the base64 Ed25519 public key its signature must verify
+// with. They are set at build time with -ldflags "-X main.CatalogURL=...",
+// and can be overridden with catalog_url and catalog_key in the config.
+var (
+	CatalogURL string
+	CatalogKey string
+)
+
+// builtinCatalogVersion is the version of the catalog compiled in
+// (knownProviders and defaultServers); a fetched catalog is only used when
+// it is newer.
+const builtinCatalogVersion = 1
+
+// catalogFile is the provider catalog as published: the public resolvers
+// dns-bench recognises (for the leak test and baselines) and the servers it
+// benchmarks when none are given. It is signed as a whole, with the
+// signature published next to it at the same URL plus ".sig".
+type catalogFile struct {
+	Version        int               `json:"version"`
+	Generated      time.Time         `json:"generated"`
+	DefaultServers []string          `json:"default_servers,omitempty"`
+	Providers      []catalogProvider `json:"providers"`
+}
+
+// catalogProvider is one provider of a catalogFile (see dnsProvider).
+type catalogProvider struct {
+	Name   string   `json:"name"`
+	Hosts  []string `json:"hosts"`
+	Egress []string `json:"egress,omitempty"`
+}
+
+// catalogPath is where the last verified catalog is kept, or "" when there
+// is no user cache directory.
+func catalogPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-bench", "catalog.json")
+}
+
+// parseCatalogKey decodes a base64 Ed25519 public key.
+func parseCatalogKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid catalog key (expected a base64 Ed25519 public key)")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// verifyCatalog checks sig (base64) over data with key and parses the
+// catalog, making sure every server and range in it is valid.
+func verifyCatalog(data, sig []byte, key ed25519.PublicKey) (*catalogFile, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(key, data, raw) {
+		return nil, errors.New("catalog signature doesn't verify")
+	}
+	var file catalogFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	if len(file.Providers) == 0 {
+		return nil, errors.New("invalid catalog: no providers")
+	}
+	for _, server := range file.DefaultServers {
+		if err := validation.IsValidServer(server); err != nil {
+			return nil, fmt.Errorf("invalid catalog server %s: %w", server, err)
+		}
+	}
+	if _, err := file.providers(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// providers converts the catalog's providers to the form the leak test and
+// baselines use.
+func (f *catalogFile) providers() ([]dnsProvider, error) {
+	providers := make([]dnsProvider, len(f.Providers))
+	for i, p := range f.Providers {
+		if p.Name == "" || len(p.Hosts) == 0 {
+			return nil, fmt.Errorf("invalid catalog: provider %d needs a name and hosts", i+1)
+		}
+		providers[i] = dnsProvider{Name: p.Name, Hosts: p.Hosts}
+		for _, r := range p.Egress {
+			prefix, err := netip.ParsePrefix(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid catalog: provider %s: %w", p.Name, err)
+			}
+			providers[i].Egress = append(providers[i].Egress, prefix)
+		}
+	}
+	return providers, nil
+}
+
+// builtinCatalog returns the compiled-in catalog in its published form, as
+// a starting point for a new one.
+func builtinCatalog() catalogFile {
+	file := catalogFile{Version: builtinCatalogVersion, DefaultServers: defaultServers}
+	for _, p := range knownProviders {
+		cp := catalogProvider{Name: p.Name, Hosts: p.Hosts}
+		for _, r := range p.Egress {
+			cp.Egress = append(cp.Egress, r.String())
+		}
+		file.Providers = append(file.Providers, cp)
+	}
+	return file
+}
+
+// fetchCatalogFile downloads rawURL for -update-catalog.
+func fetchCatalogFile(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
+
+// updateCatalog fetches the catalog at rawURL and its signature, verifies
+// them with key and keeps them at path when the catalog is newer than the
+// one in use. It returns the catalog and whether it was newer.
+func updateCatalog(rawURL, key, path string) (*catalogFile, bool, error) {
+	if rawURL == "" {
+		return nil, false, errors.New("no catalog URL: this build has none compiled in, set catalog_url in the config or -catalog-url")
+	}
+	if key == "" {
+		return nil, false, errors.New("no key to verify the catalog with: this build has none compiled in, set catalog_key in the config or -catalog-key")
+	}
+	if path == "" {
+		return nil, false, errors.New("no cache directory to keep the catalog in")
+	}
+	pub, err := parseCatalogKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := fetchCatalogFile(rawURL)
+	if err != nil {
+		return nil, false, err
+	}
+	sig, err := fetchCatalogFile(rawURL + ".sig")
+	if err != nil {
+		return nil, false, err
+	}
+	file, err := verifyCatalog(data, sig, pub)
+	if err != nil {
+		return nil, false, err
+	}
+
+	current := builtinCatalogVersion
+	if cached, err := readCatalog(path, pub); err == nil {
+		current = max(current, cached.Version)
+	}
+	if file.Version <= current {
+		return file, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path+".sig", sig, 0o644); err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+// readCatalog reads and verifies the catalog kept at path.
+func readCatalog(path string, key ed25519.PublicKey) (*catalogFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return nil, err
+	}
+	return verifyCatalog(data, sig, key)
+}
+
+// applyCatalog switches to the catalog fetched by -update-catalog, if
+// there is one newer than the built-in catalog that still verifies with
+// key. It returns the version in use.
+func applyCatalog(path, key string, warns *warningLog) int {
+	if path == "" {
+		return builtinCatalogVersion
+	}
+	if _, err := os.Stat(path); err != nil {
+		return builtinCatalogVersion
+	}
+	pub, err := parseCatalogKey(key)
+	if err != nil {
+		warns.add("catalog", "ignoring the updated catalog at %s: %v", path, err)
+		return builtinCatalogVersion
+	}
+	file, err := readCatalog(path, pub)
+	if err != nil {
+		warns.add("catalog", "ignoring the updated catalog at %s: %v", path, err)
+		return builtinCatalogVersion
+	}
+	if file.Version <= builtinCatalogVersion {
+		return builtinCatalogVersion
+	}
+	providers, err := file.providers()
+	if err != nil {
+		warns.add("catalog", "ignoring the updated catalog at %s: %v", path, err)
+		return builtinCatalogVersion
+	}
+	knownProviders = providers
+	if len(file.DefaultServers) > 0 {
+		defaultServers = file.DefaultServers
+	}
+	return file.Version
+}
+
+// runCatalog implements the catalog subcommand, which helps publish a
+// provider catalog: export writes the built-in one as a starting point,
+// keygen creates a signing key pair and sign signs a catalog file.
+func runCatalog(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s catalog export [-o catalog.json] | keygen [-o catalog.key] | sign -key catalog.key <catalog.json>\n", os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("expected export, keygen or sign")
+	}
+	fs := flag.NewFlagSet("catalog "+args[0], flag.ExitOnError)
+	var output, keyFile string
+	fs.Usage = usage
+	switch args[0] {
+	case "export":
+		fs.StringVar(&output, "o", "catalog.json", "Output catalog file")
+	case "keygen":
+		fs.StringVar(&output, "o", "catalog.key", "Output private key file (keep it secret)")
+	case "sign":
+		fs.StringVar(&keyFile, "key", "", "Private key file written by catalog keygen")
+	default:
+		usage()
+		return fmt.Errorf("unknown catalog command %q", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "export":
+		data, err := json.MarshalIndent(builtinCatalog(), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Built-in catalog (version %d) written to %s\n", builtinCatalogVersion, output)
+	case "keygen":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, []byte(base64.StdEncoding.EncodeToString(priv)+"\n"), 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("Private key written to %s\nPublic key (catalog_key): %s\n", output, base64.StdEncoding.EncodeToString(pub))
+	case "sign":
+		if keyFile == "" || fs.NArg() != 1 {
+			usage()
+			return errors.New("expected -key and one catalog file")
+		}
+		encoded, err := os.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		priv, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || len(priv) != ed25519.PrivateKeySize {
+			return fmt.Errorf("%s is not a key written by catalog keygen", keyFile)
+		}
+		path := fs.Arg(0)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(priv), data))
+		// Catch a broken catalog before it is published
+		if _, err := verifyCatalog(data, []byte(sig), ed25519.PrivateKey(priv).Public().(ed25519.PublicKey)); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+".sig", []byte(sig+"\n"), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Signature written to %s.sig; publish it next to %s\n", path, path)
+	}
+	return nil
+}
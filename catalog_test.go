@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// signedCatalog returns file encoded and signed with a new key, and the
+// base64 public key.
+func signedCatalog(t *testing.T, file catalogFile) (data, sig []byte, key string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+	sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)))
+	return data, sig, base64.StdEncoding.EncodeToString(pub)
+}
+
+func TestVerifyCatalog(t *testing.T) {
+	data, sig, key := signedCatalog(t, builtinCatalog())
+	pub, err := parseCatalogKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := verifyCatalog(data, sig, pub)
+	if err != nil {
+		t.Fatalf("expected the built-in catalog to verify: %v", err)
+	}
+	providers, err := file.providers()
+	if err != nil || len(providers) != len(knownProviders) || providers[0].Egress[0] != knownProviders[0].Egress[0] {
+		t.Errorf("expected the built-in providers back, got %+v (%v)", providers, err)
+	}
+
+	tampered := slices.Clone(data)
+	tampered[len(tampered)-2] ^= 1
+	if _, err := verifyCatalog(tampered, sig, pub); err == nil {
+		t.Error("expected a modified catalog to be rejected")
+	}
+	bad, badSig, badKey := signedCatalog(t, catalogFile{Version: 2, Providers: []catalogProvider{{Name: "X", Hosts: []string{"192.0.2.1"}, Egress: []string{"not a range"}}}})
+	badPub, _ := parseCatalogKey(badKey)
+	if _, err := verifyCatalog(bad, badSig, badPub); err == nil {
+		t.Error("expected a signed catalog with an invalid range to be rejected")
+	}
+}
+
+func TestUpdateAndApplyCatalog(t *testing.T) {
+	newer := catalogFile{
+		Version:        builtinCatalogVersion + 1,
+		DefaultServers: []string{"9.9.9.9", "https://dns.example/dns-query"},
+		Providers:      []catalogProvider{{Name: "Example", Hosts: []string{"dns.example"}, Egress: []string{"192.0.2.0/24"}}},
+	}
+	data, sig, key := signedCatalog(t, newer)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/catalog.json":
+			_, _ = w.Write(data)
+		case "/catalog.json.sig":
+			_, _ = w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "catalog.json")
+
+	if _, _, err := updateCatalog(srv.URL+"/catalog.json", base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize)), path); err == nil {
+		t.Fatal("expected a catalog signed with another key to be rejected")
+	}
+	file, updated, err := updateCatalog(srv.URL+"/catalog.json", key, path)
+	if err != nil || !updated || file.Version != newer.Version {
+		t.Fatalf("expected version %d to be saved, got %+v, %v, %v", newer.Version, file, updated, err)
+	}
+	if _, updated, err := updateCatalog(srv.URL+"/catalog.json", key, path); err != nil || updated {
+		t.Errorf("expected the same version again to leave the catalog alone, got %v, %v", updated, err)
+	}
+
+	providers, servers := knownProviders, defaultServers
+	defer func() { knownProviders, defaultServers = providers, servers }()
+	warns := &warningLog{}
+	if version := applyCatalog(path, "", warns); version != builtinCatalogVersion || len(warns.warnings()) != 1 {
+		t.Errorf("expected the catalog to be ignored with a warning without a key, got version %d, %v", version, warns.warnings())
+	}
+	if version := applyCatalog(path, key, &warningLog{}); version != newer.Version {
+		t.Fatalf("expected version %d to be applied, got %d", newer.Version, version)
+	}
+	if providerOf("https://dns.example/dns-query") == nil || providerOf("1.1.1.1") != nil || !slices.Equal(defaultServers, newer.DefaultServers) {
+		t.Errorf("expected the catalog's providers and default servers, got %+v and %v", knownProviders, defaultServers)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// categoryStats counts a server's results by category (see
+// benchmark.Classify), which tells a server that times out apart from one
+// that answers SERVFAIL or refuses the query.
+type categoryStats struct {
+	counts map[string]int
+}
+
+func (c *categoryStats) add(res benchmark.Result) {
+	category := cmp.Or(res.Category, benchmark.Classify(res))
+	if category == "" {
+		return
+	}
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[category]++
+}
+
+// Count returns the number of results in category.
+func (c categoryStats) Count(category string) int {
+	return c.counts[category]
+}
+
+// usedCategories returns the categories at least one server has results
+// in, in the order of benchmark.Categories.
+func usedCategories(stats []*ServerStats) []string {
+	var used []string
+	for _, category := range benchmark.Categories {
+		for _, s := range stats {
+			if s.Categories.Count(category) > 0 {
+				used = append(used, category)
+				break
+			}
+		}
+	}
+	return used
+}
+
+// printCategories breaks each server's failures and error answers down by
+// category, with a column for every category that occurred. It stays
+// quiet when every query got a NOERROR answer.
+func printCategories(stats []*ServerStats) {
+	used := usedCategories(stats)
+	if len(used) == 0 {
+		return
+	}
+
+	fmt.Printf("\nFailures by Category (NXDOMAIN answers are counted as answered)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SERVER\t%s\n", strings.ToUpper(strings.Join(used, "\t"))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		row := []string{s.Server}
+		for _, category := range used {
+			row = append(row, fmt.Sprint(s.Categories.Count(category)))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestCategoryStats(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "a", Domain: "ok.example"},
+		{Server: "a", Domain: "slow.example", Error: os.ErrDeadlineExceeded},
+		{Server: "a", Domain: "gone.example", Rcode: "NXDOMAIN"},
+		{Server: "b", Domain: "broken.example", Rcode: "SERVFAIL"},
+		{Server: "b", Domain: "broken.example", Rcode: "SERVFAIL"},
+	}
+	stats := calculateStats(results)
+	byServer := make(map[string]*ServerStats)
+	for _, s := range stats {
+		byServer[s.Server] = s
+	}
+	if a := byServer["a"].Categories; a.Count(benchmark.CategoryTimeout) != 1 || a.Count(benchmark.CategoryNXDomain) != 1 {
+		t.Errorf("expected a timeout and an NXDOMAIN for a, got %+v", a)
+	}
+	if b := byServer["b"].Categories; b.Count(benchmark.CategoryServfail) != 2 {
+		t.Errorf("expected 2 SERVFAILs for b, got %+v", b)
+	}
+
+	want := []string{benchmark.CategoryTimeout, benchmark.CategoryServfail, benchmark.CategoryNXDomain}
+	if got := usedCategories(stats); !slices.Equal(got, want) {
+		t.Errorf("expected categories %v, got %v", want, got)
+	}
+}
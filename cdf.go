@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// cdfPercentiles are the points of an exported CDF: every percent, then
+// every tenth of a percent above p99, where resolvers differ most.
+func cdfPercentiles() []float64 {
+	var ps []float64
+	for p := 0; p < 99; p++ {
+		ps = append(ps, float64(p))
+	}
+	for p := 990; p <= 1000; p++ {
+		ps = append(ps, float64(p)/10)
+	}
+	return ps
+}
+
+// cdfPoint is the latency at or below which a percentile of a server's
+// answered queries completed; p0 is the fastest answer, p100 the slowest.
+type cdfPoint struct {
+	Percentile float64
+	Latency    time.Duration
+}
+
+// serverCDF is one server's latency distribution over Samples answered
+// queries, out of Queries sent.
+type serverCDF struct {
+	Server  string
+	Samples int
+	Queries int
+	Points  []cdfPoint
+}
+
+// calculateCDFs returns the CDF of every server in stats order that
+// answered any query.
+func calculateCDFs(stats []*ServerStats, results []benchmark.Result) []serverCDF {
+	durations := serverDurations(results)
+	var out []serverCDF
+	for _, s := range stats {
+		sorted := durations[s.Server]
+		if len(sorted) == 0 {
+			continue
+		}
+		c := serverCDF{Server: s.Server, Samples: len(sorted), Queries: s.Total}
+		for _, p := range cdfPercentiles() {
+			c.Points = append(c.Points, cdfPoint{Percentile: p, Latency: percentile(sorted, p)})
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// exportCDF writes the CDFs to path, as JSON for a .json file and as CSV
+// otherwise, with latencies in the run's unit and precision.
+func exportCDF(cdfs []serverCDF, prov *provenance, path string) error {
+	var latency latencyFormat
+	if prov != nil {
+		latency = configLatencyFormat(prov.Config)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return exportCDFJSON(cdfs, prov, latency, path)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+		}
+	}()
+
+	if prov != nil {
+		for _, line := range prov.commentLines() {
+			if _, err := fmt.Fprintln(file, line); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fmt.Fprintf(file, "%s%d\n", csvSchemaPrefix, schemaVersion); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Server", "Percentile", latency.column("Latency"), "Samples", "Queries"}); err != nil {
+		return err
+	}
+	for _, c := range cdfs {
+		for _, p := range c.Points {
+			record := []string{
+				c.Server,
+				strconv.FormatFloat(p.Percentile, 'f', -1, 64),
+				latency.exportNumber(p.Latency),
+				strconv.Itoa(c.Samples),
+				strconv.Itoa(c.Queries),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportCDFJSON writes the CDFs to path as JSON, each point's latency field
+// named after the unit (latency_ms by default).
+func exportCDFJSON(cdfs []serverCDF, prov *provenance, latency latencyFormat, path string) error {
+	type server struct {
+		Server  string           `json:"server"`
+		Samples int              `json:"samples"`
+		Queries int              `json:"queries"`
+		Points  []map[string]any `json:"points"`
+	}
+	export := struct {
+		SchemaVersion int         `json:"schema_version"`
+		Provenance    *provenance `json:"provenance,omitempty"`
+		LatencyUnit   string      `json:"latency_unit"`
+		Servers       []server    `json:"servers"`
+	}{
+		SchemaVersion: schemaVersion,
+		Provenance:    prov,
+		LatencyUnit:   "ms",
+		Servers:       []server{},
+	}
+	if latency.set() {
+		export.LatencyUnit = latency.unit
+	}
+	field := latency.column("latency")
+	for _, c := range cdfs {
+		s := server{Server: c.Server, Samples: c.Samples, Queries: c.Queries}
+		for _, p := range c.Points {
+			s.Points = append(s.Points, map[string]any{
+				"percentile": p.Percentile,
+				field:        json.Number(latency.exportNumber(p.Latency)),
+			})
+		}
+		export.Servers = append(export.Servers, s)
+	}
+	return writeJSONExport(export, path)
+}
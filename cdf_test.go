@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func cdfResults() []benchmark.Result {
+	var results []benchmark.Result
+	for i := 1; i <= 100; i++ {
+		results = append(results, benchmark.Result{Server: "8.8.8.8", Domain: "example.com", Duration: time.Duration(i) * time.Millisecond})
+	}
+	return append(results, benchmark.Result{Server: "8.8.8.8", Domain: "example.com", Error: os.ErrDeadlineExceeded},
+		benchmark.Result{Server: "192.0.2.1", Domain: "example.com", Error: os.ErrDeadlineExceeded})
+}
+
+func TestCalculateCDFs(t *testing.T) {
+	results := cdfResults()
+	cdfs := calculateCDFs(calculateStats(results), results)
+	if len(cdfs) != 1 {
+		t.Fatalf("expected a CDF only for the server that answered, got %+v", cdfs)
+	}
+	c := cdfs[0]
+	if c.Samples != 100 || c.Queries != 101 || len(c.Points) != len(cdfPercentiles()) {
+		t.Errorf("expected 100 samples of 101 queries at every percentile, got %d of %d, %d points", c.Samples, c.Queries, len(c.Points))
+	}
+	for _, want := range []cdfPoint{{0, time.Millisecond}, {50, 50 * time.Millisecond}, {99.5, 100 * time.Millisecond}, {100, 100 * time.Millisecond}} {
+		found := false
+		for _, p := range c.Points {
+			if p == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected point %+v", want)
+		}
+	}
+}
+
+func TestExportCDF(t *testing.T) {
+	results := cdfResults()
+	cdfs := calculateCDFs(calculateStats(results), results)
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "cdf.csv")
+	if err := exportCDF(cdfs, testProvenance(), csvPath); err != nil {
+		t.Fatalf("CSV export failed: %v", err)
+	}
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 1+len(cdfPercentiles()) || records[0][2] != "Latency_ms" {
+		t.Fatalf("expected a header and a row per percentile, got %d rows, header %v", len(records), records[0])
+	}
+	if row := records[51]; row[1] != "50" || row[2] != "50.0000" || row[3] != "100" || row[4] != "101" {
+		t.Errorf("expected p50 at 50ms of 100 samples, got %v", row)
+	}
+
+	prov := testProvenance()
+	prov.Config.Unit = "us"
+	jsonPath := filepath.Join(dir, "cdf.json")
+	if err := exportCDF(cdfs, prov, jsonPath); err != nil {
+		t.Fatalf("JSON export failed: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		LatencyUnit string `json:"latency_unit"`
+		Servers     []struct {
+			Server string `json:"server"`
+			Points []struct {
+				Percentile float64 `json:"percentile"`
+				LatencyUs  float64 `json:"latency_us"`
+			} `json:"points"`
+		} `json:"servers"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if out.LatencyUnit != "us" || len(out.Servers) != 1 || out.Servers[0].Points[50].LatencyUs != 50000 {
+		t.Errorf("expected p50 at 50000us, got %s", data)
+	}
+}
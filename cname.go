@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// chainBuckets groups CNAME depths as 0, 1, 2 and 3+.
+const chainBuckets = 4
+
+// chainStats aggregates how CNAME chain depth relates to latency for a server.
+type chainStats struct {
+	Count      [chainBuckets]int
+	Avg        [chainBuckets]time.Duration
+	Unfollowed int     // Answers that stopped at a CNAME without the final record
+	Corr       float64 // Pearson correlation between depth and latency
+
+	total [chainBuckets]time.Duration
+	// Running sums for the correlation coefficient
+	n, sx, sy, sxy, sxx, syy float64
+}
+
+func (c *chainStats) add(res benchmark.Result) {
+	if res.ChainUnfollowed {
+		c.Unfollowed++
+	}
+	b := min(res.CNAMEDepth, chainBuckets-1)
+	c.Count[b]++
+	c.total[b] += res.Duration
+
+	x := float64(res.CNAMEDepth)
+	y := float64(res.Duration.Microseconds())
+	c.n++
+	c.sx += x
+	c.sy += y
+	c.sxy += x * y
+	c.sxx += x * x
+	c.syy += y * y
+}
+
+func (c *chainStats) finish() {
+	for b := range c.Count {
+		if c.Count[b] > 0 {
+			c.Avg[b] = c.total[b] / time.Duration(c.Count[b])
+		}
+	}
+	den := math.Sqrt(c.n*c.sxx-c.sx*c.sx) * math.Sqrt(c.n*c.syy-c.sy*c.sy)
+	if den > 0 {
+		c.Corr = (c.n*c.sxy - c.sx*c.sy) / den
+	}
+}
+
+// hasChains reports whether any server saw a CNAME in its answers.
+func hasChains(stats []*ServerStats) bool {
+	for _, s := range stats {
+		if s.Chain.Count[1]+s.Chain.Count[2]+s.Chain.Count[3] > 0 || s.Chain.Unfollowed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// printChains shows average latency per CNAME depth for each server, the
+// depth/latency correlation, and resolvers that failed to follow chains.
+func printChains(stats []*ServerStats) {
+	if !hasChains(stats) {
+		return
+	}
+
+	fmt.Printf("\nCNAME Chain Cost (avg latency by chain depth)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tDEPTH 0\tDEPTH 1\tDEPTH 2\tDEPTH 3+\tCORRELATION\tUNFOLLOWED"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		row := s.Server
+		for b := range s.Chain.Count {
+			if s.Chain.Count[b] == 0 {
+				row += "\t-"
+			} else {
+				row += fmt.Sprintf("\t%v (%d)", s.Chain.Avg[b], s.Chain.Count[b])
+			}
+		}
+		row += fmt.Sprintf("\t%+.2f\t%d", s.Chain.Corr, s.Chain.Unfollowed)
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	for _, s := range stats {
+		if s.Chain.Unfollowed > 0 {
+			fmt.Printf("⚠️  %s returned %d answer(s) ending in an unresolved CNAME\n", s.Server, s.Chain.Unfollowed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestChainStats(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "a.com", Duration: 10 * time.Millisecond},
+		{Server: "8.8.8.8", Domain: "b.com", Duration: 20 * time.Millisecond, CNAMEDepth: 1},
+		{Server: "8.8.8.8", Domain: "c.com", Duration: 40 * time.Millisecond, CNAMEDepth: 4},
+		{Server: "8.8.8.8", Domain: "d.com", Duration: 30 * time.Millisecond, CNAMEDepth: 2, ChainUnfollowed: true},
+	}
+
+	stats := calculateStats(results)
+	c := stats[0].Chain
+	if c.Count != [chainBuckets]int{1, 1, 1, 1} {
+		t.Errorf("unexpected bucket counts: %v", c.Count)
+	}
+	if c.Avg[3] != 40*time.Millisecond {
+		t.Errorf("expected depth 3+ avg 40ms, got %v", c.Avg[3])
+	}
+	if c.Unfollowed != 1 {
+		t.Errorf("expected 1 unfollowed chain, got %d", c.Unfollowed)
+	}
+	if c.Corr < 0.9 {
+		t.Errorf("expected strong positive correlation, got %.2f", c.Corr)
+	}
+	if !hasChains(stats) {
+		t.Error("expected hasChains to be true")
+	}
+}
+
+func TestGenerateHTMLChains(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "1.1.1.1", Domain: "a.com", Duration: 10 * time.Millisecond, CNAMEDepth: 1},
+	}
+	stats := calculateStats(results)
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := generateHTML(stats, results, time.Second, &provenance{Network: "eth0"}, path); err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "CNAME Chain Cost") {
+		t.Error("expected CNAME section in HTML report")
+	}
+}
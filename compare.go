@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// compareThresholds decide when a change between two runs counts as a
+// regression (or an improvement): latency must move by more than Pct
+// percent and by at least MinDelta, loss by more than LossPts points.
+type compareThresholds struct {
+	Pct      float64
+	MinDelta time.Duration
+	LossPts  float64
+}
+
+// compareSide is one server's numbers in one of the compared exports.
+type compareSide struct {
+	Avg     time.Duration
+	P95     time.Duration
+	LossPct float64
+}
+
+// serverDelta compares a server across two exports. Before or After is nil
+// when the server only appears in one of them.
+type serverDelta struct {
+	Server        string
+	Before, After *compareSide
+	// AvgWorse, P95Worse and LossWorse mark the regressed metrics;
+	// Regressed and Improved sum them up.
+	AvgWorse, P95Worse, LossWorse bool
+	Regressed, Improved           bool
+}
+
+// runCompare implements the compare subcommand, which loads two exports
+// (CSV or JSON) and prints each server's change in average latency, p95 and
+// loss, marking regressions.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var th compareThresholds
+	fs.Float64Var(&th.Pct, "threshold", 10, "Latency change in percent beyond which avg or p95 counts as regressed or improved")
+	fs.DurationVar(&th.MinDelta, "min-delta", time.Millisecond, "Smallest latency change that counts, however large in percent")
+	fs.Float64Var(&th.LossPts, "loss-threshold", 1, "Loss change in percentage points beyond which loss counts as regressed or improved")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s compare [options] <before.csv|json> <after.csv|json>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly two exports")
+	}
+
+	before, err := readResults(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := readResults(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Comparing %s (%d results) with %s (%d results)\n", fs.Arg(0), len(before), fs.Arg(1), len(after))
+	printCompare(compareResults(before, after, th))
+	return nil
+}
+
+// compareSides computes each server's compareSide from its results.
+func compareSides(results []benchmark.Result) map[string]*compareSide {
+	durations := serverDurations(results)
+	sides := make(map[string]*compareSide)
+	for _, s := range calculateStats(results) {
+		sides[s.Server] = &compareSide{Avg: s.Avg, P95: percentile(durations[s.Server], 95), LossPct: s.LossPct}
+	}
+	return sides
+}
+
+// compareResults pairs up the servers of two result sets, ordered by their
+// average latency in after, with servers missing from after last.
+func compareResults(before, after []benchmark.Result, th compareThresholds) []serverDelta {
+	beforeSides := compareSides(before)
+	afterSides := compareSides(after)
+
+	var deltas []serverDelta
+	for _, s := range calculateStats(after) {
+		d := serverDelta{Server: s.Server, Before: beforeSides[s.Server], After: afterSides[s.Server]}
+		if d.Before != nil {
+			d.judge(th)
+		}
+		deltas = append(deltas, d)
+	}
+	for _, s := range calculateStats(before) {
+		if afterSides[s.Server] == nil {
+			deltas = append(deltas, serverDelta{Server: s.Server, Before: beforeSides[s.Server]})
+		}
+	}
+	return deltas
+}
+
+// latencyChange reports whether latency moved from a to b by more than the
+// thresholds allow, and in which direction. A side without a successful
+// query has no latency to compare.
+func latencyChange(a, b time.Duration, th compareThresholds) (worse, better bool) {
+	if a == 0 || b == 0 {
+		return false, false
+	}
+	diff := b - a
+	if diff.Abs() < th.MinDelta || float64(diff.Abs())/float64(a)*100 <= th.Pct {
+		return false, false
+	}
+	return diff > 0, diff < 0
+}
+
+// judge sets which metrics regressed or improved between Before and After.
+func (d *serverDelta) judge(th compareThresholds) {
+	var avgBetter, p95Better bool
+	d.AvgWorse, avgBetter = latencyChange(d.Before.Avg, d.After.Avg, th)
+	d.P95Worse, p95Better = latencyChange(d.Before.P95, d.After.P95, th)
+	loss := d.After.LossPct - d.Before.LossPct
+	d.LossWorse = loss > th.LossPts
+	d.Regressed = d.AvgWorse || d.P95Worse || d.LossWorse
+	d.Improved = !d.Regressed && (avgBetter || p95Better || loss < -th.LossPts)
+}
+
+// formatLatencyDelta renders the change from a to b with its percentage,
+// or "-" when either side has no latency.
+func formatLatencyDelta(a, b time.Duration, worse bool) string {
+	if a == 0 || b == 0 {
+		return "-"
+	}
+	s := fmt.Sprintf("%v (%+.1f%%)", b-a, float64(b-a)/float64(a)*100)
+	if b > a {
+		s = "+" + s
+	}
+	if worse {
+		s += " ✗"
+	}
+	return s
+}
+
+// printCompare writes the delta table and names the regressed servers.
+func printCompare(deltas []serverDelta) {
+	fmt.Printf("\nPer-Server Change (before → after)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tAVG\tΔ AVG\tP95\tΔ P95\tLOSS %\tΔ LOSS\tSTATUS"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var regressed, improved []string
+	for _, d := range deltas {
+		var row string
+		switch {
+		case d.Before == nil:
+			row = fmt.Sprintf("%s\t- → %v\t-\t- → %v\t-\t- → %.1f%%\t-\tnew", d.Server, d.After.Avg, d.After.P95, d.After.LossPct)
+		case d.After == nil:
+			row = fmt.Sprintf("%s\t%v → -\t-\t%v → -\t-\t%.1f%% → -\t-\tmissing", d.Server, d.Before.Avg, d.Before.P95, d.Before.LossPct)
+		default:
+			loss := fmt.Sprintf("%+.1f", d.After.LossPct-d.Before.LossPct)
+			if d.LossWorse {
+				loss += " ✗"
+			}
+			status := "-"
+			switch {
+			case d.Regressed:
+				status = "REGRESSED"
+				regressed = append(regressed, d.Server)
+			case d.Improved:
+				status = "improved"
+				improved = append(improved, d.Server)
+			}
+			row = fmt.Sprintf("%s\t%v → %v\t%s\t%v → %v\t%s\t%.1f%% → %.1f%%\t%s\t%s", d.Server,
+				d.Before.Avg, d.After.Avg, formatLatencyDelta(d.Before.Avg, d.After.Avg, d.AvgWorse),
+				d.Before.P95, d.After.P95, formatLatencyDelta(d.Before.P95, d.After.P95, d.P95Worse),
+				d.Before.LossPct, d.After.LossPct, loss, status)
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(improved) > 0 {
+		fmt.Printf("✓ Improved: %s\n", strings.Join(improved, ", "))
+	}
+	if len(regressed) > 0 {
+		fmt.Printf("⚠️  Regressed: %s\n", strings.Join(regressed, ", "))
+	} else {
+		fmt.Println("✓ No regressions")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// compareRun returns n results for server, the first failed of which fail.
+func compareRun(server string, n, failed int, d time.Duration) []benchmark.Result {
+	results := make([]benchmark.Result, n)
+	for i := range results {
+		results[i] = benchmark.Result{Server: server, Domain: "example.com", Duration: d}
+		if i < failed {
+			results[i] = benchmark.Result{Server: server, Domain: "example.com", Error: errors.New("timeout")}
+		}
+	}
+	return results
+}
+
+func TestCompareResults(t *testing.T) {
+	var before, after []benchmark.Result
+	before = append(before, compareRun("slower", 20, 0, 10*time.Millisecond)...)
+	after = append(after, compareRun("slower", 20, 0, 15*time.Millisecond)...)
+	before = append(before, compareRun("faster", 20, 0, 30*time.Millisecond)...)
+	after = append(after, compareRun("faster", 20, 0, 20*time.Millisecond)...)
+	before = append(before, compareRun("lossy", 20, 0, 10*time.Millisecond)...)
+	after = append(after, compareRun("lossy", 20, 2, 10*time.Millisecond)...)
+	// Large in percent but under -min-delta
+	before = append(before, compareRun("tiny", 20, 0, 200*time.Microsecond)...)
+	after = append(after, compareRun("tiny", 20, 0, 400*time.Microsecond)...)
+	before = append(before, compareRun("gone", 5, 0, time.Millisecond)...)
+	after = append(after, compareRun("new", 5, 0, time.Millisecond)...)
+
+	deltas := compareResults(before, after, compareThresholds{Pct: 10, MinDelta: time.Millisecond, LossPts: 1})
+	got := make(map[string]serverDelta)
+	for _, d := range deltas {
+		got[d.Server] = d
+	}
+	if len(got) != 6 {
+		t.Fatalf("got %d servers, want 6", len(got))
+	}
+	if d := got["slower"]; !d.Regressed || !d.AvgWorse || !d.P95Worse || d.LossWorse {
+		t.Errorf("slower: %+v", d)
+	}
+	if d := got["faster"]; d.Regressed || !d.Improved {
+		t.Errorf("faster: %+v", d)
+	}
+	if d := got["lossy"]; !d.Regressed || !d.LossWorse || d.AvgWorse {
+		t.Errorf("lossy: %+v", d)
+	}
+	if d := got["tiny"]; d.Regressed || d.Improved {
+		t.Errorf("tiny: %+v", d)
+	}
+	if d := got["new"]; d.Before != nil || d.After == nil {
+		t.Errorf("new: %+v", d)
+	}
+	if d := got["gone"]; d.Before == nil || d.After != nil {
+		t.Errorf("gone: %+v", d)
+	}
+	if deltas[len(deltas)-1].Server != "gone" {
+		t.Errorf("servers missing from after should come last, got %s", deltas[len(deltas)-1].Server)
+	}
+	if p95 := got["slower"].After.P95; p95 != 15*time.Millisecond {
+		t.Errorf("p95 = %v, want 15ms", p95)
+	}
+}
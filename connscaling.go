@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// scalingLevels returns the connection counts to sweep: powers of two up to
+// and always including max.
+func scalingLevels(maxConns int) []int {
+	var levels []int
+	for n := 1; n < maxConns; n *= 2 {
+		levels = append(levels, n)
+	}
+	return append(levels, maxConns)
+}
+
+// encryptedServers filters servers down to the DoT and DoH entries, the only
+// transports where the connections-per-server setting has any effect.
+func encryptedServers(servers []string) []string {
+	var out []string
+	for _, s := range servers {
+		if strings.HasPrefix(s, "tls://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "h3://") {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// runConnScaling re-runs the benchmark against the encrypted servers at each
+// connection count from 1 to config.ConnsPerServer and prints how average
+// latency changes, which is what sizing a forwarder's upstream pool needs.
+func runConnScaling(config benchmark.Config) {
+	servers := encryptedServers(config.Servers)
+	if len(servers) == 0 {
+		fmt.Println("\nConnection scaling skipped: no DoT/DoH servers to test")
+		return
+	}
+
+	levels := scalingLevels(config.ConnsPerServer)
+	avgs := make(map[string][]time.Duration, len(servers))
+
+	fmt.Printf("\nMeasuring connection scaling (%v connections per server)...\n", levels)
+	for _, n := range levels {
+		cfg := config
+		cfg.Servers = servers
+		cfg.ConnsPerServer = n
+		cfg.Duration = 0
+		cfg.Verbose = false
+		cfg.LogSample = 0
+		cfg.ShowProgress = false
+		cfg.OnResult = nil
+		for _, s := range calculateStats(benchmark.Run(cfg)) {
+			avgs[s.Server] = append(avgs[s.Server], s.Avg)
+		}
+	}
+
+	printConnScaling(servers, levels, avgs)
+}
+
+func printConnScaling(servers []string, levels []int, avgs map[string][]time.Duration) {
+	fmt.Printf("\nConnection Scaling (avg latency)\n\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "SERVER"
+	for _, n := range levels {
+		header += fmt.Sprintf("\t%d CONN", n)
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+
+	for _, server := range servers {
+		row := server
+		for i := range levels {
+			if i < len(avgs[server]) && avgs[server][i] > 0 {
+				row += fmt.Sprintf("\t%v", avgs[server][i])
+			} else {
+				row += "\t-"
+			}
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
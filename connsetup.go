@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// connStats splits successful DoT and https:// DoH queries into the time
+// spent opening connections and the time spent on the query itself, so
+// handshake cost doesn't hide in the average. h3:// isn't traced.
+type connStats struct {
+	Queries  int
+	NewConns int
+	// AvgSetup is the average setup time of the queries that opened a
+	// connection; AvgQuery the average time of all queries less setup
+	AvgSetup time.Duration
+	AvgQuery time.Duration
+	// Resumed counts the new connections that resumed a TLS session (with
+	// -tls-resume); AvgFull and AvgResumed are the average latency of the
+	// queries that opened a connection with a full and a resumed handshake
+	Resumed    int
+	AvgFull    time.Duration
+	AvgResumed time.Duration
+
+	setup   time.Duration
+	query   time.Duration
+	total   time.Duration
+	full    time.Duration
+	resumed time.Duration
+}
+
+func (c *connStats) add(res benchmark.Result) {
+	if res.Protocol != "dot" && res.Protocol != "doh" {
+		return
+	}
+	c.Queries++
+	if res.Connect > 0 {
+		c.NewConns++
+		c.setup += res.Connect
+		if res.Resumed {
+			c.Resumed++
+			c.resumed += res.Duration
+		} else {
+			c.full += res.Duration
+		}
+	}
+	c.query += max(res.Duration-res.Connect, 0)
+	c.total += res.Duration
+}
+
+func (c *connStats) finish() {
+	if c.NewConns > 0 {
+		c.AvgSetup = c.setup / time.Duration(c.NewConns)
+	}
+	if full := c.NewConns - c.Resumed; full > 0 {
+		c.AvgFull = c.full / time.Duration(full)
+	}
+	if c.Resumed > 0 {
+		c.AvgResumed = c.resumed / time.Duration(c.Resumed)
+	}
+	if c.Queries > 0 {
+		c.AvgQuery = c.query / time.Duration(c.Queries)
+	}
+}
+
+// setupShare is the percentage of the server's total query time spent
+// opening connections.
+func (c *connStats) setupShare() float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.setup) / float64(c.total) * 100
+}
+
+// printConnSetup lists, for every DoT and DoH server, how many queries had
+// to open a connection, what that cost and how fast the queries were
+// without it. With resume (-tls-resume), queries over new connections are
+// also split into full and resumed TLS handshakes.
+func printConnSetup(stats []*ServerStats, resume bool) {
+	var encrypted []*ServerStats
+	for _, s := range stats {
+		if s.Conn.Queries > 0 {
+			encrypted = append(encrypted, s)
+		}
+	}
+	if len(encrypted) == 0 {
+		return
+	}
+
+	fmt.Printf("\nConnection Setup (DoT and DoH; TCP + TLS handshakes)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "SERVER\tQUERIES\tNEW CONNS\tAVG SETUP\tAVG QUERY (NO SETUP)\tSETUP %"
+	if resume {
+		header += "\tRESUMED\tFULL HANDSHAKE QUERY\tRESUMED QUERY"
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range encrypted {
+		c := s.Conn
+		row := fmt.Sprintf("%s\t%d\t%d\t%v\t%v\t%.1f%%", s.Server, c.Queries, c.NewConns, c.AvgSetup, c.AvgQuery, c.setupShare())
+		if resume {
+			row += fmt.Sprintf("\t%d\t%s\t%s", c.Resumed, orDash(c.AvgFull), orDash(c.AvgResumed))
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
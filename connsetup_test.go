@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestCalculateStatsConnSetup(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "tls://1.1.1.1", Protocol: "dot", Duration: 40 * time.Millisecond, Connect: 30 * time.Millisecond},
+		{Server: "tls://1.1.1.1", Protocol: "dot", Duration: 10 * time.Millisecond},
+		{Server: "tls://1.1.1.1", Protocol: "dot", Error: errors.New("timeout")},
+		{Server: "tls://1.1.1.1", Protocol: "dot", Duration: 30 * time.Millisecond, Connect: 20 * time.Millisecond, Resumed: true},
+		{Server: "8.8.8.8", Protocol: "udp", Duration: 10 * time.Millisecond},
+	}
+	for _, s := range calculateStats(results) {
+		c := s.Conn
+		switch s.Server {
+		case "8.8.8.8":
+			if c.Queries != 0 {
+				t.Errorf("expected no connection stats for UDP, got %+v", c)
+			}
+		default:
+			if c.Queries != 3 || c.NewConns != 2 || c.AvgSetup != 25*time.Millisecond || c.AvgQuery != 10*time.Millisecond {
+				t.Errorf("expected 2 of 3 queries to connect for 25ms, 10ms per query without, got %+v", c)
+			}
+			if c.Resumed != 1 || c.AvgFull != 40*time.Millisecond || c.AvgResumed != 30*time.Millisecond {
+				t.Errorf("expected a 40ms full-handshake query and a 30ms resumed one, got %+v", c)
+			}
+			if share := c.setupShare(); share != 62.5 {
+				t.Errorf("expected 62.5%% of the time in setup, got %.1f", share)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 3, 14, 7, 30, 0, 0, time.UTC) // A Saturday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 */6 * * *", time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 14, 7, 45, 0, 0, time.UTC)},
+		{"30 7 * * *", time.Date(2026, 3, 15, 7, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 8,20 * * *", time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches (the 20th or a Monday)
+		{"0 0 20 * 1", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "* * * * 8"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestScheduledArgs(t *testing.T) {
+	tests := []struct {
+		entry ScheduleConfig
+		want  []string
+	}{
+		{ScheduleConfig{Schedule: "@hourly"}, []string{"-config", "base.yaml", "-db", "h.db"}},
+		{ScheduleConfig{Preset: "router", Args: []string{"-n", "3"}}, []string{"-config", "base.yaml", "-preset", "router", "-n", "3", "-db", "h.db"}},
+		{ScheduleConfig{Config: "night.yaml"}, []string{"-config", "night.yaml", "-db", "h.db"}},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.scheduledArgs("base.yaml", "h.db"); !slices.Equal(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.entry, got, tt.want)
+		}
+	}
+}
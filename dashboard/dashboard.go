@@ -13,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"dns-bench/benchmark"
 )
 
 //go:embed template.html
@@ -27,8 +29,9 @@ type RunEntry struct {
 
 // ServerStat holds aggregated latency data for one server across all runs.
 type ServerStat struct {
-	Server string
-	Avg    float64
+	Server   string
+	Protocol string
+	Avg      float64
 }
 
 // TemplateData is passed to the HTML template.
@@ -48,7 +51,10 @@ type MonthGroup struct {
 
 // isPrivate returns true for RFC-1918 / loopback addresses.
 func isPrivate(server string) bool {
-	plain := strings.TrimPrefix(strings.TrimPrefix(server, "tls://"), "https://")
+	plain := server
+	for _, scheme := range []string{"tcp://", "tls://", "https://", "h3://"} {
+		plain = strings.TrimPrefix(plain, scheme)
+	}
 	plain = strings.SplitN(plain, "/", 2)[0] // strip path
 	plain = strings.SplitN(plain, ":", 2)[0] // strip port
 	return strings.HasPrefix(plain, "192.168.") ||
@@ -81,6 +87,7 @@ func Generate(resultsDir string) error {
 	publicCounts := map[string]int{}
 	privateSums := map[string]float64{}
 	privateCounts := map[string]int{}
+	protocols := map[string]string{}
 
 	f, err := os.Open(historyPath)
 	if err != nil && !os.IsNotExist(err) {
@@ -92,13 +99,13 @@ func Generate(resultsDir string) error {
 				fmt.Fprintf(os.Stderr, "warning: closing history.csv: %v\n", cerr)
 			}
 		}()
-		if err := parseHistory(f, publicSums, publicCounts, privateSums, privateCounts); err != nil {
+		if err := parseHistory(f, publicSums, publicCounts, privateSums, privateCounts, protocols); err != nil {
 			return fmt.Errorf("parsing history.csv: %w", err)
 		}
 	}
 
-	publicStats := buildStats(publicSums, publicCounts)
-	privateStats := buildStats(privateSums, privateCounts)
+	publicStats := buildStats(publicSums, publicCounts, protocols)
+	privateStats := buildStats(privateSums, privateCounts, protocols)
 
 	recent, archived, err := collectRuns(resultsDir)
 	if err != nil {
@@ -134,7 +141,7 @@ func Generate(resultsDir string) error {
 	return tmpl.Execute(out, data)
 }
 
-func parseHistory(r io.Reader, pubSums map[string]float64, pubCounts map[string]int, privSums map[string]float64, privCounts map[string]int) error {
+func parseHistory(r io.Reader, pubSums map[string]float64, pubCounts map[string]int, privSums map[string]float64, privCounts map[string]int, protocols map[string]string) error {
 	cr := csv.NewReader(r)
 	cr.FieldsPerRecord = -1 // tolerate variable columns
 
@@ -151,7 +158,7 @@ func parseHistory(r io.Reader, pubSums map[string]float64, pubCounts map[string]
 		if err != nil {
 			return err
 		}
-		// columns: Timestamp, Server, Domain, Duration_ms, Error
+		// columns: Timestamp, Server, Domain, Duration_ms, Error[, Network, Protocol, ...]
 		if len(rec) < 4 {
 			continue
 		}
@@ -163,6 +170,13 @@ func parseHistory(r io.Reader, pubSums map[string]float64, pubCounts map[string]
 			continue
 		}
 		server := rec[1]
+		if len(rec) >= 7 && rec[6] != "" {
+			// Rows written before the labels were used carry the scheme
+			protocols[server] = benchmark.ProtocolLabel(rec[6])
+		} else if protocols[server] == "" {
+			// History rows from before the Protocol column
+			protocols[server] = benchmark.ServerProtocol(server)
+		}
 		dur, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
 		if err != nil || dur <= 0 {
 			continue
@@ -178,7 +192,7 @@ func parseHistory(r io.Reader, pubSums map[string]float64, pubCounts map[string]
 	return nil
 }
 
-func buildStats(sums map[string]float64, counts map[string]int) []ServerStat {
+func buildStats(sums map[string]float64, counts map[string]int, protocols map[string]string) []ServerStat {
 	stats := make([]ServerStat, 0, len(sums))
 	for server, sum := range sums {
 		c := counts[server]
@@ -186,8 +200,9 @@ func buildStats(sums map[string]float64, counts map[string]int) []ServerStat {
 			continue
 		}
 		stats = append(stats, ServerStat{
-			Server: server,
-			Avg:    sum / float64(c),
+			Server:   server,
+			Protocol: protocols[server],
+			Avg:      sum / float64(c),
 		})
 	}
 	sort.Slice(stats, func(i, j int) bool {
@@ -0,0 +1,193 @@
+package dashboard
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runTimestampLayout is the format entrypoint.sh uses to name each run.
+const runTimestampLayout = "2006-01-02T15-04-05Z"
+
+// Retention limits how much history a results directory keeps. A run is
+// pruned when it falls outside either limit; zero disables a limit.
+type Retention struct {
+	KeepRuns int // Keep only the newest N runs
+	KeepDays int // Keep only runs from the last M days
+}
+
+// PruneResult describes what Prune removed (or would remove on a dry run).
+type PruneResult struct {
+	Runs        []string // Timestamps of the pruned runs
+	Files       []string // Report and CSV files deleted
+	HistoryRows int      // Rows dropped from history.csv
+}
+
+// Prune applies r to resultsDir, deleting the report-*.html and
+// results-*.csv files of expired runs and dropping their rows from
+// history.csv. Runs whose timestamp can't be parsed are never expired by age.
+// With dryRun set nothing is modified.
+func Prune(resultsDir string, r Retention, now time.Time, dryRun bool) (PruneResult, error) {
+	var res PruneResult
+
+	files, err := runFiles(resultsDir)
+	if err != nil {
+		return res, err
+	}
+	historyPath := filepath.Join(resultsDir, "history.csv")
+	historyRuns, err := historyTimestamps(historyPath)
+	if err != nil {
+		return res, err
+	}
+
+	seen := map[string]bool{}
+	var runs []string
+	for _, ts := range append(historyRuns, mapKeys(files)...) {
+		if !seen[ts] {
+			seen[ts] = true
+			runs = append(runs, ts)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(runs)))
+
+	expired := map[string]bool{}
+	cutoff := now.AddDate(0, 0, -r.KeepDays)
+	for i, ts := range runs {
+		tooMany := r.KeepRuns > 0 && i >= r.KeepRuns
+		tooOld := false
+		if t, err := time.Parse(runTimestampLayout, ts); err == nil && r.KeepDays > 0 {
+			tooOld = t.Before(cutoff)
+		}
+		if tooMany || tooOld {
+			expired[ts] = true
+			res.Runs = append(res.Runs, ts)
+		}
+	}
+	if len(expired) == 0 {
+		return res, nil
+	}
+
+	for ts := range expired {
+		for _, path := range files[ts] {
+			res.Files = append(res.Files, filepath.Base(path))
+			if dryRun {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return res, fmt.Errorf("removing %s: %w", path, err)
+			}
+		}
+	}
+	sort.Strings(res.Files)
+
+	res.HistoryRows, err = pruneHistory(historyPath, expired, dryRun)
+	return res, err
+}
+
+// runFiles maps each run timestamp to its report and CSV files.
+func runFiles(resultsDir string) (map[string][]string, error) {
+	files := map[string][]string{}
+	for _, kind := range []struct{ prefix, suffix string }{{"report-", ".html"}, {"results-", ".csv"}} {
+		matches, err := filepath.Glob(filepath.Join(resultsDir, kind.prefix+"*"+kind.suffix))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			ts := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), kind.prefix), kind.suffix)
+			if ts == "latest" {
+				continue
+			}
+			files[ts] = append(files[ts], path)
+		}
+	}
+	return files, nil
+}
+
+// historyTimestamps returns the distinct run timestamps in history.csv.
+func historyTimestamps(path string) ([]string, error) {
+	var out []string
+	err := eachHistoryRow(path, func(rec []string, header bool) {
+		if !header && len(rec) > 0 && (len(out) == 0 || out[len(out)-1] != rec[0]) {
+			out = append(out, rec[0])
+		}
+	})
+	return out, err
+}
+
+// pruneHistory rewrites history.csv without the rows of expired runs and
+// returns how many rows were dropped. The file is replaced atomically.
+func pruneHistory(path string, expired map[string]bool, dryRun bool) (int, error) {
+	var kept [][]string
+	dropped := 0
+	err := eachHistoryRow(path, func(rec []string, header bool) {
+		if !header && len(rec) > 0 && expired[rec[0]] {
+			dropped++
+			return
+		}
+		kept = append(kept, rec)
+	})
+	if err != nil || dropped == 0 || dryRun {
+		return dropped, err
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("creating %s: %w", tmp, err)
+	}
+	w := csv.NewWriter(out)
+	if err := w.WriteAll(kept); err != nil {
+		_ = out.Close()
+		return 0, fmt.Errorf("writing %s: %w", tmp, err)
+	}
+	if err := out.Close(); err != nil {
+		return 0, fmt.Errorf("closing %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("replacing history.csv: %w", err)
+	}
+	return dropped, nil
+}
+
+// eachHistoryRow calls fn for every record in history.csv; header is set for
+// the first one. A missing file has no rows.
+func eachHistoryRow(path string, fn func(rec []string, header bool)) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening history.csv: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			fmt.Fprintf(os.Stderr, "warning: closing history.csv: %v\n", cerr)
+		}
+	}()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1 // tolerate variable columns
+	for header := true; ; header = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parsing history.csv: %w", err)
+		}
+		fn(rec, header)
+	}
+}
+
+func mapKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,111 @@
+package dashboard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRuns creates a results directory with a report, CSV and history rows
+// for each timestamp.
+func writeRuns(t *testing.T, timestamps ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	history := "Timestamp,Server,Domain,Duration_ms,Error,Network\n"
+	for _, ts := range timestamps {
+		for _, name := range []string{"report-" + ts + ".html", "results-" + ts + ".csv"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		history += ts + ",8.8.8.8,a.com,10.0000,,eth0\n"
+		history += ts + ",1.1.1.1,a.com,5.0000,,eth0\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "history.csv"), []byte(history), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPruneKeepRuns(t *testing.T) {
+	dir := writeRuns(t, "2026-01-01T06-00-00Z", "2026-01-02T06-00-00Z", "2026-01-03T06-00-00Z")
+	now := time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	res, err := Prune(dir, Retention{KeepRuns: 2}, now, false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(res.Runs) != 1 || res.Runs[0] != "2026-01-01T06-00-00Z" {
+		t.Errorf("expected only the oldest run pruned, got %v", res.Runs)
+	}
+	if len(res.Files) != 2 || res.HistoryRows != 2 {
+		t.Errorf("expected 2 files and 2 history rows removed, got %v and %d", res.Files, res.HistoryRows)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report-2026-01-01T06-00-00Z.html")); !os.IsNotExist(err) {
+		t.Error("expected the oldest report to be deleted")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "history.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "Timestamp,") {
+		t.Errorf("expected header plus 4 rows to remain, got %q", lines)
+	}
+	if strings.Contains(string(data), "2026-01-01T06-00-00Z") {
+		t.Error("expected rows of the pruned run to be dropped from history.csv")
+	}
+}
+
+func TestPruneKeepDays(t *testing.T) {
+	dir := writeRuns(t, "2026-01-01T06-00-00Z", "2026-01-20T06-00-00Z")
+	now := time.Date(2026, 1, 21, 0, 0, 0, 0, time.UTC)
+
+	res, err := Prune(dir, Retention{KeepDays: 7}, now, false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(res.Runs) != 1 || res.Runs[0] != "2026-01-01T06-00-00Z" {
+		t.Errorf("expected the run older than 7 days pruned, got %v", res.Runs)
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	dir := writeRuns(t, "2026-01-01T06-00-00Z", "2026-01-02T06-00-00Z")
+	before, err := os.ReadFile(filepath.Join(dir, "history.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Prune(dir, Retention{KeepRuns: 1}, time.Now(), true)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(res.Files) != 2 || res.HistoryRows != 2 {
+		t.Errorf("expected dry run to report 2 files and 2 rows, got %v and %d", res.Files, res.HistoryRows)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report-2026-01-01T06-00-00Z.html")); err != nil {
+		t.Error("expected dry run to leave files in place")
+	}
+	after, err := os.ReadFile(filepath.Join(dir, "history.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("expected dry run to leave history.csv unchanged")
+	}
+}
+
+func TestPruneNoLimits(t *testing.T) {
+	dir := writeRuns(t, "2020-01-01T06-00-00Z")
+	res, err := Prune(dir, Retention{}, time.Now(), false)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(res.Runs) != 0 {
+		t.Errorf("expected nothing pruned without limits, got %v", res.Runs)
+	}
+}
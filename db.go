@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// dbSchemaVersion is stored in the database's user_version. Like the
+// export schema, tables and columns are only ever added within a version.
+// Version 2 records protocols by label (dot, doh, doh3) rather than by
+// address scheme.
+const dbSchemaVersion = 2
+
+// dbRelabelProtocols upgrades the results of a version 1 database to the
+// protocol labels, in the protocol column and in the JSON data alike.
+const dbRelabelProtocols = `
+UPDATE results SET protocol = CASE protocol WHEN 'tls' THEN 'dot' WHEN 'https' THEN 'doh' ELSE 'doh3' END
+	WHERE protocol IN ('tls', 'https', 'h3');
+UPDATE results SET data = json_set(data, '$.protocol', protocol)
+	WHERE json_extract(data, '$.protocol') IN ('tls', 'https', 'h3');
+`
+
+// dbSchema creates the tables -db appends to. Every result is also kept as
+// its JSON export form in results.data, so fields added later don't need a
+// column to be queryable with json_extract.
+const dbSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	run_id       TEXT PRIMARY KEY,
+	generated_at TEXT NOT NULL,
+	host         TEXT,
+	network      TEXT,
+	version      TEXT,
+	duration_ms  REAL,
+	provenance   TEXT
+);
+CREATE TABLE IF NOT EXISTS results (
+	run_id      TEXT NOT NULL REFERENCES runs(run_id),
+	server      TEXT NOT NULL,
+	domain      TEXT NOT NULL,
+	duration_ms REAL,
+	error       TEXT,
+	network     TEXT,
+	protocol    TEXT,
+	data        TEXT
+);
+CREATE INDEX IF NOT EXISTS results_run ON results(run_id);
+CREATE INDEX IF NOT EXISTS results_server ON results(server);
+CREATE TABLE IF NOT EXISTS server_stats (
+	run_id    TEXT NOT NULL REFERENCES runs(run_id),
+	server    TEXT NOT NULL,
+	rank      INTEGER,
+	queries   INTEGER,
+	errors    INTEGER,
+	avg_ms    REAL,
+	min_ms    REAL,
+	max_ms    REAL,
+	stddev_ms REAL,
+	jitter_ms REAL,
+	loss_pct  REAL,
+	PRIMARY KEY (run_id, server)
+);
+CREATE TABLE IF NOT EXISTS run_probes (
+	run_id TEXT PRIMARY KEY REFERENCES runs(run_id),
+	probe  TEXT NOT NULL,
+	tags   TEXT
+);
+CREATE INDEX IF NOT EXISTS run_probes_probe ON run_probes(probe);
+`
+
+// millis converts d to fractional milliseconds, as stored in the database.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// openResultsDB opens (creating if needed) the results database at path.
+func openResultsDB(path string) (*sql.DB, error) {
+	if !haveSQLite {
+		return nil, fmt.Errorf("%s: results databases are not available in this build (built without SQLite support)", path)
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if version > dbSchemaVersion {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: database schema version %d is newer than this build supports (%d)", path, version, dbSchemaVersion)
+	}
+	if _, err := db.Exec(dbSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if version == 1 {
+		if _, err := db.Exec(dbRelabelProtocols); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", dbSchemaVersion)); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// storeRun appends a run, its raw results and the computed per-server stats
+// to the database at path, in one transaction, and returns the run's ID.
+func storeRun(path string, prov *provenance, results []benchmark.Result, stats []*ServerStats, totalTime time.Duration) (string, error) {
+	db, err := openResultsDB(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close database: %v\n", err)
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }() // No-op once committed
+
+	if _, err := tx.Exec(`INSERT INTO runs (run_id, generated_at, host, network, version, duration_ms, provenance) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		prov.RunID, prov.GeneratedAt.Format(time.RFC3339), prov.Host, prov.Network, prov.Version, millis(totalTime), prov.YAML()); err != nil {
+		return "", err
+	}
+
+	if prov.Probe != "" {
+		// Tags are JSON, like results.data, to be queried with json_extract
+		tags, err := json.Marshal(prov.Tags)
+		if err != nil {
+			return "", err
+		}
+		if _, err := tx.Exec(`INSERT INTO run_probes (run_id, probe, tags) VALUES (?, ?, ?)`, prov.RunID, prov.Probe, string(tags)); err != nil {
+			return "", err
+		}
+	}
+
+	insertResult, err := tx.Prepare(`INSERT INTO results (run_id, server, domain, duration_ms, error, network, protocol, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = insertResult.Close() }()
+	for _, res := range results {
+		data, err := json.Marshal(res)
+		if err != nil {
+			return "", err
+		}
+		var errStr any
+		if res.Error != nil {
+			errStr = res.Error.Error()
+		}
+		if _, err := insertResult.Exec(prov.RunID, res.Server, res.Domain, millis(res.Duration), errStr, res.Network, res.Protocol, string(data)); err != nil {
+			return "", err
+		}
+	}
+
+	insertStats, err := tx.Prepare(`INSERT INTO server_stats (run_id, server, rank, queries, errors, avg_ms, min_ms, max_ms, stddev_ms, jitter_ms, loss_pct) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = insertStats.Close() }()
+	for i, s := range stats {
+		if _, err := insertStats.Exec(prov.RunID, s.Server, i+1, s.Total, s.Errors,
+			millis(s.Avg), millis(s.Min), millis(s.Max), millis(s.StdDev), millis(s.Jitter), s.LossPct); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return prov.RunID, nil
+}
+
+// runStored reports whether the database at path already holds the run.
+func runStored(path, runID string) (bool, error) {
+	db, err := openResultsDB(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = db.Close() }()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM runs WHERE run_id = ?`, runID).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestStoreRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "google.com", Duration: 12 * time.Millisecond, Protocol: "udp", Network: "eth0"},
+		{Server: "8.8.8.8", Domain: "github.com", Error: os.ErrDeadlineExceeded, Protocol: "udp", Network: "eth0"},
+		{Server: "1.1.1.1", Domain: "google.com", Duration: 8 * time.Millisecond, Protocol: "udp", Network: "eth0", Answers: 1},
+	}
+	stats := calculateStats(results)
+	for _, id := range []string{"run1", "run2"} {
+		prov := &provenance{RunID: id, Host: "laptop", Version: "test", GeneratedAt: time.Now().UTC(), Network: "eth0"}
+		if _, err := storeRun(path, prov, results, stats, time.Second); err != nil {
+			t.Fatalf("storing %s: %v", id, err)
+		}
+	}
+
+	db, err := openResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	var runs, rows, errs int
+	if err := db.QueryRow("SELECT COUNT(*) FROM runs").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*), COUNT(error) FROM results").Scan(&rows, &errs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 || rows != 6 || errs != 2 {
+		t.Errorf("expected 2 runs, 6 results and 2 errors, got %d, %d, %d", runs, rows, errs)
+	}
+
+	var server string
+	var avg, loss float64
+	if err := db.QueryRow("SELECT server, avg_ms, loss_pct FROM server_stats WHERE run_id = 'run2' AND rank = 1").Scan(&server, &avg, &loss); err != nil {
+		t.Fatal(err)
+	}
+	if server != "1.1.1.1" || avg != 8 || loss != 0 {
+		t.Errorf("unexpected top server stats: %s %.2fms %.1f%%", server, avg, loss)
+	}
+
+	// Fields without a column are still reachable through the JSON data
+	var answers int
+	if err := db.QueryRow("SELECT json_extract(data, '$.answers') FROM results WHERE server = '1.1.1.1' LIMIT 1").Scan(&answers); err != nil {
+		t.Fatal(err)
+	}
+	if answers != 1 {
+		t.Errorf("expected 1 answer from the JSON data, got %d", answers)
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil || version != dbSchemaVersion {
+		t.Errorf("expected user_version %d, got %d (%v)", dbSchemaVersion, version, err)
+	}
+}
+
+func TestOpenResultsDBRelabelsProtocols(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	db, err := openResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A version 1 database named protocols by address scheme
+	if _, err := db.Exec(`PRAGMA user_version = 1;
+		INSERT INTO results (run_id, server, domain, protocol, data) VALUES
+			('run1', 'tls://1.1.1.1', 'example.com', 'tls', '{"protocol": "tls"}'),
+			('run1', '8.8.8.8', 'example.com', 'udp', '{"protocol": "udp"}')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if db, err = openResultsDB(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var protocol, data string
+	if err := db.QueryRow("SELECT protocol, json_extract(data, '$.protocol') FROM results WHERE server = 'tls://1.1.1.1'").Scan(&protocol, &data); err != nil {
+		t.Fatal(err)
+	}
+	if protocol != "dot" || data != "dot" {
+		t.Errorf("expected the DoT result relabelled dot, got %s and %s", protocol, data)
+	}
+	if err := db.QueryRow("SELECT protocol FROM results WHERE server = '8.8.8.8'").Scan(&protocol); err != nil || protocol != "udp" {
+		t.Errorf("expected udp left alone, got %s (%v)", protocol, err)
+	}
+}
+
+func TestStoreRunDuplicateRunID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	prov := &provenance{RunID: "run1", GeneratedAt: time.Now().UTC()}
+	results := []benchmark.Result{{Server: "8.8.8.8", Domain: "google.com", Duration: time.Millisecond}}
+	if _, err := storeRun(path, prov, results, calculateStats(results), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storeRun(path, prov, results, calculateStats(results), time.Second); err == nil {
+		t.Fatal("expected storing the same run twice to fail")
+	}
+
+	db, err := openResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var rows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM results").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("expected the failed run to leave nothing behind, got %d results", rows)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// diagTimeout bounds each troubleshooting check.
+const diagTimeout = 3 * time.Second
+
+// diagFallbackServer is queried as the known-good server when no plain DNS
+// server answered during the run.
+const diagFallbackServer = "1.1.1.1"
+
+// diagCheck is the outcome of one troubleshooting check. A check that
+// couldn't run (no ping installed, no address to check) has Ran false.
+type diagCheck struct {
+	Name string
+	Ran  bool
+	OK   bool
+	// Refused is a connection actively refused, which shows the host is up
+	// where silence could also be a firewall
+	Refused bool
+	Detail  string
+}
+
+// diagnosis is the differential diagnosis of a server that failed every
+// query: what still works on the way to it, and what that points to.
+type diagnosis struct {
+	Server  string
+	LastErr string
+	Target  netip.Addr
+	// ResolveErr is why the server's hostname couldn't be resolved, in
+	// which case only Control ran
+	ResolveErr error
+	// Ping is ICMP reachability, DoT a TCP connect to the DoT port, HTTPS
+	// a TLS handshake on the HTTPS port, and Control a plain query to a
+	// server known to work
+	Ping, DoT, HTTPS, Control diagCheck
+	Verdict                   string
+}
+
+// diagPorts returns the ports the DoT and HTTPS checks connect to: the
+// server's own for DoT and DoH servers, 853 and 443 otherwise.
+func diagPorts(server string) (dot, https int) {
+	dot, https = 853, 443
+	u, err := url.Parse(server)
+	if err != nil || u.Port() == "" {
+		return dot, https
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return dot, https
+	}
+	switch benchmark.ServerProtocol(server) {
+	case "dot":
+		dot = port
+	case "doh", "doh3":
+		https = port
+	}
+	return dot, https
+}
+
+// pingCommand returns the command line sending one ping to target on goos.
+func pingCommand(goos string, target netip.Addr) (string, []string) {
+	switch {
+	case goos == "windows":
+		return "ping", []string{"-n", "1", "-w", "2000", target.String()}
+	case goos == "darwin" && target.Is6():
+		return "ping6", []string{"-c", "1", target.String()}
+	case goos == "darwin":
+		return "ping", []string{"-c", "1", "-t", "2", target.String()}
+	default:
+		return "ping", []string{"-c", "1", "-W", "2", target.String()}
+	}
+}
+
+// pingRTT matches the round trip time in the output of ping, e.g.
+// "time=12.3 ms" or Windows' "time<1ms".
+var pingRTT = regexp.MustCompile(`time[=<]\s*([\d.]+)\s*ms`)
+
+// pingCheck pings target once with the system ping.
+func pingCheck(target netip.Addr) diagCheck {
+	check := diagCheck{Name: "ping " + target.String()}
+	ctx, cancel := context.WithTimeout(context.Background(), diagTimeout)
+	defer cancel()
+	name, args := pingCommand(runtime.GOOS, target)
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		check.Detail = name + " is not installed"
+		return check
+	}
+	check.Ran = true
+	if err != nil {
+		check.Detail = "no reply"
+		return check
+	}
+	check.OK = true
+	check.Detail = "replied"
+	if m := pingRTT.FindStringSubmatch(string(out)); m != nil {
+		check.Detail = "replied in " + m[1] + "ms"
+	}
+	return check
+}
+
+// dialCheck opens a TCP connection to addr and, with tlsConfig, completes a
+// TLS handshake on it.
+func dialCheck(name, addr string, tlsConfig *tls.Config) diagCheck {
+	check := diagCheck{Name: name, Ran: true}
+	start := time.Now()
+	dialer := &net.Dialer{Timeout: diagTimeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		check.Refused = errors.Is(err, syscall.ECONNREFUSED)
+		check.Detail = shortError(err)
+		return check
+	}
+	_ = conn.Close()
+	check.OK = true
+	check.Detail = fmt.Sprintf("connected in %v", time.Since(start).Round(time.Millisecond))
+	return check
+}
+
+// shortError drops the operation and addresses net errors are wrapped in,
+// which the check's name already gives.
+func shortError(err error) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Err != nil {
+		err = opErr.Err
+	}
+	var sysErr *os.SyscallError
+	if errors.As(err, &sysErr) {
+		err = sysErr.Err
+	}
+	return err.Error()
+}
+
+// controlCheck queries server, one that answered during the run, for
+// domain over plain DNS, to tell a dead server from a network where DNS
+// doesn't work at all.
+func controlCheck(server, domain string) diagCheck {
+	check := diagCheck{Name: "plain DNS via " + server, Ran: true}
+	client := &benchmark.Client{Timeout: diagTimeout}
+	res := client.Measure(server, domain)
+	if res.Error != nil {
+		check.Detail = shortError(res.Error)
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("answered in %v", res.Duration.Round(time.Millisecond))
+	return check
+}
+
+// knownGoodServer picks the server for the control check: the best ranked
+// plain DNS server that answered during the run, or diagFallbackServer.
+func knownGoodServer(stats []*ServerStats) string {
+	for _, s := range stats {
+		if s.Success > 0 && benchmark.ServerProtocol(s.Server) == "udp" {
+			return s.Server
+		}
+	}
+	return diagFallbackServer
+}
+
+// verdict names the most likely cause of a total failure from the checks.
+func (d *diagnosis) verdict() string {
+	dotPort, httpsPort := diagPorts(d.Server)
+	if d.Control.Ran && !d.Control.OK {
+		return "DNS isn't working on this network at all: the known-good server didn't answer either. Check the connection, a captive portal or a firewall blocking DNS"
+	}
+	if d.ResolveErr != nil {
+		return fmt.Sprintf("the server's hostname doesn't resolve (%v): check the name, or the -bootstrap resolver", d.ResolveErr)
+	}
+	up := d.Ping.OK || d.DoT.OK || d.HTTPS.OK || d.DoT.Refused || d.HTTPS.Refused
+	if !up {
+		if !d.Ping.Ran {
+			return "no TCP connection to the server's address: the server is down or traffic to it is blocked"
+		}
+		return "the server's address is unreachable: no reply to ping and no TCP connection. The server is down or traffic to it is blocked"
+	}
+	switch benchmark.ServerProtocol(d.Server) {
+	case "dot":
+		switch {
+		case d.DoT.Refused:
+			return fmt.Sprintf("the host refuses connections on port %d: it doesn't serve DoT there", dotPort)
+		case !d.DoT.OK:
+			return fmt.Sprintf("the host is up but port %d doesn't connect: DoT is likely blocked on this network, try the provider's DoH endpoint", dotPort)
+		}
+		return fmt.Sprintf("port %d connects but every DoT query failed: a TLS or DNS problem at the server", dotPort)
+	case "doh", "doh3":
+		switch {
+		case d.HTTPS.Refused:
+			return fmt.Sprintf("the host refuses connections on port %d: it doesn't serve HTTPS there", httpsPort)
+		case !d.HTTPS.OK:
+			return fmt.Sprintf("the host is up but HTTPS on port %d doesn't connect: blocked on this network, or not served there", httpsPort)
+		}
+		return "HTTPS connects but every DoH query failed: check the URL's path, or the server doesn't serve DoH there"
+	}
+	msg := "the host is up but doesn't answer plain DNS: it may not be an open resolver, or DNS to it is filtered on this network"
+	if d.DoT.OK {
+		msg += fmt.Sprintf(". It accepts connections on %d, so try DoT (tls://)", dotPort)
+	}
+	return msg
+}
+
+// diagnoseFailures runs the troubleshooting checks for every server that
+// failed every query, in parallel, with the control check shared.
+func diagnoseFailures(config benchmark.Config, stats []*ServerStats, results []benchmark.Result) []*diagnosis {
+	lastErr := make(map[string]string)
+	for _, res := range results {
+		if res.Error != nil {
+			lastErr[res.Server] = res.Error.Error()
+		}
+	}
+	var failed []*diagnosis
+	for _, s := range stats {
+		if s.Total > 0 && s.Success == 0 {
+			failed = append(failed, &diagnosis{Server: s.Server, LastErr: lastErr[s.Server]})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	domain := "example.com"
+	if len(config.Domains) > 0 {
+		domain = config.Domains[0]
+	}
+	control := controlCheck(knownGoodServer(stats), domain)
+
+	//nolint:gosec // G402: like the benchmark, servers may be checked by IP address
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	var wg sync.WaitGroup
+	for _, d := range failed {
+		d.Control = control
+		server := d.Server
+		if via, ok := config.Via[server]; ok {
+			server = via
+		}
+		d.Target, d.ResolveErr = traceTarget(server, config.Bootstrap, diagTimeout)
+		if d.ResolveErr != nil {
+			d.Verdict = d.verdict()
+			continue
+		}
+		dotPort, httpsPort := diagPorts(d.Server)
+		for _, check := range []struct {
+			dst *diagCheck
+			run func() diagCheck
+		}{
+			{&d.Ping, func() diagCheck { return pingCheck(d.Target) }},
+			{&d.DoT, func() diagCheck {
+				addr := netip.AddrPortFrom(d.Target, uint16(dotPort)).String() //nolint:gosec // G115: a parsed port
+				return dialCheck("TCP "+addr, addr, nil)
+			}},
+			{&d.HTTPS, func() diagCheck {
+				addr := netip.AddrPortFrom(d.Target, uint16(httpsPort)).String() //nolint:gosec // G115: a parsed port
+				return dialCheck("HTTPS (TLS) "+addr, addr, tlsConfig)
+			}},
+		} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				*check.dst = check.run()
+			}()
+		}
+	}
+	wg.Wait()
+	for _, d := range failed {
+		if d.Verdict == "" {
+			d.Verdict = d.verdict()
+		}
+	}
+	return failed
+}
+
+// printDiagnoses troubleshoots every server that failed every query and
+// lists the checks and the likely cause for each. The causes are also
+// recorded as warnings, so they reach the exports.
+func printDiagnoses(config benchmark.Config, stats []*ServerStats, results []benchmark.Result, warns *warningLog) {
+	if config.Proxy != nil {
+		return
+	}
+	diagnoses := diagnoseFailures(config, stats, results)
+	if len(diagnoses) == 0 {
+		return
+	}
+
+	fmt.Printf("\nTroubleshooting (servers that failed every query)\n")
+	for _, d := range diagnoses {
+		fmt.Printf("\n%s (last error: %s)\n", d.Server, orNone(d.LastErr))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		for _, c := range []diagCheck{d.Ping, d.DoT, d.HTTPS, d.Control} {
+			if c.Name == "" {
+				continue
+			}
+			mark := "✓"
+			switch {
+			case !c.Ran:
+				mark = "-"
+			case !c.OK:
+				mark = "✗"
+			}
+			if _, err := fmt.Fprintf(w, "  %s %s\t%s\n", mark, c.Name, c.Detail); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+		}
+		fmt.Printf("  → %s\n", d.Verdict)
+		warns.add("diagnosis", "%s failed every query: %s", d.Server, d.Verdict)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosisVerdict(t *testing.T) {
+	ok := diagCheck{Ran: true, OK: true}
+	failed := diagCheck{Ran: true}
+	refused := diagCheck{Ran: true, Refused: true}
+	for _, tc := range []struct {
+		name string
+		d    diagnosis
+		want string
+	}{
+		{"network without DNS", diagnosis{Server: "9.9.9.9", Ping: ok, Control: failed}, "DNS isn't working on this network"},
+		{"unresolvable host", diagnosis{Server: "https://dns.invalid/dns-query", ResolveErr: errors.New("no such host"), Control: ok}, "hostname doesn't resolve"},
+		{"unreachable", diagnosis{Server: "tls://192.0.2.1", Ping: failed, DoT: failed, HTTPS: failed, Control: ok}, "unreachable"},
+		{"DoT filtered", diagnosis{Server: "tls://192.0.2.1", Ping: ok, DoT: failed, HTTPS: ok, Control: ok}, "port 853 doesn't connect"},
+		{"DoT not served", diagnosis{Server: "tls://192.0.2.1:8853", Ping: failed, DoT: refused, HTTPS: failed, Control: ok}, "refuses connections on port 8853"},
+		{"DoT port open", diagnosis{Server: "tls://192.0.2.1", DoT: ok, Control: ok}, "TLS or DNS problem"},
+		{"DoH path", diagnosis{Server: "https://192.0.2.1/dns", Ping: ok, HTTPS: ok, Control: ok}, "check the URL's path"},
+		{"HTTPS filtered", diagnosis{Server: "https://192.0.2.1:8443/dns-query", Ping: ok, HTTPS: failed, Control: ok}, "HTTPS on port 8443 doesn't connect"},
+		{"plain DNS filtered", diagnosis{Server: "192.0.2.1", Ping: ok, DoT: ok, Control: ok}, "try DoT"},
+	} {
+		if got := tc.d.verdict(); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: expected a verdict containing %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestPingCommand(t *testing.T) {
+	v4, v6 := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")
+	if name, args := pingCommand("windows", v4); name != "ping" || args[0] != "-n" {
+		t.Errorf("expected ping -n on Windows, got %s %v", name, args)
+	}
+	if name, _ := pingCommand("darwin", v6); name != "ping6" {
+		t.Errorf("expected ping6 for IPv6 on macOS, got %s", name)
+	}
+	if name, args := pingCommand("linux", v6); name != "ping" || args[len(args)-1] != "2001:db8::1" {
+		t.Errorf("expected ping to 2001:db8::1, got %s %v", name, args)
+	}
+	for out, want := range map[string]string{
+		"64 bytes from 1.1.1.1: icmp_seq=1 ttl=57 time=12.3 ms": "12.3",
+		"Reply from 1.1.1.1: bytes=32 time<1ms TTL=57":          "1",
+		"Reply from 1.1.1.1: bytes=32 time=14ms TTL=57":         "14",
+	} {
+		if m := pingRTT.FindStringSubmatch(out); m == nil || m[1] != want {
+			t.Errorf("expected %s from %q, got %v", want, out, m)
+		}
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if c := dialCheck("TCP", addr, nil); !c.OK {
+		t.Errorf("expected a connection to the listener, got %+v", c)
+	}
+	_ = ln.Close()
+	if c := dialCheck("TCP", addr, nil); c.OK || !c.Refused || c.Detail != "connection refused" {
+		t.Errorf("expected a refused connection once closed, got %+v", c)
+	}
+}
+
+func TestKnownGoodServer(t *testing.T) {
+	stats := []*ServerStats{
+		{Server: "tls://1.1.1.1", Total: 5, Success: 5},
+		{Server: "9.9.9.9", Total: 5},
+		{Server: "8.8.8.8", Total: 5, Success: 4},
+	}
+	if got := knownGoodServer(stats); got != "8.8.8.8" {
+		t.Errorf("expected the best plain server that answered, got %s", got)
+	}
+	if got := knownGoodServer(stats[:2]); got != diagFallbackServer {
+		t.Errorf("expected the fallback server, got %s", got)
+	}
+}
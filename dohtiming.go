@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// dohTiming splits successful DoH queries into time to first byte and the
+// time spent reading the body, which separates slow servers from ones that
+// answer quickly but dribble the response. HTTPVersion is the protocol the
+// answers came over, so h3:// and https:// entries can be compared.
+type dohTiming struct {
+	Count       int
+	AvgTTFB     time.Duration
+	AvgBody     time.Duration
+	HTTPVersion string
+
+	ttfb time.Duration
+	body time.Duration
+}
+
+func (d *dohTiming) add(res benchmark.Result) {
+	if res.TTFB <= 0 {
+		return
+	}
+	d.Count++
+	if res.HTTPVersion != "" {
+		d.HTTPVersion = res.HTTPVersion
+	}
+	d.ttfb += res.TTFB
+	d.body += max(res.Duration-res.TTFB, 0)
+}
+
+func (d *dohTiming) finish() {
+	if d.Count > 0 {
+		d.AvgTTFB = d.ttfb / time.Duration(d.Count)
+		d.AvgBody = d.body / time.Duration(d.Count)
+	}
+}
+
+// bodyShare is the percentage of the average response time spent after the
+// first byte arrived.
+func (d *dohTiming) bodyShare() float64 {
+	total := d.AvgTTFB + d.AvgBody
+	if total == 0 {
+		return 0
+	}
+	return float64(d.AvgBody) / float64(total) * 100
+}
+
+// printDoHTiming lists TTFB and body read time for every DoH server.
+func printDoHTiming(stats []*ServerStats) {
+	var doh []*ServerStats
+	for _, s := range stats {
+		if s.DoH.Count > 0 {
+			doh = append(doh, s)
+		}
+	}
+	if len(doh) == 0 {
+		return
+	}
+
+	fmt.Printf("\nDoH Response Timing\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tHTTP\tAVG TTFB\tAVG BODY\tBODY %"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range doh {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%.1f%%\n", s.Server, orNone(s.DoH.HTTPVersion), s.DoH.AvgTTFB, s.DoH.AvgBody, s.DoH.bodyShare()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+	"dns-bench/validation"
+
+	"github.com/miekg/dns"
+)
+
+// duelMinPairs is the fewest pairs both servers answered that a verdict is
+// given for; below it the signed-rank test has too little to go on.
+const duelMinPairs = 10
+
+// duelAlpha is the significance level of the verdict.
+const duelAlpha = 0.05
+
+// duelBuckets are the upper bounds of the distribution overlay's latency
+// buckets, roughly logarithmic so fast and slow servers both get detail.
+var duelBuckets = []time.Duration{
+	2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	20 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	200 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+// runDuel implements the duel subcommand, which runs a matched workload
+// against exactly two servers and reports on them head to head.
+func runDuel(args []string) error {
+	fs := flag.NewFlagSet("duel", flag.ExitOnError)
+	var (
+		iterations  int
+		duration    time.Duration
+		concurrency int
+		timeout     time.Duration
+		queryType   string
+		domainFile  string
+		verbose     bool
+	)
+	fs.IntVar(&iterations, "n", 20, "Number of iterations per domain")
+	fs.DurationVar(&duration, "d", 0, "Duration to run the duel (e.g. 1m). Overrides -n if set.")
+	fs.IntVar(&concurrency, "c", 10, "Number of domains queried at once")
+	fs.DurationVar(&timeout, "t", time.Second, "Timeout for each query")
+	fs.StringVar(&queryType, "type", "A", "Record type to query")
+	fs.StringVar(&domainFile, "domains", "", "File containing list of domains (one per line or CSV)")
+	fs.BoolVar(&verbose, "v", false, "Verbose logging (show errors and slow queries)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s duel [options] <server-a> <server-b>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly two servers")
+	}
+	servers := fs.Args()
+	for _, s := range servers {
+		if err := validation.IsValidServer(s); err != nil {
+			return fmt.Errorf("invalid server %q: %w", s, err)
+		}
+	}
+	if servers[0] == servers[1] {
+		return fmt.Errorf("a server can't duel itself")
+	}
+	qtype, ok := dns.StringToType[strings.ToUpper(queryType)]
+	if !ok {
+		return fmt.Errorf("unknown query type %q", queryType)
+	}
+
+	warns := &warningLog{}
+	domains := defaultDomains
+	if domainFile != "" {
+		var err error
+		domains, _, err = readDomains(domainFile, warns)
+		if err != nil {
+			return fmt.Errorf("reading domain file: %w", err)
+		}
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("no domains to query")
+	}
+
+	if duration > 0 {
+		fmt.Printf("Duel: %s vs %s, %d domains for %v...\n", servers[0], servers[1], len(domains), duration)
+	} else {
+		fmt.Printf("Duel: %s vs %s, %d domains x %d iterations...\n", servers[0], servers[1], len(domains), iterations)
+	}
+	start := time.Now()
+	pairs := benchmark.RunDuel(benchmark.Config{
+		Servers:     servers,
+		Domains:     domains,
+		Iterations:  iterations,
+		Concurrency: concurrency,
+		Timeout:     timeout,
+		QType:       qtype,
+		Duration:    duration,
+		Verbose:     verbose,
+	})
+	fmt.Printf("\nDuel Complete in %v (%d matched pairs)\n", time.Since(start).Round(time.Millisecond), len(pairs))
+
+	printDuel(servers[0], servers[1], pairs)
+	printWarnings(warns.warnings(), verbose)
+	return nil
+}
+
+// duelSide summarises one server's half of a duel.
+type duelSide struct {
+	Server        string
+	Queries       int
+	Errors        int
+	P50, P90, P99 time.Duration
+	Avg           time.Duration
+	// Wins counts the pairs both servers answered in which this one was faster
+	Wins int
+
+	durations []time.Duration // Successful queries, sorted
+}
+
+func (d *duelSide) add(res benchmark.Result) {
+	d.Queries++
+	if res.Error != nil {
+		d.Errors++
+		return
+	}
+	d.durations = append(d.durations, res.Duration)
+}
+
+func (d *duelSide) finish() {
+	if len(d.durations) == 0 {
+		return
+	}
+	sort.Slice(d.durations, func(i, j int) bool { return d.durations[i] < d.durations[j] })
+	d.P50 = percentile(d.durations, 50)
+	d.P90 = percentile(d.durations, 90)
+	d.P99 = percentile(d.durations, 99)
+	var total time.Duration
+	for _, v := range d.durations {
+		total += v
+	}
+	d.Avg = total / time.Duration(len(d.durations))
+}
+
+func (d *duelSide) lossPct() float64 {
+	if d.Queries == 0 {
+		return 0
+	}
+	return float64(d.Errors) / float64(d.Queries) * 100
+}
+
+// bucketShares returns the percentage of successful queries falling into
+// each of duelBuckets, plus a final bucket for anything slower.
+func (d *duelSide) bucketShares() []float64 {
+	shares := make([]float64, len(duelBuckets)+1)
+	if len(d.durations) == 0 {
+		return shares
+	}
+	for _, v := range d.durations {
+		i := sort.Search(len(duelBuckets), func(i int) bool { return v <= duelBuckets[i] })
+		shares[i]++
+	}
+	for i := range shares {
+		shares[i] = shares[i] / float64(len(d.durations)) * 100
+	}
+	return shares
+}
+
+// percentile returns the p-th percentile (nearest rank) of sorted.
+func percentile[T cmp.Ordered](sorted []T, p float64) T {
+	if len(sorted) == 0 {
+		var zero T
+		return zero
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// duelVerdict is the outcome of a Wilcoxon signed-rank test on the latency
+// differences of the pairs both servers answered.
+type duelVerdict struct {
+	Pairs int
+	// MedianDiff is the median of A's latency minus B's; negative when A is
+	// usually faster
+	MedianDiff time.Duration
+	P          float64
+	// Winner is the significantly faster server, or "" when there is none
+	Winner string
+}
+
+func (v duelVerdict) String() string {
+	switch {
+	case v.Pairs < duelMinPairs:
+		return fmt.Sprintf("Not enough pairs answered by both servers for a verdict (%d, need %d)", v.Pairs, duelMinPairs)
+	case v.Winner == "":
+		return fmt.Sprintf("No significant difference (p=%.3f over %d pairs)", v.P, v.Pairs)
+	default:
+		diff := v.MedianDiff
+		if diff < 0 {
+			diff = -diff
+		}
+		return fmt.Sprintf("%s is faster by a median %v per query (p=%.3g over %d pairs)", v.Winner, diff, v.P, v.Pairs)
+	}
+}
+
+// judgeDuel compares the servers over the pairs both of them answered.
+func judgeDuel(a, b string, pairs []benchmark.DuelPair) duelVerdict {
+	var diffs []time.Duration
+	for _, p := range pairs {
+		if p.A.Error == nil && p.B.Error == nil {
+			diffs = append(diffs, p.A.Duration-p.B.Duration)
+		}
+	}
+	v := duelVerdict{Pairs: len(diffs), P: 1}
+	if len(diffs) < duelMinPairs {
+		return v
+	}
+	sorted := append([]time.Duration(nil), diffs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	v.MedianDiff = sorted[len(sorted)/2]
+
+	var z float64
+	z, v.P = signedRank(diffs)
+	if v.P < duelAlpha {
+		if z < 0 {
+			v.Winner = a
+		} else {
+			v.Winner = b
+		}
+	}
+	return v
+}
+
+// signedRank runs a two-sided Wilcoxon signed-rank test on paired
+// differences using the normal approximation with tie and continuity
+// corrections. Zero differences are dropped. z is positive when the
+// differences tend to be positive.
+func signedRank(diffs []time.Duration) (z, p float64) {
+	var nonzero []time.Duration
+	for _, d := range diffs {
+		if d != 0 {
+			nonzero = append(nonzero, d)
+		}
+	}
+	n := float64(len(nonzero))
+	if n == 0 {
+		return 0, 1
+	}
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.Slice(nonzero, func(i, j int) bool { return abs(nonzero[i]) < abs(nonzero[j]) })
+
+	var wPlus, ties float64
+	for i := 0; i < len(nonzero); {
+		j := i
+		for j < len(nonzero) && abs(nonzero[j]) == abs(nonzero[i]) {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // Average of ranks i+1..j
+		for k := i; k < j; k++ {
+			if nonzero[k] > 0 {
+				wPlus += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	mean := n * (n + 1) / 4
+	variance := n*(n+1)*(2*n+1)/24 - ties/48
+	if variance <= 0 {
+		return 0, 1
+	}
+	dev := wPlus - mean
+	switch {
+	case dev > 0.5:
+		dev -= 0.5
+	case dev < -0.5:
+		dev += 0.5
+	default:
+		dev = 0
+	}
+	z = dev / math.Sqrt(variance)
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// printDuel writes the head-to-head table, the overlaid latency
+// distributions and the verdict.
+func printDuel(a, b string, pairs []benchmark.DuelPair) {
+	sideA, sideB := &duelSide{Server: a}, &duelSide{Server: b}
+	for _, p := range pairs {
+		sideA.add(p.A)
+		sideB.add(p.B)
+		if p.A.Error == nil && p.B.Error == nil {
+			switch {
+			case p.A.Duration < p.B.Duration:
+				sideA.Wins++
+			case p.B.Duration < p.A.Duration:
+				sideB.Wins++
+			}
+		}
+	}
+	sideA.finish()
+	sideB.finish()
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tQUERIES\tP50\tP90\tP99\tAVG\tLOSS %\tWINS"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range []*duelSide{sideA, sideB} {
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\t%.2f%%\t%d\n", s.Server, s.Queries, s.P50, s.P90, s.P99, s.Avg, s.lossPct(), s.Wins); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	printDuelDistribution(sideA, sideB)
+
+	fmt.Printf("\nVerdict: %s\n", judgeDuel(a, b, pairs))
+}
+
+// printDuelDistribution overlays the two servers' latency histograms, one
+// bar per server for each bucket, scaled to the largest bucket.
+func printDuelDistribution(a, b *duelSide) {
+	sharesA, sharesB := a.bucketShares(), b.bucketShares()
+	peak := 0.0
+	for i := range sharesA {
+		peak = max(peak, sharesA[i], sharesB[i])
+	}
+	if peak == 0 {
+		return
+	}
+
+	const width = 40
+	bar := func(share float64, fill string) string {
+		n := int(math.Round(share / peak * width))
+		if n == 0 && share > 0 {
+			n = 1
+		}
+		return strings.Repeat(fill, n)
+	}
+
+	fmt.Printf("\nLatency Distribution (█ %s, ░ %s)\n\n", a.Server, b.Server)
+	for i := range sharesA {
+		if sharesA[i] == 0 && sharesB[i] == 0 {
+			continue
+		}
+		label := fmt.Sprintf("> %v", duelBuckets[len(duelBuckets)-1])
+		if i < len(duelBuckets) {
+			label = fmt.Sprintf("≤ %v", duelBuckets[i])
+		}
+		fmt.Printf("%8s  %s %.1f%%\n", label, bar(sharesA[i], "█"), sharesA[i])
+		fmt.Printf("%8s  %s %.1f%%\n", "", bar(sharesB[i], "░"), sharesB[i])
+	}
+}
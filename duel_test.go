@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// duelPairs builds pairs where A takes a and B takes b for every entry.
+func duelPairs(a, b []time.Duration) []benchmark.DuelPair {
+	pairs := make([]benchmark.DuelPair, len(a))
+	for i := range a {
+		pairs[i] = benchmark.DuelPair{
+			Domain: "example.com",
+			A:      benchmark.Result{Server: "A", Duration: a[i]},
+			B:      benchmark.Result{Server: "B", Duration: b[i]},
+		}
+	}
+	return pairs
+}
+
+func TestJudgeDuel(t *testing.T) {
+	var fast, slow []time.Duration
+	for i := 0; i < 30; i++ {
+		fast = append(fast, time.Duration(10+i%5)*time.Millisecond)
+		slow = append(slow, time.Duration(20+i%7)*time.Millisecond)
+	}
+
+	v := judgeDuel("A", "B", duelPairs(fast, slow))
+	if v.Winner != "A" || v.P >= duelAlpha || v.MedianDiff >= 0 {
+		t.Errorf("expected A to win, got %+v", v)
+	}
+	v = judgeDuel("A", "B", duelPairs(slow, fast))
+	if v.Winner != "B" {
+		t.Errorf("expected B to win, got %+v", v)
+	}
+	if !strings.HasPrefix(v.String(), "B is faster") {
+		t.Errorf("unexpected verdict: %s", v)
+	}
+
+	// Alternating wins of the same size are no evidence either way
+	mixedA, mixedB := make([]time.Duration, 30), make([]time.Duration, 30)
+	for i := range mixedA {
+		mixedA[i], mixedB[i] = 10*time.Millisecond, 12*time.Millisecond
+		if i%2 == 1 {
+			mixedA[i], mixedB[i] = mixedB[i], mixedA[i]
+		}
+	}
+	v = judgeDuel("A", "B", duelPairs(mixedA, mixedB))
+	if v.Winner != "" || v.P < 0.9 {
+		t.Errorf("expected no significant difference, got %+v", v)
+	}
+}
+
+func TestJudgeDuelTooFewPairs(t *testing.T) {
+	pairs := duelPairs(
+		[]time.Duration{time.Millisecond, time.Millisecond},
+		[]time.Duration{time.Second, time.Second},
+	)
+	pairs = append(pairs, benchmark.DuelPair{A: benchmark.Result{Error: errors.New("timeout")}})
+	v := judgeDuel("A", "B", pairs)
+	if v.Pairs != 2 || v.Winner != "" {
+		t.Errorf("expected 2 pairs and no winner, got %+v", v)
+	}
+	if !strings.HasPrefix(v.String(), "Not enough pairs") {
+		t.Errorf("unexpected verdict: %s", v)
+	}
+}
+
+func TestSignedRankTies(t *testing.T) {
+	// All differences equal: every rank ties, and the test must still run
+	diffs := make([]time.Duration, 20)
+	for i := range diffs {
+		diffs[i] = -time.Millisecond
+	}
+	z, p := signedRank(diffs)
+	if z >= 0 || p >= 0.001 {
+		t.Errorf("expected a strongly negative z, got z=%.2f p=%.4f", z, p)
+	}
+	if _, p := signedRank(make([]time.Duration, 5)); p != 1 {
+		t.Errorf("expected p=1 for all-zero differences, got %v", p)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{{50, 5}, {90, 9}, {99, 10}, {0, 1}} {
+		if got := percentile(sorted, tc.p); got != tc.want {
+			t.Errorf("percentile(%v) = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+	if percentile([]time.Duration(nil), 50) != 0 {
+		t.Error("expected 0 for no samples")
+	}
+}
+
+func TestDuelBucketShares(t *testing.T) {
+	s := &duelSide{}
+	for _, d := range []time.Duration{time.Millisecond, 3 * time.Millisecond, 4 * time.Millisecond, 2 * time.Second} {
+		s.add(benchmark.Result{Duration: d})
+	}
+	s.add(benchmark.Result{Error: errors.New("timeout")})
+	s.finish()
+
+	shares := s.bucketShares()
+	if shares[0] != 25 || shares[1] != 50 || shares[len(shares)-1] != 25 {
+		t.Errorf("unexpected shares: %v", shares)
+	}
+	if s.lossPct() != 20 {
+		t.Errorf("expected 20%% loss, got %.1f", s.lossPct())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// ecsVerdict says what a resolver did with the client subnet, given the
+// one sent (invalid when none was) and the one Google's servers saw.
+func ecsVerdict(sent netip.Prefix, res benchmark.ECSProbeResult) (verdict string, leaks bool) {
+	if res.Err != nil {
+		return "probe failed: " + res.Err.Error(), false
+	}
+	if res.Subnet == "" {
+		if sent.IsValid() {
+			return "strips ECS", false
+		}
+		return "no ECS", false
+	}
+	seen, err := netip.ParsePrefix(res.Subnet)
+	if err == nil && sent.IsValid() && sent.Contains(seen.Addr()) {
+		return "forwards your ECS option", false
+	}
+	return "adds your subnet", true
+}
+
+// printECSProbe asks every server for Google's echo name and shows, per
+// server, where its upstream queries come from and whether it passes a
+// client subnet on to authoritative servers.
+func printECSProbe(config benchmark.Config) {
+	fmt.Printf("\nChecking EDNS Client Subnet forwarding...\n")
+	byServer := benchmark.RunECSProbe(config)
+
+	fmt.Printf("\nEDNS Client Subnet\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tEGRESS\tSUBNET SEEN UPSTREAM\tVERDICT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var leaky []string
+	for _, server := range config.Servers {
+		res := byServer[server]
+		verdict, leaks := ecsVerdict(config.ClientSubnet, res)
+		if leaks {
+			leaky = append(leaky, server)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", server, orNone(res.Egress), orNone(res.Subnet), verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(leaky) > 0 {
+		fmt.Printf("⚠️  These servers tell authoritative servers which network you query from: %s\n", strings.Join(leaky, ", "))
+	} else {
+		fmt.Println("✓ No server revealed your network to authoritative servers")
+	}
+}
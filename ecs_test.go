@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestECSVerdict(t *testing.T) {
+	sent := netip.MustParsePrefix("198.51.100.0/24")
+	for _, tc := range []struct {
+		sent  netip.Prefix
+		res   benchmark.ECSProbeResult
+		want  string
+		leaks bool
+	}{
+		{netip.Prefix{}, benchmark.ECSProbeResult{Egress: "192.0.2.1"}, "no ECS", false},
+		{sent, benchmark.ECSProbeResult{Egress: "192.0.2.1"}, "strips ECS", false},
+		{sent, benchmark.ECSProbeResult{Egress: "192.0.2.1", Subnet: "198.51.100.0/24"}, "forwards your ECS option", false},
+		{netip.Prefix{}, benchmark.ECSProbeResult{Egress: "192.0.2.1", Subnet: "203.0.113.0/24"}, "adds your subnet", true},
+		{sent, benchmark.ECSProbeResult{Err: errors.New("REFUSED")}, "probe failed: REFUSED", false},
+	} {
+		got, leaks := ecsVerdict(tc.sent, tc.res)
+		if got != tc.want || leaks != tc.leaks {
+			t.Errorf("ecsVerdict(%v, %+v) = %q, %v, want %q, %v", tc.sent, tc.res, got, leaks, tc.want, tc.leaks)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"dns-bench/benchmark"
+)
+
+// ednsLabel describes the EDNS0 settings of cfg for the run header, or
+// returns "" when queries carry no OPT record.
+func ednsLabel(cfg *Config) string {
+	if cfg.EDNSSize == 0 && !cfg.DNSSEC && !cfg.EDNSPadding && cfg.ClientSubnet == "" {
+		return ""
+	}
+	size := cfg.EDNSSize
+	if size == 0 {
+		size = benchmark.DefaultUDPSize
+	}
+	parts := []string{fmt.Sprintf("UDP size %d", size)}
+	if cfg.DNSSEC {
+		parts = append(parts, "DO bit")
+	}
+	if cfg.ClientSubnet != "" {
+		parts = append(parts, "client subnet "+cfg.ClientSubnet)
+	}
+	if cfg.EDNSPadding {
+		parts = append(parts, "padding")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printTruncated names the servers whose responses didn't fit the UDP
+// payload size, which a real client would have had to repeat over TCP.
+func printTruncated(stats []*ServerStats, results []benchmark.Result) {
+	truncated := make(map[string]int)
+	for _, res := range results {
+		if res.Truncated {
+			truncated[res.Server]++
+		}
+	}
+	for _, s := range stats {
+		if n := truncated[s.Server]; n > 0 {
+			fmt.Printf("⚠️  %s truncated %d of %d responses; a client would retry those over TCP\n", s.Server, n, s.Total)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestEDNSLabel(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{}, ""},
+		{Config{DNSSEC: true}, "UDP size 1232, DO bit"},
+		{Config{EDNSSize: 4096, EDNSPadding: true}, "UDP size 4096, padding"},
+		{Config{ClientSubnet: "203.0.113.0/24"}, "UDP size 1232, client subnet 203.0.113.0/24"},
+	} {
+		if got := ednsLabel(&tc.cfg); got != tc.want {
+			t.Errorf("ednsLabel(%+v) = %q, want %q", tc.cfg, got, tc.want)
+		}
+	}
+}
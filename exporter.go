@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"dns-bench/benchmark"
+)
+
+// exporter sends a finished run somewhere the built-in exports don't reach,
+// such as a corporate API or a time series database. Exporters are compiled
+// in: a file in this package registers one from its init function with
+// registerExporter, and -export name[=target] turns it on for a run.
+//
+//	func init() {
+//		registerExporter("acme", func(target string) (exporter, error) {
+//			return &acmeExporter{endpoint: target}, nil
+//		})
+//	}
+type exporter interface {
+	// Name identifies the exporter in messages
+	Name() string
+	// Write is called once, after the run and the built-in exports, with
+	// the same (anonymized, with -anonymize) results and stats they got
+	Write(results []benchmark.Result, stats []*ServerStats) error
+}
+
+// exporterFactory creates an exporter for the target given after the name
+// in -export name=target; target is empty for a bare name.
+type exporterFactory func(target string) (exporter, error)
+
+var (
+	exportersMu sync.Mutex
+	exporters   = map[string]exporterFactory{}
+)
+
+// registerExporter makes an exporter available to -export under name.
+// Registering a name twice is a programming error and panics.
+func registerExporter(name string, factory exporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if _, ok := exporters[name]; ok {
+		panic("exporter registered twice: " + name)
+	}
+	exporters[name] = factory
+}
+
+// exporterNames lists the registered exporters for help and error messages.
+func exporterNames() string {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if len(exporters) == 0 {
+		return "none compiled in"
+	}
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// openExporters creates the exporters for specs of the form name or
+// name=target, so a misspelt name or bad target fails before the run.
+func openExporters(specs []string) ([]exporter, error) {
+	var out []exporter
+	for _, spec := range specs {
+		name, target, _ := strings.Cut(spec, "=")
+		exportersMu.Lock()
+		factory, ok := exporters[name]
+		exportersMu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown exporter %q (available: %s)", name, exporterNames())
+		}
+		e, err := factory(target)
+		if err != nil {
+			return nil, fmt.Errorf("exporter %s: %w", name, err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// runExporters hands the run to each exporter in turn; one failing doesn't
+// stop the others.
+func runExporters(exps []exporter, results []benchmark.Result, stats []*ServerStats) {
+	for _, e := range exps {
+		if err := e.Write(results, stats); err != nil {
+			fmt.Printf("Error exporting to %s: %v\n", e.Name(), err)
+		} else {
+			fmt.Printf("Results exported to %s\n", e.Name())
+		}
+	}
+}
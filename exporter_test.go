@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+type recordingExporter struct {
+	target  string
+	results int
+	stats   int
+}
+
+func (r *recordingExporter) Name() string { return "recording" }
+
+func (r *recordingExporter) Write(results []benchmark.Result, stats []*ServerStats) error {
+	r.results, r.stats = len(results), len(stats)
+	return nil
+}
+
+func TestExporters(t *testing.T) {
+	var opened *recordingExporter
+	registerExporter("test-recording", func(target string) (exporter, error) {
+		if target == "" {
+			return nil, errors.New("needs a target")
+		}
+		opened = &recordingExporter{target: target}
+		return opened, nil
+	})
+	defer func() {
+		exportersMu.Lock()
+		delete(exporters, "test-recording")
+		exportersMu.Unlock()
+	}()
+
+	if !strings.Contains(exporterNames(), "test-recording") {
+		t.Errorf("expected the registered exporter to be listed, got %q", exporterNames())
+	}
+	if _, err := openExporters([]string{"nope"}); err == nil || !strings.Contains(err.Error(), "unknown exporter") {
+		t.Errorf("expected an unknown exporter error, got %v", err)
+	}
+	if _, err := openExporters([]string{"test-recording"}); err == nil || !strings.Contains(err.Error(), "needs a target") {
+		t.Errorf("expected the factory's error, got %v", err)
+	}
+
+	exps, err := openExporters([]string{"test-recording=https://tsdb.example/write?db=dns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.target != "https://tsdb.example/write?db=dns" {
+		t.Errorf("expected everything after the first = as the target, got %q", opened.target)
+	}
+
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "example.com", Duration: 10 * time.Millisecond},
+		{Server: "1.1.1.1", Domain: "example.com", Duration: 5 * time.Millisecond},
+	}
+	runExporters(exps, results, calculateStats(results))
+	if opened.results != 2 || opened.stats != 2 {
+		t.Errorf("expected the exporter to get 2 results and 2 server stats, got %+v", opened)
+	}
+}
+
+func TestRegisterExporterTwice(t *testing.T) {
+	factory := func(string) (exporter, error) { return &recordingExporter{}, nil }
+	registerExporter("test-twice", factory)
+	defer func() {
+		exportersMu.Lock()
+		delete(exporters, "test-twice")
+		exportersMu.Unlock()
+	}()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a name twice to panic")
+		}
+	}()
+	registerExporter("test-twice", factory)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// familyCell formats one address family's connection time for the table.
+func familyCell(f benchmark.FamilyConnect) string {
+	if f.Err != nil {
+		return "failed"
+	}
+	return f.Connect.Round(time.Microsecond).String()
+}
+
+// printHappyEyeballs measures connection setup to every dual-stack DoH and
+// DoT server over each address family, with Happy Eyeballs and IPv6-first,
+// and explains what a broken or slow IPv6 path costs clients.
+func printHappyEyeballs(config benchmark.Config) {
+	if config.Proxy != nil {
+		fmt.Println("\nHappy Eyeballs: skipped, the proxy decides which address family to use")
+		return
+	}
+	results := benchmark.RunHappyEyeballs(config)
+	if len(results) == 0 {
+		fmt.Println("\nHappy Eyeballs: no dual-stack DoH or DoT servers given by hostname")
+		return
+	}
+
+	fmt.Printf("\nHappy Eyeballs (TCP + TLS connection setup to dual-stack servers)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tIPV6\tIPV4\tHAPPY EYEBALLS\tIPV6 FIRST"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range results {
+		raced, sequential := "failed", "failed"
+		if r.Err == nil {
+			raced = fmt.Sprintf("%v (%s)", r.Raced.Round(time.Microsecond), r.Winner)
+		}
+		if r.SequentialFamily != "" {
+			sequential = fmt.Sprintf("%v (%s)", r.Sequential.Round(time.Microsecond), r.SequentialFamily)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Server, familyCell(r.IPv6), familyCell(r.IPv4), raced, sequential); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	for _, r := range results {
+		switch {
+		case r.IPv6.Err != nil && r.IPv4.Err == nil:
+			fmt.Printf("⚠️  %s is unreachable over IPv6: Happy Eyeballs clients fall back to IPv4, others wait %v before they do\n", r.Server, r.Sequential.Round(time.Millisecond))
+		case r.Err == nil && r.Winner == "IPv4":
+			fmt.Printf("⚠️  %s: IPv4 won the race, so IPv6 to it is slow; latency varies with whether a client races families\n", r.Server)
+		}
+	}
+}
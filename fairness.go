@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// underSampledShare is the share of the median query count below which a
+// server counts as under-sampled: the shared worker pool spent its time on
+// the others, so the server's statistics rest on few queries.
+const underSampledShare = 0.5
+
+// fairnessStats is how much of the benchmark one server actually got.
+type fairnessStats struct {
+	Server  string
+	Queries int
+	// Window is the wall-clock time from the server's first query starting
+	// to its last one finishing
+	Window time.Duration
+	// QPS is Queries over Window, the rate the server was really tested at
+	QPS float64
+	// Share is Queries relative to the median across servers
+	Share        float64
+	UnderSampled bool
+}
+
+// checkFairness works out, for each server in stats order, how many
+// queries it was sent and over what window, and flags servers that got
+// far fewer queries than the median.
+func checkFairness(stats []*ServerStats, results []benchmark.Result) []fairnessStats {
+	type window struct {
+		first, last time.Time
+	}
+	windows := make(map[string]*window)
+	for _, res := range results {
+		if res.Time.IsZero() {
+			continue
+		}
+		end := res.Time.Add(res.Duration)
+		w := windows[res.Server]
+		if w == nil {
+			windows[res.Server] = &window{first: res.Time, last: end}
+			continue
+		}
+		if res.Time.Before(w.first) {
+			w.first = res.Time
+		}
+		if end.After(w.last) {
+			w.last = end
+		}
+	}
+
+	counts := make([]int, len(stats))
+	for i, s := range stats {
+		counts[i] = s.Total
+	}
+	slices.Sort(counts)
+	var median float64
+	if len(counts) > 0 {
+		median = float64(counts[len(counts)/2])
+	}
+
+	out := make([]fairnessStats, 0, len(stats))
+	for _, s := range stats {
+		f := fairnessStats{Server: s.Server, Queries: s.Total}
+		if w := windows[s.Server]; w != nil {
+			f.Window = w.last.Sub(w.first)
+		}
+		if f.Window > 0 {
+			f.QPS = float64(f.Queries) / f.Window.Seconds()
+		}
+		if median > 0 {
+			f.Share = float64(f.Queries) / median
+			f.UnderSampled = f.Share < underSampledShare
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// printFairness shows the queries, window and effective rate of every
+// server. With always unset it stays quiet unless a server was
+// under-sampled.
+func printFairness(checks []fairnessStats, always bool) {
+	var under []string
+	for _, f := range checks {
+		if f.UnderSampled {
+			under = append(under, f.Server)
+		}
+	}
+	if len(checks) < 2 || (!always && len(under) == 0) {
+		return
+	}
+
+	fmt.Printf("\nQuery Distribution (per server)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tQUERIES\tWINDOW\tQPS\tVS. MEDIAN\t"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, f := range checks {
+		flag := ""
+		if f.UnderSampled {
+			flag = "⚠️  under-sampled"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%v\t%.1f\t%.0f%%\t%s\n", f.Server, f.Queries, f.Window.Round(time.Millisecond), f.QPS, f.Share*100, flag); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(under) > 0 {
+		fmt.Printf("⚠️  These servers got less than %.0f%% of the median query count, so treat their statistics with caution (try a longer run or -server-qps): %s\n", underSampledShare*100, strings.Join(under, ", "))
+	} else {
+		fmt.Println("✓ Every server got a comparable share of the queries")
+	}
+}
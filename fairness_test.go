@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestCheckFairness(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var results []benchmark.Result
+	add := func(server string, n int, every, took time.Duration) {
+		for i := range n {
+			results = append(results, benchmark.Result{Server: server, Duration: took, Time: start.Add(time.Duration(i) * every)})
+		}
+	}
+	add("fast1", 100, 10*time.Millisecond, 10*time.Millisecond)
+	add("fast2", 90, 10*time.Millisecond, 10*time.Millisecond)
+	add("slow", 20, 50*time.Millisecond, 50*time.Millisecond)
+
+	checks := checkFairness(calculateStats(results), results)
+	byServer := make(map[string]fairnessStats)
+	for _, f := range checks {
+		byServer[f.Server] = f
+	}
+
+	fast := byServer["fast1"]
+	if fast.Queries != 100 || fast.Window != time.Second || fast.QPS != 100 {
+		t.Errorf("fast1: expected 100 queries in 1s at 100 QPS, got %+v", fast)
+	}
+	if fast.UnderSampled || byServer["fast2"].UnderSampled {
+		t.Errorf("servers near the median should not be flagged: %+v", checks)
+	}
+	slow := byServer["slow"]
+	if !slow.UnderSampled || slow.Window != time.Second || slow.Share != 20.0/90 {
+		t.Errorf("slow: expected an under-sampled server over 1s, got %+v", slow)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// fallbackStats summarises stub resolver emulation for a plain DNS server:
+// how often UDP was not enough, which transport finally answered, and what
+// the fallbacks cost compared with queries answered directly over UDP.
+type fallbackStats struct {
+	Queries     int
+	FellBack    int
+	Via         map[string]int // Successful answers by transport
+	AvgDirect   time.Duration  // Successful queries answered over UDP
+	AvgFallback time.Duration  // Successful queries that needed a fallback
+
+	direct, fallback   time.Duration
+	directN, fallbackN int
+}
+
+func (f *fallbackStats) add(res benchmark.Result) {
+	if res.Transport == "" {
+		return
+	}
+	f.Queries++
+	if res.Fallbacks > 0 {
+		f.FellBack++
+	}
+	if res.Error != nil {
+		return
+	}
+	if f.Via == nil {
+		f.Via = make(map[string]int)
+	}
+	f.Via[res.Transport]++
+	if res.Fallbacks > 0 {
+		f.fallbackN++
+		f.fallback += res.Duration
+	} else {
+		f.directN++
+		f.direct += res.Duration
+	}
+}
+
+func (f *fallbackStats) finish() {
+	if f.directN > 0 {
+		f.AvgDirect = f.direct / time.Duration(f.directN)
+	}
+	if f.fallbackN > 0 {
+		f.AvgFallback = f.fallback / time.Duration(f.fallbackN)
+	}
+}
+
+// rate is the percentage of queries that needed at least one fallback.
+func (f *fallbackStats) rate() float64 {
+	if f.Queries == 0 {
+		return 0
+	}
+	return float64(f.FellBack) / float64(f.Queries) * 100
+}
+
+// printFallback lists the fallback rate and per-transport latency for every
+// server queried in fallback mode. AVG LATENCY in the main table already
+// includes the cost of fallbacks.
+func printFallback(stats []*ServerStats) {
+	var plain []*ServerStats
+	for _, s := range stats {
+		if s.Fallback.Queries > 0 {
+			plain = append(plain, s)
+		}
+	}
+	if len(plain) == 0 {
+		return
+	}
+
+	fmt.Printf("\nTransport Fallback (UDP -> TCP -> DoT)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tFALLBACK %\tVIA UDP\tVIA TCP\tVIA DOT\tAVG DIRECT\tAVG WITH FALLBACK"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range plain {
+		f := &s.Fallback
+		if _, err := fmt.Fprintf(w, "%s\t%.2f%%\t%d\t%d\t%d\t%s\t%s\n", s.Server, f.rate(),
+			f.Via["udp"], f.Via["tcp"], f.Via["tls"], orDash(f.AvgDirect), orDash(f.AvgFallback)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
+
+// orDash formats d, or "-" when there were no samples.
+func orDash(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.String()
+}
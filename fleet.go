@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProbeConfig is one entry of the probes section: an identity a machine
+// running the shared fleet config takes on, so its runs are attributed to
+// the right site wherever they end up.
+type ProbeConfig struct {
+	Name string `yaml:"name"`
+	// Hosts are hostname patterns (path.Match globs, e.g. "lon-probe-*")
+	// of the machines that are this probe; a host named Name always is
+	Hosts []string `yaml:"hosts"`
+	// Tags are stamped on every run, e.g. {site: london, region: emea}
+	Tags map[string]string `yaml:"tags"`
+	// Upload receives every run as its JSON export, typically the collect
+	// subcommand of the central history store; UploadToken is sent with
+	// it as a bearer token
+	Upload      string `yaml:"upload"`
+	UploadToken string `yaml:"upload_token"`
+	// Schedules replace the config's schedules for the monitor on this
+	// probe
+	Schedules []ScheduleConfig `yaml:"schedules"`
+}
+
+// matches reports whether the machine named host is this probe.
+func (p *ProbeConfig) matches(host string) bool {
+	host = strings.ToLower(host)
+	if strings.EqualFold(p.Name, host) {
+		return true
+	}
+	for _, pattern := range p.Hosts {
+		if ok, err := path.Match(strings.ToLower(pattern), host); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectProbe picks the probe named name, or else the first one host
+// matches. Without probes it returns nil: the run isn't part of a fleet.
+func selectProbe(probes []ProbeConfig, name, host string) (*ProbeConfig, error) {
+	if len(probes) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("probe %q selected but the config has no probes", name)
+		}
+		return nil, nil
+	}
+	names := make([]string, len(probes))
+	seen := make(map[string]bool)
+	for i, p := range probes {
+		if p.Name == "" {
+			return nil, fmt.Errorf("probe %d has no name", i+1)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("probe %q is listed twice", p.Name)
+		}
+		seen[p.Name] = true
+		names[i] = p.Name
+	}
+	for i := range probes {
+		if name != "" && probes[i].Name == name {
+			return &probes[i], nil
+		}
+		if name == "" && probes[i].matches(host) {
+			return &probes[i], nil
+		}
+	}
+	if name != "" {
+		return nil, fmt.Errorf("unknown probe %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("no probe matches this host (%s); pass -probe (available: %s)", host, strings.Join(names, ", "))
+}
+
+// resolveProbe returns the probe of cfg this machine is: cfg.Probe if set,
+// else the one matching its hostname.
+func resolveProbe(cfg *Config) (*ProbeConfig, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		host = ""
+	}
+	return selectProbe(cfg.Probes, cfg.Probe, host)
+}
+
+// formatTags renders tags as sorted key=value pairs for logs.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// uploadRun posts a run's JSON export to the probe's upload target.
+func uploadRun(ctx context.Context, probe *ProbeConfig, results []benchmark.Result, prov *provenance) error {
+	body, err := json.Marshal(jsonExport{SchemaVersion: schemaVersion, Provenance: prov, Results: results})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, probe.Upload, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if probe.UploadToken != "" {
+		req.Header.Set("Authorization", "Bearer "+probe.UploadToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("upload returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// maxUploadSize bounds the JSON export collect accepts in one request.
+const maxUploadSize = 256 << 20
+
+// collector stores the runs probes upload in the history database at db.
+type collector struct {
+	db    string
+	token string
+	// mu serializes writes, so concurrent uploads don't contend for the
+	// database lock
+	mu sync.Mutex
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a JSON export", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+c.token)) != 1 {
+		http.Error(w, "missing or wrong upload token", http.StatusUnauthorized)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	prov, results, err := parseUpload(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stored, err := runStored(c.db, prov.RunID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: checking %s for run %s: %v\n", c.db, prov.RunID, err)
+		http.Error(w, "failed to read the history database", http.StatusInternalServerError)
+		return
+	}
+	// A probe retrying an upload that did arrive gets the same answer
+	if !stored {
+		if _, err := storeRun(c.db, prov, results, calculateStats(results), prov.TotalTime); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: storing run %s: %v\n", prov.RunID, err)
+			http.Error(w, "failed to store the run", http.StatusInternalServerError)
+			return
+		}
+		from := orNone(prov.Probe)
+		if len(prov.Tags) > 0 {
+			from += " (" + formatTags(prov.Tags) + ")"
+		}
+		fmt.Printf("[%s] Stored run %s from probe %s on %s: %d result(s)\n", time.Now().Format(time.RFC3339), prov.RunID, from, orNone(prov.Host), len(results))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseUpload reads an uploaded JSON export: one run with its provenance.
+func parseUpload(data []byte) (*provenance, []benchmark.Result, error) {
+	in, err := loadJSONExport(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON export: %w", err)
+	}
+	if len(in.Provenance) == 0 || string(in.Provenance) == "null" {
+		return nil, nil, errors.New("the export has no provenance; upload single runs, not merged files")
+	}
+	// The JSON form of the provenance uses its YAML keys, and JSON is YAML
+	var prov provenance
+	if err := yaml.Unmarshal(in.Provenance, &prov); err != nil {
+		return nil, nil, fmt.Errorf("invalid provenance: %w", err)
+	}
+	if prov.RunID == "" {
+		return nil, nil, errors.New("the export's provenance has no run_id")
+	}
+	for i := range in.Results {
+		in.Results[i].RunID = prov.RunID
+	}
+	return &prov, in.Results, nil
+}
+
+// runCollect implements the collect subcommand, which runs on the central
+// history store and stores the runs probes upload (see ProbeConfig.Upload)
+// in its database until interrupted.
+func runCollect(args []string) error {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	var listen, db, token string
+	fs.StringVar(&listen, "listen", ":8053", "Address to accept uploads on")
+	fs.StringVar(&db, "db", "", "SQLite database to store uploaded runs in (required)")
+	fs.StringVar(&token, "token", "", "Only accept uploads with this bearer token (a probe's upload_token)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s collect -db history.db [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if db == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("expected -db and no arguments")
+	}
+	// Fail now on a database this build can't write rather than on the
+	// first upload
+	conn, err := openResultsDB(db)
+	if err != nil {
+		return err
+	}
+	if err := conn.Close(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: &collector{db: db, token: token}, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+	fmt.Printf("Collecting uploaded runs on http://%s/ into %s\n", ln.Addr(), db)
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "Warning: accepting uploads from anyone; set -token to require the probes' upload_token")
+	}
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	fmt.Println("Collector stopped")
+	return nil
+}
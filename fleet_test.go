@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestSelectProbe(t *testing.T) {
+	probes := []ProbeConfig{
+		{Name: "london", Hosts: []string{"lon-probe-*"}},
+		{Name: "paris"},
+	}
+	for _, tc := range []struct {
+		name, host, want string
+	}{
+		{"", "LON-PROBE-03", "london"},
+		{"", "paris", "paris"},
+		{"paris", "lon-probe-01", "paris"},
+	} {
+		p, err := selectProbe(probes, tc.name, tc.host)
+		if err != nil || p.Name != tc.want {
+			t.Errorf("probe %q on %s: expected %s, got %+v (%v)", tc.name, tc.host, tc.want, p, err)
+		}
+	}
+	if p, err := selectProbe(nil, "", "laptop"); p != nil || err != nil {
+		t.Errorf("expected no probe without a fleet, got %+v (%v)", p, err)
+	}
+	for _, tc := range []struct {
+		probes     []ProbeConfig
+		name, host string
+	}{
+		{probes, "", "laptop"},
+		{probes, "berlin", "lon-probe-01"},
+		{nil, "paris", "paris"},
+		{[]ProbeConfig{{Name: "a"}, {Name: "a"}}, "a", "a"},
+	} {
+		if _, err := selectProbe(tc.probes, tc.name, tc.host); err == nil {
+			t.Errorf("expected probe %q on %s to be rejected", tc.name, tc.host)
+		}
+	}
+}
+
+func TestUploadAndCollect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "central.db")
+	srv := httptest.NewServer(&collector{db: path, token: "s3cret"})
+	defer srv.Close()
+
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "google.com", Duration: 12 * time.Millisecond, Protocol: "udp"},
+		{Server: "1.1.1.1", Domain: "google.com", Error: os.ErrDeadlineExceeded, Protocol: "udp"},
+	}
+	prov := &provenance{
+		RunID: "run1", Host: "lon-probe-01", Version: "test", GeneratedAt: time.Now().UTC(),
+		Config: Config{Timeout: 2 * time.Second, Servers: serverEntries([]string{"8.8.8.8", "1.1.1.1"})},
+		Probe:  "london", Tags: map[string]string{"site": "london", "region": "emea"}, TotalTime: 3 * time.Second,
+	}
+	probe := &ProbeConfig{Name: "london", Upload: srv.URL, UploadToken: "wrong"}
+	if err := uploadRun(context.Background(), probe, results, prov); err == nil {
+		t.Fatal("expected an upload with the wrong token to be refused")
+	}
+	probe.UploadToken = "s3cret"
+	// A retried upload is only stored once
+	for range 2 {
+		if err := uploadRun(context.Background(), probe, results, prov); err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+	}
+
+	db, err := openResultsDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var runs, rows int
+	var duration float64
+	if err := db.QueryRow("SELECT COUNT(*), MAX(duration_ms) FROM runs").Scan(&runs, &duration); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM results WHERE run_id = 'run1'").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 || rows != 2 || duration != 3000 {
+		t.Errorf("expected 1 run of 3000ms with 2 results, got %d of %.0fms with %d", runs, duration, rows)
+	}
+	var name, site string
+	if err := db.QueryRow("SELECT probe, json_extract(tags, '$.site') FROM run_probes WHERE run_id = 'run1'").Scan(&name, &site); err != nil {
+		t.Fatal(err)
+	}
+	if name != "london" || site != "london" {
+		t.Errorf("expected the run attributed to london, got probe %s at site %s", name, site)
+	}
+}
+
+func TestParseUploadLatencyUnit(t *testing.T) {
+	prov := testProvenance()
+	prov.Config.Unit = "us"
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := exportJSON([]benchmark.Result{{Server: "8.8.8.8", Domain: "google.com", Duration: 12345 * time.Microsecond}}, prov, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, results, err := parseUpload(data)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected 1 uploaded result, got %d (%v)", len(results), err)
+	}
+	if results[0].Duration != 12345*time.Microsecond || results[0].RunID != prov.RunID {
+		t.Errorf("expected the microsecond latency under run %s, got %+v", prov.RunID, results[0])
+	}
+}
+
+func TestProvenanceOmitsFleet(t *testing.T) {
+	cfg := &Config{Probes: []ProbeConfig{{Name: "london", UploadToken: "s3cret"}}, Probe: "london"}
+	prov := newProvenance(cfg, benchmark.Config{})
+	if len(prov.Config.Probes) != 0 || prov.Config.Probe != "london" {
+		t.Errorf("expected only the probe name in the snapshot, got %+v %q", prov.Config.Probes, prov.Config.Probe)
+	}
+}
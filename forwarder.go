@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// defaultHitRatio is the share of cache hits -forwarder drives when
+// -hit-ratio isn't given.
+const defaultHitRatio = 0.8
+
+// forwarderQueries summarizes one kind of query of a -forwarder run, e.g.
+// the forwarder's cache hits.
+type forwarderQueries struct {
+	Label         string
+	Sent          int
+	Answered      int
+	P50, P95, P99 time.Duration
+}
+
+// forwarderReport is what a -forwarder run found: the forwarder's hits and
+// misses and, with an upstream, the same mix sent to the upstream directly.
+type forwarderReport struct {
+	Forwarder, Upstream string
+	HitRatio            float64
+	Rows                []forwarderQueries
+	// Hit and Miss are the forwarder's p50s, UpstreamMiss the upstream's
+	// own (zero without an upstream)
+	Hit, Miss, UpstreamMiss time.Duration
+	// QPS is how many queries per second the forwarder answered, and
+	// FailedPct the share of its queries that failed
+	QPS       float64
+	FailedPct float64
+}
+
+// summarizeQueries returns the counts and percentiles of results of server
+// with the cache pass (benchmark.CacheWarm for hits, CacheCold for misses).
+func summarizeQueries(label string, results []benchmark.Result, server, pass string) forwarderQueries {
+	q := forwarderQueries{Label: label}
+	var durations []time.Duration
+	for _, res := range results {
+		if res.Server != server || res.CachePass != pass {
+			continue
+		}
+		q.Sent++
+		if res.Error == nil {
+			durations = append(durations, res.Duration)
+		}
+	}
+	slices.Sort(durations)
+	q.Answered = len(durations)
+	q.P50, q.P95, q.P99 = percentile(durations, 50), percentile(durations, 95), percentile(durations, 99)
+	return q
+}
+
+// analyzeForwarder splits the forwarder's latency into its cache hits and
+// misses and compares its misses with the upstream's.
+func analyzeForwarder(results []benchmark.Result, forwarder, upstream string, hitRatio float64) forwarderReport {
+	r := forwarderReport{Forwarder: forwarder, Upstream: upstream, HitRatio: hitRatio}
+	hits := summarizeQueries("cache hits", results, forwarder, benchmark.CacheWarm)
+	misses := summarizeQueries("cache misses", results, forwarder, benchmark.CacheCold)
+	r.Rows = []forwarderQueries{hits, misses}
+	r.Hit, r.Miss = hits.P50, misses.P50
+	if upstream != "" {
+		upMisses := summarizeQueries("upstream misses (direct)", results, upstream, benchmark.CacheCold)
+		upHits := summarizeQueries("upstream hits (direct)", results, upstream, benchmark.CacheWarm)
+		r.Rows = append(r.Rows, upMisses, upHits)
+		r.UpstreamMiss = upMisses.P50
+	}
+
+	// Offsets start after the warm-up, so the last answer ends the run
+	var elapsed time.Duration
+	for _, res := range results {
+		if res.Server == forwarder {
+			elapsed = max(elapsed, res.Offset+res.Duration)
+		}
+	}
+	sent, answered := hits.Sent+misses.Sent, hits.Answered+misses.Answered
+	if elapsed > 0 {
+		r.QPS = float64(answered) / elapsed.Seconds()
+	}
+	if sent > 0 {
+		r.FailedPct = float64(sent-answered) / float64(sent) * 100
+	}
+	return r
+}
+
+// printForwarder shows the forwarder's hits and misses and what of a miss
+// is spent inside the forwarder and what upstream.
+func printForwarder(r forwarderReport) {
+	fmt.Printf("\nForwarder Under Test (%s, %.0f%% cache hits)\n\n", r.Forwarder, r.HitRatio*100)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "QUERIES\tSENT\tANSWERED\tP50\tP95\tP99"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, q := range r.Rows {
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", q.Label, q.Sent, q.Answered,
+			orDash(q.P50.Round(time.Microsecond)), orDash(q.P95.Round(time.Microsecond)), orDash(q.P99.Round(time.Microsecond))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	round := func(d time.Duration) time.Duration { return max(d, 0).Round(time.Microsecond) }
+	if r.Hit > 0 {
+		fmt.Printf("Cache hit: %v at p50, all of it the forwarder and the local round trip\n", round(r.Hit))
+	}
+	switch {
+	case r.Miss == 0:
+	case r.UpstreamMiss > 0:
+		fmt.Printf("Cache miss: %v at p50 = %v upstream + %v inside the forwarder\n", round(r.Miss), round(r.UpstreamMiss), round(r.Miss-r.UpstreamMiss))
+	case r.Hit > 0:
+		fmt.Printf("Cache miss: %v at p50, about %v of it upstream (miss minus hit; -upstream measures it directly)\n", round(r.Miss), round(r.Miss-r.Hit))
+	}
+	fmt.Printf("Throughput: %.0f answered queries/s, %.1f%% failed\n", r.QPS, r.FailedPct)
+}
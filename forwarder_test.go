@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestAnalyzeForwarder(t *testing.T) {
+	const fwd, up = "127.0.0.1:5353", "9.9.9.9"
+	results := []benchmark.Result{
+		{Server: fwd, Duration: time.Millisecond, CachePass: benchmark.CacheWarm, Offset: 0},
+		{Server: fwd, Duration: time.Millisecond, CachePass: benchmark.CacheWarm, Offset: 500 * time.Millisecond},
+		{Server: fwd, Duration: 25 * time.Millisecond, CachePass: benchmark.CacheCold, Offset: time.Second},
+		{Server: fwd, CachePass: benchmark.CacheCold, Offset: 1500 * time.Millisecond, Error: errors.New("timeout")},
+		{Server: up, Duration: 20 * time.Millisecond, CachePass: benchmark.CacheCold, Offset: 3 * time.Second},
+		{Server: up, Duration: 10 * time.Millisecond, CachePass: benchmark.CacheWarm},
+	}
+	r := analyzeForwarder(results, fwd, up, 0.5)
+	if len(r.Rows) != 4 {
+		t.Fatalf("expected hits, misses and the upstream's two rows, got %+v", r.Rows)
+	}
+	if misses := r.Rows[1]; misses.Sent != 2 || misses.Answered != 1 {
+		t.Errorf("expected 2 misses sent and 1 answered, got %+v", misses)
+	}
+	if r.Hit != time.Millisecond || r.Miss != 25*time.Millisecond || r.UpstreamMiss != 20*time.Millisecond {
+		t.Errorf("expected 1ms hits, 25ms misses and 20ms upstream misses, got %v, %v and %v", r.Hit, r.Miss, r.UpstreamMiss)
+	}
+	// 3 answers over the forwarder's 1.5s, however long the upstream took
+	if r.QPS != 2 || r.FailedPct != 25 {
+		t.Errorf("expected 2 qps and 25%% failed, got %v and %v", r.QPS, r.FailedPct)
+	}
+
+	// Without an upstream there's only the forwarder's own rows
+	if r := analyzeForwarder(results, fwd, "", 0.5); len(r.Rows) != 2 || r.UpstreamMiss != 0 {
+		t.Errorf("expected only the forwarder's rows, got %+v", r)
+	}
+}
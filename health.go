@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// healthTTL is how long a server that failed every query is skipped on the
+// same network before it is tried again.
+const healthTTL = time.Hour
+
+// healthEntry records a server that didn't answer a single query on a
+// network.
+type healthEntry struct {
+	Server   string    `json:"server"`
+	Network  string    `json:"network"`
+	FailedAt time.Time `json:"failed_at"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// healthState is the small file that carries unreachable servers from one
+// run to the next, so iterative sessions don't wait out their timeouts
+// every time.
+type healthState struct {
+	Unreachable []healthEntry `json:"unreachable"`
+}
+
+// defaultHealthFile returns where the health state is kept, in the user's
+// cache directory, or "" when there isn't one.
+func defaultHealthFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-bench", "health.json")
+}
+
+// loadHealth reads the health state at path. A missing file is an empty
+// state.
+func loadHealth(path string) (*healthState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &healthState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var h healthState
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &h, nil
+}
+
+// save writes the state to path, dropping entries older than healthTTL.
+func (h *healthState) save(path string, now time.Time) error {
+	fresh := h.Unreachable[:0]
+	for _, e := range h.Unreachable {
+		if now.Sub(e.FailedAt) < healthTTL {
+			fresh = append(fresh, e)
+		}
+	}
+	h.Unreachable = fresh
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// recent returns the entry for server on network if it failed within
+// healthTTL of now.
+func (h *healthState) recent(server, network string, now time.Time) (healthEntry, bool) {
+	for _, e := range h.Unreachable {
+		if e.Server == server && e.Network == network && now.Sub(e.FailedAt) < healthTTL {
+			return e, true
+		}
+	}
+	return healthEntry{}, false
+}
+
+// skipUnreachable splits servers into those to benchmark and those that
+// were recently unreachable on network. When every server would be
+// skipped, none are: there would be nothing left to run.
+func (h *healthState) skipUnreachable(servers []string, network string, now time.Time) (keep []string, skipped []healthEntry) {
+	for _, s := range servers {
+		if e, ok := h.recent(s, network, now); ok {
+			skipped = append(skipped, e)
+		} else {
+			keep = append(keep, s)
+		}
+	}
+	if len(keep) == 0 {
+		return servers, nil
+	}
+	return keep, skipped
+}
+
+// update records the servers that failed every query of this run on
+// network and forgets the ones that answered.
+func (h *healthState) update(stats []*ServerStats, results []benchmark.Result, network string, now time.Time) {
+	lastErr := make(map[string]string)
+	for _, res := range results {
+		if res.Error != nil {
+			lastErr[res.Server] = res.Error.Error()
+		}
+	}
+	measured := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		measured[s.Server] = true
+	}
+
+	kept := h.Unreachable[:0]
+	for _, e := range h.Unreachable {
+		if e.Network != network || !measured[e.Server] {
+			kept = append(kept, e)
+		}
+	}
+	h.Unreachable = kept
+	for _, s := range stats {
+		if s.Total > 0 && s.Success == 0 {
+			h.Unreachable = append(h.Unreachable, healthEntry{Server: s.Server, Network: network, FailedAt: now, Error: lastErr[s.Server]})
+		}
+	}
+}
+
+// printSkipped explains which servers were left out and why.
+func printSkipped(skipped []healthEntry, now time.Time) {
+	if len(skipped) == 0 {
+		return
+	}
+	names := make([]string, len(skipped))
+	for i, e := range skipped {
+		names[i] = e.Server
+	}
+	fmt.Printf("Skipping %d server(s) unreachable on this network in the last %v (use -recheck to test them again): %s\n",
+		len(skipped), healthTTL, strings.Join(names, ", "))
+	for _, e := range skipped {
+		fmt.Printf("  - %s: failed every query %v ago (%s)\n", e.Server, now.Sub(e.FailedAt).Round(time.Second), orNone(e.Error))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestHealthState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns-bench", "health.json")
+	h, err := loadHealth(path)
+	if err != nil {
+		t.Fatalf("missing file should load as empty state: %v", err)
+	}
+
+	now := time.Now()
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Duration: 10 * time.Millisecond},
+		{Server: "10.0.0.1", Error: os.ErrDeadlineExceeded},
+		{Server: "10.0.0.1", Error: os.ErrDeadlineExceeded},
+	}
+	h.update(calculateStats(results), results, "home", now)
+	if err := h.save(path, now); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = loadHealth(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := []string{"8.8.8.8", "10.0.0.1"}
+	keep, skipped := h.skipUnreachable(servers, "home", now.Add(time.Minute))
+	if len(keep) != 1 || keep[0] != "8.8.8.8" || len(skipped) != 1 || skipped[0].Server != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1 to be skipped, kept %v skipped %v", keep, skipped)
+	}
+	if skipped[0].Error == "" {
+		t.Error("expected the last error to be recorded")
+	}
+
+	// Only on the network it failed on, and only until healthTTL passes
+	if keep, _ := h.skipUnreachable(servers, "office", now); len(keep) != 2 {
+		t.Errorf("expected nothing skipped on another network, kept %v", keep)
+	}
+	if keep, _ := h.skipUnreachable(servers, "home", now.Add(healthTTL)); len(keep) != 2 {
+		t.Errorf("expected nothing skipped after the TTL, kept %v", keep)
+	}
+
+	// Never skip everything
+	if keep, skipped := h.skipUnreachable([]string{"10.0.0.1"}, "home", now); len(keep) != 1 || len(skipped) != 0 {
+		t.Errorf("expected the only server to be kept, kept %v skipped %v", keep, skipped)
+	}
+
+	// A server that answers again is forgotten
+	recovered := []benchmark.Result{{Server: "10.0.0.1", Duration: time.Millisecond}}
+	h.update(calculateStats(recovered), recovered, "home", now)
+	if _, ok := h.recent("10.0.0.1", "home", now); ok {
+		t.Error("expected recovered server to be removed")
+	}
+}
+
+func TestHealthStateExpiresOnSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "health.json")
+	now := time.Now()
+	h := &healthState{Unreachable: []healthEntry{
+		{Server: "old", Network: "home", FailedAt: now.Add(-2 * healthTTL)},
+		{Server: "new", Network: "home", FailedAt: now},
+	}}
+	if err := h.save(path, now); err != nil {
+		t.Fatal(err)
+	}
+	h, err := loadHealth(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Unreachable) != 1 || h.Unreachable[0].Server != "new" {
+		t.Errorf("expected only the fresh entry, got %+v", h.Unreachable)
+	}
+}
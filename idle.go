@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// parseIdlePeriods parses the comma-separated durations of -idle-periods.
+func parseIdlePeriods(spec string) ([]time.Duration, error) {
+	var periods []time.Duration
+	for _, s := range splitList(spec) {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid idle period %q (expected a duration such as 30s or 5m)", s)
+		}
+		periods = append(periods, d)
+	}
+	return periods, nil
+}
+
+// idleCell formats one idle sample for the table: the latency of the first
+// query after the idle period, its penalty and whether it reconnected.
+func idleCell(s benchmark.IdleSample) string {
+	if s.Err != nil {
+		return "failed"
+	}
+	sign := ""
+	if s.Penalty >= 0 {
+		sign = "+"
+	}
+	cell := fmt.Sprintf("%v (%s%v", s.Duration.Round(time.Microsecond), sign, s.Penalty.Round(time.Microsecond))
+	if s.Conn == benchmark.IdleConnNew {
+		cell += ", reconnected"
+	}
+	return cell + ")"
+}
+
+// printIdleProbe measures the first query after each idle period for every
+// DoT and DoH server: the reconnect penalty users feel once a laptop wakes
+// up or a connection has idled out.
+func printIdleProbe(config benchmark.Config, idles []time.Duration) {
+	if len(encryptedServers(config.Servers)) == 0 {
+		fmt.Println("\nIdle probe: no DoT or DoH servers to probe")
+		return
+	}
+	fmt.Printf("\nMeasuring the first query after idling %v (takes about %v)...\n", idles, slices.Max(idles))
+	results := benchmark.RunIdleProbe(config, idles)
+
+	fmt.Printf("\nFirst Query After Idle (latency, +penalty over a query on the open connection)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "SERVER\tWARM"
+	for _, idle := range idles {
+		header += fmt.Sprintf("\tAFTER %v", idle)
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range results {
+		warm := "-"
+		for _, s := range r.Samples {
+			if s.Warm > 0 {
+				warm = s.Warm.Round(time.Microsecond).String()
+				break
+			}
+		}
+		cells := make([]string, len(r.Samples))
+		for i, s := range r.Samples {
+			cells[i] = idleCell(s)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", r.Server, warm, strings.Join(cells, "\t")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	for _, r := range results {
+		for _, s := range r.Samples {
+			if s.Err == nil && s.Conn == benchmark.IdleConnNew {
+				fmt.Printf("⚠️  connections to %s don't survive %v idle (closed by the server or the network): the first query after that pays %v for a new one\n", r.Server, s.Idle, s.Penalty.Round(time.Millisecond))
+				break
+			}
+		}
+	}
+}
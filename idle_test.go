@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestParseIdlePeriods(t *testing.T) {
+	periods, err := parseIdlePeriods("10s, 1m,5m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 3 || periods[0] != 10*time.Second || periods[2] != 5*time.Minute {
+		t.Errorf("unexpected periods %v", periods)
+	}
+	for _, spec := range []string{"10", "0s", "-5s"} {
+		if _, err := parseIdlePeriods(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestIdleCell(t *testing.T) {
+	for _, tc := range []struct {
+		sample benchmark.IdleSample
+		want   string
+	}{
+		{benchmark.IdleSample{Duration: 12 * time.Millisecond, Penalty: time.Millisecond, Conn: benchmark.IdleConnReused}, "12ms (+1ms)"},
+		{benchmark.IdleSample{Duration: 80 * time.Millisecond, Penalty: 69 * time.Millisecond, Conn: benchmark.IdleConnNew}, "80ms (+69ms, reconnected)"},
+		{benchmark.IdleSample{Err: errors.New("timeout")}, "failed"},
+	} {
+		if got := idleCell(tc.sample); got != tc.want {
+			t.Errorf("expected %q, got %q", tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// impactEstimate is what switching from the current server to Server would
+// change for a user: Saving on each lookup that reaches either server
+// (negative when Server is slower), with AffectedPct the share of lookups
+// that do. With a stub cache the rest are answered locally either way.
+type impactEstimate struct {
+	Server      string
+	Saving      time.Duration
+	AffectedPct float64
+}
+
+// domainWeights returns how often each domain is looked up relative to the
+// others, keyed by lowercased name: browser visit counts when there are
+// some, otherwise 1/rank for ranked domains, as popularity roughly follows
+// Zipf's law. Nil means every domain counts the same.
+func domainWeights(visits, ranks map[string]int) map[string]float64 {
+	var weights map[string]float64
+	switch {
+	case len(visits) > 0:
+		weights = make(map[string]float64, len(visits))
+		for d, n := range visits {
+			weights[strings.ToLower(d)] += float64(n)
+		}
+	case len(ranks) > 0:
+		weights = make(map[string]float64, len(ranks))
+		for d, rank := range ranks {
+			if rank > 0 {
+				weights[strings.ToLower(d)] = 1 / float64(rank)
+			}
+		}
+	}
+	return weights
+}
+
+// estimateImpact compares every other server with current over the domains
+// both answered, weighting each domain by how often it is looked up and,
+// when interval is set, leaving out lookups a stub cache would answer (see
+// stubCacheStats). Estimates are ordered by saving, largest first.
+func estimateImpact(results []benchmark.Result, current string, interval time.Duration, weights map[string]float64) []impactEstimate {
+	samples := domainSamples(results)
+	base := samples[current]
+	if base == nil {
+		return nil
+	}
+
+	var out []impactEstimate
+	for server, domains := range samples {
+		if server == current {
+			continue
+		}
+		var common []string
+		for d := range domains {
+			if base[d] != nil {
+				common = append(common, d)
+			}
+		}
+		if len(common) == 0 {
+			continue
+		}
+		shares := normalizeWeights(common, weights)
+
+		var before, after, affected float64
+		for _, d := range common {
+			missBefore, missAfter := 1.0, 1.0
+			if interval > 0 {
+				missBefore = missRate(base[d].TTL, interval, shares[d], len(common))
+				missAfter = missRate(domains[d].TTL, interval, shares[d], len(common))
+			}
+			before += shares[d] * missBefore * float64(base[d].Latency)
+			after += shares[d] * missAfter * float64(domains[d].Latency)
+			affected += shares[d] * max(missBefore, missAfter)
+		}
+		out = append(out, impactEstimate{
+			Server:      server,
+			Saving:      time.Duration((before - after) / affected),
+			AffectedPct: affected * 100,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Saving != out[j].Saving {
+			return out[i].Saving > out[j].Saving
+		}
+		return out[i].Server < out[j].Server
+	})
+	return out
+}
+
+// perceptibility puts a per-lookup latency difference into words. Browsers
+// resolve several names per page, often in parallel, so differences of a
+// few milliseconds disappear in page load times.
+func perceptibility(d time.Duration) string {
+	switch d = d.Abs(); {
+	case d < 10*time.Millisecond:
+		return "imperceptible"
+	case d < 50*time.Millisecond:
+		return "barely noticeable"
+	case d < 200*time.Millisecond:
+		return "noticeable"
+	default:
+		return "obvious"
+	}
+}
+
+// roughly rounds d for estimates: to the millisecond, or to 100µs below 10ms.
+func roughly(d time.Duration) time.Duration {
+	if d.Abs() < 10*time.Millisecond {
+		return d.Round(100 * time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// printImpact shows what switching from current to each other server would
+// feel like and spells out the best option.
+func printImpact(estimates []impactEstimate, current string, interval time.Duration, weighted bool) {
+	if len(estimates) == 0 {
+		return
+	}
+
+	basis := "every domain weighted equally"
+	if weighted {
+		basis = "domains weighted by popularity"
+	}
+	if interval > 0 {
+		basis += fmt.Sprintf(", behind a stub cache with lookups every %v on average", interval)
+	}
+	fmt.Printf("\nEstimated Impact of Switching from %s (%s)\n\n", current, basis)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tSAVING PER LOOKUP\tLOOKUPS AFFECTED\tVERDICT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, e := range estimates {
+		verdict := perceptibility(e.Saving)
+		if e.Saving < 0 && verdict != "imperceptible" {
+			verdict += " (slower)"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%v\t%.0f%%\t%s\n", e.Server, roughly(e.Saving), e.AffectedPct, verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	best := estimates[0]
+	if best.Saving <= 0 {
+		fmt.Printf("✓ None of the other servers would be faster than %s\n", current)
+		return
+	}
+	fmt.Printf("Switching to %s saves ~%v on %.0f%% of your lookups ≈ %s\n", best.Server, roughly(best.Saving), best.AffectedPct, perceptibility(best.Saving))
+}
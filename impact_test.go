@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestEstimateImpact(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "current", Domain: "popular.example", Duration: 30 * time.Millisecond, TTL: time.Hour},
+		{Server: "current", Domain: "rare.example", Duration: 100 * time.Millisecond, TTL: time.Minute},
+		{Server: "fast", Domain: "popular.example", Duration: 10 * time.Millisecond, TTL: time.Hour},
+		{Server: "fast", Domain: "Rare.example", Duration: 20 * time.Millisecond, TTL: time.Minute},
+	}
+	weights := domainWeights(nil, map[string]int{"popular.example": 1, "rare.example": 3})
+
+	// Without a cache every lookup reaches the server: 3/4 of them save
+	// 20ms and 1/4 save 80ms
+	est := estimateImpact(results, "current", 0, weights)
+	if len(est) != 1 || est[0].Server != "fast" {
+		t.Fatalf("expected one estimate for fast, got %+v", est)
+	}
+	if est[0].Saving != 35*time.Millisecond || est[0].AffectedPct != 100 {
+		t.Errorf("expected 35ms saved on every lookup, got %+v", est[0])
+	}
+
+	// Behind a stub cache with lookups every 10m on average, popular.example
+	// is looked up every 6m40s and mostly cached, rare.example every 20m
+	// and never
+	est = estimateImpact(results, "current", 10*time.Minute, weights)
+	if est[0].AffectedPct >= 50 || est[0].Saving <= 35*time.Millisecond {
+		t.Errorf("expected a larger saving on fewer lookups, got %+v", est[0])
+	}
+
+	if perceptibility(-5*time.Millisecond) != "imperceptible" || perceptibility(120*time.Millisecond) != "noticeable" {
+		t.Error("unexpected perceptibility")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+	"dns-bench/netenv"
+)
+
+// resolveInterfaces expands the -interfaces list, where "all" stands for
+// every usable interface, and checks each one has an address to send from.
+func resolveInterfaces(names []string) ([]string, error) {
+	if len(names) == 1 && names[0] == "all" {
+		names = netenv.UsableInterfaces()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no usable network interfaces found")
+		}
+	}
+	for _, name := range names {
+		if _, _, err := benchmark.InterfaceAddrs(name); err != nil {
+			return nil, fmt.Errorf("interface %s: %w", name, err)
+		}
+	}
+	return names, nil
+}
+
+// resolveSourceIPs parses the -source-ip list, at most one address per
+// family, and checks each is assigned to this host. It also returns the
+// interface the first one belongs to, to label the results' network with.
+func resolveSourceIPs(list []string) ([]netip.Addr, string, error) {
+	var addrs []netip.Addr
+	var owner string
+	for _, s := range list {
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid source IP %q", s)
+		}
+		ip = ip.Unmap()
+		for _, a := range addrs {
+			if a.Is4() == ip.Is4() {
+				return nil, "", fmt.Errorf("source IPs %s and %s are of the same family; give at most one IPv4 and one IPv6 address", a, ip)
+			}
+		}
+		name := interfaceWithAddr(ip)
+		if name == "" {
+			return nil, "", fmt.Errorf("source IP %s isn't an address of this host", ip)
+		}
+		if owner == "" {
+			owner = name
+		}
+		addrs = append(addrs, ip)
+	}
+	return addrs, owner, nil
+}
+
+// interfaceWithAddr returns the name of the interface ip is assigned to, or
+// "" if none is.
+func interfaceWithAddr(ip netip.Addr) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if prefix, err := netip.ParsePrefix(a.String()); err == nil && prefix.Addr().Unmap() == ip {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// runInterfaces benchmarks the same servers and domains over each interface
+// at the same time, so every uplink sees the same period. Each result's
+// Network names the interface it was sent from.
+func runInterfaces(config benchmark.Config, ifaces []string) []benchmark.Result {
+	if onResult := config.OnResult; onResult != nil {
+		var mu sync.Mutex
+		config.OnResult = func(res benchmark.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			onResult(res)
+		}
+	}
+	// Several progress lines can't share one terminal line
+	config.ShowProgress = config.ShowProgress && len(ifaces) == 1
+
+	perIface := make([][]benchmark.Result, len(ifaces))
+	var wg sync.WaitGroup
+	for i, name := range ifaces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := config
+			c.Interface = name
+			c.Network = netenv.ForInterface(name).Label()
+			perIface[i] = benchmark.Run(c)
+		}()
+	}
+	wg.Wait()
+
+	var results []benchmark.Result
+	for _, r := range perIface {
+		results = append(results, r...)
+	}
+	return results
+}
+
+// printInterfaces compares each server's average latency and loss across
+// the interfaces it was measured over, and names the uplink that was
+// fastest for the most servers.
+func printInterfaces(stats []*ServerStats, results []benchmark.Result) {
+	byNetwork := make(map[string][]benchmark.Result)
+	var networks []string
+	for _, res := range results {
+		if _, ok := byNetwork[res.Network]; !ok {
+			networks = append(networks, res.Network)
+		}
+		byNetwork[res.Network] = append(byNetwork[res.Network], res)
+	}
+	if len(networks) < 2 {
+		return
+	}
+	sort.Strings(networks)
+
+	perNetwork := make(map[string]map[string]*ServerStats, len(networks))
+	for _, n := range networks {
+		perNetwork[n] = make(map[string]*ServerStats)
+		for _, s := range calculateStats(byNetwork[n]) {
+			perNetwork[n][s.Server] = s
+		}
+	}
+
+	fmt.Printf("\nInterface Comparison (average latency, loss)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\t"+strings.Join(networks, "\t")+"\tFASTEST"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	wins := make(map[string]int)
+	for _, s := range stats {
+		row := s.Server
+		best, bestAvg := "", time.Duration(0)
+		for _, n := range networks {
+			ns, ok := perNetwork[n][s.Server]
+			if !ok {
+				row += "\t-"
+				continue
+			}
+			if ns.Success == 0 {
+				row += fmt.Sprintf("\t- (%.1f%%)", ns.LossPct)
+				continue
+			}
+			row += fmt.Sprintf("\t%v (%.1f%%)", ns.Avg, ns.LossPct)
+			if best == "" || ns.Avg < bestAvg {
+				best, bestAvg = n, ns.Avg
+			}
+		}
+		if best != "" {
+			wins[best]++
+		}
+		row += "\t" + orNone(best)
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	winner := ""
+	for _, n := range networks {
+		if winner == "" || wins[n] > wins[winner] {
+			winner = n
+		}
+	}
+	if wins[winner] > 0 {
+		fmt.Printf("✓ Fastest uplink for %d of %d servers: %s\n", wins[winner], len(stats), winner)
+	}
+}
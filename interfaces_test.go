@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestResolveInterfacesUnknown(t *testing.T) {
+	if _, err := resolveInterfaces([]string{"nonexistent0"}); err == nil {
+		t.Error("expected an error for an unknown interface")
+	}
+}
+
+func TestResolveSourceIPs(t *testing.T) {
+	addrs, owner, err := resolveSourceIPs([]string{"127.0.0.1"})
+	if err != nil || len(addrs) != 1 || owner == "" {
+		t.Errorf("expected the loopback address and its interface, got %v %q %v", addrs, owner, err)
+	}
+	for _, list := range [][]string{
+		{"not-an-ip"},
+		{"192.0.2.77"},
+		{"127.0.0.1", "127.0.0.2"},
+	} {
+		if _, _, err := resolveSourceIPs(list); err == nil {
+			t.Errorf("%v: expected an error", list)
+		}
+	}
+}
+
+func TestPrintInterfaces(_ *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Network: "eth0", Duration: 10 * time.Millisecond},
+		{Server: "8.8.8.8", Network: "wlan0 (HomeWiFi)", Duration: 30 * time.Millisecond},
+		{Server: "1.1.1.1", Network: "eth0", Duration: 12 * time.Millisecond},
+		{Server: "1.1.1.1", Network: "wlan0 (HomeWiFi)", Error: os.ErrDeadlineExceeded},
+	}
+	printInterfaces(calculateStats(results), results)
+}
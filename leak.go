@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+
+	"github.com/miekg/dns"
+)
+
+// dnsProvider is a public resolver service the leak test knows: the
+// addresses and hostnames its servers are reached at (a name also covers
+// its subdomains) and the networks its resolvers query authoritative
+// servers from.
+type dnsProvider struct {
+	Name   string
+	Hosts  []string
+	Egress []netip.Prefix
+}
+
+// knownProviders are taken from the address ranges the providers publish.
+// Anything else needs leak_expect in the config file to get a verdict.
+var knownProviders = []dnsProvider{
+	{
+		Name: "Cloudflare",
+		Hosts: []string{
+			"1.1.1.1", "1.0.0.1", "1.1.1.2", "1.0.0.2", "1.1.1.3", "1.0.0.3",
+			"2606:4700:4700::1111", "2606:4700:4700::1001", "2606:4700:4700::1112",
+			"2606:4700:4700::1002", "2606:4700:4700::1113", "2606:4700:4700::1003",
+			"cloudflare-dns.com", "one.one.one.one",
+		},
+		Egress: mustPrefixes(
+			"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+			"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+			"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+			"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+			"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+			"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+		),
+	},
+	{
+		Name: "Google",
+		Hosts: []string{
+			"8.8.8.8", "8.8.4.4", "2001:4860:4860::8888", "2001:4860:4860::8844",
+			"2001:4860:4860::64", "2001:4860:4860::6464", "dns.google",
+		},
+		Egress: mustPrefixes(
+			"74.125.0.0/16", "172.217.0.0/16", "172.253.0.0/16", "173.194.0.0/16",
+			"108.177.0.0/17", "209.85.128.0/17", "64.233.160.0/19", "66.102.0.0/20",
+			"66.249.64.0/19",
+			"2001:4860::/32", "2404:6800::/32", "2607:f8b0::/32", "2800:3f0::/32",
+			"2a00:1450::/32", "2c0f:fb50::/32",
+		),
+	},
+}
+
+func mustPrefixes(ranges ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(ranges))
+	for i, r := range ranges {
+		prefixes[i] = netip.MustParsePrefix(r)
+	}
+	return prefixes
+}
+
+// serverHost returns the address or hostname in a server address of any
+// transport.
+func serverHost(server string) string {
+	if strings.Contains(server, "://") {
+		if u, err := url.Parse(server); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	if addr, err := netip.ParseAddr(server); err == nil {
+		return addr.String()
+	}
+	if host, _, err := net.SplitHostPort(server); err == nil {
+		return host
+	}
+	return server
+}
+
+// providerOf returns the known provider server belongs to, or nil.
+func providerOf(server string) *dnsProvider {
+	host := strings.ToLower(serverHost(server))
+	_, err := netip.ParseAddr(host)
+	isAddr := err == nil
+	for i, p := range knownProviders {
+		for _, h := range p.Hosts {
+			if h == host || (!isAddr && dns.IsSubDomain(dns.Fqdn(h), dns.Fqdn(host))) {
+				return &knownProviders[i]
+			}
+		}
+	}
+	return nil
+}
+
+// egressProvider names the known provider addr belongs to, or "".
+func egressProvider(addr netip.Addr) string {
+	for _, p := range knownProviders {
+		if slices.ContainsFunc(p.Egress, func(r netip.Prefix) bool { return r.Contains(addr) }) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// parseLeakExpect parses the leak_expect section: the addresses or ranges
+// each server's resolvers are expected to query from.
+func parseLeakExpect(expect map[string][]string) (map[string][]netip.Prefix, error) {
+	parsed := make(map[string][]netip.Prefix, len(expect))
+	for server, ranges := range expect {
+		for _, r := range ranges {
+			p, err := parseRange(r)
+			if err != nil {
+				return nil, fmt.Errorf("leak_expect %s: %w", server, err)
+			}
+			parsed[server] = append(parsed[server], p)
+		}
+	}
+	return parsed, nil
+}
+
+// leakVerdict compares the resolvers that did a server's recursion with
+// the ranges expected for it: from leak_expect, or its known provider's.
+func leakVerdict(server string, res benchmark.LeakProbeResult, expect map[string][]netip.Prefix) (provider, verdict string, leaks bool) {
+	ranges, ok := expect[server]
+	provider = "configured"
+	if !ok {
+		p := providerOf(server)
+		if p == nil {
+			provider = "-"
+		} else {
+			provider, ranges = p.Name, p.Egress
+		}
+	}
+	if res.Err != nil {
+		return provider, "probe failed: " + res.Err.Error(), false
+	}
+	if ranges == nil {
+		return provider, "unknown provider (set leak_expect to check)", false
+	}
+
+	var outside []string
+	for _, addr := range res.Resolvers {
+		if slices.ContainsFunc(ranges, func(r netip.Prefix) bool { return r.Contains(addr) }) {
+			continue
+		}
+		if name := egressProvider(addr); name != "" {
+			outside = append(outside, fmt.Sprintf("%s (%s)", addr, name))
+		} else {
+			outside = append(outside, addr.String())
+		}
+	}
+	if len(outside) > 0 {
+		return provider, "leaks to " + strings.Join(outside, ", "), true
+	}
+	return provider, "resolved by " + provider, false
+}
+
+// printLeakTest asks every server, over its own transport, which resolver
+// performed the recursion for the leak-test names, and flags servers whose
+// queries were answered by someone other than the expected provider, e.g.
+// a VPN or network that intercepts DNS.
+func printLeakTest(config benchmark.Config, expect map[string][]netip.Prefix) {
+	fmt.Printf("\nRunning DNS leak test...\n")
+	byServer := benchmark.RunLeakProbe(config)
+
+	fmt.Printf("\nDNS Leak Test\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tPROVIDER\tRESOLVERS\tVERDICT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var leaky []string
+	checked := 0
+	for _, server := range config.Servers {
+		res := byServer[server]
+		provider, verdict, leaks := leakVerdict(server, res, expect)
+		if leaks {
+			leaky = append(leaky, server)
+		}
+		if provider != "-" && res.Err == nil {
+			checked++
+		}
+		resolvers := make([]string, len(res.Resolvers))
+		for i, addr := range res.Resolvers {
+			resolvers[i] = addr.String()
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", server, provider, orNone(strings.Join(resolvers, ", ")), verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	switch {
+	case len(leaky) > 0:
+		fmt.Printf("⚠️  Queries to these servers were resolved outside the expected provider (intercepted by a VPN, router or ISP?): %s\n", strings.Join(leaky, ", "))
+	case checked > 0:
+		fmt.Println("✓ Every server with a known provider resolved its queries itself")
+	}
+}
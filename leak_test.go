@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestProviderOf(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":                                    "Cloudflare",
+		"tls://1.0.0.1:853":                          "Cloudflare",
+		"https://cloudflare-dns.com/dns-query":       "Cloudflare",
+		"h3://security.cloudflare-dns.com/dns-query": "Cloudflare",
+		"[2001:4860:4860::8888]:53":                  "Google",
+		"https://dns.google/dns-query":               "Google",
+		"9.9.9.9":                                    "",
+		"https://notdns.google.example/dns-query":    "",
+	}
+	for server, want := range tests {
+		got := ""
+		if p := providerOf(server); p != nil {
+			got = p.Name
+		}
+		if got != want {
+			t.Errorf("providerOf(%q) = %q, want %q", server, got, want)
+		}
+	}
+}
+
+func TestLeakVerdict(t *testing.T) {
+	resolvedBy := func(addrs ...string) benchmark.LeakProbeResult {
+		var res benchmark.LeakProbeResult
+		for _, a := range addrs {
+			res.Resolvers = append(res.Resolvers, netip.MustParseAddr(a))
+		}
+		return res
+	}
+	expect := map[string][]netip.Prefix{"10.0.0.53": {netip.MustParsePrefix("203.0.113.0/24")}}
+
+	if _, verdict, leaks := leakVerdict("1.1.1.1", resolvedBy("162.158.1.10", "2400:cb00:1::10"), expect); leaks || verdict != "resolved by Cloudflare" {
+		t.Errorf("expected Cloudflare to resolve its own queries, got %q", verdict)
+	}
+	if _, verdict, leaks := leakVerdict("1.1.1.1", resolvedBy("74.125.1.1"), expect); !leaks || !strings.Contains(verdict, "74.125.1.1 (Google)") {
+		t.Errorf("expected a leak to Google, got %q", verdict)
+	}
+	if provider, verdict, leaks := leakVerdict("10.0.0.53", resolvedBy("198.51.100.7"), expect); provider != "configured" || !leaks || verdict != "leaks to 198.51.100.7" {
+		t.Errorf("expected a leak outside the configured range, got %s %q", provider, verdict)
+	}
+	if _, verdict, leaks := leakVerdict("9.9.9.9", resolvedBy("198.51.100.7"), expect); leaks || !strings.HasPrefix(verdict, "unknown provider") {
+		t.Errorf("expected no verdict for an unknown provider, got %q", verdict)
+	}
+	if _, verdict, leaks := leakVerdict("8.8.8.8", benchmark.LeakProbeResult{Err: errors.New("i/o timeout")}, expect); leaks || verdict != "probe failed: i/o timeout" {
+		t.Errorf("expected a failed probe, got %q", verdict)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"golang.org/x/net/websocket"
+)
+
+// liveBuffer is how many messages a WebSocket client may fall behind by
+// before it starts missing results; a slow tab never slows the benchmark.
+const liveBuffer = 1024
+
+// liveProgressInterval is how often progress is sent to WebSocket clients.
+const liveProgressInterval = 500 * time.Millisecond
+
+// liveMessage is one message of the -listen WebSocket stream: "start" when
+// a client connects, "progress" every liveProgressInterval, "result" for
+// each result and "done" when the benchmark has finished.
+type liveMessage struct {
+	Type       string            `json:"type"`
+	Servers    []string          `json:"servers,omitempty"`
+	Completed  int               `json:"completed"`
+	Total      int               `json:"total,omitempty"`
+	ElapsedMs  float64           `json:"elapsed_ms"`
+	DurationMs float64           `json:"duration_ms,omitempty"`
+	Result     *benchmark.Result `json:"result,omitempty"`
+}
+
+// liveHub streams progress and results to the WebSocket clients of the
+// -listen server as they arrive.
+type liveHub struct {
+	servers  []string
+	total    int // Expected results, zero for a -d run
+	duration time.Duration
+
+	mu        sync.Mutex
+	clients   map[chan []byte]bool
+	completed int
+	start     time.Time
+	done      bool
+	stop      chan struct{}
+}
+
+func newLiveHub(servers []string, total int, duration time.Duration) *liveHub {
+	return &liveHub{
+		servers:  servers,
+		total:    total,
+		duration: duration,
+		clients:  make(map[chan []byte]bool),
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+	}
+}
+
+// run starts the clock for the benchmark and sends progress to every
+// client until finish is called.
+func (h *liveHub) run() {
+	h.mu.Lock()
+	h.start = time.Now()
+	h.mu.Unlock()
+	ticker := time.NewTicker(liveProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			h.broadcast(h.message("progress"))
+			h.mu.Unlock()
+		}
+	}
+}
+
+// message builds a message of the given type with the current progress.
+// Callers hold h.mu.
+func (h *liveHub) message(kind string) liveMessage {
+	return liveMessage{
+		Type:       kind,
+		Completed:  h.completed,
+		Total:      h.total,
+		ElapsedMs:  millis(time.Since(h.start)),
+		DurationMs: millis(h.duration),
+	}
+}
+
+// broadcast sends msg to every client that has room for it. Callers hold
+// h.mu.
+func (h *liveHub) broadcast(msg liveMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to encode live message: %v\n", err)
+		return
+	}
+	for ch := range h.clients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// observe sends one result to every client. It is an OnResult sink.
+func (h *liveHub) observe(res benchmark.Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.completed++
+	msg := h.message("result")
+	msg.Result = &res
+	h.broadcast(msg)
+}
+
+// finish tells every client the benchmark is done and ends their streams.
+func (h *liveHub) finish() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		return
+	}
+	h.done = true
+	close(h.stop)
+	h.broadcast(h.message("done"))
+	for ch := range h.clients {
+		close(ch)
+		delete(h.clients, ch)
+	}
+}
+
+// subscribe adds a client, queueing the start message for it; ok is false
+// once the benchmark is done.
+func (h *liveHub) subscribe() (ch chan []byte, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		return nil, false
+	}
+	ch = make(chan []byte, liveBuffer)
+	h.clients[ch] = true
+	msg := h.message("start")
+	msg.Servers = h.servers
+	if data, err := json.Marshal(msg); err == nil {
+		ch <- data
+	}
+	return ch, true
+}
+
+// unsubscribe removes a client that went away before the end.
+func (h *liveHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[ch] {
+		delete(h.clients, ch)
+		close(ch)
+	}
+}
+
+// serveWebSocket streams the hub's messages to one client.
+func (h *liveHub) serveWebSocket(ws *websocket.Conn) {
+	defer func() { _ = ws.Close() }()
+	ch, ok := h.subscribe()
+	if !ok {
+		return
+	}
+	// Clients don't send anything; reading notices when they go away
+	gone := make(chan struct{})
+	go func() {
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		close(gone)
+	}()
+	for {
+		select {
+		case <-gone:
+			h.unsubscribe(ch)
+			return
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := websocket.Message.Send(ws, string(data)); err != nil {
+				h.unsubscribe(ch)
+				return
+			}
+		}
+	}
+}
+
+// sameOrigin accepts WebSocket connections from clients that send no
+// Origin (scripts, curl) or whose page was served by this server, so other
+// sites open in the browser can't read the results.
+func sameOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != req.Host {
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+	config.Origin = u
+	return nil
+}
+
+// ServeHTTP upgrades the request to a WebSocket stream.
+func (h *liveHub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	websocket.Server{Handler: h.serveWebSocket, Handshake: sameOrigin}.ServeHTTP(w, req)
+}
+
+// expectedResults is how many results a run of config produces over ifaces
+// interfaces, or zero when it runs for a duration instead.
+func expectedResults(config benchmark.Config, ifaces int) int {
+	if config.Duration > 0 {
+		return 0
+	}
+	questions := len(config.Questions)
+	if questions == 0 {
+		questions = len(config.Domains)
+	}
+	n := len(config.Servers) * questions * config.Iterations * max(ifaces, 1)
+	if config.ColdWarm {
+		n *= 2
+	}
+	return n
+}
+
+// livePage is served at / by -listen: the run's progress and a per-server
+// latency chart, updated from the WebSocket stream.
+const livePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dns-bench live</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 2rem; color: #222; }
+#progress { margin-bottom: 1.5rem; }
+table { border-collapse: collapse; }
+td, th { padding: 0.3rem 0.8rem; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #4a90d9; height: 0.9rem; }
+</style>
+</head>
+<body>
+<h1>dns-bench live</h1>
+<div id="progress">Connecting...</div>
+<table>
+<thead><tr><th>Server</th><th>Queries</th><th>Loss</th><th>Avg</th><th>Last</th><th style="width:40vw">Avg latency</th></tr></thead>
+<tbody id="servers"></tbody>
+</table>
+<script>
+(function () {
+	var servers = {};
+	var order = [];
+	var rows = document.getElementById('servers');
+	var progress = document.getElementById('progress');
+
+	function server(name) {
+		if (!servers[name]) {
+			var tr = document.createElement('tr');
+			tr.innerHTML = '<td></td><td class="num"></td><td class="num"></td><td class="num"></td><td class="num"></td><td><div class="bar"></div></td>';
+			tr.cells[0].textContent = name;
+			rows.appendChild(tr);
+			servers[name] = { row: tr, queries: 0, errors: 0, sum: 0, last: null };
+			order.push(name);
+		}
+		return servers[name];
+	}
+
+	function render() {
+		var worst = 0;
+		order.forEach(function (name) {
+			var s = servers[name];
+			var ok = s.queries - s.errors;
+			s.avg = ok ? s.sum / ok : 0;
+			worst = Math.max(worst, s.avg);
+		});
+		order.forEach(function (name) {
+			var s = servers[name];
+			var cells = s.row.cells;
+			cells[1].textContent = s.queries;
+			cells[2].textContent = s.queries ? (100 * s.errors / s.queries).toFixed(1) + '%' : '-';
+			cells[3].textContent = s.avg ? s.avg.toFixed(1) + 'ms' : '-';
+			cells[4].textContent = s.last === null ? '-' : s.last;
+			cells[5].firstChild.style.width = worst ? (100 * s.avg / worst) + '%' : '0';
+		});
+	}
+
+	function showProgress(m) {
+		var elapsed = (m.elapsed_ms / 1000).toFixed(0) + 's';
+		if (m.total) {
+			progress.textContent = m.completed + '/' + m.total + ' results (' + (100 * m.completed / m.total).toFixed(1) + '%) - elapsed ' + elapsed;
+		} else {
+			progress.textContent = m.completed + ' results - elapsed ' + elapsed + ' of ' + (m.duration_ms / 1000).toFixed(0) + 's';
+		}
+	}
+
+	var ws = new WebSocket((location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/ws');
+	var dirty = false;
+	ws.onmessage = function (e) {
+		var m = JSON.parse(e.data);
+		if (m.type === 'start') {
+			(m.servers || []).forEach(server);
+		} else if (m.type === 'result') {
+			var s = server(m.result.server);
+			s.queries++;
+			if (m.result.error) {
+				s.errors++;
+				s.last = 'failed';
+			} else {
+				s.sum += m.result.duration_ms;
+				s.last = m.result.duration_ms.toFixed(1) + 'ms';
+			}
+			dirty = true;
+			return;
+		}
+		showProgress(m);
+		if (m.type === 'done') {
+			progress.textContent += ' - done';
+		}
+	};
+	ws.onclose = function () {
+		if (progress.textContent.indexOf('done') < 0) {
+			progress.textContent += ' - disconnected';
+		}
+	};
+	setInterval(function () {
+		if (dirty) {
+			dirty = false;
+			render();
+		}
+	}, 250);
+})();
+</script>
+</body>
+</html>
+`
+
+// serveLivePage serves livePage.
+func serveLivePage(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := fmt.Fprint(w, livePage); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write live page: %v\n", err)
+	}
+}
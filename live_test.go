@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestLiveHub(t *testing.T) {
+	hub := newLiveHub([]string{"8.8.8.8", "1.1.1.1"}, 4, 0)
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	ws, err := websocket.Dial(wsURL, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.Close() }()
+	receive := func() liveMessage {
+		t.Helper()
+		if err := ws.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		var data string
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			t.Fatalf("failed to receive: %v", err)
+		}
+		var msg liveMessage
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			t.Fatalf("bad message %s: %v", data, err)
+		}
+		return msg
+	}
+
+	if msg := receive(); msg.Type != "start" || len(msg.Servers) != 2 || msg.Total != 4 {
+		t.Errorf("expected a start message with 2 servers and 4 results to come, got %+v", msg)
+	}
+	hub.observe(benchmark.Result{Server: "8.8.8.8", Domain: "example.com", Duration: 12 * time.Millisecond})
+	hub.finish()
+
+	var data string
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(data, `"type":"result"`) || !strings.Contains(data, `"server":"8.8.8.8"`) || !strings.Contains(data, `"duration_ms":12`) {
+		t.Errorf("expected the result in the export format, got %s", data)
+	}
+	if msg := receive(); msg.Type != "done" || msg.Completed != 1 {
+		t.Errorf("expected done after 1 result, got %+v", msg)
+	}
+	if err := websocket.Message.Receive(ws, &data); err == nil {
+		t.Errorf("expected the stream to end after done, got %s", data)
+	}
+
+	// Once the benchmark is done, new clients get nothing
+	late, err := websocket.Dial(wsURL, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = late.Close() }()
+	if err := websocket.Message.Receive(late, &data); err == nil {
+		t.Errorf("expected no messages after the benchmark, got %s", data)
+	}
+}
+
+func TestLiveHubRejectsOtherOrigins(t *testing.T) {
+	srv := httptest.NewServer(newLiveHub(nil, 0, time.Minute))
+	defer srv.Close()
+	if _, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", "https://evil.example"); err == nil {
+		t.Error("expected a page from another site to be refused")
+	}
+}
+
+func TestExpectedResults(t *testing.T) {
+	config := benchmark.Config{Servers: []string{"a", "b"}, Domains: []string{"x", "y", "z"}, Iterations: 5, ColdWarm: true}
+	if got := expectedResults(config, 2); got != 2*3*5*2*2 {
+		t.Errorf("expected 120 results, got %d", got)
+	}
+	config.Duration = time.Minute
+	if got := expectedResults(config, 0); got != 0 {
+		t.Errorf("expected no total for a -d run, got %d", got)
+	}
+}
@@ -2,12 +2,23 @@ package main
 
 import (
 	"bufio"
+	"cmp"
+	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,8 +28,11 @@ import (
 	"dns-bench/benchmark"
 	"dns-bench/browser"
 	"dns-bench/dashboard"
+	"dns-bench/netenv"
+	"dns-bench/resolved"
 	"dns-bench/validation"
 
+	"github.com/miekg/dns"
 	"gopkg.in/yaml.v3"
 )
 
@@ -134,13 +148,25 @@ var (
 	}
 )
 
+// calibrationSamples is the number of mock-server queries used to estimate overhead.
+const calibrationSamples = 500
+
+// lowMemoryConcurrency is the default concurrency with -low-memory, and
+// lowMemoryResults how many results it keeps for the reports and exports
+// built from individual results.
+const (
+	lowMemoryConcurrency = 8
+	lowMemoryResults     = 10000
+)
+
 // Config represents configuration that can be loaded from file or flags
 type Config struct {
-	Servers     []string      `yaml:"servers"`
+	Servers     []serverEntry `yaml:"servers"`
 	Domains     []string      `yaml:"domains"`
 	Concurrency int           `yaml:"concurrency"`
 	Iterations  int           `yaml:"iterations"`
 	Timeout     time.Duration `yaml:"timeout"`
+	QueryType   string        `yaml:"query_type"`
 	Duration    time.Duration `yaml:"duration"`
 	Verbose     bool          `yaml:"verbose"`
 	Progress    bool          `yaml:"progress"`
@@ -148,22 +174,269 @@ type Config struct {
 	ServerFile  string        `yaml:"server_file"`
 	ExportCSV   string        `yaml:"export_csv"`
 	ExportHTML  string        `yaml:"export_html"`
+	ExportJSON  string        `yaml:"export_json"`
+	ExportJSONL string        `yaml:"export_jsonl"`
+	Listen      string        `yaml:"listen"`
+	DB          string        `yaml:"db"`
 	BrowserName string        `yaml:"browser"`
+	// BrowserProfile selects the browser profile (directory or name) whose
+	// history is read, instead of the default one
+	BrowserProfile string `yaml:"browser_profile"`
+	// ConnsPerServer caps DoT/DoH at this many persistent connections per server
+	ConnsPerServer int  `yaml:"conns_per_server"`
+	ConnsScaling   bool `yaml:"conns_scaling"`
+	// NetworkTag overrides the auto-detected network label stamped on results
+	NetworkTag string `yaml:"network_tag"`
+	// Calibrate measures tool overhead against a local mock server first;
+	// SubtractOverhead also removes the measured median from every result.
+	Calibrate        bool `yaml:"calibrate"`
+	SubtractOverhead bool `yaml:"subtract_overhead"`
+	// Fallback emulates a stub resolver for plain DNS servers: "tcp" retries
+	// over TCP on truncation or failure, "dot" also escalates to DoT.
+	Fallback string `yaml:"fallback"`
+	// AutoConcurrency replaces Concurrency with the highest level that does
+	// not inflate latency, found by a short sweep before the benchmark.
+	AutoConcurrency bool `yaml:"auto_concurrency"`
+	// Retention is applied by the prune subcommand
+	Retention RetentionConfig `yaml:"retention"`
+	// SearchDomains emulates a stub resolver search list ("system" reads
+	// /etc/resolv.conf); Ndots overrides the resolv.conf default of 1.
+	SearchDomains []string `yaml:"search_domains"`
+	Ndots         *int     `yaml:"ndots"`
+	// Preset fills in servers, domains and options for a common scenario
+	Preset string `yaml:"preset"`
+	// Resolved adds the per-link servers configured in systemd-resolved
+	Resolved bool `yaml:"resolved"`
+	// Proxy sends every query through a SOCKS5 proxy (socks5://host:port)
+	Proxy string `yaml:"proxy"`
+	// WireProbes runs edge-case queries against every server after the benchmark
+	WireProbes bool `yaml:"wire_probes"`
+	// SLA lists latency buckets to report attainment for, each a duration
+	// with an optional target percentage ("50ms:99")
+	SLA []string `yaml:"sla"`
+	// Bootstrap is the plain DNS server used to resolve DoT and DoH server
+	// hostnames instead of the system resolver
+	Bootstrap string `yaml:"bootstrap"`
+	// HappyEyeballs compares connection setup per address family for
+	// dual-stack DoH/DoT servers after the benchmark
+	HappyEyeballs bool `yaml:"happy_eyeballs"`
+	// PenalizeTimeouts ranks servers with each timeout counted as taking the
+	// full query timeout instead of leaving it out of the average
+	PenalizeTimeouts bool `yaml:"penalize_timeouts"`
+	// Interfaces benchmarks the servers over each of these network
+	// interfaces at once, or every usable one for ["all"]
+	Interfaces []string `yaml:"interfaces"`
+	// SourceIPs sends every query from these local addresses, at most one
+	// IPv4 and one IPv6, e.g. a VPN tunnel's or a secondary VLAN's
+	SourceIPs []string `yaml:"source_ips"`
+	// Schedules are the runs the monitor subcommand starts, each at the
+	// times given by a cron expression
+	Schedules []ScheduleConfig `yaml:"schedules"`
+	// Alerts sets when the monitor reports a step change between runs
+	Alerts AlertConfig `yaml:"alerts"`
+	// Anonymize replaces domain names in every export with a keyed hash
+	// that keeps only the top-level domain
+	Anonymize bool `yaml:"anonymize"`
+	// SlowThreshold is the latency above which an answered query counts as
+	// slow, both for -v logging and the slow query table
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+	// Slowest is how many of each server's slow queries are reported
+	Slowest int `yaml:"slowest"`
+	// QPS caps the queries sent per second across all servers, and
+	// ServerQPS the queries per second to individual servers, to stay
+	// within providers' rate limits
+	QPS       float64            `yaml:"qps"`
+	ServerQPS map[string]float64 `yaml:"server_qps"`
+	// CacheBust prepends a random label to every query so it misses the
+	// resolver's cache
+	CacheBust bool `yaml:"cache_bust"`
+	// StubCache simulates a caching stub resolver in front of each server,
+	// with every domain looked up once per this interval
+	StubCache time.Duration `yaml:"stub_cache"`
+	// Impact estimates what switching from this server (typically the one
+	// in use) to each other server would feel like
+	Impact string `yaml:"impact"`
+	// ColdWarm queries every domain twice in a row per server and reports
+	// the cold and warm latency separately
+	ColdWarm bool `yaml:"cold_warm"`
+	// EDNSSize, DNSSEC and EDNSPadding add an EDNS0 OPT record to every
+	// query with this UDP payload size, the DO bit and padding
+	EDNSSize    int  `yaml:"edns_size"`
+	DNSSEC      bool `yaml:"dnssec"`
+	EDNSPadding bool `yaml:"edns_padding"`
+	// Retries repeats a failed lookup up to this many times; loss is then
+	// reported both before and after retrying
+	Retries int `yaml:"retries"`
+	// ClientSubnet is sent with every query as an EDNS Client Subnet
+	// option, e.g. "203.0.113.0/24"
+	ClientSubnet string `yaml:"client_subnet"`
+	// ECSProbe checks after the benchmark whether each server passes a
+	// client subnet on to authoritative servers
+	ECSProbe bool `yaml:"ecs_probe"`
+	// QueryFile is a batch of questions, each with its own type and
+	// optionally the answer it must get (see readQueries); it replaces the
+	// domain list
+	QueryFile string `yaml:"query_file"`
+	// ExpectFile maps domains to the address ranges their answers must be
+	// in (see readExpectations); other answers are flagged as unexpected
+	ExpectFile string `yaml:"expect_file"`
+	// InternalDomains are names (and their subdomains) that only the
+	// InternalServers should resolve; answers for them are checked against
+	// each server's role. Listing only one kind of server makes every
+	// other server the other kind.
+	InternalDomains []string `yaml:"internal_domains"`
+	InternalServers []string `yaml:"internal_servers"`
+	ExternalServers []string `yaml:"external_servers"`
+	// LeakTest checks after the benchmark which resolvers actually did each
+	// server's recursion; LeakExpect gives the addresses or ranges they are
+	// expected in for servers of providers the test doesn't know
+	LeakTest   bool                `yaml:"leak_test"`
+	LeakExpect map[string][]string `yaml:"leak_expect"`
+	// CollapseSubdomains benchmarks each imported domain's registrable
+	// domain (eTLD+1) instead of the full hostname
+	CollapseSubdomains bool `yaml:"collapse_subdomains"`
+	// PcapFile takes the domains from the DNS queries in a packet capture;
+	// PcapWeighted queries each as often, relatively, as it was seen
+	PcapFile     string `yaml:"pcap_file"`
+	PcapWeighted bool   `yaml:"pcap_weighted"`
+	// LowMemory aggregates per-server stats as results arrive and keeps
+	// only a sample of the results, for routers and other small devices
+	LowMemory bool `yaml:"low_memory"`
+	// Exports are compiled-in exporters (see registerExporter) the run is
+	// also sent to, each "name" or "name=target"
+	Exports []string `yaml:"exports"`
+	// Baseline is a baselines file (path or http(s) URL) built by the
+	// baseline subcommand to compare each public provider's median latency
+	// with; BaselineSummary writes this run's anonymized contribution to
+	// one; Region (e.g. a country code) picks the regional baselines
+	Baseline        string `yaml:"baseline"`
+	BaselineSummary string `yaml:"baseline_summary"`
+	Region          string `yaml:"region"`
+	// IdleProbe measures the first query to each DoT/DoH server after its
+	// connection sat idle for each of IdlePeriods (default 10s, 1m and 5m)
+	IdleProbe   bool            `yaml:"idle_probe"`
+	IdlePeriods []time.Duration `yaml:"idle_periods"`
+	// BrowserWeighted queries each browser history domain in proportion to
+	// how often it was visited, like PcapWeighted (see applyWeights)
+	BrowserWeighted bool `yaml:"browser_weighted"`
+	// LogSample logs every failed lookup and this percentage of the
+	// answered ones, e.g. "1%", instead of -v's every slow lookup
+	LogSample string `yaml:"log_sample"`
+	// Paired queries each domain on every server back to back and compares
+	// the servers on their per-domain latency differences
+	Paired bool `yaml:"paired"`
+	// Traceroute traces the network path to every server after the
+	// benchmark and reports its hop count, ASes and biggest latency jump
+	Traceroute bool `yaml:"traceroute"`
+	// CatalogURL and CatalogKey override where -update-catalog fetches the
+	// provider catalog from and the key it must be signed with
+	CatalogURL string `yaml:"catalog_url"`
+	CatalogKey string `yaml:"catalog_key"`
+	// Probes lets one config serve a fleet of probes: each machine runs as
+	// the probe named by Probe, or else the one matching its hostname
+	Probes []ProbeConfig `yaml:"probes"`
+	Probe  string        `yaml:"probe"`
+	// NoKeepalive opens a new connection for every DoT and DoH query, to measure
+	// cold-connection performance
+	NoKeepalive bool `yaml:"no_keepalive"`
+	// NoDiagnose skips troubleshooting the servers that failed every query
+	NoDiagnose bool `yaml:"no_diagnose"`
+	// TimeWindow breaks each server's latency down by consecutive windows
+	// of this length, for runs spanning hours
+	TimeWindow time.Duration `yaml:"time_window"`
+	// TLSResume lets new DoT and DoH connections resume the server's TLS
+	// session; TLSProbe checks each server's session tickets and 0-RTT
+	// support after the benchmark
+	TLSResume bool `yaml:"tls_resume"`
+	TLSProbe  bool `yaml:"tls_probe"`
+	// Unit ("ms" or "us") and Precision (decimals) write latencies in the
+	// main tables, the HTML report and the exports as plain numbers instead
+	// of Go duration strings
+	Unit      string `yaml:"unit"`
+	Precision *int   `yaml:"precision"`
+	// ExportCDF writes each server's latency CDF (the latency at every
+	// percentile) to this CSV or, for a .json file, JSON file
+	ExportCDF string `yaml:"export_cdf"`
+	// Forwarder benchmarks only this local forwarder (unbound, CoreDNS,
+	// ...) and, if set, its Upstream queried directly, with HitRatio of the
+	// queries meant to be cache hits and the rest forced misses
+	Forwarder string  `yaml:"forwarder"`
+	Upstream  string  `yaml:"upstream"`
+	HitRatio  float64 `yaml:"hit_ratio"`
+	// TieBreak, when the top two servers are within the noise, runs extra
+	// rounds against just those two until the ranking is significant or
+	// either has this many samples
+	TieBreak int `yaml:"tiebreak"`
 }
 
-// loadConfigFile loads configuration from a YAML file
+// loadConfigFile loads configuration from a YAML file, after the files it
+// includes
 func loadConfigFile(path string) (*Config, error) {
+	var config Config
+	if err := applyConfigFile(path, &config, nil); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// configIncludes is the include (or extends) directive of a config file:
+// one path or a list of them.
+type configIncludes []string
+
+func (c *configIncludes) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = configIncludes{value.Value}
+		return nil
+	}
+	var paths []string
+	if err := value.Decode(&paths); err != nil {
+		return err
+	}
+	*c = paths
+	return nil
+}
+
+// applyConfigFile decodes the config file at path into config, first
+// applying the files it includes in order. Each file only overrides the
+// keys it sets, so a local file can change the domains or outputs of a
+// shared base. Lists are replaced, not appended to, while maps such as
+// server_qps and sections such as retention are merged key by key, an
+// entry of the same key replacing the inherited one. Relative include paths
+// are resolved against the including file's directory. stack holds the
+// files being applied, to reject include cycles.
+func applyConfigFile(path string, config *Config, stack []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(stack, abs) {
+		return fmt.Errorf("config include cycle: %s", strings.Join(append(stack, abs), " -> "))
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	var directives struct {
+		Include configIncludes `yaml:"include"`
+		Extends configIncludes `yaml:"extends"`
+	}
+	if err := yaml.Unmarshal(data, &directives); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	for _, inc := range append(directives.Include, directives.Extends...) {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		if err := applyConfigFile(inc, config, append(stack, abs)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
 	}
 
-	return &config, nil
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return nil
 }
 
 // findConfigFile looks for config file in standard locations
@@ -192,37 +465,278 @@ func findConfigFile() string {
 
 //nolint:gocyclo // main() handles CLI flag parsing and orchestration; complexity is acceptable
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "prune" {
+		if err := runPrune(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		if err := runMerge(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "duel" {
+		if err := runDuel(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		if err := runCompare(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "monitor" {
+		if err := runMonitor(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := runVerify(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if errors.Is(err, errVerifyFailed) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "baseline" {
+		if err := runBaseline(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "catalog" {
+		if err := runCatalog(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "collect" {
+		if err := runCollect(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "trend" {
+		if err := runTrend(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
 		configFile   string
 		concurrency  int
 		iterations   int
 		timeout      time.Duration
+		queryType    string
 		duration     time.Duration
 		domainFile   string
 		serverFile   string
 		exportFile   string
 		htmlFile     string
+		jsonFile     string
+		cdfFile      string
 		browserName  string
 		verbose      bool
 		showProgress bool
 		dashboardDir string
+		conns        int
+		connsScaling bool
+		noKeepalive  bool
+		tlsResume    bool
+		tlsProbe     bool
+		noDiagnose   bool
+		networkTag   string
+		stream       bool
+		calibrate    bool
+		subtractOvh  bool
+		fallback     string
+		autoConc     bool
+		search       string
+		ndots        int
+		presetName   string
+		useResolved  bool
+		applyWinner  bool
+		proxyURL     string
+		wireProbes   bool
+		slaSpec      string
+		bootstrap    string
+		eyeballs     bool
+		idleProbe    bool
+		traceroute   bool
+		idlePeriods  string
+		browserWtd   bool
+		logSample    string
+		paired       bool
+		jsonlFile    string
+		listenAddr   string
+		penalize     bool
+		recheck      bool
+		interfaces   string
+		sourceIP     string
+		dbFile       string
+		anonymize    bool
+		slow         time.Duration
+		slowest      int
+		qps          float64
+		serverQPS    string
+		cacheBust    bool
+		stubCache    time.Duration
+		timeWindow   time.Duration
+		unit         string
+		precision    int
+		impact       string
+		coldWarm     bool
+		forwarder    string
+		upstream     string
+		hitRatio     float64
+		tieBreak     int
+		ednsSize     int
+		dnssec       bool
+		ednsPadding  bool
+		retries      int
+		ecs          string
+		ecsProbe     bool
+		queryFile    string
+		expectFile   string
+		internalDoms string
+		internalSrvs string
+		externalSrvs string
+		leakTest     bool
+		collapse     bool
+		pcapFile     string
+		pcapWeighted bool
+		lowMemory    bool
+		exportSpecs  string
+		profileName  string
+		listProfiles bool
+		updateCat    bool
+		catalogURL   string
+		catalogKey   string
+		probeName    string
+		baselineSrc  string
+		baselineOut  string
+		region       string
 	)
 
 	flag.StringVar(&configFile, "config", "", "Path to config file (YAML)")
 	flag.IntVar(&concurrency, "c", 0, "Number of concurrent queries")
 	flag.IntVar(&iterations, "n", 0, "Number of iterations per domain per server")
 	flag.DurationVar(&timeout, "t", 0, "Timeout for each query")
+	flag.StringVar(&queryType, "type", "", "Record type to query (e.g. A, AAAA, HTTPS, SVCB); default A")
 	flag.DurationVar(&duration, "d", 0, "Duration to run benchmark (e.g. 30s). Overrides -n if set.")
-	flag.StringVar(&domainFile, "domains", "", "File containing list of domains (one per line or CSV)")
-	flag.StringVar(&serverFile, "servers", "", "File containing list of servers (one per line or YAML)")
+	flag.StringVar(&domainFile, "domains", "", "File or http(s) URL containing list of domains (one per line or CSV), or tranco:N / umbrella:N for the top N of that list")
+	flag.StringVar(&expectFile, "expect", "", "File mapping domains to the addresses or CIDR ranges their A/AAAA answers must be in; other answers are flagged")
+	flag.StringVar(&queryFile, "queries", "", "File of questions, one per line: name [type] [expected answers, comma-separated | RCODE]; replaces -domains")
+	flag.StringVar(&serverFile, "servers", "", "File or http(s) URL containing list of servers (one per line or YAML)")
 	flag.StringVar(&exportFile, "o", "", "Output CSV file for raw results")
 	flag.StringVar(&htmlFile, "html", "", "Output HTML report file")
-	flag.StringVar(&browserName, "browser", "", "Import domains from browser history (chrome, brave, edge, firefox, safari, opera [Windows only])")
+	flag.StringVar(&jsonFile, "json", "", "Output JSON file with raw results and the run configuration")
+	flag.StringVar(&cdfFile, "cdf", "", "Output file with each server's latency CDF for plotting: CSV, or JSON for a .json file")
+	flag.StringVar(&jsonlFile, "jsonl", "", "Write every result to this file as a JSON line the moment it is measured")
+	flag.StringVar(&exportSpecs, "export", "", "Comma-separated compiled-in exporters to also send the results to, each name or name=target (available: "+exporterNames()+")")
+	flag.StringVar(&baselineSrc, "baseline", "", "Baselines file or http(s) URL (built with the baseline subcommand) to compare each public provider's median latency with")
+	flag.StringVar(&baselineOut, "baseline-summary", "", "Write this run's anonymized per-provider summary, for building baselines, to this file")
+	flag.StringVar(&region, "region", "", "Region (e.g. country code GB) for -baseline comparisons and -baseline-summary")
+	flag.StringVar(&dbFile, "db", "", "Append the run, its raw results and per-server stats to this SQLite database")
+	flag.StringVar(&listenAddr, "listen", "", "Serve Prometheus metrics at /metrics on this address (e.g. :9090) while the benchmark runs")
+	flag.StringVar(&browserName, "browser", "", "Import domains from browser history (chrome, chromium, brave, edge, opera, vivaldi, arc [macOS/Windows], firefox, safari [macOS only])")
+	flag.StringVar(&profileName, "browser-profile", "", "Browser profile to read history from, by directory (e.g. \"Profile 2\") or name (e.g. Work); default the browser's default profile")
+	flag.BoolVar(&listProfiles, "browser-profiles", false, "List the profiles of -browser and exit")
+	flag.BoolVar(&browserWtd, "browser-weighted", false, "With -browser, query each domain in proportion to how often it was visited")
 	flag.BoolVar(&verbose, "v", false, "Verbose logging (show errors and slow queries)")
+	flag.StringVar(&logSample, "log-sample", "", "Log every failed lookup and this percentage of answered ones, picked at random (e.g. 1%), for long runs where -v would log too much")
 	flag.BoolVar(&showProgress, "progress", false, "Show progress bar during benchmark")
+	flag.BoolVar(&updateCat, "update-catalog", false, "Fetch the latest signed provider catalog (public resolvers and default servers) and exit")
+	flag.StringVar(&catalogURL, "catalog-url", "", "URL of the provider catalog for -update-catalog; its signature is fetched from the same URL plus .sig")
+	flag.StringVar(&catalogKey, "catalog-key", "", "Base64 Ed25519 public key the provider catalog must be signed with")
+	flag.StringVar(&probeName, "probe", "", "Run as this probe of the config's fleet (default: the probe matching this host's name)")
 	flag.StringVar(&dashboardDir, "dashboard", "", "Generate index.html dashboard from history.csv in this directory (skips benchmark)")
+	flag.IntVar(&conns, "conns", 0, "Persistent connections per DoT/DoH server (default: one per concurrent query for DoT, the client's own for DoH)")
+	flag.BoolVar(&connsScaling, "conns-scaling", false, "Report how DoT/DoH latency scales from 1 to -conns connections per server")
+	flag.BoolVar(&noDiagnose, "no-diagnose", false, "Don't troubleshoot servers that failed every query (ping, TCP 853, HTTPS and a known-good server)")
+	flag.BoolVar(&noKeepalive, "no-keepalive", false, "Open a new connection for every DoT and DoH query instead of reusing them, to measure cold-connection performance")
+	flag.BoolVar(&tlsResume, "tls-resume", false, "Resume TLS sessions on new DoT/DoH connections, as most stubs do, and report resumed and full-handshake queries separately")
+	flag.StringVar(&networkTag, "network-tag", "", "Label results with this network name instead of the auto-detected interface/SSID/gateway")
+	flag.BoolVar(&stream, "stream", false, "Stream each result as a JSON line to stdout as it completes (summary goes to stderr)")
+	flag.BoolVar(&calibrate, "calibrate", false, "Measure the tool's own overhead against a local mock server before benchmarking")
+	flag.BoolVar(&subtractOvh, "subtract-overhead", false, "Subtract the calibrated overhead from every result (implies -calibrate)")
+	flag.StringVar(&fallback, "fallback", "", "Emulate stub resolver fallback for plain DNS servers: tcp (UDP, then TCP) or dot (UDP, TCP, then DoT)")
+	flag.BoolVar(&autoConc, "auto-concurrency", false, "Find the highest concurrency that doesn't inflate latency and use it instead of -c")
+	flag.StringVar(&search, "search", "", "Comma-separated search domains to emulate a stub resolver's search list, or 'system' for /etc/resolv.conf")
+	flag.IntVar(&ndots, "ndots", -1, "Dots a name needs to be tried as-is before the search list (default 1, or from resolv.conf)")
+	flag.StringVar(&presetName, "preset", "", "Use a ready-made workload: "+presetNames())
+	flag.BoolVar(&useResolved, "resolved", false, "Also benchmark the per-link DNS servers configured in systemd-resolved (Linux)")
+	flag.BoolVar(&applyWinner, "resolved-apply", false, "Set the fastest plain DNS server on every systemd-resolved link that has DNS configured (implies -resolved, needs root)")
+	flag.StringVar(&proxyURL, "proxy", "", "Send every query through a SOCKS5 proxy, e.g. socks5h://127.0.0.1:9050 (plain DNS then uses TCP)")
+	flag.BoolVar(&wireProbes, "wire-probes", false, "After the benchmark, send edge-case queries (unusual flags, EDNS options) to check how each server handles them")
+	flag.StringVar(&slaSpec, "sla", "", "Comma-separated SLA buckets to report per-server attainment for, e.g. 20ms,50ms:99,100ms:99.9 (optional target %)")
+	flag.StringVar(&bootstrap, "bootstrap", "", "Plain DNS server (host[:port]) used only to resolve DoT/DoH server hostnames, instead of the system resolver")
+	flag.StringVar(&interfaces, "interfaces", "", "Comma-separated network interfaces to benchmark over in parallel (e.g. eth0,wlan0), or 'all' for every usable one")
+	flag.StringVar(&sourceIP, "source-ip", "", "Send every query from this local address (e.g. a VPN tunnel's), or one IPv4 and one IPv6 address comma-separated")
+	flag.BoolVar(&recheck, "recheck", false, "Benchmark servers that failed every query in a recent run on this network instead of skipping them")
+	flag.IntVar(&tieBreak, "tiebreak", 0, "If the top two servers are within the noise, query just those two in extra rounds until the ranking is significant or either has this many samples")
+	flag.BoolVar(&penalize, "penalize-timeouts", false, "Rank servers by average latency with every timeout counted as taking the full query timeout (-t)")
+	flag.BoolVar(&anonymize, "anonymize", false, "Replace domain names in exports and reports with a hash that keeps only the TLD, for sharing results")
+	flag.DurationVar(&slow, "slow", 0, "Latency above which an answered query counts as slow (default 500ms)")
+	flag.IntVar(&slowest, "slowest", 0, "Number of slow queries to report per server (default 5)")
+	flag.Float64Var(&qps, "qps", 0, "Maximum queries per second across all servers (default unlimited)")
+	flag.IntVar(&ednsSize, "edns-size", 0, "EDNS0 UDP payload size to advertise, e.g. 1232 or 4096 (default no EDNS0, or 1232 with -dnssec or -edns-padding)")
+	flag.BoolVar(&dnssec, "dnssec", false, "Set the EDNS0 DO bit, asking for DNSSEC records")
+	flag.BoolVar(&ednsPadding, "edns-padding", false, "Pad queries to a multiple of 128 bytes with the EDNS0 padding option")
+	flag.StringVar(&ecs, "ecs", "", "Send this EDNS Client Subnet with every query, e.g. 203.0.113.0/24 (a bare address means /24, or /56 for IPv6)")
+	flag.BoolVar(&ecsProbe, "ecs-probe", false, "After the benchmark, check whether each server forwards a client subnet to authoritative servers")
+	flag.IntVar(&retries, "retries", 0, "Retry a failed lookup up to this many times and report first-try and final success rates")
+	flag.BoolVar(&paired, "paired", false, "Query each domain on every server back to back and compare servers on per-domain latency differences, which cancels out most network noise")
+	flag.BoolVar(&coldWarm, "cold-warm", false, "Query every domain twice in a row per server and report cold and warm (cached) latency separately")
+	flag.BoolVar(&cacheBust, "cache-bust", false, "Prepend a random label to every query (e.g. k3j2d9xa.example.com) so it misses the resolver's cache")
+	flag.StringVar(&forwarder, "forwarder", "", "Benchmark only this local forwarder (e.g. 127.0.0.1:5353) with a mix of cache hits and misses and split its latency into internal and upstream time")
+	flag.StringVar(&upstream, "upstream", "", "The -forwarder's upstream, queried directly with the same mix to measure the upstream share of a miss")
+	flag.Float64Var(&hitRatio, "hit-ratio", 0, "Share of queries meant to be cache hits, after warming each server's cache; the rest get a random label and miss (default 0.8 with -forwarder)")
+	flag.DurationVar(&timeWindow, "time-window", 0, "Break each server's latency down by time windows of this length (e.g. 1h), so quiet hours don't hide busy ones in long runs")
+	flag.StringVar(&unit, "unit", "", "Write latencies in tables and exports as plain numbers in this unit: ms or us (default Go durations in tables, ms in exports)")
+	flag.IntVar(&precision, "precision", -1, "Decimals of the latencies written with -unit (default to the microsecond; implies -unit ms)")
+	flag.DurationVar(&stubCache, "stub-cache", 0, "Simulate a local caching stub in front of each server, with every domain looked up once per this interval (e.g. 5m)")
+	flag.StringVar(&impact, "impact", "", "Estimate what switching from this server (the one you use now, which must be benchmarked) to each other would feel like")
+	flag.StringVar(&serverQPS, "server-qps", "", "Comma-separated per-server query rate limits, e.g. 8.8.8.8=10,1.1.1.1=20")
+	flag.StringVar(&internalDoms, "internal-domains", "", "Comma-separated internal-only names (and their subdomains) for a split-horizon check: only internal servers may resolve them")
+	flag.StringVar(&internalSrvs, "internal-servers", "", "Comma-separated servers that must resolve -internal-domains (default: every server not in -external-servers)")
+	flag.StringVar(&externalSrvs, "external-servers", "", "Comma-separated servers that must not resolve -internal-domains (default: every server not in -internal-servers)")
+	flag.StringVar(&pcapFile, "pcap", "", "Packet capture (pcap or pcapng) whose DNS query names are benchmarked, most frequent first")
+	flag.BoolVar(&pcapWeighted, "pcap-weighted", false, "With -pcap, query each name in proportion to how often it was seen in the capture")
+	flag.BoolVar(&lowMemory, "low-memory", false, fmt.Sprintf("Keep memory flat for routers and small devices: stream per-server stats, keep a sample of %d results and default to -c %d", lowMemoryResults, lowMemoryConcurrency))
+	flag.BoolVar(&collapse, "collapse-subdomains", false, "Benchmark each domain's registrable domain (eTLD+1, e.g. bbc.co.uk for www.bbc.co.uk) instead of the full hostname")
+	flag.BoolVar(&leakTest, "leak-test", false, "After the benchmark, check which resolvers performed each server's recursion and flag queries resolved outside the expected provider")
+	flag.BoolVar(&traceroute, "traceroute", false, "After the benchmark, trace the network path to each server with the system traceroute and report hops, ASes and where latency is added")
+	flag.BoolVar(&eyeballs, "happy-eyeballs", false, "After the benchmark, compare IPv6, IPv4 and Happy Eyeballs connection setup for dual-stack DoH/DoT servers")
+	flag.BoolVar(&idleProbe, "idle-probe", false, "After the benchmark, measure the first query to each DoT/DoH server after its connection sat idle (the reconnect penalty after sleep)")
+	flag.BoolVar(&tlsProbe, "tls-probe", false, "After the benchmark, check each DoT/DoH server for TLS session tickets and 0-RTT, and compare resumed with full-handshake queries")
+	flag.StringVar(&idlePeriods, "idle-periods", "", "Comma-separated idle periods for -idle-probe (default 10s,60s,300s; implies -idle-probe)")
 	flag.Parse()
 
+	// In stream mode stdout carries nothing but JSON lines, so everything
+	// human-readable (including benchmark progress) is redirected to stderr.
+	jsonOut := os.Stdout
+	if stream {
+		os.Stdout = os.Stderr
+	}
+
 	// Dashboard-only mode: generate index.html and exit.
 	if dashboardDir != "" {
 		if err := dashboard.Generate(dashboardDir); err != nil {
@@ -251,159 +765,1097 @@ func main() {
 		}
 	}
 
-	// Set defaults
+	// Defaults are applied after presets and flags, so both can tell what
+	// was left unset
 	if cfg == nil {
-		cfg = &Config{
-			Concurrency: 50,
-			Iterations:  1,
-			Timeout:     1 * time.Second,
+		cfg = &Config{}
+	}
+
+	// A preset fills in what the config file left unset; flags still win
+	if presetName != "" {
+		cfg.Preset = presetName
+	}
+	// Warnings are listed after the results and embedded in the exports
+	warns := &warningLog{}
+	var roles []serverRole
+	var activePreset preset
+	if cfg.Preset != "" {
+		p, ok := presets[cfg.Preset]
+		if !ok {
+			fmt.Printf("Error: unknown preset %q (available: %s)\n", cfg.Preset, presetNames())
+			os.Exit(1)
+		}
+		activePreset = p
+		roles = p.apply(cfg, warns)
+		fmt.Printf("Preset %s: %s\n", cfg.Preset, p.Description)
+	}
+
+	// CLI flags override config file
+	if concurrency > 0 {
+		cfg.Concurrency = concurrency
+	}
+	if iterations > 0 {
+		cfg.Iterations = iterations
+	}
+	if timeout > 0 {
+		cfg.Timeout = timeout
+	}
+	if queryType != "" {
+		cfg.QueryType = queryType
+	}
+	if duration > 0 {
+		cfg.Duration = duration
+	}
+	if domainFile != "" {
+		cfg.DomainFile = domainFile
+	}
+	if serverFile != "" {
+		cfg.ServerFile = serverFile
+	}
+	if exportFile != "" {
+		cfg.ExportCSV = exportFile
+	}
+	if htmlFile != "" {
+		cfg.ExportHTML = htmlFile
+	}
+	if jsonFile != "" {
+		cfg.ExportJSON = jsonFile
+	}
+	if cdfFile != "" {
+		cfg.ExportCDF = cdfFile
+	}
+	if jsonlFile != "" {
+		cfg.ExportJSONL = jsonlFile
+	}
+	if listenAddr != "" {
+		cfg.Listen = listenAddr
+	}
+	if dbFile != "" {
+		cfg.DB = dbFile
+	}
+	if browserName != "" {
+		cfg.BrowserName = browserName
+	}
+	if profileName != "" {
+		cfg.BrowserProfile = profileName
+	}
+	if verbose {
+		cfg.Verbose = verbose
+	}
+	if logSample != "" {
+		cfg.LogSample = logSample
+	}
+	if showProgress {
+		cfg.Progress = showProgress
+	}
+	if conns > 0 {
+		cfg.ConnsPerServer = conns
+	}
+	if connsScaling {
+		cfg.ConnsScaling = connsScaling
+	}
+	if noKeepalive {
+		cfg.NoKeepalive = true
+	}
+	if tlsResume {
+		cfg.TLSResume = true
+	}
+	if tlsProbe {
+		cfg.TLSProbe = true
+	}
+	if noDiagnose {
+		cfg.NoDiagnose = true
+	}
+	if networkTag != "" {
+		cfg.NetworkTag = networkTag
+	}
+	if calibrate {
+		cfg.Calibrate = calibrate
+	}
+	if subtractOvh {
+		cfg.SubtractOverhead = subtractOvh
+	}
+	if fallback != "" {
+		cfg.Fallback = fallback
+	}
+	if autoConc {
+		cfg.AutoConcurrency = true
+	}
+	if search != "" {
+		cfg.SearchDomains = splitList(search)
+	}
+	if useResolved || applyWinner {
+		cfg.Resolved = true
+	}
+	if ndots >= 0 {
+		cfg.Ndots = &ndots
+	}
+	if proxyURL != "" {
+		cfg.Proxy = proxyURL
+	}
+	if wireProbes {
+		cfg.WireProbes = true
+	}
+	if interfaces != "" {
+		cfg.Interfaces = splitList(interfaces)
+	}
+	if sourceIP != "" {
+		cfg.SourceIPs = splitList(sourceIP)
+	}
+	if slaSpec != "" {
+		cfg.SLA = splitList(slaSpec)
+	}
+	if bootstrap != "" {
+		cfg.Bootstrap = bootstrap
+	}
+	if eyeballs {
+		cfg.HappyEyeballs = true
+	}
+	if traceroute {
+		cfg.Traceroute = true
+	}
+	if idleProbe {
+		cfg.IdleProbe = true
+	}
+	if idlePeriods != "" {
+		periods, err := parseIdlePeriods(idlePeriods)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.IdleProbe = true
+		cfg.IdlePeriods = periods
+	}
+	if penalize {
+		cfg.PenalizeTimeouts = true
+	}
+	if anonymize {
+		cfg.Anonymize = true
+	}
+	if slow > 0 {
+		cfg.SlowThreshold = slow
+	}
+	if slowest > 0 {
+		cfg.Slowest = slowest
+	}
+	if qps > 0 {
+		cfg.QPS = qps
+	}
+	if cacheBust {
+		cfg.CacheBust = true
+	}
+	if paired {
+		cfg.Paired = true
+	}
+	if coldWarm {
+		cfg.ColdWarm = true
+	}
+	if forwarder != "" {
+		cfg.Forwarder = forwarder
+	}
+	if upstream != "" {
+		cfg.Upstream = upstream
+	}
+	if hitRatio != 0 {
+		cfg.HitRatio = hitRatio
+	}
+	if tieBreak > 0 {
+		cfg.TieBreak = tieBreak
+	}
+	if ednsSize > 0 {
+		cfg.EDNSSize = ednsSize
+	}
+	if dnssec {
+		cfg.DNSSEC = true
+	}
+	if ednsPadding {
+		cfg.EDNSPadding = true
+	}
+	if retries > 0 {
+		cfg.Retries = retries
+	}
+	if ecs != "" {
+		cfg.ClientSubnet = ecs
+	}
+	if ecsProbe {
+		cfg.ECSProbe = true
+	}
+	if queryFile != "" {
+		cfg.QueryFile = queryFile
+	}
+	if expectFile != "" {
+		cfg.ExpectFile = expectFile
+	}
+	if internalDoms != "" {
+		cfg.InternalDomains = splitList(internalDoms)
+	}
+	if internalSrvs != "" {
+		cfg.InternalServers = splitList(internalSrvs)
+	}
+	if externalSrvs != "" {
+		cfg.ExternalServers = splitList(externalSrvs)
+	}
+	if leakTest {
+		cfg.LeakTest = true
+	}
+	if collapse {
+		cfg.CollapseSubdomains = true
+	}
+	if pcapFile != "" {
+		cfg.PcapFile = pcapFile
+	}
+	if pcapWeighted {
+		cfg.PcapWeighted = true
+	}
+	if browserWtd {
+		cfg.BrowserWeighted = true
+	}
+	if lowMemory {
+		cfg.LowMemory = true
+	}
+	if exportSpecs != "" {
+		cfg.Exports = splitList(exportSpecs)
+	}
+	if baselineSrc != "" {
+		cfg.Baseline = baselineSrc
+	}
+	if baselineOut != "" {
+		cfg.BaselineSummary = baselineOut
+	}
+	if region != "" {
+		cfg.Region = region
+	}
+	if stubCache > 0 {
+		cfg.StubCache = stubCache
+	}
+	if timeWindow > 0 {
+		cfg.TimeWindow = timeWindow
+	}
+	if unit != "" {
+		cfg.Unit = unit
+	}
+	if precision >= 0 {
+		cfg.Precision = &precision
+	}
+	latency, err := newLatencyFormat(cfg.Unit, cfg.Precision)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if impact != "" {
+		cfg.Impact = impact
+	}
+	if serverQPS != "" {
+		limits, err := parseServerQPS(splitList(serverQPS))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.ServerQPS == nil {
+			cfg.ServerQPS = make(map[string]float64, len(limits))
+		}
+		maps.Copy(cfg.ServerQPS, limits)
+	}
+
+	if catalogURL != "" {
+		cfg.CatalogURL = catalogURL
+	}
+	if catalogKey != "" {
+		cfg.CatalogKey = catalogKey
+	}
+	if updateCat {
+		path := catalogPath()
+		file, updated, err := updateCatalog(cmp.Or(cfg.CatalogURL, CatalogURL), cmp.Or(cfg.CatalogKey, CatalogKey), path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !updated {
+			fmt.Printf("Provider catalog is up to date (fetched version %d)\n", file.Version)
+			return
+		}
+		fmt.Printf("Provider catalog version %d (%d providers, %d default servers) saved to %s\n", file.Version, len(file.Providers), len(file.DefaultServers), path)
+		return
+	}
+
+	if probeName != "" {
+		cfg.Probe = probeName
+	}
+	probe, err := resolveProbe(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if probe != nil {
+		cfg.Probe = probe.Name
+	}
+
+	if listProfiles {
+		if cfg.BrowserName == "" {
+			fmt.Println("Error: -browser-profiles needs -browser")
+			os.Exit(1)
+		}
+		profiles, err := browser.Profiles(cfg.BrowserName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		printBrowserProfiles(cfg.BrowserName, profiles)
+		return
+	}
+
+	// Apply final defaults
+	if cfg.Concurrency == 0 && cfg.LowMemory {
+		cfg.Concurrency = lowMemoryConcurrency
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = 50
+	}
+	if cfg.Iterations == 0 {
+		cfg.Iterations = 1
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 1 * time.Second
+	}
+	if cfg.QueryType == "" {
+		cfg.QueryType = "A"
+	}
+	if cfg.SlowThreshold == 0 {
+		cfg.SlowThreshold = benchmark.DefaultSlowThreshold
+	}
+	if cfg.Slowest == 0 {
+		cfg.Slowest = defaultSlowest
+	}
+	qtype, ok := dns.StringToType[strings.ToUpper(cfg.QueryType)]
+	if !ok {
+		fmt.Printf("Error: unknown query type %q\n", cfg.QueryType)
+		os.Exit(1)
+	}
+	cfg.QueryType = dns.TypeToString[qtype]
+	searchDomains, searchNdots, err := resolveSearch(cfg.SearchDomains, cfg.Ndots)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.CacheBust && len(searchDomains) > 0 {
+		// Random names don't exist, so a stub would always walk the whole list
+		fmt.Printf("Error: -cache-bust can't be combined with a search list\n")
+		os.Exit(1)
+	}
+	if cfg.EDNSSize != 0 && (cfg.EDNSSize < 512 || cfg.EDNSSize > 65535) {
+		fmt.Printf("Error: invalid EDNS0 UDP size %d (expected 512 to 65535)\n", cfg.EDNSSize)
+		os.Exit(1)
+	}
+	var clientSubnet netip.Prefix
+	if cfg.ClientSubnet != "" {
+		if clientSubnet, err = benchmark.ParseClientSubnet(cfg.ClientSubnet); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.CacheBust && cfg.ColdWarm {
+		// Every query goes to a new name, so the second of a pair is cold too
+		fmt.Printf("Error: -cache-bust can't be combined with -cold-warm\n")
+		os.Exit(1)
+	}
+	if cfg.TieBreak != 0 && cfg.TieBreak < tiebreakMinSamples {
+		fmt.Printf("Error: -tiebreak needs a cap of at least %d samples\n", tiebreakMinSamples)
+		os.Exit(1)
+	}
+	if cfg.TieBreak > 0 && cfg.LowMemory {
+		// The test needs every result of the top two
+		fmt.Printf("Error: -tiebreak can't be combined with -low-memory\n")
+		os.Exit(1)
+	}
+	if cfg.Upstream != "" && cfg.Forwarder == "" {
+		fmt.Printf("Error: -upstream needs -forwarder\n")
+		os.Exit(1)
+	}
+	if cfg.Forwarder != "" && cfg.HitRatio == 0 {
+		cfg.HitRatio = defaultHitRatio
+	}
+	if cfg.HitRatio < 0 || cfg.HitRatio > 1 {
+		fmt.Printf("Error: invalid hit ratio %g (expected above 0 and up to 1)\n", cfg.HitRatio)
+		os.Exit(1)
+	}
+	if cfg.HitRatio > 0 && (cfg.CacheBust || cfg.ColdWarm) {
+		// The mix decides which queries hit and which miss on its own
+		fmt.Printf("Error: -hit-ratio and -forwarder can't be combined with -cache-bust or -cold-warm\n")
+		os.Exit(1)
+	}
+	if cfg.HitRatio > 0 && cfg.TieBreak > 0 {
+		// Every extra round would warm the caches again, and its mix of hits
+		// and misses would skew the comparison
+		fmt.Printf("Error: -tiebreak can't be combined with -hit-ratio or -forwarder\n")
+		os.Exit(1)
+	}
+	if cfg.HitRatio > 0 && len(searchDomains) > 0 {
+		// Misses go to random names, which a stub would walk the whole list for
+		fmt.Printf("Error: -hit-ratio and -forwarder can't be combined with a search list\n")
+		os.Exit(1)
+	}
+	if cfg.Fallback != "" && cfg.Fallback != benchmark.FallbackTCP && cfg.Fallback != benchmark.FallbackDoT {
+		fmt.Printf("Error: invalid fallback mode %q (expected %q or %q)\n", cfg.Fallback, benchmark.FallbackTCP, benchmark.FallbackDoT)
+		os.Exit(1)
+	}
+	slaTargets, err := parseSLA(cfg.SLA)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	logFraction, err := parseLogSample(cfg.LogSample)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	leakExpect, err := parseLeakExpect(cfg.LeakExpect)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Bootstrap != "" && (strings.Contains(cfg.Bootstrap, "://") || validation.IsValidServer(cfg.Bootstrap) != nil) {
+		fmt.Printf("Error: invalid bootstrap resolver %q (expected a plain DNS server, e.g. 9.9.9.9)\n", cfg.Bootstrap)
+		os.Exit(1)
+	}
+	if version := applyCatalog(catalogPath(), cmp.Or(cfg.CatalogKey, CatalogKey), warns); version > builtinCatalogVersion {
+		fmt.Printf("Using provider catalog version %d\n", version)
+	}
+	var proxy *url.URL
+	if cfg.Proxy != "" {
+		proxy, err = benchmark.ParseProxy(cfg.Proxy)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.Fallback != "" {
+			warns.add("proxy", "-fallback is ignored with -proxy; plain DNS always uses TCP through the proxy")
+		}
+		if cfg.Bootstrap != "" {
+			warns.add("proxy", "-bootstrap is ignored with -proxy; the proxy resolves server hostnames")
+		}
+		// Keep proxy credentials out of the console and exports
+		cfg.Proxy = proxy.Redacted()
+		fmt.Printf("Proxy: %s\n", cfg.Proxy)
+		if err := checkProxy(proxy, cfg.Timeout); err != nil {
+			warns.add("proxy", "proxy is not reachable (is it running?): %v", err)
+		}
+	}
+	var ifaces []string
+	if len(cfg.Interfaces) > 0 {
+		if proxy != nil {
+			fmt.Println("Error: -interfaces can't be combined with -proxy; the proxy decides the route")
+			os.Exit(1)
+		}
+		ifaces, err = resolveInterfaces(cfg.Interfaces)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var sourceIPs []netip.Addr
+	var sourceIface string
+	if len(cfg.SourceIPs) > 0 {
+		if proxy != nil {
+			fmt.Println("Error: -source-ip can't be combined with -proxy; the proxy decides the route")
+			os.Exit(1)
+		}
+		if len(ifaces) > 1 {
+			fmt.Println("Error: -source-ip can't be combined with more than one interface in -interfaces")
+			os.Exit(1)
+		}
+		sourceIPs, sourceIface, err = resolveSourceIPs(cfg.SourceIPs)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.ConnsScaling && cfg.ConnsPerServer == 0 {
+		cfg.ConnsPerServer = 8
+	}
+	if cfg.NoKeepalive && cfg.ConnsPerServer > 0 {
+		fmt.Println("Error: -no-keepalive can't be combined with -conns or -conns-scaling")
+		os.Exit(1)
+	}
+
+	entries := cfg.Servers
+	if len(entries) == 0 {
+		entries = serverEntries(defaultServers)
+	}
+	if cfg.ServerFile != "" {
+		var err error
+		entries, err = readServers(cfg.ServerFile, warns)
+		if err != nil {
+			fmt.Printf("Error reading server file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.Forwarder != "" {
+		// The forwarder under test replaces the server list
+		entries = serverEntries([]string{cfg.Forwarder})
+		if cfg.Upstream != "" {
+			entries = serverEntries([]string{cfg.Forwarder, cfg.Upstream})
+		}
+	}
+	servers, specs, err := serverSpecs(entries)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resolvedLinks []resolved.Link
+	if cfg.Resolved {
+		servers, resolvedLinks = addResolvedServers(servers, warns)
+	}
+
+	// Validate servers
+	validServers, serverWarnings := validation.ValidateServers(servers)
+	warns.addDropped("servers", "servers", serverWarnings, len(servers), len(validServers))
+	if len(validServers) == 0 {
+		fmt.Println("Error: no valid servers to test")
+		os.Exit(1)
+	}
+	servers = validServers
+	if cfg.Impact != "" && !slices.Contains(servers, cfg.Impact) {
+		fmt.Printf("Error: -impact server %s is not being benchmarked\n", cfg.Impact)
+		os.Exit(1)
+	}
+
+	domains := cfg.Domains
+	if len(domains) == 0 {
+		domains = defaultDomains
+	}
+	// Popularity rank of each domain, when the source provides one, and
+	// visit counts for browser history
+	var ranks, visits map[string]int
+	var questions []benchmark.Question
+	// Imported lists are normalized, with a report of what became of them
+	var imported importReport
+	if cfg.QueryFile != "" {
+		var err error
+		if questions, err = readQueries(cfg.QueryFile); err != nil {
+			fmt.Printf("Error reading query file: %v\n", err)
+			os.Exit(1)
+		}
+		domains = make([]string, len(questions))
+		for i, q := range questions {
+			domains[i] = q.Name
+		}
+	} else if cfg.DomainFile != "" {
+		list, count, isTopList, err := parseTopList(cfg.DomainFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if isTopList {
+			domains, ranks, err = loadTopList(list, count, topListCacheFile(list), time.Now(), warns)
+			if err != nil {
+				fmt.Printf("Error loading top domains list: %v\n", err)
+				os.Exit(1)
+			}
+		} else if domains, ranks, err = readDomains(cfg.DomainFile, warns); err != nil {
+			fmt.Printf("Error reading domain file: %v\n", err)
+			os.Exit(1)
+		}
+		imported = importReport{Source: cfg.DomainFile, Entries: len(domains)}
+	} else if cfg.PcapFile != "" {
+		capture, err := readPcap(cfg.PcapFile)
+		if err != nil {
+			fmt.Printf("Error reading packet capture: %v\n", err)
+			os.Exit(1)
+		}
+		domains, visits = capture.Domains, capture.Counts
+		fmt.Printf("Found %d unique names in %d DNS queries in %s\n", len(domains), capture.Queries, cfg.PcapFile)
+		imported = captureReport(cfg.PcapFile, capture)
+	} else if cfg.BrowserName != "" {
+		fmt.Printf("Extracting domains from %s history...\n", cfg.BrowserName)
+		spec := cfg.BrowserName
+		if cfg.BrowserProfile != "" {
+			spec += ":" + cfg.BrowserProfile
+		}
+		history, err := browser.ReadHistory(spec, 1000) // Limit to 1000 most recent/frequent
+		if err != nil {
+			if strings.Contains(err.Error(), "operation not permitted") {
+				fmt.Printf("\n⚠️  PERMISSION DENIED: macOS prevented access to %s history.\n", cfg.BrowserName)
+				fmt.Printf("To fix this:\n")
+				fmt.Printf("1. Open System Settings -> Privacy & Security -> Full Disk Access\n")
+				fmt.Printf("2. Grant access to your terminal app (e.g., Terminal, iTerm2, VSCode)\n")
+				fmt.Printf("3. Restart the terminal and try again.\n\n")
+				os.Exit(1)
+			}
+			fmt.Printf("Error extracting browser history: %v\n", err)
+			os.Exit(1)
+		}
+		domains, visits = history.Domains, history.Visits
+		fmt.Printf("Found %d unique domains from %s\n", len(domains), cfg.BrowserName)
+		imported = historyReport(cfg.BrowserName, history)
+	}
+	if questions == nil {
+		var renamed map[string]string
+		domains, renamed = normalizeDomains(domains, cfg.CollapseSubdomains, &imported)
+		ranks = renameCounts(ranks, renamed, func(a, b int) int { return min(a, b) })
+		visits = renameCounts(visits, renamed, func(a, b int) int { return a + b })
+		if visits != nil {
+			ranks = rankByVisits(domains, visits)
+		}
+	}
+
+	// Validate domains
+	validate := validation.ValidateDomains
+	if len(searchDomains) > 0 {
+		validate = validation.ValidateSearchNames
+	}
+	validDomains, domainWarnings := validate(domains)
+	warns.addDropped("domains", "domains", domainWarnings, len(domains), len(validDomains))
+	if imported.Source != "" {
+		imported.Invalid = len(domains) - len(validDomains)
+		imported.Kept = len(validDomains)
+		printImport(imported)
+	}
+	if len(validDomains) == 0 {
+		fmt.Println("Error: no valid domains to test")
+		os.Exit(1)
+	}
+	domains = validDomains
+	questions = keepValidQuestions(questions, domains)
+	if cfg.ExpectFile != "" {
+		expect, err := readExpectations(cfg.ExpectFile)
+		if err != nil {
+			fmt.Printf("Error reading expectations file: %v\n", err)
+			os.Exit(1)
+		}
+		questions = applyExpectations(questions, domains, expect)
+	}
+
+	// Through a proxy, the proxy's network decides how IPv4 is reached
+	var via map[string]string
+	var natLabel string
+	if proxy == nil {
+		via, natLabel = checkNAT64(servers, warns)
+		printBootstrap(servers, cfg.Bootstrap, cfg.Timeout)
+	}
+
+	// Queries from a source address leave through its interface, which
+	// needn't be the one carrying the default route
+	network := cfg.NetworkTag
+	switch {
+	case network != "":
+	case sourceIface != "":
+		network = netenv.ForInterface(sourceIface).Label() + natLabel
+	default:
+		network = netenv.Detect().Label() + natLabel
+	}
+
+	// Servers that were unreachable a moment ago on this network would only
+	// cost their timeouts again. Through a proxy, reachability is the proxy's.
+	var health *healthState
+	healthFile := defaultHealthFile()
+	if healthFile != "" && proxy == nil {
+		health, err = loadHealth(healthFile)
+		if err != nil {
+			warns.add("health", "ignoring server health state: %v", err)
+		}
+	}
+	if health != nil && !recheck {
+		var skipped []healthEntry
+		servers, skipped = health.skipUnreachable(servers, network, time.Now())
+		printSkipped(skipped, time.Now())
+	}
+
+	fmt.Printf("Starting benchmark...\n")
+	fmt.Printf("Network: %s\n", network)
+	if probe != nil {
+		if len(probe.Tags) > 0 {
+			fmt.Printf("Probe: %s (%s)\n", probe.Name, formatTags(probe.Tags))
+		} else {
+			fmt.Printf("Probe: %s\n", probe.Name)
+		}
+	}
+	if qtype != dns.TypeA {
+		fmt.Printf("Query type: %s\n", cfg.QueryType)
+	}
+	if len(ifaces) > 0 {
+		fmt.Printf("Interfaces: %s\n", strings.Join(ifaces, ", "))
+	}
+	if len(sourceIPs) > 0 {
+		fmt.Printf("Source IP: %s\n", strings.Join(cfg.SourceIPs, ", "))
+	}
+	if len(searchDomains) > 0 {
+		fmt.Printf("Search list: %s (ndots:%d)\n", strings.Join(searchDomains, " "), searchNdots)
+	}
+	if len(questions) > 0 {
+		expected, ranged := 0, 0
+		for _, q := range questions {
+			if q.ExpectRcode != "" || len(q.ExpectAnswers) > 0 || len(q.ExpectWithin) > 0 {
+				expected++
+			}
+			if len(q.ExpectWithin) > 0 {
+				ranged++
+			}
+		}
+		if cfg.QueryFile != "" {
+			fmt.Printf("Questions: %d from %s, %d with expected answers\n", len(questions), cfg.QueryFile, expected)
+		}
+		if cfg.ExpectFile != "" {
+			fmt.Printf("Expected addresses: %d of %d questions checked against %s\n", ranged, len(questions), cfg.ExpectFile)
+		}
+	}
+	if cfg.CacheBust {
+		fmt.Printf("Cache busting: every query goes to a random subdomain\n")
+	}
+	if cfg.ColdWarm {
+		fmt.Printf("Cold/warm pairs: every query is repeated right away\n")
+	}
+	if cfg.HitRatio > 0 {
+		fmt.Printf("Cache mix: %.0f%% hits after a warm-up, the rest random subdomains that miss\n", cfg.HitRatio*100)
+	}
+	if label := ednsLabel(cfg); label != "" {
+		fmt.Printf("EDNS0: %s\n", label)
+	}
+	var horizonRoles map[string]string
+	if len(cfg.InternalDomains) > 0 {
+		if len(cfg.InternalServers) == 0 && len(cfg.ExternalServers) == 0 {
+			warns.add("split-horizon", "internal_domains needs internal_servers or external_servers; skipping the split-horizon check")
+		} else {
+			warnSplitHorizon(cfg, servers, domains, warns)
+			horizonRoles = splitRoles(servers, cfg.InternalServers, cfg.ExternalServers)
+			fmt.Printf("Split horizon: %s internal-only\n", strings.Join(cfg.InternalDomains, ", "))
+		}
+	}
+	printRateLimits(cfg.QPS, cfg.ServerQPS, servers, warns)
+	concurrencyLabel := strconv.Itoa(cfg.Concurrency)
+	if cfg.AutoConcurrency {
+		concurrencyLabel = "auto"
+	}
+	if cfg.Duration > 0 {
+		fmt.Printf("Servers: %d, Domains: %d, Duration: %v, Concurrency: %s\n", len(servers), len(domains), cfg.Duration, concurrencyLabel)
+	} else {
+		fmt.Printf("Servers: %d, Domains: %d, Iterations: %d, Concurrency: %s\n", len(servers), len(domains), cfg.Iterations, concurrencyLabel)
+	}
+
+	config := benchmark.Config{
+		Servers:        servers,
+		Domains:        domains,
+		Questions:      questions,
+		Iterations:     cfg.Iterations,
+		Concurrency:    cfg.Concurrency,
+		Timeout:        cfg.Timeout,
+		QType:          qtype,
+		Duration:       cfg.Duration,
+		Verbose:        cfg.Verbose,
+		SlowThreshold:  cfg.SlowThreshold,
+		ShowProgress:   cfg.Progress,
+		ConnsPerServer: cfg.ConnsPerServer,
+		Fallback:       cfg.Fallback,
+		SearchDomains:  searchDomains,
+		Ndots:          searchNdots,
+		Proxy:          proxy,
+		Bootstrap:      cfg.Bootstrap,
+		SourceIPs:      sourceIPs,
+		Via:            via,
+		Network:        network,
+		CacheBust:      cfg.CacheBust,
+		ColdWarm:       cfg.ColdWarm,
+		UDPSize:        uint16(cfg.EDNSSize), //nolint:gosec // G115: checked to be at most 65535 above
+		DNSSEC:         cfg.DNSSEC,
+		Retries:        cfg.Retries,
+		Padding:        cfg.EDNSPadding,
+		ClientSubnet:   clientSubnet,
+		QPS:            cfg.QPS,
+		ServerQPS:      cfg.ServerQPS,
+		Paired:         cfg.Paired,
+		Specs:          specs,
+		NoKeepalive:    cfg.NoKeepalive,
+		TLSResume:      cfg.TLSResume,
+		HitRatio:       cfg.HitRatio,
+	}
+	if cfg.LowMemory {
+		config.MaxResults = lowMemoryResults
+	}
+	if logFraction > 0 {
+		config.LogSample = logFraction
+		fmt.Printf("Logging every failed lookup and %s of answered ones\n", cfg.LogSample)
+	}
+	if cfg.Paired && len(config.Servers) < 2 {
+		warns.add("paired", "-paired needs at least two servers to compare")
+	}
+	var weightedBy string
+	switch {
+	case cfg.PcapFile != "" && cfg.PcapWeighted:
+		weightedBy = "the capture"
+	case cfg.BrowserWeighted && cfg.BrowserName == "":
+		warns.add("domains", "-browser-weighted has no effect without -browser")
+	case cfg.BrowserWeighted:
+		weightedBy = "visits"
+	}
+	if weightedBy != "" {
+		applyWeights(&config, domainWeights(visits, nil))
+		if config.Duration > 0 {
+			top, share := mostVisited(domains, visits)
+			fmt.Printf("Weighted by %s: %s, the most frequent name, gets %.1f%% of queries\n", weightedBy, top, share)
+		} else {
+			fmt.Printf("Weighted by %s: %d queries per iteration over %d names\n", weightedBy, len(config.Questions), len(domains))
+		}
+	}
+	var anon *anonymizer
+	if cfg.Anonymize {
+		if anon, err = newAnonymizer(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	exps, err := openExporters(cfg.Exports)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var sinks []func(benchmark.Result)
+	if stream {
+		sinks = append(sinks, anon.sink(streamResults(jsonOut)))
+	}
+	if cfg.ExportJSONL != "" {
+		file, err := os.Create(cfg.ExportJSONL)
+		if err != nil {
+			fmt.Printf("Error creating JSONL file: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+			}
+		}()
+		sinks = append(sinks, anon.sink(streamResults(file)))
+	}
+	var live *liveHub
+	if cfg.Listen != "" {
+		m := newMetrics()
+		live = newLiveHub(config.Servers, expectedResults(config, len(ifaces)), config.Duration)
+		srv, err := serveMetrics(m, live, cfg.Listen)
+		if err != nil {
+			fmt.Printf("Error starting metrics server: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := srv.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to stop metrics server: %v\n", err)
+			}
+		}()
+		sinks = append(sinks, m.observe)
+	}
+	// With -low-memory, stats come from every result as it arrives, since
+	// Run only returns a sample
+	var collector statsCollector
+	if cfg.LowMemory {
+		collector = make(statsCollector)
+		sinks = append(sinks, collector.add)
+	}
+	// The extra rounds of -tiebreak go to every sink but the live dashboard,
+	// which has finished with the main run by then
+	roundSinks := slices.Clip(sinks)
+	if live != nil {
+		sinks = append(roundSinks, anon.sink(live.observe))
+	}
+	config.OnResult = fanOut(sinks)
+
+	if cfg.AutoConcurrency {
+		fmt.Printf("Tuning concurrency (up to %d)...\n", maxAutoConcurrency)
+		chosen, probes := autoConcurrency(config, maxAutoConcurrency)
+		printAutoConcurrency(chosen, probes)
+		config.Concurrency = chosen
+	}
+
+	if cfg.Calibrate || cfg.SubtractOverhead {
+		cal, err := benchmark.Calibrate(config, calibrationSamples)
+		if err != nil {
+			warns.add("calibration", "calibration failed: %v", err)
+		} else {
+			fmt.Printf("Measurement overhead: median %v, min %v, p95 %v (%d samples at concurrency %d)\n",
+				cal.Median, cal.Min, cal.P95, cal.Samples, config.Concurrency)
+			if cfg.SubtractOverhead {
+				config.Overhead = cal.Median
+				fmt.Printf("Subtracting %v from every result\n", cal.Median)
+			}
+		}
+	}
+
+	if live != nil {
+		go live.run()
+	}
+	start := time.Now()
+	var results []benchmark.Result
+	if len(ifaces) > 0 {
+		results = runInterfaces(config, ifaces)
+	} else {
+		results = benchmark.Run(config)
+	}
+	totalTime := time.Since(start)
+	if live != nil {
+		live.finish()
+	}
+
+	var stats []*ServerStats
+	if collector != nil {
+		stats = collector.stats()
+		total := 0
+		for _, s := range stats {
+			total += s.Total
+		}
+		if total > len(results) {
+			warns.add("low-memory", "reports and exports built from individual results use a random sample of %d of the %d results; per-server stats cover all of them", len(results), total)
+		}
+	} else {
+		stats = calculateStats(results)
+	}
+	var tb tiebreak
+	var tieTested bool
+	if cfg.TieBreak > 0 {
+		rank := func(results []benchmark.Result) []*ServerStats {
+			stats := calculateStats(results)
+			if cfg.PenalizeTimeouts {
+				rankPenalized(stats, cfg.Timeout)
+			}
+			return stats
+		}
+		run := benchmark.Run
+		if len(ifaces) > 0 {
+			run = func(c benchmark.Config) []benchmark.Result { return runInterfaces(c, ifaces) }
+		}
+		var penalty time.Duration
+		if cfg.PenalizeTimeouts {
+			penalty = cfg.Timeout
+		}
+		round := config
+		round.OnResult = fanOut(roundSinks)
+		results, tb, tieTested = breakTie(round, rank(results), results, cfg.TieBreak, penalty, run, rank)
+		if tb.Rounds > 0 {
+			stats = calculateStats(results)
 		}
 	}
-
-	// CLI flags override config file
-	if concurrency > 0 {
-		cfg.Concurrency = concurrency
-	}
-	if iterations > 0 {
-		cfg.Iterations = iterations
+	if health != nil {
+		health.update(stats, results, network, time.Now())
+		if err := health.save(healthFile, time.Now()); err != nil {
+			warns.add("health", "failed to save server health state: %v", err)
+		}
 	}
-	if timeout > 0 {
-		cfg.Timeout = timeout
+	for _, s := range stats {
+		s.Name = config.Specs[s.Server].Name
 	}
-	if duration > 0 {
-		cfg.Duration = duration
+	if cfg.PenalizeTimeouts {
+		rankPenalized(stats, cfg.Timeout)
 	}
-	if domainFile != "" {
-		cfg.DomainFile = domainFile
+	printTable(stats, totalTime, latency)
+	if cfg.PenalizeTimeouts {
+		fmt.Printf("Ranked by penalized average, with each timeout counted as %v\n", cfg.Timeout)
 	}
-	if serverFile != "" {
-		cfg.ServerFile = serverFile
+	if tieTested {
+		printTiebreak(tb)
 	}
-	if exportFile != "" {
-		cfg.ExportCSV = exportFile
+	printInterfaces(stats, results)
+	printPercentiles(calculatePercentiles(stats, results), latency)
+	if cfg.TimeWindow > 0 {
+		printTimeWindows(segmentByWindow(stats, results, cfg.TimeWindow))
 	}
-	if htmlFile != "" {
-		cfg.ExportHTML = htmlFile
+	printFairness(checkFairness(stats, results), cfg.Duration > 0 || cfg.Verbose)
+	printKeepalive(stats)
+	printChains(stats)
+	printDoHTiming(stats)
+	printConnSetup(stats, cfg.TLSResume)
+	printFallback(stats)
+	printSVCB(stats)
+	printSearch(stats)
+	if cfg.ColdWarm {
+		printColdWarm(stats)
 	}
-	if browserName != "" {
-		cfg.BrowserName = browserName
+	if cfg.Forwarder != "" {
+		printForwarder(analyzeForwarder(results, cfg.Forwarder, cfg.Upstream, cfg.HitRatio))
 	}
-	if verbose {
-		cfg.Verbose = verbose
+	if cfg.Paired {
+		printPaired(comparePaired(results))
 	}
-	if showProgress {
-		cfg.Progress = showProgress
+	printAnswers(stats, results)
+	printExpectations(checkExpectations(stats, results))
+	if horizonRoles != nil {
+		printSplitHorizon(checkSplitHorizon(stats, results, cfg.InternalDomains, horizonRoles))
 	}
-
-	// Apply final defaults
-	if cfg.Concurrency == 0 {
-		cfg.Concurrency = 50
+	printTruncated(stats, results)
+	printTimeouts(stats, cfg.Timeout)
+	printCategories(stats)
+	if !cfg.NoDiagnose {
+		printDiagnoses(config, stats, results, warns)
 	}
-	if cfg.Iterations == 0 {
-		cfg.Iterations = 1
+	printRcodes(stats)
+	if cfg.Retries > 0 {
+		printRetries(stats, cfg.Retries)
 	}
-	if cfg.Timeout == 0 {
-		cfg.Timeout = 1 * time.Second
+	printSlowQueries(findSlowQueries(stats, results, cfg.SlowThreshold, cfg.Slowest), cfg.SlowThreshold, cfg.Slowest)
+	printSLA(stats, results, slaTargets)
+	printPopularity(stats, results, ranks)
+	weights := domainWeights(visits, ranks)
+	if cfg.StubCache > 0 {
+		printStubCache(simulateStubCache(results, cfg.StubCache, weights), cfg.StubCache)
 	}
-
-	servers := cfg.Servers
-	if len(servers) == 0 {
-		servers = defaultServers
+	if cfg.Impact != "" {
+		printImpact(estimateImpact(results, cfg.Impact, cfg.StubCache, weights), cfg.Impact, cfg.StubCache, weights != nil)
 	}
-	if cfg.ServerFile != "" {
-		var err error
-		servers, err = readServers(cfg.ServerFile)
-		if err != nil {
-			fmt.Printf("Error reading server file: %v\n", err)
-			os.Exit(1)
+	if cfg.Baseline != "" {
+		if baselines, err := readBaselines(cfg.Baseline, warns); err != nil {
+			warns.add("baseline", "failed to read baselines: %v", err)
+		} else {
+			printBaselines(checkBaselines(summarizeBaseline(results, cfg.Region, time.Now()), baselines))
 		}
 	}
-
-	// Validate servers
-	validServers, serverWarnings := validation.ValidateServers(servers)
-	if len(serverWarnings) > 0 && cfg.Verbose {
-		fmt.Println("Server validation warnings:")
-		for _, warning := range serverWarnings {
-			fmt.Printf("  - %s\n", warning)
+	if len(roles) > 0 {
+		printRoles(stats, roles, activePreset.Baseline)
+		if activePreset.summarize != nil {
+			activePreset.summarize(stats, roles)
 		}
 	}
-	if len(validServers) == 0 {
-		fmt.Println("Error: no valid servers to test")
-		os.Exit(1)
+	if applyWinner && len(resolvedLinks) > 0 {
+		applyResolvedWinner(stats, resolvedLinks)
 	}
-	servers = validServers
-
-	domains := cfg.Domains
-	if len(domains) == 0 {
-		domains = defaultDomains
+	if cfg.WireProbes {
+		printWireProbes(config)
 	}
-	if cfg.DomainFile != "" {
-		var err error
-		domains, err = readDomains(cfg.DomainFile)
-		if err != nil {
-			fmt.Printf("Error reading domain file: %v\n", err)
-			os.Exit(1)
-		}
-	} else if cfg.BrowserName != "" {
-		fmt.Printf("Extracting domains from %s history...\n", cfg.BrowserName)
-		var err error
-		domains, err = browser.GetDomains(cfg.BrowserName, 1000) // Limit to 1000 most recent/frequent
-		if err != nil {
-			if strings.Contains(err.Error(), "operation not permitted") {
-				fmt.Printf("\n⚠️  PERMISSION DENIED: macOS prevented access to %s history.\n", cfg.BrowserName)
-				fmt.Printf("To fix this:\n")
-				fmt.Printf("1. Open System Settings -> Privacy & Security -> Full Disk Access\n")
-				fmt.Printf("2. Grant access to your terminal app (e.g., Terminal, iTerm2, VSCode)\n")
-				fmt.Printf("3. Restart the terminal and try again.\n\n")
-				os.Exit(1)
-			}
-			fmt.Printf("Error extracting browser history: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Found %d unique domains from %s\n", len(domains), cfg.BrowserName)
+	if cfg.ECSProbe {
+		printECSProbe(config)
 	}
-
-	// Validate domains
-	validDomains, domainWarnings := validation.ValidateDomains(domains)
-	if len(domainWarnings) > 0 && cfg.Verbose {
-		fmt.Println("Domain validation warnings:")
-		for _, warning := range domainWarnings {
-			fmt.Printf("  - %s\n", warning)
+	if cfg.LeakTest {
+		printLeakTest(config, leakExpect)
+	}
+	if cfg.HappyEyeballs {
+		printHappyEyeballs(config)
+	}
+	if cfg.IdleProbe {
+		idles := cfg.IdlePeriods
+		if len(idles) == 0 {
+			idles = benchmark.DefaultIdlePeriods
 		}
+		printIdleProbe(config, idles)
 	}
-	if len(validDomains) == 0 {
-		fmt.Println("Error: no valid domains to test")
-		os.Exit(1)
+	if cfg.TLSProbe {
+		printTLSProbe(config)
 	}
-	domains = validDomains
-
-	fmt.Printf("Starting benchmark...\n")
-	if cfg.Duration > 0 {
-		fmt.Printf("Servers: %d, Domains: %d, Duration: %v, Concurrency: %d\n", len(servers), len(domains), cfg.Duration, cfg.Concurrency)
-	} else {
-		fmt.Printf("Servers: %d, Domains: %d, Iterations: %d, Concurrency: %d\n", len(servers), len(domains), cfg.Iterations, cfg.Concurrency)
+	if cfg.Traceroute {
+		printTraceroutes(config)
 	}
 
-	config := benchmark.Config{
-		Servers:      servers,
-		Domains:      domains,
-		Iterations:   cfg.Iterations,
-		Concurrency:  cfg.Concurrency,
-		Timeout:      cfg.Timeout,
-		Duration:     cfg.Duration,
-		Verbose:      cfg.Verbose,
-		ShowProgress: cfg.Progress,
+	if cfg.ConnsScaling {
+		runConnScaling(config)
 	}
 
-	start := time.Now()
-	results := benchmark.Run(config)
-	totalTime := time.Since(start)
+	printWarnings(warns.warnings(), cfg.Verbose)
 
-	stats := calculateStats(results)
-	printTable(stats, totalTime)
+	// Exports only see anonymized names; the console output above is local
+	prov := newProvenance(cfg, config)
+	prov.Warnings = warns.warnings()
+	prov.TotalTime = totalTime
+	if probe != nil {
+		prov.Probe, prov.Tags = probe.Name, probe.Tags
+	}
+	prov = anon.provenance(prov)
+	exported := anon.results(results)
 
 	if cfg.ExportCSV != "" {
-		if err := exportCSV(results, cfg.ExportCSV); err != nil {
+		if err := exportCSV(exported, prov, cfg.ExportCSV); err != nil {
 			fmt.Printf("Error exporting results: %v\n", err)
 		} else {
 			fmt.Printf("Results exported to %s\n", cfg.ExportCSV)
@@ -411,16 +1863,63 @@ func main() {
 	}
 
 	if cfg.ExportHTML != "" {
-		if err := generateHTML(stats, totalTime, cfg.ExportHTML); err != nil {
+		if err := generateHTML(stats, exported, totalTime, prov, cfg.ExportHTML); err != nil {
 			fmt.Printf("Error generating HTML report: %v\n", err)
 		} else {
 			fmt.Printf("HTML report generated at %s\n", cfg.ExportHTML)
 		}
 	}
+
+	if cfg.ExportJSON != "" {
+		if err := exportJSON(exported, prov, cfg.ExportJSON); err != nil {
+			fmt.Printf("Error exporting JSON: %v\n", err)
+		} else {
+			fmt.Printf("JSON exported to %s\n", cfg.ExportJSON)
+		}
+	}
+
+	if cfg.ExportCDF != "" {
+		if err := exportCDF(calculateCDFs(stats, exported), prov, cfg.ExportCDF); err != nil {
+			fmt.Printf("Error exporting CDF: %v\n", err)
+		} else {
+			fmt.Printf("CDF exported to %s\n", cfg.ExportCDF)
+		}
+	}
+
+	if cfg.DB != "" {
+		if runID, err := storeRun(cfg.DB, prov, exported, stats, totalTime); err != nil {
+			fmt.Printf("Error storing results in database: %v\n", err)
+		} else {
+			fmt.Printf("Run %s stored in %s\n", runID, cfg.DB)
+		}
+	}
+
+	if probe != nil && probe.Upload != "" {
+		if err := uploadRun(context.Background(), probe, exported, prov); err != nil {
+			fmt.Printf("Error uploading run %s: %v\n", prov.RunID, err)
+		} else {
+			fmt.Printf("Run %s uploaded as probe %s\n", prov.RunID, probe.Name)
+		}
+	}
+
+	if cfg.BaselineSummary != "" {
+		if err := writeBaselineSummary(summarizeBaseline(results, cfg.Region, time.Now()), cfg.BaselineSummary); err != nil {
+			fmt.Printf("Error writing baseline summary: %v\n", err)
+		} else {
+			fmt.Printf("Baseline summary written to %s\n", cfg.BaselineSummary)
+		}
+	}
+
+	runExporters(exps, exported, stats)
 }
 
 type ServerStats struct {
-	Server    string
+	Server string
+	// Name labels the server in the results table (see serverEntry.Name)
+	Name string
+	// Protocol is the protocol of the server's first query (see
+	// benchmark.Result.Protocol)
+	Protocol  string
 	Total     int
 	Success   int
 	Errors    int
@@ -429,38 +1928,126 @@ type ServerStats struct {
 	TotalTime time.Duration
 	Avg       time.Duration // Pre-calculated for reports
 	LossPct   float64       // Pre-calculated for reports
+	// StdDev is the standard deviation of successful query latency; Jitter
+	// the mean absolute difference between consecutive successful samples.
+	// Together they separate a consistently fast server from an erratic one
+	// with the same average.
+	StdDev time.Duration
+	Jitter time.Duration
+	// KeepaliveAdvertised is set when a DoT response carried the
+	// edns-tcp-keepalive option; KeepaliveTimeout is the last value seen.
+	KeepaliveAdvertised bool
+	KeepaliveTimeout    time.Duration
+	// Chain breaks successful queries down by CNAME chain depth
+	Chain chainStats
+	// DoH splits DoH latency into time to first byte and body read time
+	DoH dohTiming
+	// Conn splits DoT and DoH latency into connection setup and the query
+	Conn connStats
+	// Fallback tracks stub resolver emulation for plain DNS servers
+	Fallback fallbackStats
+	// SVCB summarises HTTPS/SVCB answers when querying those types
+	SVCB svcbStats
+	// Search measures the cost of search list expansion
+	Search searchStats
+	// Cache compares each domain's first lookup with repeated lookups
+	Cache cacheStats
+	// Answers tracks response section sizes and empty NOERROR answers
+	Answers answerStats
+	// Timeouts separates timed-out queries from other errors
+	Timeouts timeoutStats
+	// Retry separates first-attempt successes from retried ones
+	Retry retryStats
+	// Categories counts failures and error answers by category
+	Categories categoryStats
+	// Rcodes counts answers by response code
+	Rcodes rcodeStats
+
+	// Running mean and sum of squared deviations (Welford), and the last
+	// sample and summed differences for jitter
+	mean, m2  float64
+	prev      time.Duration
+	jitterSum time.Duration
 }
 
 func calculateStats(results []benchmark.Result) []*ServerStats {
-	statsMap := make(map[string]*ServerStats)
-
+	c := make(statsCollector)
 	for _, res := range results {
-		s, ok := statsMap[res.Server]
-		if !ok {
-			s = &ServerStats{Server: res.Server, Min: time.Hour} // Init min high
-			statsMap[res.Server] = s
+		c.add(res)
+	}
+	return c.stats()
+}
+
+// statsCollector aggregates results into per-server stats one at a time, so
+// -low-memory can compute them as results arrive instead of from all of
+// them at the end.
+type statsCollector map[string]*ServerStats
+
+// add folds one result into its server's stats.
+func (c statsCollector) add(res benchmark.Result) {
+	s, ok := c[res.Server]
+	if !ok {
+		s = &ServerStats{Server: res.Server, Protocol: cmp.Or(res.Protocol, benchmark.ServerProtocol(res.Server)), Min: time.Hour} // Init min high
+		c[res.Server] = s
+	}
+	s.Total++
+	s.Fallback.add(res)
+	s.Retry.add(res)
+	s.Categories.add(res)
+	s.Rcodes.add(res.Rcode)
+	if res.KeepaliveAdvertised {
+		s.KeepaliveAdvertised = true
+		s.KeepaliveTimeout = res.KeepaliveTimeout
+	}
+	if res.Error != nil {
+		s.Errors++
+		s.Timeouts.add(res)
+	} else {
+		s.Success++
+		s.Chain.add(res)
+		s.DoH.add(res)
+		s.Conn.add(res)
+		s.SVCB.add(res)
+		s.Search.add(res)
+		s.Cache.add(res)
+		s.Answers.add(res)
+		s.TotalTime += res.Duration
+		x := float64(res.Duration)
+		delta := x - s.mean
+		s.mean += delta / float64(s.Success)
+		s.m2 += delta * (x - s.mean)
+		if s.Success > 1 {
+			s.jitterSum += (res.Duration - s.prev).Abs()
 		}
-		s.Total++
-		if res.Error != nil {
-			s.Errors++
-		} else {
-			s.Success++
-			s.TotalTime += res.Duration
-			if res.Duration < s.Min {
-				s.Min = res.Duration
-			}
-			if res.Duration > s.Max {
-				s.Max = res.Duration
-			}
+		s.prev = res.Duration
+		if res.Duration < s.Min {
+			s.Min = res.Duration
+		}
+		if res.Duration > s.Max {
+			s.Max = res.Duration
 		}
 	}
+}
 
-	sortedStats := make([]*ServerStats, 0, len(statsMap))
-	for _, s := range statsMap {
+// stats finishes the per-server stats and sorts them, fastest first.
+func (c statsCollector) stats() []*ServerStats {
+	sortedStats := make([]*ServerStats, 0, len(c))
+	for _, s := range c {
 		if s.Success > 0 {
 			s.Avg = s.TotalTime / time.Duration(s.Success)
 		}
 		s.LossPct = float64(s.Errors) / float64(s.Total) * 100
+		if s.Success > 1 {
+			s.StdDev = time.Duration(math.Sqrt(s.m2 / float64(s.Success-1)))
+			s.Jitter = s.jitterSum / time.Duration(s.Success-1)
+		}
+		s.Chain.finish()
+		s.DoH.finish()
+		s.Conn.finish()
+		s.Fallback.finish()
+		s.Search.finish()
+		s.Cache.finish()
+		s.Answers.finish()
 		if s.Success == 0 {
 			s.Min = 0
 		}
@@ -482,16 +2069,102 @@ func calculateStats(results []benchmark.Result) []*ServerStats {
 	return sortedStats
 }
 
-func printTable(stats []*ServerStats, totalTime time.Duration) {
+func printTable(stats []*ServerStats, totalTime time.Duration, latency latencyFormat) {
 	fmt.Printf("\nBenchmark Complete in %v\n\n", totalTime)
+	printRanking(stats, latency)
+}
 
+// printRanking writes the main latency table, one row per server in rank
+// order.
+func printRanking(stats []*ServerStats, latency latencyFormat) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	if _, err := fmt.Fprintln(w, "RANK\tSERVER\tAVG LATENCY\tMIN\tMAX\tLOSS %"); err != nil {
+	header := fmt.Sprintf("RANK\tSERVER\t%s\t%s\t%s\t%s\t%s\tLOSS %%", latency.header("AVG LATENCY"), latency.header("MIN"), latency.header("MAX"),
+		latency.header("STDDEV"), latency.header("JITTER"))
+	if _, err := fmt.Fprintln(w, header); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
 	}
 
 	for i, s := range stats {
-		if _, err := fmt.Fprintf(w, "%d\t%s\t%v\t%v\t%v\t%.2f%%\n", i+1, s.Server, s.Avg, s.Min, s.Max, s.LossPct); err != nil {
+		if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%.2f%%\n", i+1, s.label(), latency.cell(s.Avg), latency.cell(s.Min), latency.cell(s.Max),
+			latency.cell(s.StdDev), latency.cell(s.Jitter), s.LossPct); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
+
+// streamResults returns an OnResult hook that writes each result to w as a
+// single JSON line.
+func streamResults(w io.Writer) func(benchmark.Result) {
+	enc := json.NewEncoder(w)
+	return func(res benchmark.Result) {
+		if err := enc.Encode(res); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to stream result: %v\n", err)
+		}
+	}
+}
+
+// fanOut returns an OnResult hook that passes each result to every sink, or
+// nil without any.
+func fanOut(sinks []func(benchmark.Result)) func(benchmark.Result) {
+	if len(sinks) == 0 {
+		return nil
+	}
+	return func(res benchmark.Result) {
+		for _, sink := range sinks {
+			sink(res)
+		}
+	}
+}
+
+// parseLogSample parses a -log-sample percentage, with or without the %
+// sign, into a fraction; empty means no sampling.
+func parseLogSample(spec string) (float64, error) {
+	if spec == "" {
+		return 0, nil
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(spec), "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, fmt.Errorf("invalid log sample %q (expected a percentage up to 100, e.g. 1%%)", spec)
+	}
+	return pct / 100, nil
+}
+
+// keepaliveVerdict describes what an advertised edns-tcp-keepalive timeout
+// means for reusing connections to that server.
+func keepaliveVerdict(s *ServerStats) string {
+	switch {
+	case !s.KeepaliveAdvertised:
+		return "not advertised (server default idle timeout applies)"
+	case s.KeepaliveTimeout == 0:
+		return "0s (server asks clients to close after each query)"
+	default:
+		return fmt.Sprintf("%v (connections can idle this long)", s.KeepaliveTimeout)
+	}
+}
+
+// printKeepalive lists the edns-tcp-keepalive timeout advertised by each DoT
+// server, which decides whether long-lived connections are viable.
+func printKeepalive(stats []*ServerStats) {
+	var dot []*ServerStats
+	for _, s := range stats {
+		if strings.HasPrefix(s.Server, "tls://") {
+			dot = append(dot, s)
+		}
+	}
+	if len(dot) == 0 {
+		return
+	}
+
+	fmt.Printf("\nEDNS TCP Keepalive (RFC 7828)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tKEEPALIVE TIMEOUT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range dot {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", s.Server, keepaliveVerdict(s)); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
 		}
 	}
@@ -502,10 +2175,14 @@ func printTable(stats []*ServerStats, totalTime time.Duration) {
 
 // ServerConfigYAML matches the expected YAML structure
 type ServerConfigYAML struct {
-	Servers []string `yaml:"servers"`
+	Servers []serverEntry `yaml:"servers"`
 }
 
-func readServers(path string) ([]string, error) {
+func readServers(path string, warns *warningLog) ([]serverEntry, error) {
+	path, err := localList(path, warns)
+	if err != nil {
+		return nil, err
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".yaml" || ext == ".yml" {
 		data, err := os.ReadFile(path)
@@ -521,21 +2198,48 @@ func readServers(path string) ([]string, error) {
 	}
 
 	// Fallback to reading lines (txt)
-	return readLines(path)
+	lines, err := readLines(path)
+	return serverEntries(lines), err
+}
+
+// checkProxy makes sure something is listening on the proxy's address, so a
+// stopped Tor client shows up as one clear warning rather than 100% loss.
+func checkProxy(proxy *url.URL, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addr := proxy.Host
+	if proxy.Port() == "" {
+		addr = net.JoinHostPort(proxy.Hostname(), "1080") // SOCKS default
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
 }
 
-func readDomains(path string) ([]string, error) {
+// readDomains reads a domain list. CSV files that carry a popularity rank,
+// either in a "rank" column or as Tranco's headerless "rank,domain" rows,
+// also return each domain's rank. Like readServers, it also takes an
+// http(s) URL, fetched through the list cache.
+func readDomains(path string, warns *warningLog) ([]string, map[string]int, error) {
+	path, err := localList(path, warns)
+	if err != nil {
+		return nil, nil, err
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".csv" {
 		return readCSV(path)
 	}
-	return readLines(path)
+	domains, err := readLines(path)
+	return domains, nil, err
 }
 
-func readCSV(path string) ([]string, error) {
+func readCSV(path string) ([]string, map[string]int, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
@@ -544,24 +2248,37 @@ func readCSV(path string) ([]string, error) {
 	}()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var domains []string
 	if len(records) == 0 {
-		return domains, nil
+		return domains, nil, nil
 	}
 
 	colIdx := 0
+	rankIdx := -1
 	// Check for header
 	hasHeader := false
 	for i, field := range records[0] {
-		if strings.ToLower(strings.TrimSpace(field)) == "domain" {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "domain":
 			colIdx = i
 			hasHeader = true
-			break
+		case "rank":
+			rankIdx = i
+		}
+	}
+	if !hasHeader {
+		rankIdx = -1
+		// Tranco lists are "rank,domain" without a header
+		if len(records[0]) >= 2 {
+			if _, err := strconv.Atoi(strings.TrimSpace(records[0][0])); err == nil {
+				colIdx, rankIdx = 1, 0
+			}
 		}
 	}
 
@@ -570,16 +2287,25 @@ func readCSV(path string) ([]string, error) {
 		startRow = 1
 	}
 
+	var ranks map[string]int
+	if rankIdx >= 0 {
+		ranks = make(map[string]int)
+	}
 	for i := startRow; i < len(records); i++ {
 		record := records[i]
 		if len(record) > colIdx {
 			domain := strings.TrimSpace(record[colIdx])
 			if domain != "" {
 				domains = append(domains, domain)
+				if rankIdx >= 0 && len(record) > rankIdx {
+					if rank, err := strconv.Atoi(strings.TrimSpace(record[rankIdx])); err == nil {
+						ranks[strings.ToLower(domain)] = rank
+					}
+				}
 			}
 		}
 	}
-	return domains, nil
+	return domains, ranks, nil
 }
 
 func readLines(path string) ([]string, error) {
@@ -604,7 +2330,10 @@ func readLines(path string) ([]string, error) {
 	return lines, scanner.Err()
 }
 
-func exportCSV(results []benchmark.Result, path string) error {
+// exportCSV writes raw results to path. When prov is set, the run's
+// configuration is embedded above the header as "#" comment lines, and its
+// unit and precision decide how latencies are written.
+func exportCSV(results []benchmark.Result, prov *provenance, path string) error {
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -615,11 +2344,25 @@ func exportCSV(results []benchmark.Result, path string) error {
 		}
 	}()
 
+	var latency latencyFormat
+	if prov != nil {
+		latency = configLatencyFormat(prov.Config)
+		for _, line := range prov.commentLines() {
+			if _, err := fmt.Fprintln(file, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := fmt.Fprintf(file, "%s%d\n", csvSchemaPrefix, schemaVersion); err != nil {
+		return err
+	}
+
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
 	// Header
-	if err := writer.Write([]string{"Server", "Domain", "Duration_ms", "Error"}); err != nil {
+	if err := writer.Write(csvHeaderFor(latency)); err != nil {
 		return err
 	}
 
@@ -628,11 +2371,21 @@ func exportCSV(results []benchmark.Result, path string) error {
 		if res.Error != nil {
 			errStr = res.Error.Error()
 		}
+		timestamp := ""
+		if !res.Time.IsZero() {
+			timestamp = res.Time.Format(time.RFC3339Nano)
+		}
 		record := []string{
 			res.Server,
 			res.Domain,
-			strconv.FormatFloat(float64(res.Duration.Microseconds())/1000.0, 'f', 4, 64),
+			latency.exportNumber(res.Duration),
 			errStr,
+			res.Network,
+			res.Protocol,
+			res.Category,
+			timestamp,
+			latency.exportNumber(res.Offset),
+			res.Rcode,
 		}
 		if err := writer.Write(record); err != nil {
 			return err
@@ -659,6 +2412,13 @@ const htmlReportTemplate = `
 		.good { color: green; font-weight: bold; }
 		.bad { color: red; font-weight: bold; }
 		.rank { font-weight: bold; color: #555; }
+		footer { margin-top: 2rem; font-size: 0.85em; color: #666; }
+		footer pre { background: #f4f4f9; padding: 1rem; border-radius: 4px; overflow-x: auto; }
+		.legend { margin: 1rem 0; }
+		.legend button { border: 1px solid #ddd; background: white; border-radius: 4px; padding: 4px 8px; margin: 2px; cursor: pointer; font-size: 0.85em; }
+		.legend button.off { opacity: 0.4; text-decoration: line-through; }
+		.swatch { display: inline-block; width: 10px; height: 10px; border-radius: 2px; margin-right: 6px; }
+		.series.hidden { display: none; }
 	</style>
 </head>
 <body>
@@ -666,7 +2426,8 @@ const htmlReportTemplate = `
 		<h1>DNS Benchmark Results</h1>
 		<div class="summary">
 			<strong>Total Duration:</strong> {{.TotalTime}}<br>
-			<strong>Servers Tested:</strong> {{.ServerCount}}
+			<strong>Servers Tested:</strong> {{.ServerCount}}<br>
+			<strong>Network:</strong> {{.Provenance.Network}}
 		</div>
 
 		<table>
@@ -674,9 +2435,12 @@ const htmlReportTemplate = `
 				<tr>
 					<th>Rank</th>
 					<th>Server</th>
-					<th>Avg Latency</th>
-					<th>Min</th>
-					<th>Max</th>
+					<th>Protocol</th>
+					<th>{{latencyHeader "Avg Latency"}}</th>
+					<th>{{latencyHeader "Min"}}</th>
+					<th>{{latencyHeader "Max"}}</th>
+					<th>{{latencyHeader "Std Dev"}}</th>
+					<th>{{latencyHeader "Jitter"}}</th>
 					<th>Loss %</th>
 				</tr>
 			</thead>
@@ -685,22 +2449,177 @@ const htmlReportTemplate = `
 				<tr>
 					<td class="rank">{{add $i 1}}</td>
 					<td>{{$s.Server}}</td>
-					<td>{{$s.Avg}}</td>
-					<td>{{$s.Min}}</td>
-					<td>{{$s.Max}}</td>
+					<td>{{$s.Protocol}}</td>
+					<td>{{latency $s.Avg}}</td>
+					<td>{{latency $s.Min}}</td>
+					<td>{{latency $s.Max}}</td>
+					<td>{{latency $s.StdDev}}</td>
+					<td>{{latency $s.Jitter}}</td>
 					<td class="{{if gt $s.LossPct 5.0}}bad{{else}}good{{end}}">{{printf "%.2f" $s.LossPct}}%</td>
 				</tr>
 				{{end}}
 			</tbody>
 		</table>
+
+		<h2>Latency Charts</h2>
+		<p>Hover over a point or bar for its value; click a server to hide or show it.</p>
+		<div class="legend">
+			{{range $i, $s := .Stats}}<button type="button" data-series="{{$i}}"><span class="swatch" style="background: {{color $i}}"></span>{{$s.Server}}</button>{{end}}
+		</div>
+		<h3>Latency Distribution</h3>
+		{{.Histogram}}
+		<h3>Average and p95 Latency</h3>
+		{{.Bars}}
+		<script>
+		document.querySelectorAll('.legend button').forEach(function (button) {
+			button.addEventListener('click', function () {
+				button.classList.toggle('off');
+				document.querySelectorAll('.series[data-series="' + button.dataset.series + '"]').forEach(function (g) {
+					g.classList.toggle('hidden');
+				});
+			});
+		});
+		</script>
+
+		{{if .HasDoT}}
+		<h2>EDNS TCP Keepalive</h2>
+		<table>
+			<thead>
+				<tr>
+					<th>Server</th>
+					<th>Advertised Timeout</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range .Stats}}{{if isDoT .Server}}
+				<tr>
+					<td>{{.Server}}</td>
+					<td>{{keepalive .}}</td>
+				</tr>
+				{{end}}{{end}}
+			</tbody>
+		</table>
+		{{end}}
+
+		{{if .HasChains}}
+		<h2>CNAME Chain Cost</h2>
+		<table>
+			<thead>
+				<tr>
+					<th>Server</th>
+					<th>{{latencyHeader "Depth 0"}}</th>
+					<th>{{latencyHeader "Depth 1"}}</th>
+					<th>{{latencyHeader "Depth 2"}}</th>
+					<th>{{latencyHeader "Depth 3+"}}</th>
+					<th>Correlation</th>
+					<th>Unfollowed</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range $s := .Stats}}
+				<tr>
+					<td>{{$s.Server}}</td>
+					{{range $b, $n := $s.Chain.Count}}<td>{{if $n}}{{latency (index $s.Chain.Avg $b)}} ({{$n}}){{else}}-{{end}}</td>{{end}}
+					<td>{{printf "%+.2f" $s.Chain.Corr}}</td>
+					<td class="{{if $s.Chain.Unfollowed}}bad{{else}}good{{end}}">{{$s.Chain.Unfollowed}}</td>
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+		{{end}}
+
+		{{if .Categories}}
+		<h2>Failures by Category</h2>
+		<p>Lookups that failed and answers with an error code; NXDOMAIN answers count as answered.</p>
+		<table>
+			<thead>
+				<tr>
+					<th>Server</th>
+					{{range .Categories}}<th>{{.}}</th>{{end}}
+				</tr>
+			</thead>
+			<tbody>
+				{{range $s := .Stats}}
+				<tr>
+					<td>{{$s.Server}}</td>
+					{{range $.Categories}}<td>{{$s.Categories.Count .}}</td>{{end}}
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+		{{end}}
+
+		{{if .Slow}}
+		<h2>Slow Queries</h2>
+		<p>Answered queries over {{.SlowThreshold}}, slowest {{.Slowest}} per server.</p>
+		<table>
+			<thead>
+				<tr>
+					<th>Server</th>
+					<th>Slow</th>
+					<th>Domain</th>
+					<th>{{latencyHeader "RTT"}}</th>
+					<th>RCODE</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range $sq := .Slow}}{{range $i, $r := $sq.Slowest}}
+				<tr>
+					<td>{{if eq $i 0}}{{$sq.Server}}{{end}}</td>
+					<td>{{if eq $i 0}}{{$sq.Count}}/{{$sq.Answered}}{{end}}</td>
+					<td>{{$r.Domain}}</td>
+					<td>{{latency $r.Duration}}</td>
+					<td>{{orNone $r.Rcode}}</td>
+				</tr>
+				{{end}}{{end}}
+			</tbody>
+		</table>
+		{{end}}
+
+		{{if .Provenance.Warnings}}
+		<h2>Warnings</h2>
+		<table>
+			<thead>
+				<tr>
+					<th>Source</th>
+					<th>Warning</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range .Provenance.Warnings}}
+				<tr>
+					<td>{{.Source}}</td>
+					<td>{{.Message}}</td>
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+		{{end}}
+
+		<footer>
+			<details>
+				<summary>Run configuration (dns-bench {{.Provenance.Version}}, {{.Provenance.GeneratedAt.Format "2006-01-02 15:04:05 UTC"}})</summary>
+				<pre>{{.Provenance.YAML}}</pre>
+			</details>
+		</footer>
 	</div>
 </body>
 </html>
 `
 
-func generateHTML(stats []*ServerStats, totalTime time.Duration, path string) error {
+// generateHTML writes the HTML report: the ranking, latency charts drawn
+// from the raw results, and sections for the features that were used.
+func generateHTML(stats []*ServerStats, results []benchmark.Result, totalTime time.Duration, prov *provenance, path string) error {
+	isDoT := func(server string) bool { return strings.HasPrefix(server, "tls://") }
+	latency := configLatencyFormat(prov.Config)
 	funcMap := template.FuncMap{
-		"add": func(i, j int) int { return i + j },
+		"add":           func(i, j int) int { return i + j },
+		"isDoT":         isDoT,
+		"keepalive":     keepaliveVerdict,
+		"color":         func(i int) string { return chartColors[i%len(chartColors)] },
+		"orNone":        orNone,
+		"latency":       latency.cell,
+		"latencyHeader": latency.header,
 	}
 
 	tmpl, err := template.New("report").Funcs(funcMap).Parse(htmlReportTemplate)
@@ -718,14 +2637,44 @@ func generateHTML(stats []*ServerStats, totalTime time.Duration, path string) er
 		}
 	}()
 
+	hasDoT := false
+	for _, s := range stats {
+		if isDoT(s.Server) {
+			hasDoT = true
+		}
+	}
+
+	durations := serverDurations(results)
+	slowThreshold := cmp.Or(prov.Config.SlowThreshold, benchmark.DefaultSlowThreshold)
+	slowest := cmp.Or(prov.Config.Slowest, defaultSlowest)
 	data := struct {
-		Stats       []*ServerStats
-		TotalTime   time.Duration
-		ServerCount int
+		Stats         []*ServerStats
+		TotalTime     time.Duration
+		ServerCount   int
+		Provenance    *provenance
+		HasDoT        bool
+		HasChains     bool
+		Histogram     template.HTML
+		Bars          template.HTML
+		Slow          []slowQueries
+		SlowThreshold time.Duration
+		Slowest       int
+		Categories    []string
 	}{
 		Stats:       stats,
 		TotalTime:   totalTime,
 		ServerCount: len(stats),
+		Provenance:  prov,
+		HasDoT:      hasDoT,
+		HasChains:   hasChains(stats),
+		//nolint:gosec // G203: the charts are built from escaped values only
+		Histogram: template.HTML(latencyHistogramChart(stats, durations)),
+		//nolint:gosec // G203: the charts are built from escaped values only
+		Bars:          template.HTML(latencyBarsChart(stats, durations)),
+		Slow:          findSlowQueries(stats, results, slowThreshold, slowest),
+		SlowThreshold: slowThreshold,
+		Slowest:       slowest,
+		Categories:    usedCategories(stats),
 	}
 
 	return tmpl.Execute(file, data)
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -40,6 +43,9 @@ func TestCalculateStats(t *testing.T) {
 	if googleStats.Total != 3 {
 		t.Errorf("Expected 3 total queries for 8.8.8.8, got %d", googleStats.Total)
 	}
+	if googleStats.Protocol != "udp" {
+		t.Errorf("Expected protocol udp for 8.8.8.8, got %q", googleStats.Protocol)
+	}
 
 	if googleStats.Success != 2 {
 		t.Errorf("Expected 2 successful queries, got %d", googleStats.Success)
@@ -68,6 +74,35 @@ func TestCalculateStats(t *testing.T) {
 	}
 }
 
+func TestCalculateStatsSpread(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Duration: 10 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: 20 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: time.Second, Error: os.ErrDeadlineExceeded}, // Skipped
+		{Server: "8.8.8.8", Duration: 30 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: 20 * time.Millisecond},
+		{Server: "1.1.1.1", Duration: 5 * time.Millisecond},
+	}
+
+	stats := calculateStats(results)
+	var s *ServerStats
+	for _, st := range stats {
+		if st.Server == "8.8.8.8" {
+			s = st
+		}
+	}
+	// Sample variance (100+0+100+0)/3 ms², so stddev ≈ 8.165ms
+	if want := 8165 * time.Microsecond; (s.StdDev - want).Abs() > time.Microsecond {
+		t.Errorf("expected stddev ~%v, got %v", want, s.StdDev)
+	}
+	if s.Jitter != 10*time.Millisecond {
+		t.Errorf("expected jitter 10ms, got %v", s.Jitter)
+	}
+	if stats[0].StdDev != 0 || stats[0].Jitter != 0 {
+		t.Errorf("expected no spread from a single sample, got %v/%v", stats[0].StdDev, stats[0].Jitter)
+	}
+}
+
 func TestCalculateStatsAllErrors(t *testing.T) {
 	results := []benchmark.Result{
 		{Server: "bad.server", Domain: "google.com", Duration: 0, Error: os.ErrNotExist},
@@ -142,7 +177,7 @@ func TestReadCSV(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	domains, err := readCSV(tmpfile.Name())
+	domains, ranks, err := readCSV(tmpfile.Name())
 	if err != nil {
 		t.Fatalf("readCSV failed: %v", err)
 	}
@@ -154,6 +189,24 @@ func TestReadCSV(t *testing.T) {
 	if domains[0] != "google.com" {
 		t.Errorf("Expected first domain to be google.com, got %s", domains[0])
 	}
+	if ranks["yahoo.com"] != 2 {
+		t.Errorf("Expected yahoo.com to be ranked 2, got %v", ranks)
+	}
+}
+
+func TestReadCSVTranco(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tranco.csv")
+	if err := os.WriteFile(path, []byte("1,google.com\n2,Facebook.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	domains, ranks, err := readCSV(path)
+	if err != nil {
+		t.Fatalf("readCSV failed: %v", err)
+	}
+	if len(domains) != 2 || domains[0] != "google.com" || ranks["facebook.com"] != 2 {
+		t.Errorf("Expected ranked Tranco domains, got %v %v", domains, ranks)
+	}
 }
 
 func TestReadCSVNoHeader(t *testing.T) {
@@ -171,7 +224,7 @@ func TestReadCSVNoHeader(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	domains, err := readCSV(tmpfile.Name())
+	domains, ranks, err := readCSV(tmpfile.Name())
 	if err != nil {
 		t.Fatalf("readCSV failed: %v", err)
 	}
@@ -179,6 +232,9 @@ func TestReadCSVNoHeader(t *testing.T) {
 	if len(domains) != 2 {
 		t.Errorf("Expected 2 domains, got %d", len(domains))
 	}
+	if ranks != nil {
+		t.Errorf("Expected no ranks without a rank column, got %v", ranks)
+	}
 }
 
 func TestReadServersYAML(t *testing.T) {
@@ -200,7 +256,7 @@ func TestReadServersYAML(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	servers, err := readServers(tmpfile.Name())
+	servers, err := readServers(tmpfile.Name(), nil)
 	if err != nil {
 		t.Fatalf("readServers failed: %v", err)
 	}
@@ -209,8 +265,8 @@ func TestReadServersYAML(t *testing.T) {
 		t.Errorf("Expected 3 servers, got %d: %v", len(servers), servers)
 	}
 
-	if servers[0] != "8.8.8.8" {
-		t.Errorf("Expected first server to be 8.8.8.8, got %s", servers[0])
+	if servers[0].Address != "8.8.8.8" {
+		t.Errorf("Expected first server to be 8.8.8.8, got %s", servers[0].Address)
 	}
 }
 
@@ -229,7 +285,7 @@ func TestReadServersTXT(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	servers, err := readServers(tmpfile.Name())
+	servers, err := readServers(tmpfile.Name(), nil)
 	if err != nil {
 		t.Fatalf("readServers failed: %v", err)
 	}
@@ -241,14 +297,15 @@ func TestReadServersTXT(t *testing.T) {
 
 func TestExportCSV(t *testing.T) {
 	results := []benchmark.Result{
-		{Server: "8.8.8.8", Domain: "google.com", Duration: 10 * time.Millisecond, Error: nil},
-		{Server: "8.8.8.8", Domain: "yahoo.com", Duration: 20 * time.Millisecond, Error: nil},
+		{Server: "8.8.8.8", Domain: "google.com", Duration: 10 * time.Millisecond, Error: nil, Network: "office-lan", Protocol: "udp",
+			Time: time.Date(2026, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600)), Offset: 1500 * time.Microsecond},
+		{Server: "8.8.8.8", Domain: "yahoo.com", Duration: 20 * time.Millisecond, Error: nil, Network: "office-lan", Protocol: "udp", Rcode: "NOERROR"},
 	}
 
 	tmpfile := filepath.Join(os.TempDir(), "test-export.csv")
 	defer os.Remove(tmpfile)
 
-	err := exportCSV(results, tmpfile)
+	err := exportCSV(results, nil, tmpfile)
 	if err != nil {
 		t.Fatalf("exportCSV failed: %v", err)
 	}
@@ -269,6 +326,12 @@ func TestExportCSV(t *testing.T) {
 	if !strings.Contains(contentStr, "google.com") {
 		t.Error("Expected CSV to contain domain 'google.com'")
 	}
+	if !strings.Contains(contentStr, ",Network,Protocol,Category,Time,Offset_ms,Rcode\n") || !strings.Contains(contentStr, ",office-lan,udp,,,0.0000,NOERROR\n") {
+		t.Error("Expected CSV to contain Network, Protocol, Category, Time, Offset_ms and Rcode columns")
+	}
+	if !strings.Contains(contentStr, ",office-lan,udp,,2026-03-01T09:30:00+01:00,1.5000,\n") {
+		t.Error("Expected CSV to contain the RFC3339 time with its zone and the run offset")
+	}
 }
 
 func TestGenerateHTML(t *testing.T) {
@@ -288,7 +351,13 @@ func TestGenerateHTML(t *testing.T) {
 	tmpfile := filepath.Join(os.TempDir(), "test-report.html")
 	defer os.Remove(tmpfile)
 
-	err := generateHTML(stats, 5*time.Second, tmpfile)
+	prov := &provenance{Version: "v1.2.3", Network: "eth0 via 192.168.1.1", Config: Config{Concurrency: 50}}
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "google.com", Duration: 5 * time.Millisecond},
+		{Server: "8.8.8.8", Domain: "github.com", Duration: 50 * time.Millisecond},
+		{Server: "8.8.8.8", Domain: "slow.example", Duration: 800 * time.Millisecond, Rcode: "NOERROR"},
+	}
+	err := generateHTML(stats, results, 5*time.Second, prov, tmpfile)
 	if err != nil {
 		t.Fatalf("generateHTML failed: %v", err)
 	}
@@ -306,9 +375,24 @@ func TestGenerateHTML(t *testing.T) {
 	if !strings.Contains(contentStr, "8.8.8.8") {
 		t.Error("Expected HTML to contain server '8.8.8.8'")
 	}
+	if !strings.Contains(contentStr, "eth0 via 192.168.1.1") {
+		t.Error("Expected HTML to contain network label")
+	}
+	if !strings.Contains(contentStr, "dns-bench v1.2.3") || !strings.Contains(contentStr, "concurrency: 50") {
+		t.Error("Expected HTML footer to contain the run configuration")
+	}
 	if !strings.Contains(contentStr, "DNS Benchmark") {
 		t.Error("Expected HTML to contain title")
 	}
+	if !strings.Contains(contentStr, "Slow Queries") || !strings.Contains(contentStr, "slow.example") {
+		t.Error("Expected HTML to list the slow query")
+	}
+	if strings.Count(contentStr, "<svg") != 2 || !strings.Contains(contentStr, `data-series="0"`) {
+		t.Error("Expected HTML to contain the latency charts")
+	}
+	if strings.Contains(contentStr, "https://") {
+		t.Error("Expected HTML to be self-contained")
+	}
 }
 
 func TestLoadConfigFile(t *testing.T) {
@@ -369,6 +453,95 @@ func TestLoadConfigFileInvalid(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFileInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"shared/base.yaml": `
+servers: [8.8.8.8, 1.1.1.1]
+domains: [google.com]
+concurrency: 20
+timeout: 2s
+retention:
+  keep_runs: 100
+  keep_days: 30
+server_qps:
+  8.8.8.8: 5
+  1.1.1.1: 10
+leak_expect:
+  8.8.8.8: [Google]
+`,
+		"shared/outputs.yaml": `
+extends: base.yaml
+export_csv: shared.csv
+concurrency: 30
+`,
+		"local.yaml": `
+include:
+  - shared/outputs.yaml
+domains: [github.com, example.com]
+export_csv: local.csv
+retention:
+  keep_days: 7
+server_qps:
+  1.1.1.1: 2
+leak_expect:
+  8.8.8.8: [Google LLC]
+  1.1.1.1: [Cloudflare]
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := loadConfigFile(filepath.Join(tmpDir, "local.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if len(cfg.Servers) != 2 || cfg.Timeout != 2*time.Second {
+		t.Errorf("base settings not inherited: servers %v, timeout %v", cfg.Servers, cfg.Timeout)
+	}
+	if cfg.Concurrency != 30 {
+		t.Errorf("Expected concurrency 30 from the intermediate file, got %d", cfg.Concurrency)
+	}
+	if len(cfg.Domains) != 2 || cfg.Domains[0] != "github.com" {
+		t.Errorf("Expected local domains to replace the base list, got %v", cfg.Domains)
+	}
+	if cfg.ExportCSV != "local.csv" {
+		t.Errorf("Expected local export_csv, got %q", cfg.ExportCSV)
+	}
+	if cfg.Retention.KeepRuns != 100 || cfg.Retention.KeepDays != 7 {
+		t.Errorf("Expected retention to merge key by key, got %+v", cfg.Retention)
+	}
+	if len(cfg.ServerQPS) != 2 || cfg.ServerQPS["8.8.8.8"] != 5 || cfg.ServerQPS["1.1.1.1"] != 2 {
+		t.Errorf("Expected server_qps to merge key by key, got %v", cfg.ServerQPS)
+	}
+	if len(cfg.LeakExpect) != 2 || !slices.Equal(cfg.LeakExpect["8.8.8.8"], []string{"Google LLC"}) {
+		t.Errorf("Expected leak_expect to merge, each key's list replaced, got %v", cfg.LeakExpect)
+	}
+}
+
+func TestLoadConfigFileIncludeCycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.yaml")
+	b := filepath.Join(tmpDir, "b.yaml")
+	if err := os.WriteFile(a, []byte("include: b.yaml\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("include: a.yaml\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := loadConfigFile(a)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+}
+
 func TestLoadConfigFileNotFound(t *testing.T) {
 	_, err := loadConfigFile("/nonexistent/config.yaml")
 	if err == nil {
@@ -421,7 +594,7 @@ func TestReadDomainsCSV(t *testing.T) {
 		t.Fatalf("Failed to create CSV file: %v", err)
 	}
 
-	domains, err := readDomains(csvFile)
+	domains, _, err := readDomains(csvFile, nil)
 	if err != nil {
 		t.Fatalf("readDomains failed: %v", err)
 	}
@@ -440,7 +613,7 @@ func TestReadDomainsTXT(t *testing.T) {
 		t.Fatalf("Failed to create TXT file: %v", err)
 	}
 
-	domains, err := readDomains(txtFile)
+	domains, _, err := readDomains(txtFile, nil)
 	if err != nil {
 		t.Fatalf("readDomains failed: %v", err)
 	}
@@ -466,7 +639,7 @@ func TestPrintTable(_ *testing.T) {
 	}
 
 	// Should not panic
-	printTable(stats, 5*time.Second)
+	printTable(stats, 5*time.Second, latencyFormat{})
 }
 
 func TestReadServersInvalidYAML(t *testing.T) {
@@ -479,8 +652,203 @@ func TestReadServersInvalidYAML(t *testing.T) {
 		t.Fatalf("Failed to create YAML file: %v", err)
 	}
 
-	_, err := readServers(yamlFile)
+	_, err := readServers(yamlFile, nil)
 	if err == nil {
 		t.Error("Expected error for invalid YAML")
 	}
 }
+
+func TestScalingLevels(t *testing.T) {
+	tests := []struct {
+		max  int
+		want []int
+	}{
+		{1, []int{1}},
+		{4, []int{1, 2, 4}},
+		{6, []int{1, 2, 4, 6}},
+	}
+	for _, tt := range tests {
+		got := scalingLevels(tt.max)
+		if len(got) != len(tt.want) {
+			t.Errorf("scalingLevels(%d) = %v, want %v", tt.max, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("scalingLevels(%d) = %v, want %v", tt.max, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEncryptedServers(t *testing.T) {
+	got := encryptedServers([]string{"8.8.8.8", "tls://1.1.1.1", "https://dns.google/dns-query"})
+	if len(got) != 2 || got[0] != "tls://1.1.1.1" || got[1] != "https://dns.google/dns-query" {
+		t.Errorf("unexpected encrypted servers: %v", got)
+	}
+}
+
+func TestCalculateStatsKeepalive(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "tls://1.1.1.1", Domain: "google.com", Duration: 10 * time.Millisecond},
+		{Server: "tls://1.1.1.1", Domain: "yahoo.com", Duration: 12 * time.Millisecond, KeepaliveAdvertised: true, KeepaliveTimeout: 10 * time.Second},
+		{Server: "tls://9.9.9.9", Domain: "google.com", Duration: 15 * time.Millisecond},
+	}
+
+	stats := calculateStats(results)
+	for _, s := range stats {
+		switch s.Server {
+		case "tls://1.1.1.1":
+			if !s.KeepaliveAdvertised || s.KeepaliveTimeout != 10*time.Second {
+				t.Errorf("expected 10s advertised keepalive, got %v %v", s.KeepaliveAdvertised, s.KeepaliveTimeout)
+			}
+			if !strings.HasPrefix(keepaliveVerdict(s), "10s") {
+				t.Errorf("unexpected verdict: %s", keepaliveVerdict(s))
+			}
+		case "tls://9.9.9.9":
+			if s.KeepaliveAdvertised {
+				t.Error("expected no keepalive for 9.9.9.9")
+			}
+			if !strings.HasPrefix(keepaliveVerdict(s), "not advertised") {
+				t.Errorf("unexpected verdict: %s", keepaliveVerdict(s))
+			}
+		}
+	}
+}
+
+func TestStreamResults(t *testing.T) {
+	var buf strings.Builder
+	onResult := streamResults(&buf)
+	onResult(benchmark.Result{Server: "8.8.8.8", Domain: "google.com", Duration: 10 * time.Millisecond})
+	onResult(benchmark.Result{Server: "1.1.1.1", Domain: "google.com", Error: os.ErrDeadlineExceeded})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %d: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"server":"8.8.8.8"`) || !strings.Contains(lines[0], `"duration_ms":10`) {
+		t.Errorf("unexpected first line: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"error":`) {
+		t.Errorf("expected error in second line: %s", lines[1])
+	}
+}
+
+func TestStreamResultsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	onResult := streamResults(f)
+	onResult(benchmark.Result{Server: "8.8.8.8", Domain: "google.com", Duration: 10 * time.Millisecond})
+
+	// The line must be on disk before the file is closed.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res benchmark.Result
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", data, err)
+	}
+	if res.Server != "8.8.8.8" || res.Duration != 10*time.Millisecond {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestCalculateStatsDoHTiming(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "https://dns.google/dns-query", Domain: "a.com", Duration: 30 * time.Millisecond, TTFB: 20 * time.Millisecond},
+		{Server: "https://dns.google/dns-query", Domain: "b.com", Duration: 50 * time.Millisecond, TTFB: 20 * time.Millisecond},
+		{Server: "8.8.8.8", Domain: "a.com", Duration: 10 * time.Millisecond},
+	}
+
+	for _, s := range calculateStats(results) {
+		switch s.Server {
+		case "8.8.8.8":
+			if s.DoH.Count != 0 {
+				t.Errorf("expected no DoH timing for UDP server, got %d", s.DoH.Count)
+			}
+		default:
+			if s.DoH.AvgTTFB != 20*time.Millisecond || s.DoH.AvgBody != 20*time.Millisecond {
+				t.Errorf("expected 20ms TTFB and 20ms body, got %v and %v", s.DoH.AvgTTFB, s.DoH.AvgBody)
+			}
+			if share := s.DoH.bodyShare(); share != 50 {
+				t.Errorf("expected 50%% body share, got %.1f", share)
+			}
+		}
+	}
+}
+
+func TestCalculateStatsFallback(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Domain: "a.com", Duration: 10 * time.Millisecond, Transport: "udp"},
+		{Server: "8.8.8.8", Domain: "b.com", Duration: 30 * time.Millisecond, Transport: "tcp", Fallbacks: 1},
+		{Server: "8.8.8.8", Domain: "c.com", Duration: time.Second, Transport: "tls", Fallbacks: 2, Error: errors.New("timeout")},
+		{Server: "8.8.8.8", Domain: "d.com", Duration: 12 * time.Millisecond, Transport: "udp"},
+	}
+
+	s := calculateStats(results)[0]
+	if s.Fallback.Queries != 4 || s.Fallback.FellBack != 2 {
+		t.Fatalf("expected 2 of 4 queries to fall back, got %d of %d", s.Fallback.FellBack, s.Fallback.Queries)
+	}
+	if rate := s.Fallback.rate(); rate != 50 {
+		t.Errorf("expected 50%% fallback rate, got %.1f", rate)
+	}
+	if s.Fallback.Via["udp"] != 2 || s.Fallback.Via["tcp"] != 1 || s.Fallback.Via["tls"] != 0 {
+		t.Errorf("unexpected per-transport answers: %v", s.Fallback.Via)
+	}
+	if s.Fallback.AvgDirect != 11*time.Millisecond || s.Fallback.AvgFallback != 30*time.Millisecond {
+		t.Errorf("expected 11ms direct and 30ms with fallback, got %v and %v", s.Fallback.AvgDirect, s.Fallback.AvgFallback)
+	}
+}
+
+func TestResolveSearch(t *testing.T) {
+	domains, ndots, err := resolveSearch(splitList("svc.cluster.local, cluster.local,"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 || domains[1] != "cluster.local" || ndots != defaultNdots {
+		t.Errorf("expected 2 domains and default ndots, got %v ndots:%d", domains, ndots)
+	}
+
+	five := 5
+	if _, ndots, _ := resolveSearch(domains, &five); ndots != 5 {
+		t.Errorf("expected explicit ndots to win, got %d", ndots)
+	}
+}
+
+func TestCalculateStatsSearch(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "10.96.0.10", Domain: "web", Duration: 8 * time.Millisecond, SearchQueries: 2, SearchOverhead: 4 * time.Millisecond},
+		{Server: "10.96.0.10", Domain: "example.com", Duration: 20 * time.Millisecond, SearchQueries: 4, SearchOverhead: 12 * time.Millisecond},
+	}
+
+	s := calculateStats(results)[0].Search
+	if s.Lookups != 2 || s.Expanded != 2 || s.queriesPerLookup() != 3 {
+		t.Errorf("expected 2 expanded lookups averaging 3 queries, got %+v", s)
+	}
+	if s.AvgOverhead != 8*time.Millisecond || s.AvgTotal != 14*time.Millisecond {
+		t.Errorf("expected 8ms wasted of 14ms, got %v of %v", s.AvgOverhead, s.AvgTotal)
+	}
+	if share := s.overheadShare(); share < 57 || share > 58 {
+		t.Errorf("expected ~57%% of latency wasted, got %.1f", share)
+	}
+}
+
+func TestParseLogSample(t *testing.T) {
+	for spec, want := range map[string]float64{"": 0, "1%": 0.01, "0.5": 0.005, "100%": 1} {
+		if got, err := parseLogSample(spec); err != nil || got != want {
+			t.Errorf("%q: expected %v, got %v (%v)", spec, want, got, err)
+		}
+	}
+	for _, spec := range []string{"0", "150%", "lots"} {
+		if _, err := parseLogSample(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
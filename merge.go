@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// runSummary is the part of a provenance snapshot the merge report shows.
+type runSummary struct {
+	RunID       string `json:"run_id"`
+	Host        string `json:"host"`
+	Network     string `json:"network"`
+	GeneratedAt string `json:"generated_at"`
+}
+
+// runMerge implements the merge subcommand, which combines JSON exports
+// from several machines or runs into one file and reports on the result.
+// Runs that appear in more than one input are only included once.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	var (
+		output     string
+		perNetwork bool
+	)
+	fs.StringVar(&output, "o", "", "Output JSON file for the merged results (required)")
+	fs.BoolVar(&perNetwork, "per-network", false, "Rank each server separately per network instead of pooling all results")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s merge [options] -o combined.json <export.json>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected -o and at least one JSON export")
+	}
+
+	var (
+		runs    []json.RawMessage
+		results []benchmark.Result
+		skipped int
+	)
+	seen := make(map[string]bool)
+	for _, path := range fs.Args() {
+		fileRuns, fileResults, err := readMergeInput(path)
+		if err != nil {
+			return err
+		}
+		added := make(map[string]bool)
+		for _, run := range fileRuns {
+			id := runID(run)
+			if seen[id] {
+				skipped++
+				continue
+			}
+			seen[id] = true
+			added[id] = true
+			runs = append(runs, run)
+		}
+		for _, res := range fileResults {
+			if added[res.RunID] {
+				results = append(results, res)
+			}
+		}
+	}
+
+	if err := writeJSONExport(jsonExport{SchemaVersion: schemaVersion, Runs: runs, Results: results}, output); err != nil {
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+
+	fmt.Printf("Merged %d run(s) with %d result(s) from %d file(s)", len(runs), len(results), fs.NArg())
+	if skipped > 0 {
+		fmt.Printf(", skipping %d duplicate run(s)", skipped)
+	}
+	fmt.Printf("\n\n")
+	printRuns(runs, results)
+
+	if perNetwork {
+		results = labelByNetwork(results)
+	}
+	fmt.Println()
+	printRanking(calculateStats(results), latencyFormat{})
+	fmt.Printf("\nMerged results written to %s\n", output)
+	return nil
+}
+
+// readMergeInput reads a JSON export of any schema version and returns the
+// provenance of each run it holds, with every result's RunID set.
+func readMergeInput(path string) ([]json.RawMessage, []benchmark.Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, nil, fmt.Errorf("%s: merge reads JSON exports (-json)", path)
+	}
+	in, err := loadJSONExport(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	runs := in.Runs
+	if len(runs) == 0 {
+		prov := in.Provenance
+		if len(prov) == 0 || string(prov) == "null" {
+			// No provenance at all: the file's content identifies the run
+			sum := sha256.Sum256(data)
+			prov, err = json.Marshal(runSummary{RunID: hex.EncodeToString(sum[:8])})
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		runs = []json.RawMessage{prov}
+		id := runID(prov)
+		for i := range in.Results {
+			if in.Results[i].RunID == "" {
+				in.Results[i].RunID = id
+			}
+		}
+	}
+	return runs, in.Results, nil
+}
+
+// runID returns the run_id recorded in a provenance snapshot. Snapshots
+// from before run IDs existed are identified by a hash of their contents,
+// which is stable across merges since it ignores formatting.
+func runID(prov json.RawMessage) string {
+	var s runSummary
+	if err := json.Unmarshal(prov, &s); err == nil && s.RunID != "" {
+		return s.RunID
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, prov); err != nil {
+		compact.Write(prov)
+	}
+	sum := sha256.Sum256(compact.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// printRuns lists the merged runs with where and when each was measured.
+func printRuns(runs []json.RawMessage, results []benchmark.Result) {
+	counts := make(map[string]int)
+	for _, res := range results {
+		counts[res.RunID]++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "RUN\tHOST\tNETWORK\tGENERATED\tRESULTS"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, run := range runs {
+		var s runSummary
+		_ = json.Unmarshal(run, &s) // Missing fields are shown as "-"
+		id := runID(run)
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", id, orNone(s.Host), orNone(s.Network), orNone(s.GeneratedAt), counts[id]); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
+
+// labelByNetwork returns a copy of results with each server suffixed by the
+// network it was measured from, so stats are computed per server and
+// network.
+func labelByNetwork(results []benchmark.Result) []benchmark.Result {
+	out := make([]benchmark.Result, len(results))
+	for i, res := range results {
+		if res.Network != "" {
+			res.Server = fmt.Sprintf("%s (%s)", res.Server, res.Network)
+		}
+		out[i] = res
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestRunMergeDeduplicatesRuns(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	if err := exportJSON([]benchmark.Result{
+		{Server: "8.8.8.8", Domain: "example.com", Duration: 10 * time.Millisecond, Network: "office"},
+	}, testProvenance(), a); err != nil {
+		t.Fatal(err)
+	}
+	if err := exportJSON([]benchmark.Result{
+		{Server: "8.8.8.8", Domain: "example.com", Duration: 30 * time.Millisecond, Network: "home"},
+		{Server: "1.1.1.1", Domain: "example.com", Duration: 20 * time.Millisecond, Network: "home"},
+	}, testProvenance(), b); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(dir, "merged.json")
+	if err := runMerge([]string{"-o", merged, a, b, a}); err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	// Merging the merged file with one of its inputs again adds nothing
+	again := filepath.Join(dir, "again.json")
+	if err := runMerge([]string{"-o", again, "-per-network", merged, b}); err != nil {
+		t.Fatalf("second merge failed: %v", err)
+	}
+
+	for _, path := range []string{merged, again} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := loadJSONExport(data)
+		if err != nil {
+			t.Fatalf("invalid merged JSON: %v", err)
+		}
+		if out.SchemaVersion != schemaVersion || len(out.Runs) != 2 || len(out.Results) != 3 {
+			t.Errorf("%s: expected 2 runs and 3 results, got %d runs and %d results", filepath.Base(path), len(out.Runs), len(out.Results))
+		}
+		for _, res := range out.Results {
+			if res.RunID == "" {
+				t.Errorf("%s: result without a run ID: %+v", filepath.Base(path), res)
+			}
+		}
+	}
+
+	// Merged files are ordinary exports for readResults
+	results, err := readResults(again)
+	if err != nil || len(results) != 3 {
+		t.Errorf("expected readResults to load 3 merged results, got %d (%v)", len(results), err)
+	}
+}
+
+func TestRunMergeLatencyUnit(t *testing.T) {
+	dir := t.TempDir()
+	us := filepath.Join(dir, "us.json")
+	prov := testProvenance()
+	prov.Config.Unit = "us"
+	if err := exportJSON([]benchmark.Result{
+		{Server: "8.8.8.8", Domain: "example.com", Duration: 12345 * time.Microsecond, Offset: time.Second},
+	}, prov, us); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(dir, "merged.json")
+	if err := runMerge([]string{"-o", merged, us}); err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	results, err := readResults(merged)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected 1 merged result, got %d (%v)", len(results), err)
+	}
+	if results[0].Duration != 12345*time.Microsecond || results[0].Offset != time.Second {
+		t.Errorf("expected the microsecond latencies to survive the merge, got %v and %v", results[0].Duration, results[0].Offset)
+	}
+}
+
+func TestRunIDWithoutProvenanceID(t *testing.T) {
+	prov := json.RawMessage(`{"version": "dev", "network": "home"}`)
+	reformatted := json.RawMessage("{\n  \"version\":\"dev\",\n  \"network\":\"home\"\n}")
+	if runID(prov) != runID(reformatted) {
+		t.Error("expected the run ID of an old snapshot to ignore formatting")
+	}
+	if runID(json.RawMessage(`{"run_id": "abc"}`)) != "abc" {
+		t.Error("expected the recorded run_id to be used")
+	}
+}
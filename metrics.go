@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// metricsBuckets are the upper bounds, in seconds, of the query duration
+// histogram: Prometheus' defaults shifted down to cover fast cache hits.
+var metricsBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// serverMetrics is what is exported for one server.
+type serverMetrics struct {
+	protocol string
+	queries  uint64
+	errors   uint64
+	buckets  []uint64 // Non-cumulative counts per metricsBuckets entry, plus +Inf
+	sum      float64
+}
+
+// metrics collects per-server query counters and a latency histogram from
+// results as they arrive, and renders them in the Prometheus text format.
+type metrics struct {
+	mu      sync.Mutex
+	servers map[string]*serverMetrics
+}
+
+func newMetrics() *metrics {
+	return &metrics{servers: make(map[string]*serverMetrics)}
+}
+
+// observe records one result. It is safe to call while metrics are served.
+func (m *metrics) observe(res benchmark.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.servers[res.Server]
+	if !ok {
+		s = &serverMetrics{protocol: res.Protocol, buckets: make([]uint64, len(metricsBuckets)+1)}
+		m.servers[res.Server] = s
+	}
+	s.queries++
+	if res.Error != nil {
+		s.errors++
+		return
+	}
+	seconds := res.Duration.Seconds()
+	s.buckets[sort.SearchFloat64s(metricsBuckets, seconds)]++
+	s.sum += seconds
+}
+
+// write renders the metrics in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# HELP dns_queries_total DNS queries sent, by server.\n")
+	b.WriteString("# TYPE dns_queries_total counter\n")
+	for _, name := range names {
+		s := m.servers[name]
+		fmt.Fprintf(&b, "dns_queries_total{%s} %d\n", metricsLabels(name, s.protocol), s.queries)
+	}
+	b.WriteString("# HELP dns_query_errors_total DNS queries that failed or timed out, by server.\n")
+	b.WriteString("# TYPE dns_query_errors_total counter\n")
+	for _, name := range names {
+		s := m.servers[name]
+		fmt.Fprintf(&b, "dns_query_errors_total{%s} %d\n", metricsLabels(name, s.protocol), s.errors)
+	}
+	b.WriteString("# HELP dns_query_duration_seconds Latency of successful DNS queries, by server.\n")
+	b.WriteString("# TYPE dns_query_duration_seconds histogram\n")
+	for _, name := range names {
+		s := m.servers[name]
+		labels := metricsLabels(name, s.protocol)
+		var cumulative uint64
+		for i, count := range s.buckets {
+			cumulative += count
+			le := "+Inf"
+			if i < len(metricsBuckets) {
+				le = strconv.FormatFloat(metricsBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(&b, "dns_query_duration_seconds_bucket{%s,le=%q} %d\n", labels, le, cumulative)
+		}
+		fmt.Fprintf(&b, "dns_query_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "dns_query_duration_seconds_count{%s} %d\n", labels, cumulative)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// metricsLabels formats the label set of a server's series.
+func metricsLabels(server, protocol string) string {
+	return fmt.Sprintf(`server="%s",protocol="%s"`, escapeLabel(server), escapeLabel(protocol))
+}
+
+// escapeLabel escapes a label value as the text format requires.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.write(w); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write metrics: %v\n", err)
+	}
+}
+
+// serveMetrics starts serving m on addr at /metrics in the background, with
+// live's WebSocket stream at /ws and a page rendering it at /, and returns
+// the server so it can be closed once the benchmark is done.
+func serveMetrics(m *metrics, live *liveHub, addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	mux.Handle("/ws", live)
+	mux.HandleFunc("/", serveLivePage)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Warning: metrics server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("Serving Prometheus metrics on http://%s/metrics\n", ln.Addr())
+	fmt.Printf("Live results on http://%s/ (WebSocket stream at ws://%s/ws)\n", ln.Addr(), ln.Addr())
+	return srv, nil
+}
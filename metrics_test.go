@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	m.observe(benchmark.Result{Server: "8.8.8.8", Protocol: "udp", Duration: 3 * time.Millisecond})
+	m.observe(benchmark.Result{Server: "8.8.8.8", Protocol: "udp", Duration: 125 * time.Millisecond})
+	m.observe(benchmark.Result{Server: "8.8.8.8", Protocol: "udp", Error: errors.New("timeout")})
+	m.observe(benchmark.Result{Server: "https://dns.google/dns-query", Protocol: "doh", Duration: 10 * time.Second})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`dns_queries_total{server="8.8.8.8",protocol="udp"} 3`,
+		`dns_query_errors_total{server="8.8.8.8",protocol="udp"} 1`,
+		`dns_query_duration_seconds_bucket{server="8.8.8.8",protocol="udp",le="0.001"} 0`,
+		`dns_query_duration_seconds_bucket{server="8.8.8.8",protocol="udp",le="0.005"} 1`,
+		`dns_query_duration_seconds_bucket{server="8.8.8.8",protocol="udp",le="0.25"} 2`,
+		`dns_query_duration_seconds_bucket{server="8.8.8.8",protocol="udp",le="+Inf"} 2`,
+		`dns_query_duration_seconds_sum{server="8.8.8.8",protocol="udp"} 0.128`,
+		`dns_query_duration_seconds_count{server="8.8.8.8",protocol="udp"} 2`,
+		`dns_query_duration_seconds_bucket{server="https://dns.google/dns-query",protocol="doh",le="5"} 0`,
+		`dns_query_duration_seconds_bucket{server="https://dns.google/dns-query",protocol="doh",le="+Inf"} 1`,
+		"# TYPE dns_query_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("unexpected escaping: %s", got)
+	}
+}
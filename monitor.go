@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ScheduleConfig is one entry of the schedules section: a cron expression
+// and the profile to run at those times, either a preset or another config
+// file, plus any extra flags.
+type ScheduleConfig struct {
+	Schedule string   `yaml:"schedule"`
+	Preset   string   `yaml:"preset"`
+	Config   string   `yaml:"config"`
+	Args     []string `yaml:"args"`
+}
+
+// label names the entry in the monitor log.
+func (s ScheduleConfig) label() string {
+	switch {
+	case s.Preset != "":
+		return fmt.Sprintf("%q (preset %s)", s.Schedule, s.Preset)
+	case s.Config != "":
+		return fmt.Sprintf("%q (config %s)", s.Schedule, s.Config)
+	}
+	return fmt.Sprintf("%q", s.Schedule)
+}
+
+// scheduledArgs returns the command line of a scheduled run: the entry's
+// config file (or the monitor's), its preset and extra flags, and -db last
+// so every run lands in the history database.
+func (s ScheduleConfig) scheduledArgs(configFile, db string) []string {
+	if s.Config != "" {
+		configFile = s.Config
+	}
+	args := []string{"-config", configFile}
+	if s.Preset != "" {
+		args = append(args, "-preset", s.Preset)
+	}
+	args = append(args, s.Args...)
+	return append(args, "-db", db)
+}
+
+// monitorConfig finds and loads the monitor's config file, which must have
+// schedules, and picks the history database: db, or the config's. In a
+// fleet config, the probe (named probe, or matching this host) replaces the
+// schedules with its own if it has any, and is recorded in Config.Probe.
+func monitorConfig(configFile, db, probe string) (string, string, *Config, error) {
+	if configFile == "" {
+		configFile = findConfigFile()
+	}
+	if configFile == "" {
+		return "", "", nil, fmt.Errorf("no config file found; monitor needs one with a schedules section")
+	}
+	cfg, err := loadConfigFile(configFile)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("loading config file: %w", err)
+	}
+	if probe != "" {
+		cfg.Probe = probe
+	}
+	p, err := resolveProbe(cfg)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if p != nil {
+		cfg.Probe = p.Name
+		if len(p.Schedules) > 0 {
+			cfg.Schedules = p.Schedules
+		}
+	}
+	if len(cfg.Schedules) == 0 {
+		return "", "", nil, fmt.Errorf("%s has no schedules", configFile)
+	}
+	if db == "" {
+		db = cfg.DB
+	}
+	if db == "" {
+		return "", "", nil, fmt.Errorf("no history database: set db in %s or pass -db", configFile)
+	}
+	return configFile, db, cfg, nil
+}
+
+// runMonitor implements the monitor subcommand, which stays running and
+// starts a full benchmark at the times given by the config's schedules,
+// storing each run in the -db history database.
+//
+// Each run is a separate dns-bench process, so a run behaves exactly as it
+// would from the command line and a run that fails doesn't stop the
+// monitor. After each run, servers whose latency or loss changed sharply
+// since the schedule's previous run are logged as step changes (see
+// AlertConfig). Runs never overlap: one that is due while another is still
+// running starts when it finishes, and slots missed meanwhile are skipped.
+func runMonitor(args []string) error {
+	if len(args) > 0 && args[0] == "install" {
+		return runMonitorInstall(args[1:])
+	}
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	var configFile, db, probe string
+	fs.StringVar(&configFile, "config", "", "Path to config file (YAML) with a schedules section")
+	fs.StringVar(&db, "db", "", "SQLite database to store every run in (default: db from the config file)")
+	fs.StringVar(&probe, "probe", "", "Run as this probe of the config's fleet (default: the probe matching this host's name)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s monitor [options]\n       %s monitor install [options]\n", os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	configFile, db, cfg, err := monitorConfig(configFile, db, probe)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating dns-bench executable: %w", err)
+	}
+
+	schedules := make([]*cronSchedule, len(cfg.Schedules))
+	for i, entry := range cfg.Schedules {
+		if entry.Preset != "" {
+			if _, ok := presets[entry.Preset]; !ok {
+				return fmt.Errorf("schedule %q: unknown preset %q (available: %s)", entry.Schedule, entry.Preset, presetNames())
+			}
+		}
+		if schedules[i], err = parseCron(entry.Schedule); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Monitoring with %d schedule(s) from %s, storing runs in %s\n", len(schedules), configFile, db)
+	if cfg.Probe != "" {
+		fmt.Printf("  Running as probe %s\n", cfg.Probe)
+	}
+	next := make([]time.Time, len(schedules))
+	// last holds each schedule's previous run, which its next run is
+	// checked against for step changes
+	last := make([]map[string]trendPoint, len(schedules))
+	now := time.Now()
+	for i, s := range schedules {
+		next[i] = s.next(now)
+		if next[i].IsZero() {
+			return fmt.Errorf("schedule %q never runs", cfg.Schedules[i].Schedule)
+		}
+		fmt.Printf("  %s: next run %s\n", cfg.Schedules[i].label(), next[i].Format(time.RFC1123))
+	}
+
+	for {
+		due := 0
+		for i := range next {
+			if next[i].Before(next[due]) {
+				due = i
+			}
+		}
+		timer := time.NewTimer(time.Until(next[due]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Println("Monitor stopped")
+			return nil
+		case <-timer.C:
+		}
+
+		ran := make([]bool, len(next))
+		for i, entry := range cfg.Schedules {
+			if next[i].After(time.Now()) {
+				continue
+			}
+			ran[i] = true
+			fmt.Printf("\n[%s] Starting scheduled run %s\n", time.Now().Format(time.RFC3339), entry.label())
+			start := time.Now()
+			args := entry.scheduledArgs(configFile, db)
+			if cfg.Probe != "" {
+				args = append(args, "-probe", cfg.Probe)
+			}
+			// Other runs may land in the same database meanwhile, so the run
+			// is read back by the ID it's given
+			runID := newRunID()
+			//nolint:gosec // G204: runs this executable with arguments from the user's own config
+			cmd := exec.CommandContext(ctx, exe, args...)
+			cmd.Env = append(os.Environ(), runIDEnv+"="+runID)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: scheduled run %s failed: %v\n", entry.label(), err)
+			} else {
+				fmt.Printf("[%s] Scheduled run finished in %v\n", time.Now().Format(time.RFC3339), time.Since(start).Round(time.Second))
+				if run, err := storedRun(db, runID); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: reading scheduled run back from %s: %v\n", db, err)
+				} else {
+					if events := detectSteps(last[i], run, cfg.Alerts); len(events) > 0 {
+						reportSteps(ctx, entry.Schedule, events, cfg.Alerts.Webhook)
+					}
+					last[i] = run
+				}
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		now := time.Now()
+		for i, s := range schedules {
+			if ran[i] {
+				next[i] = s.next(now)
+				fmt.Printf("  %s: next run %s\n", cfg.Schedules[i].label(), next[i].Format(time.RFC1123))
+			}
+		}
+	}
+}
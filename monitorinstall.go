@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Service managers monitor install can generate a definition for.
+const (
+	serviceSystemd = "systemd"
+	serviceLaunchd = "launchd"
+	// serviceWindows is a Task Scheduler task started at boot rather than
+	// a Windows service: a service must answer the service control
+	// manager, which dns-bench doesn't do, so it would be killed on start.
+	serviceWindows = "windows"
+)
+
+// serviceName names the unit, launchd job and scheduled task.
+const serviceName = "dns-bench-monitor"
+
+// launchdLabel is the launchd job label, reverse-DNS style as launchd
+// expects.
+const launchdLabel = "io.github.dns-bench.monitor"
+
+// serviceSpec is what a service definition runs: the monitor with absolute
+// paths, from the directory install was run in so relative paths inside
+// the config resolve the same way.
+type serviceSpec struct {
+	Exe        string
+	ConfigFile string
+	DB         string
+	// Probe is the fleet probe the monitor runs as, when not picked by
+	// hostname
+	Probe   string
+	WorkDir string
+	// User installs a per-user service (systemd --user, a LaunchAgent)
+	// instead of a system-wide one
+	User bool
+}
+
+// args is the monitor's command line.
+func (s serviceSpec) args() []string {
+	args := []string{s.Exe, "monitor", "-config", s.ConfigFile, "-db", s.DB}
+	if s.Probe != "" {
+		args = append(args, "-probe", s.Probe)
+	}
+	return args
+}
+
+var serviceTemplates = map[string]*template.Template{
+	serviceSystemd: template.Must(template.New(serviceSystemd).Funcs(template.FuncMap{"systemdArgs": systemdArgs, "systemdPath": systemdPath}).Parse(`[Unit]
+Description=dns-bench scheduled DNS benchmarks
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{systemdPath .WorkDir}}
+ExecStart={{systemdArgs .Args}}
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy={{if .User}}default.target{{else}}multi-user.target{{end}}
+`)),
+	serviceLaunchd: template.Must(template.New(serviceLaunchd).Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkDir}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>{{xml .Log}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .Log}}</string>
+</dict>
+</plist>
+`)),
+	serviceWindows: template.Must(template.New(serviceWindows).Funcs(template.FuncMap{"xml": xmlEscape, "windowsArgs": windowsArgs}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>dns-bench scheduled DNS benchmarks</Description>
+  </RegistrationInfo>
+  <Triggers>
+    {{- if .User}}
+    <LogonTrigger>
+      <Enabled>true</Enabled>
+    </LogonTrigger>
+    {{- else}}
+    <BootTrigger>
+      <Enabled>true</Enabled>
+    </BootTrigger>
+    {{- end}}
+  </Triggers>
+  <Principals>
+    <Principal id="Author">
+      {{- if .User}}
+      <LogonType>InteractiveToken</LogonType>
+      {{- else}}
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+      {{- end}}
+    </Principal>
+  </Principals>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>
+    <RestartOnFailure>
+      <Interval>PT1M</Interval>
+      <Count>999</Count>
+    </RestartOnFailure>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>{{xml .Exe}}</Command>
+      <Arguments>{{xml (windowsArgs .Args)}}</Arguments>
+      <WorkingDirectory>{{xml .WorkDir}}</WorkingDirectory>
+    </Exec>
+  </Actions>
+</Task>
+`)),
+}
+
+// runMonitorInstall implements monitor install, which writes a service
+// definition that runs the monitor from boot (or login) on, and restarts
+// it if it fails. Without -install it only prints the definition.
+func runMonitorInstall(args []string) error {
+	fs := flag.NewFlagSet("monitor install", flag.ExitOnError)
+	var (
+		configFile, db, target string
+		probe                  string
+		user, install          bool
+	)
+	fs.StringVar(&configFile, "config", "", "Path to config file (YAML) with a schedules section")
+	fs.StringVar(&db, "db", "", "SQLite database to store every run in (default: db from the config file)")
+	fs.StringVar(&probe, "probe", "", "Run as this probe of the config's fleet (default: the probe matching this host's name)")
+	fs.StringVar(&target, "target", defaultServiceTarget(), "Service manager: systemd, launchd or windows (a Task Scheduler task)")
+	fs.BoolVar(&user, "user", false, "Install for the current user only (systemd --user, a LaunchAgent, a task at logon)")
+	fs.BoolVar(&install, "install", false, "Write the definition to its standard location and enable it, instead of printing it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s monitor install [options]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if _, ok := serviceTemplates[target]; !ok {
+		return fmt.Errorf("unknown service target %q (expected systemd, launchd or windows)", target)
+	}
+
+	configFile, db, _, err := monitorConfig(configFile, db, probe)
+	if err != nil {
+		return err
+	}
+	spec := serviceSpec{User: user, Probe: probe}
+	if spec.Exe, err = os.Executable(); err != nil {
+		return fmt.Errorf("locating dns-bench executable: %w", err)
+	}
+	if spec.WorkDir, err = os.Getwd(); err != nil {
+		return err
+	}
+	if spec.ConfigFile, err = filepath.Abs(configFile); err != nil {
+		return err
+	}
+	if spec.DB, err = filepath.Abs(db); err != nil {
+		return err
+	}
+
+	definition, err := renderService(target, spec)
+	if err != nil {
+		return err
+	}
+	path, err := servicePath(target, user)
+	if err != nil {
+		return err
+	}
+	if !install {
+		fmt.Print(definition)
+		fmt.Fprintf(os.Stderr, "\nRun again with -install to write this to %s and enable it.\n", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //nolint:gosec // G301: service directories must be readable by the service manager
+		return err
+	}
+	//nolint:gosec // G306: service managers need to read the definition
+	if err := os.WriteFile(path, []byte(definition), 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	fmt.Printf("✓ Wrote %s\n", path)
+	for _, command := range enableCommands(target, user, path) {
+		fmt.Printf("$ %s\n", strings.Join(command, " "))
+		//nolint:gosec // G204: fixed service manager commands with our own paths
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("enabling the monitor: %w", err)
+		}
+	}
+	fmt.Println("✓ The monitor is running and will start again after a reboot")
+	return nil
+}
+
+// defaultServiceTarget is the service manager of the platform dns-bench was
+// built for.
+func defaultServiceTarget() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return serviceLaunchd
+	case "windows":
+		return serviceWindows
+	}
+	return serviceSystemd
+}
+
+// renderService fills in target's template for spec.
+func renderService(target string, spec serviceSpec) (string, error) {
+	data := struct {
+		serviceSpec
+		Args []string
+		Log  string
+	}{spec, spec.args(), filepath.Join(filepath.Dir(spec.DB), serviceName+".log")}
+
+	var buf bytes.Buffer
+	if err := serviceTemplates[target].Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// servicePath is where target's service manager looks for the definition.
+// Task Scheduler imports it from anywhere, so it goes to the temp directory.
+func servicePath(target string, user bool) (string, error) {
+	home := ""
+	if user && target != serviceWindows {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return "", err
+		}
+	}
+	switch target {
+	case serviceSystemd:
+		if user {
+			return filepath.Join(home, ".config", "systemd", "user", serviceName+".service"), nil
+		}
+		return filepath.Join("/etc/systemd/system", serviceName+".service"), nil
+	case serviceLaunchd:
+		if user {
+			return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+		}
+		return filepath.Join("/Library/LaunchDaemons", launchdLabel+".plist"), nil
+	}
+	return filepath.Join(os.TempDir(), serviceName+".xml"), nil
+}
+
+// enableCommands are the commands that load the definition at path and
+// start the monitor.
+func enableCommands(target string, user bool, path string) [][]string {
+	switch target {
+	case serviceSystemd:
+		systemctl := []string{"systemctl"}
+		if user {
+			systemctl = append(systemctl, "--user")
+		}
+		return [][]string{
+			append(slices.Clone(systemctl), "daemon-reload"),
+			append(slices.Clone(systemctl), "enable", "--now", serviceName+".service"),
+		}
+	case serviceLaunchd:
+		domain := "system"
+		if user {
+			domain = fmt.Sprintf("gui/%d", os.Getuid())
+		}
+		return [][]string{{"launchctl", "bootstrap", domain, path}}
+	}
+	return [][]string{
+		{"schtasks", "/Create", "/TN", serviceName, "/XML", path, "/F"},
+		{"schtasks", "/Run", "/TN", serviceName},
+	}
+}
+
+// systemdArgs quotes a command line for ExecStart: every word in double
+// quotes, with "%" doubled so systemd doesn't read it as a specifier.
+func systemdArgs(args []string) string {
+	words := make([]string, len(args))
+	for i, a := range args {
+		a = strings.ReplaceAll(a, `\`, `\\`)
+		a = strings.ReplaceAll(a, `"`, `\"`)
+		words[i] = `"` + systemdPath(a) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// systemdPath escapes the specifier character in a path setting.
+func systemdPath(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// windowsArgs joins a command line's arguments into one string, each
+// quoted for the Windows C runtime's parser.
+func windowsArgs(cmdline []string) string {
+	args := slices.Clone(cmdline[1:])
+	for i, a := range args {
+		if strings.ContainsAny(a, " \t\"") || a == "" {
+			a = `"` + strings.ReplaceAll(a, `"`, `\"`) + `"`
+		}
+		args[i] = a
+	}
+	return strings.Join(args, " ")
+}
+
+// xmlEscape escapes s for XML text and attribute values.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s)) // Writing to a bytes.Buffer can't fail
+	return buf.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRenderService(t *testing.T) {
+	spec := serviceSpec{
+		Exe:        "/opt/dns bench/dns-bench",
+		ConfigFile: "/etc/dns-bench/100%.yaml",
+		DB:         "/var/lib/dns-bench/R&D.db",
+		WorkDir:    "/var/lib/dns-bench",
+	}
+
+	unit, err := renderService(serviceSystemd, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ExecStart="/opt/dns bench/dns-bench" "monitor" "-config" "/etc/dns-bench/100%%.yaml" "-db" "/var/lib/dns-bench/R&D.db"`
+	if !strings.Contains(unit, want+"\n") || !strings.Contains(unit, "WantedBy=multi-user.target") {
+		t.Errorf("unexpected systemd unit:\n%s", unit)
+	}
+
+	// The XML definitions must stay well-formed whatever the paths contain
+	for _, target := range []string{serviceLaunchd, serviceWindows} {
+		out, err := renderService(target, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(strings.NewReader(out))
+		for {
+			if _, err := dec.Token(); err != nil {
+				if err.Error() != "EOF" {
+					t.Errorf("%s definition is not well-formed XML: %v\n%s", target, err, out)
+				}
+				break
+			}
+		}
+		if !strings.Contains(out, "R&amp;D.db") {
+			t.Errorf("expected the escaped database path in the %s definition:\n%s", target, out)
+		}
+	}
+
+	spec.User = true
+	task, err := renderService(serviceWindows, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(task, "<LogonTrigger>") || !strings.Contains(task, `<Arguments>monitor -config /etc/dns-bench/100%.yaml -db /var/lib/dns-bench/R&amp;D.db</Arguments>`) {
+		t.Errorf("unexpected per-user task:\n%s", task)
+	}
+}
+
+func TestEnableCommands(t *testing.T) {
+	got := enableCommands(serviceSystemd, true, "/home/u/.config/systemd/user/dns-bench-monitor.service")
+	if len(got) != 2 || strings.Join(got[1], " ") != "systemctl --user enable --now dns-bench-monitor.service" {
+		t.Errorf("unexpected systemd commands %v", got)
+	}
+	got = enableCommands(serviceLaunchd, false, "/Library/LaunchDaemons/x.plist")
+	if len(got) != 1 || strings.Join(got[0], " ") != "launchctl bootstrap system /Library/LaunchDaemons/x.plist" {
+		t.Errorf("unexpected launchd commands %v", got)
+	}
+}
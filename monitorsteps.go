@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// Step change thresholds used when the alerts section leaves them out.
+const (
+	defaultStepChange  = 20 * time.Millisecond
+	defaultStepPercent = 50.0
+	defaultStepLoss    = 10.0
+)
+
+// AlertConfig is the alerts section, which sets when the monitor reports a
+// step change: a sudden shift in a server's results from one run of a
+// schedule to the next. Gradual drift never moves far enough between two
+// runs to trip it; that is what the trend subcommand is for.
+type AlertConfig struct {
+	// StepChange and StepPercent are how much a server's average latency
+	// must change between runs, both in absolute terms and relative to the
+	// earlier run, to count as a step
+	StepChange  time.Duration `yaml:"step_change"`
+	StepPercent float64       `yaml:"step_percent"`
+	// StepLoss is the change in loss, in percentage points, that counts
+	// as a step
+	StepLoss float64 `yaml:"step_loss"`
+	// Webhook receives every step change as a JSON POST
+	Webhook string `yaml:"webhook"`
+}
+
+// withDefaults fills in the thresholds a config left out.
+func (a AlertConfig) withDefaults() AlertConfig {
+	a.StepChange = cmp.Or(a.StepChange, defaultStepChange)
+	a.StepPercent = cmp.Or(a.StepPercent, defaultStepPercent)
+	a.StepLoss = cmp.Or(a.StepLoss, defaultStepLoss)
+	return a
+}
+
+// stepEvent is a step change in one server's latency or loss between two
+// consecutive runs of a schedule, as logged and sent to the webhook.
+type stepEvent struct {
+	Event    string  `json:"event"`
+	Time     string  `json:"time"`
+	Schedule string  `json:"schedule"`
+	Server   string  `json:"server"`
+	RunID    string  `json:"run_id"`
+	Metric   string  `json:"metric"`
+	Previous float64 `json:"previous"`
+	Current  float64 `json:"current"`
+	Delta    float64 `json:"delta"`
+}
+
+// Metrics a step change can be in: average latency in milliseconds, or
+// loss in percent.
+const (
+	stepMetricLatency = "avg_ms"
+	stepMetricLoss    = "loss_pct"
+)
+
+func (e stepEvent) String() string {
+	if e.Metric == stepMetricLoss {
+		return fmt.Sprintf("%s loss %.1f%% → %.1f%% (%+.1f points)", e.Server, e.Previous, e.Current, e.Delta)
+	}
+	change := ""
+	if e.Previous > 0 {
+		change = fmt.Sprintf(", %+.0f%%", e.Delta/e.Previous*100)
+	}
+	return fmt.Sprintf("%s avg %.1fms → %.1fms (%+.1fms%s)", e.Server, e.Previous, e.Current, e.Delta, change)
+}
+
+// detectSteps compares each server's results in run cur with the previous
+// run prev of the same schedule and returns the step changes, by server.
+// Servers missing from either run are skipped.
+func detectSteps(prev, cur map[string]trendPoint, alerts AlertConfig) []stepEvent {
+	alerts = alerts.withDefaults()
+	var events []stepEvent
+	for server, c := range cur {
+		p, ok := prev[server]
+		if !ok {
+			continue
+		}
+		event := stepEvent{Event: "step_change", Time: c.At.Format(time.RFC3339), Server: server, RunID: c.RunID}
+
+		delta := c.Avg - p.Avg
+		if p.Avg > 0 && c.Avg > 0 && delta.Abs() >= alerts.StepChange &&
+			float64(delta.Abs())/float64(p.Avg)*100 >= alerts.StepPercent {
+			event.Metric, event.Previous, event.Current, event.Delta = stepMetricLatency, millis(p.Avg), millis(c.Avg), millis(delta)
+			events = append(events, event)
+		}
+		if loss := c.LossPct - p.LossPct; loss >= alerts.StepLoss || -loss >= alerts.StepLoss {
+			event.Metric, event.Previous, event.Current, event.Delta = stepMetricLoss, p.LossPct, c.LossPct, loss
+			events = append(events, event)
+		}
+	}
+	slices.SortFunc(events, func(a, b stepEvent) int {
+		return cmp.Or(cmp.Compare(a.Server, b.Server), cmp.Compare(a.Metric, b.Metric))
+	})
+	return events
+}
+
+// storedRun reads the per-server stats of the run with runID from the
+// database at path.
+func storedRun(path, runID string) (map[string]trendPoint, error) {
+	db, err := openResultsDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close database: %v\n", err)
+		}
+	}()
+
+	rows, err := db.Query(`SELECT r.run_id, r.generated_at, s.server, s.avg_ms, s.loss_pct
+		FROM server_stats s JOIN runs r USING (run_id)
+		WHERE r.run_id = ?`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make(map[string]trendPoint)
+	for rows.Next() {
+		var (
+			p           trendPoint
+			at, server  string
+			avgMs, loss float64
+		)
+		if err := rows.Scan(&p.RunID, &at, &server, &avgMs, &loss); err != nil {
+			return nil, err
+		}
+		if p.At, err = time.Parse(time.RFC3339, at); err != nil {
+			return nil, fmt.Errorf("run %s: %w", p.RunID, err)
+		}
+		p.Avg = time.Duration(avgMs * float64(time.Millisecond))
+		p.LossPct = loss
+		points[server] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("run %s isn't in the database", runID)
+	}
+	return points, nil
+}
+
+// postWebhook sends events to url as a JSON array.
+func postWebhook(ctx context.Context, url string, events []stepEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// reportSteps logs the step changes a run of schedule showed and sends
+// them to the webhook, if one is configured.
+func reportSteps(ctx context.Context, schedule string, events []stepEvent, webhook string) {
+	for i := range events {
+		events[i].Schedule = schedule
+		fmt.Printf("⚠️  Step change: %s\n", events[i])
+	}
+	if webhook == "" {
+		return
+	}
+	if err := postWebhook(ctx, webhook, events); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to send step changes to webhook: %v\n", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestDetectSteps(t *testing.T) {
+	ms := time.Millisecond
+	prev := map[string]trendPoint{
+		"step":  {Avg: 20 * ms},
+		"drift": {Avg: 20 * ms},
+		"noise": {Avg: 200 * ms},
+		"down":  {Avg: 15 * ms},
+	}
+	cur := map[string]trendPoint{
+		"step":  {Avg: 60 * ms},
+		"drift": {Avg: 25 * ms},  // Below the absolute threshold
+		"noise": {Avg: 230 * ms}, // Only 15% slower
+		"down":  {Avg: 15 * ms, LossPct: 40},
+		"new":   {Avg: 500 * ms},
+	}
+	events := detectSteps(prev, cur, AlertConfig{})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 step changes, got %+v", events)
+	}
+	if e := events[0]; e.Server != "down" || e.Metric != stepMetricLoss || e.Delta != 40 {
+		t.Errorf("expected a 40 point loss step for down, got %+v", e)
+	}
+	if e := events[1]; e.Server != "step" || e.Metric != stepMetricLatency || e.Delta != 40 {
+		t.Errorf("expected a +40ms latency step for step, got %+v", e)
+	}
+
+	if events := detectSteps(prev, cur, AlertConfig{StepChange: 5 * ms, StepPercent: 10, StepLoss: 50}); len(events) != 3 {
+		t.Errorf("expected lower latency thresholds to catch step, drift and noise, got %+v", events)
+	}
+	if events := detectSteps(nil, cur, AlertConfig{}); len(events) != 0 {
+		t.Errorf("expected no step changes without a previous run, got %+v", events)
+	}
+}
+
+func TestStoredRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	// A run stored meanwhile with a later timestamp doesn't shadow the one
+	// asked for
+	for id, ms := range map[string]time.Duration{"scheduled": 10, "other": 30} {
+		results := []benchmark.Result{{Server: "8.8.8.8", Domain: "google.com", Duration: ms * time.Millisecond}}
+		prov := &provenance{RunID: id, GeneratedAt: time.Now().UTC().Add(ms * time.Minute)}
+		if _, err := storeRun(path, prov, results, calculateStats(results), time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run, err := storedRun(path, "scheduled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := run["8.8.8.8"]; p.RunID != "scheduled" || p.Avg != 10*time.Millisecond {
+		t.Errorf("expected the scheduled run's 10ms, got %+v", p)
+	}
+	if _, err := storedRun(path, "missing"); err == nil {
+		t.Error("expected an error for a run that isn't stored")
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	var got []stepEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	events := []stepEvent{{Event: "step_change", Server: "1.1.1.1", Metric: stepMetricLatency, Previous: 10, Current: 50, Delta: 40}}
+	if err := postWebhook(context.Background(), srv.URL, events); err != nil {
+		t.Fatalf("postWebhook failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != events[0] {
+		t.Errorf("expected the event to arrive unchanged, got %+v", got)
+	}
+}
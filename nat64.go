@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dns-bench/netenv"
+)
+
+// nat64Via maps every server given as an IPv4 literal to the address that
+// reaches it through the NAT64, for use as benchmark.Config.Via.
+func nat64Via(servers []string, n netenv.NAT64) map[string]string {
+	via := make(map[string]string)
+	for _, s := range servers {
+		if target, ok := viaNAT64(s, n); ok {
+			via[s] = target
+		}
+	}
+	return via
+}
+
+// viaNAT64 rewrites an IPv4-literal server (plain, tcp://, tls://, https://
+// or h3://) to the NAT64-synthesized IPv6 address, keeping scheme, port and path.
+func viaNAT64(server string, n netenv.NAT64) (string, bool) {
+	if strings.HasPrefix(server, "https://") || strings.HasPrefix(server, "h3://") {
+		u, err := url.Parse(server)
+		if err != nil {
+			return "", false
+		}
+		addr, err := netip.ParseAddr(u.Hostname())
+		if err != nil || !addr.Is4() {
+			return "", false
+		}
+		host := "[" + n.Synthesize(addr).String() + "]"
+		if port := u.Port(); port != "" {
+			host += ":" + port
+		}
+		u.Host = host
+		return u.String(), true
+	}
+
+	scheme, host, port := "", server, "53"
+	if rest, ok := strings.CutPrefix(server, "tls://"); ok {
+		scheme, host, port = "tls://", rest, "853"
+	} else if rest, ok := strings.CutPrefix(server, "tcp://"); ok {
+		scheme, host = "tcp://", rest
+	}
+	if ap, err := netip.ParseAddrPort(host); err == nil {
+		host, port = ap.Addr().String(), strconv.Itoa(int(ap.Port()))
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !addr.Is4() {
+		return "", false
+	}
+	return scheme + "[" + n.Synthesize(addr).String() + "]:" + port, true
+}
+
+// checkNAT64 detects NAT64 and 464XLAT and reports what it means for the
+// run. On an IPv6-only network it returns the servers to reach through the
+// NAT64 and a suffix for the network label.
+func checkNAT64(servers []string, warns *warningLog) (via map[string]string, label string) {
+	n, ok := netenv.DetectNAT64()
+	if !ok {
+		if !netenv.HasIPv4Route() {
+			warns.add("nat64", "no IPv4 route and no NAT64 found; IPv4 servers will be unreachable")
+		}
+		return nil, ""
+	}
+	if n.IPv4 {
+		fmt.Printf("NAT64 prefix %s found alongside an IPv4 route (likely 464XLAT): IPv4 servers are reached through a local translator and the NAT64\n", n.Prefix)
+		return nil, " [464XLAT]"
+	}
+	via = nat64Via(servers, n)
+	fmt.Printf("IPv6-only network with NAT64 prefix %s: %d IPv4 server(s) queried through it\n", n.Prefix, len(via))
+	return via, " [NAT64 " + n.Prefix.String() + "]"
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+
+	"dns-bench/netenv"
+)
+
+func TestViaNAT64(t *testing.T) {
+	n := netenv.NAT64{Prefix: netip.MustParsePrefix("64:ff9b::/96")}
+	tests := []struct {
+		server string
+		want   string
+	}{
+		{"8.8.8.8", "[64:ff9b::808:808]:53"},
+		{"8.8.8.8:5353", "[64:ff9b::808:808]:5353"},
+		{"tls://1.1.1.1", "tls://[64:ff9b::101:101]:853"},
+		{"tcp://1.1.1.1", "tcp://[64:ff9b::101:101]:53"},
+		{"https://1.1.1.1/dns-query", "https://[64:ff9b::101:101]/dns-query"},
+		{"h3://1.1.1.1/dns-query", "h3://[64:ff9b::101:101]/dns-query"},
+	}
+	for _, tt := range tests {
+		got, ok := viaNAT64(tt.server, n)
+		if !ok || got != tt.want {
+			t.Errorf("%s: expected %s, got %q (ok=%v)", tt.server, tt.want, got, ok)
+		}
+	}
+
+	for _, server := range []string{"2001:4860:4860::8888", "https://dns.google/dns-query", "tls://dns.quad9.net"} {
+		if _, ok := viaNAT64(server, n); ok {
+			t.Errorf("%s: expected no rewrite", server)
+		}
+	}
+}
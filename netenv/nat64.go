@@ -0,0 +1,103 @@
+package netenv
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// ipv4onlyAddrs are the addresses ipv4only.arpa resolves to. A DNS64
+// resolver synthesizes AAAA records for them, which reveals the NAT64
+// prefix (RFC 7050).
+var ipv4onlyAddrs = []netip.Addr{
+	netip.AddrFrom4([4]byte{192, 0, 0, 170}),
+	netip.AddrFrom4([4]byte{192, 0, 0, 171}),
+}
+
+// rfc6052Offsets lists, per NAT64 prefix length, the byte offsets of the
+// embedded IPv4 address. Byte 8 (bits 64-71) is reserved and always skipped.
+var rfc6052Offsets = []struct {
+	bits    int
+	offsets [4]int
+}{
+	{96, [4]int{12, 13, 14, 15}},
+	{64, [4]int{9, 10, 11, 12}},
+	{56, [4]int{7, 9, 10, 11}},
+	{48, [4]int{6, 7, 9, 10}},
+	{40, [4]int{5, 6, 7, 9}},
+	{32, [4]int{4, 5, 6, 7}},
+}
+
+// NAT64 describes a NAT64 gateway discovered through DNS64.
+type NAT64 struct {
+	Prefix netip.Prefix
+	// IPv4 reports whether the host has an IPv4 route as well. On a network
+	// with NAT64 that is usually 464XLAT: a local CLAT translates IPv4 into
+	// IPv6, so IPv4 traffic still crosses the NAT64.
+	IPv4 bool
+}
+
+// DetectNAT64 looks for a NAT64 prefix by resolving ipv4only.arpa's AAAA
+// records through the system resolver.
+func DetectNAT64() (NAT64, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip6", "ipv4only.arpa")
+	if err != nil {
+		return NAT64{}, false
+	}
+	for _, a := range addrs {
+		if prefix, ok := nat64Prefix(a); ok {
+			return NAT64{Prefix: prefix, IPv4: HasIPv4Route()}, true
+		}
+	}
+	return NAT64{}, false
+}
+
+// nat64Prefix recovers the NAT64 prefix from a synthesized ipv4only.arpa
+// address, trying each RFC 6052 prefix length.
+func nat64Prefix(a netip.Addr) (netip.Prefix, bool) {
+	if !a.Is6() || a.Is4In6() {
+		return netip.Prefix{}, false
+	}
+	b := a.As16()
+	for _, l := range rfc6052Offsets {
+		embedded := netip.AddrFrom4([4]byte{b[l.offsets[0]], b[l.offsets[1]], b[l.offsets[2]], b[l.offsets[3]]})
+		for _, want := range ipv4onlyAddrs {
+			if embedded == want {
+				return netip.PrefixFrom(a, l.bits).Masked(), true
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// Synthesize embeds an IPv4 address in the NAT64 prefix, giving the IPv6
+// address that reaches it through the NAT64.
+func (n NAT64) Synthesize(v4 netip.Addr) netip.Addr {
+	b := n.Prefix.Masked().Addr().As16()
+	ip := v4.As4()
+	for _, l := range rfc6052Offsets {
+		if l.bits == n.Prefix.Bits() {
+			for i, off := range l.offsets {
+				b[off] = ip[i]
+			}
+			break
+		}
+	}
+	return netip.AddrFrom16(b)
+}
+
+// HasIPv4Route reports whether the kernel has a route for IPv4 traffic.
+// No packets are sent.
+func HasIPv4Route() bool {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "udp4", "192.0.2.1:53")
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
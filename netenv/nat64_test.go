@@ -0,0 +1,51 @@
+package netenv
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNAT64Prefix(t *testing.T) {
+	tests := []struct {
+		synthesized string
+		prefix      string
+	}{
+		{"64:ff9b::c000:aa", "64:ff9b::/96"},
+		{"2001:db8:122:344::192.0.0.171", "2001:db8:122:344::/96"},
+		{"2001:db8:122:344:c0:0:aa00:0", "2001:db8:122:344::/64"},
+		{"2001:db8:c000:aa::", "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		got, ok := nat64Prefix(netip.MustParseAddr(tt.synthesized))
+		if !ok || got.String() != tt.prefix {
+			t.Errorf("%s: expected prefix %s, got %v (ok=%v)", tt.synthesized, tt.prefix, got, ok)
+		}
+	}
+
+	for _, addr := range []string{"2001:db8::1", "192.0.0.170", "::ffff:192.0.0.170"} {
+		if _, ok := nat64Prefix(netip.MustParseAddr(addr)); ok {
+			t.Errorf("%s: expected no NAT64 prefix", addr)
+		}
+	}
+}
+
+func TestNAT64Synthesize(t *testing.T) {
+	// Examples from RFC 6052 section 2.4
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::c000:221"},
+	}
+	for _, tt := range tests {
+		n := NAT64{Prefix: netip.MustParsePrefix(tt.prefix)}
+		if got := n.Synthesize(netip.MustParseAddr("192.0.2.33")); got.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.prefix, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package netenv detects the network environment a benchmark runs in, so
+// results from different networks (home Wi-Fi, office ethernet, LTE) can be
+// told apart later.
+package netenv
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds the helper commands used for SSID/gateway lookup.
+const commandTimeout = 2 * time.Second
+
+// Environment describes the active network at run time. Fields that cannot
+// be determined on the current platform are left empty.
+type Environment struct {
+	Interface string `json:"interface,omitempty"`
+	LocalIP   string `json:"local_ip,omitempty"`
+	SSID      string `json:"ssid,omitempty"`
+	Gateway   string `json:"gateway,omitempty"`
+}
+
+// Detect inspects the host and returns the active network environment.
+func Detect() Environment {
+	var env Environment
+	env.Interface, env.LocalIP = activeInterface()
+	if env.Interface != "" {
+		env.SSID = wifiSSID(env.Interface)
+	}
+	env.Gateway = defaultGateway()
+	return env
+}
+
+// Label returns a compact tag such as "wlan0 (HomeWiFi) via 192.168.1.1".
+func (e Environment) Label() string {
+	if e.Interface == "" {
+		return "unknown"
+	}
+	label := e.Interface
+	if e.SSID != "" {
+		label += " (" + e.SSID + ")"
+	}
+	if e.Gateway != "" {
+		label += " via " + e.Gateway
+	}
+	return label
+}
+
+// ForInterface describes the named interface rather than the one carrying
+// the default route. Gateway is left empty, since the default gateway
+// belongs to the default route.
+func ForInterface(name string) Environment {
+	env := Environment{Interface: name, SSID: wifiSSID(name)}
+	if iface, err := net.InterfaceByName(name); err == nil {
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, a := range addrs {
+				if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+					env.LocalIP = ipnet.IP.String()
+					break
+				}
+			}
+		}
+	}
+	return env
+}
+
+// UsableInterfaces lists the interfaces that are up and running, aren't
+// loopback and have a global unicast address: the candidate uplinks (Wi-Fi,
+// Ethernet, LTE) on a multi-homed host.
+func UsableInterfaces() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagRunning == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+				names = append(names, iface.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// activeInterface finds the interface carrying the default route by asking the
+// kernel which source address it would use for an outbound UDP socket. No
+// packets are sent. IPv6-only hosts are found through the IPv6 default route.
+func activeInterface() (name, localIP string) {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "udp", "192.0.2.1:53")
+	if err != nil {
+		conn, err = d.DialContext(ctx, "udp", "[2001:db8::1]:53")
+	}
+	if err != nil {
+		return "", ""
+	}
+	defer func() { _ = conn.Close() }()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return "", ""
+	}
+	localIP = addr.IP.String()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", localIP
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(addr.IP) {
+				return iface.Name, localIP
+			}
+		}
+	}
+	return "", localIP
+}
+
+// runCommand runs a helper command and returns its output, or "" on failure.
+func runCommand(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// parseProcNetRoute returns the default gateway from Linux /proc/net/route,
+// whose addresses are little-endian hex.
+func parseProcNetRoute(data string) string {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		return ip.String()
+	}
+	return ""
+}
+
+// parseKeyValue returns the value of the first "key: value" (or "key =
+// value") line whose key matches, as printed by route, networksetup and netsh.
+func parseKeyValue(data, key string) string {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,17 @@
+//go:build darwin
+
+package netenv
+
+import "strings"
+
+// defaultGateway parses `route -n get default`.
+func defaultGateway() string {
+	return parseKeyValue(runCommand("route", "-n", "get", "default"), "gateway")
+}
+
+// wifiSSID parses `networksetup -getairportnetwork <iface>`, which prints
+// "Current Wi-Fi Network: <ssid>" for Wi-Fi interfaces.
+func wifiSSID(iface string) string {
+	ssid := parseKeyValue(runCommand("networksetup", "-getairportnetwork", iface), "Current Wi-Fi Network")
+	return strings.TrimSpace(ssid)
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package netenv
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultGateway reads the IPv4 default route from /proc/net/route.
+func defaultGateway() string {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	return parseProcNetRoute(string(data))
+}
+
+// wifiSSID asks iwgetid for the SSID of iface; wired interfaces return "".
+func wifiSSID(iface string) string {
+	return strings.TrimSpace(runCommand("iwgetid", iface, "-r"))
+}
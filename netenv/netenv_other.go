@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package netenv
+
+// defaultGateway is not implemented on this platform.
+func defaultGateway() string { return "" }
+
+// wifiSSID is not implemented on this platform.
+func wifiSSID(_ string) string { return "" }
@@ -0,0 +1,55 @@
+package netenv
+
+import "testing"
+
+func TestParseProcNetRoute(t *testing.T) {
+	data := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0000A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	if got := parseProcNetRoute(data); got != "192.168.1.1" {
+		t.Errorf("expected gateway 192.168.1.1, got %q", got)
+	}
+	if got := parseProcNetRoute("Iface\tDestination\tGateway\n"); got != "" {
+		t.Errorf("expected no gateway, got %q", got)
+	}
+}
+
+func TestParseKeyValue(t *testing.T) {
+	route := `   route to: default
+destination: default
+       mask: default
+    gateway: 10.0.0.1
+  interface: en0`
+	if got := parseKeyValue(route, "gateway"); got != "10.0.0.1" {
+		t.Errorf("expected gateway 10.0.0.1, got %q", got)
+	}
+
+	netsh := `    Name                   : Wi-Fi
+    State                  : connected
+    SSID                   : Office Guest
+    BSSID                  : aa:bb:cc:dd:ee:ff`
+	if got := parseKeyValue(netsh, "SSID"); got != "Office Guest" {
+		t.Errorf("expected SSID 'Office Guest', got %q", got)
+	}
+
+	if got := parseKeyValue("Current Wi-Fi Network: HomeNet", "Current Wi-Fi Network"); got != "HomeNet" {
+		t.Errorf("expected SSID HomeNet, got %q", got)
+	}
+}
+
+func TestEnvironmentLabel(t *testing.T) {
+	tests := []struct {
+		env  Environment
+		want string
+	}{
+		{Environment{}, "unknown"},
+		{Environment{Interface: "eth0"}, "eth0"},
+		{Environment{Interface: "wlan0", SSID: "HomeNet", Gateway: "192.168.1.1"}, "wlan0 (HomeNet) via 192.168.1.1"},
+	}
+	for _, tt := range tests {
+		if got := tt.env.Label(); got != tt.want {
+			t.Errorf("Label() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package netenv
+
+import (
+	"bufio"
+	"net"
+	"strings"
+)
+
+// defaultGateway parses the 0.0.0.0 route out of `route print -4 0.0.0.0`.
+func defaultGateway() string {
+	scanner := bufio.NewScanner(strings.NewReader(runCommand("route", "print", "-4", "0.0.0.0")))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[0] == "0.0.0.0" && fields[1] == "0.0.0.0" && net.ParseIP(fields[2]) != nil {
+			return fields[2]
+		}
+	}
+	return ""
+}
+
+// wifiSSID parses `netsh wlan show interfaces`. Windows reports adapters by
+// friendly name rather than the Go interface name, so the first connected
+// SSID is used.
+func wifiSSID(_ string) string {
+	return parseKeyValue(runCommand("netsh", "wlan", "show", "interfaces"), "SSID")
+}
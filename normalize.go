@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/browser"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// importReport follows an imported domain list from the entries read to the
+// domains benchmarked, so it's clear why 1000 history entries became 240
+// domains.
+type importReport struct {
+	Source string
+	// Entries is what the source held: history entries or list lines
+	Entries int
+	// Repeats are entries repeating a name already listed, such as more
+	// visits to a host or more queries for a name
+	Repeats int
+	// Excluded counts entries the source's own filters left out, by reason
+	Excluded map[string]int
+	// Lowercased and Punycoded count names that were rewritten, not dropped
+	Lowercased int
+	Punycoded  int
+	// Collapsed counts names replaced by their registrable domain
+	Collapsed  int
+	Duplicates int
+	Invalid    int
+	Kept       int
+}
+
+// historyReport starts the report for domains read from browser history.
+func historyReport(name string, h *browser.History) importReport {
+	excluded := 0
+	for _, n := range h.Excluded {
+		excluded += n
+	}
+	return importReport{
+		Source:   name + " history",
+		Entries:  h.Entries,
+		Repeats:  h.Entries - excluded - len(h.Domains),
+		Excluded: h.Excluded,
+	}
+}
+
+// normalizeDomains lowercases every name, converts internationalized names
+// to punycode and, with collapse, replaces each by its registrable domain
+// (eTLD+1), dropping the duplicates that leaves. renamed maps each name that
+// changed to what it became, for carrying ranks and visit counts over.
+func normalizeDomains(domains []string, collapse bool, report *importReport) (out []string, renamed map[string]string) {
+	renamed = make(map[string]string)
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		name := strings.TrimSpace(d)
+		if name == "" {
+			continue
+		}
+		if lower := strings.ToLower(name); lower != name {
+			report.Lowercased++
+			name = lower
+		}
+		if ascii, err := idna.Lookup.ToASCII(name); err == nil && ascii != name {
+			report.Punycoded++
+			name = ascii
+		}
+		if collapse {
+			if reg, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(name, ".")); err == nil && reg != strings.TrimSuffix(name, ".") {
+				report.Collapsed++
+				name = reg
+			}
+		}
+		if key := strings.ToLower(strings.TrimSpace(d)); name != key {
+			renamed[key] = name
+		}
+		if seen[name] {
+			report.Duplicates++
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, renamed
+}
+
+// renameCounts moves the counts in m (ranks or visits) to the normalized
+// names, combining those that now share a name with merge.
+func renameCounts(m map[string]int, renamed map[string]string, merge func(a, b int) int) map[string]int {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]int, len(m))
+	for name, n := range m {
+		name = strings.ToLower(name)
+		if to, ok := renamed[name]; ok {
+			name = to
+		}
+		if prev, ok := out[name]; ok {
+			n = merge(prev, n)
+		}
+		out[name] = n
+	}
+	return out
+}
+
+// changed reports whether the import rewrote or dropped anything, which is
+// when the report is worth printing.
+func (r importReport) changed() bool {
+	return r.Kept != r.Entries || r.Lowercased > 0 || r.Punycoded > 0 || r.Collapsed > 0
+}
+
+// printImport shows how an imported list became the benchmarked domains.
+func printImport(r importReport) {
+	if !r.changed() {
+		return
+	}
+
+	fmt.Printf("\nDomain Import (%s)\n\n", r.Source)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	row := func(n int, step string) {
+		if _, err := fmt.Fprintf(w, "%d\t%s\n", n, step); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	dropped := func(n int, step string) {
+		if n > 0 {
+			row(-n, step)
+		}
+	}
+	if _, err := fmt.Fprintln(w, "DOMAINS\tSTEP"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	row(r.Entries, "read")
+	dropped(r.Repeats, "repeats of a listed name")
+	reasons := make([]string, 0, len(r.Excluded))
+	for reason := range r.Excluded {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		dropped(r.Excluded[reason], "excluded: "+reason)
+	}
+	if r.Lowercased > 0 {
+		row(r.Lowercased, "lowercased")
+	}
+	if r.Punycoded > 0 {
+		row(r.Punycoded, "converted to punycode")
+	}
+	if r.Collapsed > 0 {
+		row(r.Collapsed, "collapsed to their registrable domain (eTLD+1)")
+	}
+	dropped(r.Duplicates, "duplicates")
+	dropped(r.Invalid, "invalid names")
+	row(r.Kept, "benchmarked")
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
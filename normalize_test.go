@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"dns-bench/browser"
+)
+
+func TestNormalizeDomains(t *testing.T) {
+	h := &browser.History{
+		Domains:  []string{"WWW.Example.com", "mail.example.com", "bücher.de", "example.com", "news.bbc.co.uk"},
+		Visits:   map[string]int{"WWW.Example.com": 3, "mail.example.com": 2, "bücher.de": 1, "example.com": 4, "news.bbc.co.uk": 5},
+		Entries:  12,
+		Excluded: map[string]int{browser.ExcludedIP: 2},
+	}
+	report := historyReport("safari", h)
+	if report.Repeats != 5 {
+		t.Errorf("expected 5 repeat visits, got %d", report.Repeats)
+	}
+
+	domains, renamed := normalizeDomains(h.Domains, true, &report)
+	want := []string{"example.com", "xn--bcher-kva.de", "bbc.co.uk"}
+	if len(domains) != len(want) {
+		t.Fatalf("expected %v, got %v", want, domains)
+	}
+	for i := range want {
+		if domains[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, domains)
+		}
+	}
+	if report.Lowercased != 1 || report.Punycoded != 1 || report.Collapsed != 3 || report.Duplicates != 2 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+
+	visits := renameCounts(h.Visits, renamed, func(a, b int) int { return a + b })
+	if visits["example.com"] != 9 || visits["xn--bcher-kva.de"] != 1 || visits["bbc.co.uk"] != 5 {
+		t.Errorf("expected visits merged onto the normalized names, got %v", visits)
+	}
+
+	// Without collapsing, subdomains stay apart
+	var plain importReport
+	if domains, _ := normalizeDomains(h.Domains, false, &plain); len(domains) != 5 || plain.Duplicates != 0 {
+		t.Errorf("expected all 5 hostnames without collapsing, got %v %+v", domains, plain)
+	}
+}
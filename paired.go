@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// pairedRow compares one server with the reference server of a -paired run
+// over the pairs both of them answered.
+type pairedRow struct {
+	Server string
+	Pairs  int
+	// MedianDiff is the median of the server's latency minus the
+	// reference's; positive when the server is usually slower
+	MedianDiff time.Duration
+	// Faster is the fraction of pairs the server answered quicker than the
+	// reference
+	Faster float64
+	P      float64
+	z      float64
+}
+
+// verdict says whether the server is significantly slower or faster than
+// the reference, by a Wilcoxon signed-rank test as for duel.
+func (r pairedRow) verdict() string {
+	switch {
+	case r.Pairs < duelMinPairs:
+		return fmt.Sprintf("too few pairs (need %d)", duelMinPairs)
+	case r.P >= duelAlpha:
+		return "no significant difference"
+	case r.z > 0:
+		return "slower"
+	default:
+		return "faster"
+	}
+}
+
+// pairKey identifies one domain queried on every server back to back.
+type pairKey struct {
+	network string
+	pass    string
+	pair    int
+}
+
+// comparePaired compares every server of a -paired run with the reference:
+// the server with the lowest median latency. Each server is judged on the
+// per-domain differences from the reference rather than on its own average,
+// so changing network conditions and domain mix cancel out.
+func comparePaired(results []benchmark.Result) (reference string, rows []pairedRow) {
+	pairs := make(map[pairKey]map[string]time.Duration)
+	byServer := make(map[string][]time.Duration)
+	for _, res := range results {
+		if res.Pair == 0 || res.Error != nil {
+			continue
+		}
+		key := pairKey{res.Network, res.CachePass, res.Pair}
+		if pairs[key] == nil {
+			pairs[key] = make(map[string]time.Duration)
+		}
+		pairs[key][res.Server] = res.Duration
+		byServer[res.Server] = append(byServer[res.Server], res.Duration)
+	}
+	if len(byServer) < 2 {
+		return "", nil
+	}
+
+	var best time.Duration
+	for server, durations := range byServer {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		median := durations[len(durations)/2]
+		if reference == "" || median < best || (median == best && server < reference) {
+			reference, best = server, median
+		}
+	}
+
+	for server := range byServer {
+		if server == reference {
+			continue
+		}
+		var diffs []time.Duration
+		faster := 0
+		for _, pair := range pairs {
+			d, ok := pair[server]
+			ref, refOK := pair[reference]
+			if !ok || !refOK {
+				continue
+			}
+			diffs = append(diffs, d-ref)
+			if d < ref {
+				faster++
+			}
+		}
+		row := pairedRow{Server: server, Pairs: len(diffs), P: 1}
+		if len(diffs) > 0 {
+			row.Faster = float64(faster) / float64(len(diffs))
+			sorted := append([]time.Duration(nil), diffs...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			row.MedianDiff = sorted[len(sorted)/2]
+			row.z, row.P = signedRank(diffs)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].MedianDiff != rows[j].MedianDiff {
+			return rows[i].MedianDiff < rows[j].MedianDiff
+		}
+		return rows[i].Server < rows[j].Server
+	})
+	return reference, rows
+}
+
+// printPaired writes the Paired Comparison table of a -paired run.
+func printPaired(reference string, rows []pairedRow) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Printf("\nPaired Comparison (per-domain latency difference from %s, the fastest by median)\n\n", reference)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tPAIRS\tMEDIAN DIFF\tFASTER IN\tP\tVERDICT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range rows {
+		sign := ""
+		if r.MedianDiff >= 0 {
+			sign = "+"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s%v\t%.1f%%\t%.3g\t%s\n", r.Server, r.Pairs, sign, r.MedianDiff.Round(time.Microsecond), r.Faster*100, r.P, r.verdict()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestComparePaired(t *testing.T) {
+	var results []benchmark.Result
+	for pair := 1; pair <= 20; pair++ {
+		// Latency swings a lot from domain to domain, but b is always 2ms
+		// behind a and c matches a
+		base := time.Duration(pair%5) * 20 * time.Millisecond
+		c := base
+		if pair%2 == 0 {
+			c += time.Millisecond
+		} else {
+			c -= time.Millisecond
+		}
+		results = append(results,
+			benchmark.Result{Server: "a", Pair: pair, Duration: base + 10*time.Millisecond},
+			benchmark.Result{Server: "b", Pair: pair, Duration: base + 12*time.Millisecond},
+			benchmark.Result{Server: "c", Pair: pair, Duration: c + 10*time.Millisecond},
+		)
+	}
+	// A failed lookup drops the pair for that server only
+	results[1].Error = errors.New("timeout")
+
+	reference, rows := comparePaired(results)
+	if reference != "a" {
+		t.Fatalf("expected a as the reference, got %q", reference)
+	}
+	if len(rows) != 2 || rows[0].Server != "c" || rows[1].Server != "b" {
+		t.Fatalf("expected c then b, got %+v", rows)
+	}
+	if b := rows[1]; b.Pairs != 19 || b.MedianDiff != 2*time.Millisecond || b.Faster != 0 || b.verdict() != "slower" {
+		t.Errorf("expected b to be 2ms slower over 19 pairs, got %+v (%s)", b, b.verdict())
+	}
+	if c := rows[0]; c.Pairs != 20 || c.Faster != 0.5 || c.verdict() != "no significant difference" {
+		t.Errorf("expected no significant difference for c, got %+v (%s)", c, c.verdict())
+	}
+
+	if _, rows := comparePaired([]benchmark.Result{{Server: "a", Duration: time.Millisecond}}); rows != nil {
+		t.Errorf("expected no comparison outside a paired run, got %+v", rows)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/miekg/dns"
+)
+
+// pcapNgMagic starts a pcapng section header block.
+var pcapNgMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// captureQueries are the DNS questions seen in a packet capture.
+type captureQueries struct {
+	// Domains are the unique query names, most frequently asked first
+	Domains []string
+	// Counts is how often each name was asked
+	Counts map[string]int
+	// Queries is the number of DNS queries read, other than for the root;
+	// Malformed the port 53 payloads that didn't parse as one
+	Queries   int
+	Malformed int
+}
+
+// packetSource is what pcapgo's pcap and pcapng readers have in common.
+type packetSource interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+// readPcap collects the query names of the DNS queries (not responses) to
+// port 53 in a pcap or pcapng capture, over UDP and, for messages that fit
+// in one segment, TCP.
+func readPcap(path string) (*captureQueries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+		}
+	}()
+
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%s: not a packet capture: %w", path, err)
+	}
+	var src packetSource
+	if bytes.Equal(magic, pcapNgMagic) {
+		src, err = pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+	} else {
+		src, err = pcapgo.NewReader(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	c := &captureQueries{Counts: make(map[string]int)}
+	var order []string
+	for {
+		data, _, err := src.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		payload, ok := dnsPayload(gopacket.NewPacket(data, src.LinkType(), gopacket.DecodeOptions{Lazy: true, NoCopy: true}))
+		if !ok {
+			continue
+		}
+		var m dns.Msg
+		if err := m.Unpack(payload); err != nil {
+			c.Malformed++
+			continue
+		}
+		if m.Response || len(m.Question) == 0 {
+			continue
+		}
+		// Lowercased, as stubs and resolvers may randomize the case
+		name := strings.ToLower(strings.TrimSuffix(m.Question[0].Name, "."))
+		if name == "" {
+			continue
+		}
+		c.Queries++
+		if c.Counts[name] == 0 {
+			order = append(order, name)
+		}
+		c.Counts[name]++
+	}
+
+	c.Domains = order
+	slices.SortStableFunc(c.Domains, func(a, b string) int { return cmp.Compare(c.Counts[b], c.Counts[a]) })
+	return c, nil
+}
+
+// dnsPayload returns the DNS message a packet carries to port 53, if any.
+func dnsPayload(p gopacket.Packet) ([]byte, bool) {
+	if udp, ok := p.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		return udp.Payload, udp.DstPort == 53 && len(udp.Payload) > 0
+	}
+	if tcp, ok := p.Layer(layers.LayerTypeTCP).(*layers.TCP); ok && tcp.DstPort == 53 && len(tcp.Payload) > 2 {
+		// Length-prefixed; messages split over segments are left out
+		if n := int(binary.BigEndian.Uint16(tcp.Payload)); n == len(tcp.Payload)-2 {
+			return tcp.Payload[2:], true
+		}
+	}
+	return nil, false
+}
+
+// captureReport starts the import report for a capture.
+func captureReport(path string, c *captureQueries) importReport {
+	report := importReport{Source: path, Entries: c.Queries, Repeats: c.Queries - len(c.Domains)}
+	if c.Malformed > 0 {
+		report.Entries += c.Malformed
+		report.Excluded = map[string]int{"malformed DNS message": c.Malformed}
+	}
+	return report
+}
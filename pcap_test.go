@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/miekg/dns"
+)
+
+// writeCapture writes a pcap of Ethernet/IPv4 packets carrying payloads to
+// the given ports, UDP unless tcp is set.
+func writeCapture(t *testing.T, packets []capturePacket) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+		ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 53}}
+		var transport gopacket.SerializableLayer
+		if p.tcp {
+			ip.Protocol = layers.IPProtocolTCP
+			tcp := &layers.TCP{SrcPort: 40000, DstPort: layers.TCPPort(p.port), PSH: true, ACK: true, Window: 1024}
+			if err := tcp.SetNetworkLayerForChecksum(ip); err != nil {
+				t.Fatal(err)
+			}
+			transport = tcp
+		} else {
+			ip.Protocol = layers.IPProtocolUDP
+			udp := &layers.UDP{SrcPort: 40000, DstPort: layers.UDPPort(p.port)}
+			if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+				t.Fatal(err)
+			}
+			transport = udp
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, eth, ip, transport, gopacket.Payload(p.payload)); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		ci := gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(data), Length: len(data)}
+		if err := w.WritePacket(ci, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+type capturePacket struct {
+	port    int
+	tcp     bool
+	payload []byte
+}
+
+func packQuery(t *testing.T, name string, response bool) []byte {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	m.Response = response
+	data, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReadPcap(t *testing.T) {
+	tcpQuery := packQuery(t, "example.org", false)
+	tcpQuery = append([]byte{byte(len(tcpQuery) >> 8), byte(len(tcpQuery))}, tcpQuery...)
+	path := writeCapture(t, []capturePacket{
+		{port: 53, payload: packQuery(t, "Example.com", false)},
+		{port: 53, payload: packQuery(t, "example.com", false)},
+		{port: 53, payload: packQuery(t, "example.com", true)}, // response
+		{port: 53, tcp: true, payload: tcpQuery},
+		{port: 53, payload: packQuery(t, "example.com", false)},
+		{port: 5353, payload: packQuery(t, "printer.local", false)}, // mDNS
+		{port: 53, payload: []byte{1, 2, 3}},                        // malformed
+	})
+
+	c, err := readPcap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Queries != 4 || c.Malformed != 1 {
+		t.Errorf("expected 4 queries and 1 malformed payload, got %d and %d", c.Queries, c.Malformed)
+	}
+	if len(c.Domains) != 2 || c.Domains[0] != "example.com" || c.Counts["example.com"] != 3 || c.Counts["example.org"] != 1 {
+		t.Errorf("expected example.com (3) before example.org (1), got %v %v", c.Domains, c.Counts)
+	}
+
+	report := captureReport(path, c)
+	if report.Entries != 5 || report.Repeats != 2 || report.Excluded["malformed DNS message"] != 1 {
+		t.Errorf("unexpected import report: %+v", report)
+	}
+
+	// -pcap-weighted repeats names in a -n run and picks by weight in a -d one
+	config := benchmark.Config{Domains: c.Domains, Iterations: 1}
+	applyWeights(&config, domainWeights(c.Counts, nil))
+	if q := config.Questions; len(q) != 4 || q[0].Name != "example.com" || q[3].Name != "example.org" {
+		t.Errorf("expected example.com three times and example.org once, got %+v", q)
+	}
+	config = benchmark.Config{Domains: c.Domains, Duration: time.Minute}
+	applyWeights(&config, domainWeights(c.Counts, nil))
+	if config.Questions != nil || config.Weights["example.com"] != 3 {
+		t.Errorf("expected a -d run weighted by the counts, got %+v", config)
+	}
+}
+
+func TestReadPcapNotACapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPcap(path); err == nil {
+		t.Error("expected an error for a file that isn't a capture")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+const (
+	// minTailSamples is how many samples have to lie above a percentile
+	// for it to mean anything; with fewer, p99 is just the slowest query or
+	// two. p95 needs 100 samples and p99 500.
+	minTailSamples = 5
+	// bootstrapBelow is the sample count below which percentiles are shown
+	// with a bootstrap confidence interval; above it the interval is narrow
+	// enough to leave out.
+	bootstrapBelow = 1000
+	// bootstrapRounds is the number of resamples behind an interval.
+	bootstrapRounds = 1000
+)
+
+// percentileEstimate is a latency percentile with its 95% bootstrap
+// confidence interval, if one was computed.
+type percentileEstimate struct {
+	Value  time.Duration
+	Lo, Hi time.Duration
+	// Reliable is false when too few samples lie above the percentile
+	Reliable bool
+}
+
+// percentileStats are one server's tail latency estimates.
+type percentileStats struct {
+	Server   string
+	Samples  int
+	P50      time.Duration
+	P95, P99 percentileEstimate
+}
+
+// minSamples returns the sample count percentile p needs to be meaningful.
+func minSamples(p float64) int {
+	return int(math.Ceil(minTailSamples / (1 - p/100)))
+}
+
+// estimatePercentile returns the p-th percentile of sorted and, for small
+// samples, the 2.5th and 97.5th percentiles of its bootstrap distribution.
+func estimatePercentile(sorted []time.Duration, p float64, rng *rand.Rand) percentileEstimate {
+	e := percentileEstimate{Value: percentile(sorted, p), Reliable: len(sorted) >= minSamples(p)}
+	if len(sorted) == 0 || len(sorted) >= bootstrapBelow {
+		return e
+	}
+	estimates := make([]time.Duration, bootstrapRounds)
+	resample := make([]time.Duration, len(sorted))
+	for i := range estimates {
+		for j := range resample {
+			resample[j] = sorted[rng.Intn(len(sorted))]
+		}
+		slices.Sort(resample)
+		estimates[i] = percentile(resample, p)
+	}
+	slices.Sort(estimates)
+	e.Lo, e.Hi = percentile(estimates, 2.5), percentile(estimates, 97.5)
+	return e
+}
+
+// calculatePercentiles estimates p50, p95 and p99 for every server in stats
+// order that answered any query. The resampling is seeded, so the same
+// results always give the same intervals.
+func calculatePercentiles(stats []*ServerStats, results []benchmark.Result) []percentileStats {
+	durations := serverDurations(results)
+	//nolint:gosec // G404: math/rand is sufficient for resampling
+	rng := rand.New(rand.NewSource(1))
+	var out []percentileStats
+	for _, s := range stats {
+		sorted := durations[s.Server]
+		if len(sorted) == 0 {
+			continue
+		}
+		out = append(out, percentileStats{
+			Server:  s.Server,
+			Samples: len(sorted),
+			P50:     percentile(sorted, 50),
+			P95:     estimatePercentile(sorted, 95, rng),
+			P99:     estimatePercentile(sorted, 99, rng),
+		})
+	}
+	return out
+}
+
+// String formats the estimate with its interval, marking an unreliable one
+// with an asterisk.
+func (e percentileEstimate) String() string {
+	return e.format(latencyFormat{})
+}
+
+// format is String with the latencies in a chosen unit. Go duration
+// strings are rounded to 10µs, numbers to the format's precision.
+func (e percentileEstimate) format(latency latencyFormat) string {
+	cell := func(d time.Duration) string {
+		if latency.set() {
+			return latency.cell(d)
+		}
+		return latency.cell(d.Round(10 * time.Microsecond))
+	}
+	s := cell(e.Value)
+	if e.Hi > 0 {
+		s += fmt.Sprintf(" (%s–%s)", cell(e.Lo), cell(e.Hi))
+	}
+	if !e.Reliable {
+		s += " *"
+	}
+	return s
+}
+
+// printPercentiles shows each server's tail latency, with confidence
+// intervals for small samples and a warning for servers with too few
+// samples for p95 or p99 to mean much.
+func printPercentiles(checks []percentileStats, latency latencyFormat) {
+	if len(checks) == 0 {
+		return
+	}
+
+	fmt.Printf("\nLatency Percentiles (95%% confidence interval for small samples)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SERVER\tSAMPLES\t%s\t%s\t%s\n", latency.header("P50"), latency.header("P95"), latency.header("P99")); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var few95, few99 []string
+	for _, c := range checks {
+		p50 := percentileEstimate{Value: c.P50, Reliable: true}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", c.Server, c.Samples, p50.format(latency), c.P95.format(latency), c.P99.format(latency)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+		switch {
+		case !c.P95.Reliable:
+			few95 = append(few95, c.Server)
+		case !c.P99.Reliable:
+			few99 = append(few99, c.Server)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(few95) > 0 {
+		fmt.Printf("⚠️  * Fewer than %d answered queries, too few for a meaningful p95 or p99: %s\n", minSamples(95), strings.Join(few95, ", "))
+	}
+	if len(few99) > 0 {
+		fmt.Printf("⚠️  * Fewer than %d answered queries, too few for a meaningful p99: %s\n", minSamples(99), strings.Join(few99, ", "))
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestCalculatePercentiles(t *testing.T) {
+	var results []benchmark.Result
+	for i := range 200 {
+		results = append(results, benchmark.Result{Server: "small", Duration: time.Duration(i+1) * time.Millisecond})
+	}
+	for i := range 2000 {
+		results = append(results, benchmark.Result{Server: "large", Duration: time.Duration(i%100+1) * time.Millisecond})
+	}
+	results = append(results, benchmark.Result{Server: "failed", Error: os.ErrDeadlineExceeded})
+
+	checks := calculatePercentiles(calculateStats(results), results)
+	byServer := make(map[string]percentileStats)
+	for _, c := range checks {
+		byServer[c.Server] = c
+	}
+	if _, ok := byServer["failed"]; ok || len(checks) != 2 {
+		t.Fatalf("expected only servers with answers, got %+v", checks)
+	}
+
+	small := byServer["small"]
+	if small.P95.Value != 190*time.Millisecond || !small.P95.Reliable || small.P99.Reliable {
+		t.Errorf("small: expected a reliable p95 of 190ms and an unreliable p99, got %+v", small)
+	}
+	if small.P95.Lo > small.P95.Value || small.P95.Hi < small.P95.Value || small.P95.Lo == small.P95.Hi {
+		t.Errorf("small: expected an interval around p95, got %+v", small.P95)
+	}
+	large := byServer["large"]
+	if !large.P99.Reliable || large.P99.Hi != 0 {
+		t.Errorf("large: expected a reliable p99 without an interval, got %+v", large.P99)
+	}
+}
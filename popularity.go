@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// popularTier is the highest rank still counted as a popular domain;
+// everything ranked below it is the long tail.
+const popularTier = 100
+
+// popularityStats compares a server's latency for popular domains, which
+// every resolver should have cached, with the long tail, where a small or
+// short-lived cache shows up as extra latency.
+type popularityStats struct {
+	TopCount  int
+	TailCount int
+	AvgTop    time.Duration
+	AvgTail   time.Duration
+
+	top, tail time.Duration
+}
+
+func (p *popularityStats) add(res benchmark.Result, rank int) {
+	if rank <= popularTier {
+		p.TopCount++
+		p.top += res.Duration
+		return
+	}
+	p.TailCount++
+	p.tail += res.Duration
+}
+
+func (p *popularityStats) finish() {
+	if p.TopCount > 0 {
+		p.AvgTop = p.top / time.Duration(p.TopCount)
+	}
+	if p.TailCount > 0 {
+		p.AvgTail = p.tail / time.Duration(p.TailCount)
+	}
+}
+
+// penalty is how much slower the long tail is than the popular domains.
+func (p *popularityStats) penalty() time.Duration {
+	if p.TopCount == 0 || p.TailCount == 0 {
+		return 0
+	}
+	return p.AvgTail - p.AvgTop
+}
+
+// rankByVisits turns visit counts into ranks, most visited first. Ties keep
+// the order the domains were read in.
+func rankByVisits(domains []string, visits map[string]int) map[string]int {
+	if len(visits) == 0 {
+		return nil
+	}
+	ordered := append([]string(nil), domains...)
+	sort.SliceStable(ordered, func(i, j int) bool { return visits[ordered[i]] > visits[ordered[j]] })
+	ranks := make(map[string]int, len(ordered))
+	for i, d := range ordered {
+		ranks[strings.ToLower(d)] = i + 1
+	}
+	return ranks
+}
+
+// mostVisited returns the most visited of domains and its share of the
+// visits to all of them, in percent.
+func mostVisited(domains []string, visits map[string]int) (string, float64) {
+	top, total := "", 0
+	for _, d := range domains {
+		if top == "" || visits[d] > visits[top] {
+			top = d
+		}
+		total += visits[d]
+	}
+	if total == 0 {
+		return top, 0
+	}
+	return top, float64(visits[top]) / float64(total) * 100
+}
+
+// maxWeightRepeats caps how many times the heaviest name of a weighted -n
+// run is queried per iteration, relative to the lightest.
+const maxWeightRepeats = 50
+
+// applyWeights makes the run query each name in proportion to its weight
+// (see domainWeights), whether it runs for -d or -n: a -d run picks its
+// questions by config.Weights, and a -n run asks each question per iteration
+// as often as weightedQuestions says.
+func applyWeights(config *benchmark.Config, weights map[string]float64) {
+	if config.Duration > 0 {
+		config.Weights = weights
+		return
+	}
+	questions := config.Questions
+	if len(questions) == 0 {
+		questions = make([]benchmark.Question, len(config.Domains))
+		for i, d := range config.Domains {
+			questions[i] = benchmark.Question{Name: d}
+		}
+	}
+	config.Questions = weightedQuestions(questions, weights)
+}
+
+// weightedQuestions repeats each question in proportion to the weight of its
+// name, the lightest once, capped at maxWeightRepeats. Names without a
+// weight are asked once, as questionPicker counts them as 1.
+func weightedQuestions(questions []benchmark.Question, weights map[string]float64) []benchmark.Question {
+	lightest := math.Inf(1)
+	for _, q := range questions {
+		if w := weights[q.Name]; w > 0 {
+			lightest = min(lightest, w)
+		}
+	}
+	var weighted []benchmark.Question
+	for _, q := range questions {
+		repeats := 1
+		if w := weights[q.Name]; w > 0 {
+			repeats = min(max(int(math.Round(w/lightest)), 1), maxWeightRepeats)
+		}
+		for range repeats {
+			weighted = append(weighted, q)
+		}
+	}
+	return weighted
+}
+
+// popularityByServer splits every server's successful queries by the rank
+// of the domain. Domains without a rank are left out.
+func popularityByServer(results []benchmark.Result, ranks map[string]int) map[string]*popularityStats {
+	byServer := make(map[string]*popularityStats)
+	for _, res := range results {
+		if res.Error != nil {
+			continue
+		}
+		rank, ok := ranks[res.Domain]
+		if !ok {
+			continue
+		}
+		p, ok := byServer[res.Server]
+		if !ok {
+			p = &popularityStats{}
+			byServer[res.Server] = p
+		}
+		p.add(res, rank)
+	}
+	for _, p := range byServer {
+		p.finish()
+	}
+	return byServer
+}
+
+// printPopularity shows, per server, latency for the top-ranked domains
+// against the long tail and names the resolver whose cache falls off most.
+func printPopularity(stats []*ServerStats, results []benchmark.Result, ranks map[string]int) {
+	if len(ranks) == 0 {
+		return
+	}
+	byServer := popularityByServer(results, ranks)
+	if len(byServer) == 0 {
+		return
+	}
+
+	fmt.Printf("\nLatency by Domain Popularity (top %d vs. long tail)\n\n", popularTier)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SERVER\tTOP %d\tLONG TAIL\tTAIL PENALTY\n", popularTier); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var weakest string
+	var worst time.Duration
+	for _, s := range stats {
+		p, ok := byServer[s.Server]
+		if !ok {
+			continue
+		}
+		penalty := "-"
+		if p.TopCount > 0 && p.TailCount > 0 {
+			penalty = fmt.Sprintf("%+v", p.penalty())
+			if p.penalty() > worst {
+				weakest, worst = s.Server, p.penalty()
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Server, orDash(p.AvgTop), orDash(p.AvgTail), penalty); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if weakest != "" {
+		fmt.Printf("⚠️  %s is %v slower on less popular names, the weakest cache for the long tail\n", weakest, worst)
+	}
+}
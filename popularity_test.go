@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestRankByVisits(t *testing.T) {
+	ranks := rankByVisits([]string{"a.com", "b.com", "c.com"}, map[string]int{"a.com": 1, "b.com": 7, "c.com": 1})
+	if ranks["b.com"] != 1 || ranks["a.com"] != 2 || ranks["c.com"] != 3 {
+		t.Errorf("expected b.com, a.com, c.com, got %v", ranks)
+	}
+	if rankByVisits([]string{"a.com"}, nil) != nil {
+		t.Error("expected no ranks without visit counts")
+	}
+}
+
+func TestPopularityByServer(t *testing.T) {
+	ranks := map[string]int{"top.com": 1, "tail.com": 5000}
+	results := []benchmark.Result{
+		{Server: "s", Domain: "top.com", Duration: 2 * time.Millisecond},
+		{Server: "s", Domain: "tail.com", Duration: 40 * time.Millisecond},
+		{Server: "s", Domain: "tail.com", Duration: 20 * time.Millisecond},
+		{Server: "s", Domain: "tail.com", Error: errors.New("timeout")},
+		{Server: "s", Domain: "unranked.com", Duration: time.Second},
+	}
+	p := popularityByServer(results, ranks)["s"]
+
+	if p.TopCount != 1 || p.TailCount != 2 {
+		t.Fatalf("expected 1 top and 2 tail queries, got %+v", p)
+	}
+	if p.penalty() != 28*time.Millisecond {
+		t.Errorf("expected a 28ms tail penalty, got %v", p.penalty())
+	}
+}
+
+func TestMostVisited(t *testing.T) {
+	top, share := mostVisited([]string{"a.com", "b.com", "c.com"}, map[string]int{"a.com": 1, "b.com": 6, "c.com": 1, "other.com": 100})
+	if top != "b.com" || share != 75 {
+		t.Errorf("expected b.com with 75%% of visits, got %s %.1f%%", top, share)
+	}
+}
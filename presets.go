@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/netenv"
+
+	"github.com/miekg/dns"
+)
+
+// serverRole labels a server with the part it plays in a preset's
+// comparison, e.g. "cluster DNS" or "upstream".
+type serverRole struct {
+	Server string
+	Role   string
+}
+
+// preset is a ready-made workload for a common question. apply fills in
+// whatever the config file left unset (flags still override it afterwards)
+// and returns the roles of the servers it chose; baseline names the role
+// every other role is compared against. summarize, if set, prints a verdict
+// after the role comparison.
+type preset struct {
+	Description string
+	Baseline    string
+	apply       func(cfg *Config, warns *warningLog) []serverRole
+	summarize   func(stats []*ServerStats, roles []serverRole)
+}
+
+var presets = map[string]preset{
+	"k8s": {
+		Description: "In-cluster Kubernetes DNS: service names, ndots:5 search list, cluster DNS vs node-local cache vs upstream",
+		Baseline:    "cluster DNS",
+		apply:       applyK8sPreset,
+	},
+	"router": {
+		Description: "LAN gateway's DNS forwarder vs querying upstream resolvers directly, cold and cached",
+		Baseline:    "upstream",
+		apply:       applyRouterPreset,
+		summarize:   summarizeRouter,
+	},
+	"tor": {
+		Description: "DoH, DoT and plain DNS through Tor's SOCKS port, as seen from a Tor exit",
+		Baseline:    "DoH",
+		apply:       applyTorPreset,
+	},
+}
+
+// presetNames lists the available presets for help and error messages.
+func presetNames() string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+const (
+	// kubeadmClusterDNS is the kube-dns Service IP in a default kubeadm cluster.
+	kubeadmClusterDNS = "10.96.0.10"
+	// nodeLocalDNS is the link-local address NodeLocal DNSCache listens on.
+	nodeLocalDNS = "169.254.20.10"
+	// k8sNdots is the ndots value kubelet writes into pod resolv.conf files.
+	k8sNdots = 5
+)
+
+// applyK8sPreset configures a run for debugging DNS latency from inside a
+// pod. When /etc/resolv.conf looks like a pod's, its nameserver and search
+// list are used; otherwise kubeadm defaults are assumed.
+func applyK8sPreset(cfg *Config, warns *warningLog) []serverRole {
+	clusterDNS := kubeadmClusterDNS
+	clusterDomain := "cluster.local"
+	search := []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local"}
+	ndots := k8sNdots
+	if conf, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(conf.Servers) > 0 {
+		for _, s := range conf.Search {
+			if strings.HasPrefix(s, "svc.") {
+				clusterDNS = conf.Servers[0]
+				clusterDomain = strings.TrimPrefix(s, "svc.")
+				search = conf.Search
+				ndots = conf.Ndots
+				break
+			}
+		}
+	}
+
+	roles := []serverRole{
+		{clusterDNS, "cluster DNS"},
+		{nodeLocalDNS, "node-local cache"},
+		{"8.8.8.8", "upstream"},
+		{"1.1.1.1", "upstream"},
+	}
+	if len(cfg.Servers) == 0 {
+		for _, r := range roles {
+			cfg.Servers = append(cfg.Servers, serverEntry{Address: r.Server})
+		}
+	}
+	if len(cfg.Domains) == 0 {
+		cfg.Domains = []string{
+			// Service names, resolved through the search list
+			"kubernetes",
+			"kubernetes.default",
+			"kube-dns.kube-system",
+			// Fully qualified, so only one query is needed
+			"kubernetes.default.svc." + clusterDomain + ".",
+			// External names, which pay for every search suffix first
+			"google.com",
+			"github.com",
+			"api.github.com",
+			"registry-1.docker.io",
+		}
+	}
+	if len(cfg.SearchDomains) == 0 {
+		cfg.SearchDomains = search
+	}
+	if cfg.Ndots == nil {
+		cfg.Ndots = &ndots
+	}
+	return roles
+}
+
+// routerIterations is how often the router preset repeats each domain, so
+// every name is measured once cold and several times from cache.
+const routerIterations = 5
+
+// applyRouterPreset compares the default gateway, which on home networks is
+// usually a caching DNS forwarder, with well-known public resolvers.
+func applyRouterPreset(cfg *Config, warns *warningLog) []serverRole {
+	var roles []serverRole
+	if gw := netenv.Detect().Gateway; gw != "" {
+		roles = append(roles, serverRole{gw, "router"})
+	} else {
+		warns.add("preset", "no default gateway found; comparing upstream resolvers only")
+	}
+	roles = append(roles,
+		serverRole{"8.8.8.8", "upstream"},
+		serverRole{"1.1.1.1", "upstream"},
+		serverRole{"9.9.9.9", "upstream"},
+	)
+
+	if len(cfg.Servers) == 0 {
+		for _, r := range roles {
+			cfg.Servers = append(cfg.Servers, serverEntry{Address: r.Server})
+		}
+	}
+	if cfg.Iterations == 0 {
+		cfg.Iterations = routerIterations
+	}
+	return roles
+}
+
+// summarizeRouter states the router's forwarding overhead (first lookups,
+// which it has to forward) and the effect of its cache (repeated lookups)
+// relative to the fastest upstream resolver.
+func summarizeRouter(stats []*ServerStats, roles []serverRole) {
+	var router *ServerStats
+	var bestCold, bestWarm time.Duration
+	byServer := make(map[string]*ServerStats, len(stats))
+	for _, s := range stats {
+		byServer[s.Server] = s
+	}
+	for _, r := range roles {
+		s, ok := byServer[r.Server]
+		if !ok || s.Success == 0 {
+			continue
+		}
+		switch r.Role {
+		case "router":
+			router = s
+		case "upstream":
+			if bestCold == 0 || (s.Cache.AvgCold > 0 && s.Cache.AvgCold < bestCold) {
+				bestCold = s.Cache.AvgCold
+			}
+			if bestWarm == 0 || (s.Cache.AvgWarm > 0 && s.Cache.AvgWarm < bestWarm) {
+				bestWarm = s.Cache.AvgWarm
+			}
+		}
+	}
+	if router == nil || bestCold == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("Router forwarding overhead (first lookups): %+v vs. fastest upstream\n", router.Cache.AvgCold-bestCold)
+	if router.Cache.AvgWarm > 0 && bestWarm > 0 {
+		diff := router.Cache.AvgWarm - bestWarm
+		verdict := "the router's cache is faster than going upstream"
+		if diff > 0 {
+			verdict = "going upstream directly is faster even for cached names"
+		}
+		fmt.Printf("Router cache effect (repeat lookups): %+v vs. fastest upstream (%s)\n", diff, verdict)
+	}
+}
+
+// Tor circuits add hundreds of milliseconds and don't like bursts, so the
+// tor preset waits longer and sends fewer queries at once.
+const (
+	torSOCKS       = "socks5h://127.0.0.1:9050" // tor daemon; Tor Browser listens on 9150
+	torTimeout     = 10 * time.Second
+	torConcurrency = 8
+)
+
+// applyTorPreset measures public resolvers through a local Tor client. Each
+// query leaves the Tor network at an exit relay, so the numbers include the
+// circuit and reflect what anonymized resolution actually costs.
+func applyTorPreset(cfg *Config, warns *warningLog) []serverRole {
+	roles := []serverRole{
+		{"https://cloudflare-dns.com/dns-query", "DoH"},
+		{"https://dns.google/dns-query", "DoH"},
+		{"https://dns.quad9.net/dns-query", "DoH"},
+		{"tls://1.1.1.1", "DoT"},
+		{"tls://9.9.9.9", "DoT"},
+		{"1.1.1.1", "DNS over TCP"},
+		{"8.8.8.8", "DNS over TCP"},
+	}
+
+	if len(cfg.Servers) == 0 {
+		for _, r := range roles {
+			cfg.Servers = append(cfg.Servers, serverEntry{Address: r.Server})
+		}
+	}
+	if cfg.Proxy == "" {
+		cfg.Proxy = torSOCKS
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = torTimeout
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = torConcurrency
+	}
+	return roles
+}
+
+// printRoles compares the average latency of each preset role with the
+// baseline role's fastest server. Upstream resolvers can't answer cluster
+// names, so their rows are only meaningful for the external domains.
+func printRoles(stats []*ServerStats, roles []serverRole, baseline string) {
+	byServer := make(map[string]*ServerStats, len(stats))
+	for _, s := range stats {
+		byServer[s.Server] = s
+	}
+
+	var base time.Duration
+	for _, r := range roles {
+		if s, ok := byServer[r.Server]; ok && r.Role == baseline && s.Success > 0 && (base == 0 || s.Avg < base) {
+			base = s.Avg
+		}
+	}
+
+	fmt.Printf("\nRole Comparison (vs. %s)\n\n", baseline)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "ROLE\tSERVER\tAVG LATENCY\tFIRST LOOKUP\tREPEAT LOOKUP\tLOSS %\tDIFFERENCE"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range roles {
+		s, ok := byServer[r.Server]
+		if !ok {
+			continue
+		}
+		diff := "-"
+		switch {
+		case s.Success == 0:
+			diff = "unreachable"
+		case r.Role != baseline && base > 0:
+			diff = fmt.Sprintf("%+v (%+.0f%%)", s.Avg-base, (float64(s.Avg)/float64(base)-1)*100)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\t%.2f%%\t%s\n", r.Role, r.Server, s.Avg,
+			orDash(s.Cache.AvgCold), orDash(s.Cache.AvgWarm), s.LossPct, diff); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
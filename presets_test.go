@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestApplyK8sPreset(t *testing.T) {
+	cfg := &Config{}
+	roles := presets["k8s"].apply(cfg, nil)
+
+	if len(cfg.Servers) != len(roles) || cfg.Servers[1].Address != nodeLocalDNS {
+		t.Errorf("expected the preset servers, got %v", cfg.Servers)
+	}
+	if len(cfg.Domains) == 0 || len(cfg.SearchDomains) == 0 || cfg.Ndots == nil {
+		t.Errorf("expected domains, search list and ndots to be set, got %+v", cfg)
+	}
+	if roles[0].Role != presets["k8s"].Baseline {
+		t.Errorf("expected cluster DNS first, got %+v", roles[0])
+	}
+}
+
+func TestApplyK8sPresetKeepsConfig(t *testing.T) {
+	two := 2
+	cfg := &Config{Servers: serverEntries([]string{"10.0.0.10"}), Domains: []string{"web"}, SearchDomains: []string{"corp.local"}, Ndots: &two}
+	presets["k8s"].apply(cfg, nil)
+
+	if len(cfg.Servers) != 1 || len(cfg.Domains) != 1 || cfg.SearchDomains[0] != "corp.local" || *cfg.Ndots != 2 {
+		t.Errorf("expected configured values to be kept, got %+v", cfg)
+	}
+}
+
+func TestPrintRoles(_ *testing.T) {
+	stats := []*ServerStats{
+		{Server: "10.96.0.10", Success: 10, Total: 10, Avg: 4 * time.Millisecond},
+		{Server: nodeLocalDNS, Success: 10, Total: 10, Avg: time.Millisecond},
+		{Server: "8.8.8.8", Total: 10, Errors: 10, LossPct: 100},
+	}
+	roles := []serverRole{
+		{"10.96.0.10", "cluster DNS"},
+		{nodeLocalDNS, "node-local cache"},
+		{"8.8.8.8", "upstream"},
+		{"1.1.1.1", "upstream"}, // Not benchmarked, so skipped
+	}
+	printRoles(stats, roles, "cluster DNS")
+}
+
+func TestApplyRouterPreset(t *testing.T) {
+	cfg := &Config{}
+	roles := presets["router"].apply(cfg, nil)
+
+	if len(cfg.Servers) != len(roles) || cfg.Iterations != routerIterations {
+		t.Errorf("expected the preset servers and repeats, got %+v", cfg)
+	}
+	if roles[len(roles)-1].Role != presets["router"].Baseline {
+		t.Errorf("expected upstream resolvers last, got %+v", roles)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	var c cacheStats
+	for _, d := range []time.Duration{30, 2, 4} {
+		c.add(benchmark.Result{Domain: "example.com", Duration: d * time.Millisecond})
+	}
+	c.add(benchmark.Result{Domain: "example.org", Duration: 10 * time.Millisecond})
+	c.finish()
+
+	if c.AvgCold != 20*time.Millisecond || c.AvgWarm != 3*time.Millisecond {
+		t.Errorf("expected 20ms cold and 3ms warm, got %v and %v", c.AvgCold, c.AvgWarm)
+	}
+}
+
+func TestSummarizeRouter(_ *testing.T) {
+	stats := []*ServerStats{
+		{Server: "192.168.1.1", Success: 10, Cache: cacheStats{AvgCold: 25 * time.Millisecond, AvgWarm: time.Millisecond}},
+		{Server: "1.1.1.1", Success: 10, Cache: cacheStats{AvgCold: 15 * time.Millisecond, AvgWarm: 12 * time.Millisecond}},
+	}
+	roles := []serverRole{{"192.168.1.1", "router"}, {"1.1.1.1", "upstream"}}
+	summarizeRouter(stats, roles)
+}
+
+func TestApplyTorPreset(t *testing.T) {
+	cfg := &Config{}
+	roles := presets["tor"].apply(cfg, nil)
+
+	if len(cfg.Servers) != len(roles) || cfg.Proxy != torSOCKS || cfg.Timeout != torTimeout {
+		t.Errorf("expected the preset servers over Tor's SOCKS port, got %+v", cfg)
+	}
+
+	cfg = &Config{Proxy: "socks5://127.0.0.1:9150", Timeout: time.Second}
+	presets["tor"].apply(cfg, nil)
+	if cfg.Proxy != "socks5://127.0.0.1:9150" || cfg.Timeout != time.Second {
+		t.Errorf("expected configured values to be kept, got %+v", cfg)
+	}
+}
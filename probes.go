@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// printWireProbes runs the wire-format probe suite against every server and
+// lists, per server, how many probes passed and what went wrong with the rest.
+func printWireProbes(config benchmark.Config) {
+	fmt.Printf("\nRunning %d wire-format probes per server...\n", len(benchmark.WireProbes))
+	byServer := benchmark.RunWireProbes(config)
+
+	fmt.Printf("\nWire-Format Probes\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tOK\tERROR\tDROPPED\tMISHANDLED\tPROBLEMS"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, server := range config.Servers {
+		results := byServer[server]
+		counts := make(map[string]int)
+		var problems []string
+		for _, res := range results {
+			counts[res.Outcome]++
+			if res.Outcome == benchmark.ProbeOK {
+				continue
+			}
+			problem := res.Probe + " " + res.Outcome
+			if res.Detail != "" {
+				problem += " (" + res.Detail + ")"
+			}
+			problems = append(problems, problem)
+		}
+		// When even the plain query fails, the other failures say nothing
+		// about how the server handles the probes
+		if len(results) > 0 && results[0].Outcome != benchmark.ProbeOK {
+			problems = problems[:1]
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", server,
+			counts[benchmark.ProbeOK], counts[benchmark.ProbeError], counts[benchmark.ProbeDropped],
+			counts[benchmark.ProbeMishandled], orNone(strings.Join(problems, "; "))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	fmt.Println()
+	for _, p := range benchmark.WireProbes {
+		fmt.Printf("  %-18s %s\n", p.Name, p.Description)
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
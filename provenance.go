@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Version is set at build time with -ldflags "-X main.Version=...".
+var Version = "dev"
+
+// provenance records how a run was produced: the tool version, the command
+// line and the fully resolved configuration after merging the config file,
+// flags and defaults. It is embedded in every export so a report can be
+// reproduced long after the fact.
+type provenance struct {
+	// RunID identifies the run, so merging exports can skip runs already
+	// included; Host is the machine it ran on.
+	RunID       string    `yaml:"run_id"`
+	Host        string    `yaml:"host,omitempty"`
+	Version     string    `yaml:"version"`
+	GeneratedAt time.Time `yaml:"generated_at"`
+	Args        []string  `yaml:"args"`
+	Network     string    `yaml:"network"`
+	// Config inlines the resolved server and domain lists, so it can be saved
+	// and passed back with -config to repeat the run.
+	Config Config `yaml:"config"`
+	// Warnings are the problems the run ran into, such as dropped domains
+	Warnings []runWarning `yaml:"warnings,omitempty"`
+	// Probe and Tags attribute the run to the fleet probe it ran as (see
+	// ProbeConfig); TotalTime is how long the benchmark took
+	Probe     string            `yaml:"probe,omitempty"`
+	Tags      map[string]string `yaml:"tags,omitempty"`
+	TotalTime time.Duration     `yaml:"total_time,omitempty"`
+}
+
+// newProvenance snapshots the effective configuration of a run. Server and
+// domain files are replaced by the lists they resolved to, and settings
+// decided at run time (such as the auto-tuned concurrency) are filled in.
+func newProvenance(cfg *Config, config benchmark.Config) *provenance {
+	effective := *cfg
+	effective.Servers = specEntries(config.Servers, config.Specs)
+	effective.Domains = config.Domains
+	effective.ServerFile = ""
+	effective.DomainFile = ""
+	effective.PcapFile = ""
+	effective.BrowserName = ""
+	effective.BrowserProfile = ""
+	effective.Concurrency = config.Concurrency
+	effective.AutoConcurrency = false
+	// The fleet's other probes and upload tokens don't belong in every
+	// export; the probe the run was attributed to is kept
+	effective.Probes = nil
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = ""
+	}
+	return &provenance{
+		RunID:       cmp.Or(os.Getenv(runIDEnv), newRunID()),
+		Host:        host,
+		Version:     Version,
+		GeneratedAt: time.Now().UTC(),
+		Args:        os.Args[1:],
+		Network:     config.Network,
+		Config:      effective,
+	}
+}
+
+// runIDEnv names the environment variable that sets the run ID instead of
+// a random one; monitor sets it for its scheduled runs to read them back.
+const runIDEnv = "DNS_BENCH_RUN_ID"
+
+// newRunID returns a random identifier for a run.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the clock; IDs only need to differ between runs
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// YAML renders the snapshot; the config section is valid input for -config.
+func (p *provenance) YAML() string {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(p); err != nil {
+		return fmt.Sprintf("# failed to encode configuration: %v\n", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Sprintf("# failed to encode configuration: %v\n", err)
+	}
+	return b.String()
+}
+
+// MarshalJSON encodes the snapshot with the same keys and duration format as
+// the YAML form, rather than Go field names and nanoseconds.
+func (p *provenance) MarshalJSON() ([]byte, error) {
+	var m map[string]any
+	if err := yaml.Unmarshal([]byte(p.YAML()), &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// commentLines prefixes every line of the YAML snapshot with "# " for
+// embedding above a CSV header.
+func (p *provenance) commentLines() []string {
+	lines := strings.Split(strings.TrimRight(p.YAML(), "\n"), "\n")
+	out := make([]string, 0, len(lines)+1)
+	out = append(out, "# dns-bench run provenance")
+	for _, l := range lines {
+		out = append(out, "# "+l)
+	}
+	return out
+}
+
+// jsonExport is the layout of a JSON export.
+// Merged exports list the provenance of every run they contain under runs
+// instead. LatencyUnit is set when the run chose one with -unit: the
+// results' latency fields are then named after it, e.g. duration_us.
+type jsonExport struct {
+	SchemaVersion int                `json:"schema_version"`
+	Provenance    *provenance        `json:"provenance,omitempty"`
+	Runs          []json.RawMessage  `json:"runs,omitempty"`
+	LatencyUnit   string             `json:"latency_unit,omitempty"`
+	Results       []benchmark.Result `json:"results"`
+}
+
+// exportJSON writes the provenance snapshot and every raw result to path,
+// with the latencies in the run's unit and precision.
+func exportJSON(results []benchmark.Result, prov *provenance, path string) error {
+	var latency latencyFormat
+	if prov != nil {
+		latency = configLatencyFormat(prov.Config)
+	}
+	export := jsonExport{
+		SchemaVersion: schemaVersion,
+		Provenance:    prov,
+		Results:       results,
+	}
+	if !latency.set() {
+		return writeJSONExport(export, path)
+	}
+
+	export.LatencyUnit = latency.unit
+	converted := make([]json.RawMessage, len(results))
+	for i, res := range results {
+		data, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		if converted[i], err = relabelLatencies(data, "ms", latency.unit, latency.precision); err != nil {
+			return err
+		}
+	}
+	return writeJSONExport(struct {
+		jsonExport
+		Results []json.RawMessage `json:"results"`
+	}{export, converted}, path)
+}
+
+// writeJSONExport writes export to path as indented JSON.
+func writeJSONExport(export any, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+		}
+	}()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"gopkg.in/yaml.v3"
+)
+
+func testProvenance() *provenance {
+	cfg := &Config{Concurrency: 50, Iterations: 3, Timeout: 2 * time.Second, DomainFile: "domains.csv", AutoConcurrency: true}
+	return newProvenance(cfg, benchmark.Config{
+		Servers:     []string{"8.8.8.8", "tls://1.1.1.1"},
+		Domains:     []string{"example.com"},
+		Concurrency: 16,
+		Network:     "eth0",
+	})
+}
+
+func TestNewProvenanceResolvesConfig(t *testing.T) {
+	prov := testProvenance()
+	if prov.Config.DomainFile != "" || len(prov.Config.Domains) != 1 || len(prov.Config.Servers) != 2 {
+		t.Errorf("expected resolved server and domain lists to replace files, got %+v", prov.Config)
+	}
+	if prov.Config.Concurrency != 16 || prov.Config.AutoConcurrency {
+		t.Errorf("expected the tuned concurrency to be recorded, got %d (auto %v)", prov.Config.Concurrency, prov.Config.AutoConcurrency)
+	}
+
+	// The config section must be usable with -config to repeat the run
+	var snapshot struct {
+		Config Config `yaml:"config"`
+	}
+	if err := yaml.Unmarshal([]byte(prov.YAML()), &snapshot); err != nil {
+		t.Fatalf("failed to parse snapshot: %v", err)
+	}
+	if snapshot.Config.Timeout != 2*time.Second || snapshot.Config.Iterations != 3 {
+		t.Errorf("config did not round trip: %+v", snapshot.Config)
+	}
+}
+
+func TestExportCSVProvenance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	results := []benchmark.Result{{Server: "8.8.8.8", Domain: "example.com", Duration: 10 * time.Millisecond}}
+	if err := exportCSV(results, testProvenance(), path); err != nil {
+		t.Fatalf("exportCSV failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("CSV with provenance comments failed to parse: %v", err)
+	}
+	if len(records) != 2 || records[0][0] != "Server" {
+		t.Errorf("expected header and one row after the comments, got %v", records)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# dns-bench run provenance\n") || !strings.Contains(string(data), "#     - tls://1.1.1.1\n") {
+		t.Errorf("expected the configuration as comment lines, got:\n%s", data)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	results := []benchmark.Result{{Server: "8.8.8.8", Domain: "example.com", Duration: 10 * time.Millisecond}}
+	if err := exportJSON(results, testProvenance(), path); err != nil {
+		t.Fatalf("exportJSON failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		SchemaVersion int `json:"schema_version"`
+		Provenance    struct {
+			Version string         `json:"version"`
+			Config  map[string]any `json:"config"`
+		} `json:"provenance"`
+		Results []benchmark.Result `json:"results"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("invalid JSON export: %v", err)
+	}
+	if out.SchemaVersion != schemaVersion {
+		t.Errorf("expected schema_version %d, got %d", schemaVersion, out.SchemaVersion)
+	}
+	if out.Provenance.Version != Version || out.Provenance.Config["timeout"] != "2s" {
+		t.Errorf("expected version and YAML-style config, got %+v", out.Provenance)
+	}
+	if len(out.Results) != 1 || out.Results[0].Duration != 10*time.Millisecond {
+		t.Errorf("expected results to round trip, got %+v", out.Results)
+	}
+}
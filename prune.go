@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"dns-bench/dashboard"
+)
+
+// RetentionConfig limits how much history a results directory keeps.
+type RetentionConfig struct {
+	KeepRuns int `yaml:"keep_runs"`
+	KeepDays int `yaml:"keep_days"`
+}
+
+// runPrune implements the prune subcommand, which applies the retention
+// policy to a results directory written by the scheduled entrypoint.
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	var (
+		configFile string
+		keepRuns   int
+		keepDays   int
+		dryRun     bool
+	)
+	fs.StringVar(&configFile, "config", "", "Path to config file (YAML) with a retention section")
+	fs.IntVar(&keepRuns, "keep-runs", 0, "Keep only the newest N runs")
+	fs.IntVar(&keepDays, "keep-days", 0, "Keep only runs from the last M days")
+	fs.BoolVar(&dryRun, "dry-run", false, "List what would be removed without deleting anything")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s prune [options] <results-dir>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one results directory")
+	}
+	dir := fs.Arg(0)
+
+	var retention RetentionConfig
+	if configFile == "" {
+		configFile = findConfigFile()
+	}
+	if configFile != "" {
+		cfg, err := loadConfigFile(configFile)
+		if err != nil {
+			return fmt.Errorf("loading config file: %w", err)
+		}
+		retention = cfg.Retention
+	}
+	if keepRuns > 0 {
+		retention.KeepRuns = keepRuns
+	}
+	if keepDays > 0 {
+		retention.KeepDays = keepDays
+	}
+	if retention.KeepRuns <= 0 && retention.KeepDays <= 0 {
+		return fmt.Errorf("no retention configured: set -keep-runs, -keep-days or retention in the config file")
+	}
+
+	res, err := dashboard.Prune(dir, dashboard.Retention{KeepRuns: retention.KeepRuns, KeepDays: retention.KeepDays}, time.Now().UTC(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	for _, f := range res.Files {
+		fmt.Printf("%s %s\n", verb, f)
+	}
+	fmt.Printf("%s %d run(s), %d file(s) and %d history row(s)\n", verb, len(res.Runs), len(res.Files), res.HistoryRows)
+	return nil
+}
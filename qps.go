@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parseServerQPS parses per-server query rate limits written as
+// server=qps, e.g. "8.8.8.8=10". The rate follows the last "=", since
+// DoH URLs may contain one.
+func parseServerQPS(specs []string) (map[string]float64, error) {
+	limits := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid server rate limit %q (expected server=qps)", spec)
+		}
+		qps, err := strconv.ParseFloat(spec[i+1:], 64)
+		if err != nil || qps <= 0 {
+			return nil, fmt.Errorf("invalid rate %q in %q (expected queries per second above zero)", spec[i+1:], spec)
+		}
+		limits[strings.TrimSpace(spec[:i])] = qps
+	}
+	return limits, nil
+}
+
+// printRateLimits describes the rate limits in effect, warning about
+// limits for servers that aren't being benchmarked.
+func printRateLimits(qps float64, serverQPS map[string]float64, servers []string, warns *warningLog) {
+	var parts []string
+	if qps > 0 {
+		parts = append(parts, fmt.Sprintf("%s qps overall", strconv.FormatFloat(qps, 'f', -1, 64)))
+	}
+	for _, server := range slices.Sorted(maps.Keys(serverQPS)) {
+		if !slices.Contains(servers, server) {
+			warns.add("rate-limit", "rate limit for %s, which is not being benchmarked", server)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s qps", server, strconv.FormatFloat(serverQPS[server], 'f', -1, 64)))
+	}
+	if len(parts) > 0 {
+		fmt.Printf("Rate limit: %s\n", strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestParseServerQPS(t *testing.T) {
+	limits, err := parseServerQPS([]string{"8.8.8.8=10", "https://dns.example/dns-query?a=b=2.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits["8.8.8.8"] != 10 || limits["https://dns.example/dns-query?a=b"] != 2.5 {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+
+	for _, spec := range []string{"8.8.8.8", "=10", "8.8.8.8=fast", "8.8.8.8=0"} {
+		if _, err := parseServerQPS([]string{spec}); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
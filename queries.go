@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+
+	"dns-bench/benchmark"
+)
+
+// maxMismatches is how many distinct wrong answers are listed per server.
+const maxMismatches = 3
+
+// queriesYAML is the layout of a YAML batch file.
+type queriesYAML struct {
+	Queries []struct {
+		Name   string   `yaml:"name"`
+		Type   string   `yaml:"type"`
+		Expect []string `yaml:"expect"`
+		Rcode  string   `yaml:"rcode"`
+	} `yaml:"queries"`
+}
+
+// readQueries reads a batch of questions, one per line:
+//
+//	name [type] [expected answers, comma-separated | RCODE]
+//
+// The type defaults to -type. Expected answers are rdata as dig prints it
+// (e.g. "192.0.2.1" or "10 mail.example.com."), all of which must be in the
+// answer; an RCODE such as NXDOMAIN is the response code the answer must
+// have instead. Blank lines and lines starting with "#" are skipped.
+// YAML files list the same fields under "queries" (see queriesYAML).
+func readQueries(path string) ([]benchmark.Question, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		return readQueriesYAML(path)
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var questions []benchmark.Question
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		questions = append(questions, parseQuestion(line))
+	}
+	return questions, nil
+}
+
+func readQueriesYAML(path string) ([]benchmark.Question, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file queriesYAML
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %v", err)
+	}
+	questions := make([]benchmark.Question, 0, len(file.Queries))
+	for i, entry := range file.Queries {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("query %d has no name", i+1)
+		}
+		q := benchmark.Question{Name: entry.Name, ExpectAnswers: entry.Expect}
+		if entry.Type != "" {
+			qtype, ok := dns.StringToType[strings.ToUpper(entry.Type)]
+			if !ok {
+				return nil, fmt.Errorf("unknown query type %q for %s", entry.Type, entry.Name)
+			}
+			q.Type = qtype
+		}
+		if entry.Rcode != "" {
+			if _, ok := dns.StringToRcode[strings.ToUpper(entry.Rcode)]; !ok {
+				return nil, fmt.Errorf("unknown response code %q for %s", entry.Rcode, entry.Name)
+			}
+			q.ExpectRcode = strings.ToUpper(entry.Rcode)
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// keepValidQuestions drops the questions whose names aren't among valid,
+// the validated (lowercased) domain list, and lowercases the rest.
+func keepValidQuestions(questions []benchmark.Question, valid []string) []benchmark.Question {
+	names := make(map[string]bool, len(valid))
+	for _, d := range valid {
+		names[d] = true
+	}
+	kept := questions[:0]
+	for _, q := range questions {
+		q.Name = strings.ToLower(strings.TrimSpace(q.Name))
+		if names[q.Name] {
+			kept = append(kept, q)
+		}
+	}
+	return kept
+}
+
+// readExpectations reads the address ranges each domain's A and AAAA
+// answers must fall in, keyed by lowercased domain. Text files have a
+// domain per line followed by addresses or CIDR ranges, separated by
+// spaces or commas; YAML files map domains to lists of them.
+func readExpectations(path string) (map[string][]netip.Prefix, error) {
+	raw := make(map[string][]string)
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %v", err)
+		}
+	} else {
+		lines, err := readLines(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Fields(strings.ReplaceAll(line, ",", " "))
+			raw[fields[0]] = append(raw[fields[0]], fields[1:]...)
+		}
+	}
+
+	expect := make(map[string][]netip.Prefix, len(raw))
+	for domain, values := range raw {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("no addresses expected for %s", domain)
+		}
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		for _, v := range values {
+			p, err := parseRange(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", domain, err)
+			}
+			expect[domain] = append(expect[domain], p)
+		}
+	}
+	return expect, nil
+}
+
+// parseRange parses an address range in CIDR notation, or a single address
+// as a range of one.
+func parseRange(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid range %q", s)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", s)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// applyExpectations adds each domain's expected address ranges to its
+// questions, turning a plain domain list into questions first.
+func applyExpectations(questions []benchmark.Question, domains []string, expect map[string][]netip.Prefix) []benchmark.Question {
+	if len(questions) == 0 {
+		questions = make([]benchmark.Question, len(domains))
+		for i, d := range domains {
+			questions[i] = benchmark.Question{Name: d}
+		}
+	}
+	for i := range questions {
+		questions[i].ExpectWithin = expect[strings.ToLower(questions[i].Name)]
+	}
+	return questions
+}
+
+// parseQuestion parses one line of a batch file (see readQueries).
+func parseQuestion(line string) benchmark.Question {
+	fields := strings.Fields(line)
+	q := benchmark.Question{Name: fields[0]}
+	fields = fields[1:]
+	if len(fields) > 0 {
+		if qtype, ok := dns.StringToType[strings.ToUpper(fields[0])]; ok {
+			q.Type = qtype
+			fields = fields[1:]
+		}
+	}
+	rest := strings.Join(fields, " ")
+	if _, ok := dns.StringToRcode[strings.ToUpper(rest)]; ok {
+		q.ExpectRcode = strings.ToUpper(rest)
+		return q
+	}
+	for _, answer := range strings.Split(rest, ",") {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			q.ExpectAnswers = append(q.ExpectAnswers, answer)
+		}
+	}
+	return q
+}
+
+// expectationStats is how often a server's answers met the batch file's
+// expectations, with the most common ways they didn't.
+type expectationStats struct {
+	Server  string
+	Checked int
+	Correct int
+	// Unexpected counts answers with addresses outside the expected ranges
+	Unexpected int
+	Mismatches []string
+}
+
+// checkExpectations tallies the checked results per server, in the order
+// of stats. Servers without checked answers are left out.
+func checkExpectations(stats []*ServerStats, results []benchmark.Result) []expectationStats {
+	byServer := make(map[string]*expectationStats)
+	wrong := make(map[string]map[string]int)
+	for _, res := range results {
+		if !res.Checked {
+			continue
+		}
+		e := byServer[res.Server]
+		if e == nil {
+			e = &expectationStats{Server: res.Server}
+			byServer[res.Server] = e
+			wrong[res.Server] = make(map[string]int)
+		}
+		e.Checked++
+		if res.Mismatch == "" {
+			e.Correct++
+			continue
+		}
+		if res.MismatchKind == benchmark.MismatchUnexpected {
+			e.Unexpected++
+		}
+		wrong[res.Server][fmt.Sprintf("%s %s: %s", res.Domain, res.QType, res.Mismatch)]++
+	}
+
+	var out []expectationStats
+	for _, s := range stats {
+		e := byServer[s.Server]
+		if e == nil {
+			continue
+		}
+		counts := wrong[s.Server]
+		for m := range counts {
+			e.Mismatches = append(e.Mismatches, m)
+		}
+		sort.Slice(e.Mismatches, func(i, j int) bool {
+			a, b := e.Mismatches[i], e.Mismatches[j]
+			if counts[a] != counts[b] {
+				return counts[a] > counts[b]
+			}
+			return a < b
+		})
+		if len(e.Mismatches) > maxMismatches {
+			e.Mismatches = e.Mismatches[:maxMismatches]
+		}
+		out = append(out, *e)
+	}
+	return out
+}
+
+// printExpectations shows how many answers were as the batch file expected
+// next to the latency table's ranking.
+func printExpectations(expectations []expectationStats) {
+	if len(expectations) == 0 {
+		return
+	}
+
+	fmt.Printf("\nExpected Answers\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tCHECKED\tCORRECT %\tMISMATCHES"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var wrong, redirected []string
+	for _, e := range expectations {
+		pct := float64(e.Correct) / float64(e.Checked) * 100
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", e.Server, e.Checked, pct, orNone(strings.Join(e.Mismatches, "; "))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+		if e.Correct < e.Checked {
+			wrong = append(wrong, e.Server)
+		}
+		if e.Unexpected > 0 {
+			redirected = append(redirected, e.Server)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(redirected) > 0 {
+		fmt.Printf("⚠️  Addresses outside the expected ranges, a sign of hijacking, filtering or misconfiguration, from: %s\n", strings.Join(redirected, ", "))
+	}
+	if len(wrong) > 0 {
+		fmt.Printf("⚠️  Unexpected answers from: %s\n", strings.Join(wrong, ", "))
+	} else {
+		fmt.Println("✓ Every answer was as expected")
+	}
+}
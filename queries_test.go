@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"dns-bench/benchmark"
+)
+
+func TestReadQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.txt")
+	data := `# name	type	expected
+example.com	A	192.0.2.1, 192.0.2.2
+example.com MX 10 mail.example.com.
+missing.example NXDOMAIN
+example.org 192.0.2.9
+example.net   txt
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readQueries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []benchmark.Question{
+		{Name: "example.com", Type: dns.TypeA, ExpectAnswers: []string{"192.0.2.1", "192.0.2.2"}},
+		{Name: "example.com", Type: dns.TypeMX, ExpectAnswers: []string{"10 mail.example.com."}},
+		{Name: "missing.example", ExpectRcode: "NXDOMAIN"},
+		{Name: "example.org", ExpectAnswers: []string{"192.0.2.9"}},
+		{Name: "example.net", Type: dns.TypeTXT},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readQueries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckExpectations(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "a", Domain: "example.com", QType: "A", Checked: true},
+		{Server: "a", Domain: "example.com", QType: "A", Checked: true, Mismatch: "missing 192.0.2.1", MismatchKind: benchmark.MismatchMissing},
+		{Server: "a", Domain: "example.com", QType: "A", Checked: true, Mismatch: "missing 192.0.2.1", MismatchKind: benchmark.MismatchMissing},
+		{Server: "a", Domain: "x.example", QType: "A", Checked: true, Mismatch: "NOERROR instead of NXDOMAIN", MismatchKind: benchmark.MismatchRcode},
+		{Server: "a", Domain: "example.org", QType: "A", Checked: true, Mismatch: "unexpected 203.0.113.66", MismatchKind: benchmark.MismatchUnexpected},
+		{Server: "a", Domain: "example.net", QType: "TXT"},
+		{Server: "b", Domain: "example.net", QType: "TXT"},
+	}
+	got := checkExpectations(calculateStats(results), results)
+	if len(got) != 1 {
+		t.Fatalf("expected only server a to be checked, got %+v", got)
+	}
+	e := got[0]
+	if e.Checked != 5 || e.Correct != 1 || e.Unexpected != 1 {
+		t.Errorf("expected 1 of 5 correct and 1 unexpected, got %d of %d and %d", e.Correct, e.Checked, e.Unexpected)
+	}
+	if len(e.Mismatches) != 3 || e.Mismatches[0] != "example.com A: missing 192.0.2.1" {
+		t.Errorf("expected the most common mismatch first, got %v", e.Mismatches)
+	}
+}
+
+func TestReadExpectations(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "expect.txt")
+	if err := os.WriteFile(text, []byte("# domain ranges\nExample.com 192.0.2.0/24, 2001:db8::1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	yml := filepath.Join(dir, "expect.yaml")
+	if err := os.WriteFile(yml, []byte("example.com:\n  - 192.0.2.7/24\n  - 2001:db8::1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]netip.Prefix{
+		"example.com": {netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("2001:db8::1/128")},
+	}
+	for _, path := range []string{text, yml} {
+		got, err := readExpectations(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: readExpectations() = %v, want %v", path, got, want)
+		}
+	}
+
+	if err := os.WriteFile(text, []byte("example.com 192.0.2.300\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readExpectations(text); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestApplyExpectations(t *testing.T) {
+	expect := map[string][]netip.Prefix{"example.com": {netip.MustParsePrefix("192.0.2.0/24")}}
+	questions := applyExpectations(nil, []string{"example.com", "example.org"}, expect)
+	if len(questions) != 2 || len(questions[0].ExpectWithin) != 1 || questions[1].ExpectWithin != nil {
+		t.Errorf("expected ranges for example.com only, got %+v", questions)
+	}
+}
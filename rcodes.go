@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/miekg/dns"
+)
+
+// rcodeStats counts a server's answers by response code.
+type rcodeStats struct {
+	counts   map[string]int
+	answered int
+}
+
+func (r *rcodeStats) add(rcode string) {
+	if rcode == "" {
+		return // No response
+	}
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[rcode]++
+	r.answered++
+}
+
+// Count returns the number of answers with rcode.
+func (r rcodeStats) Count(rcode string) int {
+	return r.counts[rcode]
+}
+
+// Share returns the percentage of answers with rcode.
+func (r rcodeStats) Share(rcode string) float64 {
+	if r.answered == 0 {
+		return 0
+	}
+	return float64(r.counts[rcode]) / float64(r.answered) * 100
+}
+
+// usedRcodes returns every response code any server answered with, in
+// numeric order, so NOERROR comes first.
+func usedRcodes(stats []*ServerStats) []string {
+	var used []string
+	for _, s := range stats {
+		for rcode := range s.Rcodes.counts {
+			if !slices.Contains(used, rcode) {
+				used = append(used, rcode)
+			}
+		}
+	}
+	slices.SortFunc(used, func(a, b string) int {
+		return cmp.Or(cmp.Compare(rcodeValue(a), rcodeValue(b)), cmp.Compare(a, b))
+	})
+	return used
+}
+
+// rcodeValue is the numeric value of a response code's name, with names
+// the dns package doesn't know last.
+func rcodeValue(rcode string) int {
+	if v, ok := dns.StringToRcode[rcode]; ok {
+		return v
+	}
+	return 1 << 16
+}
+
+// printRcodes shows the distribution of each server's answers over the
+// response codes that occurred. It stays quiet when every answer was
+// NOERROR.
+func printRcodes(stats []*ServerStats) {
+	used := usedRcodes(stats)
+	if len(used) == 0 || (len(used) == 1 && used[0] == dns.RcodeToString[dns.RcodeSuccess]) {
+		return
+	}
+
+	fmt.Printf("\nResponse Codes (share of answered queries)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SERVER\t%s\n", strings.Join(used, "\t")); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		row := []string{s.Server}
+		for _, rcode := range used {
+			cell := "-"
+			if n := s.Rcodes.Count(rcode); n > 0 {
+				cell = fmt.Sprintf("%d (%.1f%%)", n, s.Rcodes.Share(rcode))
+			}
+			row = append(row, cell)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
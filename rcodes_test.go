@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestRcodeStats(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "a", Domain: "one.example", Rcode: "NOERROR"},
+		{Server: "a", Domain: "two.example", Rcode: "SERVFAIL"},
+		{Server: "a", Domain: "three.example", Rcode: "NXDOMAIN"},
+		{Server: "a", Domain: "four.example", Rcode: "NXDOMAIN"},
+		{Server: "a", Domain: "five.example", Error: errors.New("i/o timeout")},
+	}
+	s := calculateStats(results)[0]
+	if s.Rcodes.Count("NXDOMAIN") != 2 || s.Rcodes.Share("NXDOMAIN") != 50 {
+		t.Errorf("expected 2 NXDOMAIN answers, half of those answered, got %+v", s.Rcodes)
+	}
+	want := []string{"NOERROR", "SERVFAIL", "NXDOMAIN"}
+	if got := usedRcodes([]*ServerStats{s}); !slices.Equal(got, want) {
+		t.Errorf("expected response codes in numeric order %v, got %v", want, got)
+	}
+}
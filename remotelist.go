@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isListURL reports whether a domain or server list argument is an http(s)
+// URL rather than a local path.
+func isListURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// listCacheDir returns where lists fetched from URLs are cached, in the
+// user's cache directory, or "" when there isn't one.
+func listCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-bench", "lists")
+}
+
+// localList returns a path to read a list from: source itself, or for a URL
+// the cached copy of it, fetched or revalidated first.
+func localList(source string, warns *warningLog) (string, error) {
+	if !isListURL(source) {
+		return source, nil
+	}
+	return fetchList(source, listCacheDir(), warns)
+}
+
+// fetchList downloads the list at rawURL into cacheDir and returns the
+// cached file's path. A cached copy is revalidated with its ETag, so an
+// unchanged list isn't downloaded again, and is used as is, with a warning,
+// when the server can't be reached. The file keeps the URL's extension, so
+// .csv and .yaml lists are parsed as such.
+func fetchList(rawURL, cacheDir string, warns *warningLog) (string, error) {
+	if cacheDir == "" {
+		return "", fmt.Errorf("no cache directory to keep %s in", rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	cached := filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+strings.ToLower(path.Ext(u.Path)))
+	etagFile := cached + ".etag"
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	_, statErr := os.Stat(cached)
+	if statErr == nil {
+		if etag, err := os.ReadFile(etagFile); err == nil && len(etag) > 0 {
+			req.Header.Set("If-None-Match", string(etag))
+		}
+	}
+
+	stale := func(err error) (string, error) {
+		if statErr != nil {
+			return "", fmt.Errorf("fetching %s: %w", redactList(u), err)
+		}
+		warns.add("lists", "failed to fetch %s, using the cached copy: %v", redactList(u), err)
+		return cached, nil
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return stale(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close response body: %v\n", err)
+		}
+	}()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && statErr == nil:
+		return cached, nil
+	case resp.StatusCode != http.StatusOK:
+		return stale(fmt.Errorf("%s", resp.Status))
+	}
+
+	if err := replaceFile(cached, resp.Body); err != nil {
+		return stale(err)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if err := os.WriteFile(etagFile, []byte(etag), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save ETag: %v\n", err)
+		}
+	} else if err := os.Remove(etagFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove stale ETag: %v\n", err)
+	}
+	return cached, nil
+}
+
+// redactList drops credentials and the query string, which may carry a
+// token, from a list URL before it is printed.
+func redactList(u *url.URL) string {
+	r := *u
+	r.User = nil
+	r.RawQuery = ""
+	return r.String()
+}
+
+// replaceFile writes src to a temporary file next to path and renames it
+// into place, so an interrupted download never replaces a good copy.
+func replaceFile(path string, src io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		return errors.Join(err, tmp.Close(), os.Remove(tmp.Name()))
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Join(err, os.Remove(tmp.Name()))
+	}
+	return os.Rename(tmp.Name(), path)
+}
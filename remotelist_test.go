@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchList(t *testing.T) {
+	downloads, revalidated := 0, 0
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		if _, err := w.Write([]byte("rank,domain\n1,example.com\n2,example.org\n")); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	listURL := srv.URL + "/lists/top.csv?token=secret"
+	path, err := fetchList(listURL, cacheDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(path) != ".csv" {
+		t.Errorf("expected the cached copy to keep the .csv extension, got %s", path)
+	}
+	domains, ranks, err := readDomains(path, nil)
+	if err != nil || len(domains) != 2 || ranks["example.org"] != 2 {
+		t.Errorf("expected the ranked domains from the cached copy, got %v %v %v", domains, ranks, err)
+	}
+
+	if again, err := fetchList(listURL, cacheDir, nil); err != nil || again != path {
+		t.Errorf("expected the same cached copy, got %s %v", again, err)
+	}
+	if downloads != 1 || revalidated != 1 {
+		t.Errorf("expected one download and one ETag revalidation, got %d and %d", downloads, revalidated)
+	}
+
+	up = false
+	warns := &warningLog{}
+	if stale, err := fetchList(listURL, cacheDir, warns); err != nil || stale != path {
+		t.Errorf("expected the cached copy when the server fails, got %s %v", stale, err)
+	}
+	if w := warns.warnings(); len(w) != 1 || w[0].Source != "lists" {
+		t.Errorf("expected a warning about the cached copy, got %+v", w)
+	}
+	if _, err := fetchList(srv.URL+"/other.txt", cacheDir, nil); err == nil {
+		t.Error("expected an error without a cached copy")
+	}
+}
+
+func TestIsListURL(t *testing.T) {
+	for s, want := range map[string]bool{
+		"https://lists.example.com/domains.csv": true,
+		"http://10.0.0.1/servers.yaml":          true,
+		"domains.csv":                           false,
+		"tranco:1000":                           false,
+		"/etc/dns-bench/servers.txt":            false,
+	} {
+		if got := isListURL(s); got != want {
+			t.Errorf("isListURL(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// chartColors are the series colours of the HTML charts, reused in order.
+var chartColors = []string{"#2c3e50", "#e74c3c", "#27ae60", "#2980b9", "#f39c12", "#8e44ad", "#16a085", "#d35400", "#7f8c8d", "#c0392b"}
+
+// serverDurations returns each server's successful query latencies, sorted.
+func serverDurations(results []benchmark.Result) map[string][]time.Duration {
+	durations := make(map[string][]time.Duration)
+	for _, res := range results {
+		if res.Error == nil {
+			durations[res.Server] = append(durations[res.Server], res.Duration)
+		}
+	}
+	for _, d := range durations {
+		slices.Sort(d)
+	}
+	return durations
+}
+
+// chartLabel shortens long server names (DoH URLs) for chart axes.
+func chartLabel(server string) string {
+	const maxLen = 30
+	if r := []rune(server); len(r) > maxLen {
+		return string(r[:maxLen-1]) + "…"
+	}
+	return server
+}
+
+// bucketLabel names histogram bucket i of duelBuckets.
+func bucketLabel(i int) string {
+	if i == len(duelBuckets) {
+		return fmt.Sprintf(">%v", duelBuckets[i-1])
+	}
+	return fmt.Sprintf("≤%v", duelBuckets[i])
+}
+
+// latencyHistogramChart draws each server's latency distribution over the
+// duel buckets as one line per server, as the share of its answered
+// queries, so servers with different sample counts compare directly.
+func latencyHistogramChart(stats []*ServerStats, durations map[string][]time.Duration) string {
+	const width, height, left, right, top, bottom = 900.0, 320.0, 50.0, 20.0, 20.0, 40.0
+	bins := len(duelBuckets) + 1
+	shares := make([][]float64, len(stats))
+	maxShare := 0.0
+	for i, s := range stats {
+		shares[i] = make([]float64, bins)
+		d := durations[s.Server]
+		for _, v := range d {
+			b, _ := slices.BinarySearch(duelBuckets, v)
+			shares[i][b]++
+		}
+		for b := range shares[i] {
+			if len(d) > 0 {
+				shares[i][b] = shares[i][b] / float64(len(d)) * 100
+			}
+			maxShare = max(maxShare, shares[i][b])
+		}
+	}
+	if maxShare == 0 {
+		maxShare = 100
+	}
+	step := (width - left - right) / float64(bins-1)
+	x := func(b int) float64 { return left + float64(b)*step }
+	y := func(pct float64) float64 { return height - bottom - pct/maxShare*(height-top-bottom) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" width="100%%" role="img" aria-label="Latency distribution per server">`, width, height)
+	for i := 0; i <= 4; i++ {
+		pct := maxShare * float64(i) / 4
+		fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#ddd"/>`, left, width-right, y(pct), y(pct))
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" font-size="11" text-anchor="end">%.0f%%</text>`, left-6, y(pct)+4, pct)
+	}
+	for bin := 0; bin < bins; bin++ {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.0f" font-size="11" text-anchor="middle">%s</text>`, x(bin), height-bottom+18, html.EscapeString(bucketLabel(bin)))
+	}
+	for i, s := range stats {
+		color := chartColors[i%len(chartColors)]
+		coords := make([]string, bins)
+		for bin, pct := range shares[i] {
+			coords[bin] = fmt.Sprintf("%.1f,%.1f", x(bin), y(pct))
+		}
+		fmt.Fprintf(&b, `<g class="series" data-series="%d"><polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, i, color, strings.Join(coords, " "))
+		for bin, pct := range shares[i] {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3.5" fill="%s"><title>%s %s: %.1f%% of answers</title></circle>`,
+				x(bin), y(pct), color, html.EscapeString(s.Server), html.EscapeString(bucketLabel(bin)), pct)
+		}
+		b.WriteString(`</g>`)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// latencyBarsChart draws each server's average and p95 latency as a pair
+// of horizontal bars, in ranking order.
+func latencyBarsChart(stats []*ServerStats, durations map[string][]time.Duration) string {
+	const width, left, right, top, rowHeight, barHeight = 900.0, 230.0, 90.0, 10.0, 34.0, 12.0
+	height := top + rowHeight*float64(len(stats)) + 30
+	var maxD time.Duration
+	p95 := make([]time.Duration, len(stats))
+	for i, s := range stats {
+		p95[i] = percentile(durations[s.Server], 95)
+		maxD = max(maxD, p95[i], s.Avg)
+	}
+	if maxD == 0 {
+		maxD = time.Millisecond
+	}
+	w := func(d time.Duration) float64 { return float64(d) / float64(maxD) * (width - left - right) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" width="100%%" role="img" aria-label="Average and p95 latency per server">`, width, height)
+	for i, s := range stats {
+		color := chartColors[i%len(chartColors)]
+		rowY := top + rowHeight*float64(i)
+		name := html.EscapeString(s.Server)
+		fmt.Fprintf(&b, `<g class="series" data-series="%d">`, i)
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" font-size="12" text-anchor="end">%s<title>%s</title></text>`,
+			left-8, rowY+barHeight+4, html.EscapeString(chartLabel(s.Server)), name)
+		fmt.Fprintf(&b, `<rect x="%.0f" y="%.1f" width="%.1f" height="%.0f" fill="%s"><title>%s avg: %v</title></rect>`,
+			left, rowY, w(s.Avg), barHeight, color, name, s.Avg)
+		fmt.Fprintf(&b, `<rect x="%.0f" y="%.1f" width="%.1f" height="%.0f" fill="%s" fill-opacity="0.45"><title>%s p95: %v</title></rect>`,
+			left, rowY+barHeight, w(p95[i]), barHeight, color, name, p95[i])
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11">%v / %v</text>`,
+			left+max(w(s.Avg), w(p95[i]))+6, rowY+barHeight+4, s.Avg.Round(10*time.Microsecond), p95[i].Round(10*time.Microsecond))
+		b.WriteString(`</g>`)
+	}
+	fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="11">solid: average, light: p95</text>`, left, height-8)
+	b.WriteString(`</svg>`)
+	return b.String()
+}
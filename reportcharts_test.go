@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestLatencyCharts(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "1.1.1.1", Duration: 2 * time.Millisecond},
+		{Server: "1.1.1.1", Duration: 3 * time.Millisecond},
+		{Server: "1.1.1.1", Duration: 4 * time.Millisecond},
+		{Server: "1.1.1.1", Duration: 2 * time.Second},
+		{Server: "https://dns.example/dns-query?<x>", Duration: 30 * time.Millisecond},
+		{Server: "https://dns.example/dns-query?<x>", Error: errors.New("timeout")},
+	}
+	stats := calculateStats(results)
+	durations := serverDurations(results)
+	if d := durations["1.1.1.1"]; len(d) != 4 || d[0] != 2*time.Millisecond || d[3] != 2*time.Second {
+		t.Fatalf("durations = %v", d)
+	}
+
+	hist := latencyHistogramChart(stats, durations)
+	for _, want := range []string{
+		"1.1.1.1 ≤2ms: 25.0% of answers",
+		"1.1.1.1 ≤5ms: 50.0% of answers",
+		"1.1.1.1 &gt;1s: 25.0% of answers",
+		"≤50ms: 100.0% of answers",
+	} {
+		if !strings.Contains(hist, want) {
+			t.Errorf("histogram missing %q", want)
+		}
+	}
+
+	bars := latencyBarsChart(stats, durations)
+	if !strings.Contains(bars, "p95: 2s") || !strings.Contains(bars, "avg: 30ms") {
+		t.Error("bar chart missing avg or p95 values")
+	}
+	for _, chart := range []string{hist, bars} {
+		if strings.Contains(chart, "<x>") {
+			t.Error("server name not escaped")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package resolved reads and updates the per-link DNS servers configured in
+// systemd-resolved, using the resolvectl command line tool.
+package resolved
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds each resolvectl invocation.
+const commandTimeout = 5 * time.Second
+
+// ErrUnsupported is returned on platforms without systemd-resolved.
+var ErrUnsupported = errors.New("systemd-resolved is only available on Linux")
+
+// Link is a network link and the DNS servers systemd-resolved uses for it.
+// The pseudo-link "Global" holds servers that apply to every link.
+type Link struct {
+	Name    string
+	Servers []string // In dns-bench server syntax ("1.1.1.1", "[2001:db8::1]:53")
+}
+
+// parseDNS parses the output of "resolvectl dns", for example:
+//
+//	Global: 1.1.1.1#cloudflare-dns.com
+//	Link 2 (eth0): 192.168.1.1 fe80::1%eth0
+//	Link 3 (wlan0):
+//
+// Links without servers are included so callers can see every link.
+func parseDNS(out string) []Link {
+	var links []Link
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		head, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(head)
+		if open := strings.IndexByte(name, '('); open >= 0 && strings.HasSuffix(name, ")") {
+			name = name[open+1 : len(name)-1]
+		}
+		link := Link{Name: name}
+		for _, field := range strings.Fields(rest) {
+			if server, ok := serverAddr(field); ok {
+				link.Servers = append(link.Servers, server)
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// serverAddr converts a resolvectl server entry ("ip", "ip:port",
+// "[ipv6]:port", each optionally followed by "#servername") into the
+// address syntax the benchmark uses. IPv6 addresses always get an explicit
+// port so they are not mistaken for host:port pairs.
+func serverAddr(field string) (string, bool) {
+	field, _, _ = strings.Cut(field, "#")
+	if addr, err := netip.ParseAddr(field); err == nil {
+		if addr.Is4() {
+			return addr.String(), true
+		}
+		return net.JoinHostPort(addr.String(), "53"), true
+	}
+	if ap, err := netip.ParseAddrPort(field); err == nil {
+		return ap.String(), true
+	}
+	return "", false
+}
+
+// Applicable reports whether server can be handed to systemd-resolved, which
+// only speaks plain DNS (and DoT, configured separately) to IP addresses.
+func Applicable(server string) bool {
+	if strings.Contains(server, "://") {
+		return false
+	}
+	_, ok := serverAddr(server)
+	return ok
+}
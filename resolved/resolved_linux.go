@@ -0,0 +1,36 @@
+//go:build linux
+
+package resolved
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Links returns the DNS servers systemd-resolved has configured for each
+// link, including the Global pseudo-link.
+func Links() ([]Link, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "resolvectl", "dns").Output()
+	if err != nil {
+		return nil, fmt.Errorf("running resolvectl dns: %w", err)
+	}
+	return parseDNS(string(out)), nil
+}
+
+// SetDNS replaces the DNS servers of link. This needs root (or polkit
+// authorisation) and lasts until the link is reconfigured, e.g. by DHCP.
+func SetDNS(link string, servers []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	args := append([]string{"dns", link}, servers...)
+	//nolint:gosec // G204: arguments are a link name and validated IP addresses
+	out, err := exec.CommandContext(ctx, "resolvectl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("resolvectl dns %s: %w: %s", link, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
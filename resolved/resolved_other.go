@@ -0,0 +1,9 @@
+//go:build !linux
+
+package resolved
+
+// Links is not supported on this platform.
+func Links() ([]Link, error) { return nil, ErrUnsupported }
+
+// SetDNS is not supported on this platform.
+func SetDNS(_ string, _ []string) error { return ErrUnsupported }
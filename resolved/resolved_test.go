@@ -0,0 +1,36 @@
+package resolved
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDNS(t *testing.T) {
+	out := `Global: 1.1.1.1#cloudflare-dns.com 9.9.9.9:53
+Link 2 (eth0): 192.168.1.1 fe80::1%eth0 2001:db8::53
+Link 3 (wlan0):
+`
+	want := []Link{
+		{Name: "Global", Servers: []string{"1.1.1.1", "9.9.9.9:53"}},
+		{Name: "eth0", Servers: []string{"192.168.1.1", "[fe80::1%eth0]:53", "[2001:db8::53]:53"}},
+		{Name: "wlan0"},
+	}
+	if got := parseDNS(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDNS() = %+v, want %+v", got, want)
+	}
+}
+
+func TestApplicable(t *testing.T) {
+	tests := map[string]bool{
+		"1.1.1.1":                      true,
+		"[2001:db8::53]:53":            true,
+		"tls://1.1.1.1":                false,
+		"https://dns.google/dns-query": false,
+		"dns.google":                   false,
+	}
+	for server, want := range tests {
+		if got := Applicable(server); got != want {
+			t.Errorf("Applicable(%q) = %v, want %v", server, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"dns-bench/resolved"
+)
+
+// addResolvedServers appends the DNS servers systemd-resolved has configured
+// for each link to servers, skipping duplicates, and returns the links so
+// the winner can be applied to them later.
+func addResolvedServers(servers []string, warns *warningLog) ([]string, []resolved.Link) {
+	links, err := resolved.Links()
+	if err != nil {
+		warns.add("servers", "could not read systemd-resolved configuration: %v", err)
+		return servers, nil
+	}
+
+	seen := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		seen[s] = true
+	}
+	for _, link := range links {
+		if len(link.Servers) == 0 {
+			continue
+		}
+		fmt.Printf("systemd-resolved %s: %v\n", link.Name, link.Servers)
+		for _, s := range link.Servers {
+			if !seen[s] {
+				seen[s] = true
+				servers = append(servers, s)
+			}
+		}
+	}
+	return servers, links
+}
+
+// applyResolvedWinner points every link that has DNS servers configured at
+// the fastest server systemd-resolved can use. The Global entry comes from
+// resolved.conf and is left alone.
+func applyResolvedWinner(stats []*ServerStats, links []resolved.Link) {
+	var winner string
+	for _, s := range stats {
+		if s.Success > 0 && resolved.Applicable(s.Server) {
+			winner = s.Server
+			break
+		}
+	}
+	if winner == "" {
+		fmt.Println("\nNot applying a DNS server: no plain DNS server answered successfully")
+		return
+	}
+
+	fmt.Printf("\nApplying %s to systemd-resolved links\n", winner)
+	for _, link := range links {
+		if link.Name == "Global" || len(link.Servers) == 0 {
+			continue
+		}
+		if len(link.Servers) == 1 && link.Servers[0] == winner {
+			fmt.Printf("  %s: already using %s\n", link.Name, winner)
+			continue
+		}
+		if err := resolved.SetDNS(link.Name, []string{winner}); err != nil {
+			fmt.Printf("  %s: failed: %v\n", link.Name, err)
+			continue
+		}
+		fmt.Printf("  %s: %v -> %s\n", link.Name, link.Servers, winner)
+	}
+}
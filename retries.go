@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// retryStats separates lookups that succeeded on the first attempt from
+// those that needed retries, so a server that only looks reliable because
+// it was asked again shows up as such.
+type retryStats struct {
+	// FirstTry counts lookups that succeeded without a retry, Recovered
+	// those that succeeded after one or more
+	FirstTry  int
+	Recovered int
+	// Retries is the number of extra attempts over all lookups
+	Retries int
+}
+
+func (r *retryStats) add(res benchmark.Result) {
+	r.Retries += res.Retries
+	if res.Error != nil {
+		return
+	}
+	if res.Retries > 0 {
+		r.Recovered++
+	} else {
+		r.FirstTry++
+	}
+}
+
+// printRetries compares each server's first-attempt success rate with its
+// final one after retries.
+func printRetries(stats []*ServerStats, retries int) {
+	fmt.Printf("\nRetries (up to %d per lookup)\n\n", retries)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tFIRST-TRY SUCCESS %\tFINAL SUCCESS %\tRECOVERED\tRETRIES"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var needy []string
+	for _, s := range stats {
+		first := float64(s.Retry.FirstTry) / float64(s.Total) * 100
+		final := float64(s.Success) / float64(s.Total) * 100
+		if _, err := fmt.Fprintf(w, "%s\t%.2f%%\t%.2f%%\t%d\t%d\n", s.Server, first, final, s.Retry.Recovered, s.Retry.Retries); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+		if s.Retry.Recovered > 0 {
+			needy = append(needy, s.Server)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+	if len(needy) > 0 {
+		fmt.Printf("⚠️  Final success rates include lookups that only succeeded on a retry for: %s\n", strings.Join(needy, ", "))
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestRetryStats(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "s", Domain: "a.example"},
+		{Server: "s", Domain: "b.example", Retries: 1},
+		{Server: "s", Domain: "c.example", Retries: 2},
+		{Server: "s", Domain: "d.example", Retries: 2, Error: errors.New("i/o timeout")},
+	}
+	s := calculateStats(results)[0]
+	if s.Retry.FirstTry != 1 || s.Retry.Recovered != 2 || s.Retry.Retries != 5 {
+		t.Errorf("expected 1 first-try success, 2 recovered and 5 retries, got %+v", s.Retry)
+	}
+	if s.Success != 3 {
+		t.Errorf("expected 3 successes after retries, got %d", s.Success)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// schemaVersion is the version of the JSON and CSV export format. Within a
+// version, fields and columns are only ever added, never renamed, removed or
+// reordered, so consumers that look columns up by header name and ignore
+// unknown JSON fields keep working. Anything else bumps the version, and
+// readResults learns to convert the old layout.
+//
+// Version 0 is the unversioned layout written before this constant existed:
+// the same fields, minus the protocol column. Version 2 names the latency
+// fields and columns after latency_unit, e.g. duration_us; exports in
+// milliseconds were still written as version 1. Version 3 records protocols
+// by label (dot, doh, doh3, see benchmark.ProtocolLabel) instead of by
+// address scheme (tls, https, h3).
+const schemaVersion = 3
+
+// csvSchemaPrefix starts the comment line that carries the schema version
+// directly above the CSV header.
+const csvSchemaPrefix = "# schema_version: "
+
+// csvHeader is the header row of a CSV export.
+var csvHeader = []string{"Server", "Domain", "Duration_ms", "Error", "Network", "Protocol", "Category", "Time", "Offset_ms", "Rcode"}
+
+// csvHeaderFor is csvHeader with the latency columns named after the unit
+// of the format, e.g. Duration_us. Readers find either by name.
+func csvHeaderFor(latency latencyFormat) []string {
+	header := slices.Clone(csvHeader)
+	for i, name := range header {
+		if base, ok := strings.CutSuffix(name, "_ms"); ok {
+			header[i] = latency.column(base)
+		}
+	}
+	return header
+}
+
+// readResults loads the results from a JSON or CSV export written by any
+// version of the tool, converted to the current schema. History CSVs with a
+// leading Timestamp column are accepted too.
+func readResults(path string) ([]benchmark.Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []benchmark.Result
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var export decodedExport
+		export, err = loadJSONExport(data)
+		results = export.Results
+	} else {
+		var version int
+		if results, version, err = decodeCSVExport(data); err == nil {
+			err = upgradeResults(results, version)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// decodedExport is a JSON export as read back: the provenance of its run,
+// or of every run for a merged file, as written, and its results with the
+// latencies in milliseconds whatever unit they were written in.
+type decodedExport struct {
+	SchemaVersion int
+	Provenance    json.RawMessage
+	Runs          []json.RawMessage
+	Results       []benchmark.Result
+}
+
+// loadJSONExport parses a JSON export written by any version of the tool,
+// with its results converted to the current schema.
+func loadJSONExport(data []byte) (decodedExport, error) {
+	export, err := decodeJSONExport(data)
+	if err != nil {
+		return export, err
+	}
+	return export, upgradeResults(export.Results, export.SchemaVersion)
+}
+
+// decodeJSONExport parses a JSON export. Exports without a schema_version
+// field are version 0.
+func decodeJSONExport(data []byte) (decodedExport, error) {
+	var in struct {
+		SchemaVersion int               `json:"schema_version"`
+		Provenance    json.RawMessage   `json:"provenance"`
+		Runs          []json.RawMessage `json:"runs"`
+		LatencyUnit   string            `json:"latency_unit"`
+		Results       []json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return decodedExport{}, err
+	}
+	if _, ok := latencyUnits[in.LatencyUnit]; in.LatencyUnit != "" && !ok {
+		return decodedExport{}, fmt.Errorf("unknown latency unit %q", in.LatencyUnit)
+	}
+	export := decodedExport{
+		SchemaVersion: in.SchemaVersion,
+		Provenance:    in.Provenance,
+		Runs:          in.Runs,
+		Results:       make([]benchmark.Result, len(in.Results)),
+	}
+	for i, raw := range in.Results {
+		if in.LatencyUnit != "" && in.LatencyUnit != "ms" {
+			var err error
+			if raw, err = relabelLatencies(raw, in.LatencyUnit, "ms", -1); err != nil {
+				return decodedExport{}, err
+			}
+		}
+		if err := json.Unmarshal(raw, &export.Results[i]); err != nil {
+			return decodedExport{}, err
+		}
+	}
+	return export, nil
+}
+
+// decodeCSVExport parses a CSV export, reading the schema version from its
+// comment lines and the columns by header name.
+func decodeCSVExport(data []byte) ([]benchmark.Result, int, error) {
+	version := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, csvSchemaPrefix); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid schema version %q", v)
+			}
+			version = n
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, version, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	// Latency columns are named after their unit, Duration_ms by default
+	unit := "ms"
+	for u := range latencyUnits {
+		if _, ok := col["Duration_"+u]; ok {
+			unit = u
+		}
+	}
+	durationCol, offsetCol := "Duration_"+unit, "Offset_"+unit
+	for _, name := range []string{"Server", "Domain", durationCol} {
+		if _, ok := col[name]; !ok {
+			return nil, 0, fmt.Errorf("missing %s column", name)
+		}
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+
+	var results []benchmark.Result
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		duration, err := strconv.ParseFloat(field(rec, durationCol), 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid duration %q", field(rec, durationCol))
+		}
+		res := benchmark.Result{
+			Server:   field(rec, "Server"),
+			Domain:   field(rec, "Domain"),
+			Duration: time.Duration(duration * float64(latencyUnits[unit])),
+			Network:  field(rec, "Network"),
+			Protocol: field(rec, "Protocol"),
+			Category: field(rec, "Category"),
+			Rcode:    field(rec, "Rcode"),
+		}
+		if e := field(rec, "Error"); e != "" {
+			res.Error = errors.New(e)
+		}
+		if t := field(rec, "Time"); t != "" {
+			if res.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+				return nil, 0, fmt.Errorf("invalid time %q", t)
+			}
+		}
+		if o := field(rec, offsetCol); o != "" {
+			offset, err := strconv.ParseFloat(o, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid offset %q", o)
+			}
+			res.Offset = time.Duration(offset * float64(latencyUnits[unit]))
+		}
+		if res.Category == "" {
+			// Exports from before categories were recorded
+			res.Category = benchmark.Classify(res)
+		}
+		results = append(results, res)
+	}
+	return results, version, nil
+}
+
+// upgradeResults converts results read from a version's layout to the
+// current one in place. Versions newer than this build are refused.
+func upgradeResults(results []benchmark.Result, version int) error {
+	if version > schemaVersion {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, schemaVersion)
+	}
+	for i := range results {
+		switch {
+		case version < 1 && results[i].Protocol == "":
+			// Version 0 had no protocol; infer it from the server's scheme.
+			results[i].Protocol = benchmark.ServerProtocol(results[i].Server)
+		case version < 3:
+			results[i].Protocol = benchmark.ProtocolLabel(results[i].Protocol)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestReadResultsRoundTrip(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "tls://1.1.1.1", Domain: "example.com", Duration: 12500 * time.Microsecond, Network: "office-lan", Protocol: "dot",
+			Time: time.Date(2026, 3, 1, 9, 30, 0, 250, time.FixedZone("CET", 3600)), Offset: 3 * time.Millisecond},
+		{Server: "8.8.8.8", Domain: "example.org", Duration: time.Second, Error: os.ErrDeadlineExceeded, Protocol: "udp"},
+	}
+	dir := t.TempDir()
+	for name, export := range map[string]func([]benchmark.Result, *provenance, string) error{
+		"results.json": exportJSON,
+		"results.csv":  exportCSV,
+	} {
+		path := filepath.Join(dir, name)
+		if err := export(results, testProvenance(), path); err != nil {
+			t.Fatalf("%s: export failed: %v", name, err)
+		}
+		got, err := readResults(path)
+		if err != nil {
+			t.Fatalf("%s: readResults failed: %v", name, err)
+		}
+		if len(got) != 2 || got[0].Server != "tls://1.1.1.1" || got[0].Duration != 12500*time.Microsecond ||
+			got[0].Protocol != "dot" || got[0].Network != "office-lan" || got[1].Error == nil ||
+			!got[0].Time.Equal(results[0].Time) || got[0].Offset != 3*time.Millisecond || !got[1].Time.IsZero() {
+			t.Errorf("%s: results did not round trip: %+v", name, got)
+		}
+	}
+}
+
+func TestReadResultsUpgradesVersion0(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"old.json": `{"provenance": {"version": "dev"}, "results": [{"server": "https://dns.google/dns-query", "domain": "example.com", "duration_ms": 20}]}`,
+		"old.csv":  "# dns-bench run provenance\n# version: dev\nServer,Domain,Duration_ms,Error,Network\nhttps://dns.google/dns-query,example.com,20.0000,,\n",
+		// History files prepend a Timestamp column
+		"history.csv": "Timestamp,Server,Domain,Duration_ms,Error,Network\n20260101-000000,https://dns.google/dns-query,example.com,20.0000,,home\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readResults(path)
+		if err != nil {
+			t.Fatalf("%s: readResults failed: %v", name, err)
+		}
+		if len(got) != 1 || got[0].Domain != "example.com" || got[0].Duration != 20*time.Millisecond || got[0].Protocol != "doh" {
+			t.Errorf("%s: expected one upgraded doh result, got %+v", name, got)
+		}
+	}
+}
+
+func TestReadResultsRelabelsVersion1Protocols(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"v1.json": `{"schema_version": 1, "results": [{"server": "h3://dns.google/dns-query", "domain": "example.com", "duration_ms": 20, "protocol": "h3"}]}`,
+		"v1.csv":  csvSchemaPrefix + "1\nServer,Domain,Duration_ms,Error,Network,Protocol\nh3://dns.google/dns-query,example.com,20.0000,,,h3\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readResults(path)
+		if err != nil {
+			t.Fatalf("%s: readResults failed: %v", name, err)
+		}
+		if len(got) != 1 || got[0].Protocol != "doh3" {
+			t.Errorf("%s: expected one doh3 result, got %+v", name, got)
+		}
+	}
+}
+
+func TestReadResultsRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.csv")
+	content := csvSchemaPrefix + "99\nServer,Domain,Duration_ms\n8.8.8.8,example.com,1\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readResults(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected an error for a newer schema version, got %v", err)
+	}
+}
@@ -24,10 +24,17 @@ echo "==> Benchmark complete. Appending to history CSV..."
 
 # Append this run's results to the master history CSV, tagging each row with the timestamp.
 if [ ! -f "${HISTORY_CSV}" ]; then
-  echo "Timestamp,Server,Domain,Duration_ms,Error" > "${HISTORY_CSV}"
+  echo "Timestamp,Server,Domain,Duration_ms,Error,Network,Protocol,Category,Time,Offset_ms,Rcode" > "${HISTORY_CSV}"
+fi
+# Append data rows (skip the provenance comments and header of the per-run CSV)
+grep -v '^#' "${RUN_CSV}" | tail -n +2 | sed "s/^/${TIMESTAMP},/" >> "${HISTORY_CSV}"
+
+# Apply retention when configured (e.g. KEEP_DAYS=90 or KEEP_RUNS=500), so
+# long-running deployments don't grow the results volume without bound.
+if [ -n "${KEEP_DAYS}" ] || [ -n "${KEEP_RUNS}" ]; then
+  echo "==> Pruning old results..."
+  /app/dns-bench prune -keep-days="${KEEP_DAYS:-0}" -keep-runs="${KEEP_RUNS:-0}" "${RESULTS_DIR}"
 fi
-# Append data rows (skip header line of the per-run CSV)
-tail -n +2 "${RUN_CSV}" | sed "s/^/${TIMESTAMP},/" >> "${HISTORY_CSV}"
 
 echo "==> Regenerating dashboard..."
 
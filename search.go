@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"github.com/miekg/dns"
+)
+
+// defaultNdots is the resolv.conf(5) default.
+const defaultNdots = 1
+
+// systemSearch is the -search value that loads the search list and ndots
+// from /etc/resolv.conf.
+const systemSearch = "system"
+
+// resolveSearch turns the configured search list into domains and an ndots
+// value. "system" reads both from /etc/resolv.conf; an explicit ndots always
+// wins.
+func resolveSearch(search []string, ndots *int) ([]string, int, error) {
+	n := defaultNdots
+	if len(search) == 1 && search[0] == systemSearch {
+		conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading /etc/resolv.conf: %w", err)
+		}
+		search, n = conf.Search, conf.Ndots
+	}
+	if ndots != nil {
+		n = *ndots
+	}
+	return search, n, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// searchStats measures what search list expansion costs a server: how many
+// queries each lookup took and how much latency went on the NXDOMAIN (or
+// empty) answers before the useful one.
+type searchStats struct {
+	Lookups     int
+	Queries     int
+	Expanded    int           // Lookups that needed more than one query
+	AvgOverhead time.Duration // Mean time per lookup spent on discarded queries
+	AvgTotal    time.Duration // Mean lookup latency including the overhead
+
+	overhead, total time.Duration
+}
+
+func (s *searchStats) add(res benchmark.Result) {
+	if res.SearchQueries == 0 {
+		return
+	}
+	s.Lookups++
+	s.Queries += res.SearchQueries
+	if res.SearchQueries > 1 {
+		s.Expanded++
+	}
+	s.overhead += res.SearchOverhead
+	s.total += res.Duration
+}
+
+func (s *searchStats) finish() {
+	if s.Lookups > 0 {
+		s.AvgOverhead = s.overhead / time.Duration(s.Lookups)
+		s.AvgTotal = s.total / time.Duration(s.Lookups)
+	}
+}
+
+// queriesPerLookup is the average number of queries behind one lookup.
+func (s *searchStats) queriesPerLookup() float64 {
+	if s.Lookups == 0 {
+		return 0
+	}
+	return float64(s.Queries) / float64(s.Lookups)
+}
+
+// overheadShare is the percentage of lookup latency caused by the search list.
+func (s *searchStats) overheadShare() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	return float64(s.overhead) / float64(s.total) * 100
+}
+
+// printSearch shows the hidden cost of search list expansion per server.
+func printSearch(stats []*ServerStats) {
+	var searched []*ServerStats
+	for _, s := range stats {
+		if s.Search.Lookups > 0 {
+			searched = append(searched, s)
+		}
+	}
+	if len(searched) == 0 {
+		return
+	}
+
+	fmt.Printf("\nSearch List Cost (successful lookups)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tQUERIES/LOOKUP\tEXPANDED\tAVG WASTED\tAVG TOTAL\tWASTED %"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range searched {
+		v := &s.Search
+		if _, err := fmt.Fprintf(w, "%s\t%.2f\t%d/%d\t%v\t%v\t%.1f%%\n", s.Server, v.queriesPerLookup(),
+			v.Expanded, v.Lookups, v.AvgOverhead, v.AvgTotal, v.overheadShare()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
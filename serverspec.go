@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns-bench/benchmark"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serverEntry is one entry of a YAML servers list: a bare address, or a
+// mapping that also sets options for that server, e.g.
+// {address: 1.1.1.1, protocol: tls, timeout: 2s, name: "Cloudflare DoT"}.
+type serverEntry struct {
+	Address string `yaml:"address"`
+	// Name labels the server in the results table
+	Name string `yaml:"name,omitempty"`
+	// Protocol (udp, tcp, tls, https or h3) and Port override those of
+	// Address
+	Protocol string `yaml:"protocol,omitempty"`
+	Port     int    `yaml:"port,omitempty"`
+	// Timeout replaces the run's timeout for this server, e.g. a longer
+	// one for DoH than for plain DNS
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (e *serverEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = serverEntry{Address: value.Value}
+		return nil
+	}
+	type plain serverEntry
+	var p plain
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	if p.Address == "" {
+		return fmt.Errorf("line %d: server entry has no address", value.Line)
+	}
+	*e = serverEntry(p)
+	return nil
+}
+
+// MarshalYAML writes entries without options as bare addresses, so saved
+// configs look like the ones written by hand.
+func (e serverEntry) MarshalYAML() (any, error) {
+	if e == (serverEntry{Address: e.Address}) {
+		return e.Address, nil
+	}
+	type plain serverEntry
+	return plain(e), nil
+}
+
+// serverEntries wraps bare addresses as entries without options.
+func serverEntries(addresses []string) []serverEntry {
+	entries := make([]serverEntry, len(addresses))
+	for i, a := range addresses {
+		entries[i] = serverEntry{Address: a}
+	}
+	return entries
+}
+
+// address returns the server address the entry stands for, with its
+// protocol and port applied: {address: 1.1.1.1, protocol: tls, port: 8853}
+// is tls://1.1.1.1:8853. DoH addresses without a path get /dns-query.
+func (e serverEntry) address() (string, error) {
+	addr := strings.TrimSpace(e.Address)
+	if e.Protocol == "" && e.Port == 0 {
+		return addr, nil
+	}
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if !ok {
+		scheme, rest = "", addr
+	}
+	if e.Protocol != "" {
+		if ok {
+			return "", fmt.Errorf("server %s: protocol %s conflicts with the address's scheme", addr, e.Protocol)
+		}
+		switch p := strings.ToLower(e.Protocol); p {
+		case "udp":
+		case "tcp", "tls", "https", "h3":
+			scheme = p
+		default:
+			return "", fmt.Errorf("server %s: invalid protocol %q (expected udp, tcp, tls, https or h3)", addr, e.Protocol)
+		}
+	}
+	if e.Port < 0 || e.Port > 65535 {
+		return "", fmt.Errorf("server %s: invalid port %d", addr, e.Port)
+	}
+
+	if scheme == "https" || scheme == "h3" {
+		u, err := url.Parse("https://" + rest)
+		if err != nil {
+			return "", fmt.Errorf("server %s: %w", addr, err)
+		}
+		if e.Port != 0 {
+			u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(e.Port))
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return scheme + "://" + strings.TrimPrefix(u.String(), "https://"), nil
+	}
+	if e.Port != 0 {
+		host := rest
+		if h, _, err := net.SplitHostPort(rest); err == nil {
+			host = h
+		}
+		rest = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(e.Port))
+	}
+	if scheme == "" {
+		return rest, nil
+	}
+	return scheme + "://" + rest, nil
+}
+
+// serverSpecs resolves entries to server addresses and the options set for
+// them, keyed by address.
+func serverSpecs(entries []serverEntry) ([]string, map[string]benchmark.ServerSpec, error) {
+	var servers []string
+	specs := make(map[string]benchmark.ServerSpec)
+	for _, e := range entries {
+		addr, err := e.address()
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.Timeout < 0 {
+			return nil, nil, fmt.Errorf("server %s: invalid timeout %v", addr, e.Timeout)
+		}
+		servers = append(servers, addr)
+		if e.Name == "" && e.Timeout == 0 {
+			continue
+		}
+		if _, ok := specs[addr]; ok {
+			return nil, nil, fmt.Errorf("server %s is listed twice with options", addr)
+		}
+		specs[addr] = benchmark.ServerSpec{Name: e.Name, Timeout: e.Timeout}
+	}
+	return servers, specs, nil
+}
+
+// specEntries turns servers and their specs back into entries, for saving
+// the effective configuration.
+func specEntries(servers []string, specs map[string]benchmark.ServerSpec) []serverEntry {
+	entries := serverEntries(servers)
+	for i := range entries {
+		spec := specs[entries[i].Address]
+		entries[i].Name, entries[i].Timeout = spec.Name, spec.Timeout
+	}
+	return entries
+}
+
+// label is how the results table names a server: its address, after its
+// name when it has one.
+func (s *ServerStats) label() string {
+	if s.Name != "" {
+		return fmt.Sprintf("%s (%s)", s.Name, s.Server)
+	}
+	return s.Server
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestServerEntriesYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.yaml")
+	content := `servers:
+  - 8.8.8.8
+  - {address: 1.1.1.1, timeout: 500ms, name: "Cloudflare UDP"}
+  - address: 1.1.1.1
+    protocol: tls
+    port: 8853
+    timeout: 2s
+  - {address: dns.google, protocol: https}
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readServers(path, nil)
+	if err != nil {
+		t.Fatalf("readServers failed: %v", err)
+	}
+	servers, specs, err := serverSpecs(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"8.8.8.8", "1.1.1.1", "tls://1.1.1.1:8853", "https://dns.google/dns-query"}
+	if !slices.Equal(servers, want) {
+		t.Errorf("expected %v, got %v", want, servers)
+	}
+	if len(specs) != 2 || specs["1.1.1.1"].Name != "Cloudflare UDP" || specs["1.1.1.1"].Timeout != 500*time.Millisecond || specs["tls://1.1.1.1:8853"].Timeout != 2*time.Second {
+		t.Errorf("unexpected specs %+v", specs)
+	}
+
+	// Saved back, entries without options stay bare addresses
+	out, err := yaml.Marshal(specEntries(servers, specs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "- 8.8.8.8\n") || !strings.Contains(string(out), "name: Cloudflare UDP") {
+		t.Errorf("unexpected YAML:\n%s", out)
+	}
+	var again []serverEntry
+	if err := yaml.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if again[1].Timeout != 500*time.Millisecond || again[2].Address != "tls://1.1.1.1:8853" {
+		t.Errorf("entries did not round trip: %+v", again)
+	}
+}
+
+func TestServerEntryAddress(t *testing.T) {
+	for _, tc := range []struct {
+		entry serverEntry
+		want  string
+	}{
+		{serverEntry{Address: "9.9.9.9", Protocol: "tcp"}, "tcp://9.9.9.9"},
+		{serverEntry{Address: "9.9.9.9:53", Port: 5353}, "9.9.9.9:5353"},
+		{serverEntry{Address: "2620:fe::fe", Protocol: "tls"}, "tls://2620:fe::fe"},
+		{serverEntry{Address: "2620:fe::fe", Port: 853, Protocol: "TLS"}, "tls://[2620:fe::fe]:853"},
+		{serverEntry{Address: "https://dns.example/resolve", Port: 8443}, "https://dns.example:8443/resolve"},
+		{serverEntry{Address: "dns.example", Protocol: "h3"}, "h3://dns.example/dns-query"},
+		{serverEntry{Address: "9.9.9.9", Protocol: "udp"}, "9.9.9.9"},
+	} {
+		got, err := tc.entry.address()
+		if err != nil || got != tc.want {
+			t.Errorf("%+v: expected %s, got %s (%v)", tc.entry, tc.want, got, err)
+		}
+	}
+	for _, entry := range []serverEntry{
+		{Address: "tls://9.9.9.9", Protocol: "https"},
+		{Address: "9.9.9.9", Protocol: "quic"},
+		{Address: "9.9.9.9", Port: 70000},
+	} {
+		if _, err := entry.address(); err == nil {
+			t.Errorf("expected %+v to be rejected", entry)
+		}
+	}
+	if _, _, err := serverSpecs([]serverEntry{{Address: "9.9.9.9", Name: "a"}, {Address: "9.9.9.9", Name: "b"}}); err == nil {
+		t.Error("expected one server with two sets of options to be rejected")
+	}
+}
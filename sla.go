@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// slaTarget is one latency bucket of an SLA: the share of queries answered
+// within Threshold, optionally with the Target percentage they must reach.
+type slaTarget struct {
+	Threshold time.Duration
+	Target    float64 // Zero reports attainment without a pass/fail verdict
+}
+
+func (t slaTarget) String() string {
+	if t.Target > 0 {
+		return fmt.Sprintf("≤%v (%s%%)", t.Threshold, strconv.FormatFloat(t.Target, 'f', -1, 64))
+	}
+	return fmt.Sprintf("≤%v", t.Threshold)
+}
+
+// parseSLA parses buckets written as a duration, optionally followed by a
+// colon and the target percentage ("50ms:99"), sorted by threshold.
+func parseSLA(specs []string) ([]slaTarget, error) {
+	targets := make([]slaTarget, 0, len(specs))
+	for _, spec := range specs {
+		threshold, target, hasTarget := strings.Cut(strings.TrimSpace(spec), ":")
+		d, err := time.ParseDuration(threshold)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SLA threshold %q (expected a duration such as 50ms)", threshold)
+		}
+		t := slaTarget{Threshold: d}
+		if hasTarget {
+			t.Target, err = strconv.ParseFloat(strings.TrimSuffix(target, "%"), 64)
+			if err != nil || t.Target <= 0 || t.Target > 100 {
+				return nil, fmt.Errorf("invalid SLA target %q in %q (expected a percentage up to 100)", target, spec)
+			}
+		}
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Threshold < targets[j].Threshold })
+	return targets, nil
+}
+
+// slaAttainment returns, per server, the percentage of queries answered
+// successfully within each target's threshold. Failed queries count against
+// every bucket, as they would against a real SLO.
+func slaAttainment(results []benchmark.Result, targets []slaTarget) map[string][]float64 {
+	met := make(map[string][]int)
+	total := make(map[string]int)
+	for _, res := range results {
+		if met[res.Server] == nil {
+			met[res.Server] = make([]int, len(targets))
+		}
+		total[res.Server]++
+		if res.Error != nil {
+			continue
+		}
+		for i, t := range targets {
+			if res.Duration <= t.Threshold {
+				met[res.Server][i]++
+			}
+		}
+	}
+
+	out := make(map[string][]float64, len(met))
+	for server, counts := range met {
+		pct := make([]float64, len(counts))
+		for i, n := range counts {
+			pct[i] = float64(n) / float64(total[server]) * 100
+		}
+		out[server] = pct
+	}
+	return out
+}
+
+// printSLA shows each server's attainment of the SLA buckets, marking
+// buckets that miss their target, and names the servers that meet them all.
+func printSLA(stats []*ServerStats, results []benchmark.Result, targets []slaTarget) {
+	if len(targets) == 0 {
+		return
+	}
+	attainment := slaAttainment(results, targets)
+
+	fmt.Printf("\nSLA Attainment (share of queries answered within each threshold)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "SERVER"
+	for _, t := range targets {
+		header += "\t" + t.String()
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+
+	hasTargets := false
+	var passing []string
+	for _, s := range stats {
+		row := s.Server
+		pass := true
+		for i, t := range targets {
+			pct := attainment[s.Server][i]
+			row += fmt.Sprintf("\t%.2f%%", pct)
+			if t.Target > 0 {
+				hasTargets = true
+				if pct < t.Target {
+					row += " ✗"
+					pass = false
+				}
+			}
+		}
+		if pass {
+			passing = append(passing, s.Server)
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if !hasTargets {
+		return
+	}
+	if len(passing) == 0 {
+		fmt.Println("⚠️  No server met every SLA target")
+		return
+	}
+	fmt.Printf("✓ Meeting every SLA target: %s\n", strings.Join(passing, ", "))
+}
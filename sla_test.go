@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestParseSLA(t *testing.T) {
+	targets, err := parseSLA([]string{"100ms:99.9", "20ms", "50ms:99%"})
+	if err != nil {
+		t.Fatalf("parseSLA failed: %v", err)
+	}
+	want := []slaTarget{{20 * time.Millisecond, 0}, {50 * time.Millisecond, 99}, {100 * time.Millisecond, 99.9}}
+	if len(targets) != len(want) {
+		t.Fatalf("expected %d targets, got %v", len(want), targets)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d: expected %+v, got %+v", i, want[i], targets[i])
+		}
+	}
+	if targets[1].String() != "≤50ms (99%)" {
+		t.Errorf("unexpected label %q", targets[1].String())
+	}
+
+	for _, bad := range []string{"fast", "0ms", "50ms:0", "50ms:101", "50ms:x"} {
+		if _, err := parseSLA([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSLAAttainment(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "8.8.8.8", Duration: 10 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: 40 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: 90 * time.Millisecond},
+		{Server: "8.8.8.8", Duration: time.Millisecond, Error: errors.New("timeout")},
+	}
+	targets := []slaTarget{{Threshold: 20 * time.Millisecond}, {Threshold: 50 * time.Millisecond}, {Threshold: 100 * time.Millisecond}}
+
+	got := slaAttainment(results, targets)["8.8.8.8"]
+	want := []float64{25, 50, 75} // The failed query misses every bucket
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bucket %v: expected %.0f%%, got %.2f%%", targets[i].Threshold, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// defaultSlowest is how many of each server's slowest queries are kept
+// unless -slowest says otherwise.
+const defaultSlowest = 5
+
+// slowQueries are the answered queries of one server that took longer than
+// the slow threshold.
+type slowQueries struct {
+	Server string
+	// Count is the number of answered queries over the threshold, out of
+	// Answered
+	Count    int
+	Answered int
+	// Slowest are the slowest of them, slowest first
+	Slowest []benchmark.Result
+}
+
+// findSlowQueries collects, for each server in stats order that had any, the
+// answered queries slower than threshold, keeping the n slowest. Failed
+// queries are left to the timeout and error tables.
+func findSlowQueries(stats []*ServerStats, results []benchmark.Result, threshold time.Duration, n int) []slowQueries {
+	byServer := make(map[string]*slowQueries)
+	for _, res := range results {
+		if res.Error != nil {
+			continue
+		}
+		sq := byServer[res.Server]
+		if sq == nil {
+			sq = &slowQueries{Server: res.Server}
+			byServer[res.Server] = sq
+		}
+		sq.Answered++
+		if res.Duration > threshold {
+			sq.Count++
+			sq.Slowest = append(sq.Slowest, res)
+		}
+	}
+
+	var out []slowQueries
+	for _, s := range stats {
+		sq := byServer[s.Server]
+		if sq == nil || sq.Count == 0 {
+			continue
+		}
+		slices.SortStableFunc(sq.Slowest, func(a, b benchmark.Result) int { return cmp.Compare(b.Duration, a.Duration) })
+		if len(sq.Slowest) > n {
+			sq.Slowest = sq.Slowest[:n]
+		}
+		out = append(out, *sq)
+	}
+	return out
+}
+
+// printSlowQueries lists the n slowest queries over threshold for each
+// server, with the domain and response code of each. It stays quiet when no
+// query was slow.
+func printSlowQueries(slow []slowQueries, threshold time.Duration, n int) {
+	if len(slow) == 0 {
+		return
+	}
+
+	fmt.Printf("\nSlow Queries (over %v, slowest %d per server)\n\n", threshold, n)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tSLOW\tDOMAIN\tRTT\tRCODE"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, sq := range slow {
+		for i, res := range sq.Slowest {
+			server, count := "", ""
+			if i == 0 {
+				server = sq.Server
+				count = fmt.Sprintf("%d/%d", sq.Count, sq.Answered)
+			}
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", server, count, res.Domain, res.Duration, orNone(res.Rcode)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestFindSlowQueries(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "fast", Domain: "a.example", Duration: 10 * time.Millisecond},
+		{Server: "slow", Domain: "a.example", Duration: 600 * time.Millisecond, Rcode: "NOERROR"},
+		{Server: "slow", Domain: "b.example", Duration: 900 * time.Millisecond, Rcode: "SERVFAIL"},
+		{Server: "slow", Domain: "c.example", Duration: 700 * time.Millisecond, Rcode: "NOERROR"},
+		{Server: "slow", Domain: "d.example", Duration: 20 * time.Millisecond},
+		{Server: "slow", Domain: "e.example", Error: errors.New("i/o timeout")},
+	}
+	stats := calculateStats(results)
+
+	slow := findSlowQueries(stats, results, 500*time.Millisecond, 2)
+	if len(slow) != 1 || slow[0].Server != "slow" {
+		t.Fatalf("expected only the slow server, got %+v", slow)
+	}
+	sq := slow[0]
+	if sq.Count != 3 || sq.Answered != 4 {
+		t.Errorf("expected 3 of 4 answered queries over the threshold, got %d/%d", sq.Count, sq.Answered)
+	}
+	if len(sq.Slowest) != 2 || sq.Slowest[0].Domain != "b.example" || sq.Slowest[1].Domain != "c.example" {
+		t.Errorf("expected b.example then c.example, got %+v", sq.Slowest)
+	}
+
+	if slow := findSlowQueries(stats, results, time.Second, 2); len(slow) != 0 {
+		t.Errorf("expected nothing over 1s, got %+v", slow)
+	}
+}
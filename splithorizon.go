@@ -0,0 +1,182 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+
+	"github.com/miekg/dns"
+)
+
+// Server roles in a split-horizon check.
+const (
+	roleInternal = "internal"
+	roleExternal = "external"
+)
+
+// splitHorizonStats is how one server handled the internal-only names: an
+// internal server has to resolve them, an external one must not.
+type splitHorizonStats struct {
+	Server   string
+	Role     string
+	Checked  int
+	Resolved int
+	// Problems are the names an internal server failed to resolve or an
+	// external one leaked, each with the reason
+	Problems []string
+}
+
+// problemCount is the number of queries that went wrong for the server's
+// role.
+func (s splitHorizonStats) problemCount() int {
+	if s.Role == roleInternal {
+		return s.Checked - s.Resolved
+	}
+	return s.Resolved
+}
+
+// splitRoles assigns each benchmarked server its role. Servers that are in
+// neither list are external when only internal servers are listed and
+// internal when only external ones are; with both lists they are left out
+// of the check.
+func splitRoles(servers, internal, external []string) map[string]string {
+	roles := make(map[string]string)
+	for _, s := range servers {
+		switch {
+		case slices.Contains(internal, s):
+			roles[s] = roleInternal
+		case slices.Contains(external, s):
+			roles[s] = roleExternal
+		case len(external) == 0:
+			roles[s] = roleExternal
+		case len(internal) == 0:
+			roles[s] = roleInternal
+		}
+	}
+	return roles
+}
+
+// isInternalName reports whether domain is one of the internal-only names
+// or below one of them.
+func isInternalName(domain string, internal []string) bool {
+	for _, name := range internal {
+		if dns.IsSubDomain(dns.Fqdn(strings.ToLower(name)), dns.Fqdn(strings.ToLower(domain))) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnSplitHorizon points out internal names and servers that match
+// nothing being benchmarked, which would leave the check empty.
+func warnSplitHorizon(cfg *Config, servers, domains []string, warns *warningLog) {
+	for _, s := range slices.Concat(cfg.InternalServers, cfg.ExternalServers) {
+		if !slices.Contains(servers, s) {
+			warns.add("split-horizon", "split-horizon server %s is not being benchmarked", s)
+		}
+	}
+	if !slices.ContainsFunc(domains, func(d string) bool { return isInternalName(d, cfg.InternalDomains) }) {
+		warns.add("split-horizon", "none of the benchmarked domains is in internal_domains (%s)", strings.Join(cfg.InternalDomains, ", "))
+	}
+}
+
+// checkSplitHorizon checks every result for an internal-only name against
+// its server's role. A name counts as resolved when the answer was NOERROR
+// with records in the answer section.
+func checkSplitHorizon(stats []*ServerStats, results []benchmark.Result, internalNames []string, roles map[string]string) []splitHorizonStats {
+	byServer := make(map[string]*splitHorizonStats)
+	problems := make(map[string]map[string]int)
+	for _, res := range results {
+		role, ok := roles[res.Server]
+		if !ok || !isInternalName(res.Domain, internalNames) {
+			continue
+		}
+		s := byServer[res.Server]
+		if s == nil {
+			s = &splitHorizonStats{Server: res.Server, Role: role}
+			byServer[res.Server] = s
+			problems[res.Server] = make(map[string]int)
+		}
+		s.Checked++
+		resolved := res.Error == nil && res.Rcode == dns.RcodeToString[dns.RcodeSuccess] && res.Answers > 0
+		if resolved {
+			s.Resolved++
+		}
+		switch {
+		case role == roleInternal && !resolved:
+			problems[res.Server][fmt.Sprintf("%s (%s)", res.Domain, cmp.Or(res.Category, "empty answer"))]++
+		case role == roleExternal && resolved:
+			problems[res.Server][res.Domain+" (leaked)"]++
+		}
+	}
+
+	var out []splitHorizonStats
+	for _, st := range stats {
+		s := byServer[st.Server]
+		if s == nil {
+			continue
+		}
+		counts := problems[st.Server]
+		for p := range counts {
+			s.Problems = append(s.Problems, p)
+		}
+		slices.SortFunc(s.Problems, func(a, b string) int {
+			return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+		})
+		if len(s.Problems) > maxMismatches {
+			s.Problems = s.Problems[:maxMismatches]
+		}
+		out = append(out, *s)
+	}
+	// Internal servers first, keeping the ranking within each role
+	slices.SortStableFunc(out, func(a, b splitHorizonStats) int {
+		return cmp.Compare(b.Role, a.Role)
+	})
+	return out
+}
+
+// printSplitHorizon shows whether the internal-only names resolved via the
+// internal servers and only there.
+func printSplitHorizon(checks []splitHorizonStats) {
+	if len(checks) == 0 {
+		return
+	}
+
+	fmt.Printf("\nSplit-Horizon Check (internal-only names)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tROLE\tCHECKED\tRESOLVED\tPROBLEMS"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	var failed, leaked []string
+	for _, c := range checks {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", c.Server, c.Role, c.Checked, c.Resolved, orNone(strings.Join(c.Problems, "; "))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+		if c.problemCount() == 0 {
+			continue
+		}
+		if c.Role == roleInternal {
+			failed = append(failed, c.Server)
+		} else {
+			leaked = append(leaked, c.Server)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	if len(leaked) > 0 {
+		fmt.Printf("⚠️  Internal names resolved by external servers, so the internal zone leaks: %s\n", strings.Join(leaked, ", "))
+	}
+	if len(failed) > 0 {
+		fmt.Printf("✗ Internal servers that failed to resolve internal names: %s\n", strings.Join(failed, ", "))
+	}
+	if len(leaked) == 0 && len(failed) == 0 {
+		fmt.Println("✓ Internal names resolved on every internal server and on no external one")
+	}
+}
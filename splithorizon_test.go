@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestSplitRoles(t *testing.T) {
+	servers := []string{"10.0.0.53", "10.0.1.53", "8.8.8.8"}
+	roles := splitRoles(servers, []string{"10.0.0.53", "10.0.1.53"}, nil)
+	if roles["10.0.0.53"] != roleInternal || roles["8.8.8.8"] != roleExternal {
+		t.Errorf("expected unlisted servers to be external, got %v", roles)
+	}
+	roles = splitRoles(servers, []string{"10.0.0.53"}, []string{"8.8.8.8"})
+	if _, ok := roles["10.0.1.53"]; ok || len(roles) != 2 {
+		t.Errorf("expected unlisted servers to be left out with both lists, got %v", roles)
+	}
+}
+
+func TestCheckSplitHorizon(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "10.0.0.53", Domain: "intranet.corp.example", Rcode: "NOERROR", Answers: 1},
+		{Server: "10.0.0.53", Domain: "Wiki.Corp.Example", Rcode: "NXDOMAIN", Category: benchmark.CategoryNXDomain},
+		{Server: "8.8.8.8", Domain: "intranet.corp.example", Rcode: "NOERROR", Answers: 1},
+		{Server: "8.8.8.8", Domain: "wiki.corp.example", Error: errors.New("i/o timeout"), Category: benchmark.CategoryTimeout},
+		{Server: "1.1.1.1", Domain: "intranet.corp.example", Rcode: "NXDOMAIN"},
+		{Server: "1.1.1.1", Domain: "example.com", Rcode: "NOERROR", Answers: 1},
+	}
+	stats := calculateStats(results)
+	roles := splitRoles([]string{"10.0.0.53", "8.8.8.8", "1.1.1.1"}, []string{"10.0.0.53"}, nil)
+	checks := checkSplitHorizon(stats, results, []string{"corp.example"}, roles)
+	if len(checks) != 3 || checks[0].Server != "10.0.0.53" {
+		t.Fatalf("expected the internal server first and 3 servers checked, got %+v", checks)
+	}
+
+	byServer := make(map[string]splitHorizonStats)
+	for _, c := range checks {
+		byServer[c.Server] = c
+	}
+	if c := byServer["10.0.0.53"]; c.problemCount() != 1 || !strings.Contains(strings.Join(c.Problems, ";"), "(nxdomain)") {
+		t.Errorf("expected the internal server's NXDOMAIN to be a failure, got %+v", c)
+	}
+	if c := byServer["8.8.8.8"]; c.problemCount() != 1 || c.Problems[0] != "intranet.corp.example (leaked)" {
+		t.Errorf("expected 8.8.8.8 to leak intranet.corp.example, got %+v", c)
+	}
+	if c := byServer["1.1.1.1"]; c.Checked != 1 || c.problemCount() != 0 {
+		t.Errorf("expected 1.1.1.1 to pass with only the internal name checked, got %+v", c)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !nosqlite && !mips && !mipsle && !mips64 && !mips64le
+
+package main
+
+// Import sqlite driver for database/sql (pure Go, no CGO required)
+import _ "modernc.org/sqlite"
+
+// haveSQLite reports whether this build can use a results database.
+const haveSQLite = true
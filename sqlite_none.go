@@ -0,0 +1,7 @@
+//go:build nosqlite || mips || mipsle || mips64 || mips64le
+
+package main
+
+// haveSQLite is false in builds without the SQLite driver: those tagged
+// nosqlite and the MIPS targets modernc.org/sqlite doesn't support.
+const haveSQLite = false
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// stubCacheStats models a caching stub resolver (dnsmasq, unbound, ...) in
+// front of one server. A client looks up every domain once per interval on
+// average, more popular domains more often; the stub answers from its cache
+// while the server's TTL lasts and asks the server again once it expires.
+// Cache hits are taken to cost nothing.
+type stubCacheStats struct {
+	Server string
+	// Upstream is the average latency without a cache, over the same
+	// domains and with the same weights as Effective
+	Upstream time.Duration
+	// HitPct is the share of lookups answered from the stub's cache
+	HitPct float64
+	// Effective is the average latency a client would see, hits included
+	Effective time.Duration
+	// AvgTTL is the average TTL the server handed out
+	AvgTTL time.Duration
+}
+
+// domainSample is a server's average latency and TTL for one domain over
+// its successful queries.
+type domainSample struct {
+	Latency, TTL time.Duration
+
+	n int
+}
+
+// domainSamples averages every server's successful queries per domain,
+// keyed by server and then lowercased domain.
+func domainSamples(results []benchmark.Result) map[string]map[string]*domainSample {
+	byServer := make(map[string]map[string]*domainSample)
+	for _, res := range results {
+		if res.Error != nil {
+			continue
+		}
+		domains := byServer[res.Server]
+		if domains == nil {
+			domains = make(map[string]*domainSample)
+			byServer[res.Server] = domains
+		}
+		name := strings.ToLower(res.Domain)
+		d := domains[name]
+		if d == nil {
+			d = &domainSample{}
+			domains[name] = d
+		}
+		// Running means, so the sums can't overflow on long runs
+		d.n++
+		d.Latency += (res.Duration - d.Latency) / time.Duration(d.n)
+		d.TTL += (res.TTL - d.TTL) / time.Duration(d.n)
+	}
+	return byServer
+}
+
+// normalizeWeights scales the weights of domains to sum to one. Domains
+// without a weight count as much as the lightest one that has one, and with
+// no weights at all every domain counts the same.
+func normalizeWeights(domains []string, weights map[string]float64) map[string]float64 {
+	lightest := 0.0
+	for _, d := range domains {
+		if w := weights[d]; w > 0 && (lightest == 0 || w < lightest) {
+			lightest = w
+		}
+	}
+	if lightest == 0 {
+		lightest = 1
+	}
+	out := make(map[string]float64, len(domains))
+	total := 0.0
+	for _, d := range domains {
+		w := weights[d]
+		if w <= 0 {
+			w = lightest
+		}
+		out[d] = w
+		total += w
+	}
+	for d := range out {
+		out[d] /= total
+	}
+	return out
+}
+
+// missRate is the share of a domain's lookups a stub cache passes on to the
+// server: once per TTL, or every time when lookups are further apart than
+// the TTL. The domain is looked up once per interval divided by its share
+// of all lookups relative to an average domain.
+func missRate(ttl, interval time.Duration, share float64, domains int) float64 {
+	every := float64(interval) / (share * float64(domains))
+	if float64(ttl) <= every {
+		return 1
+	}
+	return every / float64(ttl)
+}
+
+// simulateStubCache runs the stub cache model for every server over the
+// domains it answered, weighted by how often each domain is looked up
+// (lowercased names; nil weighs them equally).
+func simulateStubCache(results []benchmark.Result, interval time.Duration, weights map[string]float64) []stubCacheStats {
+	samples := domainSamples(results)
+	out := make([]stubCacheStats, 0, len(samples))
+	for server, domains := range samples {
+		names := make([]string, 0, len(domains))
+		for d := range domains {
+			names = append(names, d)
+		}
+		shares := normalizeWeights(names, weights)
+
+		var upstream, effective, ttl, misses float64
+		for d, sample := range domains {
+			share := shares[d]
+			miss := missRate(sample.TTL, interval, share, len(domains))
+			upstream += share * float64(sample.Latency)
+			effective += share * miss * float64(sample.Latency)
+			ttl += share * float64(sample.TTL)
+			misses += share * miss
+		}
+		out = append(out, stubCacheStats{
+			Server:    server,
+			Upstream:  time.Duration(upstream),
+			HitPct:    (1 - misses) * 100,
+			Effective: time.Duration(effective),
+			AvgTTL:    time.Duration(ttl).Round(time.Second),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Effective != out[j].Effective {
+			return out[i].Effective < out[j].Effective
+		}
+		return out[i].Server < out[j].Server
+	})
+	return out
+}
+
+// printStubCache shows each server's latency behind a simulated caching
+// stub, ranked by the effective latency.
+func printStubCache(sim []stubCacheStats, interval time.Duration) {
+	if len(sim) == 0 {
+		return
+	}
+
+	fmt.Printf("\nStub Cache Simulation (each domain looked up every %v on average, cache hits free)\n\n", interval)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tAVG (NO CACHE)\tAVG TTL\tHIT %\tEFFECTIVE AVG"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range sim {
+		if _, err := fmt.Fprintf(w, "%s\t%v\t%v\t%.1f%%\t%v\n", s.Server, s.Upstream, s.AvgTTL, s.HitPct, s.Effective); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
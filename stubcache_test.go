@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestSimulateStubCache(t *testing.T) {
+	results := []benchmark.Result{
+		// "long" hands out 10-minute TTLs, so a lookup every minute reaches
+		// it once in ten; "short" hands out 30s TTLs and is always asked.
+		{Server: "long", Domain: "a.example", Duration: 40 * time.Millisecond, TTL: 10 * time.Minute},
+		{Server: "long", Domain: "a.example", Duration: 60 * time.Millisecond, TTL: 10 * time.Minute},
+		{Server: "short", Domain: "a.example", Duration: 20 * time.Millisecond, TTL: 30 * time.Second},
+		{Server: "short", Domain: "b.example", Error: os.ErrDeadlineExceeded},
+	}
+
+	sim := simulateStubCache(results, time.Minute, nil)
+	if len(sim) != 2 || sim[0].Server != "long" {
+		t.Fatalf("expected long to rank first, got %+v", sim)
+	}
+	long, short := sim[0], sim[1]
+	if long.Upstream != 50*time.Millisecond || long.Effective != 5*time.Millisecond || long.HitPct != 90 {
+		t.Errorf("unexpected long simulation: %+v", long)
+	}
+	if short.Effective != short.Upstream || short.HitPct != 0 || short.AvgTTL != 30*time.Second {
+		t.Errorf("unexpected short simulation: %+v", short)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"dns-bench/benchmark"
+)
+
+// svcbStats aggregates the HTTPS/SVCB answers a server returned, showing
+// whether it hands out the records browsers rely on for ALPN and ECH.
+type svcbStats struct {
+	Answered    int            // Successful HTTPS/SVCB queries
+	WithRecords int            // ... whose answer contained at least one record
+	AliasMode   int            // ... with an alias-mode record
+	ECH         int            // ... publishing an ECH config
+	IPHints     int            // ... carrying ipv4hint/ipv6hint
+	ALPN        map[string]int // Answers advertising each ALPN id
+}
+
+func (v *svcbStats) add(res benchmark.Result) {
+	if res.SVCB == nil {
+		return
+	}
+	v.Answered++
+	if res.SVCB.Records == 0 {
+		return
+	}
+	v.WithRecords++
+	if res.SVCB.AliasMode {
+		v.AliasMode++
+	}
+	if res.SVCB.ECH {
+		v.ECH++
+	}
+	if res.SVCB.IPHints {
+		v.IPHints++
+	}
+	if v.ALPN == nil {
+		v.ALPN = make(map[string]int)
+	}
+	for _, id := range res.SVCB.ALPN {
+		v.ALPN[id]++
+	}
+}
+
+// alpnSummary lists ALPN ids by how many answers advertised them.
+func (v *svcbStats) alpnSummary() string {
+	if len(v.ALPN) == 0 {
+		return "-"
+	}
+	ids := make([]string, 0, len(v.ALPN))
+	for id := range v.ALPN {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if v.ALPN[ids[i]] != v.ALPN[ids[j]] {
+			return v.ALPN[ids[i]] > v.ALPN[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s (%d)", id, v.ALPN[id])
+	}
+	return strings.Join(parts, " ")
+}
+
+// printSVCB summarises the HTTPS/SVCB records each server returned. Servers
+// that answer with far fewer records than their peers for the same names are
+// likely filtering or mishandling the type.
+func printSVCB(stats []*ServerStats) {
+	var answered []*ServerStats
+	for _, s := range stats {
+		if s.SVCB.Answered > 0 {
+			answered = append(answered, s)
+		}
+	}
+	if len(answered) == 0 {
+		return
+	}
+
+	fmt.Printf("\nHTTPS/SVCB Answers\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tWITH RECORDS\tALIAS\tECH\tIP HINTS\tALPN"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range answered {
+		v := &s.SVCB
+		if _, err := fmt.Fprintf(w, "%s\t%d/%d\t%d\t%d\t%d\t%s\n", s.Server, v.WithRecords, v.Answered,
+			v.AliasMode, v.ECH, v.IPHints, v.alpnSummary()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestCalculateStatsSVCB(t *testing.T) {
+	results := []benchmark.Result{
+		{Server: "1.1.1.1", Domain: "a.com", Duration: 10 * time.Millisecond,
+			SVCB: &benchmark.SVCBInfo{Records: 1, ALPN: []string{"h3", "h2"}, ECH: true, IPHints: true}},
+		{Server: "1.1.1.1", Domain: "b.com", Duration: 10 * time.Millisecond,
+			SVCB: &benchmark.SVCBInfo{Records: 1, ALPN: []string{"h2"}}},
+		{Server: "1.1.1.1", Domain: "c.com", Duration: 10 * time.Millisecond, SVCB: &benchmark.SVCBInfo{}},
+		{Server: "8.8.8.8", Domain: "a.com", Duration: 10 * time.Millisecond},
+	}
+
+	for _, s := range calculateStats(results) {
+		switch s.Server {
+		case "1.1.1.1":
+			if s.SVCB.Answered != 3 || s.SVCB.WithRecords != 2 || s.SVCB.ECH != 1 || s.SVCB.IPHints != 1 {
+				t.Errorf("unexpected SVCB stats: %+v", s.SVCB)
+			}
+			if got := s.SVCB.alpnSummary(); got != "h2 (2) h3 (1)" {
+				t.Errorf("expected ALPN ids ordered by count, got %q", got)
+			}
+		case "8.8.8.8":
+			if s.SVCB.Answered != 0 || s.SVCB.alpnSummary() != "-" {
+				t.Errorf("expected no SVCB stats for an A query, got %+v", s.SVCB)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// tiebreakMinSamples is the fewest samples per server the top two are
+// compared on; below it the normal approximation of the test is too rough.
+const tiebreakMinSamples = 30
+
+// tiebreakTest compares the average latency of the servers ranked first and
+// second with a two-sided Welch's t-test.
+type tiebreakTest struct {
+	First, Second       string
+	Samples1, Samples2  int
+	AvgFirst, AvgSecond time.Duration
+	P                   float64
+	// Alpha is the significance level this look is held to (see alphaSpent)
+	Alpha float64
+}
+
+// significant reports whether the first server is faster by more than the
+// noise, at the look's significance level.
+func (t tiebreakTest) significant() bool {
+	return t.Samples1 >= tiebreakMinSamples && t.Samples2 >= tiebreakMinSamples && t.P < t.Alpha
+}
+
+// alphaSpent is how much of duelAlpha the tests may have spent once either
+// server has fraction of the cap's samples, by Lan and DeMets' O'Brien-Fleming
+// spending function. Every look after the main run and after each extra round
+// is tested at its increment of it, so however many rounds it takes, two
+// equally fast servers are called apart at most duelAlpha of the time. Early
+// looks, on few samples, need a wide gap; the one at the cap gets the rest.
+func alphaSpent(fraction float64) float64 {
+	switch {
+	case fraction >= 1:
+		return duelAlpha
+	case fraction <= 0:
+		return 0
+	}
+	z := math.Sqrt2 * math.Erfinv(1-duelAlpha)
+	return math.Erfc(z / math.Sqrt(fraction) / math.Sqrt2)
+}
+
+// tiebreak is how -tiebreak settled the top two: the test after the main
+// run and, if that was inconclusive, after the extra rounds.
+type tiebreak struct {
+	Before, After tiebreakTest
+	Rounds        int
+	Elapsed       time.Duration
+	// Cap is the samples per server the extra rounds stop at
+	Cap int
+}
+
+// rankingSamples returns the latencies server's rank was decided on: its
+// answered queries and, with a penalty, every timeout counted as that long.
+func rankingSamples(results []benchmark.Result, server string, penalty time.Duration) []time.Duration {
+	var samples []time.Duration
+	for _, res := range results {
+		switch {
+		case res.Server != server:
+		case res.Error == nil:
+			samples = append(samples, res.Duration)
+		case penalty > 0 && isTimeout(res.Error):
+			samples = append(samples, penalty)
+		}
+	}
+	return samples
+}
+
+// testTopTwo compares the first two servers of stats, which must be ranked
+// already; ok is false when fewer than two servers answered anything.
+func testTopTwo(stats []*ServerStats, results []benchmark.Result, penalty time.Duration) (t tiebreakTest, ok bool) {
+	if len(stats) < 2 || stats[1].Success == 0 {
+		return t, false
+	}
+	t.First, t.Second = stats[0].Server, stats[1].Server
+	a, b := rankingSamples(results, t.First, penalty), rankingSamples(results, t.Second, penalty)
+	t.Samples1, t.Samples2 = len(a), len(b)
+	t.AvgFirst, t.AvgSecond, t.P = welch(a, b)
+	return t, true
+}
+
+// welch returns the means of a and b and the two-sided p-value of Welch's
+// t-test on them, using the normal approximation, which the sample sizes
+// of a benchmark allow.
+func welch(a, b []time.Duration) (meanA, meanB time.Duration, p float64) {
+	moments := func(xs []time.Duration) (mean, variance float64) {
+		for _, x := range xs {
+			mean += float64(x)
+		}
+		mean /= float64(len(xs))
+		for _, x := range xs {
+			d := float64(x) - mean
+			variance += d * d
+		}
+		return mean, variance / float64(len(xs)-1)
+	}
+	if len(a) < 2 || len(b) < 2 {
+		return 0, 0, 1
+	}
+	ma, va := moments(a)
+	mb, vb := moments(b)
+	se := math.Sqrt(va/float64(len(a)) + vb/float64(len(b)))
+	switch {
+	case ma == mb:
+		p = 1
+	case se == 0:
+		p = 0
+	default:
+		p = math.Erfc(math.Abs(ma-mb) / se / math.Sqrt2)
+	}
+	return time.Duration(ma), time.Duration(mb), p
+}
+
+// breakTie tests whether the top two of stats are apart by more than the
+// noise and, while they aren't, runs extra rounds of every question against
+// just those two, until they are or either has limit samples. Their results
+// go to config.OnResult as they arrive. It returns the results with the
+// extra rounds appended; stats must then be recalculated from them, since
+// the rounds can swap the two.
+func breakTie(config benchmark.Config, stats []*ServerStats, results []benchmark.Result, limit int, penalty time.Duration,
+	run func(benchmark.Config) []benchmark.Result, rank func([]benchmark.Result) []*ServerStats) ([]benchmark.Result, tiebreak, bool) {
+	var spent float64
+	look := func(t tiebreakTest) tiebreakTest {
+		total := alphaSpent(float64(max(t.Samples1, t.Samples2)) / float64(limit))
+		t.Alpha, spent = max(total-spent, 0), max(total, spent)
+		return t
+	}
+	t, ok := testTopTwo(stats, results, penalty)
+	if !ok {
+		return results, tiebreak{}, false
+	}
+	t = look(t)
+	tb := tiebreak{Before: t, After: t, Cap: limit}
+
+	round := config
+	round.Servers = []string{t.First, t.Second}
+	round.Iterations = 1
+	round.Duration = 0
+	round.ShowProgress = false
+	start := time.Now()
+	for !tb.After.significant() && max(tb.After.Samples1, tb.After.Samples2) < limit {
+		extra := run(round)
+		results = append(results, extra...)
+		tb.Rounds++
+		before := tb.After.Samples1 + tb.After.Samples2
+		if tb.After, ok = testTopTwo(rank(results), results, penalty); !ok || tb.After.Samples1+tb.After.Samples2 == before {
+			break
+		}
+		tb.After = look(tb.After)
+	}
+	tb.Elapsed = time.Since(start)
+	return results, tb, true
+}
+
+// printTiebreak says whether the ranking's #1 is ahead of #2 by more than
+// the noise, and what the extra rounds took to tell.
+func printTiebreak(tb tiebreak) {
+	t := tb.After
+	gap := (t.AvgSecond - t.AvgFirst).Round(time.Microsecond)
+	switch {
+	case tb.Rounds == 0 && t.significant():
+		fmt.Printf("Tiebreak: %s is ahead of %s by %v on average (p=%.3g), no extra rounds needed\n", t.First, t.Second, gap, t.P)
+	case tb.Rounds == 0:
+		fmt.Printf("Tiebreak: %s and %s are within the noise (p=%.2g, needed %.2g, over %d and %d samples), already at the %d-sample cap; treat them as tied\n",
+			t.First, t.Second, t.P, t.Alpha, t.Samples1, t.Samples2, tb.Cap)
+	case t.significant():
+		fmt.Printf("Tiebreak: %d extra rounds for %s and %s (%v); %s is ahead by %v on average (p=%.3g over %d and %d samples)\n",
+			tb.Rounds, tb.Before.First, tb.Before.Second, tb.Elapsed.Round(time.Millisecond), t.First, gap, t.P, t.Samples1, t.Samples2)
+	default:
+		fmt.Printf("Tiebreak: %d extra rounds for %s and %s (%v); still within the noise (p=%.2g, needed %.2g, over %d and %d samples), treat them as tied\n",
+			tb.Rounds, tb.Before.First, tb.Before.Second, tb.Elapsed.Round(time.Millisecond), t.P, t.Alpha, t.Samples1, t.Samples2)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// alternating returns n results for server whose latencies alternate
+// between base and base+spread.
+func alternating(server string, n int, base, spread time.Duration) []benchmark.Result {
+	var results []benchmark.Result
+	for i := range n {
+		results = append(results, benchmark.Result{Server: server, Duration: base + time.Duration(i%2)*spread})
+	}
+	return results
+}
+
+func TestWelch(t *testing.T) {
+	a := alternating("a", 100, 10*time.Millisecond, 10*time.Millisecond)
+	b := alternating("b", 100, 11*time.Millisecond, 10*time.Millisecond)
+	far := alternating("c", 100, 20*time.Millisecond, 10*time.Millisecond)
+	durations := func(results []benchmark.Result) []time.Duration {
+		return rankingSamples(results, results[0].Server, 0)
+	}
+
+	if _, _, p := welch(durations(a), durations(b)); p < duelAlpha {
+		t.Errorf("expected 1ms apart with 5ms of spread to be noise, got p=%v", p)
+	}
+	meanA, meanC, p := welch(durations(a), durations(far))
+	if p >= duelAlpha {
+		t.Errorf("expected 10ms apart to be significant, got p=%v", p)
+	}
+	if meanA != 15*time.Millisecond || meanC != 25*time.Millisecond {
+		t.Errorf("expected means of 15ms and 25ms, got %v and %v", meanA, meanC)
+	}
+}
+
+func TestAlphaSpent(t *testing.T) {
+	if alphaSpent(0) != 0 || alphaSpent(1) != duelAlpha || alphaSpent(2) != duelAlpha {
+		t.Errorf("expected nothing spent at the start and all of it at the cap, got %v and %v", alphaSpent(0), alphaSpent(1))
+	}
+	if math.Abs(alphaSpent(1-1e-9)-duelAlpha) > 1e-6 {
+		t.Errorf("expected the spending to reach duelAlpha smoothly, got %v just short of the cap", alphaSpent(1-1e-9))
+	}
+	// A look at a quarter of the cap needs p below about 0.0001
+	if early := alphaSpent(0.25); early > 0.0001 || early <= 0 {
+		t.Errorf("expected a small share of duelAlpha at a quarter of the cap, got %v", early)
+	}
+}
+
+func TestBreakTie(t *testing.T) {
+	// Each round gives a 40 samples at 10ms/20ms and b 40 at 11ms/21ms: too
+	// close to call on one round, clear after a few
+	round := func(c benchmark.Config) []benchmark.Result {
+		if len(c.Servers) != 2 || c.Iterations != 1 || c.Duration != 0 {
+			t.Fatalf("expected one round against the top two, got %+v", c)
+		}
+		return append(alternating("a", 40, 10*time.Millisecond, 10*time.Millisecond), alternating("b", 40, 11*time.Millisecond, 10*time.Millisecond)...)
+	}
+	results := append(round(benchmark.Config{Servers: []string{"a", "b"}, Iterations: 1}),
+		alternating("c", 40, 50*time.Millisecond, 0)...)
+
+	rounds := 0
+	run := func(c benchmark.Config) []benchmark.Result {
+		rounds++
+		return round(c)
+	}
+	out, tb, ok := breakTie(benchmark.Config{Duration: time.Minute}, calculateStats(results), results, 10000, 0, run, calculateStats)
+	if !ok {
+		t.Fatal("expected the top two to be tested")
+	}
+	if tb.Before.significant() || !tb.After.significant() {
+		t.Errorf("expected the extra rounds to settle the ranking, got %+v", tb)
+	}
+	if tb.After.Alpha >= duelAlpha || tb.After.Alpha <= 0 {
+		t.Errorf("expected the deciding look held to a share of duelAlpha, got %v", tb.After.Alpha)
+	}
+	if tb.After.First != "a" || tb.Rounds != rounds || len(out) != len(results)+80*rounds {
+		t.Errorf("expected a ahead after %d rounds and their results appended, got %+v and %d results", rounds, tb, len(out))
+	}
+
+	// The cap stops the rounds however close the two stay
+	_, tb, _ = breakTie(benchmark.Config{}, calculateStats(results), results, 100, 0, run, calculateStats)
+	if tb.After.significant() || tb.Rounds != 2 {
+		t.Errorf("expected two rounds to hit the 100-sample cap, got %+v", tb)
+	}
+
+	// A single server has nothing to break a tie with
+	if _, _, ok := breakTie(benchmark.Config{}, calculateStats(results[:40]), results[:40], 1000, 0, run, calculateStats); ok {
+		t.Error("expected no test with a single server")
+	}
+}
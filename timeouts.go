@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// isTimeout reports whether a query failed by running out of time rather
+// than with an answer or a connection error. Errors read back from an
+// export are plain strings, so their text is checked as well.
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded")
+}
+
+// timeoutStats separates timeouts from other failures. A timeout is a
+// censored latency: the answer took at least the timeout, if it came at
+// all, so leaving it out of the average flatters a server that times out.
+type timeoutStats struct {
+	Count int
+	// Other counts failures that weren't timeouts (refused, SERVFAIL, ...)
+	Other int
+}
+
+func (t *timeoutStats) add(res benchmark.Result) {
+	if res.Error == nil {
+		return
+	}
+	if isTimeout(res.Error) {
+		t.Count++
+	} else {
+		t.Other++
+	}
+}
+
+// penalizedAvg is the average latency with every timeout counted as
+// taking penalty, a lower bound on what a client actually waited.
+func (s *ServerStats) penalizedAvg(penalty time.Duration) time.Duration {
+	n := s.Success + s.Timeouts.Count
+	if n == 0 {
+		return 0
+	}
+	return (s.TotalTime + time.Duration(s.Timeouts.Count)*penalty) / time.Duration(n)
+}
+
+// rankPenalized reorders stats by penalizedAvg, so timeouts count against
+// a server's rank instead of being dropped from its average.
+func rankPenalized(stats []*ServerStats, penalty time.Duration) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if (a.Success > 0) != (b.Success > 0) {
+			return a.Success > 0
+		}
+		return a.penalizedAvg(penalty) < b.penalizedAvg(penalty)
+	})
+}
+
+// printTimeouts shows each server's timeout rate apart from its other
+// errors, next to its average over answered queries and the penalized
+// average. It stays quiet when nothing timed out.
+func printTimeouts(stats []*ServerStats, penalty time.Duration) {
+	found := false
+	for _, s := range stats {
+		if s.Timeouts.Count > 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+
+	fmt.Printf("\nTimeouts (penalized average counts each timeout as %v)\n\n", penalty)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tTIMEOUTS\tTIMEOUT %\tOTHER ERRORS %\tAVG (ANSWERED)\tPENALIZED AVG"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range stats {
+		timeoutPct := float64(s.Timeouts.Count) / float64(s.Total) * 100
+		otherPct := float64(s.Timeouts.Other) / float64(s.Total) * 100
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%.2f%%\t%.2f%%\t%v\t%v\n", s.Server, s.Timeouts.Count, timeoutPct, otherPct, s.Avg, s.penalizedAvg(penalty)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
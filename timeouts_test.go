@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestIsTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{os.ErrDeadlineExceeded, true},
+		{fmt.Errorf("exchange: %w", context.DeadlineExceeded), true},
+		{errors.New("read udp 127.0.0.1:53: i/o timeout"), true}, // As read back from an export
+		{errors.New("SERVFAIL"), false},
+		{errors.New("connection refused"), false},
+	} {
+		if got := isTimeout(tc.err); got != tc.want {
+			t.Errorf("isTimeout(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestPenalizedRanking(t *testing.T) {
+	// "flaky" answers in 10ms but times out 1 in 20 queries; "steady" takes
+	// 20ms and never times out.
+	var results []benchmark.Result
+	for i := 0; i < 20; i++ {
+		flaky := benchmark.Result{Server: "flaky", Duration: 10 * time.Millisecond}
+		if i == 0 {
+			flaky = benchmark.Result{Server: "flaky", Error: os.ErrDeadlineExceeded}
+		}
+		results = append(results, flaky, benchmark.Result{Server: "steady", Duration: 20 * time.Millisecond})
+	}
+	results = append(results, benchmark.Result{Server: "steady", Error: errors.New("SERVFAIL")})
+
+	stats := calculateStats(results)
+	if stats[0].Server != "flaky" {
+		t.Fatalf("expected flaky to rank first by answered average, got %s", stats[0].Server)
+	}
+	flaky, steady := stats[0], stats[1]
+	if flaky.Timeouts.Count != 1 || flaky.Timeouts.Other != 0 {
+		t.Errorf("unexpected flaky timeouts: %+v", flaky.Timeouts)
+	}
+	if steady.Timeouts.Count != 0 || steady.Timeouts.Other != 1 {
+		t.Errorf("unexpected steady timeouts: %+v", steady.Timeouts)
+	}
+	// (19 x 10ms + 1s) / 20
+	if got := flaky.penalizedAvg(time.Second); got != 59500*time.Microsecond {
+		t.Errorf("expected penalized average 59.5ms, got %v", got)
+	}
+	// Other errors aren't censored latencies and don't count
+	if got := steady.penalizedAvg(time.Second); got != 20*time.Millisecond {
+		t.Errorf("expected penalized average 20ms, got %v", got)
+	}
+
+	rankPenalized(stats, time.Second)
+	if stats[0].Server != "steady" {
+		t.Errorf("expected steady to rank first once timeouts count, got %s", stats[0].Server)
+	}
+}
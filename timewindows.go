@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// windowCell is one server's results within one time window.
+type windowCell struct {
+	Queries int
+	Errors  int
+	total   time.Duration
+}
+
+// Avg is the average latency of the window's answered queries.
+func (c *windowCell) Avg() time.Duration {
+	if answered := c.Queries - c.Errors; answered > 0 {
+		return c.total / time.Duration(answered)
+	}
+	return 0
+}
+
+// LossPct is the share of the window's queries that failed.
+func (c *windowCell) LossPct() float64 {
+	if c.Queries == 0 {
+		return 0
+	}
+	return float64(c.Errors) / float64(c.Queries) * 100
+}
+
+// timeWindows splits a long run's results into consecutive windows of
+// Size, so a quiet night doesn't hide a congested afternoon in the overall
+// average.
+type timeWindows struct {
+	Size    time.Duration
+	Starts  []time.Time
+	Servers []string
+	cells   map[time.Time]map[string]*windowCell
+}
+
+// windowStart returns the start of the window of size t falls in. Windows
+// of a day or less are aligned to local midnight, so 1h windows start on
+// the hour of the local clock; longer ones to the zero time.
+func windowStart(t time.Time, size time.Duration) time.Time {
+	if size > 24*time.Hour {
+		return t.Truncate(size)
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight) / size * size)
+}
+
+// segmentByWindow sorts results into windows of size, with the servers in
+// the order of stats (the ranking). Results without a timestamp are left
+// out.
+func segmentByWindow(stats []*ServerStats, results []benchmark.Result, size time.Duration) *timeWindows {
+	tw := &timeWindows{Size: size, cells: make(map[time.Time]map[string]*windowCell)}
+	for _, s := range stats {
+		tw.Servers = append(tw.Servers, s.Server)
+	}
+	for _, res := range results {
+		if res.Time.IsZero() {
+			continue
+		}
+		start := windowStart(res.Time, size)
+		row, ok := tw.cells[start]
+		if !ok {
+			row = make(map[string]*windowCell)
+			tw.cells[start] = row
+			tw.Starts = append(tw.Starts, start)
+		}
+		cell, ok := row[res.Server]
+		if !ok {
+			cell = &windowCell{}
+			row[res.Server] = cell
+		}
+		cell.Queries++
+		if res.Error != nil {
+			cell.Errors++
+		} else {
+			cell.total += res.Duration
+		}
+	}
+	slices.SortFunc(tw.Starts, func(a, b time.Time) int { return a.Compare(b) })
+	return tw
+}
+
+// cell returns server's results in the window starting at start, nil if it
+// had none.
+func (tw *timeWindows) cell(start time.Time, server string) *windowCell {
+	return tw.cells[start][server]
+}
+
+// windowSummary compares a server's overall average with its windows.
+type windowSummary struct {
+	Server string
+	// Avg weights every answered query equally; WeightedAvg every window,
+	// so windows where fast answers let more queries through don't count
+	// for more
+	Avg         time.Duration
+	WeightedAvg time.Duration
+	// Worst is the start of the window with the highest average
+	Worst    time.Time
+	WorstAvg time.Duration
+}
+
+// summary returns the window summary of server.
+func (tw *timeWindows) summary(server string) windowSummary {
+	sum := windowSummary{Server: server}
+	var total, windowTotal time.Duration
+	answered, windows := 0, 0
+	for _, start := range tw.Starts {
+		c := tw.cell(start, server)
+		if c == nil || c.Queries == c.Errors {
+			continue
+		}
+		total += c.total
+		answered += c.Queries - c.Errors
+		windowTotal += c.Avg()
+		windows++
+		if c.Avg() > sum.WorstAvg {
+			sum.Worst, sum.WorstAvg = start, c.Avg()
+		}
+	}
+	if windows > 0 {
+		sum.Avg = total / time.Duration(answered)
+		sum.WeightedAvg = windowTotal / time.Duration(windows)
+	}
+	return sum
+}
+
+// label formats a window's start, with seconds for windows shorter than a
+// minute and the weekday when the run spans several days.
+func (tw *timeWindows) label(start time.Time) string {
+	layout := "15:04"
+	if tw.Size < time.Minute {
+		layout = "15:04:05"
+	}
+	first, last := tw.Starts[0], tw.Starts[len(tw.Starts)-1]
+	if last.Sub(first) >= 24*time.Hour || first.Day() != last.Day() {
+		layout = "Mon " + layout
+	}
+	return start.Format(layout)
+}
+
+// printTimeWindows shows each server's average latency (and loss, where
+// any) per time window, then how its overall average compares with its
+// windows: weighted by window and the worst one.
+func printTimeWindows(tw *timeWindows) {
+	if len(tw.Starts) < 2 {
+		fmt.Printf("\nLatency by Time Window: the run fits in one %v window\n", tw.Size)
+		return
+	}
+
+	fmt.Printf("\nLatency by Time Window (%v windows, local time; average latency, and loss where any)\n\n", tw.Size)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintf(w, "WINDOW\t%s\n", strings.Join(tw.Servers, "\t")); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, start := range tw.Starts {
+		row := []string{tw.label(start)}
+		for _, server := range tw.Servers {
+			c := tw.cell(start, server)
+			switch {
+			case c == nil:
+				row = append(row, "-")
+			case c.Errors == c.Queries:
+				row = append(row, "100% loss")
+			case c.Errors > 0:
+				row = append(row, fmt.Sprintf("%v (%.1f%% loss)", c.Avg().Round(100*time.Microsecond), c.LossPct()))
+			default:
+				row = append(row, c.Avg().Round(100*time.Microsecond).String())
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tAVG\tAVG PER WINDOW\tWORST WINDOW\tWORST AVG\tWORST/AVG"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, server := range tw.Servers {
+		sum := tw.summary(server)
+		if sum.Worst.IsZero() {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%v\t%v\t%s\t%v\t%.1fx\n", server, sum.Avg.Round(100*time.Microsecond), sum.WeightedAvg.Round(100*time.Microsecond),
+			tw.label(sum.Worst), sum.WorstAvg.Round(100*time.Microsecond), float64(sum.WorstAvg)/float64(sum.Avg)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestWindowStart(t *testing.T) {
+	loc := time.FixedZone("UTC+5:30", 5*3600+1800)
+	at := time.Date(2026, 3, 1, 9, 47, 12, 0, loc)
+	if got := windowStart(at, time.Hour); !got.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, loc)) {
+		t.Errorf("expected the window to start on the local hour, got %v", got)
+	}
+	if got := windowStart(at, 15*time.Minute); !got.Equal(time.Date(2026, 3, 1, 9, 45, 0, 0, loc)) {
+		t.Errorf("expected a 15m window starting at 9:45, got %v", got)
+	}
+}
+
+func TestSegmentByWindow(t *testing.T) {
+	night := time.Date(2026, 3, 1, 3, 0, 0, 0, time.Local)
+	day := time.Date(2026, 3, 1, 14, 0, 0, 0, time.Local)
+	var results []benchmark.Result
+	// A quiet night lets three times as many fast queries through as the
+	// congested afternoon
+	for i := range 30 {
+		results = append(results, benchmark.Result{Server: "9.9.9.9", Time: night.Add(time.Duration(i) * time.Minute), Duration: 10 * time.Millisecond})
+	}
+	for i := range 10 {
+		results = append(results, benchmark.Result{Server: "9.9.9.9", Time: day.Add(time.Duration(i) * time.Minute), Duration: 90 * time.Millisecond})
+	}
+	results = append(results, benchmark.Result{Server: "9.9.9.9", Time: day, Error: errors.New("timeout")})
+	results = append(results, benchmark.Result{Server: "1.1.1.1", Time: day, Duration: 5 * time.Millisecond})
+
+	tw := segmentByWindow([]*ServerStats{{Server: "1.1.1.1"}, {Server: "9.9.9.9"}}, results, time.Hour)
+	if len(tw.Starts) != 2 || !tw.Starts[0].Equal(night) || !tw.Starts[1].Equal(day) {
+		t.Fatalf("expected the night and afternoon windows in order, got %v", tw.Starts)
+	}
+	if c := tw.cell(day, "9.9.9.9"); c.Queries != 11 || c.Errors != 1 || c.Avg() != 90*time.Millisecond {
+		t.Errorf("unexpected afternoon window %+v", c)
+	}
+	if tw.cell(night, "1.1.1.1") != nil {
+		t.Error("expected no night window for 1.1.1.1")
+	}
+
+	sum := tw.summary("9.9.9.9")
+	if sum.Avg != 30*time.Millisecond || sum.WeightedAvg != 50*time.Millisecond {
+		t.Errorf("expected 30ms overall and 50ms per window, got %v and %v", sum.Avg, sum.WeightedAvg)
+	}
+	if !sum.Worst.Equal(day) || sum.WorstAvg != 90*time.Millisecond {
+		t.Errorf("expected the afternoon as the worst window, got %v at %v", sum.WorstAvg, sum.Worst)
+	}
+}
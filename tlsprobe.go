@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// yesNo formats a probe finding for the table.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// ticketsCell formats whether a server issues session tickets, with their
+// lifetime where it is known.
+func ticketsCell(r benchmark.TLSProbeResult) string {
+	if r.Tickets && r.Lifetime > 0 {
+		return fmt.Sprintf("yes (%v)", r.Lifetime)
+	}
+	return yesNo(r.Tickets)
+}
+
+// printTLSProbe checks every DoT and DoH server for TLS session resumption
+// and 0-RTT, and compares queries over a new connection with a full
+// handshake with ones over a resumed session.
+func printTLSProbe(config benchmark.Config) {
+	if config.Proxy != nil {
+		fmt.Println("\nTLS probe: skipped, not run through the proxy")
+		return
+	}
+	if len(encryptedServers(config.Servers)) == 0 {
+		fmt.Println("\nTLS probe: no DoT or DoH servers to probe")
+		return
+	}
+	results := benchmark.RunTLSProbe(config)
+
+	fmt.Printf("\nTLS Session Resumption (median of new connections; setup, then query latency)\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tTLS\tTICKETS\tRESUMES\t0-RTT\tFULL SETUP\tRESUMED SETUP\tFULL QUERY\tRESUMED QUERY"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range results {
+		row := fmt.Sprintf("%s\tfailed\t-\t-\t-\t-\t-\t-\t-", r.Server)
+		if r.Err == nil {
+			row = fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s", r.Server, orNone(r.Version), ticketsCell(r), yesNo(r.Resumes), yesNo(r.ZeroRTT),
+				orDash(r.FullConnect.Round(time.Microsecond)), orDash(r.ResumedConnect.Round(time.Microsecond)),
+				orDash(r.Full.Round(time.Microsecond)), orDash(r.Resumed.Round(time.Microsecond)))
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	probed := false
+	for _, r := range results {
+		probed = probed || r.Err == nil
+		switch {
+		case r.Err != nil:
+			fmt.Printf("⚠️  %s: %v\n", r.Server, r.Err)
+		case !r.Resumes:
+			fmt.Printf("⚠️  %s doesn't resume sessions: every new connection pays for a full handshake\n", r.Server)
+		case r.ResumedConnect > 0 && r.FullConnect > r.ResumedConnect:
+			fmt.Printf("%s: resuming saves %v per new connection\n", r.Server, (r.FullConnect - r.ResumedConnect).Round(time.Microsecond))
+		}
+		if r.Note != "" {
+			fmt.Printf("   %s: %s\n", r.Server, r.Note)
+		}
+	}
+	if probed {
+		fmt.Println("0-RTT is what the server's tickets allow; dns-bench doesn't send early data itself, so resumed queries still wait for the handshake")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// topList is a published ranking of popular domains, downloaded as a zipped
+// "rank,domain" CSV.
+type topList struct {
+	Name string
+	URL  string
+}
+
+// topLists are the rankings -domains accepts as "<name>:<count>".
+var topLists = map[string]topList{
+	"tranco":   {Name: "Tranco", URL: "https://tranco-list.eu/top-1m.csv.zip"},
+	"umbrella": {Name: "Umbrella", URL: "https://s3-us-west-1.amazonaws.com/umbrella-static/top-1m.csv.zip"},
+}
+
+const (
+	// defaultTopCount is how many domains a top list without a count gives.
+	defaultTopCount = 1000
+	// topListTTL is how long a downloaded list is used before it is fetched
+	// again; both lists are updated daily.
+	topListTTL = 24 * time.Hour
+)
+
+// parseTopList recognises a "tranco:1000" style domain source. ok is false
+// for anything else, which is then read as a file.
+func parseTopList(spec string) (list topList, count int, ok bool, err error) {
+	name, n, hasCount := strings.Cut(spec, ":")
+	list, ok = topLists[strings.ToLower(name)]
+	if !ok {
+		return topList{}, 0, false, nil
+	}
+	count = defaultTopCount
+	if hasCount {
+		count, err = strconv.Atoi(n)
+		if err != nil || count <= 0 {
+			return list, 0, true, fmt.Errorf("invalid %s count %q: want a positive number", list.Name, n)
+		}
+	}
+	return list, count, true, nil
+}
+
+// topListCacheFile returns where list is cached, in the user's cache
+// directory, or "" when there isn't one.
+func topListCacheFile(list topList) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-bench", strings.ToLower(list.Name)+"-top-1m.csv")
+}
+
+// loadTopList returns the count highest-ranked domains of list with their
+// ranks. The list is downloaded to cacheFile when that is missing or older
+// than topListTTL; a stale copy is still used if the download fails.
+func loadTopList(list topList, count int, cacheFile string, now time.Time, warns *warningLog) ([]string, map[string]int, error) {
+	if cacheFile == "" {
+		return nil, nil, fmt.Errorf("no cache directory to keep the %s list in", list.Name)
+	}
+	info, statErr := os.Stat(cacheFile)
+	if statErr != nil || now.Sub(info.ModTime()) > topListTTL {
+		fmt.Printf("Downloading the %s top domains list...\n", list.Name)
+		if err := downloadTopList(list.URL, cacheFile); err != nil {
+			if statErr != nil {
+				return nil, nil, fmt.Errorf("downloading %s list: %w", list.Name, err)
+			}
+			warns.add("domains", "failed to refresh the %s list, using the copy from %s: %v", list.Name, info.ModTime().Format(time.DateOnly), err)
+		}
+	}
+	return readTopList(cacheFile, count)
+}
+
+// downloadTopList fetches the zipped list at url and stores the CSV inside
+// it at path.
+func downloadTopList(url, path string) error {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close response body: %v\n", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	// zip needs random access, and the archives are only a few MB
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	if len(archive.File) == 0 {
+		return fmt.Errorf("%s: empty archive", url)
+	}
+	src, err := archive.File[0].Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close archive: %v\n", err)
+		}
+	}()
+
+	return replaceFile(path, src)
+}
+
+// readTopList reads the first count rows of a cached "rank,domain" list.
+func readTopList(path string, count int) ([]string, map[string]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+		}
+	}()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	var domains []string
+	ranks := make(map[string]int, count)
+	for len(domains) < count {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rank, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: invalid rank %q", path, record[0])
+		}
+		domain := strings.TrimSpace(record[1])
+		domains = append(domains, domain)
+		ranks[strings.ToLower(domain)] = rank
+	}
+	return domains, ranks, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTopList(t *testing.T) {
+	list, count, ok, err := parseTopList("tranco:500")
+	if err != nil || !ok || list.Name != "Tranco" || count != 500 {
+		t.Errorf("tranco:500 = %v %d %v %v", list, count, ok, err)
+	}
+	if _, count, ok, _ := parseTopList("Umbrella"); !ok || count != defaultTopCount {
+		t.Errorf("expected umbrella without a count to give %d domains, got %d", defaultTopCount, count)
+	}
+	if _, _, ok, _ := parseTopList("domains.csv"); ok {
+		t.Error("a file name should not be taken for a top list")
+	}
+	if _, _, _, err := parseTopList("tranco:0"); err == nil {
+		t.Error("expected an error for a zero count")
+	}
+}
+
+func TestLoadTopList(t *testing.T) {
+	requests := 0
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		zw := zip.NewWriter(w)
+		f, err := zw.Create("top-1m.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte("1,google.com\r\n2,Facebook.com\r\n3,example.org\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer srv.Close()
+
+	list := topList{Name: "Test", URL: srv.URL}
+	cache := filepath.Join(t.TempDir(), "dns-bench", "test-top-1m.csv")
+	now := time.Now()
+
+	domains, ranks, err := loadTopList(list, 2, cache, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 || domains[1] != "Facebook.com" || ranks["facebook.com"] != 2 {
+		t.Errorf("expected the top 2 domains with ranks, got %v %v", domains, ranks)
+	}
+
+	// A fresh copy is read from the cache
+	if domains, _, err = loadTopList(list, 10, cache, now.Add(time.Hour), nil); err != nil || len(domains) != 3 {
+		t.Errorf("expected all 3 cached domains, got %v %v", domains, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected one download, got %d", requests)
+	}
+
+	// A stale copy is refreshed, and kept when that fails
+	fail = true
+	warns := &warningLog{}
+	if domains, _, err = loadTopList(list, 1, cache, now.Add(2*topListTTL), warns); err != nil || len(domains) != 1 {
+		t.Errorf("expected the stale copy to be used, got %v %v", domains, err)
+	}
+	if w := warns.warnings(); len(w) != 1 || w[0].Source != "domains" {
+		t.Errorf("expected a warning about the stale copy, got %+v", w)
+	}
+	if requests != 2 {
+		t.Errorf("expected a refresh attempt, got %d requests", requests)
+	}
+
+	if err := os.Remove(cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadTopList(list, 1, cache, now, nil); err == nil {
+		t.Error("expected an error without a cached copy to fall back on")
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+// traceMaxHops is the TTL traceroute gives up at.
+const traceMaxHops = 30
+
+// traceTimeout bounds one traceroute, silent hops included.
+const traceTimeout = 90 * time.Second
+
+// traceHop is one hop of a traceroute: the router that answered at TTL, or
+// an empty Addr when none did.
+type traceHop struct {
+	TTL  int
+	Addr netip.Addr
+	RTT  time.Duration
+	ASN  int // 0 when unknown or a private address
+}
+
+// traceResult is the path to one server's address.
+type traceResult struct {
+	Server  string
+	Target  netip.Addr
+	Hops    []traceHop
+	Reached bool
+	// ASNames names the autonomous systems seen on the path
+	ASNames map[int]string
+	Err     error
+}
+
+// tracerouteCommand returns the system traceroute command for target on
+// goos, asking for numeric output and one probe per hop where the command
+// allows it.
+func tracerouteCommand(goos string, target netip.Addr) (string, []string) {
+	hops := strconv.Itoa(traceMaxHops)
+	switch {
+	case goos == "windows":
+		return "tracert", []string{"-d", "-h", hops, "-w", "1000", target.String()}
+	case goos == "darwin" && target.Is6():
+		return "traceroute6", []string{"-n", "-q", "1", "-w", "1", "-m", hops, target.String()}
+	default:
+		return "traceroute", []string{"-n", "-q", "1", "-w", "1", "-m", hops, target.String()}
+	}
+}
+
+// parseTraceroute reads the hops from the output of traceroute or tracert.
+// Lines that don't start with a hop number (headers, "Trace complete.") are
+// skipped; of several probes per hop the fastest counts.
+func parseTraceroute(out string) []traceHop {
+	var hops []traceHop
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		ttl, err := strconv.Atoi(fields[0])
+		if err != nil || ttl <= 0 {
+			continue
+		}
+		hop := traceHop{TTL: ttl}
+		for i, f := range fields[1:] {
+			if addr, err := netip.ParseAddr(strings.Trim(f, "()[]")); err == nil {
+				hop.Addr = addr.Unmap()
+				continue
+			}
+			// RTTs are printed as "0.512 ms", or "<1 ms" by tracert
+			if i+2 < len(fields) && fields[i+2] == "ms" {
+				ms, err := strconv.ParseFloat(strings.TrimPrefix(f, "<"), 64)
+				if err != nil {
+					continue
+				}
+				rtt := time.Duration(ms * float64(time.Millisecond))
+				if hop.RTT == 0 || rtt < hop.RTT {
+					hop.RTT = rtt
+				}
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// txtLookup looks up the TXT records of a name.
+type txtLookup func(ctx context.Context, name string) ([]string, error)
+
+// originName is the Team Cymru IP-to-ASN name for addr, e.g.
+// 1.1.1.1.origin.asn.cymru.com for 1.1.1.1.
+func originName(addr netip.Addr) string {
+	if addr.Is4() {
+		b := addr.As4()
+		return fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", b[3], b[2], b[1], b[0])
+	}
+	b := addr.As16()
+	var sb strings.Builder
+	for i := len(b) - 1; i >= 0; i-- {
+		fmt.Fprintf(&sb, "%x.%x.", b[i]&0xf, b[i]>>4)
+	}
+	return sb.String() + "origin6.asn.cymru.com"
+}
+
+// lookupASN returns the AS that originates addr, from Team Cymru's DNS
+// service ("13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"). Private and
+// other non-routable addresses have none.
+func lookupASN(ctx context.Context, lookup txtLookup, addr netip.Addr) int {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || netip.MustParsePrefix("100.64.0.0/10").Contains(addr) {
+		return 0
+	}
+	records, err := lookup(ctx, originName(addr))
+	if err != nil || len(records) == 0 {
+		return 0
+	}
+	// Prefixes announced by several ASes list them all; the first will do
+	fields := strings.Fields(strings.SplitN(records[0], "|", 2)[0])
+	if len(fields) == 0 {
+		return 0
+	}
+	asn, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0
+	}
+	return asn
+}
+
+// lookupASName returns the name Team Cymru has for an AS
+// ("13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US" gives
+// CLOUDFLARENET), or "" when it has none.
+func lookupASName(ctx context.Context, lookup txtLookup, asn int) string {
+	records, err := lookup(ctx, fmt.Sprintf("AS%d.asn.cymru.com", asn))
+	if err != nil || len(records) == 0 {
+		return ""
+	}
+	parts := strings.Split(records[0], "|")
+	name := strings.TrimSpace(parts[len(parts)-1])
+	name, _, _ = strings.Cut(name, ",")
+	if before, _, ok := strings.Cut(name, " - "); ok {
+		name = before
+	}
+	return strings.TrimSpace(name)
+}
+
+// annotateASNs fills in the AS of every hop and looks up the names of the
+// ASes on the path.
+func annotateASNs(ctx context.Context, lookup txtLookup, res *traceResult) {
+	res.ASNames = make(map[int]string)
+	for i := range res.Hops {
+		if !res.Hops[i].Addr.IsValid() {
+			continue
+		}
+		res.Hops[i].ASN = lookupASN(ctx, lookup, res.Hops[i].Addr)
+		if asn := res.Hops[i].ASN; asn != 0 {
+			if _, ok := res.ASNames[asn]; !ok {
+				res.ASNames[asn] = lookupASName(ctx, lookup, asn)
+			}
+		}
+	}
+}
+
+// asPath is the ASes a path crosses, in order, e.g.
+// "AS7922 (COMCAST-7922) > AS13335 (CLOUDFLARENET)".
+func (r traceResult) asPath() string {
+	var path []string
+	last := 0
+	for _, h := range r.Hops {
+		if h.ASN == 0 || h.ASN == last {
+			continue
+		}
+		last = h.ASN
+		as := fmt.Sprintf("AS%d", h.ASN)
+		if name := r.ASNames[h.ASN]; name != "" {
+			as += " (" + name + ")"
+		}
+		path = append(path, as)
+	}
+	if len(path) == 0 {
+		return "-"
+	}
+	return strings.Join(path, " > ")
+}
+
+// biggestJump finds the hop that added the most latency over the last hop
+// that answered before it, which is usually where a slow path goes the long
+// way round. ok is false when fewer than two hops answered.
+func (r traceResult) biggestJump() (hop traceHop, jump time.Duration, ok bool) {
+	var prev time.Duration
+	seen := false
+	for _, h := range r.Hops {
+		if !h.Addr.IsValid() || h.RTT == 0 {
+			continue
+		}
+		if seen && h.RTT-prev > jump {
+			hop, jump, ok = h, h.RTT-prev, true
+		}
+		prev, seen = h.RTT, true
+	}
+	return hop, jump, ok
+}
+
+// traceTarget returns the address to trace for server: the address itself,
+// or the first address its hostname resolves to, through bootstrap if set.
+func traceTarget(server, bootstrap string, timeout time.Duration) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(serverHost(server)); err == nil {
+		return addr.Unmap(), nil
+	}
+	if benchmark.BootstrapHost(server) == "" {
+		return netip.Addr{}, fmt.Errorf("no address to trace in %s", server)
+	}
+	res := benchmark.Bootstrap(server, bootstrap, timeout)
+	if res.Err != nil {
+		return netip.Addr{}, res.Err
+	}
+	if len(res.Addrs) == 0 {
+		return netip.Addr{}, fmt.Errorf("%s has no addresses", res.Host)
+	}
+	return res.Addrs[0], nil
+}
+
+// traceroute runs the system traceroute to target.
+func traceroute(target netip.Addr) ([]traceHop, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceTimeout)
+	defer cancel()
+	name, args := tracerouteCommand(runtime.GOOS, target)
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s is not installed", name)
+	}
+	hops := parseTraceroute(string(out))
+	if len(hops) == 0 {
+		if err == nil {
+			err = errors.New("no hops in the output")
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return hops, nil
+}
+
+// runTraceroutes traces the path to every server once, in parallel; servers
+// sharing an address (e.g. 1.1.1.1 and tls://1.1.1.1) share the trace.
+func runTraceroutes(config benchmark.Config) []traceResult {
+	results := make([]traceResult, len(config.Servers))
+	traces := make(map[netip.Addr]*traceResult)
+	var wg sync.WaitGroup
+	for i, server := range config.Servers {
+		results[i] = traceResult{Server: server}
+		target, err := traceTarget(server, config.Bootstrap, config.Timeout)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Target = target
+		if traces[target] != nil {
+			continue
+		}
+		trace := &traceResult{Target: target}
+		traces[target] = trace
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trace.Hops, trace.Err = traceroute(target)
+			if trace.Err != nil {
+				return
+			}
+			for _, h := range trace.Hops {
+				trace.Reached = trace.Reached || h.Addr == target
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), traceTimeout)
+			defer cancel()
+			annotateASNs(ctx, net.DefaultResolver.LookupTXT, trace)
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		if trace := traces[results[i].Target]; trace != nil && results[i].Err == nil {
+			server := results[i].Server
+			results[i] = *trace
+			results[i].Server = server
+		}
+	}
+	return results
+}
+
+// printTraceroutes traces the network path to every server and summarizes
+// it: how many hops away it is, the networks in between and where most of
+// the latency is added.
+func printTraceroutes(config benchmark.Config) {
+	if config.Proxy != nil {
+		fmt.Println("\nTraceroute: skipped, queries go through the proxy")
+		return
+	}
+	fmt.Printf("\nTracing the path to each server (takes up to %v)...\n", traceTimeout)
+	results := runTraceroutes(config)
+
+	fmt.Printf("\nNetwork Path\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tADDRESS\tHOPS\tRTT\tBIGGEST JUMP\tAS PATH"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range results {
+		var row string
+		switch {
+		case r.Err != nil:
+			row = fmt.Sprintf("%s\t-\t-\t-\t-\tfailed: %v", r.Server, r.Err)
+		default:
+			hops, rtt := fmt.Sprintf("%d+ (not reached)", len(r.Hops)), "-"
+			if r.Reached {
+				last := r.Hops[len(r.Hops)-1]
+				hops, rtt = strconv.Itoa(last.TTL), last.RTT.Round(time.Microsecond).String()
+			}
+			jump := "-"
+			if hop, d, ok := r.biggestJump(); ok {
+				jump = fmt.Sprintf("+%v at hop %d (%s)", d.Round(time.Microsecond), hop.TTL, hop.Addr)
+			}
+			row = fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", r.Server, r.Target, hops, rtt, jump, r.asPath())
+		}
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
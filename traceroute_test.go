@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParseTraceroute(t *testing.T) {
+	unix := `traceroute to 1.1.1.1 (1.1.1.1), 30 hops max, 60 byte packets
+ 1  192.168.1.1  0.512 ms
+ 2  *
+ 3  68.85.1.1  9.871 ms
+ 4  1.1.1.1  11.204 ms
+`
+	hops := parseTraceroute(unix)
+	if len(hops) != 4 {
+		t.Fatalf("expected 4 hops, got %+v", hops)
+	}
+	if hops[0].Addr != netip.MustParseAddr("192.168.1.1") || hops[0].RTT != 512*time.Microsecond {
+		t.Errorf("unexpected first hop %+v", hops[0])
+	}
+	if hops[1].TTL != 2 || hops[1].Addr.IsValid() {
+		t.Errorf("expected a silent second hop, got %+v", hops[1])
+	}
+
+	windows := `
+Tracing route to 1.1.1.1 over a maximum of 30 hops
+
+  1    <1 ms    <1 ms     2 ms  192.168.1.1
+  2     *        *        *     Request timed out.
+  3    14 ms    12 ms    13 ms  1.1.1.1
+
+Trace complete.
+`
+	hops = parseTraceroute(windows)
+	if len(hops) != 3 {
+		t.Fatalf("expected 3 hops, got %+v", hops)
+	}
+	if hops[0].RTT != time.Millisecond || hops[2].RTT != 12*time.Millisecond || hops[2].Addr != netip.MustParseAddr("1.1.1.1") {
+		t.Errorf("expected the fastest probe per hop, got %+v", hops)
+	}
+}
+
+func TestTracerouteCommand(t *testing.T) {
+	v4, v6 := netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("2606:4700::1111")
+	if name, _ := tracerouteCommand("windows", v6); name != "tracert" {
+		t.Errorf("expected tracert on Windows, got %s", name)
+	}
+	if name, _ := tracerouteCommand("darwin", v6); name != "traceroute6" {
+		t.Errorf("expected traceroute6 for IPv6 on macOS, got %s", name)
+	}
+	if name, args := tracerouteCommand("linux", v4); name != "traceroute" || args[len(args)-1] != "1.1.1.1" {
+		t.Errorf("expected traceroute to 1.1.1.1, got %s %v", name, args)
+	}
+}
+
+func TestOriginName(t *testing.T) {
+	if got := originName(netip.MustParseAddr("1.2.3.4")); got != "4.3.2.1.origin.asn.cymru.com" {
+		t.Errorf("unexpected IPv4 name %s", got)
+	}
+	want := "1.1.1.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.7.4.6.0.6.2.origin6.asn.cymru.com"
+	if got := originName(netip.MustParseAddr("2606:4700::1111")); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestAnnotateASNs(t *testing.T) {
+	records := map[string]string{
+		"1.1.85.68.origin.asn.cymru.com": "7922 | 68.80.0.0/13 | US | arin | 2005-06-09",
+		"2.1.85.68.origin.asn.cymru.com": "7922 | 68.80.0.0/13 | US | arin | 2005-06-09",
+		"1.1.1.1.origin.asn.cymru.com":   "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11",
+		"AS7922.asn.cymru.com":           "7922 | US | arin | 1997-02-14 | COMCAST-7922, US",
+		"AS13335.asn.cymru.com":          "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US",
+	}
+	var lookups int
+	lookup := func(_ context.Context, name string) ([]string, error) {
+		lookups++
+		if r, ok := records[name]; ok {
+			return []string{r}, nil
+		}
+		return nil, errors.New("no such name")
+	}
+	res := traceResult{Hops: []traceHop{
+		{TTL: 1, Addr: netip.MustParseAddr("192.168.1.1"), RTT: time.Millisecond},
+		{TTL: 2},
+		{TTL: 3, Addr: netip.MustParseAddr("68.85.1.1"), RTT: 9 * time.Millisecond},
+		{TTL: 4, Addr: netip.MustParseAddr("68.85.1.2"), RTT: 10 * time.Millisecond},
+		{TTL: 5, Addr: netip.MustParseAddr("1.1.1.1"), RTT: 40 * time.Millisecond},
+	}}
+	annotateASNs(context.Background(), lookup, &res)
+	if res.Hops[0].ASN != 0 || res.Hops[2].ASN != 7922 || res.Hops[4].ASN != 13335 {
+		t.Errorf("unexpected ASNs %+v", res.Hops)
+	}
+	// Private hops aren't looked up and each AS is named once
+	if lookups != 5 {
+		t.Errorf("expected 5 lookups, got %d", lookups)
+	}
+	if got := res.asPath(); got != "AS7922 (COMCAST-7922) > AS13335 (CLOUDFLARENET)" {
+		t.Errorf("unexpected AS path %q", got)
+	}
+	if hop, jump, ok := res.biggestJump(); !ok || hop.TTL != 5 || jump != 30*time.Millisecond {
+		t.Errorf("expected the 30ms jump at hop 5, got %+v %v", hop, jump)
+	}
+}
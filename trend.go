@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// trendThreshold is the change over the whole period, relative to the
+// fitted starting latency, beyond which a server is called better or worse.
+const trendThreshold = 10.0
+
+// trendMinRuns is the fewest runs a server needs for a regression.
+const trendMinRuns = 3
+
+// trendPoint is one server's result in one run.
+type trendPoint struct {
+	At      time.Time
+	RunID   string
+	Avg     time.Duration
+	LossPct float64
+}
+
+// trendSeries is a server's latency over time and its least-squares trend.
+type trendSeries struct {
+	Server string
+	Points []trendPoint
+	// SlopePerDay is the fitted change in average latency per day; Change
+	// the fitted change over the whole period, in percent of the fitted
+	// starting latency.
+	SlopePerDay time.Duration
+	Change      float64
+	Verdict     string
+	Latest      time.Duration
+}
+
+// runTrend implements the trend subcommand, which reads earlier runs from a
+// -db SQLite database or a directory of CSV/JSON exports and writes an HTML
+// report of each server's latency over time with a regression summary.
+func runTrend(args []string) error {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	var output string
+	fs.StringVar(&output, "o", "trend.html", "Output HTML report file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s trend [options] <results.db | results-dir>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a results database or directory")
+	}
+	source := fs.Arg(0)
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	var points map[string][]trendPoint
+	if info.IsDir() {
+		points, err = loadTrendDir(source)
+	} else {
+		points, err = loadTrendDB(source)
+	}
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no runs found in %s", source)
+	}
+
+	series := buildTrends(points)
+	printTrends(series)
+	if err := generateTrendHTML(series, source, output); err != nil {
+		return fmt.Errorf("writing %s: %w", output, err)
+	}
+	fmt.Printf("\nTrend report generated at %s\n", output)
+	return nil
+}
+
+// loadTrendDB reads every run's per-server stats from a -db database.
+func loadTrendDB(path string) (map[string][]trendPoint, error) {
+	db, err := openResultsDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close database: %v\n", err)
+		}
+	}()
+
+	rows, err := db.Query(`SELECT r.run_id, r.generated_at, s.server, s.avg_ms, s.loss_pct
+		FROM server_stats s JOIN runs r USING (run_id) ORDER BY r.generated_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make(map[string][]trendPoint)
+	for rows.Next() {
+		var (
+			p           trendPoint
+			at, server  string
+			avgMs, loss float64
+		)
+		if err := rows.Scan(&p.RunID, &at, &server, &avgMs, &loss); err != nil {
+			return nil, err
+		}
+		if p.At, err = time.Parse(time.RFC3339, at); err != nil {
+			return nil, fmt.Errorf("run %s: %w", p.RunID, err)
+		}
+		p.Avg = time.Duration(avgMs * float64(time.Millisecond))
+		p.LossPct = loss
+		points[server] = append(points[server], p)
+	}
+	return points, rows.Err()
+}
+
+// loadTrendDir reads every CSV and JSON export in dir as one run. The
+// scheduled deployment's history.csv mixes many runs and is skipped.
+func loadTrendDir(dir string) (map[string][]trendPoint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	points := make(map[string][]trendPoint)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".csv" && ext != ".json") || e.Name() == "history.csv" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		results, err := readResults(path)
+		if err != nil {
+			fmt.Printf("Warning: skipping %v\n", err)
+			continue
+		}
+		at := exportTime(path)
+		for _, s := range calculateStats(results) {
+			points[s.Server] = append(points[s.Server], trendPoint{At: at, RunID: e.Name(), Avg: s.Avg, LossPct: s.LossPct})
+		}
+	}
+	for _, p := range points {
+		sort.Slice(p, func(i, j int) bool { return p[i].At.Before(p[j].At) })
+	}
+	return points, nil
+}
+
+// exportTime returns when an export was generated according to its
+// provenance, or the file's modification time for exports without one.
+func exportTime(path string) time.Time {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}
+	}
+	var generated string
+	if strings.HasSuffix(path, ".json") {
+		var export struct {
+			Provenance struct {
+				GeneratedAt string `json:"generated_at"`
+			} `json:"provenance"`
+		}
+		if json.Unmarshal(data, &export) == nil {
+			generated = export.Provenance.GeneratedAt
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if v, ok := strings.CutPrefix(line, "# generated_at: "); ok {
+				generated = strings.TrimSpace(v)
+				break
+			}
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, generated); err == nil {
+		return t
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// buildTrends fits a least-squares line to each server's average latency
+// over time, ordered by latest latency.
+func buildTrends(points map[string][]trendPoint) []trendSeries {
+	series := make([]trendSeries, 0, len(points))
+	for server, p := range points {
+		s := trendSeries{Server: server, Points: p, Latest: p[len(p)-1].Avg, Verdict: "not enough runs"}
+		if len(p) >= trendMinRuns {
+			slope, start, ok := fitTrend(p)
+			if ok {
+				span := p[len(p)-1].At.Sub(p[0].At).Hours() / 24
+				s.SlopePerDay = time.Duration(slope * float64(time.Millisecond))
+				if start > 0 {
+					s.Change = slope * span / start * 100
+				}
+				switch {
+				case s.Change > trendThreshold:
+					s.Verdict = "worse"
+				case s.Change < -trendThreshold:
+					s.Verdict = "better"
+				default:
+					s.Verdict = "stable"
+				}
+			}
+		}
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Latest != series[j].Latest {
+			return series[i].Latest < series[j].Latest
+		}
+		return series[i].Server < series[j].Server
+	})
+	return series
+}
+
+// fitTrend returns the least-squares slope (ms per day) and the fitted
+// value at the first point (ms) of average latency over time. It fails
+// when all points were taken at the same time.
+func fitTrend(points []trendPoint) (slope, start float64, ok bool) {
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += p.At.Sub(points[0].At).Hours() / 24
+		sumY += float64(p.Avg) / float64(time.Millisecond)
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX float64
+	for _, p := range points {
+		dx := p.At.Sub(points[0].At).Hours()/24 - meanX
+		cov += dx * (float64(p.Avg)/float64(time.Millisecond) - meanY)
+		varX += dx * dx
+	}
+	if varX == 0 {
+		return 0, 0, false
+	}
+	slope = cov / varX
+	return slope, meanY - slope*meanX, true
+}
+
+// printTrends writes the regression summary to the console.
+func printTrends(series []trendSeries) {
+	fmt.Printf("Latency Trend\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tRUNS\tFIRST\tLAST\tLATEST AVG\tSLOPE/DAY\tCHANGE\tTREND"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, s := range series {
+		first, last := s.Points[0].At, s.Points[len(s.Points)-1].At
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%v\t%v\t%+.1f%%\t%s\n", s.Server, len(s.Points),
+			first.Format("2006-01-02"), last.Format("2006-01-02"), s.Latest, s.SlopePerDay, s.Change, s.Verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+}
+
+// trendChart draws every series as a line on one latency-over-time SVG
+// chart, with a tooltip on each point.
+func trendChart(series []trendSeries) string {
+	const width, height, left, right, top, bottom = 900.0, 360.0, 60.0, 20.0, 20.0, 40.0
+	var minT, maxT time.Time
+	var maxY time.Duration
+	for _, s := range series {
+		for _, p := range s.Points {
+			if minT.IsZero() || p.At.Before(minT) {
+				minT = p.At
+			}
+			if p.At.After(maxT) {
+				maxT = p.At
+			}
+			maxY = max(maxY, p.Avg)
+		}
+	}
+	if maxY == 0 {
+		maxY = time.Millisecond
+	}
+	maxY = maxY * 11 / 10
+	span := maxT.Sub(minT)
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return left + (width-left-right)/2
+		}
+		return left + float64(t.Sub(minT))/float64(span)*(width-left-right)
+	}
+	y := func(d time.Duration) float64 {
+		return height - bottom - float64(d)/float64(maxY)*(height-top-bottom)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" width="100%%" role="img" aria-label="Average latency over time">`, width, height)
+	for i := 0; i <= 4; i++ {
+		d := maxY * time.Duration(i) / 4
+		fmt.Fprintf(&b, `<line x1="%.0f" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#ddd"/>`, left, width-right, y(d), y(d))
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.1f" font-size="11" text-anchor="end">%s</text>`, left-6, y(d)+4, d.Round(100*time.Microsecond))
+	}
+	fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="11">%s</text>`, left, height-12, minT.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="11" text-anchor="end">%s</text>`, width-right, height-12, maxT.Format("2006-01-02 15:04"))
+
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		coords := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			coords[j] = fmt.Sprintf("%.1f,%.1f", x(p.At), y(p.Avg))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(coords, " "))
+		for _, p := range s.Points {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s %s: %v (%.1f%% loss)</title></circle>`,
+				x(p.At), y(p.Avg), color, html.EscapeString(s.Server), p.At.Format("2006-01-02 15:04"), p.Avg, p.LossPct)
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// generateTrendHTML writes the chart and regression summary to path.
+func generateTrendHTML(series []trendSeries, source, path string) error {
+	funcMap := template.FuncMap{
+		"color": func(i int) string { return chartColors[i%len(chartColors)] },
+		"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+		"runs":  func(s trendSeries) int { return len(s.Points) },
+		"first": func(s trendSeries) time.Time { return s.Points[0].At },
+		"last":  func(s trendSeries) time.Time { return s.Points[len(s.Points)-1].At },
+	}
+	tmpl, err := template.New("trend").Funcs(funcMap).Parse(trendTemplate)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close file: %v\n", err)
+		}
+	}()
+
+	data := struct {
+		Series    []trendSeries
+		Chart     template.HTML
+		Source    string
+		Threshold float64
+	}{
+		Series: series,
+		//nolint:gosec // G203: the chart is built from escaped values only
+		Chart:     template.HTML(trendChart(series)),
+		Source:    source,
+		Threshold: trendThreshold,
+	}
+	return tmpl.Execute(file, data)
+}
+
+const trendTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<title>DNS Latency Trend</title>
+	<style>
+		body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 2rem; background: #f4f4f9; color: #333; }
+		.container { max-width: 1000px; margin: 0 auto; background: white; padding: 2rem; border-radius: 8px; box-shadow: 0 2px 5px rgba(0,0,0,0.1); }
+		h1 { margin-top: 0; color: #2c3e50; }
+		.summary { margin-bottom: 2rem; padding: 1rem; background: #eef2f7; border-radius: 4px; }
+		table { width: 100%; border-collapse: collapse; margin-top: 1rem; }
+		th, td { padding: 12px; text-align: left; border-bottom: 1px solid #ddd; }
+		th { background-color: #2c3e50; color: white; }
+		tr:nth-child(even) { background-color: #f9f9f9; }
+		.swatch { display: inline-block; width: 12px; height: 12px; border-radius: 2px; margin-right: 6px; }
+		.good { color: green; font-weight: bold; }
+		.bad { color: red; font-weight: bold; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<h1>DNS Latency Trend</h1>
+		<div class="summary">
+			<p><strong>Source:</strong> {{.Source}}</p>
+			<p>Each point is a server's average latency in one run. A server is <em>worse</em> or <em>better</em> when its fitted latency changed by more than {{.Threshold}}% over the period.</p>
+		</div>
+		{{.Chart}}
+		<table>
+			<thead>
+				<tr>
+					<th>Server</th>
+					<th>Runs</th>
+					<th>Period</th>
+					<th>Latest Avg</th>
+					<th>Slope / Day</th>
+					<th>Change</th>
+					<th>Trend</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{range $i, $s := .Series}}
+				<tr>
+					<td><span class="swatch" style="background: {{color $i}}"></span>{{$s.Server}}</td>
+					<td>{{runs $s}}</td>
+					<td>{{date (first $s)}} – {{date (last $s)}}</td>
+					<td>{{$s.Latest}}</td>
+					<td>{{$s.SlopePerDay}}</td>
+					<td>{{printf "%+.1f%%" $s.Change}}</td>
+					<td class="{{if eq $s.Verdict "worse"}}bad{{else if eq $s.Verdict "better"}}good{{end}}">{{$s.Verdict}}</td>
+				</tr>
+				{{end}}
+			</tbody>
+		</table>
+	</div>
+</body>
+</html>
+`
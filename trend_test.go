@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func trendPoints(start time.Time, avgs ...time.Duration) []trendPoint {
+	points := make([]trendPoint, len(avgs))
+	for i, avg := range avgs {
+		points[i] = trendPoint{At: start.Add(time.Duration(i) * 24 * time.Hour), Avg: avg}
+	}
+	return points
+}
+
+func TestBuildTrends(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := buildTrends(map[string][]trendPoint{
+		"worse":  trendPoints(start, 10*time.Millisecond, 12*time.Millisecond, 14*time.Millisecond),
+		"better": trendPoints(start, 20*time.Millisecond, 15*time.Millisecond, 10*time.Millisecond),
+		"stable": trendPoints(start, 5*time.Millisecond, 5*time.Millisecond, 5*time.Millisecond),
+		"short":  trendPoints(start, 30*time.Millisecond, 60*time.Millisecond),
+	})
+
+	want := map[string]string{"worse": "worse", "better": "better", "stable": "stable", "short": "not enough runs"}
+	if len(series) != len(want) {
+		t.Fatalf("got %d series, want %d", len(series), len(want))
+	}
+	for _, s := range series {
+		if s.Verdict != want[s.Server] {
+			t.Errorf("%s: verdict %q, want %q", s.Server, s.Verdict, want[s.Server])
+		}
+	}
+	// Ordered by latest average latency
+	if series[0].Server != "stable" || series[len(series)-1].Server != "short" {
+		t.Errorf("unexpected order: %s ... %s", series[0].Server, series[len(series)-1].Server)
+	}
+	for _, s := range series {
+		if s.Server == "worse" {
+			if s.SlopePerDay != 2*time.Millisecond {
+				t.Errorf("slope %v, want 2ms", s.SlopePerDay)
+			}
+			if s.Change < 39.9 || s.Change > 40.1 {
+				t.Errorf("change %.2f%%, want 40%%", s.Change)
+			}
+		}
+	}
+}
+
+func TestFitTrendSameTime(t *testing.T) {
+	at := time.Now()
+	points := []trendPoint{{At: at, Avg: time.Millisecond}, {At: at, Avg: 2 * time.Millisecond}, {At: at, Avg: 3 * time.Millisecond}}
+	if _, _, ok := fitTrend(points); ok {
+		t.Error("fit succeeded with every point at the same time")
+	}
+}
+
+func TestLoadTrendDir(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, avg := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond} {
+		results := []benchmark.Result{{Server: "1.1.1.1", Domain: "google.com", Duration: avg}}
+		prov := &provenance{RunID: "run", Version: "test", GeneratedAt: first.AddDate(0, 0, i)}
+		var err error
+		if i == 0 {
+			err = exportCSV(results, prov, filepath.Join(dir, "first.csv"))
+		} else {
+			err = exportJSON(results, prov, filepath.Join(dir, "second.json"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "history.csv"), []byte("not an export\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := loadTrendDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := points["1.1.1.1"]
+	if len(p) != 2 {
+		t.Fatalf("got %d points, want 2", len(p))
+	}
+	if !p[0].At.Equal(first) || !p[1].At.Equal(first.AddDate(0, 0, 1)) {
+		t.Errorf("times %v, %v not taken from provenance", p[0].At, p[1].At)
+	}
+	if p[0].Avg != 10*time.Millisecond || p[1].Avg != 20*time.Millisecond {
+		t.Errorf("averages %v, %v", p[0].Avg, p[1].Avg)
+	}
+}
+
+func TestLoadTrendDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, avg := range []time.Duration{30 * time.Millisecond, 10 * time.Millisecond} {
+		results := []benchmark.Result{{Server: "8.8.8.8", Domain: "google.com", Duration: avg}}
+		// Stored out of order; the trend is ordered by time
+		prov := &provenance{RunID: []string{"b", "a"}[i], Version: "test", GeneratedAt: first.AddDate(0, 0, 1-i)}
+		if _, err := storeRun(path, prov, results, calculateStats(results), time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	points, err := loadTrendDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := points["8.8.8.8"]
+	if len(p) != 2 || p[0].RunID != "a" || p[0].Avg != 10*time.Millisecond || p[1].Avg != 30*time.Millisecond {
+		t.Errorf("unexpected points %+v", p)
+	}
+}
+
+func TestGenerateTrendHTML(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := buildTrends(map[string][]trendPoint{
+		"<script>": trendPoints(start, 10*time.Millisecond, 12*time.Millisecond, 14*time.Millisecond),
+		"1.1.1.1":  trendPoints(start, 5*time.Millisecond, 5*time.Millisecond, 5*time.Millisecond),
+	})
+	path := filepath.Join(t.TempDir(), "trend.html")
+	if err := generateTrendHTML(series, "results.db", path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, want := range []string{"<svg", "<polyline", "1.1.1.1", "worse", "stable"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("server name not escaped")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latencyFormat is how latencies are written in the main tables and the
+// exports once -unit or -precision is given: plain numbers in unit ("ms" or
+// "us") with precision decimals. The zero value keeps the defaults: Go
+// duration strings such as 1.234567ms in tables, milliseconds in exports.
+type latencyFormat struct {
+	unit      string
+	precision int
+}
+
+// latencyUnits are the units -unit accepts.
+var latencyUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+}
+
+// newLatencyFormat returns the format for a unit and precision, either of
+// which may be unset. The default precision is to the microsecond, which
+// is the resolution of the exports.
+func newLatencyFormat(unit string, precision *int) (latencyFormat, error) {
+	if unit == "" && precision == nil {
+		return latencyFormat{}, nil
+	}
+	if unit == "" {
+		unit = "ms"
+	}
+	if _, ok := latencyUnits[unit]; !ok {
+		return latencyFormat{}, fmt.Errorf("invalid unit %q (expected ms or us)", unit)
+	}
+	f := latencyFormat{unit: unit}
+	if unit == "ms" {
+		f.precision = 3
+	}
+	if precision != nil {
+		if *precision < 0 || *precision > 9 {
+			return latencyFormat{}, fmt.Errorf("invalid precision %d (expected 0 to 9 decimals)", *precision)
+		}
+		f.precision = *precision
+	}
+	return f, nil
+}
+
+// configLatencyFormat is the format cfg asks for. It was validated when the
+// run started; an invalid one falls back to the defaults.
+func configLatencyFormat(cfg Config) latencyFormat {
+	f, err := newLatencyFormat(cfg.Unit, cfg.Precision)
+	if err != nil {
+		return latencyFormat{}
+	}
+	return f
+}
+
+// set reports whether a unit or precision was chosen.
+func (f latencyFormat) set() bool {
+	return f.unit != ""
+}
+
+// number formats d in the unit, without the unit.
+func (f latencyFormat) number(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(latencyUnits[f.unit]), 'f', f.precision, 64)
+}
+
+// exportNumber formats d for an export column or field named with column:
+// milliseconds with four decimals by default.
+func (f latencyFormat) exportNumber(d time.Duration) string {
+	if !f.set() {
+		return strconv.FormatFloat(float64(d.Microseconds())/1000.0, 'f', 4, 64)
+	}
+	return f.number(d)
+}
+
+// cell formats d for a table whose header carries the unit (see header).
+func (f latencyFormat) cell(d time.Duration) string {
+	if !f.set() {
+		return d.String()
+	}
+	return f.number(d)
+}
+
+// header adds the unit to a latency column heading.
+func (f latencyFormat) header(name string) string {
+	if !f.set() {
+		return name
+	}
+	return name + " (" + f.unit + ")"
+}
+
+// column names a latency column or field of an export, such as
+// Duration_ms, after the unit.
+func (f latencyFormat) column(name string) string {
+	if !f.set() {
+		return name + "_ms"
+	}
+	return name + "_" + f.unit
+}
+
+// relabelLatencies rewrites the latency fields of a JSON result from one
+// unit to another: every number field ending in _<from> is renamed to end in
+// _<to> and converted, with precision decimals (-1 for as many as needed).
+// Field order and everything else is kept.
+func relabelLatencies(data []byte, from, to string, precision int) ([]byte, error) {
+	scale := float64(latencyUnits[from]) / float64(latencyUnits[to])
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if name, ok := strings.CutSuffix(key, "_"+from); ok {
+			if v, err := strconv.ParseFloat(string(value), 64); err == nil {
+				key = name + "_" + to
+				value = strconv.AppendFloat(nil, v*scale, 'f', precision, 64)
+			}
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(k)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestNewLatencyFormat(t *testing.T) {
+	two, negative := 2, -1
+	for _, tc := range []struct {
+		unit      string
+		precision *int
+		want      string
+		err       bool
+	}{
+		{"", nil, "1.234567ms", false},
+		{"ms", nil, "1.235", false},
+		{"us", nil, "1235", false},
+		{"", &two, "1.23", false},
+		{"us", &two, "1234.57", false},
+		{"ns", nil, "", true},
+		{"ms", &negative, "", true},
+	} {
+		f, err := newLatencyFormat(tc.unit, tc.precision)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: expected error %v, got %v", tc.unit, tc.err, err)
+			continue
+		}
+		if got := f.cell(1234567 * time.Nanosecond); !tc.err && got != tc.want {
+			t.Errorf("%q: expected %s, got %s", tc.unit, tc.want, got)
+		}
+	}
+}
+
+func TestRelabelLatencies(t *testing.T) {
+	in := `{"server":"8.8.8.8","duration_ms":12.345,"ttl_s":300,"svcb":{"records":1},"offset_ms":1.5}`
+	out, err := relabelLatencies([]byte(in), "ms", "us", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"server":"8.8.8.8","duration_us":12345,"ttl_s":300,"svcb":{"records":1},"offset_us":1500}`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+	back, err := relabelLatencies(out, "us", "ms", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(back), `"duration_ms":12.345`) || !strings.Contains(string(back), `"offset_ms":1.5`) {
+		t.Errorf("expected the latencies back in milliseconds, got %s", back)
+	}
+}
+
+func TestExportLatencyUnit(t *testing.T) {
+	prov := testProvenance()
+	prov.Config.Unit = "us"
+	results := []benchmark.Result{{Server: "8.8.8.8", Domain: "example.com", Duration: 12345 * time.Microsecond, Offset: time.Second}}
+	dir := t.TempDir()
+
+	csvPath, jsonPath := filepath.Join(dir, "results.csv"), filepath.Join(dir, "results.json")
+	if err := exportCSV(results, prov, csvPath); err != nil {
+		t.Fatalf("exportCSV failed: %v", err)
+	}
+	if err := exportJSON(results, prov, jsonPath); err != nil {
+		t.Fatalf("exportJSON failed: %v", err)
+	}
+	for path, want := range map[string][]string{
+		csvPath:  {"# schema_version: 3", "Duration_us", ",12345,", ",1000000,"},
+		jsonPath: {`"schema_version": 3`, `"latency_unit": "us"`, `"duration_us": 12345`, `"offset_us": 1000000`},
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(data), w) {
+				t.Errorf("%s: expected %s in:\n%s", filepath.Base(path), w, data)
+			}
+		}
+
+		// Exports in microseconds read back like any other
+		got, err := readResults(path)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		if len(got) != 1 || got[0].Duration != results[0].Duration || got[0].Offset != time.Second {
+			t.Errorf("%s: expected the results to round trip, got %+v", filepath.Base(path), got)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package validation
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
 	"regexp"
 	"strings"
@@ -17,6 +18,8 @@ const (
 var (
 	// Domain name regex: allows letters, numbers, hyphens, and dots
 	domainRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`)
+	// Label regex for names subject to search list expansion
+	labelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?$`)
 )
 
 // IsValidDomain checks if a domain name is valid according to DNS standards
@@ -56,6 +59,30 @@ func IsValidDomain(domain string) error {
 	return nil
 }
 
+// IsValidSearchName checks a name that will be expanded with a search list.
+// Unlike IsValidDomain it accepts single-label names ("kubernetes") and a
+// trailing dot marking the name as absolute ("example.com.").
+func IsValidSearchName(name string) error {
+	if name == "" || name == "." {
+		return fmt.Errorf("name cannot be empty")
+	}
+	if len(name) > maxDomainLength {
+		return fmt.Errorf("name exceeds maximum length of %d characters", maxDomainLength)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 {
+			return fmt.Errorf("name contains empty label")
+		}
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("name label '%s' exceeds maximum length of %d", label, maxLabelLength)
+		}
+		if !labelRegex.MatchString(label) {
+			return fmt.Errorf("invalid name label '%s'", label)
+		}
+	}
+	return nil
+}
+
 // IsValidServer checks if a server address is valid
 func IsValidServer(server string) error {
 	if server == "" {
@@ -64,17 +91,12 @@ func IsValidServer(server string) error {
 
 	// Handle DoH (HTTPS)
 	if strings.HasPrefix(server, "https://") {
-		u, err := url.Parse(server)
-		if err != nil {
-			return fmt.Errorf("invalid DoH URL: %w", err)
-		}
-		if u.Scheme != "https" {
-			return fmt.Errorf("DoH URL must use https scheme")
-		}
-		if u.Host == "" {
-			return fmt.Errorf("DoH URL must have a host")
-		}
-		return nil
+		return validateDoHURL(server)
+	}
+
+	// Handle DoH over HTTP/3, which uses the same URL with an h3 scheme
+	if rest, ok := strings.CutPrefix(server, "h3://"); ok {
+		return IsValidServer("https://" + rest)
 	}
 
 	// Handle DoT (TLS)
@@ -83,10 +105,44 @@ func IsValidServer(server string) error {
 		return validateHostPort(host, 853)
 	}
 
+	// Handle DNS forced over TCP
+	if rest, ok := strings.CutPrefix(server, "tcp://"); ok {
+		return validateHostPort(rest, 53)
+	}
+
 	// Handle standard UDP/TCP
 	return validateHostPort(server, 53)
 }
 
+// validateDoHURL checks a DoH endpoint. Any port, path and query parameters
+// are allowed (e.g. an internal proxy at https://10.1.2.3:8443/resolve?ct=),
+// except the dns parameter, which carries the query of GET requests.
+func validateDoHURL(server string) error {
+	u, err := url.Parse(server)
+	if err != nil {
+		return fmt.Errorf("invalid DoH URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("DoH URL must use https scheme")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("DoH URL must have a host")
+	}
+	if strings.HasSuffix(u.Host, ":") {
+		return fmt.Errorf("DoH URL has an empty port")
+	}
+	if err := validateHostPort(u.Host, 443); err != nil {
+		return fmt.Errorf("invalid DoH URL: %w", err)
+	}
+	if u.Fragment != "" || strings.HasSuffix(server, "#") {
+		return fmt.Errorf("DoH URL must not have a fragment")
+	}
+	if u.Query().Has("dns") {
+		return fmt.Errorf("DoH URL must not set the dns query parameter, which carries the query itself")
+	}
+	return nil
+}
+
 // validateHostPort validates a host:port or just host string
 func validateHostPort(hostPort string, _ int) error {
 	host, port, err := net.SplitHostPort(hostPort)
@@ -97,8 +153,8 @@ func validateHostPort(hostPort string, _ int) error {
 	}
 
 	// Validate host is either valid IP or domain
-	if ip := net.ParseIP(host); ip != nil {
-		// Valid IP address
+	if _, err := netip.ParseAddr(host); err == nil {
+		// Valid IP address (including zoned link-local IPv6, e.g. fe80::1%eth0)
 		if port != "" {
 			// Validate port range
 			portNum, err := net.LookupPort("tcp", port)
@@ -135,6 +191,16 @@ func validateHostPort(hostPort string, _ int) error {
 
 // ValidateDomains validates a list of domains and returns only valid ones with warnings
 func ValidateDomains(domains []string) ([]string, []string) {
+	return validateNames(domains, "domain", IsValidDomain)
+}
+
+// ValidateSearchNames is ValidateDomains for names that will be expanded
+// with a search list (see IsValidSearchName).
+func ValidateSearchNames(names []string) ([]string, []string) {
+	return validateNames(names, "name", IsValidSearchName)
+}
+
+func validateNames(domains []string, kind string, check func(string) error) ([]string, []string) {
 	valid := make([]string, 0, len(domains))
 	warnings := make([]string, 0)
 
@@ -147,14 +213,14 @@ func ValidateDomains(domains []string) ([]string, []string) {
 
 		// Check for duplicates
 		if seen[domain] {
-			warnings = append(warnings, fmt.Sprintf("duplicate domain ignored: %s", domain))
+			warnings = append(warnings, fmt.Sprintf("duplicate %s ignored: %s", kind, domain))
 			continue
 		}
 		seen[domain] = true
 
 		// Validate domain
-		if err := IsValidDomain(domain); err != nil {
-			warnings = append(warnings, fmt.Sprintf("invalid domain '%s': %v", domain, err))
+		if err := check(domain); err != nil {
+			warnings = append(warnings, fmt.Sprintf("invalid %s '%s': %v", kind, domain, err))
 			continue
 		}
 
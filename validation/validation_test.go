@@ -38,6 +38,32 @@ func TestIsValidDomain(t *testing.T) {
 	}
 }
 
+func TestIsValidSearchName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"single label", "kubernetes", false},
+		{"service name", "web.default", false},
+		{"absolute", "google.com.", false},
+		{"empty", "", true},
+		{"root only", ".", true},
+		{"double dots", "web..default", true},
+		{"special chars", "we@b", true},
+		{"starts with hyphen", "-web", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := IsValidSearchName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IsValidSearchName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestIsValidServer(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -47,15 +73,27 @@ func TestIsValidServer(t *testing.T) {
 		{"valid IP", "8.8.8.8", false},
 		{"valid IP with port", "8.8.8.8:53", false},
 		{"valid IPv6", "2001:4860:4860::8888", false},
+		{"zoned link-local IPv6 with port", "[fe80::1%eth0]:53", false},
 		{"valid hostname", "dns.google", false},
 		{"valid DoT", "tls://1.1.1.1", false},
 		{"valid DoT with port", "tls://1.1.1.1:853", false},
+		{"valid TCP", "tcp://1.1.1.1", false},
+		{"valid TCP with port", "tcp://1.1.1.1:5353", false},
+		{"invalid TCP port", "tcp://1.1.1.1:99999", true},
 		{"valid DoH", "https://dns.google/dns-query", false},
+		{"valid DoH over HTTP/3", "h3://dns.google/dns-query", false},
+		{"DoH over HTTP/3 without host", "h3:///dns-query", true},
 		{"invalid DoH scheme", "http://dns.google/dns-query", true},
 		{"empty server", "", true},
 		{"localhost", "localhost", false},
 		{"invalid port", "8.8.8.8:999999", true},
 		{"DoH without host", "https:///dns-query", true},
+		{"DoH with port, path and query", "https://10.1.2.3:8443/resolve?ct=", false},
+		{"DoH with IPv6 and port", "https://[2001:db8::1]:8443/dns-query", false},
+		{"DoH with out of range port", "https://10.1.2.3:99999/dns-query", true},
+		{"DoH with empty port", "https://10.1.2.3:/dns-query", true},
+		{"DoH with fragment", "https://dns.google/dns-query#frag", true},
+		{"DoH with dns parameter", "https://dns.google/dns-query?dns=AAAB", true},
 	}
 
 	for _, tt := range tests {
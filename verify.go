@@ -0,0 +1,356 @@
+package main
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"dns-bench/benchmark"
+	"dns-bench/validation"
+)
+
+// errVerifyFailed is returned by runVerify when the servers answered but
+// missed a threshold. main exits with status 2 for it, keeping status 1
+// for errors that kept verification from running at all.
+var errVerifyFailed = errors.New("verification failed")
+
+// verifyThresholds are what a server must meet to pass verification.
+type verifyThresholds struct {
+	// MinCorrect is the lowest acceptable share of checked answers that
+	// were as expected, in percent
+	MinCorrect float64
+	// MaxErrors is the highest acceptable share of failed lookups, in percent
+	MaxErrors float64
+	// MaxP95 is the highest acceptable 95th percentile latency; zero means
+	// no limit
+	MaxP95 time.Duration
+}
+
+// verifyCase is one pass/fail check against one server: either a question
+// from the batch file or one of the thresholds. Failure is empty when the
+// check passed.
+type verifyCase struct {
+	Name     string
+	Failure  string
+	Duration time.Duration
+	// Threshold marks the checks the exit status depends on
+	Threshold bool
+}
+
+// verifyReport is the outcome of verification for one server.
+type verifyReport struct {
+	Server     string
+	CorrectPct float64
+	ErrorPct   float64
+	P95        time.Duration
+	Cases      []verifyCase
+}
+
+// passed reports whether the server met every threshold.
+func (r verifyReport) passed() bool {
+	for _, c := range r.Cases {
+		if c.Threshold && c.Failure != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// runVerify implements the verify subcommand, which checks resolvers
+// against a batch of questions with expected answers, e.g. right after a
+// deployment, and exits non-zero when they miss the thresholds.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	var (
+		queryFile   string
+		serverList  string
+		iterations  int
+		concurrency int
+		timeout     time.Duration
+		queryType   string
+		junitFile   string
+		limits      verifyThresholds
+	)
+	fs.StringVar(&queryFile, "queries", "", "File of questions with expected answers (text or YAML, see -queries of the benchmark)")
+	fs.StringVar(&serverList, "server", "", "Comma-separated servers to verify")
+	fs.IntVar(&iterations, "n", 3, "Number of times each question is asked per server")
+	fs.IntVar(&concurrency, "c", 10, "Number of concurrent queries")
+	fs.DurationVar(&timeout, "t", 2*time.Second, "Timeout for each query")
+	fs.StringVar(&queryType, "type", "A", "Record type for questions that don't name one")
+	fs.StringVar(&junitFile, "junit", "", "Write the results as a JUnit XML report to this file")
+	fs.Float64Var(&limits.MinCorrect, "min-correct", 100, "Lowest acceptable percentage of answers matching the expectations")
+	fs.Float64Var(&limits.MaxErrors, "max-errors", 0, "Highest acceptable percentage of failed lookups")
+	fs.DurationVar(&limits.MaxP95, "max-p95", 0, "Highest acceptable 95th percentile latency (default no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s verify [options] -queries <file> -server <addr>[,<addr>...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exits 0 when every server passes, 1 on errors and 2 when a threshold is missed.\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if queryFile == "" || serverList == "" {
+		fs.Usage()
+		return fmt.Errorf("-queries and -server are required")
+	}
+	servers := splitList(serverList)
+	for _, s := range servers {
+		if err := validation.IsValidServer(s); err != nil {
+			return fmt.Errorf("invalid server %q: %w", s, err)
+		}
+	}
+	qtype, ok := dns.StringToType[strings.ToUpper(queryType)]
+	if !ok {
+		return fmt.Errorf("unknown query type %q", queryType)
+	}
+
+	questions, err := readQueries(queryFile)
+	if err != nil {
+		return fmt.Errorf("reading query file: %w", err)
+	}
+	names := make([]string, len(questions))
+	for i, q := range questions {
+		names[i] = q.Name
+	}
+	domains, warnings := validation.ValidateDomains(names)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	questions = keepValidQuestions(questions, domains)
+	if len(questions) == 0 {
+		return fmt.Errorf("no valid questions in %s", queryFile)
+	}
+
+	fmt.Printf("Verifying %d servers with %d questions x %d iterations...\n", len(servers), len(questions), iterations)
+	start := time.Now()
+	results := benchmark.Run(benchmark.Config{
+		Servers:     servers,
+		Domains:     domains,
+		Questions:   questions,
+		Iterations:  iterations,
+		Concurrency: concurrency,
+		Timeout:     timeout,
+		QType:       qtype,
+	})
+	elapsed := time.Since(start)
+
+	reports := verifyResults(servers, questions, qtype, results, limits)
+	printVerify(reports)
+	if junitFile != "" {
+		if err := writeJUnit(reports, elapsed, junitFile); err != nil {
+			return fmt.Errorf("writing JUnit report: %w", err)
+		}
+		fmt.Printf("JUnit report written to %s\n", junitFile)
+	}
+	for _, r := range reports {
+		if !r.passed() {
+			return errVerifyFailed
+		}
+	}
+	return nil
+}
+
+// verifyResults turns each server's results into a check per question,
+// failed when any lookup of it failed or came back unexpected, followed by
+// the threshold checks.
+func verifyResults(servers []string, questions []benchmark.Question, qtype uint16, results []benchmark.Result, limits verifyThresholds) []verifyReport {
+	type tally struct {
+		total     int
+		problems  []string
+		totalTime time.Duration
+	}
+	byServer := make(map[string]map[string]*tally, len(servers))
+	for _, res := range results {
+		key := res.Domain + " " + res.QType
+		if byServer[res.Server] == nil {
+			byServer[res.Server] = make(map[string]*tally)
+		}
+		t := byServer[res.Server][key]
+		if t == nil {
+			t = &tally{}
+			byServer[res.Server][key] = t
+		}
+		t.total++
+		t.totalTime += res.Duration
+		switch {
+		case res.Error != nil:
+			t.problems = append(t.problems, res.Error.Error())
+		case res.Mismatch != "":
+			t.problems = append(t.problems, res.Mismatch)
+		}
+	}
+
+	stats := calculateStats(results)
+	expectations := make(map[string]expectationStats)
+	for _, e := range checkExpectations(stats, results) {
+		expectations[e.Server] = e
+	}
+	errorPct := make(map[string]float64, len(stats))
+	for _, s := range stats {
+		errorPct[s.Server] = float64(s.Errors) / float64(s.Total) * 100
+	}
+	durations := serverDurations(results)
+
+	reports := make([]verifyReport, 0, len(servers))
+	for _, server := range servers {
+		r := verifyReport{Server: server, CorrectPct: 100, ErrorPct: errorPct[server]}
+		if e, ok := expectations[server]; ok {
+			r.CorrectPct = float64(e.Correct) / float64(e.Checked) * 100
+		}
+		r.P95 = percentile(durations[server], 95)
+
+		seen := make(map[string]bool, len(questions))
+		for _, q := range questions {
+			key := q.Name + " " + dns.TypeToString[cmp.Or(q.Type, qtype, dns.TypeA)]
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			c := verifyCase{Name: key}
+			t := byServer[server][key]
+			switch {
+			case t == nil:
+				c.Failure = "never asked"
+			case len(t.problems) > 0:
+				c.Failure = fmt.Sprintf("%d of %d lookups went wrong: %s", len(t.problems), t.total, t.problems[0])
+				c.Duration = t.totalTime / time.Duration(t.total)
+			default:
+				c.Duration = t.totalTime / time.Duration(t.total)
+			}
+			r.Cases = append(r.Cases, c)
+		}
+
+		correct := verifyCase{Name: fmt.Sprintf("correct answers >= %g%%", limits.MinCorrect), Threshold: true}
+		if r.CorrectPct < limits.MinCorrect {
+			correct.Failure = fmt.Sprintf("%.1f%% of answers were as expected", r.CorrectPct)
+		}
+		errs := verifyCase{Name: fmt.Sprintf("failed lookups <= %g%%", limits.MaxErrors), Threshold: true}
+		if r.ErrorPct > limits.MaxErrors {
+			errs.Failure = fmt.Sprintf("%.1f%% of lookups failed", r.ErrorPct)
+		}
+		r.Cases = append(r.Cases, correct, errs)
+		if limits.MaxP95 > 0 {
+			p95 := verifyCase{Name: fmt.Sprintf("p95 latency <= %v", limits.MaxP95), Threshold: true, Duration: r.P95}
+			if r.P95 > limits.MaxP95 {
+				p95.Failure = fmt.Sprintf("p95 latency was %v", r.P95)
+			}
+			r.Cases = append(r.Cases, p95)
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// printVerify shows each server's verdict and what went wrong.
+func printVerify(reports []verifyReport) {
+	fmt.Printf("\nVerification\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "SERVER\tQUESTIONS PASSED\tCORRECT %\tERRORS %\tP95\tRESULT"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write header: %v\n", err)
+	}
+	for _, r := range reports {
+		passed, questions := 0, 0
+		for _, c := range r.Cases {
+			if c.Threshold {
+				continue
+			}
+			questions++
+			if c.Failure == "" {
+				passed++
+			}
+		}
+		result := "PASS"
+		if !r.passed() {
+			result = "FAIL"
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d/%d\t%.1f%%\t%.1f%%\t%v\t%s\n", r.Server, passed, questions, r.CorrectPct, r.ErrorPct, r.P95, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to write row: %v\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to flush output: %v\n", err)
+	}
+
+	failed := false
+	for _, r := range reports {
+		for _, c := range r.Cases {
+			if c.Failure != "" {
+				fmt.Printf("✗ %s: %s: %s\n", r.Server, c.Name, c.Failure)
+			}
+		}
+		failed = failed || !r.passed()
+	}
+	if failed {
+		fmt.Println("✗ Verification failed")
+	} else {
+		fmt.Println("✓ Every server passed")
+	}
+}
+
+// junitTestSuites is the root of a JUnit XML report, with one suite per
+// server and one test case per check.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr"`
+	Tests   int              `xml:"tests,attr"`
+	Fails   int              `xml:"failures,attr"`
+	Time    string           `xml:"time,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name  string          `xml:"name,attr"`
+	Tests int             `xml:"tests,attr"`
+	Fails int             `xml:"failures,attr"`
+	Cases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// junitSeconds formats d the way JUnit reports expect times.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit writes reports to path as JUnit XML, for CI systems to show.
+func writeJUnit(reports []verifyReport, elapsed time.Duration, path string) error {
+	root := junitTestSuites{Name: "dns-bench verify", Time: junitSeconds(elapsed)}
+	for _, r := range reports {
+		suite := junitTestSuite{Name: r.Server}
+		for _, c := range r.Cases {
+			tc := junitTestCase{Name: c.Name, Classname: r.Server, Time: junitSeconds(c.Duration)}
+			if c.Failure != "" {
+				tc.Failure = &junitFailure{Message: c.Failure}
+				suite.Fails++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		root.Tests += suite.Tests
+		root.Fails += suite.Fails
+		root.Suites = append(root.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o600)
+}
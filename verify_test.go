@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-bench/benchmark"
+)
+
+func TestRunVerify(t *testing.T) {
+	mock, err := benchmark.StartMockServer()
+	if err != nil {
+		t.Fatalf("failed to start mock server: %v", err)
+	}
+	defer func() { _ = mock.Close() }()
+
+	dir := t.TempDir()
+	queries := filepath.Join(dir, "acceptance.yaml")
+	data := `queries:
+  - name: example.com
+    expect: [127.0.0.1]
+  - name: example.org
+    type: TXT
+    rcode: NOERROR
+`
+	if err := os.WriteFile(queries, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	junit := filepath.Join(dir, "junit.xml")
+	if err := runVerify([]string{"-queries", queries, "-server", mock.Addr, "-n", "1", "-junit", junit}); err != nil {
+		t.Fatalf("expected verification to pass, got %v", err)
+	}
+	report, err := os.ReadFile(junit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), `<testcase name="example.com A"`) || strings.Contains(string(report), "<failure") {
+		t.Errorf("expected a passing case per question, got %s", report)
+	}
+
+	if err := os.WriteFile(queries, []byte("queries:\n  - name: example.com\n    type: AAAA\n    expect: [\"::1\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := runVerify([]string{"-queries", queries, "-server", mock.Addr, "-n", "1"}); !errors.Is(err, errVerifyFailed) {
+		t.Errorf("expected a failed verification, got %v", err)
+	}
+}
+
+func TestVerifyResultsThresholds(t *testing.T) {
+	questions := []benchmark.Question{{Name: "example.com", ExpectAnswers: []string{"192.0.2.1"}}}
+	results := []benchmark.Result{
+		{Server: "s", Domain: "example.com", QType: "A", Duration: 10 * time.Millisecond, Checked: true},
+		{Server: "s", Domain: "example.com", QType: "A", Duration: 90 * time.Millisecond, Checked: true, Mismatch: "missing 192.0.2.1"},
+	}
+	reports := verifyResults([]string{"s"}, questions, 0, results, verifyThresholds{MinCorrect: 50, MaxP95: 50 * time.Millisecond})
+	r := reports[0]
+	if r.CorrectPct != 50 || r.P95 != 90*time.Millisecond {
+		t.Errorf("expected 50%% correct and a 90ms p95, got %+v", r)
+	}
+	var failed []string
+	for _, c := range r.Cases {
+		if c.Failure != "" {
+			failed = append(failed, c.Name)
+		}
+	}
+	if strings.Join(failed, "; ") != "example.com A; p95 latency <= 50ms" {
+		t.Errorf("unexpected failed checks %v", failed)
+	}
+	if r.passed() {
+		t.Error("expected the p95 threshold to fail the server")
+	}
+}
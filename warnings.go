@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// runWarning is something that went wrong preparing or running a benchmark
+// without stopping it, such as a dropped domain or an unreachable proxy.
+type runWarning struct {
+	// Source is the part of the run it concerns: servers, domains, lists,
+	// proxy, nat64, preset, rate-limit, health, split-horizon, calibration,
+	// low-memory, baseline or diagnosis
+	Source  string `yaml:"source"`
+	Message string `yaml:"message"`
+	// Detail marks one of many itemised warnings, such as every dropped
+	// domain, that a summary warning of the same source already counts
+	Detail bool `yaml:"detail,omitempty"`
+}
+
+// warningLog collects a run's warnings, so they are listed together after
+// the results and embedded in the exports rather than interleaved with the
+// progress output. A nil log drops everything.
+type warningLog struct {
+	mu   sync.Mutex
+	list []runWarning
+}
+
+// add records a warning from source.
+func (l *warningLog) add(source, format string, args ...any) {
+	l.record(runWarning{Source: source, Message: fmt.Sprintf(format, args...)})
+}
+
+// addDropped records the validation warnings for a list of kind (servers,
+// domains) with a summary of how many of total entries were dropped.
+func (l *warningLog) addDropped(source, kind string, warnings []string, total, kept int) {
+	if len(warnings) == 0 {
+		return
+	}
+	if dropped := total - kept; dropped > 0 {
+		l.add(source, "%d of %d %s dropped by validation", dropped, total, kind)
+	} else {
+		l.add(source, "%d %s validation warnings", len(warnings), kind)
+	}
+	for _, w := range warnings {
+		l.record(runWarning{Source: source, Message: w, Detail: true})
+	}
+}
+
+func (l *warningLog) record(w runWarning) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = append(l.list, w)
+}
+
+// warnings returns a copy of the warnings recorded so far.
+func (l *warningLog) warnings() []runWarning {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]runWarning(nil), l.list...)
+}
+
+// printWarnings lists the run's warnings; itemised details only with
+// verbose, otherwise counted.
+func printWarnings(warnings []runWarning, verbose bool) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	fmt.Printf("\nWarnings\n\n")
+	hidden := 0
+	for _, w := range warnings {
+		switch {
+		case !w.Detail:
+			fmt.Printf("⚠️  %s: %s\n", w.Source, w.Message)
+		case verbose:
+			fmt.Printf("     - %s\n", w.Message)
+		default:
+			hidden++
+		}
+	}
+	if hidden > 0 {
+		fmt.Printf("(%d more with -v; every warning is also in the JSON and HTML exports)\n", hidden)
+	}
+}
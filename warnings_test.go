@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dns-bench/benchmark"
+)
+
+func TestWarningLogInExports(t *testing.T) {
+	warns := &warningLog{}
+	warns.add("proxy", "proxy is not reachable (is it running?): %v", os.ErrDeadlineExceeded)
+	warns.addDropped("domains", "domains", []string{"invalid domain 'bad..name'", "duplicate domain ignored: example.com"}, 5, 3)
+	warns.addDropped("servers", "servers", nil, 2, 2)
+
+	prov := testProvenance()
+	prov.Warnings = warns.warnings()
+	if len(prov.Warnings) != 4 || prov.Warnings[1].Message != "2 of 5 domains dropped by validation" || !prov.Warnings[2].Detail {
+		t.Fatalf("expected a proxy warning, a domain summary and two details, got %+v", prov.Warnings)
+	}
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := exportJSON([]benchmark.Result{{Server: "8.8.8.8", Domain: "example.com"}}, prov, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Provenance struct {
+			Warnings []struct {
+				Source  string `json:"source"`
+				Message string `json:"message"`
+				Detail  bool   `json:"detail"`
+			} `json:"warnings"`
+		} `json:"provenance"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if w := out.Provenance.Warnings; len(w) != 4 || w[0].Source != "proxy" || !w[3].Detail {
+		t.Errorf("expected the warnings in the JSON export, got %+v", w)
+	}
+
+	// Itemised domain warnings would reveal the names
+	anon := &anonymizer{key: []byte("key")}
+	if w := anon.provenance(prov).Warnings; len(w) != 2 || w[1].Detail {
+		t.Errorf("expected only the summaries in an anonymized export, got %+v", w)
+	}
+	if len(prov.Warnings) != 4 {
+		t.Error("anonymizing should not change the original warnings")
+	}
+}